@@ -0,0 +1,148 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AccessLogRecord is the structured access log record of an ldap operation
+// (see: WithAccessLog)
+type AccessLogRecord struct {
+	// Time the operation's request was received
+	Time time.Time `json:"time"`
+	// Duration between receiving the request and writing its final response
+	Duration time.Duration `json:"duration"`
+	// ConnectionID of the request's conn
+	ConnectionID int `json:"connectionID"`
+	// MessageID of the request
+	MessageID int64 `json:"messageID"`
+	// CorrelationID of the request (see: Request.CorrelationID)
+	CorrelationID string `json:"correlationID"`
+	// Operation of the request
+	Operation RouteOperation `json:"operation"`
+	// ExtendedName is the name of an extended operation
+	ExtendedName ExtendedOperationName `json:"extendedName,omitempty"`
+	// BoundDN is the DN the request's conn was bound as when the request was
+	// responded to
+	BoundDN string `json:"boundDN,omitempty"`
+	// DN that the request's operation targets (i.e. a search's base DN)
+	DN string `json:"dn,omitempty"`
+	// Scope of a search
+	Scope Scope `json:"scope,omitempty"`
+	// Filter of a search
+	Filter string `json:"filter,omitempty"`
+	// ResultCode of the request's final response, which is -1 for the
+	// operations without a response (abandon and unbind)
+	ResultCode int `json:"resultCode"`
+	// DiagnosticMessage of the request's final response
+	DiagnosticMessage string `json:"diagnosticMessage,omitempty"`
+	// Entries is the number of entries returned by a search
+	Entries int64 `json:"entries,omitempty"`
+}
+
+// AccessLogSink receives a server's access log records (see: WithAccessLog)
+type AccessLogSink func(AccessLogRecord)
+
+// WithAccessLog enables the server's access log, which sends one record for
+// every operation to the sink (see: AccessLogToLogger and AccessLogToWriter,
+// or a func which sends them to a channel in tests).  The record of an
+// operation is sent before its final response is written, so it's received
+// before the client gets the response.  The sink is called synchronously, so
+// it must be safe for concurrent use and it shouldn't block.
+func WithAccessLog(sink AccessLogSink) Option {
+	return serverOption(func(o *configOptions) {
+		o.withAccessLog = sink
+	})
+}
+
+// AccessLogToLogger returns an AccessLogSink which logs the records at the
+// info level with the logger (i.e. a *slog.Logger, see: NewSlogLogger)
+func AccessLogToLogger(l Logger) AccessLogSink {
+	return func(r AccessLogRecord) {
+		l.Info("access", r.logArgs()...)
+	}
+}
+
+// logArgs returns the record's key/value pairs for a Logger
+func (r AccessLogRecord) logArgs() []interface{} {
+	args := []interface{}{
+		"conn", r.ConnectionID,
+		"msgID", r.MessageID,
+		"correlationID", r.CorrelationID,
+		"operation", r.Operation,
+		"resultCode", r.ResultCode,
+		"duration", r.Duration,
+	}
+	if r.ExtendedName != "" {
+		args = append(args, "extendedName", r.ExtendedName)
+	}
+	if r.BoundDN != "" {
+		args = append(args, "boundDN", r.BoundDN)
+	}
+	if r.DN != "" {
+		args = append(args, "dn", r.DN)
+	}
+	if r.Operation == SearchRouteOperation {
+		args = append(args, "scope", r.Scope, "filter", r.Filter, "entries", r.Entries)
+	}
+	if r.DiagnosticMessage != "" {
+		args = append(args, "diagnosticMessage", r.DiagnosticMessage)
+	}
+	return args
+}
+
+// AccessLogToWriter returns an AccessLogSink which writes the records to w as
+// JSON, one record per line (i.e. to an access log file).  Records that
+// can't be written are dropped.
+func AccessLogToWriter(w io.Writer) AccessLogSink {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(r AccessLogRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = enc.Encode(r)
+	}
+}
+
+// logAccess sends the access log record of the request to the conn's access
+// log, with the request's final response, which is nil for the operations
+// without a response.
+func (c *conn) logAccess(r *Request, resp Response) {
+	if c.accessLog == nil || r == nil {
+		return
+	}
+	c.accessLog(c.accessLogRecord(r, resp))
+}
+
+// accessLogRecord returns the access log record of the request, with its final
+// response, which is nil for the operations without a response.
+func (c *conn) accessLogRecord(r *Request, resp Response) AccessLogRecord {
+	rec := AccessLogRecord{
+		Time:          r.received,
+		Duration:      c.clock.Now().Sub(r.received),
+		ConnectionID:  c.connID,
+		CorrelationID: r.correlationID,
+		Operation:     r.routeOp,
+		ExtendedName:  r.extendedName,
+		BoundDN:       c.getBoundDN(),
+		ResultCode:    -1,
+		Entries:       r.entries.Load(),
+	}
+	if r.message != nil {
+		rec.MessageID = r.message.GetID()
+	}
+	rec.DN, _ = r.targetDN()
+	if m, ok := r.message.(*SearchMessage); ok {
+		rec.Scope, rec.Filter = m.Scope, m.Filter
+	}
+	if d, ok := resp.(diagnosticResponse); ok {
+		rec.ResultCode = d.resultCode()
+		rec.DiagnosticMessage = d.diagnosticMessage()
+	}
+	return rec
+}
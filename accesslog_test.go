@@ -0,0 +1,113 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WithAccessLog(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	records := make(chan AccessLogRecord, 10)
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		clock.Advance(time.Second)
+		_ = w.Write(r.NewSearchResponseEntry("uid=alice,ou=people,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchResponseEntry("uid=bob,ou=people,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultNoSuchObject), WithDiagnosticMessage("no such entry")))
+	}))
+	_, url := testServer(t, mux, WithAccessLog(func(r AccessLogRecord) { records <- r }), WithClock(clock))
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+
+	assert, require := assert.New(t), require.New(t)
+	require.NoError(client.Bind("cn=alice", "password"))
+	res, err := client.Search(ldap.NewSearchRequest("ou=people,dc=example,dc=org", ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil))
+	require.NoError(err)
+	require.Len(res.Entries, 2)
+	require.Error(client.Del(ldap.NewDelRequest("uid=eve,ou=people,dc=example,dc=org", nil)))
+	require.NoError(client.Unbind())
+
+	bind := <-records
+	assert.Equal(BindRouteOperation, bind.Operation)
+	assert.Equal("cn=alice", bind.DN)
+	assert.Equal("cn=alice", bind.BoundDN)
+	assert.Equal(ResultSuccess, bind.ResultCode)
+	assert.NotZero(bind.ConnectionID)
+	assert.NotZero(bind.MessageID)
+
+	search := <-records
+	assert.Equal(SearchRouteOperation, search.Operation)
+	assert.Equal("ou=people,dc=example,dc=org", search.DN)
+	assert.Equal(SingleLevel, search.Scope)
+	assert.Equal("(uid=*)", search.Filter)
+	assert.Equal(int64(2), search.Entries)
+	assert.Equal(ResultSuccess, search.ResultCode)
+	assert.Equal(time.Second, search.Duration)
+	assert.Equal(bind.ConnectionID, search.ConnectionID)
+	assert.Greater(search.MessageID, bind.MessageID)
+
+	del := <-records
+	assert.Equal(DeleteRouteOperation, del.Operation)
+	assert.Equal("uid=eve,ou=people,dc=example,dc=org", del.DN)
+	assert.Equal(ResultNoSuchObject, del.ResultCode)
+	assert.Equal("no such entry", del.DiagnosticMessage)
+
+	// an unbind has a record without a result
+	unbind := <-records
+	assert.Equal(UnbindRouteOperation, unbind.Operation)
+	assert.Equal(-1, unbind.ResultCode)
+}
+
+func TestAccessLogToWriter(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	buf := testSafeBuf(t)
+	sink := AccessLogToWriter(buf)
+	sink(AccessLogRecord{ConnectionID: 1, MessageID: 2, Operation: SearchRouteOperation, DN: "dc=example,dc=org", Scope: WholeSubtree, Filter: "(uid=alice)", Entries: 1})
+	sink(AccessLogRecord{ConnectionID: 1, MessageID: 3, Operation: UnbindRouteOperation, ResultCode: -1})
+
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+	var got []AccessLogRecord
+	for scanner.Scan() {
+		var r AccessLogRecord
+		require.NoError(json.Unmarshal(scanner.Bytes(), &r))
+		got = append(got, r)
+	}
+	require.Len(got, 2)
+	assert.Equal("(uid=alice)", got[0].Filter)
+	assert.Equal(int64(1), got[0].Entries)
+	assert.Equal(-1, got[1].ResultCode)
+	assert.Contains(buf.String(), `"operation":"search"`)
+}
+
+func TestAccessLogToLogger(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	buf := testSafeBuf(t)
+	sink := AccessLogToLogger(hclog.New(&hclog.LoggerOptions{Output: buf, Level: hclog.Info}))
+	sink(AccessLogRecord{ConnectionID: 1, MessageID: 2, Operation: SearchRouteOperation, DN: "dc=example,dc=org", Filter: "(uid=alice)", Entries: 1})
+	logged := buf.String()
+	assert.Contains(logged, "access")
+	assert.Contains(logged, `dn="dc=example,dc=org"`)
+	assert.Contains(logged, `filter="(uid=alice)"`)
+	assert.Contains(logged, "entries=1")
+}
@@ -0,0 +1,260 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"net"
+	"time"
+)
+
+// Auditor receives a server's audit events (see: WithAuditor), which are
+// typed so compliance logging can record the details of each kind of event
+// (i.e. with a type switch).
+type Auditor interface {
+	// Audit receives an audit event, which is one of: ConnectionOpenedEvent,
+	// ConnectionClosedEvent, BindAttemptEvent, BindSuccessEvent,
+	// BindFailureEvent, SearchPerformedEvent or EntryModifiedEvent
+	Audit(e AuditEvent)
+}
+
+// AuditFunc is an adapter to use a func as an Auditor
+type AuditFunc func(AuditEvent)
+
+// Audit calls f(e)
+func (f AuditFunc) Audit(e AuditEvent) {
+	f(e)
+}
+
+// AuditEvent is an event received by an Auditor
+type AuditEvent interface {
+	// EventTime returns when the event occurred
+	EventTime() time.Time
+	// EventConnectionID returns the ID of the event's connection
+	EventConnectionID() int
+
+	auditEvent()
+}
+
+// AuditConnection is the connection of an audit event
+type AuditConnection struct {
+	// Time of the event
+	Time time.Time
+	// ConnectionID of the event's connection
+	ConnectionID int
+	// RemoteAddr is the network address of the connection's client
+	RemoteAddr net.Addr
+	// LocalAddr is the network address the connection was accepted on
+	LocalAddr net.Addr
+}
+
+// EventTime returns when the event occurred
+func (c AuditConnection) EventTime() time.Time { return c.Time }
+
+// EventConnectionID returns the ID of the event's connection
+func (c AuditConnection) EventConnectionID() int { return c.ConnectionID }
+
+func (AuditConnection) auditEvent() {}
+
+// AuditRequest is the request of an audit event
+type AuditRequest struct {
+	AuditConnection
+	// MessageID of the request
+	MessageID int64
+	// CorrelationID of the request (see: Request.CorrelationID)
+	CorrelationID string
+	// BoundDN is the DN the request's connection was bound as when the event
+	// occurred, which is empty when anonymous
+	BoundDN string
+}
+
+// ConnectionOpenedEvent is audited when a connection is accepted, once it's
+// been accepted by the server's on connect handler (see: WithOnConnect)
+type ConnectionOpenedEvent struct {
+	AuditConnection
+}
+
+// ConnectionClosedEvent is audited when a connection that was opened is
+// closed
+type ConnectionClosedEvent struct {
+	AuditConnection
+	// Duration the connection was open
+	Duration time.Duration
+}
+
+// BindAttemptEvent is audited when a bind request is received, before it's
+// served
+type BindAttemptEvent struct {
+	AuditRequest
+	// DN of the bind
+	DN string
+	// AuthChoice of the bind
+	AuthChoice AuthChoice
+}
+
+// BindSuccessEvent is audited when a bind request is successful
+type BindSuccessEvent struct {
+	AuditRequest
+	// DN of the bind
+	DN string
+	// AuthChoice of the bind
+	AuthChoice AuthChoice
+}
+
+// BindFailureEvent is audited when a bind request fails
+type BindFailureEvent struct {
+	AuditRequest
+	// DN of the bind
+	DN string
+	// AuthChoice of the bind
+	AuthChoice AuthChoice
+	// ResultCode of the bind's response
+	ResultCode int
+	// DiagnosticMessage of the bind's response
+	DiagnosticMessage string
+}
+
+// SearchPerformedEvent is audited when a search request is responded to,
+// whether or not it's successful
+type SearchPerformedEvent struct {
+	AuditRequest
+	// BaseDN of the search
+	BaseDN string
+	// Scope of the search
+	Scope Scope
+	// Filter of the search
+	Filter string
+	// Attributes requested by the search
+	Attributes []string
+	// Entries is the number of entries returned by the search
+	Entries int64
+	// ResultCode of the search's done response
+	ResultCode int
+}
+
+// EntryModifiedEvent is audited when an add, modify, modify DN, delete or
+// password modify request is successful
+type EntryModifiedEvent struct {
+	AuditRequest
+	// Operation of the request
+	Operation RouteOperation
+	// DN of the modified entry
+	DN string
+}
+
+// WithAuditor sets an auditor which receives the server's audit events (see:
+// AuditEvent).  Unlike the events of an event sink (see: WithEventSink), the
+// audit events include bind attempts and searches, and each kind of event has
+// its own type with the details a compliance log needs (i.e. the bind's
+// authentication choice or the search's filter and number of entries).  An
+// event is audited before the final response of its request is written, and
+// the auditor is called synchronously, so a handler's response can't reach the
+// client without being recorded.  The auditor must be safe for concurrent use.
+func WithAuditor(a Auditor) Option {
+	return serverOption(func(o *configOptions) {
+		o.withAuditor = a
+	})
+}
+
+// auditConnection returns the audit connection of the conn's events
+func (c *conn) auditConnection() AuditConnection {
+	return AuditConnection{
+		Time:         c.clock.Now(),
+		ConnectionID: c.connID,
+		RemoteAddr:   c.remoteAddr,
+		LocalAddr:    c.localAddr,
+	}
+}
+
+// auditRequest returns the audit request of the request's events
+func (c *conn) auditRequest(r *Request) AuditRequest {
+	ar := AuditRequest{
+		AuditConnection: c.auditConnection(),
+		CorrelationID:   r.correlationID,
+		BoundDN:         c.getBoundDN(),
+	}
+	if r.message != nil {
+		ar.MessageID = r.message.GetID()
+	}
+	return ar
+}
+
+// auditConnOpened audits the opening of the conn
+func (c *conn) auditConnOpened() {
+	if c.auditor == nil {
+		return
+	}
+	c.auditor.Audit(ConnectionOpenedEvent{AuditConnection: c.auditConnection()})
+}
+
+// auditConnClosed audits the closing of the conn
+func (c *conn) auditConnClosed() {
+	if c.auditor == nil {
+		return
+	}
+	ac := c.auditConnection()
+	c.auditor.Audit(ConnectionClosedEvent{AuditConnection: ac, Duration: ac.Time.Sub(c.opened)})
+}
+
+// auditBindAttempt audits the bind request when it's received
+func (c *conn) auditBindAttempt(r *Request) {
+	if c.auditor == nil {
+		return
+	}
+	e := BindAttemptEvent{AuditRequest: c.auditRequest(r)}
+	if m, ok := r.message.(*SimpleBindMessage); ok {
+		e.DN, e.AuthChoice = m.UserName, m.AuthChoice
+	}
+	c.auditor.Audit(e)
+}
+
+// audit audits the event of the request when the response is its final
+// response to a bind, a search or an update request.
+func (rw *ResponseWriter) audit(r Response) {
+	if rw.request == nil || rw.request.conn == nil || rw.request.conn.auditor == nil {
+		return
+	}
+	kind, resp, ok := requestEvent(rw.request, r)
+	if !ok {
+		return
+	}
+	req, c := rw.request, rw.request.conn
+	var dn string
+	var choice AuthChoice
+	if m, ok := req.message.(*SimpleBindMessage); ok {
+		dn, choice = m.UserName, m.AuthChoice
+	}
+	var e AuditEvent
+	switch kind {
+	case bindSucceededKind:
+		e = BindSuccessEvent{AuditRequest: c.auditRequest(req), DN: dn, AuthChoice: choice}
+	case bindFailedKind:
+		e = BindFailureEvent{
+			AuditRequest:      c.auditRequest(req),
+			DN:                dn,
+			AuthChoice:        choice,
+			ResultCode:        resp.resultCode(),
+			DiagnosticMessage: resp.diagnosticMessage(),
+		}
+	case searchPerformedKind:
+		m, ok := req.message.(*SearchMessage)
+		if !ok {
+			return
+		}
+		e = SearchPerformedEvent{
+			AuditRequest: c.auditRequest(req),
+			BaseDN:       m.BaseDN,
+			Scope:        m.Scope,
+			Filter:       m.Filter,
+			Attributes:   m.Attributes,
+			Entries:      req.entries.Load(),
+			ResultCode:   resp.resultCode(),
+		}
+	case entryModifiedKind:
+		dn, _ := req.targetDN()
+		e = EntryModifiedEvent{AuditRequest: c.auditRequest(req), Operation: req.routeOp, DN: dn}
+	default:
+		return
+	}
+	c.auditor.Audit(e)
+}
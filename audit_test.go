@@ -0,0 +1,140 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuditor records the events it receives
+type testAuditor struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (a *testAuditor) Audit(e AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, e)
+}
+
+func (a *testAuditor) audited() []AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AuditEvent{}, a.events...)
+}
+
+func TestServer_WithAuditor(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetSimpleBindMessage()
+		if err != nil || string(m.Password) != "password" {
+			resp := r.NewBindResponse(WithResponseCode(ResultInvalidCredentials))
+			resp.SetDiagnosticMessage("invalid credentials")
+			_ = w.Write(resp)
+			return
+		}
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("uid=bob,ou=people,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultNoSuchObject)))
+	}))
+	a := &testAuditor{}
+	_, url := testServer(t, mux, WithAuditor(a), WithClock(clock))
+
+	assert, require := assert.New(t), require.New(t)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	assert.Error(client.Bind("cn=alice", "bad"))
+	require.NoError(client.Bind("cn=alice", "password"))
+	_, err = client.Search(ldap.NewSearchRequest("ou=people,dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=bob)", []string{"cn"}, nil))
+	require.NoError(err)
+	mod := ldap.NewModifyRequest("uid=bob,ou=people,dc=example,dc=org", nil)
+	mod.Replace("cn", []string{"bob"})
+	require.NoError(client.Modify(mod))
+	// failed updates aren't audited
+	assert.Error(client.Del(ldap.NewDelRequest("uid=eve,ou=people,dc=example,dc=org", nil)))
+	clock.Advance(time.Minute)
+	require.NoError(client.Unbind())
+	assert.Eventually(func() bool { return len(a.audited()) == 8 }, time.Second, 10*time.Millisecond)
+
+	got := a.audited()
+	require.Len(got, 8)
+	connID := got[0].EventConnectionID()
+	assert.NotZero(connID)
+	for _, e := range got {
+		assert.Equal(connID, e.EventConnectionID())
+		assert.False(e.EventTime().IsZero())
+	}
+
+	opened, ok := got[0].(ConnectionOpenedEvent)
+	require.True(ok, "%T", got[0])
+	assert.NotNil(opened.RemoteAddr)
+	assert.NotNil(opened.LocalAddr)
+
+	attempt, ok := got[1].(BindAttemptEvent)
+	require.True(ok, "%T", got[1])
+	assert.Equal("cn=alice", attempt.DN)
+	assert.Equal(SimpleAuthChoice, attempt.AuthChoice)
+	assert.NotEmpty(attempt.CorrelationID)
+
+	failure, ok := got[2].(BindFailureEvent)
+	require.True(ok, "%T", got[2])
+	assert.Equal("cn=alice", failure.DN)
+	assert.Equal(ResultInvalidCredentials, failure.ResultCode)
+	assert.Equal("invalid credentials", failure.DiagnosticMessage)
+	assert.Empty(failure.BoundDN)
+
+	_, ok = got[3].(BindAttemptEvent)
+	require.True(ok, "%T", got[3])
+	success, ok := got[4].(BindSuccessEvent)
+	require.True(ok, "%T", got[4])
+	assert.Equal("cn=alice", success.DN)
+	assert.Equal("cn=alice", success.BoundDN)
+	assert.Equal(attempt.MessageID+1, success.MessageID)
+
+	search, ok := got[5].(SearchPerformedEvent)
+	require.True(ok, "%T", got[5])
+	assert.Equal("ou=people,dc=example,dc=org", search.BaseDN)
+	assert.Equal(WholeSubtree, search.Scope)
+	assert.Equal("(uid=bob)", search.Filter)
+	assert.Equal([]string{"cn"}, search.Attributes)
+	assert.Equal(int64(1), search.Entries)
+	assert.Equal(ResultSuccess, search.ResultCode)
+	assert.Equal("cn=alice", search.BoundDN)
+
+	modified, ok := got[6].(EntryModifiedEvent)
+	require.True(ok, "%T", got[6])
+	assert.Equal(ModifyRouteOperation, modified.Operation)
+	assert.Equal("uid=bob,ou=people,dc=example,dc=org", modified.DN)
+
+	closed, ok := got[7].(ConnectionClosedEvent)
+	require.True(ok, "%T", got[7])
+	assert.Equal(time.Minute, closed.Duration)
+}
+
+func TestAuditFunc(t *testing.T) {
+	t.Parallel()
+	var got AuditEvent
+	var a Auditor = AuditFunc(func(e AuditEvent) { got = e })
+	e := ConnectionOpenedEvent{AuditConnection: AuditConnection{ConnectionID: 1}}
+	a.Audit(e)
+	assert.Equal(t, e, got)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// ControlAuthzIDRequest implements the authorization identity request control
+// described in https://tools.ietf.org/html/rfc3829, which a client sends with
+// a bind request to ask for its authorization identity in the bind response
+// (see: ControlAuthzIDResponse)
+type ControlAuthzIDRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlAuthzIDRequest) GetControlType() string {
+	return ControlTypeAuthzIDRequest
+}
+
+// Encode returns the ber packet representation
+func (c *ControlAuthzIDRequest) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeAuthzIDRequest, "Control Type ("+ControlTypeMap[ControlTypeAuthzIDRequest]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlAuthzIDRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeAuthzIDRequest],
+		ControlTypeAuthzIDRequest,
+		c.Criticality)
+}
+
+// NewControlAuthzIDRequest returns an authorization identity request control.
+// Supported options: WithCriticality
+func NewControlAuthzIDRequest(opt ...Option) (*ControlAuthzIDRequest, error) {
+	opts := getControlOpts(opt...)
+	return &ControlAuthzIDRequest{Criticality: opts.withCriticality}, nil
+}
+
+// ControlAuthzIDResponse implements the authorization identity response
+// control described in https://tools.ietf.org/html/rfc3829, which is attached
+// to a bind response (see: BindResponse.SetControls)
+type ControlAuthzIDResponse struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// AuthzID is the authorization identity (i.e. "dn:cn=alice,dc=example,dc=org"
+	// or "u:alice") of the bound client, which is empty for an anonymous bind
+	AuthzID string
+}
+
+// GetControlType returns the OID
+func (c *ControlAuthzIDResponse) GetControlType() string {
+	return ControlTypeAuthzIDResponse
+}
+
+// Encode returns the ber packet representation
+func (c *ControlAuthzIDResponse) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeAuthzIDResponse, "Control Type ("+ControlTypeMap[ControlTypeAuthzIDResponse]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	// the value is the authzId itself rather than a ber encoding of it
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.AuthzID, "Control Value (Authorization Identity Response)"))
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlAuthzIDResponse) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  AuthzID: %q",
+		ControlTypeMap[ControlTypeAuthzIDResponse],
+		ControlTypeAuthzIDResponse,
+		c.Criticality,
+		c.AuthzID)
+}
+
+// NewControlAuthzIDResponse returns an authorization identity response control
+// for the authzID, which should be empty for an anonymous bind.  Supported
+// options: WithCriticality
+func NewControlAuthzIDResponse(authzID string, opt ...Option) (*ControlAuthzIDResponse, error) {
+	opts := getControlOpts(opt...)
+	return &ControlAuthzIDResponse{
+		Criticality: opts.withCriticality,
+		AuthzID:     authzID,
+	}, nil
+}
+
+// AuthzIDRequested returns true if the request has the authorization identity
+// request control, in which case the bind response should have an
+// authorization identity response control when the bind succeeds.
+func (r *Request) AuthzIDRequested() bool {
+	return r.findControl(ControlTypeAuthzIDRequest) != nil
+}
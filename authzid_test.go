@@ -0,0 +1,79 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlAuthzIDRequest(t *testing.T) {
+	runControlTest(t,
+		testControlAuthzIDRequest(t, WithCriticality(true)),
+		withTestType(ControlTypeAuthzIDRequest),
+		withTestToString("Control Type: Authorization Identity Request (\"2.16.840.1.113730.3.4.16\")  Criticality: true"),
+	)
+	runAddControlDescriptions(t, testControlAuthzIDRequest(t, WithCriticality(true)), "Control Type (Authorization Identity Request)", "Criticality")
+}
+
+func TestControlAuthzIDResponse(t *testing.T) {
+	runControlTest(t,
+		testControlAuthzIDResponse(t, "dn:cn=alice,ou=people,dc=example,dc=org"),
+		withTestType(ControlTypeAuthzIDResponse),
+		withTestToString("Control Type: Authorization Identity Response (\"2.16.840.1.113730.3.4.15\")  Criticality: false  AuthzID: \"dn:cn=alice,ou=people,dc=example,dc=org\""),
+	)
+	// anonymous
+	runControlTest(t, testControlAuthzIDResponse(t, ""))
+	runAddControlDescriptions(t, testControlAuthzIDResponse(t, "u:alice"), "Control Type (Authorization Identity Response)", "Control Value")
+}
+
+func TestRequest_AuthzIDRequested(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		resp := r.NewBindResponse(WithResponseCode(ResultSuccess))
+		if r.AuthzIDRequested() {
+			c, err := NewControlAuthzIDResponse("dn:" + m.UserName)
+			if err != nil {
+				_ = w.Write(r.NewBindResponse(WithResponseCode(ResultOperationsError)))
+				return
+			}
+			resp.SetControls(c)
+		}
+		_ = w.Write(resp)
+	}))
+	_, url := testServer(t, mux)
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	res, err := client.SimpleBind(&ldap.SimpleBindRequest{
+		Username: "cn=alice,ou=people,dc=example,dc=org",
+		Password: "password",
+		Controls: []ldap.Control{ldap.NewControlString(ControlTypeAuthzIDRequest, false, "")},
+	})
+	require.NoError(err)
+	ctrl := ldap.FindControl(res.Controls, ControlTypeAuthzIDResponse)
+	require.NotNil(ctrl)
+	assert.Equal("dn:cn=alice,ou=people,dc=example,dc=org", ctrl.(*ldap.ControlString).ControlValue)
+
+	res, err = client.SimpleBind(&ldap.SimpleBindRequest{
+		Username: "cn=alice,ou=people,dc=example,dc=org",
+		Password: "password",
+	})
+	require.NoError(err)
+	assert.Nil(ldap.FindControl(res.Controls, ControlTypeAuthzIDResponse))
+}
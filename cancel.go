@@ -0,0 +1,156 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "time"
+
+// markCanceled marks the request as canceled by a cancel extended operation.
+// It returns false when it's too late to cancel the request, since its final
+// response has already been written.
+func (r *Request) markCanceled() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if r.responded {
+		return false
+	}
+	r.canceled = true
+	return true
+}
+
+// markResponded marks the request's final response as written and returns
+// true if the request was canceled.
+func (r *Request) markResponded() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.responded = true
+	return r.canceled
+}
+
+// markTimedOut marks the request as timed out by its route's timeout (see:
+// WithRouteTimeout).  It returns false when its final response has already
+// been written.
+func (r *Request) markTimedOut() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if r.responded {
+		return false
+	}
+	r.timedOut = true
+	return true
+}
+
+// setRouteDeadline sets the deadline of the request's route (see:
+// WithRouteTimeout)
+func (r *Request) setRouteDeadline(deadline time.Time) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.routeDeadline = deadline
+}
+
+// isTimedOut returns true if the request was timed out by its route's timeout.
+// A request whose final response hasn't been written is timed out once its
+// route's deadline has passed, even if the timeout hasn't been handled yet.
+func (r *Request) isTimedOut() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if !r.routeDeadline.IsZero() && !r.responded && !r.now().Before(r.routeDeadline) {
+		r.timedOut = true
+	}
+	return r.timedOut
+}
+
+// now returns the current time of the request's conn's clock (see: WithClock),
+// or the system's time for a request without a conn.
+func (r *Request) now() time.Time {
+	if r.conn == nil || r.conn.clock == nil {
+		return time.Now()
+	}
+	return r.conn.clock.Now()
+}
+
+// setWriteHook sets the function called with the responses written to the
+// request.
+func (r *Request) setWriteHook(fn func(Response)) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.writeHook = fn
+}
+
+// getWriteHook returns the function called with the responses written to the
+// request, which is nil when there isn't one.
+func (r *Request) getWriteHook() func(Response) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.writeHook
+}
+
+// canceledWithoutResponse returns true if the request was canceled and its
+// final response hasn't been written.
+func (r *Request) canceledWithoutResponse() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.canceled && !r.responded
+}
+
+// isFinalResponse returns true if the response is the final response to a
+// request, rather than one of the entries or intermediate responses that may
+// precede it.
+func isFinalResponse(r Response) bool {
+	switch r.(type) {
+	case *SearchResponseEntry, *IntermediateResponse:
+		return false
+	default:
+		return true
+	}
+}
+
+// cancelRequest cancels the in-flight request with the message ID and returns
+// the result code of the cancel response (see:
+// https://tools.ietf.org/html/rfc3909#section-2.2).  The request, if it's
+// canceled, is returned so the caller can wait for it to complete.
+func (c *conn) cancelRequest(messageID int64) (int, *Request) {
+	const op = "gldap.(Conn).cancelRequest"
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	r, ok := c.inFlight[messageID]
+	switch {
+	case !ok:
+		c.logger.Debug("no in-flight request to cancel", "op", op, "conn", c.connID, "messageID", messageID)
+		return ResultNoSuchOperation, nil
+	case r.routeOp == BindRouteOperation,
+		r.extendedName == ExtendedOperationStartTLS,
+		r.extendedName == ExtendedOperationCancel:
+		return ResultCannotCancel, nil
+	case !r.markCanceled():
+		return ResultTooLate, nil
+	}
+	c.logger.Debug("canceling request", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "messageID", messageID)
+	r.cancel()
+	delete(c.inFlight, messageID)
+	return ResultSuccess, r
+}
+
+// serveCancel responds to a cancel extended operation request when the router
+// doesn't have a route for it.  The response is written once the canceled
+// request has completed.
+func (c *conn) serveCancel(w *ResponseWriter, r *Request) {
+	const op = "gldap.(Conn).serveCancel"
+	m, err := r.GetCancelMessage()
+	if err != nil {
+		c.logger.Debug("invalid cancel request", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "err", err)
+		resp := r.NewExtendedResponse(WithResponseCode(ResultProtocolError))
+		resp.SetDiagnosticMessage(err.Error())
+		_ = w.Write(resp)
+		return
+	}
+	code, canceled := c.cancelRequest(m.CancelID)
+	if canceled != nil {
+		select {
+		case <-canceled.done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	_ = w.Write(r.NewExtendedResponse(WithResponseCode(code)))
+}
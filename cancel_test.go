@@ -0,0 +1,142 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn_cancel(t *testing.T) {
+	t.Parallel()
+
+	readResult := func(t *testing.T, c net.Conn) (int64, ber.Tag, int64) {
+		t.Helper()
+		require := require.New(t)
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.GreaterOrEqual(len(p.Children), 2)
+		require.NotEmpty(p.Children[1].Children)
+		return p.Children[0].Value.(int64), p.Children[1].Tag, p.Children[1].Children[0].Value.(int64)
+	}
+	startServer := func(t *testing.T, respond bool, cancelFn HandlerFunc) (net.Conn, chan struct{}) {
+		t.Helper()
+		require := require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		startedCh := make(chan struct{}, 1)
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			startedCh <- struct{}{}
+			<-r.Context().Done()
+			if respond {
+				_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+			}
+		}))
+		if cancelFn != nil {
+			require.NoError(mux.ExtendedOperation(cancelFn, ExtendedOperationCancel))
+		}
+		s, _ := testServer(t, mux)
+		c, err := net.Dial("tcp", s.Addr().String())
+		require.NoError(err)
+		t.Cleanup(func() { _ = c.Close() })
+		return c, startedCh
+	}
+	search := testSearchRequestPacket(t, SearchMessage{
+		baseMessage: baseMessage{id: 1},
+		BaseDN:      "ou=people,dc=example,dc=org",
+		Scope:       WholeSubtree,
+		Filter:      "(objectClass=*)",
+	})
+
+	for _, respond := range []bool{false, true} {
+		t.Run(fmt.Sprintf("canceled-respond-%t", respond), func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			c, startedCh := startServer(t, respond, nil)
+			_, err := c.Write(search.Bytes())
+			require.NoError(err)
+			<-startedCh
+			_, err = c.Write(testCancelRequestPacket(t, 2, 1).Bytes())
+			require.NoError(err)
+
+			// the canceled search is responded to before the cancel
+			id, tag, code := readResult(t, c)
+			assert.Equal(int64(1), id)
+			assert.Equal(ber.Tag(ApplicationSearchResultDone), tag)
+			assert.Equal(int64(ResultCanceled), code)
+			id, tag, code = readResult(t, c)
+			assert.Equal(int64(2), id)
+			assert.Equal(ber.Tag(ApplicationExtendedResponse), tag)
+			assert.Equal(int64(ResultSuccess), code)
+		})
+	}
+	t.Run("no-such-operation", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c, _ := startServer(t, false, nil)
+		_, err := c.Write(testCancelRequestPacket(t, 2, 9).Bytes())
+		require.NoError(err)
+		id, _, code := readResult(t, c)
+		assert.Equal(int64(2), id)
+		assert.Equal(int64(ResultNoSuchOperation), code)
+	})
+	t.Run("invalid-request", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c, _ := startServer(t, false, nil)
+		p := testExtendedOperationRequestPacket(t, ExtendedOperationMessage{baseMessage: baseMessage{id: 2}, Name: ExtendedOperationCancel})
+		_, err := c.Write(p.Bytes())
+		require.NoError(err)
+		_, _, code := readResult(t, c)
+		assert.Equal(int64(ResultProtocolError), code)
+	})
+	t.Run("custom-handler", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c, _ := startServer(t, false, func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewExtendedResponse(WithResponseCode(ResultCannotCancel)))
+		})
+		_, err := c.Write(testCancelRequestPacket(t, 2, 9).Bytes())
+		require.NoError(err)
+		_, _, code := readResult(t, c)
+		assert.Equal(int64(ResultCannotCancel), code)
+	})
+}
+
+func TestConn_cancelRequest(t *testing.T) {
+	t.Parallel()
+	newTracked := func(c *conn, r *Request) *Request {
+		c.trackRequest(r)
+		return r
+	}
+	c := &conn{connID: 1, logger: hclog.NewNullLogger()}
+
+	bind := newTracked(c, &Request{message: &SimpleBindMessage{baseMessage: baseMessage{id: 1}}, routeOp: BindRouteOperation})
+	code, _ := c.cancelRequest(1)
+	assert.Equal(t, ResultCannotCancel, code)
+
+	responded := newTracked(c, &Request{message: &SearchMessage{baseMessage: baseMessage{id: 2}}, routeOp: SearchRouteOperation})
+	responded.markResponded()
+	code, _ = c.cancelRequest(2)
+	assert.Equal(t, ResultTooLate, code)
+
+	search := newTracked(c, &Request{message: &SearchMessage{baseMessage: baseMessage{id: 3}}, routeOp: SearchRouteOperation})
+	code, canceled := c.cancelRequest(3)
+	assert.Equal(t, ResultSuccess, code)
+	assert.Equal(t, search, canceled)
+	assert.Error(t, search.Context().Err())
+	assert.True(t, search.canceledWithoutResponse())
+
+	// it's no longer in-flight
+	code, _ = c.cancelRequest(3)
+	assert.Equal(t, ResultNoSuchOperation, code)
+
+	c.untrackRequest(bind)
+	c.untrackRequest(responded)
+	c.untrackRequest(search)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// ChangelogBaseDN is the base DN of the changelog's entries (see:
+// WithChangelog)
+const ChangelogBaseDN = "cn=changelog"
+
+// changelogChangeTypes are the changeType attribute values of the changelog's
+// entries (see: https://tools.ietf.org/html/draft-good-ldap-changelog-04)
+var changelogChangeTypes = map[ChangeType]string{
+	ChangeTypeAdd:    "add",
+	ChangeTypeDelete: "delete",
+	ChangeTypeModify: "modify",
+	ChangeTypeModDN:  "modrdn",
+}
+
+// ChangelogEntry is a change recorded by a Changelog
+type ChangelogEntry struct {
+	// ChangeNumber of the change, which starts at 1 and is incremented for
+	// every change
+	ChangeNumber int64
+	// TargetDN is the DN of the changed entry
+	TargetDN string
+	// ChangeType of the change
+	ChangeType ChangeType
+	// Changes are the changes in LDIF format, which is empty for a delete
+	Changes string
+	// ChangeTime is when the change was recorded
+	ChangeTime time.Time
+}
+
+// Changelog is a retro changelog of an ldap server's mutations, which are
+// exposed as entries under cn=changelog (see:
+// https://tools.ietf.org/html/draft-good-ldap-changelog-04) for the
+// identity-sync products that poll a changelog rather than use a persistent
+// search or content synchronization.
+type Changelog struct {
+	mu               sync.RWMutex
+	maxChanges       int
+	changes          []*ChangelogEntry
+	lastChangeNumber int64
+	clock            Clock
+}
+
+type changelogOptions struct {
+	withClock Clock
+}
+
+func changelogDefaults() changelogOptions {
+	return changelogOptions{
+		withClock: systemClock{},
+	}
+}
+
+func getChangelogOpts(opt ...Option) changelogOptions {
+	opts := changelogDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// NewChangelog creates a new changelog which retains the most recent
+// maxChanges changes, or all changes when maxChanges is zero.
+//
+// Supported options: WithClock
+func NewChangelog(maxChanges int, opt ...Option) (*Changelog, error) {
+	const op = "gldap.NewChangelog"
+	if maxChanges < 0 {
+		return nil, fmt.Errorf("%s: invalid max changes %d: %w", op, maxChanges, ErrInvalidParameter)
+	}
+	opts := getChangelogOpts(opt...)
+	return &Changelog{maxChanges: maxChanges, clock: opts.withClock}, nil
+}
+
+// Append records a change to the entry with the targetDN.  The changes should
+// be in LDIF format (i.e. "replace: mail\nmail: alice@example.org\n-\n").
+// Changes are recorded automatically for a server's successful add, modify,
+// modify DN and delete requests when it has a changelog, so Append is only
+// needed for changes made by other means.
+func (c *Changelog) Append(targetDN string, changeType ChangeType, changes string) (*ChangelogEntry, error) {
+	const op = "gldap.(Changelog).Append"
+	switch {
+	case targetDN == "":
+		return nil, fmt.Errorf("%s: missing target DN: %w", op, ErrInvalidParameter)
+	case changelogChangeTypes[changeType] == "":
+		return nil, fmt.Errorf("%s: invalid change type %d: %w", op, changeType, ErrInvalidParameter)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastChangeNumber++
+	e := &ChangelogEntry{
+		ChangeNumber: c.lastChangeNumber,
+		TargetDN:     targetDN,
+		ChangeType:   changeType,
+		Changes:      changes,
+		ChangeTime:   c.clock.Now(),
+	}
+	c.changes = append(c.changes, e)
+	if c.maxChanges > 0 && len(c.changes) > c.maxChanges {
+		c.changes = c.changes[len(c.changes)-c.maxChanges:]
+	}
+	return e, nil
+}
+
+// Changes returns the changelog's retained changes, oldest first.
+func (c *Changelog) Changes() []ChangelogEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	changes := make([]ChangelogEntry, 0, len(c.changes))
+	for _, e := range c.changes {
+		changes = append(changes, *e)
+	}
+	return changes
+}
+
+// recordRequest records the change made by a successful add, modify, modify
+// DN or delete request and returns its changelog entry.  Other requests are
+// ignored, and a nil entry is returned for them.
+func (c *Changelog) recordRequest(r *Request) (*ChangelogEntry, error) {
+	const op = "gldap.(Changelog).recordRequest"
+	var e *ChangelogEntry
+	var err error
+	switch m := r.message.(type) {
+	case *AddMessage:
+		var sb strings.Builder
+		for _, a := range m.Attributes {
+			for _, v := range a.Vals {
+				sb.WriteString(ldifLine(a.Type, v))
+			}
+		}
+		e, err = c.Append(m.DN, ChangeTypeAdd, sb.String())
+	case *ModifyMessage:
+		var sb strings.Builder
+		for _, ch := range m.Changes {
+			var operation string
+			switch ch.Operation {
+			case AddAttribute:
+				operation = "add"
+			case DeleteAttribute:
+				operation = "delete"
+			case ReplaceAttribute:
+				operation = "replace"
+			case IncrementAttribute:
+				operation = "increment"
+			default:
+				return nil, fmt.Errorf("%s: invalid modify operation %d: %w", op, ch.Operation, ErrInvalidParameter)
+			}
+			sb.WriteString(ldifLine(operation, ch.Modification.Type))
+			vals, err := decodeModificationValues(ch.Modification.Vals)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			for _, v := range vals {
+				sb.WriteString(ldifLine(ch.Modification.Type, v))
+			}
+			sb.WriteString("-\n")
+		}
+		e, err = c.Append(m.DN, ChangeTypeModify, sb.String())
+	case *ModifyDNMessage:
+		deleteOldRDN := "0"
+		if m.DeleteOldRDN {
+			deleteOldRDN = "1"
+		}
+		changes := ldifLine("newrdn", m.NewRDN) + ldifLine("deleteoldrdn", deleteOldRDN)
+		if m.NewSuperior != "" {
+			changes += ldifLine("newsuperior", m.NewSuperior)
+		}
+		e, err = c.Append(m.DN, ChangeTypeModDN, changes)
+	case *DeleteMessage:
+		e, err = c.Append(m.DN, ChangeTypeDelete, "")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return e, nil
+}
+
+// entries returns the changelog's entries
+func (c *Changelog) entries() []*Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]*Entry, 0, len(c.changes)+1)
+	entries = append(entries, NewEntry(ChangelogBaseDN, map[string][]string{
+		"objectClass": {"top", "nsContainer"},
+		"cn":          {"changelog"},
+	}))
+	for _, ch := range c.changes {
+		changeNumber := strconv.FormatInt(ch.ChangeNumber, 10)
+		attrs := map[string][]string{
+			"objectClass":  {"top", "changeLogEntry"},
+			"changeNumber": {changeNumber},
+			"targetDN":     {ch.TargetDN},
+			"changeType":   {changelogChangeTypes[ch.ChangeType]},
+			"changeTime":   {ch.ChangeTime.UTC().Format(monitorTimeFormat)},
+		}
+		if ch.Changes != "" {
+			attrs["changes"] = []string{ch.Changes}
+		}
+		entries = append(entries, NewEntry("changeNumber="+changeNumber+","+ChangelogBaseDN, attrs))
+	}
+	return entries
+}
+
+// decodeModificationValues decodes the values of a modify request's change,
+// which are the ber encoded contents of the change's set of values
+func decodeModificationValues(encoded []string) ([]string, error) {
+	const op = "gldap.decodeModificationValues"
+	var vals []string
+	for _, e := range encoded {
+		r := bytes.NewReader([]byte(e))
+		for r.Len() > 0 {
+			p, err := ber.ReadPacket(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s: unable to decode value: %w", op, err)
+			}
+			vals = append(vals, p.Data.String())
+		}
+	}
+	return vals, nil
+}
+
+// ldifLine returns an LDIF attribute line, which is base64 encoded when the
+// value isn't safe to include as-is (see: https://tools.ietf.org/html/rfc2849)
+func ldifLine(name, value string) string {
+	safe := !strings.ContainsAny(value, "\x00\r\n") &&
+		!strings.HasPrefix(value, " ") && !strings.HasPrefix(value, ":") &&
+		!strings.HasPrefix(value, "<") && !strings.HasSuffix(value, " ")
+	for _, r := range value {
+		if r > 127 {
+			safe = false
+			break
+		}
+	}
+	if !safe {
+		return name + ":: " + base64.StdEncoding.EncodeToString([]byte(value)) + "\n"
+	}
+	return name + ": " + value + "\n"
+}
+
+// serveChangelog responds to a search of the changelog's entries.
+func (c *conn) serveChangelog(w *ResponseWriter, r *Request) {
+	c.serveEntries(w, r, c.changelog.entries())
+}
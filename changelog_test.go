@@ -0,0 +1,97 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangelog_Append(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	_, err := NewChangelog(-1)
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	c, err := NewChangelog(2)
+	require.NoError(err)
+	_, err = c.Append("", ChangeTypeAdd, "")
+	assert.ErrorIs(err, ErrInvalidParameter)
+	_, err = c.Append("cn=alice", ChangeTypeAdd|ChangeTypeDelete, "")
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	for _, dn := range []string{"cn=alice", "cn=bob", "cn=eve"} {
+		_, err := c.Append(dn, ChangeTypeDelete, "")
+		require.NoError(err)
+	}
+	// only the most recent changes are retained
+	changes := c.Changes()
+	require.Len(changes, 2)
+	assert.Equal(int64(2), changes[0].ChangeNumber)
+	assert.Equal("cn=bob", changes[0].TargetDN)
+	assert.Equal(int64(3), changes[1].ChangeNumber)
+}
+
+func Test_ldifLine(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Equal("cn: alice\n", ldifLine("cn", "alice"))
+	assert.Equal("cn:: IGFsaWNl\n", ldifLine("cn", " alice"))
+	assert.Equal("cn:: w6lsaXNl\n", ldifLine("cn", "élise"))
+	assert.Equal("description:: YQpi\n", ldifLine("description", "a\nb"))
+}
+
+func TestServer_WithChangelog(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	changelog, err := NewChangelog(0)
+	require.NoError(err)
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Add(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationAddResponse), WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Delete(func(w *ResponseWriter, r *Request) {
+		// failed changes aren't recorded
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultNoSuchObject)))
+	}))
+	_, url := testServer(t, mux, WithChangelog(changelog))
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	add := ldap.NewAddRequest("cn=alice,ou=people,dc=example,dc=org", nil)
+	add.Attribute("objectClass", []string{"person"})
+	add.Attribute("cn", []string{"alice"})
+	require.NoError(client.Add(add))
+	modify := ldap.NewModifyRequest("cn=alice,ou=people,dc=example,dc=org", nil)
+	modify.Replace("mail", []string{"alice@example.org"})
+	modify.Delete("description", nil)
+	require.NoError(client.Modify(modify))
+	require.Error(client.Del(ldap.NewDelRequest("cn=bob,ou=people,dc=example,dc=org", nil)))
+
+	res, err := client.Search(ldap.NewSearchRequest(ChangelogBaseDN, ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(changeNumber>=1)", []string{"changeNumber", "targetDN", "changeType", "changes"}, nil))
+	require.NoError(err)
+	require.Len(res.Entries, 2)
+	assert.Equal("changeNumber=1,cn=changelog", res.Entries[0].DN)
+	assert.Equal("cn=alice,ou=people,dc=example,dc=org", res.Entries[0].GetAttributeValue("targetDN"))
+	assert.Equal("add", res.Entries[0].GetAttributeValue("changeType"))
+	assert.Equal("objectClass: person\ncn: alice\n", res.Entries[0].GetAttributeValue("changes"))
+	assert.Equal("modify", res.Entries[1].GetAttributeValue("changeType"))
+	assert.Equal("replace: mail\nmail: alice@example.org\n-\ndelete: description\n-\n", res.Entries[1].GetAttributeValue("changes"))
+
+	res, err = client.Search(ldap.NewSearchRequest(ChangelogBaseDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	require.Len(res.Entries, 1)
+	assert.Equal("changelog", res.Entries[0].GetAttributeValue("cn"))
+}
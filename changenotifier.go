@@ -0,0 +1,183 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// EntryChange is a change to an entry which is published by a ChangeNotifier
+type EntryChange struct {
+	// DN of the changed entry, which is its new DN when it was renamed
+	DN string
+	// ChangeType of the change
+	ChangeType ChangeType
+	// PreviousDN is the DN of the entry before it was renamed and is only set
+	// when the ChangeType is ChangeTypeModDN
+	PreviousDN string
+	// ChangeNumber is the number of the change in the server's changelog (see:
+	// WithChangelog), which is nil when the server doesn't have one
+	ChangeNumber *int64
+}
+
+// Options returns the options of the change's entry change notification (see:
+// ResponseWriter.WriteChange)
+func (c EntryChange) Options() []Option {
+	var opts []Option
+	if c.PreviousDN != "" {
+		opts = append(opts, WithPreviousDN(c.PreviousDN))
+	}
+	if c.ChangeNumber != nil {
+		opts = append(opts, WithChangeNumber(*c.ChangeNumber))
+	}
+	return opts
+}
+
+// ChangeNotifier publishes the changes made by a server's successful add,
+// modify, modify DN and delete requests (see: WithChangeNotifier) to its
+// subscribers, which are typically the handlers of persistent searches (see:
+// ResponseWriter.WriteChange).
+type ChangeNotifier struct {
+	mu          sync.Mutex
+	bufferSize  int
+	subscribers map[*changeSubscription]struct{}
+}
+
+type changeSubscription struct {
+	changes     chan EntryChange
+	changeTypes ChangeType
+}
+
+// NewChangeNotifier creates a new change notifier, whose subscribers may fall
+// behind by up to bufferSize changes (see: Subscribe).
+func NewChangeNotifier(bufferSize int) (*ChangeNotifier, error) {
+	const op = "gldap.NewChangeNotifier"
+	if bufferSize < 1 {
+		return nil, fmt.Errorf("%s: invalid buffer size %d: %w", op, bufferSize, ErrInvalidParameter)
+	}
+	return &ChangeNotifier{
+		bufferSize:  bufferSize,
+		subscribers: map[*changeSubscription]struct{}{},
+	}, nil
+}
+
+// Subscribe returns a channel of the notifier's changes with the change types
+// (i.e. the ChangeTypes of a request's persistent search control).  The
+// channel is closed when the ctx is done, and when the subscriber falls behind
+// by more than the notifier's buffer size rather than miss a change, so a
+// persistent search's handler should end the search when the channel is closed
+// before the request's Context is done.
+func (n *ChangeNotifier) Subscribe(ctx context.Context, changeTypes ChangeType) (<-chan EntryChange, error) {
+	const op = "gldap.(ChangeNotifier).Subscribe"
+	switch {
+	case ctx == nil:
+		return nil, fmt.Errorf("%s: missing context: %w", op, ErrInvalidParameter)
+	case changeTypes <= 0 || changeTypes&^ChangeTypeAny != 0:
+		return nil, fmt.Errorf("%s: invalid change types %d: %w", op, changeTypes, ErrInvalidParameter)
+	}
+	s := &changeSubscription{
+		changes:     make(chan EntryChange, n.bufferSize),
+		changeTypes: changeTypes,
+	}
+	n.mu.Lock()
+	n.subscribers[s] = struct{}{}
+	n.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		n.unsubscribe(s)
+	}()
+	return s.changes, nil
+}
+
+// Notify publishes a change to the notifier's subscribers.  Changes are
+// published automatically for a server's successful add, modify, modify DN and
+// delete requests when it has a change notifier, so Notify is only needed for
+// changes made by other means.
+func (n *ChangeNotifier) Notify(c EntryChange) error {
+	const op = "gldap.(ChangeNotifier).Notify"
+	switch {
+	case c.DN == "":
+		return fmt.Errorf("%s: missing dn: %w", op, ErrInvalidParameter)
+	case ChangeTypeMap[c.ChangeType] == "":
+		return fmt.Errorf("%s: invalid change type %d: %w", op, c.ChangeType, ErrInvalidParameter)
+	case c.PreviousDN != "" && c.ChangeType != ChangeTypeModDN:
+		return fmt.Errorf("%s: previous DN is only valid for a %s change: %w", op, ChangeTypeModDN, ErrInvalidParameter)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for s := range n.subscribers {
+		if s.changeTypes&c.ChangeType == 0 {
+			continue
+		}
+		select {
+		case s.changes <- c:
+		default:
+			// the subscriber has fallen behind, so it's unsubscribed rather
+			// than miss the change
+			delete(n.subscribers, s)
+			close(s.changes)
+		}
+	}
+	return nil
+}
+
+// unsubscribe removes the subscription and closes its channel, unless it's
+// already been removed
+func (n *ChangeNotifier) unsubscribe(s *changeSubscription) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.subscribers[s]; ok {
+		delete(n.subscribers, s)
+		close(s.changes)
+	}
+}
+
+// notifyRequest publishes the change made by a successful add, modify, modify
+// DN or delete request, with its changelog change number when it was recorded
+// in a changelog.  Other requests are ignored.
+func (n *ChangeNotifier) notifyRequest(r *Request, changeNumber *int64) error {
+	const op = "gldap.(ChangeNotifier).notifyRequest"
+	c := EntryChange{ChangeNumber: changeNumber}
+	switch m := r.message.(type) {
+	case *AddMessage:
+		c.DN, c.ChangeType = m.DN, ChangeTypeAdd
+	case *ModifyMessage:
+		c.DN, c.ChangeType = m.DN, ChangeTypeModify
+	case *ModifyDNMessage:
+		dn, err := modifiedDN(m)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		c.DN, c.ChangeType, c.PreviousDN = dn, ChangeTypeModDN, m.DN
+	case *DeleteMessage:
+		c.DN, c.ChangeType = m.DN, ChangeTypeDelete
+	default:
+		return nil
+	}
+	if err := n.Notify(c); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// modifiedDN returns the DN of the entry renamed by the modify DN request
+func modifiedDN(m *ModifyDNMessage) (string, error) {
+	const op = "gldap.modifiedDN"
+	superior := m.NewSuperior
+	if superior == "" {
+		dn, err := ldap.ParseDN(m.DN)
+		if err != nil || len(dn.RDNs) == 0 {
+			return "", fmt.Errorf("%s: invalid dn %q: %w", op, m.DN, ErrInvalidParameter)
+		}
+		superior = (&ldap.DN{RDNs: dn.RDNs[1:]}).String()
+	}
+	if superior == "" {
+		return m.NewRDN, nil
+	}
+	return m.NewRDN + "," + superior, nil
+}
@@ -0,0 +1,226 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChangeNotifier(t *testing.T) {
+	t.Parallel()
+	_, err := NewChangeNotifier(0)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	n, err := NewChangeNotifier(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n.bufferSize)
+}
+
+func TestChangeNotifier_Subscribe(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	n, err := NewChangeNotifier(2)
+	require.NoError(err)
+
+	_, err = n.Subscribe(nil, ChangeTypeAdd) // nolint:staticcheck
+	assert.ErrorIs(err, ErrInvalidParameter)
+	_, err = n.Subscribe(context.Background(), 0)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	_, err = n.Subscribe(context.Background(), 16)
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	adds, err := n.Subscribe(ctx, ChangeTypeAdd)
+	require.NoError(err)
+	anyCtx, anyCancel := context.WithCancel(context.Background())
+	all, err := n.Subscribe(anyCtx, ChangeTypeAny)
+	require.NoError(err)
+
+	require.NoError(n.Notify(EntryChange{DN: "cn=alice,dc=example,dc=org", ChangeType: ChangeTypeAdd}))
+	require.NoError(n.Notify(EntryChange{DN: "cn=alice,dc=example,dc=org", ChangeType: ChangeTypeDelete}))
+	// only the subscribed change types are published
+	assert.Equal(EntryChange{DN: "cn=alice,dc=example,dc=org", ChangeType: ChangeTypeAdd}, <-adds)
+	assert.Equal(ChangeTypeAdd, (<-all).ChangeType)
+	assert.Equal(ChangeTypeDelete, (<-all).ChangeType)
+
+	// the channel is closed when the ctx is done
+	anyCancel()
+	select {
+	case _, ok := <-all:
+		assert.False(ok)
+	case <-time.After(5 * time.Second):
+		assert.Fail("subscription was not closed")
+	}
+
+	// the channel is closed when the subscriber falls behind
+	for i := 0; i < 3; i++ {
+		require.NoError(n.Notify(EntryChange{DN: "cn=bob,dc=example,dc=org", ChangeType: ChangeTypeAdd}))
+	}
+	var received int
+	for range adds {
+		received++
+	}
+	assert.Equal(2, received)
+	n.mu.Lock()
+	assert.Empty(n.subscribers)
+	n.mu.Unlock()
+}
+
+func TestChangeNotifier_Notify(t *testing.T) {
+	t.Parallel()
+	n, err := NewChangeNotifier(1)
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		change  EntryChange
+		wantErr bool
+	}{
+		{name: "valid", change: EntryChange{DN: "cn=alice", ChangeType: ChangeTypeModDN, PreviousDN: "cn=bob"}},
+		{name: "missing-dn", change: EntryChange{ChangeType: ChangeTypeAdd}, wantErr: true},
+		{name: "invalid-change-type", change: EntryChange{DN: "cn=alice", ChangeType: ChangeTypeAny}, wantErr: true},
+		{name: "previous-dn", change: EntryChange{DN: "cn=alice", ChangeType: ChangeTypeModify, PreviousDN: "cn=bob"}, wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := n.Notify(tc.change)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidParameter)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_modifiedDN(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		msg     *ModifyDNMessage
+		want    string
+		wantErr bool
+	}{
+		{name: "rename", msg: &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=eve"}, want: "cn=eve,ou=people,dc=example,dc=org"},
+		{name: "move", msg: &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=alice", NewSuperior: "ou=admins,dc=example,dc=org"}, want: "cn=alice,ou=admins,dc=example,dc=org"},
+		{name: "top-level", msg: &ModifyDNMessage{DN: "dc=org", NewRDN: "dc=com"}, want: "dc=com"},
+		{name: "invalid-dn", msg: &ModifyDNMessage{DN: "invalid", NewRDN: "cn=eve"}, wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := modifiedDN(tc.msg)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidParameter)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestServer_WithChangeNotifier(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	n, err := NewChangeNotifier(10)
+	require.NoError(err)
+	changelog, err := NewChangelog(0)
+	require.NoError(err)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	subscribed := make(chan struct{})
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		ps, ok := r.GetPersistentSearchControl()
+		if !ok {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultUnwillingToPerform)))
+			return
+		}
+		changes, err := n.Subscribe(r.Context(), ps.ChangeTypes)
+		if err != nil {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		close(subscribed)
+		for c := range changes {
+			if err := w.WriteChange(&Entry{DN: c.DN}, c.ChangeType, c.Options()...); err != nil {
+				return
+			}
+		}
+	}))
+	require.NoError(mux.Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.ModifyDN(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyDNResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultNoSuchObject)))
+	}))
+	s, url := testServer(t, mux, WithChangeNotifier(n), WithChangelog(changelog))
+
+	c, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(err)
+	defer c.Close()
+	search := testSearchRequestPacket(t, SearchMessage{
+		baseMessage: baseMessage{id: 1},
+		BaseDN:      "dc=example,dc=org",
+		Scope:       WholeSubtree,
+		Filter:      "(objectClass=*)",
+		Controls: []Control{
+			testControlPersistentSearch(t, ChangeTypeAny, WithChangesOnly(true), WithReturnECs(true)),
+		},
+	})
+	_, err = c.Write(search.Bytes())
+	require.NoError(err)
+	<-subscribed
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	mod := ldap.NewModifyRequest("cn=alice,ou=people,dc=example,dc=org", nil)
+	mod.Replace("mail", []string{"alice@example.org"})
+	require.NoError(client.Modify(mod))
+	// an unsuccessful request isn't published
+	assert.Error(client.Del(ldap.NewDelRequest("cn=bob,ou=people,dc=example,dc=org", nil)))
+	require.NoError(client.ModifyDN(ldap.NewModifyDNRequest("cn=alice,ou=people,dc=example,dc=org", "cn=eve", true, "")))
+
+	type change struct {
+		dn  string
+		ecn *ControlEntryChangeNotification
+	}
+	var got []change
+	for i := 0; i < 2; i++ {
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.Len(p.Children, 3)
+		assert.Equal(ber.Tag(ApplicationSearchResultEntry), p.Children[1].Tag)
+		require.Len(p.Children[2].Children, 1)
+		ctrl, err := decodeControl(p.Children[2].Children[0])
+		require.NoError(err)
+		ecn, ok := ctrl.(*ControlEntryChangeNotification)
+		require.True(ok)
+		got = append(got, change{dn: p.Children[1].Children[0].Data.String(), ecn: ecn})
+	}
+	assert.Equal("cn=alice,ou=people,dc=example,dc=org", got[0].dn)
+	assert.Equal(ChangeTypeModify, got[0].ecn.ChangeType)
+	require.NotNil(got[0].ecn.ChangeNumber)
+	assert.Equal(int64(1), *got[0].ecn.ChangeNumber)
+	assert.Equal("cn=eve,ou=people,dc=example,dc=org", got[1].dn)
+	assert.Equal(ChangeTypeModDN, got[1].ecn.ChangeType)
+	assert.Equal("cn=alice,ou=people,dc=example,dc=org", got[1].ecn.PreviousDN)
+	require.NotNil(got[1].ecn.ChangeNumber)
+	assert.Equal(int64(2), *got[1].ecn.ChangeNumber)
+}
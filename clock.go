@@ -0,0 +1,62 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "time"
+
+// Clock is a source of the current time, which can be replaced (see:
+// WithClock) so tests of time-dependent behavior (i.e. timestamps and expiry)
+// don't need to sleep.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+}
+
+// systemClock is the Clock of the system's time
+type systemClock struct{}
+
+// Now returns the current system time
+func (systemClock) Now() time.Time { return time.Now() }
+
+// WithClock specifies an optional clock, which is used for the timestamps of
+// the server's events, audit events, monitor backend, journal and access log
+// and for the deadlines of its route timeouts (see: NewServer), a changelog's
+// change times (see: NewChangelog), a write-through's queue times (see:
+// NewWriteThrough), the idle times of an upstream pool's connections (see:
+// NewUpstreamPool), the token buckets of a rate limit (see: RateLimitPerIP)
+// and a quota (see: Quota), and the file checks of a certificate reloader
+// (see: NewCertificateReloader).  A Clock doesn't provide timers, so the
+// runtime's timers still fire a handler's route timeout (see:
+// WithRouteTimeout), a connection's maximum lifetime (see:
+// WithMaxConnLifetime) and the network deadlines (see: WithReadTimeout).  A
+// handler's responses are rejected once the clock passes its route's
+// deadline, and the timeLimitExceeded response is written when the runtime's
+// timer fires.
+func WithClock(c Clock) Option {
+	return targetedOption(func(o interface{}) bool {
+		var clock *Clock
+		switch v := o.(type) {
+		case *configOptions:
+			clock = &v.withClock
+		case *changelogOptions:
+			clock = &v.withClock
+		case *writeThroughOptions:
+			clock = &v.withClock
+		case *upstreamOptions:
+			clock = &v.withClock
+		case *rateLimitOptions:
+			clock = &v.withClock
+		case *quotaOptions:
+			clock = &v.withClock
+		case *certReloaderOptions:
+			clock = &v.withClock
+		default:
+			return false
+		}
+		if !isNil(c) {
+			*clock = c
+		}
+		return true
+	})
+}
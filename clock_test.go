@@ -0,0 +1,120 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithClock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	configOpts := configDefaults()
+	configOpts.withClock = clock
+	assert.Equal(configOpts, getConfigOpts(WithClock(clock)))
+	changelogOpts := changelogDefaults()
+	changelogOpts.withClock = clock
+	assert.Equal(changelogOpts, getChangelogOpts(WithClock(clock)))
+	writeThroughOpts := writeThroughDefaults()
+	writeThroughOpts.withClock = clock
+	assert.Equal(writeThroughOpts, getWriteThroughOpts(WithClock(clock)))
+	upstreamOpts := upstreamDefaults()
+	upstreamOpts.withClock = clock
+	assert.Equal(upstreamOpts, getUpstreamOpts(WithClock(clock)))
+	certReloaderOpts := certReloaderDefaults()
+	certReloaderOpts.withClock = clock
+	assert.Equal(certReloaderOpts, getCertReloaderOpts(WithClock(clock)))
+
+	// a nil clock is ignored
+	assert.Equal(configDefaults(), getConfigOpts(WithClock(nil)))
+	var nilClock *TestClock
+	assert.Equal(configDefaults(), getConfigOpts(WithClock(nilClock)))
+}
+
+func TestTestClock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewTestClock(t, start)
+	assert.Equal(start, clock.Now())
+	clock.Advance(time.Hour)
+	assert.Equal(start.Add(time.Hour), clock.Now())
+	clock.Set(start)
+	assert.Equal(start, clock.Now())
+}
+
+func TestChangelog_clock(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewTestClock(t, start)
+	c, err := NewChangelog(0, WithClock(clock))
+	require.NoError(err)
+	_, err = c.Append("cn=alice", ChangeTypeAdd, "")
+	require.NoError(err)
+	clock.Advance(time.Minute)
+	_, err = c.Append("cn=alice", ChangeTypeDelete, "")
+	require.NoError(err)
+	changes := c.Changes()
+	require.Len(changes, 2)
+	assert.Equal(start, changes[0].ChangeTime)
+	assert.Equal(start.Add(time.Minute), changes[1].ChangeTime)
+}
+
+func TestUpstreamPool_clock(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	// the upstream server counts the health checks' root DSE searches
+	var probes atomic.Int64
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.RootDSE(func(w *ResponseWriter, r *Request) {
+		probes.Add(1)
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux)
+
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	p, err := NewUpstreamPool(url, WithClock(clock), WithUpstreamHealthCheckInterval(time.Minute))
+	require.NoError(err)
+	defer p.Close()
+
+	c, err := p.Get(context.Background())
+	require.NoError(err)
+	c.Release()
+	clock.Advance(time.Minute - time.Second)
+	c, err = p.Get(context.Background())
+	require.NoError(err)
+	c.Release()
+	assert.Equal(int64(0), probes.Load())
+
+	clock.Advance(time.Minute)
+	c, err = p.Get(context.Background())
+	require.NoError(err)
+	c.Release()
+	assert.Equal(int64(1), probes.Load())
+}
+
+func TestRequest_isTimedOut_clock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := &Request{conn: &conn{clock: clock}}
+	assert.False(r.isTimedOut())
+
+	// the route's deadline is relative to the conn's clock
+	r.routeDeadline = clock.Now().Add(time.Minute)
+	assert.False(r.isTimedOut())
+	clock.Advance(time.Minute)
+	assert.True(r.isTimedOut())
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "fmt"
+
+// ExtendedDecodeFunc decodes the value of an extended operation request, which
+// is empty when the request doesn't have a value.  See:
+// Mux.ExtendedOperationWithCodec(...)
+type ExtendedDecodeFunc func(value []byte) (interface{}, error)
+
+// ExtendedEncodeFunc encodes the value of an extended operation response.  See:
+// WithEncodeFunc(...) and ExtendedResponse.SetValue(...)
+type ExtendedEncodeFunc func(value interface{}) ([]byte, error)
+
+// ExtendedHandlerFunc defines a function for handling an extended operation
+// request with its decoded value.  See: Mux.ExtendedOperationWithCodec(...)
+type ExtendedHandlerFunc func(w *ResponseWriter, r *Request, value interface{})
+
+// ExtendedOperationWithCodec will register a handler for extended operation
+// requests whose values are decoded by the decodeFn, so handlers of
+// proprietary extended operations don't need to decode the request's raw BER.
+// Requests with a value that can't be decoded are responded to with a
+// protocolError and aren't passed to the handler.  Responses created by the
+// handler with Request.NewExtendedResponse(...) can have their value encoded
+// by the WithEncodeFunc option's function (see: ExtendedResponse.SetValue).
+//
+// Options supported: WithLabel, WithEncodeFunc, WithRouteTimeout,
+// WithRequireAuthentication, WithAllowedBindDNs
+func (m *Mux) ExtendedOperationWithCodec(exName ExtendedOperationName, decodeFn ExtendedDecodeFunc, handlerFn ExtendedHandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).ExtendedOperationWithCodec"
+	switch {
+	case exName == "":
+		return fmt.Errorf("%s: missing extended operation name: %w", op, ErrInvalidParameter)
+	case decodeFn == nil:
+		return fmt.Errorf("%s: missing ExtendedDecodeFunc: %w", op, ErrInvalidParameter)
+	case handlerFn == nil:
+		return fmt.Errorf("%s: missing ExtendedHandlerFunc: %w", op, ErrInvalidParameter)
+	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
+	opts := getRouteOpts(opt...)
+	h := func(w *ResponseWriter, r *Request) {
+		msg, err := r.GetExtendedOperationMessage()
+		if err != nil {
+			_ = w.Write(r.NewExtendedResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		value, err := decodeFn([]byte(msg.Value))
+		if err != nil {
+			resp := r.NewExtendedResponse(WithResponseCode(ResultProtocolError))
+			resp.SetResponseName(exName)
+			resp.SetDiagnosticMessage(fmt.Sprintf("invalid %s request value: %s", exName, err.Error()))
+			_ = w.Write(resp)
+			return
+		}
+		r.extendedEncodeFn = opts.withEncodeFunc
+		handlerFn(w, r, value)
+	}
+	if err := m.ExtendedOperation(h, exName, opt...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetValue will set the response value of an extended operation response to
+// the value encoded by the route's ExtendedEncodeFunc.  It's only supported
+// for responses to requests handled by a route registered with
+// Mux.ExtendedOperationWithCodec(...) and the WithEncodeFunc option.
+func (r *ExtendedResponse) SetValue(value interface{}) error {
+	const op = "gldap.(ExtendedResponse).SetValue"
+	if r.encodeFn == nil {
+		return fmt.Errorf("%s: missing ExtendedEncodeFunc: %w", op, ErrInvalidParameter)
+	}
+	encoded, err := r.encodeFn(value)
+	if err != nil {
+		return fmt.Errorf("%s: unable to encode value: %w", op, err)
+	}
+	r.value = encoded
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMux_ExtendedOperationWithCodec(t *testing.T) {
+	t.Parallel()
+	const echoOID ExtendedOperationName = "1.3.6.1.4.1.99999.1"
+	decodeFn := func(value []byte) (interface{}, error) {
+		if len(value) == 0 {
+			return nil, errors.New("missing value")
+		}
+		return string(value), nil
+	}
+	encodeFn := func(value interface{}) ([]byte, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value %T", value)
+		}
+		return []byte(strings.ToUpper(s)), nil
+	}
+	handlerFn := func(w *ResponseWriter, r *Request, value interface{}) {
+		resp := r.NewExtendedResponse(WithResponseCode(ResultSuccess))
+		resp.SetResponseName(echoOID)
+		if err := resp.SetValue(value); err != nil {
+			resp.SetResultCode(ResultOperationsError)
+		}
+		_ = w.Write(resp)
+	}
+
+	t.Run("invalid-parameters", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		assert.ErrorIs(mux.ExtendedOperationWithCodec("", decodeFn, handlerFn), ErrInvalidParameter)
+		assert.ErrorIs(mux.ExtendedOperationWithCodec(echoOID, nil, handlerFn), ErrInvalidParameter)
+		assert.ErrorIs(mux.ExtendedOperationWithCodec(echoOID, decodeFn, nil), ErrInvalidParameter)
+	})
+
+	readResponse := func(t *testing.T, c net.Conn) (int64, string) {
+		t.Helper()
+		require := require.New(t)
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.Len(p.Children, 2)
+		res := p.Children[1]
+		require.NotEmpty(res.Children)
+		var value string
+		for _, child := range res.Children {
+			if child.ClassType == ber.ClassContext && child.Tag == 11 {
+				value = child.Data.String()
+			}
+		}
+		return res.Children[0].Value.(int64), value
+	}
+	startServer := func(t *testing.T, opt ...RouteOption) net.Conn {
+		t.Helper()
+		require := require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.ExtendedOperationWithCodec(echoOID, decodeFn, handlerFn, opt...))
+		s, _ := testServer(t, mux)
+		c, err := net.Dial("tcp", s.Addr().String())
+		require.NoError(err)
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+
+	t.Run("encoded", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c := startServer(t, WithEncodeFunc(encodeFn))
+		_, err := c.Write(testExtendedOperationRequestPacket(t, ExtendedOperationMessage{baseMessage: baseMessage{id: 1}, Name: echoOID, Value: "hello"}).Bytes())
+		require.NoError(err)
+		code, value := readResponse(t, c)
+		assert.Equal(int64(ResultSuccess), code)
+		assert.Equal("HELLO", value)
+	})
+	t.Run("missing-encoder", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c := startServer(t)
+		_, err := c.Write(testExtendedOperationRequestPacket(t, ExtendedOperationMessage{baseMessage: baseMessage{id: 1}, Name: echoOID, Value: "hello"}).Bytes())
+		require.NoError(err)
+		code, _ := readResponse(t, c)
+		assert.Equal(int64(ResultOperationsError), code)
+	})
+	t.Run("undecodable", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c := startServer(t, WithEncodeFunc(encodeFn))
+		_, err := c.Write(testExtendedOperationRequestPacket(t, ExtendedOperationMessage{baseMessage: baseMessage{id: 1}, Name: echoOID}).Bytes())
+		require.NoError(err)
+		code, _ := readResponse(t, c)
+		assert.Equal(int64(ResultProtocolError), code)
+	})
+}
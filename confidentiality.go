@@ -0,0 +1,26 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+// confidentialityRequiredDiag is the diagnostic message of the responses to the
+// requests rejected because the conn isn't protected by TLS (see:
+// WithRequireTLS and WithRequireConfidentiality)
+const confidentialityRequiredDiag = "TLS is required"
+
+// confidentialityRequired returns true if the request must be rejected until
+// the conn is protected by TLS (see: WithRequireTLS).
+func (c *conn) confidentialityRequired(r *Request) bool {
+	if c.isTLS() {
+		return false
+	}
+	if m, ok := r.message.(*SimpleBindMessage); ok && m.Password != "" {
+		return true
+	}
+	for _, op := range c.requireTLSOps {
+		if r.routeOp == op {
+			return true
+		}
+	}
+	return false
+}
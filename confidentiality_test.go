@@ -0,0 +1,129 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTLSConfigs returns the server and client TLS configs of a new
+// certificate for localhost.
+func testTLSConfigs(t *testing.T) (srv *tls.Config, client *tls.Config) {
+	t.Helper()
+	certPEM, keyPEM := testCertificate(t, "localhost")
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(certPEM))
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, &tls.Config{RootCAs: pool, ServerName: "localhost"}
+}
+
+func TestServer_WithRequireTLS(t *testing.T) {
+	t.Parallel()
+	srvTLS, clientTLS := testTLSConfigs(t)
+	mux, err := NewMux()
+	require.NoError(t, err)
+	success := func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.resultResponse(ResultSuccess, ""))
+	}
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Modify(success))
+	require.NoError(t, mux.Delete(success))
+	_, url := testServer(t, mux, WithRequireTLS(ModifyRouteOperation), WithStartTLS(srvTLS))
+	_, ldapsURL := testServer(t, mux, WithRequireTLS(ModifyRouteOperation), WithTLSConfig(srvTLS))
+
+	requests := func(c *ldap.Conn) map[string]error {
+		mod := ldap.NewModifyRequest("cn=alice,dc=example,dc=org", nil)
+		mod.Replace("mail", []string{"alice@example.org"})
+		return map[string]error{
+			"bind":   c.Bind("cn=alice,dc=example,dc=org", "password"),
+			"modify": c.Modify(mod),
+		}
+	}
+	t.Run("plaintext", func(t *testing.T) {
+		t.Parallel()
+		assert, require := assert.New(t), require.New(t)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		for name, err := range requests(client) {
+			require.Error(err, name)
+			var ldapErr *ldap.Error
+			require.ErrorAs(err, &ldapErr, name)
+			assert.Equal(uint16(ResultConfidentialityRequired), ldapErr.ResultCode, name)
+			assert.Contains(ldapErr.Err.Error(), confidentialityRequiredDiag, name)
+		}
+		// unauthenticated binds and the operations that aren't configured
+		// are still allowed
+		assert.NoError(client.UnauthenticatedBind("cn=alice,dc=example,dc=org"))
+		assert.NoError(client.Del(ldap.NewDelRequest("cn=alice,dc=example,dc=org", nil)))
+	})
+	t.Run("starttls", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(client.StartTLS(clientTLS))
+		for name, err := range requests(client) {
+			require.NoError(err, name)
+		}
+	})
+	t.Run("ldaps", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		client, err := ldap.DialURL(ldapsURL, ldap.DialWithTLSConfig(clientTLS))
+		require.NoError(err)
+		defer client.Close()
+		for name, err := range requests(client) {
+			require.NoError(err, name)
+		}
+	})
+}
+
+func TestMux_requireConfidentiality(t *testing.T) {
+	t.Parallel()
+	srvTLS, clientTLS := testTLSConfigs(t)
+	mux, err := NewMux()
+	require.NoError(t, err)
+	var catchAll int
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}, WithBaseDN("ou=secrets,dc=example,dc=org"), WithRequireConfidentiality()))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		catchAll++
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	routes := mux.Routes()
+	require.Len(t, routes, 2)
+	assert.True(t, routes[0].RequireConfidentiality)
+	assert.False(t, routes[1].RequireConfidentiality)
+	_, url := testServer(t, mux, WithStartTLS(srvTLS))
+
+	search := func(c *ldap.Conn) error {
+		_, err := c.Search(ldap.NewSearchRequest("ou=secrets,dc=example,dc=org", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		return err
+	}
+	assert, require := assert.New(t), require.New(t)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	err = search(client)
+	require.Error(err)
+	assert.True(ldap.IsErrorWithCode(err, ResultConfidentialityRequired))
+	// the request isn't dispatched to the catch-all route
+	assert.Equal(0, catchAll)
+
+	require.NoError(client.StartTLS(clientTLS))
+	assert.NoError(search(client))
+	assert.Equal(0, catchAll)
+}
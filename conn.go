@@ -6,37 +6,93 @@ package gldap
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
-	"github.com/hashicorp/go-hclog"
 )
 
+// errIdleTimeout is returned when a conn without in-flight requests hasn't
+// received a request within its idle timeout (see: WithIdleTimeout)
+var errIdleTimeout = errors.New("idle timeout")
+
 // conn is a connection to an ldap client
 type conn struct {
 	mu sync.Mutex // mutex for the conn
 
-	connID      int
-	netConn     net.Conn
-	logger      hclog.Logger
-	router      *Mux
-	shutdownCtx context.Context
-	requestsWg  sync.WaitGroup
+	connID         int
+	netConn        net.Conn
+	remoteAddr     net.Addr // of the accepted net conn, which doesn't change with StartTLS
+	localAddr      net.Addr
+	opened         time.Time // when the conn was accepted
+	logger         Logger
+	router         *Mux
+	routerFn       func() *Mux // current router of the conn's server (see: Server.Router)
+	shutdownCtx    context.Context
+	requestsWg     sync.WaitGroup
+	stats          *serverStats
+	monitor        bool             // respond to searches of the monitor's entries
+	changelog      *Changelog       // record mutations and respond to searches of its entries
+	changeNotifier *ChangeNotifier  // publish mutations to its subscribers
+	writeThrough   *WriteThrough    // forward mutations upstream
+	readOnly       bool             // reject update requests
+	readOnlyDiag   string           // diagnostic message of rejected update requests
+	requireTLS     bool             // reject simple binds with a password until the conn is protected by TLS
+	requireTLSOps  []RouteOperation // operations also rejected until the conn is protected by TLS
+	maintenance    *maintenanceMode // answer requests with unavailable when enabled
+	autoWhoAmI     bool             // respond to "Who am I?" requests
+	startTLSConfig *tls.Config      // respond to StartTLS requests
+	diagnosticHook DiagnosticMessageHook
+	journal        *journal // records the conn's requests, when not nil
+	eventSink      EventSink
+	accessLog      AccessLogSink
+	metricsHook    MetricsHook
+	tracer         Tracer
+	criticalCtrl   CriticalControlPolicy
+	supportedCtrls []string
+	auditor        Auditor
+	clock          Clock         // of the requests' received times
+	maxRequestSize int           // maximum size of a request's packet in bytes, when greater than zero
+	readTimeout    time.Duration // time allowed to read a request, once it starts arriving
+	writeTimeout   time.Duration // time allowed to write a response
+	idleTimeout    time.Duration // time allowed to wait for a request while the conn has no in-flight requests
+
+	// slowOpThreshold and slowOpFn report the operations that take longer than
+	// the threshold (see: WithSlowOperationThreshold)
+	slowOpThreshold time.Duration
+	slowOpFn        SlowOperationFunc
+
+	boundMu sync.Mutex
+	boundDN string // DN of the last successful bind, which is empty when anonymous
+
+	disconnectMu sync.Mutex
+	disconnected bool // sent a notice of disconnection
+	draining     bool // finishing its in-flight requests as the server shuts down
+
+	inFlightMu sync.Mutex
+	inFlight   map[int64]*Request // in-flight requests by message ID
 
 	reader   *bufio.Reader
 	writer   *bufio.Writer
-	writerMu sync.Mutex // shared lock across all ResponseWriter's to prevent write data races
+	secured  atomic.Bool // the net conn is a *tls.Conn
+	writerMu sync.Mutex  // shared lock across all ResponseWriter's to prevent write data races
+
+	tlsState atomic.Pointer[tls.ConnectionState] // set once the TLS handshake has completed
+
+	state ConnectionState // shared by the conn's requests (see: Request.ConnectionState)
 }
 
 // newConn will create a new Conn from an accepted net.Conn which will be used
 // to serve requests to an ldap client.
-func newConn(shutdownCtx context.Context, connID int, netConn net.Conn, logger hclog.Logger, router *Mux) (*conn, error) {
+func newConn(shutdownCtx context.Context, connID int, netConn net.Conn, logger Logger, router *Mux) (*conn, error) {
 	const op = "gldap.NewConn"
 	if shutdownCtx == nil {
 		return nil, fmt.Errorf("%s: missing shutdown context: %w", op, ErrInvalidParameter)
@@ -56,6 +112,9 @@ func newConn(shutdownCtx context.Context, connID int, netConn net.Conn, logger h
 	c := &conn{
 		connID:      connID,
 		netConn:     netConn,
+		remoteAddr:  netConn.RemoteAddr(),
+		localAddr:   netConn.LocalAddr(),
+		clock:       systemClock{},
 		shutdownCtx: shutdownCtx,
 		logger:      logger,
 		router:      router,
@@ -66,6 +125,18 @@ func newConn(shutdownCtx context.Context, connID int, netConn net.Conn, logger h
 	return c, nil
 }
 
+// currentRouter returns the router requests are served by, which is the
+// current router of the conn's server so a router replaced while the server is
+// running takes effect for the conn's subsequent requests.
+func (c *conn) currentRouter() *Mux {
+	if c.routerFn != nil {
+		if r := c.routerFn(); r != nil {
+			return r
+		}
+	}
+	return c.router
+}
+
 // serveRequests until the connection is closed or the shutdownCtx is cancelled
 // as the server stops
 func (c *conn) serveRequests() error {
@@ -82,21 +153,9 @@ func (c *conn) serveRequests() error {
 		select {
 		case <-c.shutdownCtx.Done():
 			c.logger.Debug("received shutdown cancellation", "op", op, "conn", c.connID, "requestID", w.requestID)
-			// build a request by hand, since this is not a normal situation
-			// where we've read a request... and we need to make this check
-			// before blocking on reading the next request.
-			req := &Request{
-				ID:           w.requestID,
-				conn:         c,
-				message:      &ExtendedOperationMessage{baseMessage: baseMessage{id: 0}},
-				routeOp:      routeOperation(ExtendedOperationDisconnection),
-				extendedName: ExtendedOperationDisconnection,
-			}
-			resp := req.NewResponse(WithResponseCode(ResultUnwillingToPerform), WithDiagnosticMessage("server stopping"))
-			if err := w.Write(resp); err != nil {
-				return fmt.Errorf("%s: %w", op, err)
-			}
-			if err := c.netConn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+			// we need to make this check before blocking on reading the next
+			// request.
+			if err := c.disconnect(ResultUnavailable, "server stopping"); err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
 			return nil
@@ -105,39 +164,128 @@ func (c *conn) serveRequests() error {
 		}
 		r, err := c.readRequest(w.requestID)
 		if err != nil {
+			if c.isDisconnected() {
+				return nil // the conn was sent a notice of disconnection
+			}
+			if errors.Is(err, errIdleTimeout) {
+				c.logger.Debug("closing idle connection", "op", op, "conn", c.connID, "idleTimeout", c.idleTimeout)
+				return nil
+			}
+			if errors.Is(err, errRequestTooLarge) {
+				c.logger.Warn("request exceeds the maximum request size", "op", op, "conn", c.connID, "requestID", w.requestID, "maxRequestSize", c.maxRequestSize)
+				if err := c.disconnect(ResultProtocolError, fmt.Sprintf("request exceeds the maximum size of %d bytes", c.maxRequestSize)); err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+				return nil
+			}
+			if errors.Is(err, errMalformedPacket) {
+				c.logger.Warn("malformed request", "op", op, "conn", c.connID, "requestID", w.requestID, "err", err)
+				if err := c.disconnect(ResultProtocolError, "malformed request"); err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+				return nil
+			}
 			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || strings.Contains(err.Error(), "unexpected EOF") {
 				return nil // connection is closed
 			}
 			return fmt.Errorf("%s: error reading request: %w", op, err)
 		}
+		w.request = r
+		r.received = c.clock.Now()
+		c.startSpan(r)
+		c.stats.opInitiated(r.routeOp)
+		if r.routeOp == BindRouteOperation {
+			// the conn is anonymous while a bind is in progress, and remains
+			// anonymous if it fails (see: ResponseWriter.trackBind)
+			c.setBoundDN("")
+			c.auditBindAttempt(r)
+		}
+		router := c.currentRouter()
 
 		switch {
 		// TODO: rate limit in-flight requests per conn and send a
 		// BusyResponse when the limit is reached.  This limit per conn
 		// should be configurable
 
-		case r.routeOp == unbindRouteOperation:
-			// support an optional unbind route
-			if c.router.unbindRoute != nil {
-				c.router.unbindRoute.handler()(w, r)
+		case r.routeOp == AbandonRouteOperation:
+			// there's no response to an abandon request, the in-flight request
+			// is simply cancelled.
+			// see: https://datatracker.ietf.org/doc/html/rfc4511#section-4.11
+			if m, ok := r.message.(*AbandonMessage); ok {
+				c.abandonRequest(m.MessageID)
 			}
+			c.logAccess(r, nil)
+			c.checkSlowOperation(r, nil)
+			c.observeOperation(r, nil)
+			r.endSpan(nil)
+			c.stats.opCompleted(r.routeOp)
+
+		case r.routeOp == UnbindRouteOperation:
+			// support an optional unbind route
+			router.serveUnbind(w, r)
+			c.logAccess(r, nil)
+			c.checkSlowOperation(r, nil)
+			c.observeOperation(r, nil)
+			r.endSpan(nil)
+			c.setBoundDN("")
 			// stop serving requests when UnbindRequest is received
+			c.cancelRequests()
+			c.stats.opCompleted(r.routeOp)
 			return nil
 
+		case c.inMaintenance():
+			c.serveMaintenance(w, r)
+			c.stats.opCompleted(r.routeOp)
+
 		// If it's a StartTLS request, then we can't dispatch it concurrently,
 		// since the conn needs to complete it's TLS negotiation before handling
 		// any other requests.
 		// see: https://datatracker.ietf.org/doc/html/rfc4511#section-4.14.1
 		case r.extendedName == ExtendedOperationStartTLS:
-			c.router.serve(w, r)
+			if c.startTLSConfig != nil {
+				if err := c.serveStartTLS(w, r); err != nil {
+					c.stats.opCompleted(r.routeOp)
+					return fmt.Errorf("%s: %w", op, err)
+				}
+			} else {
+				router.serve(w, r)
+			}
+			c.stats.opCompleted(r.routeOp)
 		default:
+			c.trackRequest(r)
 			c.requestsWg.Add(1)
 			go func() {
 				defer func() {
-					c.logger.Debug("requestsWg done", "op", op, "conn", c.connID, "requestID", w.requestID)
+					c.untrackRequest(r)
+					r.endSpan(nil) // when the handler didn't write a final response
+					c.stats.opCompleted(r.routeOp)
+					c.logger.Debug("requestsWg done", "op", op, "conn", c.connID, "requestID", w.requestID, "correlationID", r.CorrelationID())
 					c.requestsWg.Done()
 				}()
-				c.router.serve(w, r)
+				utf8Code, utf8Diag := r.invalidUTF8()
+				switch {
+				case utf8Code != ResultSuccess:
+					_ = w.Write(r.resultResponse(utf8Code, utf8Diag))
+				case c.requireTLS && c.confidentialityRequired(r):
+					_ = w.Write(r.resultResponse(ResultConfidentialityRequired, confidentialityRequiredDiag))
+				case c.readOnly && isUpdateRequest(r):
+					c.serveReadOnly(w, r)
+				case c.monitor && isSubtreeSearch(r, MonitorBaseDN):
+					c.serveMonitor(w, r)
+				case c.changelog != nil && isSubtreeSearch(r, ChangelogBaseDN):
+					c.serveChangelog(w, r)
+				case c.autoWhoAmI && (r.extendedName == ExtendedOperationWhoAmI || r.extendedName == ExtendedOperationGetBindDN):
+					c.serveWhoAmI(w, r)
+				case r.extendedName == ExtendedOperationCancel && !router.hasExtendedRoute(ExtendedOperationCancel):
+					c.serveCancel(w, r)
+				default:
+					router.serve(w, r)
+				}
+				// a canceled request must be responded to, even when its
+				// handler returns without responding.
+				if r.canceledWithoutResponse() {
+					_ = w.Write(r.resultResponse(ResultCanceled, ""))
+				}
 			}()
 		}
 	}
@@ -146,7 +294,7 @@ func (c *conn) serveRequests() error {
 func (c *conn) readRequest(requestID int) (*Request, error) {
 	const op = "gldap.(Conn).readRequest"
 
-	p, err := c.readPacket(requestID)
+	p, size, err := c.readPacket(requestID)
 	if err != nil {
 		return nil, fmt.Errorf("%s: error reading packet for %d/%d: %w", op, c.connID, requestID, err)
 	}
@@ -154,17 +302,46 @@ func (c *conn) readRequest(requestID int) (*Request, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%s: unable to create new in-memory request for %d/%d: %w", op, c.connID, requestID, err)
 	}
+	c.stats.requestRead(r.routeOp, size)
+	for _, ctrl := range r.controls() {
+		if t, critical, unknown := isUnknownControl(ctrl, c.supportedCtrls); unknown {
+			c.stats.unknownControlRead(t, critical)
+		}
+	}
+	if c.metricsHook != nil {
+		c.metricsHook.BytesRead(r.routeOp, size)
+	}
 
 	return r, nil
 }
 
-func (c *conn) readPacket(requestID int) (*packet, error) {
+// readPacket reads a request's packet and returns it along with its encoded
+// size in bytes.
+func (c *conn) readPacket(requestID int) (*packet, int, error) {
 	const op = "gldap.readPacket"
 	// read a request
+	var size int
 	berPacket, err := func() (*ber.Packet, error) {
 		c.mu.Lock()
 		defer c.mu.Unlock()
-		berPacket, err := ber.ReadPacket(c.reader)
+		if err := c.awaitRequest(); err != nil {
+			return nil, fmt.Errorf("%s: error waiting for request %d/%d: %w", op, c.connID, requestID, err)
+		}
+		if c.maxRequestSize > 0 {
+			data, err := readBoundedPacket(c.reader, c.maxRequestSize)
+			if err != nil {
+				return nil, fmt.Errorf("%s: error reading ber packet for %d/%d: %w", op, c.connID, requestID, err)
+			}
+			size = len(data)
+			berPacket, err := ber.DecodePacketErr(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: error decoding ber packet for %d/%d: %w", op, c.connID, requestID, err)
+			}
+			return berPacket, nil
+		}
+		cr := &countingReader{r: c.reader}
+		berPacket, err := ber.ReadPacket(cr)
+		size = cr.n
 		switch {
 		case err != nil && strings.Contains(err.Error(), "invalid character for IA5String at pos 2"):
 			return nil, fmt.Errorf("%s: error reading ber packet for %d/%d (possible attempt to use TLS with a non-TLS server): %w", op, c.connID, requestID, err)
@@ -174,22 +351,127 @@ func (c *conn) readPacket(requestID int) (*packet, error) {
 		return berPacket, nil
 	}()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	p := &packet{Packet: berPacket}
 	if c.logger.IsDebug() {
 		c.logger.Debug("packet read", "op", op, "conn", c.connID, "requestID", requestID)
-		p.Log(c.logger.StandardWriter(&hclog.StandardLoggerOptions{}), 0, false)
+		logPacket(c.logger, p)
 	}
 	// Simple header is first... let's make sure it's an ldap packet with 2
 	// children containing:
 	//		[0] is a message ID
 	//		[1] is a request header
 	if err := p.basicValidation(); err != nil {
-		return nil, fmt.Errorf("%s: failed validation: %w", op, err)
+		return nil, 0, fmt.Errorf("%s: failed validation: %w", op, err)
+	}
+	return p, size, nil
+}
+
+// awaitRequest waits for the first byte of the conn's next request and then
+// sets the read deadline of the rest of the request (see: WithReadTimeout).
+// The wait is limited by the idle timeout, which is renewed while the conn has
+// in-flight requests, so only a conn which is genuinely idle returns
+// errIdleTimeout.  The conn's mutex must be held.
+func (c *conn) awaitRequest() error {
+	const op = "gldap.(Conn).awaitRequest"
+	if c.readTimeout == 0 && c.idleTimeout == 0 {
+		return nil
+	}
+	for {
+		var deadline time.Time
+		if c.idleTimeout != 0 {
+			deadline = time.Now().Add(c.idleTimeout)
+		}
+		if err := c.setReadDeadline(deadline); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		_, err := c.reader.Peek(1)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) || c.isDisconnected() {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !c.hasInFlightRequests() {
+			return fmt.Errorf("%s: no request received within %s: %w", op, c.idleTimeout, errIdleTimeout)
+		}
+	}
+	var deadline time.Time
+	if c.readTimeout != 0 {
+		deadline = time.Now().Add(c.readTimeout)
+	}
+	if err := c.setReadDeadline(deadline); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// handshake completes the TLS handshake of a conn accepted by a TLS listener
+// (see: WithTLSConfig), which is limited by the conn's read and write
+// timeouts.  It's a no-op for other conns.
+func (c *conn) handshake() error {
+	const op = "gldap.(Conn).handshake"
+	tlsConn, ok := c.netConn.(*tls.Conn)
+	if !ok {
+		return nil
 	}
-	return p, nil
+	var readDeadline, writeDeadline time.Time
+	if c.readTimeout != 0 {
+		readDeadline = time.Now().Add(c.readTimeout)
+	}
+	if c.writeTimeout != 0 {
+		writeDeadline = time.Now().Add(c.writeTimeout)
+	}
+	if err := c.setReadDeadline(readDeadline); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tlsConn.SetWriteDeadline(writeDeadline); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	c.setTLSState(tlsConn)
+	// the following reads and writes have their own deadlines
+	if err := tlsConn.SetWriteDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// setReadDeadline sets the read deadline of the conn, unless it has been sent
+// a notice of disconnection, whose immediate deadline unblocks the conn's
+// pending read.
+func (c *conn) setReadDeadline(t time.Time) error {
+	c.disconnectMu.Lock()
+	defer c.disconnectMu.Unlock()
+	if c.disconnected {
+		return nil
+	}
+	return c.netConn.SetReadDeadline(t)
+}
+
+// setWriteDeadline sets the write deadline of the next response written to the
+// conn (see: WithWriteTimeout).  The conn's writer lock must be held.
+func (c *conn) setWriteDeadline() error {
+	if c.writeTimeout == 0 {
+		return nil
+	}
+	return c.netConn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+}
+
+// countingReader counts the bytes read from its reader
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
 }
 
 func (c *conn) initConn(netConn net.Conn) error {
@@ -199,15 +481,150 @@ func (c *conn) initConn(netConn net.Conn) error {
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	// the writer lock is also needed, since notices of disconnection are
+	// written outside of the conn's request loop
+	c.writerMu.Lock()
+	defer c.writerMu.Unlock()
 	c.netConn = netConn
+	_, secured := netConn.(*tls.Conn)
+	c.secured.Store(secured)
 	c.reader = bufio.NewReader(c.netConn)
 	c.writer = bufio.NewWriter(c.netConn)
 	return nil
 }
 
+// trackRequest sets the request's context and adds it to the conn's in-flight
+// requests, so it can be cancelled when it's abandoned or the conn is closed.
+func (c *conn) trackRequest(r *Request) {
+	parent := c.shutdownCtx
+	if r.span != nil {
+		parent = r.span.ctx
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	r.ctx, r.cancel = context.WithCancel(parent)
+	r.done = make(chan struct{})
+
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	if c.inFlight == nil {
+		c.inFlight = map[int64]*Request{}
+	}
+	c.inFlight[r.message.GetID()] = r
+}
+
+// untrackRequest cancels the request's context and removes it from the conn's
+// in-flight requests.
+func (c *conn) untrackRequest(r *Request) {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	id := r.message.GetID()
+	if c.inFlight[id] == r {
+		delete(c.inFlight, id)
+	}
+	if r.done != nil {
+		close(r.done)
+	}
+}
+
+// hasInFlightRequests returns true if the conn has requests which haven't
+// finished.
+func (c *conn) hasInFlightRequests() bool {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	return len(c.inFlight) > 0
+}
+
+// inFlightRequests returns the number of the conn's requests which haven't
+// finished.
+func (c *conn) inFlightRequests() int {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	return len(c.inFlight)
+}
+
+// abandonRequest cancels the in-flight request with the message ID.  It's not
+// an error if the request has already finished.
+func (c *conn) abandonRequest(messageID int64) {
+	const op = "gldap.(Conn).abandonRequest"
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	r, ok := c.inFlight[messageID]
+	if !ok {
+		c.logger.Debug("no in-flight request to abandon", "op", op, "conn", c.connID, "messageID", messageID)
+		return
+	}
+	c.logger.Debug("abandoning request", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "messageID", messageID)
+	r.cancel()
+	delete(c.inFlight, messageID)
+}
+
+// cancelRequests cancels all the conn's in-flight requests
+func (c *conn) cancelRequests() {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	for id, r := range c.inFlight {
+		r.cancel()
+		delete(c.inFlight, id)
+	}
+}
+
+// setBoundDN sets the DN of the conn's last successful bind, which is empty
+// when the conn is anonymous.
+func (c *conn) setBoundDN(dn string) {
+	c.boundMu.Lock()
+	defer c.boundMu.Unlock()
+	c.boundDN = dn
+}
+
+// getBoundDN returns the DN of the conn's last successful bind, which is empty
+// when the conn is anonymous.
+func (c *conn) getBoundDN() string {
+	c.boundMu.Lock()
+	defer c.boundMu.Unlock()
+	return c.boundDN
+}
+
+// authzID returns the conn's authorization identity, which is empty when the
+// conn is anonymous (see: https://tools.ietf.org/html/rfc4513#section-5.2.1.8)
+func (c *conn) authzID() string {
+	c.boundMu.Lock()
+	defer c.boundMu.Unlock()
+	if c.boundDN == "" {
+		return ""
+	}
+	return "dn:" + c.boundDN
+}
+
+// serveWhoAmI responds to a "Who am I?" request with the conn's authorization
+// identity.
+func (c *conn) serveWhoAmI(w *ResponseWriter, r *Request) {
+	const op = "gldap.(Conn).serveWhoAmI"
+	resp, err := r.NewWhoAmIResponse(c.authzID())
+	if err != nil {
+		c.logger.Error("unable to create who am i response", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "err", err)
+		_ = w.Write(r.NewExtendedResponse(WithResponseCode(ResultOperationsError)))
+		return
+	}
+	_ = w.Write(resp)
+}
+
 func (c *conn) close() error {
 	const op = "gldap.(Conn).close"
+	// a draining conn's in-flight requests are allowed to finish (see:
+	// Server.Shutdown)
+	c.disconnectMu.Lock()
+	draining := c.draining
+	c.disconnectMu.Unlock()
+	if !draining {
+		c.cancelRequests()
+	}
 	c.requestsWg.Wait()
+	c.state.clear()
 	if err := c.netConn.Close(); err != nil {
 		return fmt.Errorf("%s: error closing conn: %w", op, err)
 	}
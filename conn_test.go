@@ -5,9 +5,14 @@ package gldap
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net"
+	"os"
 	"testing"
+	"time"
 
+	ber "github.com/go-asn1-ber/asn1-ber"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -81,6 +86,9 @@ func Test_newConn(t *testing.T) {
 				shutdownCtx: testCtx,
 				connID:      1,
 				netConn:     server,
+				remoteAddr:  server.RemoteAddr(),
+				localAddr:   server.LocalAddr(),
+				clock:       systemClock{},
 				logger:      testLogger,
 				router:      &Mux{},
 			},
@@ -152,3 +160,58 @@ func Test_initConn(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_idleTimeout(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	const idleTimeout = 200 * time.Millisecond
+	s, err := NewServer(WithIdleTimeout(idleTimeout), WithReadTimeout(time.Second), WithWriteTimeout(time.Second))
+	require.NoError(err)
+	mux, err := NewMux()
+	require.NoError(err)
+	startedCh := make(chan struct{})
+	doneCh := make(chan error, 1)
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		close(startedCh)
+		<-r.Context().Done()
+		doneCh <- r.Context().Err()
+	}))
+	require.NoError(s.Router(mux))
+	go func() { _ = s.Run("127.0.0.1:0") }()
+	defer func() { _ = s.Stop() }()
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+
+	idle, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(err)
+	defer idle.Close()
+	psearch, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(err)
+	defer psearch.Close()
+	_, err = psearch.Write(testSearchRequestPacket(t, SearchMessage{
+		baseMessage: baseMessage{id: 1},
+		BaseDN:      "ou=people,dc=example,dc=org",
+		Scope:       WholeSubtree,
+		Filter:      "(objectClass=*)",
+		Controls:    []Control{testControlPersistentSearch(t, ChangeTypeAny)},
+	}).Bytes())
+	require.NoError(err)
+	<-startedCh
+
+	// the idle conn is closed once it hasn't sent a request within the idle
+	// timeout
+	require.NoError(idle.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	_, err = ber.ReadPacket(idle)
+	assert.ErrorIs(err, io.EOF)
+
+	// while the conn with an in-flight persistent search remains open
+	require.NoError(psearch.SetReadDeadline(time.Now().Add(3 * idleTimeout)))
+	_, err = ber.ReadPacket(psearch)
+	assert.True(errors.Is(err, os.ErrDeadlineExceeded), "unexpected error: %v", err)
+	select {
+	case err := <-doneCh:
+		assert.Fail("persistent search was cancelled", "err: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// ConnectionInfo describes one of a server's open connections (see:
+// Server.Connections)
+type ConnectionInfo struct {
+	// ID of the connection (see: Request.ConnectionID)
+	ID int
+	// RemoteAddr is the network address of the connection's client
+	RemoteAddr net.Addr
+	// LocalAddr is the server's network address the connection was accepted on
+	LocalAddr net.Addr
+	// BoundDN is the DN the connection is bound as, which is empty for an
+	// anonymous connection (see: Request.BoundDN)
+	BoundDN string
+	// TLS is true when the connection is protected by TLS
+	TLS bool
+	// Opened is when the connection was accepted
+	Opened time.Time
+	// Age of the connection
+	Age time.Duration
+	// InFlight is the number of the connection's requests that are being
+	// handled
+	InFlight int
+}
+
+type closeConnectionOptions struct {
+	withNotice           bool
+	withNoticeDiagnostic string
+}
+
+func closeConnectionDefaults() closeConnectionOptions {
+	return closeConnectionOptions{}
+}
+
+func getCloseConnectionOpts(opt ...Option) closeConnectionOptions {
+	opts := closeConnectionDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithCloseNotice specifies that CloseConnection sends the connection a notice
+// of disconnection with the diagMsg before closing it (see:
+// https://tools.ietf.org/html/rfc4511#section-4.4.1)
+func WithCloseNotice(diagMsg string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*closeConnectionOptions); ok {
+			o.withNotice = true
+			o.withNoticeDiagnostic = diagMsg
+		}
+	}
+}
+
+// Connections returns descriptions of the server's open connections, ordered
+// by ID.
+func (s *Server) Connections() []ConnectionInfo {
+	conns := s.openConns()
+	now := s.clock.Now()
+	infos := make([]ConnectionInfo, 0, len(conns))
+	for _, c := range conns {
+		infos = append(infos, c.info(now))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// CloseConnection closes one of the server's open connections, which cancels
+// its in-flight requests.  By default the connection is closed without
+// notice, as if its network connection failed; use WithCloseNotice to send
+// the connection a notice of disconnection first (see: NoticeOfDisconnection)
+//
+// Options supported: WithCloseNotice
+func (s *Server) CloseConnection(connectionID int, opt ...Option) error {
+	const op = "gldap.(Server).CloseConnection"
+	opts := getCloseConnectionOpts(opt...)
+	s.connsMu.Lock()
+	c, ok := s.conns[connectionID]
+	s.connsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("%s: unknown connection %d: %w", op, connectionID, ErrInvalidParameter)
+	}
+	c.cancelRequests()
+	if opts.withNotice {
+		if err := c.disconnect(ResultUnavailable, opts.withNoticeDiagnostic); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+	if err := c.netConn.Close(); err != nil {
+		return fmt.Errorf("%s: unable to close connection %d: %w", op, connectionID, err)
+	}
+	return nil
+}
+
+// info returns the conn's description at the time now
+func (c *conn) info(now time.Time) ConnectionInfo {
+	return ConnectionInfo{
+		ID:         c.connID,
+		RemoteAddr: c.remoteAddr,
+		LocalAddr:  c.localAddr,
+		BoundDN:    c.getBoundDN(),
+		TLS:        c.isTLS(),
+		Opened:     c.opened,
+		Age:        now.Sub(c.opened),
+		InFlight:   c.inFlightRequests(),
+	}
+}
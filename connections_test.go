@@ -0,0 +1,92 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithCloseNotice(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getCloseConnectionOpts(WithCloseNotice("closed by admin"))
+	testOpts := closeConnectionDefaults()
+	testOpts.withNotice = true
+	testOpts.withNoticeDiagnostic = "closed by admin"
+	assert.Equal(opts, testOpts)
+}
+
+func TestServer_Connections(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	inFlight, release := make(chan struct{}), make(chan struct{})
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		inFlight <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	s, url := testServer(t, mux, WithClock(clock))
+
+	assert, require := assert.New(t), require.New(t)
+	alice, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer alice.Close()
+	require.NoError(alice.Bind("cn=alice", "password"))
+	clock.Advance(time.Minute)
+	bob, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer bob.Close()
+	require.NoError(bob.UnauthenticatedBind(""))
+
+	// bob has an in-flight search
+	searchErr := make(chan error, 1)
+	go func() {
+		_, err := bob.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		searchErr <- err
+	}()
+	<-inFlight
+
+	conns := s.Connections()
+	require.Len(conns, 2)
+	assert.Less(conns[0].ID, conns[1].ID)
+	assert.Equal("cn=alice", conns[0].BoundDN)
+	assert.Equal(time.Minute, conns[0].Age)
+	assert.Equal(0, conns[0].InFlight)
+	assert.Empty(conns[1].BoundDN)
+	assert.Equal(time.Duration(0), conns[1].Age)
+	assert.Equal(1, conns[1].InFlight)
+	for _, c := range conns {
+		assert.NotNil(c.RemoteAddr)
+		assert.NotNil(c.LocalAddr)
+		assert.False(c.TLS)
+	}
+
+	// closing a conn cancels its in-flight requests
+	require.NoError(s.CloseConnection(conns[1].ID))
+	assert.Error(<-searchErr)
+	assert.Eventually(func() bool { return len(s.Connections()) == 1 }, time.Second, 10*time.Millisecond)
+
+	// or it can be sent a notice of disconnection first
+	require.NoError(s.CloseConnection(conns[0].ID, WithCloseNotice("closed by admin")))
+	assert.Eventually(func() bool { return len(s.Connections()) == 0 }, time.Second, 10*time.Millisecond)
+	assert.Error(alice.Bind("cn=alice", "password"))
+
+	err = s.CloseConnection(conns[0].ID)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	assert.Contains(err.Error(), "unknown connection")
+	close(release)
+}
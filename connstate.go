@@ -0,0 +1,92 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ConnectionState is a concurrency-safe key/value store of a connection, which
+// lets handlers share state across the operations of the same connection (i.e.
+// the step of a multi-round SASL bind, or the cursors of paged searches).
+// Keys should be of an unexported type defined by the package that uses them
+// (like context keys), so the keys of different packages don't collide.  The
+// state is cleared once the connection is closed and its requests have
+// finished, which closes the values that implement io.Closer.  See:
+// Request.ConnectionState()
+type ConnectionState struct {
+	mu     sync.Mutex
+	values map[interface{}]interface{}
+
+	released bool          // cleared once its connection was closed
+	leaks    *atomic.Int64 // counts the values set once it was released (see: AssertNoLeaks)
+}
+
+// Get returns the key's value and true, or nil and false when the key is not
+// set.
+func (s *ConnectionState) Get(key interface{}) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set sets the key's value, which replaces its current value.
+func (s *ConnectionState) Set(key, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released && s.leaks != nil {
+		// a handler outlived its connection, and the value won't be cleared
+		s.leaks.Add(1)
+	}
+	if s.values == nil {
+		s.values = map[interface{}]interface{}{}
+	}
+	s.values[key] = value
+}
+
+// Delete removes the key and returns its value, or nil when the key is not set.
+// The removed value isn't closed.
+func (s *ConnectionState) Delete(key interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.values[key]
+	delete(s.values, key)
+	return v
+}
+
+// Len returns the number of keys that are set
+func (s *ConnectionState) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.values)
+}
+
+// clear removes all the keys and closes the values that implement io.Closer,
+// whose errors are ignored since the connection is already closed.
+func (s *ConnectionState) clear() {
+	s.mu.Lock()
+	values := s.values
+	s.values = nil
+	s.released = true
+	s.mu.Unlock()
+	for _, v := range values {
+		if c, ok := v.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+}
+
+// ConnectionState returns the state of the request's connection, which is
+// shared by all the requests of the connection.  See: ConnectionState
+func (r *Request) ConnectionState() *ConnectionState {
+	if r.conn == nil {
+		// a request that wasn't read from a connection has its own state
+		r.stateOnce.Do(func() { r.state = &ConnectionState{} })
+		return r.state
+	}
+	return &r.conn.state
+}
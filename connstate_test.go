@@ -0,0 +1,108 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStateKey struct{}
+
+// testCloser records when it's closed
+type testCloser chan struct{}
+
+func (c testCloser) Close() error {
+	close(c)
+	return nil
+}
+
+func TestConnectionState(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	var s ConnectionState
+	_, ok := s.Get(testStateKey{})
+	assert.False(ok)
+	assert.Nil(s.Delete(testStateKey{}))
+
+	s.Set(testStateKey{}, "step-1")
+	s.Set("other", 1)
+	v, ok := s.Get(testStateKey{})
+	assert.True(ok)
+	assert.Equal("step-1", v)
+	assert.Equal(2, s.Len())
+	assert.Equal(1, s.Delete("other"))
+	assert.Equal(1, s.Len())
+
+	closer := make(testCloser)
+	s.Set("closer", closer)
+	s.clear()
+	assert.Equal(0, s.Len())
+	select {
+	case <-closer:
+	default:
+		assert.Fail("closer wasn't closed")
+	}
+
+	r := &Request{}
+	r.ConnectionState().Set(testStateKey{}, "value")
+	v, _ = r.ConnectionState().Get(testStateKey{})
+	assert.Equal("value", v)
+}
+
+func TestRequest_ConnectionState(t *testing.T) {
+	t.Parallel()
+	closed := make(testCloser)
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		r.ConnectionState().Set(testStateKey{}, m.UserName)
+		r.ConnectionState().Set("closer", closed)
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		v, ok := r.ConnectionState().Get(testStateKey{})
+		if !ok {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultNoSuchObject)))
+			return
+		}
+		_ = w.Write(r.NewSearchResponseEntry(v.(string)))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux)
+	search := ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+
+	assert, require := assert.New(t), require.New(t)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	require.NoError(client.Bind("cn=alice", "password"))
+	result, err := client.Search(search)
+	require.NoError(err)
+	require.Len(result.Entries, 1)
+	assert.Equal("cn=alice", result.Entries[0].DN)
+
+	// the state isn't shared with other connections
+	other, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer other.Close()
+	_, err = other.Search(search)
+	assert.True(ldap.IsErrorWithCode(err, ResultNoSuchObject))
+
+	// the state is cleared once the connection is closed
+	client.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		assert.Fail("connection state wasn't cleared")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
 )
 
 const (
@@ -23,23 +24,65 @@ const (
 	ControlTypeManageDsaIT = "2.16.840.1.113730.3.4.2"
 	// ControlTypeWhoAmI - https://tools.ietf.org/html/rfc4532
 	ControlTypeWhoAmI = "1.3.6.1.4.1.4203.1.11.3"
+	// ControlTypeAssertion - https://tools.ietf.org/html/rfc4528
+	ControlTypeAssertion = "1.3.6.1.1.12"
+	// ControlTypeSyncRequest - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncRequest = "1.3.6.1.4.1.4203.1.9.1.1"
+	// ControlTypeSyncState - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncState = "1.3.6.1.4.1.4203.1.9.1.2"
+	// ControlTypeSyncDone - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncDone = "1.3.6.1.4.1.4203.1.9.1.3"
+	// ControlTypePersistentSearch - https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+	ControlTypePersistentSearch = "2.16.840.1.113730.3.4.3"
+	// ControlTypeEntryChangeNotification - https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+	ControlTypeEntryChangeNotification = "2.16.840.1.113730.3.4.7"
+	// ControlTypeGetEffectiveRights - https://tools.ietf.org/html/draft-ietf-ldapext-acl-model-08
+	ControlTypeGetEffectiveRights = "1.3.6.1.4.1.42.2.27.9.5.2"
+	// ControlTypeSessionTracking - https://tools.ietf.org/html/draft-wahl-ldap-session-03
+	ControlTypeSessionTracking = "1.3.6.1.4.1.21008.108.63.1"
+	// ControlTypeAuthzIDRequest - https://tools.ietf.org/html/rfc3829
+	ControlTypeAuthzIDRequest = "2.16.840.1.113730.3.4.16"
+	// ControlTypeAuthzIDResponse - https://tools.ietf.org/html/rfc3829
+	ControlTypeAuthzIDResponse = "2.16.840.1.113730.3.4.15"
+	// ControlTypeDereference - https://tools.ietf.org/html/draft-masarati-ldap-deref-00
+	ControlTypeDereference = "1.3.6.1.4.1.4203.666.5.16"
 
 	// ControlTypeMicrosoftNotification - https://msdn.microsoft.com/en-us/library/aa366983(v=vs.85).aspx
 	ControlTypeMicrosoftNotification = "1.2.840.113556.1.4.528"
+	// ControlTypeMicrosoftDirSync - https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
+	ControlTypeMicrosoftDirSync = "1.2.840.113556.1.4.841"
 	// ControlTypeMicrosoftShowDeleted - https://msdn.microsoft.com/en-us/library/aa366989(v=vs.85).aspx
 	ControlTypeMicrosoftShowDeleted = "1.2.840.113556.1.4.417"
+	// ControlTypeMicrosoftPermissiveModify - https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/4986b7ae-e2b6-4712-b5b5-3ab42e695ba5
+	ControlTypeMicrosoftPermissiveModify = "1.2.840.113556.1.4.1413"
+	// ControlTypeMicrosoftTreeDelete - https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/d8dd6c4f-e81b-4bd3-a849-fe5749c4fe2a
+	ControlTypeMicrosoftTreeDelete = "1.2.840.113556.1.4.805"
 	// ControlTypeMicrosoftServerLinkTTL - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/f4f523a8-abc0-4b3a-a471-6b2fef135481?redirectedfrom=MSDN
 	ControlTypeMicrosoftServerLinkTTL = "1.2.840.113556.1.4.2309"
 )
 
 // ControlTypeMap maps controls to text descriptions
 var ControlTypeMap = map[string]string{
-	ControlTypePaging:                 "Paging",
-	ControlTypeBeheraPasswordPolicy:   "Password Policy - Behera Draft",
-	ControlTypeManageDsaIT:            "Manage DSA IT",
-	ControlTypeMicrosoftNotification:  "Change Notification - Microsoft",
-	ControlTypeMicrosoftShowDeleted:   "Show Deleted Objects - Microsoft",
-	ControlTypeMicrosoftServerLinkTTL: "Return TTL-DNs for link values with associated expiry times - Microsoft",
+	ControlTypePaging:                    "Paging",
+	ControlTypeBeheraPasswordPolicy:      "Password Policy - Behera Draft",
+	ControlTypeManageDsaIT:               "Manage DSA IT",
+	ControlTypeAssertion:                 "Assertion",
+	ControlTypeSyncRequest:               "Sync Request",
+	ControlTypeSyncState:                 "Sync State",
+	ControlTypeSyncDone:                  "Sync Done",
+	ControlTypePersistentSearch:          "Persistent Search",
+	ControlTypeEntryChangeNotification:   "Entry Change Notification",
+	ControlTypeGetEffectiveRights:        "Get Effective Rights",
+	ControlTypeDereference:               "Dereference",
+	ControlTypeSessionTracking:           "Session Tracking",
+	ControlTypeAuthzIDRequest:            "Authorization Identity Request",
+	ControlTypeAuthzIDResponse:           "Authorization Identity Response",
+	ControlTypeMicrosoftNotification:     "Change Notification - Microsoft",
+	ControlTypeMicrosoftDirSync:          "DirSync - Microsoft",
+	ControlTypeMicrosoftShowDeleted:      "Show Deleted Objects - Microsoft",
+	ControlTypeMicrosoftPermissiveModify: "Permissive Modify - Microsoft",
+	ControlTypeMicrosoftTreeDelete:       "Tree Delete - Microsoft",
+	ControlTypeMicrosoftServerLinkTTL:    "Return TTL-DNs for link values with associated expiry times - Microsoft",
 }
 
 // Ldap Behera Password Policy Draft 10 (https://tools.ietf.org/html/draft-behera-ldap-password-policy-10)
@@ -235,10 +278,82 @@ func decodeControl(packet *ber.Packet) (Control, error) {
 		c.Expire = expire
 		value.Value = c.Expire
 		return c, nil
+	case ControlTypeAssertion:
+		if value == nil {
+			return nil, fmt.Errorf("%s: assertion control value is required: %w", op, ErrInvalidParameter)
+		}
+		value.Description += " (Assertion)"
+		filterPacket, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to decode assertion filter: %w", op, err)
+		}
+		filter, err := ldap.DecompileFilter(filterPacket)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to decompile assertion filter: %w", op, err)
+		}
+		return NewControlAssertion(filter, WithCriticality(Criticality))
+	case ControlTypeSyncRequest:
+		if value != nil {
+			value.Description += " (Sync Request)"
+		}
+		return decodeControlSyncRequest(value, Criticality)
+	case ControlTypeSyncState:
+		if value != nil {
+			value.Description += " (Sync State)"
+		}
+		return decodeControlSyncState(value, Criticality)
+	case ControlTypeSyncDone:
+		if value != nil {
+			value.Description += " (Sync Done)"
+		}
+		return decodeControlSyncDone(value, Criticality)
+	case ControlTypePersistentSearch:
+		if value != nil {
+			value.Description += " (Persistent Search)"
+		}
+		return decodeControlPersistentSearch(value, Criticality)
+	case ControlTypeEntryChangeNotification:
+		if value != nil {
+			value.Description += " (Entry Change Notification)"
+		}
+		return decodeControlEntryChangeNotification(value, Criticality)
+	case ControlTypeGetEffectiveRights:
+		if value != nil {
+			value.Description += " (Get Effective Rights)"
+		}
+		return decodeControlGetEffectiveRights(value, Criticality)
+	case ControlTypeDereference:
+		if value != nil {
+			value.Description += " (Dereference)"
+		}
+		return decodeControlDeref(value, Criticality)
+	case ControlTypeSessionTracking:
+		if value != nil {
+			value.Description += " (Session Tracking)"
+		}
+		return decodeControlSessionTracking(value, Criticality)
+	case ControlTypeAuthzIDRequest:
+		return NewControlAuthzIDRequest(WithCriticality(Criticality))
+	case ControlTypeAuthzIDResponse:
+		var authzID string
+		if value != nil {
+			value.Description += " (Authorization Identity Response)"
+			authzID = value.Data.String()
+		}
+		return NewControlAuthzIDResponse(authzID, WithCriticality(Criticality))
 	case ControlTypeMicrosoftNotification:
 		return NewControlMicrosoftNotification()
+	case ControlTypeMicrosoftDirSync:
+		if value != nil {
+			value.Description += " (DirSync)"
+		}
+		return decodeControlDirSyncRequest(value, Criticality)
 	case ControlTypeMicrosoftShowDeleted:
 		return NewControlMicrosoftShowDeleted()
+	case ControlTypeMicrosoftPermissiveModify:
+		return NewControlMicrosoftPermissiveModify(WithCriticality(Criticality))
+	case ControlTypeMicrosoftTreeDelete:
+		return NewControlMicrosoftTreeDelete(WithCriticality(Criticality))
 	case ControlTypeMicrosoftServerLinkTTL:
 		return NewControlMicrosoftServerLinkTTL()
 	default:
@@ -335,6 +450,76 @@ func NewControlManageDsaIT(opt ...Option) (*ControlManageDsaIT, error) {
 	return &ControlManageDsaIT{Criticality: opts.withCriticality}, nil
 }
 
+// ControlAssertion implements the assertion control described in
+// https://tools.ietf.org/html/rfc4528
+type ControlAssertion struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Filter is the assertion which must be true for the operation to be
+	// performed
+	Filter string
+}
+
+// GetControlType returns the OID
+func (c *ControlAssertion) GetControlType() string {
+	return ControlTypeAssertion
+}
+
+// Encode returns the ber packet representation
+func (c *ControlAssertion) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeAssertion, "Control Type ("+ControlTypeMap[ControlTypeAssertion]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Assertion)")
+	if f, err := ldap.CompileFilter(c.Filter); err == nil {
+		value.AppendChild(f)
+	}
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlAssertion) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Filter: %s",
+		ControlTypeMap[ControlTypeAssertion],
+		ControlTypeAssertion,
+		c.Criticality,
+		c.Filter)
+}
+
+// Evaluate returns true when the entry matches the control's assertion filter.
+func (c *ControlAssertion) Evaluate(e *Entry) (bool, error) {
+	const op = "gldap.(ControlAssertion).Evaluate"
+	if e == nil {
+		return false, fmt.Errorf("%s: missing entry: %w", op, ErrInvalidParameter)
+	}
+	ok, err := e.MatchFilter(c.Filter)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return ok, nil
+}
+
+// NewControlAssertion returns an assertion control for the filter.  Supported
+// options: WithCriticality
+func NewControlAssertion(filter string, opt ...Option) (*ControlAssertion, error) {
+	const op = "gldap.NewControlAssertion"
+	if filter == "" {
+		return nil, fmt.Errorf("%s: missing filter: %w", op, ErrInvalidParameter)
+	}
+	if _, err := ldap.CompileFilter(filter); err != nil {
+		return nil, fmt.Errorf("%s: invalid filter: %w", op, err)
+	}
+	opts := getControlOpts(opt...)
+	return &ControlAssertion{
+		Criticality: opts.withCriticality,
+		Filter:      filter,
+	}, nil
+}
+
 // ControlMicrosoftNotification implements the control described in https://msdn.microsoft.com/en-us/library/aa366983(v=vs.85).aspx
 type ControlMicrosoftNotification struct{}
 
@@ -425,6 +610,103 @@ func NewControlMicrosoftShowDeleted(_ ...Option) (*ControlMicrosoftShowDeleted,
 	return &ControlMicrosoftShowDeleted{}, nil
 }
 
+// ControlMicrosoftPermissiveModify implements the permissive modify control
+// (LDAP_SERVER_PERMISSIVE_MODIFY_OID), which asks the server to succeed when
+// a modify adds a value that already exists or deletes a value that doesn't
+// exist (see:
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/4986b7ae-e2b6-4712-b5b5-3ab42e695ba5)
+type ControlMicrosoftPermissiveModify struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftPermissiveModify) GetControlType() string {
+	return ControlTypeMicrosoftPermissiveModify
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftPermissiveModify) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftPermissiveModify, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftPermissiveModify]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlMicrosoftPermissiveModify) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeMicrosoftPermissiveModify],
+		ControlTypeMicrosoftPermissiveModify,
+		c.Criticality)
+}
+
+// NewControlMicrosoftPermissiveModify returns a
+// ControlMicrosoftPermissiveModify control.  Supported options:
+// WithCriticality
+func NewControlMicrosoftPermissiveModify(opt ...Option) (*ControlMicrosoftPermissiveModify, error) {
+	opts := getControlOpts(opt...)
+	return &ControlMicrosoftPermissiveModify{
+		Criticality: opts.withCriticality,
+	}, nil
+}
+
+// ControlMicrosoftTreeDelete implements the tree delete control
+// (LDAP_SERVER_TREE_DELETE_OID), which asks the server to delete an entry and
+// all of its subordinates (see:
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/d8dd6c4f-e81b-4bd3-a849-fe5749c4fe2a)
+type ControlMicrosoftTreeDelete struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftTreeDelete) GetControlType() string {
+	return ControlTypeMicrosoftTreeDelete
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftTreeDelete) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftTreeDelete, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftTreeDelete]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlMicrosoftTreeDelete) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeMicrosoftTreeDelete],
+		ControlTypeMicrosoftTreeDelete,
+		c.Criticality)
+}
+
+// NewControlMicrosoftTreeDelete returns a ControlMicrosoftTreeDelete control.
+// Supported options: WithCriticality
+func NewControlMicrosoftTreeDelete(opt ...Option) (*ControlMicrosoftTreeDelete, error) {
+	opts := getControlOpts(opt...)
+	return &ControlMicrosoftTreeDelete{
+		Criticality: opts.withCriticality,
+	}, nil
+}
+
+// findControl returns the first control with the OID, or nil if there isn't
+// one.
+func findControl(controls []Control, oid string) Control {
+	for _, c := range controls {
+		if c != nil && c.GetControlType() == oid {
+			return c
+		}
+	}
+	return nil
+}
+
 // ControlBeheraPasswordPolicy implements the control described in https://tools.ietf.org/html/draft-behera-ldap-password-policy-10
 type ControlBeheraPasswordPolicy struct {
 	// expire contains the number of seconds before a password will expire
@@ -4,11 +4,24 @@
 package gldap
 
 type controlOptions struct {
-	withGrace        int
-	withExpire       int
-	withErrorCode    int
-	withCriticality  bool
-	withControlValue string
+	withGrace            int
+	withExpire           int
+	withErrorCode        int
+	withCriticality      bool
+	withControlValue     string
+	withCookie           []byte
+	withReloadHint       bool
+	withRefreshDeletes   bool
+	withChangesOnly      bool
+	withReturnECs        bool
+	withPreviousDN       string
+	withChangeNumber     *int64
+	withAuthzID          string
+	withRightsAttributes []string
+	withMaxBytes         int64
+	withMoreResults      bool
+	withSourceIP         string
+	withSourceName       string
 
 	// test options
 	withTestType     string
@@ -76,6 +89,135 @@ func WithControlValue(value string) Option {
 	}
 }
 
+// WithCookie specifies the control's cookie
+func WithCookie(cookie []byte) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withCookie = cookie
+		}
+	}
+}
+
+// WithReloadHint specifies the reload hint of a sync request control
+func WithReloadHint(hint bool) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withReloadHint = hint
+		}
+	}
+}
+
+// WithRefreshDeletes specifies that deleted entries are sent (rather than
+// sending present entries) when the refresh phase of a content synchronization
+// is done.
+func WithRefreshDeletes(refreshDeletes bool) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withRefreshDeletes = refreshDeletes
+		}
+	}
+}
+
+// WithChangesOnly specifies that a persistent search only returns changed
+// entries (rather than first returning the entries matching the search)
+func WithChangesOnly(changesOnly bool) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withChangesOnly = changesOnly
+		}
+	}
+}
+
+// WithReturnECs specifies that a persistent search returns an entry change
+// notification control with every changed entry
+func WithReturnECs(returnECs bool) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withReturnECs = returnECs
+		}
+	}
+}
+
+// WithPreviousDN specifies the previous DN of an entry change notification,
+// which is only valid for a ChangeTypeModDN change.
+func WithPreviousDN(dn string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withPreviousDN = dn
+		}
+	}
+}
+
+// WithChangeNumber specifies the change number of an entry change
+// notification
+func WithChangeNumber(n int64) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withChangeNumber = &n
+		}
+	}
+}
+
+// WithAuthzID specifies the authorization identity (i.e. "dn:cn=alice,...")
+// of a get effective rights control
+func WithAuthzID(authzID string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withAuthzID = authzID
+		}
+	}
+}
+
+// WithRightsAttributes specifies the attributes of a get effective rights
+// control
+func WithRightsAttributes(attributes ...string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withRightsAttributes = attributes
+		}
+	}
+}
+
+// WithMaxBytes specifies the max number of bytes to return for a DirSync
+// request control
+func WithMaxBytes(n int64) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withMaxBytes = n
+		}
+	}
+}
+
+// WithMoreResults specifies that a DirSync response control indicates there
+// are more changes for the client to request
+func WithMoreResults(more bool) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withMoreResults = more
+		}
+	}
+}
+
+// WithSourceIP specifies the IP address of the session source (i.e. the
+// end-user's client) for a session tracking control
+func WithSourceIP(ip string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withSourceIP = ip
+		}
+	}
+}
+
+// WithSourceName specifies the host name of the session source (i.e. the
+// end-user's client) for a session tracking control
+func WithSourceName(name string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*controlOptions); ok {
+			o.withSourceName = name
+		}
+	}
+}
+
 func withTestType(s string) Option {
 	return func(o interface{}) {
 		if o, ok := o.(*controlOptions); ok {
@@ -33,6 +33,34 @@ func TestControlManageDsaIT(t *testing.T) {
 	runControlTest(t, testControlManageDsaIT(t))
 }
 
+func TestControlAssertion(t *testing.T) {
+	runControlTest(t,
+		testControlAssertion(t, "(&(cn=alice)(objectClass=person))", WithCriticality(true)),
+		withTestType(ControlTypeAssertion),
+		withTestToString("Control Type: Assertion (\"1.3.6.1.1.12\")  Criticality: true  Filter: (&(cn=alice)(objectClass=person))"),
+	)
+	runControlTest(t, testControlAssertion(t, "(uid=*)"))
+
+	t.Run("invalid-filter", func(t *testing.T) {
+		_, err := NewControlAssertion("(cn=alice")
+		require.Error(t, err)
+		_, err = NewControlAssertion("")
+		require.ErrorIs(t, err, ErrInvalidParameter)
+	})
+	t.Run("evaluate", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c := testControlAssertion(t, "(cn=alice)")
+		ok, err := c.Evaluate(NewEntry("cn=alice,ou=people", map[string][]string{"cn": {"alice"}}))
+		require.NoError(err)
+		assert.True(ok)
+		ok, err = c.Evaluate(NewEntry("cn=eve,ou=people", map[string][]string{"cn": {"eve"}}))
+		require.NoError(err)
+		assert.False(ok)
+		_, err = c.Evaluate(nil)
+		assert.ErrorIs(err, ErrInvalidParameter)
+	})
+}
+
 func TestControlMicrosoftNotification(t *testing.T) {
 	runControlTest(t,
 		testControlMicrosoftNotification(t),
@@ -57,6 +85,24 @@ func TestControlMicrosoftServerLinkTTL(t *testing.T) {
 	)
 }
 
+func TestControlMicrosoftPermissiveModify(t *testing.T) {
+	runControlTest(t,
+		testControlMicrosoftPermissiveModify(t, WithCriticality(true)),
+		withTestType(ControlTypeMicrosoftPermissiveModify),
+		withTestToString("Control Type: Permissive Modify - Microsoft (\"1.2.840.113556.1.4.1413\")  Criticality: true"),
+	)
+	runControlTest(t, testControlMicrosoftPermissiveModify(t))
+}
+
+func TestControlMicrosoftTreeDelete(t *testing.T) {
+	runControlTest(t,
+		testControlMicrosoftTreeDelete(t, WithCriticality(true)),
+		withTestType(ControlTypeMicrosoftTreeDelete),
+		withTestToString("Control Type: Tree Delete - Microsoft (\"1.2.840.113556.1.4.805\")  Criticality: true"),
+	)
+	runControlTest(t, testControlMicrosoftTreeDelete(t))
+}
+
 func TestControlString(t *testing.T) {
 	runControlTest(t,
 		testControlString(t, "x", WithCriticality(true), WithControlValue("y")),
@@ -125,6 +171,11 @@ func TestDescribeControlPaging(t *testing.T) {
 	runAddControlDescriptions(t, testControlPaging(t, 0), "Control Type (Paging)", "Control Value (Paging)")
 }
 
+func TestDescribeControlAssertion(t *testing.T) {
+	runAddControlDescriptions(t, testControlAssertion(t, "(cn=alice)"), "Control Type (Assertion)", "Control Value")
+	runAddControlDescriptions(t, testControlAssertion(t, "(cn=alice)", WithCriticality(true)), "Control Type (Assertion)", "Criticality", "Control Value")
+}
+
 func TestDescribeControlMicrosoftNotification(t *testing.T) {
 	runAddControlDescriptions(t, testControlMicrosoftNotification(t), "Control Type (Change Notification - Microsoft)")
 }
@@ -137,6 +188,14 @@ func TestDescribeControlMicrosoftServerLinkTTL(t *testing.T) {
 	runAddControlDescriptions(t, testControlMicrosoftServerLinkTTL(t), "Control Type (Return TTL-DNs for link values with associated expiry times - Microsoft)")
 }
 
+func TestDescribeControlMicrosoftPermissiveModify(t *testing.T) {
+	runAddControlDescriptions(t, testControlMicrosoftPermissiveModify(t, WithCriticality(true)), "Control Type (Permissive Modify - Microsoft)", "Criticality")
+}
+
+func TestDescribeControlMicrosoftTreeDelete(t *testing.T) {
+	runAddControlDescriptions(t, testControlMicrosoftTreeDelete(t, WithCriticality(true)), "Control Type (Tree Delete - Microsoft)", "Criticality")
+}
+
 func TestDescribeControlString(t *testing.T) {
 	runAddControlDescriptions(t, testControlString(t, "x", WithCriticality(true), WithControlValue("y")), "Control Type ()", "Criticality", "Control Value")
 	runAddControlDescriptions(t, testControlString(t, "x", WithCriticality(true)), "Control Type ()", "Criticality")
@@ -0,0 +1,133 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "fmt"
+
+// CriticalControlPolicy specifies how the requests with unknown critical
+// controls are served (see: WithCriticalControlPolicy and
+// WithRouteCriticalControlPolicy).  A control is unknown when its type isn't
+// one of the ControlTypeMap's, so a handler that supports other controls should
+// declare their types with WithSupportedControls or WithRouteSupportedControls.
+type CriticalControlPolicy int
+
+const (
+	// RejectUnknownCriticalControls responds to the requests with an unknown
+	// critical control with unavailableCriticalExtension, without invoking a
+	// handler (see: https://tools.ietf.org/html/rfc4511#section-4.1.11).  It's
+	// the default policy.
+	RejectUnknownCriticalControls CriticalControlPolicy = iota + 1
+
+	// IgnoreUnknownCriticalControls serves the requests with unknown critical
+	// controls as if the controls weren't critical (i.e. for an emulator of a
+	// directory whose clients send controls the emulator doesn't implement).
+	IgnoreUnknownCriticalControls
+)
+
+// unknownCriticalControlDiag is the diagnostic message of the responses to
+// requests with an unknown critical control
+const unknownCriticalControlDiag = "unsupported critical control %s"
+
+// WithCriticalControlPolicy sets the policy of the server's routes for
+// requests with unknown critical controls (see: CriticalControlPolicy), which
+// is RejectUnknownCriticalControls by default.  A route's policy takes
+// precedence (see: WithRouteCriticalControlPolicy).  An invalid policy is
+// ignored.
+func WithCriticalControlPolicy(p CriticalControlPolicy) Option {
+	return serverOption(func(o *configOptions) {
+		if p.valid() {
+			o.withCriticalControlPolicy = p
+		}
+	})
+}
+
+// WithRouteCriticalControlPolicy sets the route's policy for requests with
+// unknown critical controls (see: CriticalControlPolicy), which takes
+// precedence over the server's policy (see: WithCriticalControlPolicy).  An
+// invalid policy is ignored.
+func WithRouteCriticalControlPolicy(p CriticalControlPolicy) Option {
+	return routeOption(func(o *routeOptions) {
+		if p.valid() {
+			o.withCriticalControlPolicy = p
+		}
+	})
+}
+
+// WithSupportedControls declares the types (OIDs) of the controls the server's
+// routes support in addition to the ControlTypeMap's, so requests with them
+// aren't rejected and they aren't counted as unknown controls.
+func WithSupportedControls(controlTypes ...string) Option {
+	return serverOption(func(o *configOptions) {
+		o.withSupportedControls = append(o.withSupportedControls, controlTypes...)
+	})
+}
+
+// WithRouteSupportedControls declares the types (OIDs) of the controls the
+// route's handler supports in addition to the server's (see:
+// WithSupportedControls), so requests with them aren't rejected by the route.
+func WithRouteSupportedControls(controlTypes ...string) Option {
+	return routeOption(func(o *routeOptions) {
+		o.withSupportedControls = append(o.withSupportedControls, controlTypes...)
+	})
+}
+
+func (p CriticalControlPolicy) valid() bool {
+	return p == RejectUnknownCriticalControls || p == IgnoreUnknownCriticalControls
+}
+
+// isUnknownControl returns the control's type and true when the control isn't
+// known (see: CriticalControlPolicy) or one of the supported types, along with
+// its criticality
+func isUnknownControl(c Control, supported ...[]string) (controlType string, critical bool, unknown bool) {
+	cs, ok := c.(*ControlString)
+	if !ok {
+		return "", false, false
+	}
+	if _, ok := ControlTypeMap[cs.ControlType]; ok {
+		return "", false, false
+	}
+	for _, types := range supported {
+		for _, t := range types {
+			if t == cs.ControlType {
+				return "", false, false
+			}
+		}
+	}
+	return cs.ControlType, cs.Criticality, true
+}
+
+// unknownCriticalControl returns the type of the request's first unknown
+// critical control which isn't one of the supported types, and false when the
+// request doesn't have one.
+func (r *Request) unknownCriticalControl(supported ...[]string) (string, bool) {
+	for _, c := range r.controls() {
+		if t, critical, unknown := isUnknownControl(c, supported...); unknown && critical {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// criticalControlsSupported returns ResultSuccess if the route serves the
+// request's critical controls, or the result code and diagnostic message of
+// the response when it has an unknown critical control the route's policy
+// (or its conn's policy) rejects.
+func (r *baseRoute) criticalControlsSupported(req *Request) (int, string) {
+	supported := [][]string{r.supportedControls}
+	if req.conn != nil {
+		supported = append(supported, req.conn.supportedCtrls)
+	}
+	t, ok := req.unknownCriticalControl(supported...)
+	if !ok {
+		return ResultSuccess, ""
+	}
+	policy := r.criticalControlPolicy
+	if policy == 0 && req.conn != nil {
+		policy = req.conn.criticalCtrl
+	}
+	if policy == IgnoreUnknownCriticalControls {
+		return ResultSuccess, ""
+	}
+	return ResultUnavailableCriticalExtension, fmt.Sprintf(unknownCriticalControlDiag, t)
+}
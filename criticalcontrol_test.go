@@ -0,0 +1,125 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_CriticalControlPolicy(t *testing.T) {
+	t.Parallel()
+	newMux := func(t *testing.T) *Mux {
+		t.Helper()
+		mux, err := NewMux()
+		require.NoError(t, err)
+		require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}, WithRouteCriticalControlPolicy(IgnoreUnknownCriticalControls)))
+		require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		return mux
+	}
+	search := func(client *ldap.Conn, controls ...ldap.Control) error {
+		_, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, controls))
+		return err
+	}
+	bind := func(client *ldap.Conn, controls ...ldap.Control) error {
+		_, err := client.SimpleBind(&ldap.SimpleBindRequest{Username: "cn=alice", Password: "password", Controls: controls})
+		return err
+	}
+	unknownCritical := ldap.NewControlString("1.2.3.4", true, "")
+
+	t.Run("reject", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		s, url := testServer(t, newMux(t))
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+
+		err = search(client, unknownCritical)
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, ResultUnavailableCriticalExtension))
+		assert.Contains(err.Error(), "unsupported critical control 1.2.3.4")
+		// unknown controls that aren't critical and known critical controls
+		// are served
+		assert.NoError(search(client, ldap.NewControlString("1.2.3.4", false, "")))
+		assert.NoError(search(client, ldap.NewControlManageDsaIT(true)))
+		// the bind route's policy ignores unknown critical controls
+		assert.NoError(bind(client, unknownCritical))
+
+		// the unknown controls are counted
+		var sb strings.Builder
+		require.NoError(s.WriteMetrics(&sb))
+		assert.Contains(sb.String(), `gldap_unknown_controls_total{type="1.2.3.4",critical="false"} 1`)
+		assert.Contains(sb.String(), `gldap_unknown_controls_total{type="1.2.3.4",critical="true"} 2`)
+	})
+	t.Run("default-route", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.DefaultRoute(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		_, url := testServer(t, mux)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+
+		err = search(client, unknownCritical)
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, ResultUnavailableCriticalExtension))
+		assert.NoError(search(client))
+	})
+	t.Run("supported", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mux := newMux(t)
+		require.NoError(mux.Add(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationAddResponse), WithResponseCode(ResultSuccess)))
+		}, WithRouteSupportedControls("1.2.3.5")))
+		s, url := testServer(t, mux, WithSupportedControls("1.2.3.4"))
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+
+		// the server's supported controls are served by every route and
+		// aren't counted as unknown
+		assert.NoError(search(client, unknownCritical))
+		var sb strings.Builder
+		require.NoError(s.WriteMetrics(&sb))
+		assert.NotContains(sb.String(), `gldap_unknown_controls_total{type="1.2.3.4"`)
+
+		// the route's supported controls are only served by the route
+		routeCritical := ldap.NewControlString("1.2.3.5", true, "")
+		assert.True(ldap.IsErrorWithCode(search(client, routeCritical), ResultUnavailableCriticalExtension))
+		add := ldap.NewAddRequest("cn=alice", []ldap.Control{routeCritical})
+		add.Attribute("objectClass", []string{"person"})
+		assert.NoError(client.Add(add))
+	})
+	t.Run("ignore", func(t *testing.T) {
+		require := require.New(t)
+		_, url := testServer(t, newMux(t), WithCriticalControlPolicy(IgnoreUnknownCriticalControls))
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(search(client, unknownCritical))
+	})
+}
+
+func TestMux_Routes_criticalControlPolicy(t *testing.T) {
+	t.Parallel()
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {}, WithRouteCriticalControlPolicy(IgnoreUnknownCriticalControls)))
+	require.NoError(t, mux.Add(func(w *ResponseWriter, r *Request) {}))
+	routes := mux.Routes()
+	require.Len(t, routes, 2)
+	assert.Equal(t, IgnoreUnknownCriticalControls, routes[0].CriticalControlPolicy)
+	assert.Zero(t, routes[1].CriticalControlPolicy)
+}
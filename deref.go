@@ -0,0 +1,282 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// DerefSpec specifies an attribute whose values are DNs of entries to
+// dereference and the attributes of those entries to return (see:
+// https://tools.ietf.org/html/draft-masarati-ldap-deref-00)
+type DerefSpec struct {
+	// DerefAttr is the attribute holding the DNs to dereference (i.e. member)
+	DerefAttr string
+	// Attributes of the dereferenced entries to return
+	Attributes []string
+}
+
+// DerefResult is a dereferenced value of an entry's attribute
+type DerefResult struct {
+	// DerefAttr is the attribute holding the dereferenced DN
+	DerefAttr string
+	// DerefVal is the dereferenced DN
+	DerefVal string
+	// Attributes of the dereferenced entry
+	Attributes []*EntryAttribute
+}
+
+// ControlDerefRequest implements the dereference request control described in
+// https://tools.ietf.org/html/draft-masarati-ldap-deref-00
+type ControlDerefRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Specs are the attributes to dereference
+	Specs []DerefSpec
+}
+
+// GetControlType returns the OID
+func (c *ControlDerefRequest) GetControlType() string {
+	return ControlTypeDereference
+}
+
+// Encode returns the ber packet representation
+func (c *ControlDerefRequest) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeDereference, "Control Type ("+ControlTypeMap[ControlTypeDereference]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Deref Specs")
+	for _, s := range c.Specs {
+		spec := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Deref Spec")
+		spec.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, s.DerefAttr, "Deref Attr"))
+		attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for _, a := range s.Attributes {
+			attrs.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a, "Attribute"))
+		}
+		spec.AppendChild(attrs)
+		seq.AppendChild(spec)
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Dereference)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlDerefRequest) String() string {
+	specs := make([]string, 0, len(c.Specs))
+	for _, s := range c.Specs {
+		specs = append(specs, fmt.Sprintf("%s:%s", s.DerefAttr, strings.Join(s.Attributes, ",")))
+	}
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Specs: %s",
+		ControlTypeMap[ControlTypeDereference],
+		ControlTypeDereference,
+		c.Criticality,
+		specs)
+}
+
+// NewControlDerefRequest returns a dereference request control. Supported
+// options: WithCriticality
+func NewControlDerefRequest(specs []DerefSpec, opt ...Option) (*ControlDerefRequest, error) {
+	const op = "gldap.NewControlDerefRequest"
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("%s: missing deref specs: %w", op, ErrInvalidParameter)
+	}
+	for _, s := range specs {
+		switch {
+		case s.DerefAttr == "":
+			return nil, fmt.Errorf("%s: missing deref attribute: %w", op, ErrInvalidParameter)
+		case len(s.Attributes) == 0:
+			return nil, fmt.Errorf("%s: missing attributes for deref attribute %q: %w", op, s.DerefAttr, ErrInvalidParameter)
+		}
+	}
+	opts := getControlOpts(opt...)
+	return &ControlDerefRequest{
+		Criticality: opts.withCriticality,
+		Specs:       specs,
+	}, nil
+}
+
+// ControlDerefResponse implements the dereference response control described
+// in https://tools.ietf.org/html/draft-masarati-ldap-deref-00, which is
+// attached to a search result entry (see: SearchResponseEntry.SetControls)
+type ControlDerefResponse struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Results are the dereferenced values
+	Results []DerefResult
+}
+
+// GetControlType returns the OID
+func (c *ControlDerefResponse) GetControlType() string {
+	return ControlTypeDereference
+}
+
+// Encode returns the ber packet representation
+func (c *ControlDerefResponse) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeDereference, "Control Type ("+ControlTypeMap[ControlTypeDereference]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Deref Results")
+	for _, r := range c.Results {
+		res := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Deref Result")
+		res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, r.DerefAttr, "Deref Attr"))
+		res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, r.DerefVal, "Deref Val"))
+		if len(r.Attributes) > 0 {
+			attrs := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Attr Vals")
+			for _, a := range r.Attributes {
+				attrs.AppendChild(a.encode())
+			}
+			res.AppendChild(attrs)
+		}
+		seq.AppendChild(res)
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Dereference)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlDerefResponse) String() string {
+	results := make([]string, 0, len(c.Results))
+	for _, r := range c.Results {
+		results = append(results, fmt.Sprintf("%s:%s", r.DerefAttr, r.DerefVal))
+	}
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Results: %s",
+		ControlTypeMap[ControlTypeDereference],
+		ControlTypeDereference,
+		c.Criticality,
+		results)
+}
+
+// NewControlDerefResponse returns a dereference response control (see:
+// NewDerefResults). Supported options: WithCriticality
+func NewControlDerefResponse(results []DerefResult, opt ...Option) (*ControlDerefResponse, error) {
+	const op = "gldap.NewControlDerefResponse"
+	for _, r := range results {
+		switch {
+		case r.DerefAttr == "":
+			return nil, fmt.Errorf("%s: missing deref attribute: %w", op, ErrInvalidParameter)
+		case r.DerefVal == "":
+			return nil, fmt.Errorf("%s: missing deref value for deref attribute %q: %w", op, r.DerefAttr, ErrInvalidParameter)
+		}
+	}
+	opts := getControlOpts(opt...)
+	return &ControlDerefResponse{
+		Criticality: opts.withCriticality,
+		Results:     results,
+	}, nil
+}
+
+// decodeControlDeref decodes either a dereference request or response control,
+// since they share the same OID.  A response is distinguished by its results
+// having a DN (octet string) rather than an attribute list (sequence) or by
+// being empty, since a request requires at least one spec.
+func decodeControlDeref(value *ber.Packet, criticality bool) (Control, error) {
+	const op = "gldap.decodeControlDeref"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, child := range seq.Children {
+		if len(child.Children) < 2 {
+			return nil, fmt.Errorf("%s: invalid number of children (%d) in dereference control: %w", op, len(child.Children), ErrInvalidParameter)
+		}
+	}
+	if len(seq.Children) == 0 || seq.Children[0].Children[1].Tag == ber.TagOctetString {
+		results := make([]DerefResult, 0, len(seq.Children))
+		for _, child := range seq.Children {
+			r := DerefResult{
+				DerefAttr: child.Children[0].Data.String(),
+				DerefVal:  child.Children[1].Data.String(),
+			}
+			if len(child.Children) > 2 {
+				for _, a := range child.Children[2].Children {
+					if len(a.Children) != 2 {
+						return nil, fmt.Errorf("%s: invalid attribute in dereference response control: %w", op, ErrInvalidParameter)
+					}
+					vals := make([]string, 0, len(a.Children[1].Children))
+					for _, v := range a.Children[1].Children {
+						vals = append(vals, v.Data.String())
+					}
+					r.Attributes = append(r.Attributes, NewEntryAttribute(a.Children[0].Data.String(), vals))
+				}
+			}
+			results = append(results, r)
+		}
+		c, err := NewControlDerefResponse(results, WithCriticality(criticality))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return c, nil
+	}
+	specs := make([]DerefSpec, 0, len(seq.Children))
+	for _, child := range seq.Children {
+		s := DerefSpec{DerefAttr: child.Children[0].Data.String()}
+		for _, a := range child.Children[1].Children {
+			s.Attributes = append(s.Attributes, a.Data.String())
+		}
+		specs = append(specs, s)
+	}
+	c, err := NewControlDerefRequest(specs, WithCriticality(criticality))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+// GetDerefRequestControl returns the request's dereference request control
+// and false if the request doesn't have one.
+func (r *Request) GetDerefRequestControl() (*ControlDerefRequest, bool) {
+	c, ok := r.findControl(ControlTypeDereference).(*ControlDerefRequest)
+	return c, ok
+}
+
+// NewDerefResults dereferences the entry's attribute values for each of the
+// specs using the lookup func, which returns the entry for a DN or nil when
+// the entry doesn't exist.  Values referencing an entry that doesn't exist are
+// omitted from the results.  The results are typically attached to a search
+// result entry using a ControlDerefResponse.
+func NewDerefResults(e *Entry, specs []DerefSpec, lookup func(dn string) (*Entry, error)) ([]DerefResult, error) {
+	const op = "gldap.NewDerefResults"
+	switch {
+	case e == nil:
+		return nil, fmt.Errorf("%s: missing entry: %w", op, ErrInvalidParameter)
+	case lookup == nil:
+		return nil, fmt.Errorf("%s: missing lookup func: %w", op, ErrInvalidParameter)
+	}
+	var results []DerefResult
+	for _, s := range specs {
+		for _, dn := range e.attributeValues(s.DerefAttr) {
+			ref, err := lookup(dn)
+			if err != nil {
+				return nil, fmt.Errorf("%s: unable to lookup %q: %w", op, dn, err)
+			}
+			if ref == nil {
+				continue
+			}
+			r := DerefResult{
+				DerefAttr: s.DerefAttr,
+				DerefVal:  dn,
+			}
+			for _, a := range s.Attributes {
+				if vals := ref.attributeValues(a); len(vals) > 0 {
+					r.Attributes = append(r.Attributes, NewEntryAttribute(a, vals))
+				}
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlDerefRequest(t *testing.T) {
+	runControlTest(t,
+		testControlDerefRequest(t, []DerefSpec{
+			{DerefAttr: "member", Attributes: []string{"uid", "cn"}},
+			{DerefAttr: "manager", Attributes: []string{"mail"}},
+		}, WithCriticality(true)),
+		withTestType(ControlTypeDereference),
+		withTestToString("Control Type: Dereference (\"1.3.6.1.4.1.4203.666.5.16\")  Criticality: true  Specs: [member:uid,cn manager:mail]"),
+	)
+
+	_, err := NewControlDerefRequest(nil)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewControlDerefRequest([]DerefSpec{{Attributes: []string{"uid"}}})
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewControlDerefRequest([]DerefSpec{{DerefAttr: "member"}})
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestControlDerefResponse(t *testing.T) {
+	runControlTest(t,
+		testControlDerefResponse(t, []DerefResult{
+			{
+				DerefAttr:  "member",
+				DerefVal:   "uid=alice,ou=people,dc=example,dc=org",
+				Attributes: []*EntryAttribute{NewEntryAttribute("uid", []string{"alice"})},
+			},
+			{DerefAttr: "member", DerefVal: "uid=bob,ou=people,dc=example,dc=org"},
+		}),
+		withTestType(ControlTypeDereference),
+		withTestToString("Control Type: Dereference (\"1.3.6.1.4.1.4203.666.5.16\")  Criticality: false  Results: [member:uid=alice,ou=people,dc=example,dc=org member:uid=bob,ou=people,dc=example,dc=org]"),
+	)
+
+	runControlTest(t, testControlDerefResponse(t, nil))
+
+	_, err := NewControlDerefResponse([]DerefResult{{DerefVal: "uid=alice"}})
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewControlDerefResponse([]DerefResult{{DerefAttr: "member"}})
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestRequest_GetDerefRequestControl(t *testing.T) {
+	assert := assert.New(t)
+	deref := testControlDerefRequest(t, []DerefSpec{{DerefAttr: "member", Attributes: []string{"uid"}}})
+	r := &Request{message: &SearchMessage{Controls: []Control{deref}}}
+	got, ok := r.GetDerefRequestControl()
+	assert.True(ok)
+	assert.Equal(deref, got)
+
+	r = &Request{message: &SearchMessage{Controls: []Control{testControlDerefResponse(t, nil)}}}
+	_, ok = r.GetDerefRequestControl()
+	assert.False(ok)
+}
+
+func TestNewDerefResults(t *testing.T) {
+	entries := map[string]*Entry{
+		"uid=alice,ou=people,dc=example,dc=org": NewEntry("uid=alice,ou=people,dc=example,dc=org", map[string][]string{"uid": {"alice"}, "cn": {"Alice"}, "mail": {"alice@example.org"}}),
+		"uid=bob,ou=people,dc=example,dc=org":   NewEntry("uid=bob,ou=people,dc=example,dc=org", map[string][]string{"uid": {"bob"}}),
+	}
+	lookup := func(dn string) (*Entry, error) {
+		return entries[dn], nil
+	}
+	group := NewEntry("cn=admins,ou=groups,dc=example,dc=org", map[string][]string{
+		"member": {"uid=alice,ou=people,dc=example,dc=org", "uid=bob,ou=people,dc=example,dc=org", "uid=eve,ou=people,dc=example,dc=org"},
+	})
+	specs := []DerefSpec{{DerefAttr: "Member", Attributes: []string{"uid", "cn"}}}
+
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		got, err := NewDerefResults(group, specs, lookup)
+		require.NoError(err)
+		assert.Equal([]DerefResult{
+			{
+				DerefAttr: "Member",
+				DerefVal:  "uid=alice,ou=people,dc=example,dc=org",
+				Attributes: []*EntryAttribute{
+					NewEntryAttribute("uid", []string{"alice"}),
+					NewEntryAttribute("cn", []string{"Alice"}),
+				},
+			},
+			{
+				DerefAttr:  "Member",
+				DerefVal:   "uid=bob,ou=people,dc=example,dc=org",
+				Attributes: []*EntryAttribute{NewEntryAttribute("uid", []string{"bob"})},
+			},
+		}, got)
+	})
+	t.Run("lookup-error", func(t *testing.T) {
+		_, err := NewDerefResults(group, specs, func(string) (*Entry, error) { return nil, errors.New("lookup failed") })
+		assert.ErrorContains(t, err, "lookup failed")
+	})
+	t.Run("missing-entry", func(t *testing.T) {
+		_, err := NewDerefResults(nil, specs, lookup)
+		assert.ErrorIs(t, err, ErrInvalidParameter)
+	})
+	t.Run("missing-lookup", func(t *testing.T) {
+		_, err := NewDerefResults(group, specs, nil)
+		assert.ErrorIs(t, err, ErrInvalidParameter)
+	})
+}
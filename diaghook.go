@@ -0,0 +1,47 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+// DiagnosticMessageHook transforms the diagnostic message of a response before
+// it's written (i.e. to translate it, append a ticket ID, or strip internal
+// details), and returns the diagnostic message that's sent.  The request is
+// the one being responded to, which is nil for a notice of disconnection.
+// See: WithDiagnosticMessageHook
+type DiagnosticMessageHook func(r *Request, code int, msg string) string
+
+// WithDiagnosticMessageHook sets a hook which transforms the diagnostic
+// messages of the server's responses before they're written.  It's applied
+// uniformly to the result of every response that has one (the final response
+// of every request, including the responses written by the server itself and
+// notices of disconnection), even when its message is empty, after the
+// ResponseWriter's interceptors (see: ResponseWriter.WithInterceptor).  The
+// hook must be safe for concurrent use.
+func WithDiagnosticMessageHook(hook DiagnosticMessageHook) Option {
+	return serverOption(func(o *configOptions) {
+		o.withDiagnosticHook = hook
+	})
+}
+
+// diagnosticResponse is a response with a result and diagnostic message
+type diagnosticResponse interface {
+	resultCode() int
+	diagnosticMessage() string
+	SetDiagnosticMessage(string)
+}
+
+// diagnosticMessage returns the response's diagnostic message
+func (l *baseResponse) diagnosticMessage() string {
+	return l.diagMessage
+}
+
+// applyDiagnosticHook transforms the diagnostic message of the response with
+// the hook, which is a no-op for a nil hook or a response without a result.
+func applyDiagnosticHook(hook DiagnosticMessageHook, req *Request, r Response) {
+	if hook == nil || !isFinalResponse(r) {
+		return
+	}
+	if d, ok := r.(diagnosticResponse); ok {
+		d.SetDiagnosticMessage(hook(req, d.resultCode(), d.diagnosticMessage()))
+	}
+}
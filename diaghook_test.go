@@ -0,0 +1,78 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WithDiagnosticMessageHook(t *testing.T) {
+	t.Parallel()
+	translations := map[string]string{"invalid credentials": "identifiants invalides"}
+	hook := func(r *Request, code int, msg string) string {
+		if code == ResultSuccess || r == nil {
+			return msg
+		}
+		if tr, ok := translations[msg]; ok {
+			msg = tr
+		}
+		return fmt.Sprintf("%s (conn %d, code %d)", msg, r.ConnectionID(), code)
+	}
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		resp := r.NewBindResponse(WithResponseCode(ResultInvalidCredentials))
+		resp.SetDiagnosticMessage("invalid credentials")
+		_ = w.Write(resp)
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		e := r.NewSearchResponseEntry("cn=alice")
+		e.AddAttribute("cn", []string{"alice"})
+		_ = w.Write(e)
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess), WithDiagnosticMessage("done")))
+	}))
+	_, url := testServer(t, mux, WithDiagnosticMessageHook(hook), WithReadOnly())
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+	defer client.Close()
+
+	assert, require := assert.New(t), require.New(t)
+	err = client.Bind("cn=alice", "password")
+	require.Error(err)
+	assert.Contains(err.Error(), "identifiants invalides (conn ")
+	assert.Contains(err.Error(), fmt.Sprintf("code %d)", ResultInvalidCredentials))
+
+	// the responses written by the server itself are transformed too
+	err = client.Del(ldap.NewDelRequest("cn=alice", nil))
+	require.Error(err)
+	assert.True(ldap.IsErrorWithCode(err, ResultUnwillingToPerform))
+	assert.Contains(err.Error(), fmt.Sprintf("code %d)", ResultUnwillingToPerform))
+
+	result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	require.Len(result.Entries, 1)
+	assert.Equal([]string{"alice"}, result.Entries[0].GetAttributeValues("cn"))
+}
+
+func Test_applyDiagnosticHook(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	hook := func(r *Request, code int, msg string) string {
+		assert.Nil(r)
+		return fmt.Sprintf("%d: %s", code, msg)
+	}
+	n := noticeOfDisconnection(ResultUnavailable, "shutting down")
+	applyDiagnosticHook(hook, nil, n)
+	assert.Equal(fmt.Sprintf("%d: shutting down", ResultUnavailable), n.diagnosticMessage())
+
+	// a nil hook is a no-op
+	n = noticeOfDisconnection(ResultUnavailable, "shutting down")
+	applyDiagnosticHook(nil, nil, n)
+	assert.Equal("shutting down", n.diagnosticMessage())
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "fmt"
+
+// ADBindErrorData is the sub-code ("data" value) Active Directory includes in
+// the diagnostic message of a failed bind response.  Clients often branch on
+// the sub-code to determine why a bind failed.
+type ADBindErrorData string
+
+// Active Directory bind error sub-codes
+const (
+	// ADBindErrorUserNotFound indicates the user was not found
+	ADBindErrorUserNotFound ADBindErrorData = "525"
+	// ADBindErrorInvalidCredentials indicates an invalid password
+	ADBindErrorInvalidCredentials ADBindErrorData = "52e"
+	// ADBindErrorInvalidLogonHours indicates the user is not permitted to log
+	// on at this time
+	ADBindErrorInvalidLogonHours ADBindErrorData = "530"
+	// ADBindErrorInvalidWorkstation indicates the user is not permitted to log
+	// on from this workstation
+	ADBindErrorInvalidWorkstation ADBindErrorData = "531"
+	// ADBindErrorPasswordExpired indicates the user's password has expired
+	ADBindErrorPasswordExpired ADBindErrorData = "532"
+	// ADBindErrorAccountDisabled indicates the account is disabled
+	ADBindErrorAccountDisabled ADBindErrorData = "533"
+	// ADBindErrorLogonTypeNotGranted indicates the user has not been granted the
+	// requested logon type
+	ADBindErrorLogonTypeNotGranted ADBindErrorData = "534"
+	// ADBindErrorAccountExpired indicates the account has expired
+	ADBindErrorAccountExpired ADBindErrorData = "701"
+	// ADBindErrorMustResetPassword indicates the user must reset their password
+	// before they can log on
+	ADBindErrorMustResetPassword ADBindErrorData = "773"
+	// ADBindErrorAccountLocked indicates the account is locked out
+	ADBindErrorAccountLocked ADBindErrorData = "775"
+)
+
+// ADBindErrorDataMap contains human readable descriptions of Active Directory
+// bind error sub-codes
+var ADBindErrorDataMap = map[ADBindErrorData]string{
+	ADBindErrorUserNotFound:        "User not found",
+	ADBindErrorInvalidCredentials:  "Invalid credentials",
+	ADBindErrorInvalidLogonHours:   "Not permitted to logon at this time",
+	ADBindErrorInvalidWorkstation:  "Not permitted to logon at this workstation",
+	ADBindErrorPasswordExpired:     "Password expired",
+	ADBindErrorAccountDisabled:     "Account disabled",
+	ADBindErrorLogonTypeNotGranted: "Logon type not granted",
+	ADBindErrorAccountExpired:      "Account expired",
+	ADBindErrorMustResetPassword:   "User must reset password",
+	ADBindErrorAccountLocked:       "Account locked out",
+}
+
+const (
+	// DefaultADBindErrorDSID is the DSID used in Active Directory bind error
+	// diagnostic messages
+	DefaultADBindErrorDSID = "DSID-0C0903A9"
+
+	// DefaultADBindErrorVersion is the version used in Active Directory bind
+	// error diagnostic messages
+	DefaultADBindErrorVersion = "v2580"
+)
+
+// ADBindDiagnostic returns an Active Directory style diagnostic message for a
+// failed bind with the sub-code. For example:
+//
+//	80090308: LdapErr: DSID-0C0903A9, comment: AcceptSecurityContext error, data 52e, v2580
+//
+// Supported options: WithADDiagnosticDSID, WithADDiagnosticVersion
+func ADBindDiagnostic(data ADBindErrorData, opt ...Option) string {
+	opts := getDiagnosticOpts(opt...)
+	return fmt.Sprintf("80090308: LdapErr: %s, comment: AcceptSecurityContext error, data %s, %s", opts.withDSID, data, opts.withVersion)
+}
@@ -0,0 +1,42 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+type diagnosticOptions struct {
+	withDSID    string
+	withVersion string
+}
+
+func diagnosticDefaults() diagnosticOptions {
+	return diagnosticOptions{
+		withDSID:    DefaultADBindErrorDSID,
+		withVersion: DefaultADBindErrorVersion,
+	}
+}
+
+func getDiagnosticOpts(opt ...Option) diagnosticOptions {
+	opts := diagnosticDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithADDiagnosticDSID sets the DSID used in an Active Directory style
+// diagnostic message (the default is DefaultADBindErrorDSID)
+func WithADDiagnosticDSID(dsid string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*diagnosticOptions); ok && dsid != "" {
+			o.withDSID = dsid
+		}
+	}
+}
+
+// WithADDiagnosticVersion sets the version used in an Active Directory style
+// diagnostic message (the default is DefaultADBindErrorVersion)
+func WithADDiagnosticVersion(version string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*diagnosticOptions); ok && version != "" {
+			o.withVersion = version
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestADBindDiagnostic(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data ADBindErrorData
+		opts []Option
+		want string
+	}{
+		{
+			name: "invalid-credentials",
+			data: ADBindErrorInvalidCredentials,
+			want: "80090308: LdapErr: DSID-0C0903A9, comment: AcceptSecurityContext error, data 52e, v2580",
+		},
+		{
+			name: "account-locked",
+			data: ADBindErrorAccountLocked,
+			want: "80090308: LdapErr: DSID-0C0903A9, comment: AcceptSecurityContext error, data 775, v2580",
+		},
+		{
+			name: "with-dsid-and-version",
+			data: ADBindErrorAccountDisabled,
+			opts: []Option{WithADDiagnosticDSID("DSID-0C09042A"), WithADDiagnosticVersion("v3839")},
+			want: "80090308: LdapErr: DSID-0C09042A, comment: AcceptSecurityContext error, data 533, v3839",
+		},
+		{
+			name: "empty-options-ignored",
+			data: ADBindErrorUserNotFound,
+			opts: []Option{WithADDiagnosticDSID(""), WithADDiagnosticVersion("")},
+			want: "80090308: LdapErr: DSID-0C0903A9, comment: AcceptSecurityContext error, data 525, v2580",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ADBindDiagnostic(tc.data, tc.opts...))
+		})
+	}
+}
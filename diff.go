@@ -0,0 +1,239 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangeError is the error returned by ApplyChanges when a change can't be
+// applied to an entry.
+type ChangeError struct {
+	// ResultCode is the result code of a modify response for the change (i.e.
+	// ResultNoSuchAttribute)
+	ResultCode int
+	// Change that couldn't be applied, which is empty when the modified entry
+	// violates a schema (see: ApplyModify)
+	Change Change
+	// Msg describes why the change couldn't be applied
+	Msg string
+	// MatchedDN is the DN of the closest existing ancestor of an entry that
+	// doesn't exist, which is only set when the ResultCode is
+	// ResultNoSuchObject (see: MatchedDN)
+	MatchedDN string
+}
+
+// Error returns a string representation of the error
+func (e *ChangeError) Error() string {
+	return fmt.Sprintf("%s: %s", ResultCodeMap[uint16(e.ResultCode)], e.Msg)
+}
+
+// DiffEntries returns the changes which modify the old entry's attributes into
+// the new entry's attributes.  Attribute names are compared case-insensitively
+// and values are compared exactly.  An attribute missing from the new entry is
+// deleted, while an attribute whose values changed has its removed values
+// deleted and its added values added.  A nil entry has no attributes and the
+// entries' DNs are not compared.
+func DiffEntries(oldEntry, newEntry *Entry) []Change {
+	var oldAttrs, newAttrs []*EntryAttribute
+	if oldEntry != nil {
+		oldAttrs = oldEntry.Attributes
+	}
+	if newEntry != nil {
+		newAttrs = newEntry.Attributes
+	}
+	var changes []Change
+	for _, o := range oldAttrs {
+		n := findAttribute(newAttrs, o.Name)
+		if n == nil {
+			changes = append(changes, Change{
+				Operation:    DeleteAttribute,
+				Modification: PartialAttribute{Type: o.Name},
+			})
+			continue
+		}
+		if removed := missingValues(OctetStringMatch, o.Values, n.Values); len(removed) > 0 {
+			changes = append(changes, Change{
+				Operation:    DeleteAttribute,
+				Modification: PartialAttribute{Type: o.Name, Vals: removed},
+			})
+		}
+		if added := missingValues(OctetStringMatch, n.Values, o.Values); len(added) > 0 {
+			changes = append(changes, Change{
+				Operation:    AddAttribute,
+				Modification: PartialAttribute{Type: o.Name, Vals: added},
+			})
+		}
+	}
+	for _, n := range newAttrs {
+		if findAttribute(oldAttrs, n.Name) == nil && len(n.Values) > 0 {
+			changes = append(changes, Change{
+				Operation:    AddAttribute,
+				Modification: PartialAttribute{Type: n.Name, Vals: append([]string(nil), n.Values...)},
+			})
+		}
+	}
+	return changes
+}
+
+// ApplyChanges applies the changes of a modify request to the entry using the
+// semantics of https://tools.ietf.org/html/rfc4511#section-4.6 and
+// https://tools.ietf.org/html/rfc4525 for increments.  Values are compared
+// using the attribute's matching rule (see: AttributeMatchingRule).  The
+// changes are applied atomically, so the entry is only modified when all the
+// changes can be applied.  A *ChangeError is returned when a change can't be
+// applied.
+func ApplyChanges(e *Entry, changes []Change) error {
+	const op = "gldap.ApplyChanges"
+	if e == nil {
+		return fmt.Errorf("%s: missing entry: %w", op, ErrInvalidParameter)
+	}
+	attrs, err := applyChanges(e.Attributes, changes, AttributeMatchingRule)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	e.Attributes = attrs
+	return nil
+}
+
+// applyChanges applies the changes to a copy of the attributes and returns the
+// resulting attributes.  The ruleFn returns the matching rule of an attribute.
+func applyChanges(attrs []*EntryAttribute, changes []Change, ruleFn func(name string) MatchingRule) ([]*EntryAttribute, error) {
+	applied := make([]*EntryAttribute, 0, len(attrs))
+	for _, a := range attrs {
+		applied = append(applied, NewEntryAttribute(a.Name, append([]string(nil), a.Values...)))
+	}
+	for _, ch := range changes {
+		var err error
+		if applied, err = applyChange(applied, ch, ruleFn(ch.Modification.Type)); err != nil {
+			return nil, err
+		}
+	}
+	return applied, nil
+}
+
+// applyChange applies a single change to the attributes and returns the
+// resulting attributes.
+func applyChange(attrs []*EntryAttribute, ch Change, rule MatchingRule) ([]*EntryAttribute, error) {
+	name := ch.Modification.Type
+	vals := ch.Modification.Vals
+	changeErr := func(code int, format string, a ...interface{}) error {
+		return &ChangeError{ResultCode: code, Change: ch, Msg: fmt.Sprintf(format, a...)}
+	}
+	if name == "" {
+		return nil, changeErr(ResultProtocolError, "missing attribute type")
+	}
+	a := findAttribute(attrs, name)
+	switch ch.Operation {
+	case AddAttribute:
+		if len(vals) == 0 {
+			return nil, changeErr(ResultProtocolError, "no values to add to %q", name)
+		}
+		if a == nil {
+			return append(attrs, NewEntryAttribute(name, append([]string(nil), vals...))), nil
+		}
+		for _, v := range vals {
+			if containsValue(rule, a.Values, v) {
+				return nil, changeErr(ResultAttributeOrValueExists, "value %q already exists in %q", v, name)
+			}
+		}
+		a.AddValue(vals...)
+		return attrs, nil
+	case DeleteAttribute:
+		if a == nil {
+			return nil, changeErr(ResultNoSuchAttribute, "no such attribute %q", name)
+		}
+		if len(vals) == 0 {
+			return removeAttribute(attrs, name), nil
+		}
+		for _, v := range vals {
+			if !containsValue(rule, a.Values, v) {
+				return nil, changeErr(ResultNoSuchAttribute, "no such value %q in %q", v, name)
+			}
+		}
+		remaining := missingValues(rule, a.Values, vals)
+		if len(remaining) == 0 {
+			return removeAttribute(attrs, name), nil
+		}
+		*a = *NewEntryAttribute(a.Name, remaining)
+		return attrs, nil
+	case ReplaceAttribute:
+		if len(vals) == 0 {
+			return removeAttribute(attrs, name), nil
+		}
+		if a == nil {
+			return append(attrs, NewEntryAttribute(name, append([]string(nil), vals...))), nil
+		}
+		*a = *NewEntryAttribute(a.Name, append([]string(nil), vals...))
+		return attrs, nil
+	case IncrementAttribute:
+		if len(vals) != 1 {
+			return nil, changeErr(ResultProtocolError, "increment of %q requires a single value", name)
+		}
+		delta, err := strconv.ParseInt(vals[0], 10, 64)
+		if err != nil {
+			return nil, changeErr(ResultProtocolError, "invalid increment %q of %q", vals[0], name)
+		}
+		if a == nil {
+			return nil, changeErr(ResultNoSuchAttribute, "no such attribute %q", name)
+		}
+		incremented := make([]string, 0, len(a.Values))
+		for _, v := range a.Values {
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, changeErr(ResultConstraintViolation, "value %q of %q is not an integer", v, name)
+			}
+			incremented = append(incremented, strconv.FormatInt(i+delta, 10))
+		}
+		*a = *NewEntryAttribute(a.Name, incremented)
+		return attrs, nil
+	default:
+		return nil, changeErr(ResultProtocolError, "invalid modify operation %d", ch.Operation)
+	}
+}
+
+// findAttribute returns the named attribute, which is matched
+// case-insensitively, or nil when it's not found.
+func findAttribute(attrs []*EntryAttribute, name string) *EntryAttribute {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a
+		}
+	}
+	return nil
+}
+
+// removeAttribute returns the attributes without the named attribute.
+func removeAttribute(attrs []*EntryAttribute, name string) []*EntryAttribute {
+	remaining := make([]*EntryAttribute, 0, len(attrs))
+	for _, a := range attrs {
+		if !strings.EqualFold(a.Name, name) {
+			remaining = append(remaining, a)
+		}
+	}
+	return remaining
+}
+
+// missingValues returns the values which aren't in the other values for the
+// matching rule.
+func missingValues(rule MatchingRule, values, other []string) []string {
+	var missing []string
+	for _, v := range values {
+		if !containsValue(rule, other, v) {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+func containsValue(rule MatchingRule, values []string, v string) bool {
+	for _, existing := range values {
+		if rule.Equal(existing, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffEntries(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		old, new *Entry
+		want     []Change
+	}{
+		{
+			name: "equal",
+			old:  NewEntry("cn=alice", map[string][]string{"cn": {"alice"}}),
+			new:  NewEntry("cn=alice", map[string][]string{"CN": {"alice"}}),
+		},
+		{
+			name: "nil-old",
+			new:  NewEntry("cn=alice", map[string][]string{"cn": {"alice"}}),
+			want: []Change{{Operation: AddAttribute, Modification: PartialAttribute{Type: "cn", Vals: []string{"alice"}}}},
+		},
+		{
+			name: "nil-new",
+			old:  NewEntry("cn=alice", map[string][]string{"cn": {"alice"}}),
+			want: []Change{{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "cn"}}},
+		},
+		{
+			name: "values",
+			old:  NewEntry("cn=alice", map[string][]string{"mail": {"alice@example.org", "a@example.org"}, "sn": {"smith"}}),
+			new:  NewEntry("cn=alice", map[string][]string{"mail": {"alice@example.org", "alice@example.com"}, "title": {"eng"}}),
+			want: []Change{
+				{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "mail", Vals: []string{"a@example.org"}}},
+				{Operation: AddAttribute, Modification: PartialAttribute{Type: "mail", Vals: []string{"alice@example.com"}}},
+				{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "sn"}},
+				{Operation: AddAttribute, Modification: PartialAttribute{Type: "title", Vals: []string{"eng"}}},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			got := DiffEntries(tc.old, tc.new)
+			assert.Equal(tc.want, got)
+
+			// applying the diff to the old entry results in the new entry
+			e := &Entry{}
+			if tc.old != nil {
+				e = NewEntry(tc.old.DN, nil)
+				for _, a := range tc.old.Attributes {
+					e.Attributes = append(e.Attributes, NewEntryAttribute(a.Name, a.Values))
+				}
+			}
+			require.NoError(ApplyChanges(e, got))
+			assert.Empty(DiffEntries(e, tc.new))
+		})
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	t.Parallel()
+	newEntry := func() *Entry {
+		return NewEntry("cn=alice", map[string][]string{
+			"cn":         {"alice"},
+			"mail":       {"alice@example.org", "a@example.org"},
+			"loginCount": {"1"},
+		})
+	}
+	change := func(op int64, name string, vals ...string) Change {
+		return Change{Operation: op, Modification: PartialAttribute{Type: name, Vals: vals}}
+	}
+	tests := []struct {
+		name     string
+		changes  []Change
+		want     map[string][]string
+		wantCode int
+	}{
+		{
+			name:    "add",
+			changes: []Change{change(AddAttribute, "MAIL", "alice@example.com"), change(AddAttribute, "sn", "smith")},
+			want: map[string][]string{
+				"cn":         {"alice"},
+				"mail":       {"alice@example.org", "a@example.org", "alice@example.com"},
+				"loginCount": {"1"},
+				"sn":         {"smith"},
+			},
+		},
+		{
+			name:     "add-existing",
+			changes:  []Change{change(AddAttribute, "mail", "a@example.org")},
+			wantCode: ResultAttributeOrValueExists,
+		},
+		{
+			name:    "delete",
+			changes: []Change{change(DeleteAttribute, "mail", "a@example.org"), change(DeleteAttribute, "loginCount")},
+			want:    map[string][]string{"cn": {"alice"}, "mail": {"alice@example.org"}},
+		},
+		{
+			name:     "delete-missing-value",
+			changes:  []Change{change(DeleteAttribute, "mail", "b@example.org")},
+			wantCode: ResultNoSuchAttribute,
+		},
+		{
+			name:     "delete-missing-attribute",
+			changes:  []Change{change(DeleteAttribute, "sn")},
+			wantCode: ResultNoSuchAttribute,
+		},
+		{
+			name:    "replace",
+			changes: []Change{change(ReplaceAttribute, "mail", "alice@example.com"), change(ReplaceAttribute, "loginCount"), change(ReplaceAttribute, "sn")},
+			want:    map[string][]string{"cn": {"alice"}, "mail": {"alice@example.com"}},
+		},
+		{
+			name:    "increment",
+			changes: []Change{change(IncrementAttribute, "loginCount", "2")},
+			want:    map[string][]string{"cn": {"alice"}, "mail": {"alice@example.org", "a@example.org"}, "loginCount": {"3"}},
+		},
+		{
+			name:     "increment-not-integer",
+			changes:  []Change{change(IncrementAttribute, "cn", "1")},
+			wantCode: ResultConstraintViolation,
+		},
+		{
+			name:     "invalid-operation",
+			changes:  []Change{change(99, "cn", "bob")},
+			wantCode: ResultProtocolError,
+		},
+		{
+			name:     "atomic",
+			changes:  []Change{change(ReplaceAttribute, "cn", "bob"), change(DeleteAttribute, "sn")},
+			wantCode: ResultNoSuchAttribute,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			e := newEntry()
+			err := ApplyChanges(e, tc.changes)
+			if tc.wantCode != 0 {
+				require.Error(err)
+				var changeErr *ChangeError
+				require.ErrorAs(err, &changeErr)
+				assert.Equal(tc.wantCode, changeErr.ResultCode)
+				assert.Equal(newEntry(), e)
+				return
+			}
+			require.NoError(err)
+			assert.Empty(DiffEntries(e, NewEntry("cn=alice", tc.want)))
+		})
+	}
+
+	assert.ErrorIs(t, ApplyChanges(nil, nil), ErrInvalidParameter)
+}
+
+func TestApplyChanges_matchingRule(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	e := NewEntry("cn=alice", map[string][]string{"mail": {"Alice@example.org"}, "userPassword": {"secret"}})
+	err := ApplyChanges(e, []Change{{Operation: AddAttribute, Modification: PartialAttribute{Type: "mail", Vals: []string{"alice@example.org"}}}})
+	var changeErr *ChangeError
+	assert.ErrorAs(err, &changeErr)
+	assert.Equal(ResultAttributeOrValueExists, changeErr.ResultCode)
+
+	assert.NoError(ApplyChanges(e, []Change{
+		{Operation: AddAttribute, Modification: PartialAttribute{Type: "userPassword", Vals: []string{"Secret"}}},
+		{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "mail", Vals: []string{"ALICE@example.org"}}},
+	}))
+	assert.Equal([]string{"secret", "Secret"}, e.GetAttributeValues("userPassword"))
+	assert.Empty(e.GetAttributeValues("mail"))
+}
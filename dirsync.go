@@ -0,0 +1,200 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// DirSyncFlag is a flag of an Active Directory DirSync request control (see:
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3).
+// Flags are bit flags and may be combined.
+type DirSyncFlag int64
+
+const (
+	// DirSyncObjectSecurity requests that only the objects and attributes the
+	// client has access to are returned
+	DirSyncObjectSecurity DirSyncFlag = 0x00000001
+
+	// DirSyncAncestorsFirstOrder requests that parents are returned before
+	// their children
+	DirSyncAncestorsFirstOrder DirSyncFlag = 0x00000800
+
+	// DirSyncPublicDataOnly requests that secret attributes (i.e. passwords)
+	// are not returned
+	DirSyncPublicDataOnly DirSyncFlag = 0x00002000
+
+	// DirSyncIncrementalValues requests that only the changed values of
+	// multi-valued attributes are returned
+	DirSyncIncrementalValues DirSyncFlag = 0x80000000
+)
+
+// ControlDirSyncRequest implements the Active Directory DirSync request
+// control (LDAP_SERVER_DIRSYNC_OID) described in
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
+type ControlDirSyncRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Flags of the request
+	Flags DirSyncFlag
+	// MaxBytes is the max number of bytes to return
+	MaxBytes int64
+	// Cookie is the client's current synchronization state, which is empty
+	// for the initial request
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlDirSyncRequest) GetControlType() string {
+	return ControlTypeMicrosoftDirSync
+}
+
+// Encode returns the ber packet representation
+func (c *ControlDirSyncRequest) Encode() *ber.Packet {
+	return encodeControlDirSync(c.Criticality, int64(c.Flags), c.MaxBytes, c.Cookie)
+}
+
+// String returns a human-readable description
+func (c *ControlDirSyncRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Flags: %#x  MaxBytes: %d  Cookie: %q",
+		ControlTypeMap[ControlTypeMicrosoftDirSync],
+		ControlTypeMicrosoftDirSync,
+		c.Criticality,
+		int64(c.Flags),
+		c.MaxBytes,
+		string(c.Cookie))
+}
+
+// NewControlDirSyncRequest returns a DirSync request control.  Supported
+// options: WithCriticality, WithMaxBytes and WithCookie
+func NewControlDirSyncRequest(flags DirSyncFlag, opt ...Option) (*ControlDirSyncRequest, error) {
+	const op = "gldap.NewControlDirSyncRequest"
+	opts := getControlOpts(opt...)
+	if opts.withMaxBytes < 0 {
+		return nil, fmt.Errorf("%s: invalid max bytes %d: %w", op, opts.withMaxBytes, ErrInvalidParameter)
+	}
+	return &ControlDirSyncRequest{
+		Criticality: opts.withCriticality,
+		Flags:       flags,
+		MaxBytes:    opts.withMaxBytes,
+		Cookie:      opts.withCookie,
+	}, nil
+}
+
+// ControlDirSyncResponse implements the Active Directory DirSync response
+// control, which is sent with the search result done response.
+type ControlDirSyncResponse struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// MoreResults indicates there are more changes for the client to request
+	MoreResults bool
+	// Cookie is the updated synchronization state the client sends with its
+	// next request
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlDirSyncResponse) GetControlType() string {
+	return ControlTypeMicrosoftDirSync
+}
+
+// Encode returns the ber packet representation
+func (c *ControlDirSyncResponse) Encode() *ber.Packet {
+	var moreResults int64
+	if c.MoreResults {
+		moreResults = 1
+	}
+	return encodeControlDirSync(c.Criticality, moreResults, 0, c.Cookie)
+}
+
+// String returns a human-readable description
+func (c *ControlDirSyncResponse) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  MoreResults: %t  Cookie: %q",
+		ControlTypeMap[ControlTypeMicrosoftDirSync],
+		ControlTypeMicrosoftDirSync,
+		c.Criticality,
+		c.MoreResults,
+		string(c.Cookie))
+}
+
+// NewControlDirSyncResponse returns a DirSync response control.  Supported
+// options: WithCriticality, WithMoreResults and WithCookie
+func NewControlDirSyncResponse(opt ...Option) (*ControlDirSyncResponse, error) {
+	opts := getControlOpts(opt...)
+	return &ControlDirSyncResponse{
+		Criticality: opts.withCriticality,
+		MoreResults: opts.withMoreResults,
+		Cookie:      opts.withCookie,
+	}, nil
+}
+
+// encodeControlDirSync encodes a DirSync control, since the request and
+// response share the same OID and value layout: SEQUENCE { INTEGER, INTEGER,
+// OCTET STRING }
+func encodeControlDirSync(criticality bool, first, second int64, cookie []byte) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftDirSync, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftDirSync]+")"))
+	if criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "DirSync Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, first, "Flags"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, second, "Max Bytes"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(cookie), "Cookie"))
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (DirSync)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// decodeControlDirSyncRequest decodes a DirSync request control.  Responses
+// aren't decoded, since they share the same OID and layout and a server only
+// receives requests.
+func decodeControlDirSyncRequest(value *ber.Packet, criticality bool) (*ControlDirSyncRequest, error) {
+	const op = "gldap.decodeControlDirSyncRequest"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) != 3 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in dirsync control: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	flags, ok := seq.Children[0].Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid flags: %w", op, ErrInvalidParameter)
+	}
+	maxBytes, ok := seq.Children[1].Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid max bytes: %w", op, ErrInvalidParameter)
+	}
+	opts := []Option{WithCriticality(criticality), WithMaxBytes(maxBytes)}
+	if cookie := seq.Children[2].Data.Bytes(); len(cookie) > 0 {
+		opts = append(opts, WithCookie(cookie))
+	}
+	// AD clients encode the flags as a 32 bit integer, so the incremental
+	// values flag is decoded as a negative number.
+	c, err := NewControlDirSyncRequest(DirSyncFlag(uint32(flags)), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+// GetDirSyncControl returns the request's DirSync request control and false
+// if the request doesn't have one.
+func (r *Request) GetDirSyncControl() (*ControlDirSyncRequest, bool) {
+	c, ok := r.findControl(ControlTypeMicrosoftDirSync).(*ControlDirSyncRequest)
+	return c, ok
+}
+
+// ShowDeleted returns true if the request has the Active Directory show
+// deleted control (LDAP_SERVER_SHOW_DELETED_OID), which asks the server to
+// return deleted objects (tombstones).
+func (r *Request) ShowDeleted() bool {
+	return r.findControl(ControlTypeMicrosoftShowDeleted) != nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlDirSyncRequest(t *testing.T) {
+	runControlTest(t,
+		testControlDirSyncRequest(t, DirSyncObjectSecurity|DirSyncIncrementalValues, WithCriticality(true), WithMaxBytes(1048576), WithCookie([]byte("cookie"))),
+		withTestType(ControlTypeMicrosoftDirSync),
+		withTestToString("Control Type: DirSync - Microsoft (\"1.2.840.113556.1.4.841\")  Criticality: true  Flags: 0x80000001  MaxBytes: 1048576  Cookie: \"cookie\""),
+	)
+	runControlTest(t, testControlDirSyncRequest(t, 0))
+
+	_, err := NewControlDirSyncRequest(0, WithMaxBytes(-1))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestControlDirSyncRequest_negativeFlags(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	// AD clients encode the flags as a 32 bit signed integer
+	p := testControlDirSyncRequest(t, 0).Encode()
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "DirSync Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(int32(-2147483647)), "Flags"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(0), "Max Bytes"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Cookie"))
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (DirSync)")
+	value.AppendChild(seq)
+	p.Children[1] = value
+
+	c, err := decodeControl(p)
+	require.NoError(err)
+	dirSync, ok := c.(*ControlDirSyncRequest)
+	require.True(ok)
+	assert.Equal(DirSyncObjectSecurity|DirSyncIncrementalValues, dirSync.Flags)
+}
+
+func TestControlDirSyncResponse(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	c, err := NewControlDirSyncResponse(WithMoreResults(true), WithCookie([]byte("cookie")))
+	require.NoError(err)
+	assert.Equal(ControlTypeMicrosoftDirSync, c.GetControlType())
+	assert.Equal("Control Type: DirSync - Microsoft (\"1.2.840.113556.1.4.841\")  Criticality: false  MoreResults: true  Cookie: \"cookie\"", c.String())
+
+	// a response can be decoded by a client
+	p, err := ber.DecodePacketErr(c.Encode().Bytes())
+	require.NoError(err)
+	got, err := ldap.DecodeControl(p)
+	require.NoError(err)
+	dirSync, ok := got.(*ldap.ControlDirSync)
+	require.True(ok)
+	assert.Equal(int64(1), dirSync.Flags)
+	assert.Equal([]byte("cookie"), dirSync.Cookie)
+}
+
+func TestRequest_ShowDeleted(t *testing.T) {
+	assert := assert.New(t)
+	r := &Request{message: &SearchMessage{Controls: []Control{testControlMicrosoftShowDeleted(t)}}}
+	assert.True(r.ShowDeleted())
+	r = &Request{message: &SearchMessage{}}
+	assert.False(r.ShowDeleted())
+}
+
+func TestRequest_GetDirSyncControl(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	requestCh := make(chan *ControlDirSyncRequest, 1)
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		c, ok := r.GetDirSyncControl()
+		if !ok {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultUnwillingToPerform)))
+			return
+		}
+		requestCh <- c
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,ou=people,dc=example,dc=org", WithAttributes(map[string][]string{"cn": {"alice"}})))
+		done := r.NewSearchDoneResponse(WithResponseCode(ResultSuccess))
+		resp, err := NewControlDirSyncResponse(WithCookie([]byte("usn=2")))
+		if err != nil {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		done.SetControls(resp)
+		_ = w.Write(done)
+	}))
+	_, url := testServer(t, mux)
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	req := ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.DerefAlways, 0, 0, false, "(objectClass=*)", nil, nil)
+	res, err := client.DirSync(req, ldap.DirSyncObjectSecurity, 1000, []byte("usn=1"))
+	require.NoError(err)
+
+	gotReq := <-requestCh
+	assert.True(gotReq.Criticality)
+	assert.Equal(DirSyncObjectSecurity, gotReq.Flags)
+	assert.Equal(int64(1000), gotReq.MaxBytes)
+	assert.Equal([]byte("usn=1"), gotReq.Cookie)
+
+	require.Len(res.Entries, 1)
+	ctrl := ldap.FindControl(res.Controls, ldap.ControlTypeDirSync)
+	require.NotNil(ctrl)
+	assert.Equal([]byte("usn=2"), ctrl.(*ldap.ControlDirSync).Cookie)
+}
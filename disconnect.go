@@ -0,0 +1,115 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"time"
+)
+
+// noticeOfDisconnection returns the unsolicited notice of disconnection
+// response (see: https://tools.ietf.org/html/rfc4511#section-4.4.1)
+func noticeOfDisconnection(code int, diagMsg string) *ExtendedResponse {
+	return &ExtendedResponse{
+		baseResponse: &baseResponse{
+			messageID:   0, // unsolicited notifications always have a message ID of zero
+			code:        int16(code),
+			diagMessage: diagMsg,
+		},
+		name: ExtendedOperationDisconnection,
+	}
+}
+
+// disconnect sends the conn a notice of disconnection and stops it from
+// serving any more requests.  It's a no-op when the conn has already been
+// disconnected.
+func (c *conn) disconnect(code int, diagMsg string) (retErr error) {
+	const op = "gldap.(Conn).disconnect"
+	c.disconnectMu.Lock()
+	if c.disconnected {
+		c.disconnectMu.Unlock()
+		return nil
+	}
+	c.disconnected = true
+	c.disconnectMu.Unlock()
+
+	// unblock the conn's pending read, so it stops serving requests, once the
+	// notice has been written or has failed to be written
+	defer func() {
+		if err := c.netConn.SetReadDeadline(time.Now()); err != nil && retErr == nil {
+			retErr = fmt.Errorf("%s: %w", op, err)
+		}
+	}()
+
+	c.writerMu.Lock()
+	defer c.writerMu.Unlock()
+	if err := c.setWriteDeadline(); err != nil {
+		return fmt.Errorf("%s: unable to set write deadline: %w", op, err)
+	}
+	notice := noticeOfDisconnection(code, diagMsg)
+	applyDiagnosticHook(c.diagnosticHook, nil, notice)
+	if _, err := c.writer.Write(notice.packet().Bytes()); err != nil {
+		return fmt.Errorf("%s: unable to write notice of disconnection: %w", op, err)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("%s: unable to flush notice of disconnection: %w", op, err)
+	}
+	c.logger.Debug("sent notice of disconnection", "op", op, "conn", c.connID, "code", code)
+	return nil
+}
+
+// isDisconnected returns true if the conn has been sent a notice of
+// disconnection.
+func (c *conn) isDisconnected() bool {
+	c.disconnectMu.Lock()
+	defer c.disconnectMu.Unlock()
+	return c.disconnected
+}
+
+// NoticeOfDisconnection sends the connection an unsolicited notice of
+// disconnection (see: https://tools.ietf.org/html/rfc4511#section-4.4.1) and
+// then closes it, so the client gets a protocol-level signal rather than just
+// a closed connection.  The notice's result code defaults to
+// ResultUnavailable, and it doesn't have a diagnostic message by default.
+// Notices are sent to all the connections when the server is stopped.
+//
+// Options supported: WithResponseCode, WithDiagnosticMessage
+func (s *Server) NoticeOfDisconnection(connectionID int, opt ...ResponseOption) error {
+	const op = "gldap.(Server).NoticeOfDisconnection"
+	if err := unsupportedOptions(op, "a response", &responseOptions{}, opt...); err != nil {
+		return err
+	}
+	// the notice doesn't have a diagnostic message unless one is provided
+	opts := getResponseOpts(append([]ResponseOption{WithDiagnosticMessage("")}, opt...)...)
+	code := ResultUnavailable
+	if opts.withResponseCode != nil {
+		code = *opts.withResponseCode
+	}
+	s.connsMu.Lock()
+	c, ok := s.conns[connectionID]
+	s.connsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("%s: unknown connection %d: %w", op, connectionID, ErrInvalidParameter)
+	}
+	if err := c.disconnect(code, opts.withDiagnosticMessage); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// disconnectAll sends all the server's connections a notice of disconnection
+func (s *Server) disconnectAll(code int, diagMsg string) {
+	const op = "gldap.(Server).disconnectAll"
+	s.connsMu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for _, c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.connsMu.Unlock()
+	for _, c := range conns {
+		if err := c.disconnect(code, diagMsg); err != nil {
+			s.logger.Debug("unable to disconnect", "op", op, "conn", c.connID, "err", err)
+		}
+	}
+}
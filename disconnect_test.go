@@ -0,0 +1,94 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_NoticeOfDisconnection(t *testing.T) {
+	t.Parallel()
+
+	startServer := func(t *testing.T) (*Server, net.Conn) {
+		t.Helper()
+		require := require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}))
+		s, _ := testServer(t, mux)
+		c, err := net.Dial("tcp", s.Addr().String())
+		require.NoError(err)
+		t.Cleanup(func() { _ = c.Close() })
+		// wait for the server to accept the conn
+		_, err = c.Write(testSimpleBindRequestPacket(t, SimpleBindMessage{baseMessage: baseMessage{id: 1}, UserName: "alice", Password: "password"}).Bytes())
+		require.NoError(err)
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		_, err = ber.ReadPacket(c)
+		require.NoError(err)
+		return s, c
+	}
+	readNotice := func(t *testing.T, c net.Conn) (int64, string) {
+		t.Helper()
+		assert, require := assert.New(t), require.New(t)
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.Len(p.Children, 2)
+		assert.Equal(int64(0), p.Children[0].Value.(int64))
+		res := p.Children[1]
+		assert.Equal(ber.Tag(ApplicationExtendedResponse), res.Tag)
+		require.NotEmpty(res.Children)
+		var name string
+		for _, child := range res.Children {
+			if child.ClassType == ber.ClassContext && child.Tag == 10 {
+				name = child.Data.String()
+			}
+		}
+		assert.Equal(string(ExtendedOperationDisconnection), name)
+
+		// the server closes the conn after the notice
+		_, err = ber.ReadPacket(c)
+		assert.ErrorIs(err, io.EOF)
+		return res.Children[0].Value.(int64), res.Children[2].Value.(string)
+	}
+
+	t.Run("connection", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		s, c := startServer(t)
+		assert.ErrorIs(s.NoticeOfDisconnection(99), ErrInvalidParameter)
+		require.NoError(s.NoticeOfDisconnection(1, WithResponseCode(ResultStrongAuthRequired), WithDiagnosticMessage("tls required")))
+		code, msg := readNotice(t, c)
+		assert.Equal(int64(ResultStrongAuthRequired), code)
+		assert.Equal("tls required", msg)
+		// a conn is disconnected once
+		require.Eventually(func() bool {
+			return s.NoticeOfDisconnection(1) != nil
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+	t.Run("defaults", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		s, c := startServer(t)
+		require.NoError(s.NoticeOfDisconnection(1))
+		code, msg := readNotice(t, c)
+		assert.Equal(int64(ResultUnavailable), code)
+		assert.Equal("", msg)
+	})
+	t.Run("stop", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		s, c := startServer(t)
+		require.NoError(s.Stop())
+		code, msg := readNotice(t, c)
+		assert.Equal(int64(ResultUnavailable), code)
+		assert.Equal("server stopping", msg)
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// NormalizeDN returns the dn's normalized form, so DNs which are equal (i.e.
+// "CN=Alice, OU=People,DC=Example,DC=Org" and "cn=alice,ou=people,dc=example,dc=org")
+// have the same normalized form, which can be used to index entries by DN
+// while their DNs are stored as-is.  Attribute types are compared
+// case-insensitively, the values of a multi-valued RDN in any order, and each
+// value with the matching rule of its attribute type, which is the schema's
+// when a schema is provided (see: Schema.MatchingRule) or
+// AttributeMatchingRule(...) otherwise.  The null DN ("") is normalized to
+// itself.
+func NormalizeDN(dn string, schema *Schema) (string, error) {
+	const op = "gldap.NormalizeDN"
+	if strings.TrimSpace(dn) == "" {
+		return "", nil
+	}
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid dn %q: %s: %w", op, dn, err, ErrInvalidParameter)
+	}
+	ruleFn := AttributeMatchingRule
+	if schema != nil {
+		ruleFn = schema.MatchingRule
+	}
+	rdns := make([]string, 0, len(parsed.RDNs))
+	for _, rdn := range parsed.RDNs {
+		avas := make([]string, 0, len(rdn.Attributes))
+		for _, a := range rdn.Attributes {
+			t := strings.ToLower(a.Type)
+			avas = append(avas, t+"="+escapeDNValue(ruleFn(t).Normalize(a.Value)))
+		}
+		sort.Strings(avas)
+		rdns = append(rdns, strings.Join(avas, "+"))
+	}
+	return strings.Join(rdns, ","), nil
+}
+
+// escapeDNValue escapes the characters of an attribute value which are special
+// in a DN's string representation (see:
+// https://tools.ietf.org/html/rfc4514#section-2.4)
+func escapeDNValue(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case strings.IndexByte(`"+,;<>\=`, c) >= 0,
+			c == '#' && i == 0,
+			c == ' ' && (i == 0 || i == len(v)-1):
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
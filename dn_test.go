@@ -0,0 +1,48 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDN(t *testing.T) {
+	t.Parallel()
+	schema := NewSchema()
+	require.NoError(t, schema.AddAttributeType(AttributeType{Name: "uid", Equality: CaseExactMatch}))
+	tests := []struct {
+		name      string
+		dn        string
+		schema    *Schema
+		want      string
+		wantErrIs error
+	}{
+		{name: "null-dn", dn: "", want: ""},
+		{name: "invalid", dn: "not a dn", wantErrIs: ErrInvalidParameter},
+		{name: "lower", dn: "cn=alice,ou=people,dc=example,dc=org", want: "cn=alice,ou=people,dc=example,dc=org"},
+		{name: "case-and-spaces", dn: "CN=Alice  Smith, OU=People,DC=Example,DC=Org", want: "cn=alice smith,ou=people,dc=example,dc=org"},
+		{name: "multi-valued-rdn", dn: "uid=alice+CN=Alice,dc=org", want: "cn=alice+uid=alice,dc=org"},
+		{name: "escaped", dn: `cn=Smith\, Alice,dc=org`, want: `cn=smith\, alice,dc=org`},
+		{name: "hex-escaped", dn: `cn=Alice\2bBob,dc=org`, want: `cn=alice\+bob,dc=org`},
+		{name: "schema-case-exact", dn: "UID=Alice,DC=Org", schema: schema, want: "uid=Alice,dc=org"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			got, err := NormalizeDN(tc.dn, tc.schema)
+			if tc.wantErrIs != nil {
+				require.Error(err)
+				assert.ErrorIs(err, tc.wantErrIs)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
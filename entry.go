@@ -96,6 +96,29 @@ func NewEntryAttribute(name string, values []string) *EntryAttribute {
 	}
 }
 
+// clone returns a deep copy of the entry, whose attributes can be modified
+// without modifying the entry's (see: SearchResponseEntry.RemoveAttribute)
+func (e *Entry) clone() Entry {
+	cp := Entry{DN: e.DN}
+	if e.Attributes != nil {
+		cp.Attributes = make([]*EntryAttribute, 0, len(e.Attributes))
+	}
+	for _, a := range e.Attributes {
+		ca := &EntryAttribute{Name: a.Name}
+		if a.Values != nil {
+			ca.Values = append([]string{}, a.Values...)
+		}
+		if a.ByteValues != nil {
+			ca.ByteValues = make([][]byte, 0, len(a.ByteValues))
+			for _, v := range a.ByteValues {
+				ca.ByteValues = append(ca.ByteValues, append([]byte{}, v...))
+			}
+		}
+		cp.Attributes = append(cp.Attributes, ca)
+	}
+	return cp
+}
+
 // AddValue to an existing EntryAttribute
 func (e *EntryAttribute) AddValue(value ...string) {
 	for _, v := range value {
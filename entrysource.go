@@ -0,0 +1,52 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"io"
+)
+
+// EntrySource is an iterator of entries, which lets a handler stream the
+// entries of a lazy backend (i.e. the rows of a database query) into a search
+// response without materializing them (see: ResponseWriter.WriteSearchSource).
+type EntrySource interface {
+	// Next returns the next entry, or io.EOF when there aren't any more
+	// entries.  The ctx is done when the request is abandoned or the
+	// connection is closed.
+	Next(ctx context.Context) (*Entry, error)
+}
+
+// EntrySourceFunc is an adapter which allows a function to be used as an
+// EntrySource.
+type EntrySourceFunc func(ctx context.Context) (*Entry, error)
+
+// Next returns the next entry by calling the function.
+func (fn EntrySourceFunc) Next(ctx context.Context) (*Entry, error) {
+	return fn(ctx)
+}
+
+// NewEntrySliceSource creates an EntrySource which yields the entries in
+// order.
+func NewEntrySliceSource(entries ...*Entry) EntrySource {
+	return &sliceSource{entries: entries}
+}
+
+type sliceSource struct {
+	entries []*Entry
+	next    int
+}
+
+// Next returns the next entry of the slice.
+func (s *sliceSource) Next(ctx context.Context) (*Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.next >= len(s.entries) {
+		return nil, io.EOF
+	}
+	e := s.entries[s.next]
+	s.next++
+	return e, nil
+}
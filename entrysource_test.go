@@ -0,0 +1,32 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEntrySliceSource(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	alice, bob := NewEntry("cn=alice", nil), NewEntry("cn=bob", nil)
+	src := NewEntrySliceSource(alice, bob)
+	for _, want := range []*Entry{alice, bob} {
+		e, err := src.Next(context.Background())
+		require.NoError(err)
+		assert.Same(want, e)
+	}
+	_, err := src.Next(context.Background())
+	assert.ErrorIs(err, io.EOF)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewEntrySliceSource(alice).Next(ctx)
+	assert.ErrorIs(err, context.Canceled)
+}
@@ -17,4 +17,11 @@ var (
 
 	// ErrInternal is an internal error
 	ErrInternal = errors.New("internal error")
+
+	// ErrNotFound is a not found error
+	ErrNotFound = errors.New("not found")
+
+	// ErrConnectionRejected is a connection rejected by a connection policy
+	// error (see: ConnectionPolicy)
+	ErrConnectionRejected = errors.New("connection rejected")
 )
@@ -0,0 +1,148 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"net"
+	"time"
+)
+
+// EventType is the type of an Event
+type EventType string
+
+// Event types
+const (
+	// EventConnectionOpened is sent when a connection is accepted, once it's
+	// been accepted by the server's on connect handler (see: WithOnConnect)
+	EventConnectionOpened EventType = "connectionOpened"
+	// EventConnectionClosed is sent when a connection that was opened is
+	// closed
+	EventConnectionClosed EventType = "connectionClosed"
+	// EventBindSucceeded is sent when a bind request is successful
+	EventBindSucceeded EventType = "bindSucceeded"
+	// EventBindFailed is sent when a bind request fails
+	EventBindFailed EventType = "bindFailed"
+	// EventEntryModified is sent when an add, modify, modify DN, delete or
+	// password modify request is successful
+	EventEntryModified EventType = "entryModified"
+)
+
+// Event is an event sent to a server's event sink (see: WithEventSink)
+type Event struct {
+	// Type of the event
+	Type EventType
+	// Time of the event
+	Time time.Time
+	// ConnectionID of the event's connection
+	ConnectionID int
+	// RemoteAddr is the network address of the connection's client
+	RemoteAddr net.Addr
+	// Operation of the event's request, which is empty for a connection event
+	Operation RouteOperation
+	// DN that the event's request targets (i.e. the DN of a bind or of the
+	// modified entry), which is empty for a connection event
+	DN string
+	// ResultCode of the event's request
+	ResultCode int
+}
+
+// EventSink receives a server's events (see: WithEventSink)
+type EventSink func(Event)
+
+// WithEventSink sets a sink which receives the server's connection, bind and
+// modification events, so applications that embed the server can trigger side
+// effects (i.e. invalidate a cache or send notifications) without wrapping
+// every route with middleware.  Request events are sent before their final
+// response is written, so they're received before the client gets the
+// response.  The sink is called synchronously, so it must be safe for
+// concurrent use and it shouldn't block.
+func WithEventSink(sink EventSink) Option {
+	return serverOption(func(o *configOptions) {
+		o.withEventSink = sink
+	})
+}
+
+// sendConnEvent sends a connection event to the conn's event sink
+func (c *conn) sendConnEvent(t EventType) {
+	if c.eventSink == nil {
+		return
+	}
+	c.eventSink(Event{
+		Type:         t,
+		Time:         c.clock.Now(),
+		ConnectionID: c.connID,
+		RemoteAddr:   c.remoteAddr,
+	})
+}
+
+// requestEventKind is the kind of event of a request's final response, which is
+// shared by the events (see: WithEventSink) and the audit events (see:
+// WithAuditor)
+type requestEventKind int
+
+const (
+	bindSucceededKind requestEventKind = iota + 1
+	bindFailedKind
+	searchPerformedKind
+	entryModifiedKind
+)
+
+// requestEvent classifies the response, and returns the kind of its event and
+// the response when it's the final response to a bind, a search or an update
+// request.  It returns false when the response isn't an event.
+func requestEvent(req *Request, r Response) (requestEventKind, diagnosticResponse, bool) {
+	if req == nil || !isFinalResponse(r) {
+		return 0, nil, false
+	}
+	resp, ok := r.(diagnosticResponse)
+	if !ok {
+		return 0, nil, false
+	}
+	switch {
+	case req.routeOp == BindRouteOperation && resp.resultCode() == ResultSuccess:
+		return bindSucceededKind, resp, true
+	case req.routeOp == BindRouteOperation:
+		return bindFailedKind, resp, true
+	case req.routeOp == SearchRouteOperation:
+		return searchPerformedKind, resp, true
+	case isUpdateRequest(req) && resp.resultCode() == ResultSuccess:
+		return entryModifiedKind, resp, true
+	default:
+		return 0, nil, false
+	}
+}
+
+// sendEvent sends the event of the request to the conn's event sink when the
+// response is the final response to a bind or an update request.
+func (rw *ResponseWriter) sendEvent(r Response) {
+	if rw.request == nil || rw.request.conn == nil || rw.request.conn.eventSink == nil {
+		return
+	}
+	kind, resp, ok := requestEvent(rw.request, r)
+	if !ok {
+		return
+	}
+	var t EventType
+	switch kind {
+	case bindSucceededKind:
+		t = EventBindSucceeded
+	case bindFailedKind:
+		t = EventBindFailed
+	case entryModifiedKind:
+		t = EventEntryModified
+	default:
+		return
+	}
+	dn, _ := rw.request.targetDN()
+	c := rw.request.conn
+	c.eventSink(Event{
+		Type:         t,
+		Time:         c.clock.Now(),
+		ConnectionID: c.connID,
+		RemoteAddr:   c.remoteAddr,
+		Operation:    rw.request.routeOp,
+		DN:           dn,
+		ResultCode:   resp.resultCode(),
+	})
+}
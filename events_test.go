@@ -0,0 +1,123 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WithEventSink(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var events []Event
+	sink := func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+	sent := func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Event{}, events...)
+	}
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetSimpleBindMessage()
+		if err != nil || string(m.Password) != "password" {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultInvalidCredentials)))
+			return
+		}
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Add(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationAddResponse), WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultNoSuchObject)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, url := testServer(t, mux, WithEventSink(sink), WithClock(NewTestClock(t, now)))
+
+	assert, require := assert.New(t), require.New(t)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	require.NoError(client.Bind("cn=alice", "password"))
+	assert.Error(client.Bind("cn=alice", "bad"))
+	require.NoError(client.Add(ldap.NewAddRequest("uid=bob,ou=people,dc=example,dc=org", nil)))
+	// failed updates and searches don't send events
+	assert.Error(client.Del(ldap.NewDelRequest("uid=eve,ou=people,dc=example,dc=org", nil)))
+	_, err = client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	client.Close()
+	assert.Eventually(func() bool { return len(sent()) == 5 }, time.Second, 10*time.Millisecond)
+
+	got := sent()
+	require.Len(got, 5)
+	connID := got[0].ConnectionID
+	assert.NotZero(connID)
+	for _, e := range got {
+		assert.Equal(connID, e.ConnectionID)
+		assert.NotNil(e.RemoteAddr)
+		// the events are timed by the server's clock
+		assert.Equal(now, e.Time)
+	}
+	assert.Equal(EventConnectionOpened, got[0].Type)
+	assert.Equal(EventBindSucceeded, got[1].Type)
+	assert.Equal("cn=alice", got[1].DN)
+	assert.Equal(BindRouteOperation, got[1].Operation)
+	assert.Equal(EventBindFailed, got[2].Type)
+	assert.Equal(ResultInvalidCredentials, got[2].ResultCode)
+	assert.Equal(EventEntryModified, got[3].Type)
+	assert.Equal(AddRouteOperation, got[3].Operation)
+	assert.Equal("uid=bob,ou=people,dc=example,dc=org", got[3].DN)
+	assert.Equal(EventConnectionClosed, got[4].Type)
+}
+
+func Test_requestEvent(t *testing.T) {
+	t.Parallel()
+	req := func(op RouteOperation) *Request {
+		return &Request{routeOp: op, message: &SimpleBindMessage{baseMessage: baseMessage{id: 1}}}
+	}
+	resp := func(code int) Response {
+		return req(BindRouteOperation).NewResponse(WithResponseCode(code))
+	}
+	tests := []struct {
+		name     string
+		req      *Request
+		resp     Response
+		wantKind requestEventKind
+		wantOk   bool
+	}{
+		{name: "missing-request", resp: resp(ResultSuccess)},
+		{name: "bind-succeeded", req: req(BindRouteOperation), resp: resp(ResultSuccess), wantKind: bindSucceededKind, wantOk: true},
+		{name: "bind-failed", req: req(BindRouteOperation), resp: resp(ResultInvalidCredentials), wantKind: bindFailedKind, wantOk: true},
+		{name: "search-performed", req: req(SearchRouteOperation), resp: resp(ResultNoSuchObject), wantKind: searchPerformedKind, wantOk: true},
+		{name: "search-entry", req: req(SearchRouteOperation), resp: req(SearchRouteOperation).NewSearchResponseEntry("cn=alice")},
+		{name: "entry-modified", req: req(ModifyRouteOperation), resp: resp(ResultSuccess), wantKind: entryModifiedKind, wantOk: true},
+		{name: "modify-failed", req: req(ModifyRouteOperation), resp: resp(ResultNoSuchObject)},
+		{name: "extended", req: req(ExtendedRouteOperation), resp: resp(ResultSuccess)},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			kind, r, ok := requestEvent(tc.req, tc.resp)
+			assert.Equal(tc.wantOk, ok)
+			assert.Equal(tc.wantKind, kind)
+			if tc.wantOk {
+				assert.Equal(tc.resp, r)
+			}
+		})
+	}
+}
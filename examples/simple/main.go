@@ -12,6 +12,7 @@ import (
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/hclogger"
 )
 
 func main() {
@@ -25,7 +26,7 @@ func main() {
 	authenticatedConnections := map[int]struct{}{}
 
 	// create a new server
-	s, err := gldap.NewServer(gldap.WithLogger(l), gldap.WithDisablePanicRecovery())
+	s, err := gldap.NewServer(gldap.WithLogger(hclogger.New(l)), gldap.WithDisablePanicRecovery())
 	if err != nil {
 		log.Fatalf("unable to create server: %s", err.Error())
 	}
@@ -0,0 +1,248 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// MatchFilter returns true if the entry matches the ldap filter (see:
+// https://datatracker.ietf.org/doc/html/rfc4515).  Attribute descriptions are
+// matched case-insensitively and values are compared using case-insensitive
+// string matching (ordering matches will compare integer values when both
+// values are integers).  Extensible matches only support equality matching on
+// a specified attribute type.
+func (e *Entry) MatchFilter(filter string) (bool, error) {
+	const op = "gldap.(Entry).MatchFilter"
+	if filter == "" {
+		return false, fmt.Errorf("%s: missing filter: %w", op, ErrInvalidParameter)
+	}
+	f, err := ldap.CompileFilter(filter)
+	if err != nil {
+		return false, fmt.Errorf("%s: unable to compile filter: %w", op, err)
+	}
+	ok, err := matchFilterPacket(f, e)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return ok, nil
+}
+
+// matchFilterPacket will evaluate the ber encoded filter against the entry.
+func matchFilterPacket(f *ber.Packet, e *Entry) (bool, error) {
+	const (
+		op = "gldap.matchFilterPacket"
+
+		childAttribute = 0
+		childValue     = 1
+	)
+	if f == nil {
+		return false, fmt.Errorf("%s: missing filter packet: %w", op, ErrInvalidParameter)
+	}
+	if e == nil {
+		return false, fmt.Errorf("%s: missing entry: %w", op, ErrInvalidParameter)
+	}
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			ok, err := matchFilterPacket(c, e)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			ok, err := matchFilterPacket(c, e)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterNot:
+		if len(f.Children) != 1 {
+			return false, fmt.Errorf("%s: not filter must have exactly one child: %w", op, ErrInvalidParameter)
+		}
+		ok, err := matchFilterPacket(f.Children[0], e)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	case ldap.FilterPresent:
+		return len(e.attributeValues(f.Data.String())) > 0, nil
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		if len(f.Children) != 2 {
+			return false, fmt.Errorf("%s: %s filter must have exactly two children: %w", op, ldap.FilterMap[uint64(f.Tag)], ErrInvalidParameter)
+		}
+		attr := f.Children[childAttribute].Data.String()
+		assertion := f.Children[childValue].Data.String()
+		for _, v := range e.attributeValues(attr) {
+			var matched bool
+			switch f.Tag {
+			case ldap.FilterGreaterOrEqual:
+				matched = compareValues(v, assertion) >= 0
+			case ldap.FilterLessOrEqual:
+				matched = compareValues(v, assertion) <= 0
+			default:
+				matched = AttributeMatchingRule(attr).Equal(v, assertion)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterSubstrings:
+		if len(f.Children) != 2 {
+			return false, fmt.Errorf("%s: substrings filter must have exactly two children: %w", op, ErrInvalidParameter)
+		}
+		attr := f.Children[childAttribute].Data.String()
+		rule := AttributeMatchingRule(attr)
+		for _, v := range e.attributeValues(attr) {
+			if matchSubstrings(rule, rule.Normalize(v), f.Children[childValue].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterExtensibleMatch:
+		var attr, assertion string
+		for _, c := range f.Children {
+			switch c.Tag {
+			case ldap.MatchingRuleAssertionType:
+				attr = c.Data.String()
+			case ldap.MatchingRuleAssertionMatchValue:
+				assertion = c.Data.String()
+			}
+		}
+		if attr == "" {
+			return false, nil
+		}
+		for _, v := range e.attributeValues(attr) {
+			if AttributeMatchingRule(attr).Equal(v, assertion) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("%s: unknown filter tag %d: %w", op, f.Tag, ErrInvalidParameter)
+	}
+}
+
+// filtersEqual returns true if the ber encoded filters are semantically equal,
+// which ignores the order of the filters in an and/or filter and compares
+// attribute descriptions and values case-insensitively.
+func filtersEqual(a, b *ber.Packet) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.ClassType != b.ClassType || a.Tag != b.Tag {
+		return false
+	}
+	switch a.Tag {
+	case ldap.FilterAnd, ldap.FilterOr:
+		if len(a.Children) != len(b.Children) {
+			return false
+		}
+		matched := make([]bool, len(b.Children))
+	next:
+		for _, ac := range a.Children {
+			for i, bc := range b.Children {
+				if !matched[i] && filtersEqual(ac, bc) {
+					matched[i] = true
+					continue next
+				}
+			}
+			return false
+		}
+		return true
+	case ldap.FilterNot:
+		return len(a.Children) == 1 && len(b.Children) == 1 && filtersEqual(a.Children[0], b.Children[0])
+	case ldap.FilterPresent:
+		return strings.EqualFold(a.Data.String(), b.Data.String())
+	default:
+		return filterValuesEqual(a, b)
+	}
+}
+
+// filterValuesEqual returns true if the ber encoded components of a filter
+// (i.e. an equality match's attribute description and assertion value) are
+// equal.  Components are ordered and their values compared case-insensitively.
+func filterValuesEqual(a, b *ber.Packet) bool {
+	if a.ClassType != b.ClassType || a.Tag != b.Tag || len(a.Children) != len(b.Children) {
+		return false
+	}
+	if !strings.EqualFold(fmt.Sprint(a.Value), fmt.Sprint(b.Value)) {
+		return false
+	}
+	for i := range a.Children {
+		if !filterValuesEqual(a.Children[i], b.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSubstrings returns true if the value, which has been normalized with the
+// rule, matches the substrings
+func matchSubstrings(rule MatchingRule, value string, substrings []*ber.Packet) bool {
+	for idx, s := range substrings {
+		sub := rule.Normalize(s.Data.String())
+		switch s.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, sub) {
+				return false
+			}
+			value = value[len(sub):]
+		case ldap.FilterSubstringsFinal:
+			if idx != len(substrings)-1 || !strings.HasSuffix(value, sub) {
+				return false
+			}
+			value = ""
+		default:
+			i := strings.Index(value, sub)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(sub):]
+		}
+	}
+	return true
+}
+
+// compareValues compares integers numerically and everything else as case
+// insensitive strings, which are prepared for matching (see:
+// MatchingRule.Normalize).
+func compareValues(a, b string) int {
+	ai, aErr := strconv.ParseInt(a, 10, 64)
+	bi, bErr := strconv.ParseInt(b, 10, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case ai < bi:
+			return -1
+		case ai > bi:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(CaseIgnoreMatch.Normalize(a), CaseIgnoreMatch.Normalize(b))
+}
+
+// attributeValues returns the values for the named attribute using a case
+// insensitive match of the attribute's name.
+func (e *Entry) attributeValues(name string) []string {
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return attr.Values
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntry_MatchFilter(t *testing.T) {
+	t.Parallel()
+	e := NewEntry("cn=alice,ou=people,dc=example,dc=org", map[string][]string{
+		"objectClass": {"top", "person"},
+		"cn":          {"Alice"},
+		"mail":        {"alice@example.org"},
+		"uidNumber":   {"1500"},
+	})
+	tests := []struct {
+		name            string
+		filter          string
+		want            bool
+		wantErr         bool
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{name: "missing-filter", wantErr: true, wantErrIs: ErrInvalidParameter, wantErrContains: "missing filter"},
+		{name: "invalid-filter", filter: "(cn=alice", wantErr: true, wantErrContains: "unable to compile filter"},
+		{name: "equality", filter: "(cn=alice)", want: true},
+		{name: "equality-attr-case", filter: "(CN=alice)", want: true},
+		{name: "equality-no-match", filter: "(cn=eve)"},
+		{name: "present", filter: "(mail=*)", want: true},
+		{name: "not-present", filter: "(telephoneNumber=*)"},
+		{name: "and", filter: "(&(objectClass=person)(cn=alice))", want: true},
+		{name: "and-no-match", filter: "(&(objectClass=person)(cn=eve))"},
+		{name: "or", filter: "(|(cn=eve)(cn=alice))", want: true},
+		{name: "not", filter: "(!(cn=eve))", want: true},
+		{name: "substring-initial", filter: "(mail=alice@*)", want: true},
+		{name: "substring-any", filter: "(mail=*@example*)", want: true},
+		{name: "substring-final", filter: "(mail=*.org)", want: true},
+		{name: "substring-no-match", filter: "(mail=bob*.org)"},
+		{name: "greater-or-equal", filter: "(uidNumber>=1000)", want: true},
+		{name: "greater-or-equal-numeric", filter: "(uidNumber>=200)", want: true},
+		{name: "less-or-equal", filter: "(uidNumber<=1000)"},
+		{name: "approx", filter: "(cn~=ALICE)", want: true},
+		{name: "extensible", filter: "(cn:caseIgnoreMatch:=alice)", want: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := e.MatchFilter(tc.filter)
+			if tc.wantErr {
+				require.Error(err)
+				if tc.wantErrIs != nil {
+					assert.ErrorIs(err, tc.wantErrIs)
+				}
+				if tc.wantErrContains != "" {
+					assert.Contains(err.Error(), tc.wantErrContains)
+				}
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func Test_matchRouteFilter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		routeFilter string
+		reqFilter   string
+		want        bool
+	}{
+		{name: "equal", routeFilter: "(uid=alice)", reqFilter: "(uid=alice)", want: true},
+		{name: "case", routeFilter: "(uid=alice)", reqFilter: "(UID=Alice)", want: true},
+		{name: "and-order", routeFilter: "(&(objectClass=person)(uid=alice))", reqFilter: "(&(uid=alice)(objectClass=Person))", want: true},
+		{name: "or-nested-order", routeFilter: "(|(cn=a)(&(sn=b)(mail=c)))", reqFilter: "(|(&(mail=c)(sn=b))(cn=a))", want: true},
+		{name: "and-duplicates", routeFilter: "(&(cn=a)(cn=a))", reqFilter: "(&(cn=a)(sn=b))"},
+		{name: "and-or", routeFilter: "(&(cn=a)(sn=b))", reqFilter: "(|(cn=a)(sn=b))"},
+		{name: "not", routeFilter: "(!(cn=a))", reqFilter: "(!(CN=A))", want: true},
+		{name: "present", routeFilter: "(objectClass=*)", reqFilter: "(objectclass=*)", want: true},
+		{name: "value", routeFilter: "(uid=alice)", reqFilter: "(uid=bob)"},
+		{name: "match-type", routeFilter: "(uidNumber>=5)", reqFilter: "(uidNumber<=5)"},
+		{name: "substrings", routeFilter: "(cn=al*ce)", reqFilter: "(CN=AL*CE)", want: true},
+		{name: "substrings-order", routeFilter: "(cn=al*ce)", reqFilter: "(cn=ce*al)"},
+		{name: "substrings-equality", routeFilter: "(cn=alice)", reqFilter: "(cn=alice*)"},
+		{name: "extensible", routeFilter: "(cn:caseExactMatch:=Alice)", reqFilter: "(cn:caseExactMatch:=Alice)", want: true},
+		{name: "extensible-dn", routeFilter: "(cn:dn:=Alice)", reqFilter: "(cn:=Alice)"},
+		{name: "invalid-request", routeFilter: "(uid=alice)", reqFilter: "(uid=alice"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			routeFilter, err := ldap.CompileFilter(tc.routeFilter)
+			require.NoError(t, err)
+			req := &Request{message: &SearchMessage{Filter: tc.reqFilter}}
+			assert.Equal(t, tc.want, matchRouteFilter(routeFilter, req))
+		})
+	}
+}
@@ -9,6 +9,7 @@ require (
 	github.com/hashicorp/go-hclog v1.6.2
 	github.com/stretchr/testify v1.8.4
 	golang.org/x/exp v0.0.0-20231226003508-02704c960a9b
+	golang.org/x/text v0.14.0
 	mvdan.cc/gofumpt v0.2.1
 )
 
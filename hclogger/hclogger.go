@@ -0,0 +1,35 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+// Package hclogger adapts an hclog.Logger to a gldap.Logger, so a server can
+// log with hclog without the gldap package depending on it.
+package hclogger
+
+import (
+	"io"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/jimlambrt/gldap"
+)
+
+// logger adapts an hclog.Logger to a gldap.Logger, whose logging methods are
+// the hclog.Logger's
+type logger struct {
+	hclog.Logger
+}
+
+// New returns a gldap.Logger which logs with the hclog.Logger (see:
+// gldap.WithLogger).  The server's packet dumps are written with the
+// hclog.Logger's standard writer, so every line of a dump is logged as a
+// message.  A nil hclog.Logger is replaced by hclog.Default().
+func New(l hclog.Logger) gldap.Logger {
+	if l == nil {
+		l = hclog.Default()
+	}
+	return logger{Logger: l}
+}
+
+// LogPacket calls logFn with the logger's standard writer
+func (l logger) LogPacket(logFn func(w io.Writer)) {
+	logFn(l.StandardWriter(&hclog.StandardLoggerOptions{}))
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package hclogger_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/hclogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// safeBuf is a buffer which is safe for concurrent use
+type safeBuf struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuf) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuf) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	buf := &safeBuf{}
+	logger := hclogger.New(hclog.New(&hclog.LoggerOptions{Output: buf, Level: hclog.Debug}))
+	assert.True(logger.IsDebug())
+	assert.NotNil(hclogger.New(nil))
+
+	mux, err := gldap.NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+	}))
+	s, err := gldap.NewServer(gldap.WithLogger(logger))
+	require.NoError(err)
+	require.NoError(s.Router(mux))
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = s.Stop() })
+	require.Eventually(s.Ready, time.Second, 10*time.Millisecond)
+
+	client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", l.Addr()))
+	require.NoError(err)
+	defer client.Close()
+	require.NoError(client.Bind("cn=alice", "password"))
+
+	// the packet dumps are written with the logger's standard writer
+	logged := buf.String()
+	assert.Contains(logged, "packet read")
+	assert.Contains(logged, "cn=alice")
+	assert.NotContains(logged, "packet=")
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalEntry is the decoded summary of a request and its final response
+// recorded by a server's journal (see: WithJournal)
+type JournalEntry struct {
+	// Time the request was received
+	Time time.Time
+	// Duration between receiving the request and writing its final response
+	Duration time.Duration
+	// ConnectionID of the request's conn
+	ConnectionID int
+	// RequestID of the request within its conn (see: Request.ID)
+	RequestID int
+	// MessageID of the request
+	MessageID int64
+	// Operation of the request
+	Operation RouteOperation
+	// DN that the request's operation targets (i.e. a search's base DN)
+	DN string
+	// Summary of the request's parameters (i.e. a search's scope and filter),
+	// which never includes credentials or attribute values
+	Summary string
+	// ResultCode of the request's final response
+	ResultCode int
+	// DiagnosticMessage of the request's final response
+	DiagnosticMessage string
+}
+
+// WithJournal enables a journal which retains the decoded summaries of the
+// server's last maxEntries requests and their final responses in a ring
+// buffer, so intermittent failures (i.e. of a flaky integration test) can be
+// diagnosed after the fact without enabling packet tracing.  A request is
+// recorded when its final response is written, so abandon and unbind requests
+// (which have no response) aren't recorded.  A max less than one is ignored.
+// See: Server.Journal
+func WithJournal(maxEntries int) Option {
+	return serverOption(func(o *configOptions) {
+		if maxEntries > 0 {
+			o.withJournal = maxEntries
+		}
+	})
+}
+
+// Journal returns the entries retained by the server's journal, oldest first,
+// and nil if the server doesn't have a journal (see: WithJournal)
+func (s *Server) Journal() []JournalEntry {
+	return s.journal.snapshot()
+}
+
+// journal is a ring buffer of journal entries.  A nil *journal is valid and
+// doesn't record anything.
+type journal struct {
+	mu      sync.Mutex
+	clock   Clock
+	entries []JournalEntry
+	next    int  // index of the next entry to be recorded
+	full    bool // true once the ring buffer has wrapped around
+}
+
+func newJournal(maxEntries int, clock Clock) *journal {
+	if maxEntries < 1 {
+		return nil
+	}
+	return &journal{
+		clock:   clock,
+		entries: make([]JournalEntry, maxEntries),
+	}
+}
+
+// record the request and its final response
+func (j *journal) record(r *Request, resp Response) {
+	if j == nil || r == nil {
+		return
+	}
+	e := JournalEntry{
+		Time:      r.received,
+		RequestID: r.ID,
+		Operation: r.routeOp,
+		Summary:   journalSummary(r),
+	}
+	if r.conn != nil {
+		e.ConnectionID = r.conn.connID
+	}
+	if r.message != nil {
+		e.MessageID = r.message.GetID()
+	}
+	e.DN, _ = r.targetDN()
+	if d, ok := resp.(diagnosticResponse); ok {
+		e.ResultCode = d.resultCode()
+		e.DiagnosticMessage = d.diagnosticMessage()
+	}
+	now := j.clock.Now()
+	if !e.Time.IsZero() {
+		e.Duration = now.Sub(e.Time)
+	} else {
+		e.Time = now
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[j.next] = e
+	j.next = (j.next + 1) % len(j.entries)
+	if j.next == 0 {
+		j.full = true
+	}
+}
+
+// snapshot returns a copy of the journal's entries, oldest first
+func (j *journal) snapshot() []JournalEntry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.full {
+		return append([]JournalEntry{}, j.entries[:j.next]...)
+	}
+	entries := make([]JournalEntry, 0, len(j.entries))
+	entries = append(entries, j.entries[j.next:]...)
+	return append(entries, j.entries[:j.next]...)
+}
+
+// journalSummary returns a summary of the request's parameters, which never
+// includes credentials or attribute values.
+func journalSummary(r *Request) string {
+	switch m := r.message.(type) {
+	case *SimpleBindMessage:
+		return fmt.Sprintf("authChoice=%s", m.AuthChoice)
+	case *SearchMessage:
+		s := fmt.Sprintf("scope=%d filter=%s", m.Scope, m.Filter)
+		if len(m.Attributes) > 0 {
+			s += fmt.Sprintf(" attrs=%s", strings.Join(m.Attributes, ","))
+		}
+		return s
+	case *ModifyMessage:
+		changes := make([]string, 0, len(m.Changes))
+		for _, ch := range m.Changes {
+			changes = append(changes, fmt.Sprintf("%d:%s", ch.Operation, ch.Modification.Type))
+		}
+		return fmt.Sprintf("changes=%s", strings.Join(changes, ","))
+	case *AddMessage:
+		attrs := make([]string, 0, len(m.Attributes))
+		for _, a := range m.Attributes {
+			attrs = append(attrs, a.Type)
+		}
+		return fmt.Sprintf("attrs=%s", strings.Join(attrs, ","))
+	case *ModifyDNMessage:
+		s := fmt.Sprintf("newRDN=%s deleteOldRDN=%t", m.NewRDN, m.DeleteOldRDN)
+		if m.NewSuperior != "" {
+			s += fmt.Sprintf(" newSuperior=%s", m.NewSuperior)
+		}
+		return s
+	default:
+		if r.extendedName != "" {
+			return fmt.Sprintf("name=%s", r.extendedName)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Journal(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		clock.Advance(time.Second)
+		_ = w.Write(r.NewSearchResponseEntry("uid=alice,ou=people,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSizeLimitExceeded), WithDiagnosticMessage("too many entries")))
+	}))
+	require.NoError(t, mux.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultNoSuchObject)))
+	}))
+	s, url := testServer(t, mux, WithJournal(2), WithClock(clock))
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+	defer client.Close()
+
+	assert, require := assert.New(t), require.New(t)
+	require.NoError(client.Bind("cn=alice", "password"))
+	require.Len(s.Journal(), 1)
+	assert.Equal(BindRouteOperation, s.Journal()[0].Operation)
+	assert.Equal("cn=alice", s.Journal()[0].DN)
+	assert.NotContains(s.Journal()[0].Summary, "password")
+
+	_, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=alice)", []string{"cn", "mail"}, nil))
+	require.Error(err)
+	_ = client.Del(ldap.NewDelRequest("uid=bob,ou=people,dc=example,dc=org", nil))
+
+	// the journal only retains the last two requests
+	entries := s.Journal()
+	require.Len(entries, 2)
+	search, del := entries[0], entries[1]
+	assert.Equal(SearchRouteOperation, search.Operation)
+	assert.Equal("dc=example,dc=org", search.DN)
+	assert.Equal("scope=2 filter=(uid=alice) attrs=cn,mail", search.Summary)
+	assert.Equal(ResultSizeLimitExceeded, search.ResultCode)
+	assert.Equal("too many entries", search.DiagnosticMessage)
+	assert.Equal(time.Second, search.Duration)
+	assert.Equal(2, search.RequestID)
+	assert.NotZero(search.ConnectionID)
+
+	assert.Equal(DeleteRouteOperation, del.Operation)
+	assert.Equal("uid=bob,ou=people,dc=example,dc=org", del.DN)
+	assert.Equal(ResultNoSuchObject, del.ResultCode)
+	assert.Equal(3, del.RequestID)
+	assert.Equal(search.ConnectionID, del.ConnectionID)
+
+	// a server without a journal doesn't record anything
+	s, url = testServer(t, mux)
+	client2, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client2.Close()
+	require.NoError(client2.Bind("cn=alice", "password"))
+	assert.Nil(s.Journal())
+}
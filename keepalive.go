@@ -0,0 +1,40 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// setKeepAlive enables TCP keepalive probes for the conn, which are sent after
+// the conn has been idle for the period and then every period until the
+// client responds or the kernel gives up on the client.  A period less than
+// zero disables the probes.
+func setKeepAlive(c net.Conn, period time.Duration) error {
+	const op = "gldap.setKeepAlive"
+	if tlsConn, ok := c.(*tls.Conn); ok {
+		c = tlsConn.NetConn()
+	}
+	tcpConn, ok := c.(*net.TCPConn)
+	if !ok {
+		// keepalive probes are only supported by TCP conns
+		return nil
+	}
+	if period < 0 {
+		if err := tcpConn.SetKeepAlive(false); err != nil {
+			return fmt.Errorf("%s: unable to disable keepalive: %w", op, err)
+		}
+		return nil
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		return fmt.Errorf("%s: unable to enable keepalive: %w", op, err)
+	}
+	if err := tcpConn.SetKeepAlivePeriod(period); err != nil {
+		return fmt.Errorf("%s: unable to set keepalive period: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_setKeepAlive(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	dial := func(t *testing.T) net.Conn {
+		t.Helper()
+		c, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		conn   func(t *testing.T) net.Conn
+		period time.Duration
+	}{
+		{
+			name:   "tcp-enable",
+			conn:   dial,
+			period: 30 * time.Second,
+		},
+		{
+			name:   "tcp-disable",
+			conn:   dial,
+			period: -1,
+		},
+		{
+			name:   "tls",
+			conn:   func(t *testing.T) net.Conn { return tls.Client(dial(t), &tls.Config{}) },
+			period: 30 * time.Second,
+		},
+		{
+			name: "not-tcp",
+			conn: func(t *testing.T) net.Conn {
+				c1, c2 := net.Pipe()
+				t.Cleanup(func() { _ = c1.Close(); _ = c2.Close() })
+				return c1
+			},
+			period: 30 * time.Second,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.NoError(t, setKeepAlive(tc.conn(t), tc.period))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"sort"
+)
+
+// leaks returns descriptions of the resources the server hasn't released:
+// its open connections, the handlers that haven't returned and the
+// connection state values that were set once their connection was closed
+// (see: AssertNoLeaks)
+func (s *Server) leaks() []string {
+	var leaks []string
+	conns := s.openConns()
+	sort.Slice(conns, func(i, j int) bool { return conns[i].connID < conns[j].connID })
+	for _, c := range conns {
+		leaks = append(leaks, fmt.Sprintf("connection %d is still open with %d in-flight requests", c.connID, c.inFlightRequests()))
+	}
+	inFlight := s.stats.opsInFlight()
+	ops := make([]RouteOperation, 0, len(inFlight))
+	for op := range inFlight {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	for _, op := range ops {
+		leaks = append(leaks, fmt.Sprintf("%d %s handlers haven't returned", inFlight[op], op))
+	}
+	if n := s.leakedStates.Load(); n > 0 {
+		leaks = append(leaks, fmt.Sprintf("%d connection state values were set once their connection was closed", n))
+	}
+	return leaks
+}
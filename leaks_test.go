@@ -0,0 +1,88 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertNoLeaks(t *testing.T) {
+	t.Parallel()
+	type stateKey struct{}
+
+	t.Run("released", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			r.ConnectionState().Set(stateKey{}, "cursor")
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		s, url := testServer(t, mux)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		_, err = client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+
+		require.NoError(s.Stop())
+		AssertNoLeaks(t, s)
+		assert.Empty(s.leaks())
+	})
+	t.Run("leaked", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		release, setState := make(chan struct{}), make(chan struct{})
+		stateSet := make(chan struct{})
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+			// a handler which ignores its request's context
+			<-release
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}))
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			// a goroutine which outlives the handler's connection
+			go func() {
+				<-setState
+				r.ConnectionState().Set(stateKey{}, "cursor")
+				close(stateSet)
+			}()
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		s, url := testServer(t, mux)
+		searcher, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer searcher.Close()
+		_, err = searcher.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+		require.NoError(searcher.Unbind())
+		binder, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer binder.Close()
+		go func() { _ = binder.Bind("cn=alice", "password") }()
+		require.Eventually(func() bool { return len(s.stats.opsInFlight()) == 1 }, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = s.Shutdown(ctx)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+		close(setState)
+		<-stateSet
+		leaks := strings.Join(s.leaks(), "\n")
+		assert.Regexp(`connection \d+ is still open with 1 in-flight requests`, leaks)
+		assert.Contains(leaks, "1 bind handlers haven't returned")
+		assert.Contains(leaks, "1 connection state values were set once their connection was closed")
+
+		// the connection is closed once its handler returns
+		close(release)
+		assert.Eventually(func() bool { return len(s.leaks()) == 1 }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,43 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"io"
+	"strings"
+)
+
+// Logger is the structured logger used by a server (see: WithLogger).  Its
+// args are alternating keys and values.  NewSlogLogger adapts a *slog.Logger
+// (go 1.21 or later) and the hclogger package adapts an hclog.Logger, so
+// applications can use their logging library.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+
+	// IsDebug returns true if debug messages are logged, which enables the
+	// logging of every packet read and written
+	IsDebug() bool
+}
+
+// packetLogger is implemented by the Loggers which write a pretty printed
+// packet with a writer of their own, rather than log it as the arg of a debug
+// message (i.e. the hclogger package's Logger)
+type packetLogger interface {
+	LogPacket(logFn func(w io.Writer))
+}
+
+// logPacket logs the pretty printed packet at the debug level, with the
+// logger's writer when it's a packetLogger.
+func logPacket(l Logger, p *packet) {
+	if pl, ok := l.(packetLogger); ok {
+		pl.LogPacket(func(w io.Writer) { p.Log(w, 0, false) })
+		return
+	}
+	var sb strings.Builder
+	p.Log(&sb, 0, false)
+	l.Debug("packet", "packet", sb.String())
+}
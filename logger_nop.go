@@ -0,0 +1,22 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+//go:build !go1.21
+
+package gldap
+
+// nopLogger is a Logger which discards its messages
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+func (nopLogger) IsDebug() bool                { return false }
+
+// defaultLogger returns the logger of a server without one (see:
+// WithLogger), which discards its messages without the standard library's
+// structured logging.
+func defaultLogger() Logger {
+	return nopLogger{}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "sync"
+
+// DefaultMaintenanceDiagnosticMessage is the default diagnostic message of
+// the responses to requests received while a server is in maintenance mode
+// (see: Server.SetMaintenance)
+const DefaultMaintenanceDiagnosticMessage = "server is in maintenance mode"
+
+// maintenanceMode is a server's maintenance mode, which is shared with its
+// conns.  A nil *maintenanceMode is valid and never enabled.
+type maintenanceMode struct {
+	mu          sync.RWMutex
+	enabled     bool
+	diagMessage string
+}
+
+// set enables or disables the maintenance mode
+func (m *maintenanceMode) set(enabled bool, diagMessage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = enabled
+	m.diagMessage = diagMessage
+}
+
+// state returns true and the diagnostic message when the maintenance mode is
+// enabled.
+func (m *maintenanceMode) state() (bool, string) {
+	if m == nil {
+		return false, ""
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled, m.diagMessage
+}
+
+// inMaintenance returns true when the server's maintenance mode is enabled
+func (c *conn) inMaintenance() bool {
+	enabled, _ := c.maintenance.state()
+	return enabled
+}
+
+// serveMaintenance answers a request received while the server's maintenance
+// mode is enabled.
+func (c *conn) serveMaintenance(w *ResponseWriter, r *Request) {
+	_, diagMessage := c.maintenance.state()
+	_ = w.Write(r.resultResponse(ResultUnavailable, diagMessage))
+}
+
+// SetMaintenance enables or disables the server's maintenance mode while it's
+// running.  When it's enabled every request, except abandon and unbind
+// requests which have no response, is answered with ResultUnavailable and the
+// diagnostic message (or DefaultMaintenanceDiagnosticMessage when it's
+// empty), so clients' behavior during an outage can be tested without closing
+// their connections.  Requests are never routed to the server's handlers
+// while it's enabled.
+func (s *Server) SetMaintenance(enabled bool, diagMessage string) {
+	if diagMessage == "" {
+		diagMessage = DefaultMaintenanceDiagnosticMessage
+	}
+	s.maintenance.set(enabled, diagMessage)
+}
+
+// InMaintenance returns true if the server's maintenance mode is enabled (see:
+// SetMaintenance).
+func (s *Server) InMaintenance() bool {
+	enabled, _ := s.maintenance.state()
+	return enabled
+}
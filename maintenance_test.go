@@ -0,0 +1,62 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_SetMaintenance(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultSuccess)))
+	}))
+	s, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	search := func() error {
+		_, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		return err
+	}
+	assertUnavailable := func(err error, wantDiag string) {
+		t.Helper()
+		var ldapErr *ldap.Error
+		require.ErrorAs(err, &ldapErr)
+		assert.Equal(uint16(ResultUnavailable), ldapErr.ResultCode)
+		assert.Contains(ldapErr.Err.Error(), wantDiag)
+	}
+	assert.False(s.InMaintenance())
+	require.NoError(search())
+
+	s.SetMaintenance(true, "")
+	assert.True(s.InMaintenance())
+	assertUnavailable(search(), DefaultMaintenanceDiagnosticMessage)
+	assertUnavailable(client.Bind("cn=alice,dc=example,dc=org", "password"), DefaultMaintenanceDiagnosticMessage)
+	assertUnavailable(client.Del(ldap.NewDelRequest("cn=alice,dc=example,dc=org", nil)), DefaultMaintenanceDiagnosticMessage)
+
+	s.SetMaintenance(true, "scheduled upgrade")
+	assertUnavailable(search(), "scheduled upgrade")
+
+	// the conn is still usable once the maintenance mode is disabled
+	s.SetMaintenance(false, "")
+	assert.False(s.InMaintenance())
+	require.NoError(search())
+	require.NoError(client.Bind("cn=alice,dc=example,dc=org", "password"))
+}
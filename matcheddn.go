@@ -0,0 +1,32 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "github.com/go-ldap/ldap/v3"
+
+// MatchedDN returns the DN of the entry that's the closest existing ancestor of
+// the dn, which is the matchedDN of a ResultNoSuchObject response (see:
+// https://tools.ietf.org/html/rfc4511#section-4.1.9).  Some clients rely on the
+// matchedDN to determine which of the dn's ancestors must be created before
+// the dn can be added.  The dn is returned when it exists and an empty string
+// is returned when none of its ancestors exist or it's an invalid DN.  DNs are
+// compared case-insensitively and the matched entry's DN is returned as-is.
+func MatchedDN(dn string, entries []*Entry) string {
+	target, err := ldap.ParseDN(dn)
+	if err != nil {
+		return ""
+	}
+	var matched string
+	var matchedRDNs int
+	for _, e := range entries {
+		d, err := ldap.ParseDN(e.DN)
+		if err != nil || len(d.RDNs) <= matchedRDNs {
+			continue
+		}
+		if d.EqualFold(target) || d.AncestorOfFold(target) {
+			matched, matchedRDNs = e.DN, len(d.RDNs)
+		}
+	}
+	return matched
+}
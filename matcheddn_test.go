@@ -0,0 +1,40 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchedDN(t *testing.T) {
+	t.Parallel()
+	entries := []*Entry{
+		NewEntry("dc=example,dc=org", nil),
+		NewEntry("cn=alice,ou=people,dc=example,dc=org", nil),
+		NewEntry("ou=People,dc=example,dc=org", nil),
+		NewEntry("invalid", nil),
+	}
+	tests := []struct {
+		name string
+		dn   string
+		want string
+	}{
+		{name: "parent", dn: "cn=bob,ou=people,dc=example,dc=org", want: "ou=People,dc=example,dc=org"},
+		{name: "ancestor", dn: "cn=laptop,ou=devices,cn=bob,ou=people,dc=example,dc=org", want: "ou=People,dc=example,dc=org"},
+		{name: "naming-context", dn: "ou=groups,DC=Example,DC=Org", want: "dc=example,dc=org"},
+		{name: "exists", dn: "cn=alice,ou=people,dc=example,dc=org", want: "cn=alice,ou=people,dc=example,dc=org"},
+		{name: "no-ancestor", dn: "cn=bob,dc=example,dc=com"},
+		{name: "invalid", dn: "not a dn"},
+		{name: "empty", dn: ""},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, MatchedDN(tc.dn, entries))
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "strings"
+
+// MatchingRule is an equality matching rule used to compare attribute values
+// (see: https://tools.ietf.org/html/rfc4517#section-4.2)
+type MatchingRule int
+
+// Equality matching rules
+const (
+	// CaseIgnoreMatch compares values case-insensitively, ignoring leading,
+	// trailing and repeated spaces.  It's the matching rule of most string
+	// attributes.
+	CaseIgnoreMatch MatchingRule = iota
+	// CaseExactMatch compares values case-sensitively, ignoring leading,
+	// trailing and repeated spaces.
+	CaseExactMatch
+	// OctetStringMatch compares values byte for byte.  It's the matching rule
+	// of binary attributes (i.e. userPassword).
+	OctetStringMatch
+)
+
+// octetStringAttributes are the well-known attributes whose values are
+// compared with OctetStringMatch, keyed by their lower case names.
+var octetStringAttributes = map[string]struct{}{
+	"userpassword":              {},
+	"unicodepwd":                {},
+	"jpegphoto":                 {},
+	"photo":                     {},
+	"thumbnailphoto":            {},
+	"usercertificate":           {},
+	"cacertificate":             {},
+	"certificaterevocationlist": {},
+	"authorityrevocationlist":   {},
+	"crosscertificatepair":      {},
+	"usersmimecertificate":      {},
+	"userpkcs12":                {},
+	"objectguid":                {},
+	"objectsid":                 {},
+}
+
+// AttributeMatchingRule returns the equality matching rule of the attribute,
+// which is OctetStringMatch for well-known binary attributes and attributes
+// with the ";binary" option, and CaseIgnoreMatch otherwise.
+func AttributeMatchingRule(name string) MatchingRule {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ";binary") {
+		return OctetStringMatch
+	}
+	if _, ok := octetStringAttributes[name]; ok {
+		return OctetStringMatch
+	}
+	return CaseIgnoreMatch
+}
+
+// Normalize returns the value's normalized form, so values which are equal
+// for the matching rule have the same normalized form.  The values of
+// CaseIgnoreMatch and CaseExactMatch are prepared with the string preparation
+// algorithm of https://tools.ietf.org/html/rfc4518 (i.e. "Ångström" and
+// "A\u030Angstro\u0308m" are equal).
+func (m MatchingRule) Normalize(value string) string {
+	switch m {
+	case OctetStringMatch:
+		return value
+	case CaseExactMatch:
+		return prepareString(value, false)
+	default:
+		return prepareString(value, true)
+	}
+}
+
+// Equal returns true if the values are equal for the matching rule.
+func (m MatchingRule) Equal(a, b string) bool {
+	return m.Normalize(a) == m.Normalize(b)
+}
+
+// MergeValues adds the values which aren't already one of the attribute's
+// values for the matching rule, which prevents values like "Foo" and "foo"
+// from both being added to a CaseIgnoreMatch attribute.  The attribute's
+// existing values are unchanged.
+func (e *EntryAttribute) MergeValues(rule MatchingRule, values ...string) {
+	existing := make(map[string]struct{}, len(e.Values)+len(values))
+	for _, v := range e.Values {
+		existing[rule.Normalize(v)] = struct{}{}
+	}
+	for _, v := range values {
+		n := rule.Normalize(v)
+		if _, ok := existing[n]; ok {
+			continue
+		}
+		existing[n] = struct{}{}
+		e.AddValue(v)
+	}
+}
+
+// MergeAttribute merges the values into the entry's named attribute using the
+// attribute's matching rule (see: AttributeMatchingRule), adding the attribute
+// when the entry doesn't have it.  Attribute names are matched
+// case-insensitively.
+func (e *Entry) MergeAttribute(name string, values ...string) {
+	if len(values) == 0 {
+		return
+	}
+	a := findAttribute(e.Attributes, name)
+	if a == nil {
+		a = NewEntryAttribute(name, nil)
+		e.Attributes = append(e.Attributes, a)
+	}
+	a.MergeValues(AttributeMatchingRule(name), values...)
+}
+
+// MergeEntry merges all the other entry's attribute values into the entry
+// (see: Entry.MergeAttribute).  The entries' DNs are not compared.
+func (e *Entry) MergeEntry(other *Entry) {
+	if other == nil {
+		return
+	}
+	for _, a := range other.Attributes {
+		e.MergeAttribute(a.Name, a.Values...)
+	}
+}
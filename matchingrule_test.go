@@ -0,0 +1,67 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchingRule_Equal(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		rule MatchingRule
+		a, b string
+		want bool
+	}{
+		{name: "case-ignore", rule: CaseIgnoreMatch, a: "Foo", b: "foo", want: true},
+		{name: "case-ignore-spaces", rule: CaseIgnoreMatch, a: " Alice  Smith ", b: "alice smith", want: true},
+		{name: "case-ignore-different", rule: CaseIgnoreMatch, a: "foo", b: "bar"},
+		{name: "case-exact", rule: CaseExactMatch, a: "Foo", b: "foo"},
+		{name: "case-exact-spaces", rule: CaseExactMatch, a: "Alice  Smith", b: "Alice Smith", want: true},
+		{name: "octet-string", rule: OctetStringMatch, a: "Foo", b: "foo"},
+		{name: "octet-string-spaces", rule: OctetStringMatch, a: "foo ", b: "foo"},
+		{name: "octet-string-equal", rule: OctetStringMatch, a: "foo", b: "foo", want: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, tc.rule.Equal(tc.a, tc.b))
+		})
+	}
+}
+
+func TestAttributeMatchingRule(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Equal(CaseIgnoreMatch, AttributeMatchingRule("cn"))
+	assert.Equal(OctetStringMatch, AttributeMatchingRule("userPassword"))
+	assert.Equal(OctetStringMatch, AttributeMatchingRule("objectGUID"))
+	assert.Equal(OctetStringMatch, AttributeMatchingRule("userCertificate;binary"))
+}
+
+func TestEntry_MergeAttribute(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	e := NewEntry("cn=alice", map[string][]string{
+		"mail":         {"Alice@example.org"},
+		"userPassword": {"secret"},
+	})
+	e.MergeAttribute("MAIL", "alice@example.org", "alice@example.com", "ALICE@example.com")
+	e.MergeAttribute("userPassword", "secret", "Secret")
+	e.MergeAttribute("sn", "Smith", "smith")
+	e.MergeAttribute("title")
+	assert.Equal([]string{"Alice@example.org", "alice@example.com"}, e.GetAttributeValues("mail"))
+	assert.Equal([]string{"secret", "Secret"}, e.GetAttributeValues("userPassword"))
+	assert.Equal([]string{"Smith"}, e.GetAttributeValues("sn"))
+	assert.Nil(findAttribute(e.Attributes, "title"))
+
+	other := NewEntry("cn=alice", map[string][]string{"sn": {"SMITH", "Jones"}})
+	e.MergeEntry(other)
+	e.MergeEntry(nil)
+	assert.Equal([]string{"Smith", "Jones"}, e.GetAttributeValues("sn"))
+}
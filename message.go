@@ -5,6 +5,7 @@ package gldap
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Scope represents the scope of a search (see: https://ldap.com/the-ldap-search-operation/)
@@ -28,8 +29,110 @@ const (
 	// DN is the null DN, the root DSE should not be considered in a
 	// wholeSubtree search.
 	WholeSubtree Scope = 2
+
+	// SubordinateSubtree (often referred to as “children”): Indicates that all
+	// of the subordinates of the entry specified as the search base, to any
+	// depth, should be considered. The base entry itself should not be
+	// considered (see:
+	// https://tools.ietf.org/html/draft-sermersheim-ldap-subordinate-scope-02)
+	SubordinateSubtree Scope = 3
+)
+
+// scopeNames are the names of the scopes, as they're named by
+// https://tools.ietf.org/html/rfc4511#section-4.5.1.2, and their short names
+// (as they're named by ldap URLs and ldapsearch)
+var scopeNames = []struct {
+	scope     Scope
+	name      string
+	shortName string
+}{
+	{BaseObject, "baseObject", "base"},
+	{SingleLevel, "singleLevel", "one"},
+	{WholeSubtree, "wholeSubtree", "sub"},
+	{SubordinateSubtree, "subordinateSubtree", "children"},
+}
+
+// String returns the scope's name (i.e. "wholeSubtree")
+func (s Scope) String() string {
+	for _, n := range scopeNames {
+		if n.scope == s {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("Scope(%d)", int64(s))
+}
+
+// ParseScope returns the scope with the name, or its short name (i.e.
+// "wholeSubtree" or "sub").  Names are compared case-insensitively.
+func ParseScope(name string) (Scope, error) {
+	const op = "gldap.ParseScope"
+	for _, n := range scopeNames {
+		if strings.EqualFold(n.name, name) || strings.EqualFold(n.shortName, name) {
+			return n.scope, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: unknown scope %q: %w", op, name, ErrInvalidParameter)
+}
+
+// DerefAliases specifies how a search dereferences aliases (see:
+// https://tools.ietf.org/html/rfc4511#section-4.5.1.3)
+type DerefAliases int
+
+const (
+	// NeverDerefAliases: Do not dereference aliases in searching or in
+	// locating the base object of the search.
+	NeverDerefAliases DerefAliases = 0
+
+	// DerefInSearching: While searching subordinates of the base object,
+	// dereference any alias within the search scope.
+	DerefInSearching DerefAliases = 1
+
+	// DerefFindingBaseObj: Dereference aliases in locating the base object of
+	// the search, but not when searching subordinates of the base object.
+	DerefFindingBaseObj DerefAliases = 2
+
+	// DerefAlways: Dereference aliases both in searching and in locating the
+	// base object of the search.
+	DerefAlways DerefAliases = 3
 )
 
+// derefAliasesNames are the names of the deref aliases values, as they're
+// named by https://tools.ietf.org/html/rfc4511#section-4.5.1.3, and their
+// short names (as they're named by ldapsearch)
+var derefAliasesNames = []struct {
+	deref     DerefAliases
+	name      string
+	shortName string
+}{
+	{NeverDerefAliases, "neverDerefAliases", "never"},
+	{DerefInSearching, "derefInSearching", "search"},
+	{DerefFindingBaseObj, "derefFindingBaseObj", "find"},
+	{DerefAlways, "derefAlways", "always"},
+}
+
+// String returns the deref aliases value's name (i.e. "derefAlways")
+func (d DerefAliases) String() string {
+	for _, n := range derefAliasesNames {
+		if n.deref == d {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("DerefAliases(%d)", int(d))
+}
+
+// ParseDerefAliases returns the deref aliases value with the name, or its
+// short name (i.e. "derefAlways" or "always").  Names are compared
+// case-insensitively.
+func ParseDerefAliases(name string) (DerefAliases, error) {
+	const op = "gldap.ParseDerefAliases"
+	for _, n := range derefAliasesNames {
+		if strings.EqualFold(n.name, name) || strings.EqualFold(n.shortName, name) {
+			return n.deref, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: unknown deref aliases %q: %w", op, name, ErrInvalidParameter)
+}
+
 // AuthChoice defines the authentication choice for bind message
 type AuthChoice string
 
@@ -37,6 +140,23 @@ type AuthChoice string
 // the bind message
 const SimpleAuthChoice AuthChoice = "simple"
 
+// String returns the authentication choice's name
+func (a AuthChoice) String() string {
+	return string(a)
+}
+
+// ParseAuthChoice returns the authentication choice with the name (i.e.
+// "simple").  Names are compared case-insensitively.
+func ParseAuthChoice(name string) (AuthChoice, error) {
+	const op = "gldap.ParseAuthChoice"
+	switch {
+	case strings.EqualFold(string(SimpleAuthChoice), name):
+		return SimpleAuthChoice, nil
+	default:
+		return "", fmt.Errorf("%s: unknown authentication choice %q: %w", op, name, ErrInvalidParameter)
+	}
+}
+
 type requestType string
 
 const (
@@ -45,9 +165,11 @@ const (
 	searchRequestType   requestType = "search"
 	extendedRequestType requestType = "extended"
 	modifyRequestType   requestType = "modify"
+	modifyDNRequestType requestType = "modifyDN"
 	addRequestType      requestType = "add"
 	deleteRequestType   requestType = "delete"
 	unbindRequestType   requestType = "unbind"
+	abandonRequestType  requestType = "abandon"
 )
 
 // Message defines a common interface for all messages
@@ -72,7 +194,7 @@ type SearchMessage struct {
 	// Scope of the request
 	Scope Scope
 	// DerefAliases for the request
-	DerefAliases int
+	DerefAliases DerefAliases
 	// TimeLimit is the max time in seconds to spend processing
 	TimeLimit int64
 	// SizeLimit is the max number of results to return
@@ -119,11 +241,24 @@ type DeleteMessage struct {
 	Controls []Control
 }
 
+// TreeDelete returns true if the message has the tree delete control, which
+// means the entry and all of its subordinates should be deleted.
+func (m *DeleteMessage) TreeDelete() bool {
+	return findControl(m.Controls, ControlTypeMicrosoftTreeDelete) != nil
+}
+
 // UnbindMessage is an unbind request message
 type UnbindMessage struct {
 	baseMessage
 }
 
+// AbandonMessage is an abandon request message
+type AbandonMessage struct {
+	baseMessage
+	// MessageID of the request being abandoned
+	MessageID int64
+}
+
 // newMessage will create a new message from the packet.
 func newMessage(p *packet) (Message, error) {
 	const op = "gldap.NewMessage"
@@ -145,6 +280,17 @@ func newMessage(p *packet) (Message, error) {
 				id: msgID,
 			},
 		}, nil
+	case abandonRequestType:
+		abandonID, err := p.abandonMessageID()
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid abandon message: %w", op, err)
+		}
+		return &AbandonMessage{
+			baseMessage: baseMessage{
+				id: msgID,
+			},
+			MessageID: abandonID,
+		}, nil
 	case bindRequestType:
 		u, pass, controls, err := p.simpleBindParameters()
 		if err != nil {
@@ -170,7 +316,7 @@ func newMessage(p *packet) (Message, error) {
 			},
 			BaseDN:       parameters.baseDN,
 			Scope:        Scope(parameters.scope),
-			DerefAliases: int(parameters.derefAliases),
+			DerefAliases: DerefAliases(parameters.derefAliases),
 			SizeLimit:    parameters.sizeLimit,
 			TimeLimit:    parameters.timeLimit,
 			TypesOnly:    parameters.typesOnly,
@@ -183,11 +329,16 @@ func newMessage(p *packet) (Message, error) {
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
+		opValue, err := p.extendedOperationValue()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 		return &ExtendedOperationMessage{
 			baseMessage: baseMessage{
 				id: msgID,
 			},
-			Name: opName,
+			Name:  opName,
+			Value: opValue,
 		}, nil
 	case modifyRequestType:
 		parameters, err := p.modifyParameters()
@@ -202,6 +353,21 @@ func newMessage(p *packet) (Message, error) {
 			Changes:  parameters.changes,
 			Controls: parameters.controls,
 		}, nil
+	case modifyDNRequestType:
+		parameters, err := p.modifyDNParameters()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return &ModifyDNMessage{
+			baseMessage: baseMessage{
+				id: msgID,
+			},
+			DN:           parameters.dn,
+			NewRDN:       parameters.newRDN,
+			DeleteOldRDN: parameters.deleteOldRDN,
+			NewSuperior:  parameters.newSuperior,
+			Controls:     parameters.controls,
+		}, nil
 	case addRequestType:
 		parameters, err := p.addParameters()
 		if err != nil {
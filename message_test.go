@@ -0,0 +1,110 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScope_String(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	for _, s := range []Scope{BaseObject, SingleLevel, WholeSubtree, SubordinateSubtree} {
+		got, err := ParseScope(s.String())
+		require.NoError(t, err)
+		assert.Equal(s, got)
+	}
+	assert.Equal("wholeSubtree", WholeSubtree.String())
+	assert.Equal("Scope(7)", Scope(7).String())
+}
+
+func TestParseScope(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		want    Scope
+		wantErr bool
+	}{
+		{name: "baseObject", want: BaseObject},
+		{name: "base", want: BaseObject},
+		{name: "one", want: SingleLevel},
+		{name: "SUB", want: WholeSubtree},
+		{name: "children", want: SubordinateSubtree},
+		{name: "subordinateSubtree", want: SubordinateSubtree},
+		{name: "", wantErr: true},
+		{name: "everything", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			got, err := ParseScope(tc.name)
+			if tc.wantErr {
+				assert.ErrorIs(err, ErrInvalidParameter)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestDerefAliases_String(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	for _, d := range []DerefAliases{NeverDerefAliases, DerefInSearching, DerefFindingBaseObj, DerefAlways} {
+		got, err := ParseDerefAliases(d.String())
+		require.NoError(t, err)
+		assert.Equal(d, got)
+	}
+	assert.Equal("derefAlways", DerefAlways.String())
+	assert.Equal("DerefAliases(4)", DerefAliases(4).String())
+}
+
+func TestParseDerefAliases(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		want    DerefAliases
+		wantErr bool
+	}{
+		{name: "never", want: NeverDerefAliases},
+		{name: "search", want: DerefInSearching},
+		{name: "find", want: DerefFindingBaseObj},
+		{name: "Always", want: DerefAlways},
+		{name: "derefFindingBaseObj", want: DerefFindingBaseObj},
+		{name: "", wantErr: true},
+		{name: "sometimes", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			got, err := ParseDerefAliases(tc.name)
+			if tc.wantErr {
+				assert.ErrorIs(err, ErrInvalidParameter)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestParseAuthChoice(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	got, err := ParseAuthChoice("Simple")
+	assert.NoError(err)
+	assert.Equal(SimpleAuthChoice, got)
+	assert.Equal("simple", got.String())
+
+	_, err = ParseAuthChoice("sasl")
+	assert.ErrorIs(err, ErrInvalidParameter)
+}
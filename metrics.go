@@ -0,0 +1,132 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WriteMetrics writes the server's statistics to the writer in the OpenMetrics
+// text format (see: https://github.com/OpenObservability/OpenMetrics), which
+// allows them to be scraped from a file or an admin extended operation in
+// environments without a Prometheus client library.  The statistics include
+// histograms of the encoded sizes of each operation's requests and responses,
+// which help diagnose clients that transfer unexpectedly large amounts of data.
+// The unknown controls are counted by type for a limited number of types, and
+// the rest are counted with the type "other".
+func (s *Server) WriteMetrics(w io.Writer) error {
+	const op = "gldap.(Server).WriteMetrics"
+	if w == nil {
+		return fmt.Errorf("%s: missing writer: %w", op, ErrInvalidParameter)
+	}
+	if err := s.stats.writeMetrics(w); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// writeMetrics writes the stats in the OpenMetrics text format
+func (s *serverStats) writeMetrics(w io.Writer) error {
+	const op = "gldap.(serverStats).writeMetrics"
+	if s == nil {
+		return fmt.Errorf("%s: missing stats: %w", op, ErrInvalidParameter)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(bw, "# TYPE %s %s\n# HELP %s %s\n", name, typ, name, help)
+	}
+	metric("gldap_start_time_seconds", "gauge", "Time the server was started in seconds since the epoch.")
+	fmt.Fprintf(bw, "gldap_start_time_seconds %d\n", s.startTime.Unix())
+	metric("gldap_connections", "counter", "Connections accepted by the server.")
+	fmt.Fprintf(bw, "gldap_connections_total %d\n", s.totalConns)
+	metric("gldap_connections_current", "gauge", "Connections currently open.")
+	fmt.Fprintf(bw, "gldap_connections_current %d\n", s.currentConns)
+	metric("gldap_operations_initiated", "counter", "Operations initiated by clients.")
+	for _, o := range monitorOperations {
+		fmt.Fprintf(bw, "gldap_operations_initiated_total{operation=%q} %d\n", o.op, s.opsInitiated[o.op])
+	}
+	metric("gldap_operations_completed", "counter", "Operations completed by the server.")
+	for _, o := range monitorOperations {
+		fmt.Fprintf(bw, "gldap_operations_completed_total{operation=%q} %d\n", o.op, s.opsCompleted[o.op])
+	}
+	metric("gldap_unknown_controls", "counter", "Controls of unknown types sent by clients.")
+	unknownControls := make([]unknownControlKey, 0, len(s.unknownControls))
+	for k := range s.unknownControls {
+		unknownControls = append(unknownControls, k)
+	}
+	sort.Slice(unknownControls, func(i, j int) bool {
+		if unknownControls[i].controlType != unknownControls[j].controlType {
+			return unknownControls[i].controlType < unknownControls[j].controlType
+		}
+		return !unknownControls[i].critical && unknownControls[j].critical
+	})
+	for _, k := range unknownControls {
+		fmt.Fprintf(bw, "gldap_unknown_controls_total{type=%q,critical=\"%t\"} %d\n", k.controlType, k.critical, s.unknownControls[k])
+	}
+	metric("gldap_request_size_bytes", "histogram", "Encoded size of the requests read by the server.")
+	writeSizeHistograms(bw, "gldap_request_size_bytes", s.requestSizes)
+	metric("gldap_response_size_bytes", "histogram", "Encoded size of the responses written by the server.")
+	writeSizeHistograms(bw, "gldap_response_size_bytes", s.responseSizes)
+	fmt.Fprint(bw, "# EOF\n")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("%s: unable to write metrics: %w", op, err)
+	}
+	return nil
+}
+
+// sizeBuckets are the upper bounds of the size histograms' buckets in bytes,
+// which range from 64 bytes to 1GiB.
+var sizeBuckets = []int64{
+	64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10,
+	1 << 20, 4 << 20, 16 << 20, 64 << 20, 256 << 20, 1 << 30,
+}
+
+// sizeHistogram is a histogram of encoded sizes (see: sizeBuckets)
+type sizeHistogram struct {
+	buckets []int64 // the count of sizes within each bucket (not cumulative)
+	count   int64
+	sum     int64
+}
+
+// observeSize adds the size to the operation's histogram
+func observeSize(histograms map[RouteOperation]*sizeHistogram, op RouteOperation, size int) {
+	h, ok := histograms[op]
+	if !ok {
+		h = &sizeHistogram{buckets: make([]int64, len(sizeBuckets))}
+		histograms[op] = h
+	}
+	h.count++
+	h.sum += int64(size)
+	for i, le := range sizeBuckets {
+		if int64(size) <= le {
+			h.buckets[i]++
+			break
+		}
+	}
+}
+
+// writeSizeHistograms writes the histograms of the operations that have been
+// observed in the order of monitorOperations
+func writeSizeHistograms(w io.Writer, name string, histograms map[RouteOperation]*sizeHistogram) {
+	for _, o := range monitorOperations {
+		h, ok := histograms[o.op]
+		if !ok {
+			continue
+		}
+		var cumulative int64
+		for i, le := range sizeBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(w, "%s_bucket{operation=%q,le=\"%d\"} %d\n", name, o.op, le, cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{operation=%q,le=\"+Inf\"} %d\n", name, o.op, h.count)
+		fmt.Fprintf(w, "%s_sum{operation=%q} %d\n", name, o.op, h.sum)
+		fmt.Fprintf(w, "%s_count{operation=%q} %d\n", name, o.op, h.count)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_serverStats_writeMetrics(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	s := newServerStats(systemClock{})
+	s.startTime = time.Unix(1700000000, 0)
+	s.connOpened()
+	s.connOpened()
+	s.connClosed()
+	s.opInitiated(BindRouteOperation)
+	s.opCompleted(BindRouteOperation)
+	s.opInitiated(SearchRouteOperation)
+	s.unknownControlRead("1.2.3.4", true)
+	s.unknownControlRead("1.2.3.4", false)
+	s.unknownControlRead("1.2.3.4", true)
+
+	var sb strings.Builder
+	require.NoError(s.writeMetrics(&sb))
+	assert.Equal(`# TYPE gldap_start_time_seconds gauge
+# HELP gldap_start_time_seconds Time the server was started in seconds since the epoch.
+gldap_start_time_seconds 1700000000
+# TYPE gldap_connections counter
+# HELP gldap_connections Connections accepted by the server.
+gldap_connections_total 2
+# TYPE gldap_connections_current gauge
+# HELP gldap_connections_current Connections currently open.
+gldap_connections_current 1
+# TYPE gldap_operations_initiated counter
+# HELP gldap_operations_initiated Operations initiated by clients.
+gldap_operations_initiated_total{operation="bind"} 1
+gldap_operations_initiated_total{operation="unbind"} 0
+gldap_operations_initiated_total{operation="search"} 1
+gldap_operations_initiated_total{operation="modify"} 0
+gldap_operations_initiated_total{operation="modifyDN"} 0
+gldap_operations_initiated_total{operation="add"} 0
+gldap_operations_initiated_total{operation="delete"} 0
+gldap_operations_initiated_total{operation="abandon"} 0
+gldap_operations_initiated_total{operation="extendedOperation"} 0
+# TYPE gldap_operations_completed counter
+# HELP gldap_operations_completed Operations completed by the server.
+gldap_operations_completed_total{operation="bind"} 1
+gldap_operations_completed_total{operation="unbind"} 0
+gldap_operations_completed_total{operation="search"} 0
+gldap_operations_completed_total{operation="modify"} 0
+gldap_operations_completed_total{operation="modifyDN"} 0
+gldap_operations_completed_total{operation="add"} 0
+gldap_operations_completed_total{operation="delete"} 0
+gldap_operations_completed_total{operation="abandon"} 0
+gldap_operations_completed_total{operation="extendedOperation"} 0
+# TYPE gldap_unknown_controls counter
+# HELP gldap_unknown_controls Controls of unknown types sent by clients.
+gldap_unknown_controls_total{type="1.2.3.4",critical="false"} 1
+gldap_unknown_controls_total{type="1.2.3.4",critical="true"} 2
+# TYPE gldap_request_size_bytes histogram
+# HELP gldap_request_size_bytes Encoded size of the requests read by the server.
+# TYPE gldap_response_size_bytes histogram
+# HELP gldap_response_size_bytes Encoded size of the responses written by the server.
+# EOF
+`, sb.String())
+
+	var nilStats *serverStats
+	assert.ErrorIs(nilStats.writeMetrics(&sb), ErrInvalidParameter)
+}
+
+func Test_serverStats_unknownControlRead(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	s := newServerStats(systemClock{})
+	for i := 0; i < maxUnknownControlTypes+10; i++ {
+		s.unknownControlRead(fmt.Sprintf("1.2.3.%d", i), false)
+	}
+	s.unknownControlRead("1.2.3.0", true)
+	s.unknownControlRead("1.2.3.4", false)
+
+	assert.Len(s.unknownControls, maxUnknownControlTypes+2)
+	assert.Equal(int64(2), s.unknownControls[unknownControlKey{controlType: "1.2.3.4"}])
+	assert.Equal(int64(1), s.unknownControls[unknownControlKey{controlType: "1.2.3.0", critical: true}])
+	assert.Equal(int64(10), s.unknownControls[unknownControlKey{controlType: otherControlType}])
+}
+
+func Test_serverStats_sizeHistograms(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	s := newServerStats(systemClock{})
+	s.requestRead(BindRouteOperation, 40)
+	s.responseWritten(BindRouteOperation, 14)
+	s.requestRead(SearchRouteOperation, 300)
+	s.requestRead(SearchRouteOperation, 2<<30)
+
+	var sb strings.Builder
+	require.NoError(s.writeMetrics(&sb))
+	got := sb.String()
+	assert.Contains(got, `gldap_request_size_bytes_bucket{operation="bind",le="64"} 1
+gldap_request_size_bytes_bucket{operation="bind",le="256"} 1
+`)
+	assert.Contains(got, `gldap_request_size_bytes_bucket{operation="bind",le="+Inf"} 1
+gldap_request_size_bytes_sum{operation="bind"} 40
+gldap_request_size_bytes_count{operation="bind"} 1
+gldap_request_size_bytes_bucket{operation="search",le="64"} 0
+gldap_request_size_bytes_bucket{operation="search",le="256"} 0
+gldap_request_size_bytes_bucket{operation="search",le="1024"} 1
+`)
+	assert.Contains(got, `gldap_request_size_bytes_bucket{operation="search",le="1073741824"} 1
+gldap_request_size_bytes_bucket{operation="search",le="+Inf"} 2
+gldap_request_size_bytes_sum{operation="search"} 2147483948
+gldap_request_size_bytes_count{operation="search"} 2
+# TYPE gldap_response_size_bytes histogram
+`)
+	assert.Contains(got, `gldap_response_size_bytes_sum{operation="bind"} 14
+gldap_response_size_bytes_count{operation="bind"} 1
+# EOF
+`)
+	// operations without any requests don't have a histogram
+	assert.NotContains(got, `gldap_response_size_bytes_count{operation="search"}`)
+}
+
+func TestServer_WriteMetrics(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	s, url := testServer(t, mux)
+	assert.ErrorIs(s.WriteMetrics(nil), ErrInvalidParameter)
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	require.NoError(client.Bind("cn=alice", "password"))
+
+	var sb strings.Builder
+	require.NoError(s.WriteMetrics(&sb))
+	assert.Contains(sb.String(), "gldap_connections_current 1\n")
+	assert.Contains(sb.String(), "gldap_operations_initiated_total{operation=\"bind\"} 1\n")
+	assert.Contains(sb.String(), "gldap_request_size_bytes_count{operation=\"bind\"} 1\n")
+	assert.Contains(sb.String(), "gldap_response_size_bytes_count{operation=\"bind\"} 1\n")
+	assert.True(strings.HasSuffix(sb.String(), "# EOF\n"))
+}
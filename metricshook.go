@@ -0,0 +1,53 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "time"
+
+// MetricsHook receives a server's measurements as they're made (see:
+// WithMetricsHook), so they can be recorded with a metrics library (i.e. by
+// a prometheus.Collector) and the server monitored like any other service.
+// The current number of connections is the difference between the opened and
+// closed connections.  The hook is called synchronously, so it must be safe
+// for concurrent use and it shouldn't block.
+type MetricsHook interface {
+	// ConnectionOpened is called when a connection is accepted
+	ConnectionOpened()
+	// ConnectionClosed is called when an accepted connection is closed
+	ConnectionClosed()
+	// OperationCompleted is called when an operation's final response is
+	// written, with its result code and the latency between receiving the
+	// request and writing the response.  The result code is -1 for the
+	// operations without a response (abandon and unbind).
+	OperationCompleted(op RouteOperation, resultCode int, latency time.Duration)
+	// BytesRead is called when a request is read, with its encoded size
+	BytesRead(op RouteOperation, n int)
+	// BytesWritten is called when a response is written, with its encoded
+	// size
+	BytesWritten(op RouteOperation, n int)
+}
+
+// WithMetricsHook sets a hook which receives the server's connection,
+// operation and byte measurements (see: MetricsHook).  The hook is called
+// before an operation's final response is written, so it's updated before the
+// client gets the response.
+func WithMetricsHook(h MetricsHook) Option {
+	return serverOption(func(o *configOptions) {
+		o.withMetricsHook = h
+	})
+}
+
+// observeOperation sends the measurements of the request's completed operation
+// to the conn's metrics hook, with the request's final response, which is nil
+// for the operations without a response.
+func (c *conn) observeOperation(r *Request, resp Response) {
+	if c.metricsHook == nil || r == nil {
+		return
+	}
+	code := -1
+	if d, ok := resp.(diagnosticResponse); ok {
+		code = d.resultCode()
+	}
+	c.metricsHook.OperationCompleted(r.routeOp, code, c.clock.Now().Sub(r.received))
+}
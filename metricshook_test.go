@@ -0,0 +1,110 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMetricsHook records the measurements it receives
+type testMetricsHook struct {
+	mu           sync.Mutex
+	opened       int
+	closed       int
+	completed    map[RouteOperation][]int // result codes by operation
+	latencies    []time.Duration
+	bytesRead    map[RouteOperation]int
+	bytesWritten map[RouteOperation]int
+}
+
+func (h *testMetricsHook) ConnectionOpened() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opened++
+}
+
+func (h *testMetricsHook) ConnectionClosed() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed++
+}
+
+func (h *testMetricsHook) OperationCompleted(op RouteOperation, resultCode int, latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.completed == nil {
+		h.completed = map[RouteOperation][]int{}
+	}
+	h.completed[op] = append(h.completed[op], resultCode)
+	h.latencies = append(h.latencies, latency)
+}
+
+func (h *testMetricsHook) BytesRead(op RouteOperation, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.bytesRead == nil {
+		h.bytesRead = map[RouteOperation]int{}
+	}
+	h.bytesRead[op] += n
+}
+
+func (h *testMetricsHook) BytesWritten(op RouteOperation, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.bytesWritten == nil {
+		h.bytesWritten = map[RouteOperation]int{}
+	}
+	h.bytesWritten[op] += n
+}
+
+func TestServer_WithMetricsHook(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultInvalidCredentials)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		clock.Advance(time.Second)
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	h := &testMetricsHook{}
+	_, url := testServer(t, mux, WithMetricsHook(h), WithClock(clock))
+
+	assert, require := assert.New(t), require.New(t)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	assert.Error(client.Bind("cn=alice", "bad"))
+	_, err = client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	require.NoError(client.Unbind())
+	assert.Eventually(func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.closed == 1
+	}, time.Second, 10*time.Millisecond)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	assert.Equal(1, h.opened)
+	assert.Equal([]int{ResultInvalidCredentials}, h.completed[BindRouteOperation])
+	assert.Equal([]int{ResultSuccess}, h.completed[SearchRouteOperation])
+	assert.Equal([]int{-1}, h.completed[UnbindRouteOperation])
+	assert.Contains(h.latencies, time.Second)
+	for _, op := range []RouteOperation{BindRouteOperation, SearchRouteOperation, UnbindRouteOperation} {
+		assert.Positive(h.bytesRead[op], op)
+	}
+	assert.Positive(h.bytesWritten[BindRouteOperation])
+	// the search's entry and done responses
+	assert.Greater(h.bytesWritten[SearchRouteOperation], h.bytesWritten[BindRouteOperation])
+	assert.Zero(h.bytesWritten[UnbindRouteOperation])
+}
@@ -3,7 +3,12 @@
 
 package gldap
 
-import ber "github.com/go-asn1-ber/asn1-ber"
+import (
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
 
 // Change operation choices
 const (
@@ -21,6 +26,13 @@ type ModifyMessage struct {
 	Controls []Control
 }
 
+// PermissiveModify returns true if the message has the permissive modify
+// control, which means adding a value that already exists or deleting a value
+// that doesn't exist should not fail the modify.
+func (m *ModifyMessage) PermissiveModify() bool {
+	return findControl(m.Controls, ControlTypeMicrosoftPermissiveModify) != nil
+}
+
 // Change for a ModifyMessage as defined in https://tools.ietf.org/html/rfc4511
 type Change struct {
 	// Operation is the type of change to be made
@@ -54,3 +66,76 @@ func (p *PartialAttribute) encode() *ber.Packet {
 	seq.AppendChild(set)
 	return seq
 }
+
+// ApplyModify applies the modify request's changes to the entry using the
+// semantics of https://tools.ietf.org/html/rfc4511#section-4.6 (see:
+// ApplyChanges).  When a schema is provided, its matching rules are used to
+// compare values and the modified entry is validated against it.  The entry is
+// only modified when the request succeeds.  A *ChangeError with the result code
+// of the modify's response (i.e. ResultNoSuchAttribute,
+// ResultAttributeOrValueExists or ResultObjectClassViolation) is returned when
+// it fails.  The msg should be a request's ModifyMessage (see:
+// Request.GetModifyMessage)
+func ApplyModify(entry *Entry, msg *ModifyMessage, schema *Schema) error {
+	const op = "gldap.ApplyModify"
+	switch {
+	case entry == nil:
+		return fmt.Errorf("%s: missing entry: %w", op, ErrInvalidParameter)
+	case msg == nil:
+		return fmt.Errorf("%s: missing modify message: %w", op, ErrInvalidParameter)
+	}
+	ruleFn := AttributeMatchingRule
+	if schema != nil {
+		ruleFn = schema.MatchingRule
+	}
+	changes := make([]Change, 0, len(msg.Changes))
+	for _, ch := range msg.Changes {
+		vals, err := decodeModificationValues(ch.Modification.Vals)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, &ChangeError{ResultCode: ResultProtocolError, Change: ch, Msg: err.Error()})
+		}
+		if schema != nil {
+			if at, ok := schema.attributeType(ch.Modification.Type); ok && at.NoUserModification {
+				return fmt.Errorf("%s: %w", op, &ChangeError{ResultCode: ResultConstraintViolation, Change: ch, Msg: fmt.Sprintf("attribute %q can't be modified", ch.Modification.Type)})
+			}
+		}
+		changes = append(changes, Change{
+			Operation:    ch.Operation,
+			Modification: PartialAttribute{Type: ch.Modification.Type, Vals: vals},
+		})
+	}
+	attrs, err := applyChanges(entry.Attributes, changes, ruleFn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rdnRetained(entry.DN, attrs, ruleFn); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if schema != nil {
+		if err := schema.validateEntry(attrs); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	entry.Attributes = attrs
+	return nil
+}
+
+// rdnRetained returns a *ChangeError with a result code of
+// ResultNotAllowedOnRDN when the attributes no longer have the values of the
+// entry's RDN (see: https://tools.ietf.org/html/rfc4511#section-4.6)
+func rdnRetained(dn string, attrs []*EntryAttribute, ruleFn func(name string) MatchingRule) error {
+	if dn == "" {
+		return nil
+	}
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return nil
+	}
+	for _, rdn := range parsed.RDNs[0].Attributes {
+		a := findAttribute(attrs, rdn.Type)
+		if a == nil || !containsValue(ruleFn(rdn.Type), a.Values, rdn.Value) {
+			return &ChangeError{ResultCode: ResultNotAllowedOnRDN, Msg: fmt.Sprintf("can't remove RDN value %s=%s", rdn.Type, rdn.Value)}
+		}
+	}
+	return nil
+}
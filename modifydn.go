@@ -0,0 +1,135 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ModifyDNMessage is a modify DN request message, which renames an entry or
+// moves it (and its subordinates) to a new superior (see:
+// https://tools.ietf.org/html/rfc4511#section-4.9)
+type ModifyDNMessage struct {
+	baseMessage
+	// DN of the entry being renamed or moved
+	DN string
+	// NewRDN is the new RDN of the entry
+	NewRDN string
+	// DeleteOldRDN is true if the values of the entry's old RDN should be
+	// deleted from the entry
+	DeleteOldRDN bool
+	// NewSuperior is the optional DN of the entry's new parent, which is empty
+	// when the entry is only being renamed
+	NewSuperior string
+	// Controls hold optional controls to send with the request
+	Controls []Control
+}
+
+// ApplyModifyDN applies the modify DN request to the entries of a directory
+// tree using the semantics of https://tools.ietf.org/html/rfc4511#section-4.9.
+// The entry is renamed to the request's NewRDN and, when the request has a
+// NewSuperior, moved along with all of its subordinates.  The values of the new
+// RDN are added to the entry and the values of the old RDN are deleted when
+// DeleteOldRDN is true.  DNs are compared case-insensitively.  The entries are
+// only modified when the request succeeds.  A *ChangeError with the result code
+// of the modify DN's response (i.e. ResultNoSuchObject or
+// ResultEntryAlreadyExists) is returned when it fails.  The msg should be a
+// request's ModifyDNMessage (see: Request.GetModifyDNMessage)
+func ApplyModifyDN(tree []*Entry, msg *ModifyDNMessage) error {
+	const op = "gldap.ApplyModifyDN"
+	if msg == nil {
+		return fmt.Errorf("%s: missing modify dn message: %w", op, ErrInvalidParameter)
+	}
+	changeErr := func(code int, format string, a ...interface{}) error {
+		return fmt.Errorf("%s: %w", op, &ChangeError{ResultCode: code, Msg: fmt.Sprintf(format, a...)})
+	}
+	oldDN, err := ldap.ParseDN(msg.DN)
+	if err != nil || len(oldDN.RDNs) == 0 {
+		return changeErr(ResultInvalidDNSyntax, "invalid dn %q", msg.DN)
+	}
+	newRDN, err := ldap.ParseDN(msg.NewRDN)
+	if err != nil || len(newRDN.RDNs) != 1 {
+		return changeErr(ResultInvalidDNSyntax, "invalid new rdn %q", msg.NewRDN)
+	}
+	superior := &ldap.DN{RDNs: oldDN.RDNs[1:]}
+	if msg.NewSuperior != "" {
+		if superior, err = ldap.ParseDN(msg.NewSuperior); err != nil {
+			return changeErr(ResultInvalidDNSyntax, "invalid new superior %q", msg.NewSuperior)
+		}
+		if oldDN.EqualFold(superior) || oldDN.AncestorOfFold(superior) {
+			return changeErr(ResultUnwillingToPerform, "can't move %q beneath itself", msg.DN)
+		}
+	}
+	newDN := &ldap.DN{RDNs: append([]*ldap.RelativeDN{newRDN.RDNs[0]}, superior.RDNs...)}
+
+	var entry *Entry
+	var subordinates []*Entry
+	superiorFound := msg.NewSuperior == "" || len(superior.RDNs) == 0
+	for _, e := range tree {
+		dn, err := ldap.ParseDN(e.DN)
+		if err != nil {
+			continue
+		}
+		switch {
+		case dn.EqualFold(oldDN):
+			entry = e
+		case oldDN.AncestorOfFold(dn):
+			subordinates = append(subordinates, e)
+		case dn.EqualFold(newDN):
+			return changeErr(ResultEntryAlreadyExists, "entry %q already exists", newDN.String())
+		}
+		if dn.EqualFold(superior) {
+			superiorFound = true
+		}
+	}
+	switch {
+	case entry == nil:
+		return fmt.Errorf("%s: %w", op, &ChangeError{ResultCode: ResultNoSuchObject, Msg: fmt.Sprintf("no such entry %q", msg.DN), MatchedDN: MatchedDN(msg.DN, tree)})
+	case !superiorFound:
+		return fmt.Errorf("%s: %w", op, &ChangeError{ResultCode: ResultNoSuchObject, Msg: fmt.Sprintf("no such new superior %q", msg.NewSuperior), MatchedDN: MatchedDN(msg.NewSuperior, tree)})
+	}
+
+	var changes []Change
+	if msg.DeleteOldRDN {
+		for _, rdn := range oldDN.RDNs[0].Attributes {
+			a := findAttribute(entry.Attributes, rdn.Type)
+			if a == nil || rdnHasValue(newRDN.RDNs[0], rdn.Type, rdn.Value) || !containsValue(AttributeMatchingRule(rdn.Type), a.Values, rdn.Value) {
+				continue
+			}
+			changes = append(changes, Change{Operation: DeleteAttribute, Modification: PartialAttribute{Type: rdn.Type, Vals: []string{rdn.Value}}})
+		}
+	}
+	for _, rdn := range newRDN.RDNs[0].Attributes {
+		if a := findAttribute(entry.Attributes, rdn.Type); a != nil && containsValue(AttributeMatchingRule(rdn.Type), a.Values, rdn.Value) {
+			continue
+		}
+		changes = append(changes, Change{Operation: AddAttribute, Modification: PartialAttribute{Type: rdn.Type, Vals: []string{rdn.Value}}})
+	}
+	attrs, err := applyChanges(entry.Attributes, changes, AttributeMatchingRule)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, e := range subordinates {
+		dn, _ := ldap.ParseDN(e.DN)
+		rdns := append([]*ldap.RelativeDN{}, dn.RDNs[:len(dn.RDNs)-len(oldDN.RDNs)]...)
+		e.DN = (&ldap.DN{RDNs: append(rdns, newDN.RDNs...)}).String()
+	}
+	entry.DN = newDN.String()
+	entry.Attributes = attrs
+	return nil
+}
+
+// rdnHasValue returns true if the rdn has the attribute value
+func rdnHasValue(rdn *ldap.RelativeDN, attrType, value string) bool {
+	for _, a := range rdn.Attributes {
+		if strings.EqualFold(a.Type, attrType) && AttributeMatchingRule(attrType).Equal(a.Value, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testModifyDNTree() []*Entry {
+	return []*Entry{
+		NewEntry("dc=example,dc=org", map[string][]string{"dc": {"example"}}),
+		NewEntry("ou=people,dc=example,dc=org", map[string][]string{"ou": {"people"}}),
+		NewEntry("ou=staff,dc=example,dc=org", map[string][]string{"ou": {"staff"}}),
+		NewEntry("cn=alice,ou=people,dc=example,dc=org", map[string][]string{"cn": {"alice", "alice smith"}}),
+		NewEntry("ou=devices,cn=alice,ou=people,dc=example,dc=org", map[string][]string{"ou": {"devices"}}),
+		NewEntry("cn=laptop,ou=devices,cn=alice,ou=people,dc=example,dc=org", map[string][]string{"cn": {"laptop"}}),
+		NewEntry("cn=bob,ou=people,dc=example,dc=org", map[string][]string{"cn": {"bob"}}),
+	}
+}
+
+func TestApplyModifyDN(t *testing.T) {
+	tests := []struct {
+		name          string
+		msg           *ModifyDNMessage
+		wantDNs       []string
+		wantCN        []string
+		wantCode      int
+		wantMatchedDN string
+		wantErr       bool
+	}{
+		{
+			name:    "rename",
+			msg:     &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=carol"},
+			wantDNs: []string{"cn=carol,ou=people,dc=example,dc=org", "ou=devices,cn=carol,ou=people,dc=example,dc=org", "cn=laptop,ou=devices,cn=carol,ou=people,dc=example,dc=org"},
+			wantCN:  []string{"alice", "alice smith", "carol"},
+		},
+		{
+			name:    "rename-delete-old-rdn",
+			msg:     &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=carol", DeleteOldRDN: true},
+			wantDNs: []string{"cn=carol,ou=people,dc=example,dc=org"},
+			wantCN:  []string{"alice smith", "carol"},
+		},
+		{
+			name:    "rename-existing-value",
+			msg:     &ModifyDNMessage{DN: "CN=Alice,ou=people,dc=example,dc=org", NewRDN: "cn=Alice Smith", DeleteOldRDN: true},
+			wantDNs: []string{"cn=Alice Smith,ou=people,dc=example,dc=org"},
+			wantCN:  []string{"alice smith"},
+		},
+		{
+			name:    "move-subtree",
+			msg:     &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=alice", DeleteOldRDN: true, NewSuperior: "ou=staff,dc=example,dc=org"},
+			wantDNs: []string{"cn=alice,ou=staff,dc=example,dc=org", "ou=devices,cn=alice,ou=staff,dc=example,dc=org", "cn=laptop,ou=devices,cn=alice,ou=staff,dc=example,dc=org"},
+			wantCN:  []string{"alice", "alice smith"},
+		},
+		{
+			name:          "no-such-entry",
+			msg:           &ModifyDNMessage{DN: "cn=eve,ou=people,dc=example,dc=org", NewRDN: "cn=carol"},
+			wantErr:       true,
+			wantCode:      ResultNoSuchObject,
+			wantMatchedDN: "ou=people,dc=example,dc=org",
+		},
+		{
+			name:          "no-such-superior",
+			msg:           &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=alice", NewSuperior: "ou=groups,dc=example,dc=org"},
+			wantErr:       true,
+			wantCode:      ResultNoSuchObject,
+			wantMatchedDN: "dc=example,dc=org",
+		},
+		{
+			name:     "already-exists",
+			msg:      &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "CN=Bob"},
+			wantErr:  true,
+			wantCode: ResultEntryAlreadyExists,
+		},
+		{
+			name:     "move-beneath-itself",
+			msg:      &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=alice", NewSuperior: "ou=devices,cn=alice,ou=people,dc=example,dc=org"},
+			wantErr:  true,
+			wantCode: ResultUnwillingToPerform,
+		},
+		{
+			name:     "invalid-new-rdn",
+			msg:      &ModifyDNMessage{DN: "cn=alice,ou=people,dc=example,dc=org", NewRDN: "cn=carol,ou=people"},
+			wantErr:  true,
+			wantCode: ResultInvalidDNSyntax,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			tree := testModifyDNTree()
+			err := ApplyModifyDN(tree, tc.msg)
+			if tc.wantErr {
+				require.Error(err)
+				var changeErr *ChangeError
+				require.ErrorAs(err, &changeErr)
+				assert.Equal(tc.wantCode, changeErr.ResultCode)
+				assert.Equal(tc.wantMatchedDN, changeErr.MatchedDN)
+				assert.Equal(testModifyDNTree(), tree)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.wantDNs[0], tree[3].DN)
+			assert.Equal(tc.wantCN, tree[3].GetAttributeValues("cn"))
+			for i, dn := range tc.wantDNs[1:] {
+				assert.Equal(dn, tree[4+i].DN)
+			}
+			assert.Equal("cn=bob,ou=people,dc=example,dc=org", tree[6].DN)
+		})
+	}
+	t.Run("missing-message", func(t *testing.T) {
+		assert.ErrorIs(t, ApplyModifyDN(testModifyDNTree(), nil), ErrInvalidParameter)
+	})
+}
+
+func TestMux_ModifyDN(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	tree := testModifyDNTree()
+	require.NoError(mux.ModifyDN(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetModifyDNMessage()
+		if err != nil {
+			_ = w.Write(r.NewModifyDNResponse(WithResponseCode(ResultProtocolError)))
+			return
+		}
+		if err := ApplyModifyDN(tree, m); err != nil {
+			_ = w.Write(r.NewModifyDNResponse(WithResponseCode(ResultOperationsError), WithDiagnosticMessage(err.Error())))
+			return
+		}
+		_ = w.Write(r.NewModifyDNResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux)
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	require.NoError(client.ModifyDN(ldap.NewModifyDNRequest("cn=alice,ou=people,dc=example,dc=org", "cn=alice", true, "ou=staff,dc=example,dc=org")))
+	assert.Equal("cn=alice,ou=staff,dc=example,dc=org", tree[3].DN)
+	assert.Error(client.ModifyDN(ldap.NewModifyDNRequest("cn=alice,ou=people,dc=example,dc=org", "cn=carol", true, "")))
+
+	assert.ErrorIs(mux.ModifyDN(nil), ErrInvalidParameter)
+}
@@ -0,0 +1,394 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// MonitorBaseDN is the base DN of the monitor backend's entries (see:
+// WithMonitor)
+const MonitorBaseDN = "cn=Monitor"
+
+// monitorTimeFormat is the generalized time format of the monitor's timestamps
+const monitorTimeFormat = "20060102150405Z"
+
+// monitorOperations are the operations reported by the monitor in the order
+// they're reported, along with the RDN value of their entries.
+var monitorOperations = []struct {
+	op   RouteOperation
+	name string
+}{
+	{BindRouteOperation, "Bind"},
+	{UnbindRouteOperation, "Unbind"},
+	{SearchRouteOperation, "Search"},
+	{ModifyRouteOperation, "Modify"},
+	{ModifyDNRouteOperation, "Modrdn"},
+	{AddRouteOperation, "Add"},
+	{DeleteRouteOperation, "Delete"},
+	{AbandonRouteOperation, "Abandon"},
+	{ExtendedRouteOperation, "Extended"},
+}
+
+// serverStats are the server statistics reported by the monitor backend.  A
+// nil *serverStats is valid and doesn't track anything.
+type serverStats struct {
+	mu           sync.Mutex
+	clock        Clock
+	startTime    time.Time
+	totalConns   int64
+	currentConns int64
+	opsInitiated map[RouteOperation]int64
+	opsCompleted map[RouteOperation]int64
+
+	// requestSizes and responseSizes are the encoded sizes of the requests
+	// read and responses written for each operation.
+	requestSizes  map[RouteOperation]*sizeHistogram
+	responseSizes map[RouteOperation]*sizeHistogram
+
+	// unknownControls are the number of unknown controls read by their type
+	// and criticality (see: CriticalControlPolicy).  The types are supplied by
+	// clients, so at most maxUnknownControlTypes of them are tracked and the
+	// rest are counted as otherControlType.
+	unknownControls map[unknownControlKey]int64
+}
+
+const (
+	// maxUnknownControlTypes is the maximum number of distinct unknown control
+	// types tracked by the server's stats
+	maxUnknownControlTypes = 32
+
+	// otherControlType is the type the unknown controls are counted as once
+	// maxUnknownControlTypes have been tracked
+	otherControlType = "other"
+)
+
+// unknownControlKey is the key of the unknown controls' stats
+type unknownControlKey struct {
+	controlType string
+	critical    bool
+}
+
+func newServerStats(clock Clock) *serverStats {
+	return &serverStats{
+		clock:           clock,
+		startTime:       clock.Now(),
+		opsInitiated:    map[RouteOperation]int64{},
+		opsCompleted:    map[RouteOperation]int64{},
+		requestSizes:    map[RouteOperation]*sizeHistogram{},
+		responseSizes:   map[RouteOperation]*sizeHistogram{},
+		unknownControls: map[unknownControlKey]int64{},
+	}
+}
+
+func (s *serverStats) connOpened() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totalConns++
+	s.currentConns++
+}
+
+func (s *serverStats) connClosed() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentConns--
+}
+
+func (s *serverStats) opInitiated(op RouteOperation) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opsInitiated[op]++
+}
+
+func (s *serverStats) opCompleted(op RouteOperation) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opsCompleted[op]++
+}
+
+// opsInFlight returns the number of operations which were initiated and
+// haven't completed, by operation
+func (s *serverStats) opsInFlight() map[RouteOperation]int64 {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inFlight := map[RouteOperation]int64{}
+	for op, n := range s.opsInitiated {
+		if n -= s.opsCompleted[op]; n > 0 {
+			inFlight[op] = n
+		}
+	}
+	return inFlight
+}
+
+func (s *serverStats) unknownControlRead(controlType string, critical bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := unknownControlKey{controlType: controlType, critical: critical}
+	if _, ok := s.unknownControls[k]; !ok && !s.unknownControlTracked(controlType) {
+		k.controlType = otherControlType
+	}
+	s.unknownControls[k]++
+}
+
+// unknownControlTracked returns true if the controlType is already tracked or
+// there's room to track it.  The caller must hold s.mu.
+func (s *serverStats) unknownControlTracked(controlType string) bool {
+	types := map[string]bool{}
+	for k := range s.unknownControls {
+		if k.controlType == controlType {
+			return true
+		}
+		if k.controlType != otherControlType {
+			types[k.controlType] = true
+		}
+	}
+	return len(types) < maxUnknownControlTypes
+}
+
+func (s *serverStats) requestRead(op RouteOperation, size int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	observeSize(s.requestSizes, op, size)
+}
+
+func (s *serverStats) responseWritten(op RouteOperation, size int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	observeSize(s.responseSizes, op, size)
+}
+
+// entries returns the monitor's entries for the stats and the router's routes,
+// which are reported as the server's backends.  The entries mirror the
+// layout of OpenLDAP's monitor backend (see:
+// https://www.openldap.org/doc/admin26/monitoringslapd.html)
+func (s *serverStats) entries(router *Mux) []*Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	itoa := func(i int64) []string { return []string{strconv.FormatInt(i, 10)} }
+	dn := func(rdns ...string) string {
+		return strings.Join(append(rdns, MonitorBaseDN), ",")
+	}
+
+	entries := []*Entry{
+		NewEntry(MonitorBaseDN, map[string][]string{
+			"objectClass":   {"monitorServer"},
+			"cn":            {"Monitor"},
+			"monitoredInfo": {"gldap"},
+		}),
+		NewEntry(dn("cn=Connections"), map[string][]string{
+			"objectClass": {"monitorContainer"},
+			"cn":          {"Connections"},
+		}),
+		NewEntry(dn("cn=Total", "cn=Connections"), map[string][]string{
+			"objectClass":    {"monitorCounterObject"},
+			"cn":             {"Total"},
+			"monitorCounter": itoa(s.totalConns),
+		}),
+		NewEntry(dn("cn=Current", "cn=Connections"), map[string][]string{
+			"objectClass":    {"monitorCounterObject"},
+			"cn":             {"Current"},
+			"monitorCounter": itoa(s.currentConns),
+		}),
+	}
+
+	var totalInitiated, totalCompleted int64
+	opEntries := make([]*Entry, 0, len(monitorOperations))
+	for _, o := range monitorOperations {
+		totalInitiated += s.opsInitiated[o.op]
+		totalCompleted += s.opsCompleted[o.op]
+		opEntries = append(opEntries, NewEntry(dn("cn="+o.name, "cn=Operations"), map[string][]string{
+			"objectClass":        {"monitorOperation"},
+			"cn":                 {o.name},
+			"monitorOpInitiated": itoa(s.opsInitiated[o.op]),
+			"monitorOpCompleted": itoa(s.opsCompleted[o.op]),
+		}))
+	}
+	entries = append(entries, NewEntry(dn("cn=Operations"), map[string][]string{
+		"objectClass":        {"monitorOperation"},
+		"cn":                 {"Operations"},
+		"monitorOpInitiated": itoa(totalInitiated),
+		"monitorOpCompleted": itoa(totalCompleted),
+	}))
+	entries = append(entries, opEntries...)
+
+	entries = append(entries, NewEntry(dn("cn=Backends"), map[string][]string{
+		"objectClass": {"monitorContainer"},
+		"cn":          {"Backends"},
+	}))
+	if router != nil {
+		router.mu.Lock()
+		routes := append([]route{}, router.routes...)
+		router.mu.Unlock()
+		for i, r := range routes {
+			name := fmt.Sprintf("Backend %d", i)
+			attrs := map[string][]string{
+				"objectClass":   {"monitoredObject"},
+				"cn":            {name},
+				"monitoredInfo": {string(r.op())},
+			}
+			switch v := r.(type) {
+			case *searchRoute:
+				if v.basedn != "" {
+					attrs["namingContexts"] = []string{v.basedn}
+				}
+				if v.baseDNSuffix != "" {
+					attrs["namingContexts"] = append(attrs["namingContexts"], v.baseDNSuffix)
+				}
+				if len(v.suffixes) > 0 {
+					attrs["namingContexts"] = append(attrs["namingContexts"], v.suffixes...)
+				}
+			case *extendedRoute:
+				attrs["supportedExtension"] = []string{string(v.extendedName)}
+			}
+			if b, ok := r.(interface{ routeLabel() string }); ok && b.routeLabel() != "" {
+				attrs["description"] = []string{b.routeLabel()}
+			}
+			entries = append(entries, NewEntry(dn("cn="+name, "cn=Backends"), attrs))
+		}
+	}
+
+	entries = append(entries,
+		NewEntry(dn("cn=Time"), map[string][]string{
+			"objectClass": {"monitorContainer"},
+			"cn":          {"Time"},
+		}),
+		NewEntry(dn("cn=Start", "cn=Time"), map[string][]string{
+			"objectClass":      {"monitoredObject"},
+			"cn":               {"Start"},
+			"monitorTimestamp": {s.startTime.UTC().Format(monitorTimeFormat)},
+		}),
+		NewEntry(dn("cn=Current", "cn=Time"), map[string][]string{
+			"objectClass":      {"monitoredObject"},
+			"cn":               {"Current"},
+			"monitorTimestamp": {s.clock.Now().UTC().Format(monitorTimeFormat)},
+		}),
+	)
+	return entries
+}
+
+// isSubtreeSearch returns true if the request is a search of the subtree
+// rooted at the baseDN (i.e. a search of the monitor's entries).
+func isSubtreeSearch(r *Request, baseDN string) bool {
+	m, ok := r.message.(*SearchMessage)
+	if !ok {
+		return false
+	}
+	ok, err := dnInScope(m.BaseDN, baseDN, WholeSubtree)
+	return err == nil && ok
+}
+
+// serveMonitor responds to a search of the monitor's entries.
+func (c *conn) serveMonitor(w *ResponseWriter, r *Request) {
+	c.serveEntries(w, r, c.stats.entries(c.currentRouter()))
+}
+
+// serveEntries responds to a search of the entries of a server-managed subtree
+// (i.e. cn=Monitor).  A search of an entry that doesn't exist is responded to
+// with the matchedDN of its closest existing ancestor.
+func (c *conn) serveEntries(w *ResponseWriter, r *Request, entries []*Entry) {
+	const op = "gldap.(Conn).serveEntries"
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		c.logger.Error("not a search message", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "err", err)
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultOperationsError)))
+		return
+	}
+	filter := m.Filter
+	if filter == "" {
+		filter = "(objectClass=*)"
+	}
+	var found bool
+	for _, e := range entries {
+		if ok, _ := dnInScope(e.DN, m.BaseDN, BaseObject); ok {
+			found = true
+		}
+		if ok, err := dnInScope(e.DN, m.BaseDN, m.Scope); err != nil || !ok {
+			continue
+		}
+		ok, err := e.MatchFilter(filter)
+		if err != nil {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultProtocolError), WithDiagnosticMessage(err.Error())))
+			return
+		}
+		if !ok {
+			continue
+		}
+		if err := w.Write(r.NewSearchResponseEntry(e.DN, WithAttributes(selectAttributes(e, m.RequestedAttributes())))); err != nil {
+			c.logger.Error("unable to write entry", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "err", err)
+			return
+		}
+	}
+	if !found {
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultNoSuchObject), WithMatchedDN(MatchedDN(m.BaseDN, entries))))
+		return
+	}
+	_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+}
+
+// dnInScope returns true if the dn is within the scope of the baseDN.  DNs are
+// compared case-insensitively.
+func dnInScope(dn, baseDN string, scope Scope) (bool, error) {
+	const op = "gldap.dnInScope"
+	d, err := ldap.ParseDN(dn)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid dn %q: %w", op, dn, err)
+	}
+	base, err := ldap.ParseDN(baseDN)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid base dn %q: %w", op, baseDN, err)
+	}
+	switch scope {
+	case BaseObject:
+		return d.EqualFold(base), nil
+	case SingleLevel:
+		return len(d.RDNs) == len(base.RDNs)+1 && base.AncestorOfFold(d), nil
+	case WholeSubtree:
+		return d.EqualFold(base) || base.AncestorOfFold(d), nil
+	case SubordinateSubtree:
+		return base.AncestorOfFold(d), nil
+	default:
+		return false, fmt.Errorf("%s: invalid scope %d: %w", op, scope, ErrInvalidParameter)
+	}
+}
+
+// selectAttributes returns the entry's attributes requested by a search.
+func selectAttributes(e *Entry, requested SearchAttributes) map[string][]string {
+	selected := make(map[string][]string, len(e.Attributes))
+	for _, a := range e.Attributes {
+		if requested.Wants(a.Name) {
+			selected[a.Name] = a.Values
+		}
+	}
+	return selected
+}
@@ -0,0 +1,142 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WithMonitor(t *testing.T) {
+	t.Parallel()
+
+	startServer := func(t *testing.T, opt ...ServerOption) *ldap.Conn {
+		t.Helper()
+		require := require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}))
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultUnwillingToPerform)))
+		}, WithBaseDN("dc=example,dc=org"), WithLabel("example")))
+		require.NoError(mux.DefaultRoute(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultUnwillingToPerform)))
+		}))
+		_, url := testServer(t, mux, opt...)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		t.Cleanup(func() { _ = client.Close() })
+		return client
+	}
+	search := func(client *ldap.Conn, baseDN string, scope int, filter string, attrs ...string) (*ldap.SearchResult, error) {
+		return client.Search(ldap.NewSearchRequest(baseDN, scope, ldap.NeverDerefAliases, 0, 0, false, filter, attrs, nil))
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		client := startServer(t, WithMonitor())
+		require.NoError(client.Bind("cn=alice", "password"))
+
+		res, err := search(client, "cn=monitor", ldap.ScopeBaseObject, "(objectClass=*)")
+		require.NoError(err)
+		require.Len(res.Entries, 1)
+		assert.Equal("gldap", res.Entries[0].GetAttributeValue("monitoredInfo"))
+
+		res, err = search(client, "cn=Connections,cn=Monitor", ldap.ScopeSingleLevel, "(cn=Current)", "monitorCounter")
+		require.NoError(err)
+		require.Len(res.Entries, 1)
+		assert.Equal("cn=Current,cn=Connections,cn=Monitor", res.Entries[0].DN)
+		assert.Equal("1", res.Entries[0].GetAttributeValue("monitorCounter"))
+		assert.Empty(res.Entries[0].GetAttributeValue("cn"))
+
+		// the bind is completed after its response is written
+		require.Eventually(func() bool {
+			res, err = search(client, "cn=Bind,cn=Operations,cn=Monitor", ldap.ScopeBaseObject, "(objectClass=*)")
+			require.NoError(err)
+			require.Len(res.Entries, 1)
+			return res.Entries[0].GetAttributeValue("monitorOpCompleted") == "1"
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal("1", res.Entries[0].GetAttributeValue("monitorOpInitiated"))
+
+		res, err = search(client, "cn=Operations,cn=Monitor", ldap.ScopeBaseObject, "(monitorOpInitiated>=4)")
+		require.NoError(err)
+		assert.Len(res.Entries, 1)
+
+		res, err = search(client, "cn=Backends,cn=Monitor", ldap.ScopeWholeSubtree, "(namingContexts=dc=example,dc=org)")
+		require.NoError(err)
+		require.Len(res.Entries, 1)
+		assert.Equal("cn=Backend 1,cn=Backends,cn=Monitor", res.Entries[0].DN)
+		assert.Equal("search", res.Entries[0].GetAttributeValue("monitoredInfo"))
+		assert.Equal("example", res.Entries[0].GetAttributeValue("description"))
+
+		res, err = search(client, "cn=Monitor", ldap.ScopeWholeSubtree, "(objectClass=monitorOperation)")
+		require.NoError(err)
+		assert.Len(res.Entries, len(monitorOperations)+1)
+
+		_, err = search(client, "cn=missing,cn=Monitor", ldap.ScopeBaseObject, "(objectClass=*)")
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject))
+
+		_, err = search(client, "cn=missing,cn=Operations,cn=Monitor", ldap.ScopeBaseObject, "(objectClass=*)")
+		var ldapErr *ldap.Error
+		require.ErrorAs(err, &ldapErr)
+		assert.Equal("cn=Operations,cn=Monitor", ldapErr.MatchedDN)
+
+		// other searches are still routed
+		_, err = search(client, "dc=example,dc=org", ldap.ScopeBaseObject, "(objectClass=*)")
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		client := startServer(t)
+		_, err := search(client, "cn=Monitor", ldap.ScopeBaseObject, "(objectClass=*)")
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform))
+	})
+}
+
+func Test_dnInScope(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		dn              string
+		baseDN          string
+		scope           Scope
+		want            bool
+		wantErrContains string
+	}{
+		{name: "base", dn: "cn=Monitor", baseDN: "CN=monitor", scope: BaseObject, want: true},
+		{name: "base-child", dn: "cn=Time,cn=Monitor", baseDN: "cn=Monitor", scope: BaseObject},
+		{name: "one", dn: "cn=Time,cn=Monitor", baseDN: "cn=Monitor", scope: SingleLevel, want: true},
+		{name: "one-base", dn: "cn=Monitor", baseDN: "cn=Monitor", scope: SingleLevel},
+		{name: "one-grandchild", dn: "cn=Start,cn=Time,cn=Monitor", baseDN: "cn=Monitor", scope: SingleLevel},
+		{name: "sub", dn: "cn=Start,cn=Time,cn=Monitor", baseDN: "cn=Monitor", scope: WholeSubtree, want: true},
+		{name: "sub-base", dn: "cn=Monitor", baseDN: "cn=Monitor", scope: WholeSubtree, want: true},
+		{name: "sub-other", dn: "dc=example,dc=org", baseDN: "cn=Monitor", scope: WholeSubtree},
+		{name: "invalid-dn", dn: "invalid", baseDN: "cn=Monitor", scope: WholeSubtree, wantErrContains: "invalid dn"},
+		{name: "children", dn: "cn=Start,cn=Time,cn=Monitor", baseDN: "cn=Monitor", scope: SubordinateSubtree, want: true},
+		{name: "children-base", dn: "cn=Monitor", baseDN: "cn=Monitor", scope: SubordinateSubtree},
+		{name: "invalid-scope", dn: "cn=Monitor", baseDN: "cn=Monitor", scope: 4, wantErrContains: "invalid scope"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := dnInScope(tc.dn, tc.baseDN, tc.scope)
+			if tc.wantErrContains != "" {
+				require.Error(err)
+				assert.Contains(err.Error(), tc.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// mount is a backend mounted at a suffix of a mux's tree (see: Mux.Mount)
+type mount struct {
+	suffix  string
+	dn      *ldap.DN
+	backend *Mux
+}
+
+// Mount mounts the backend mux at the suffix (i.e. a naming context like
+// "ou=people,dc=example,dc=org"), which composes several backends (i.e. an
+// in-memory directory for ou=test, a database for ou=people and a proxy for
+// ou=corp) into one directory tree.  A request whose target DN (i.e. a bind's
+// name, a search's base DN or the DN of the entry being modified, added or
+// deleted) is within the suffix's subtree is served by the backend's routes,
+// rather than the mux's.  When backends are mounted at nested suffixes, the
+// backend with the longest matching suffix serves the request.  A search whose
+// scope includes the suffixes of backends mounted below its base DN is served
+// by each of them as well, and their results are aggregated into one response
+// whose result is the first failure other than noSuchObject, or success when
+// any of the backends succeeded.  Requests
+// without a target DN (i.e. extended operations) and unbind requests are
+// served by the mux's routes.  The mux's middlewares are applied to the
+// requests served by a backend, before the backend's own middlewares.  DNs are
+// compared case-insensitively.
+//
+//	_ = mux.Mount("ou=test,dc=example,dc=org", memoryBackend)
+//	_ = mux.Mount("ou=corp,dc=example,dc=org", proxyBackend)
+func (m *Mux) Mount(suffix string, backend *Mux) error {
+	const op = "gldap.(Mux).Mount"
+	switch {
+	case suffix == "":
+		return fmt.Errorf("%s: missing suffix: %w", op, ErrInvalidParameter)
+	case backend == nil:
+		return fmt.Errorf("%s: missing backend: %w", op, ErrInvalidParameter)
+	}
+	if m.parent != nil {
+		return m.parent.Mount(suffix, backend)
+	}
+	if backend == m || backend.parent == m {
+		return fmt.Errorf("%s: a mux can't be mounted on itself: %w", op, ErrInvalidParameter)
+	}
+	dn, err := ldap.ParseDN(suffix)
+	if err != nil {
+		return fmt.Errorf("%s: invalid suffix %q: %w", op, suffix, ErrInvalidParameter)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, mt := range m.mounts {
+		if mt.dn.EqualFold(dn) {
+			return fmt.Errorf("%s: a backend is already mounted at %q: %w", op, suffix, ErrInvalidParameter)
+		}
+	}
+	m.mounts = append(m.mounts, mount{suffix: suffix, dn: dn, backend: backend})
+	return nil
+}
+
+// mountFor returns the backend mounted at the longest suffix that contains
+// the request's target DN, or nil when the request isn't within a mounted
+// backend's subtree.
+func (m *Mux) mountFor(req *Request) *Mux {
+	m.mu.Lock()
+	mounts := m.mounts
+	m.mu.Unlock()
+	if len(mounts) == 0 || req.routeOp == UnbindRouteOperation {
+		return nil
+	}
+	target, ok := req.targetDN()
+	if !ok {
+		return nil
+	}
+	dn, err := ldap.ParseDN(target)
+	if err != nil {
+		return nil
+	}
+	var found *mount
+	for i, mt := range mounts {
+		if !mt.dn.EqualFold(dn) && !mt.dn.AncestorOfFold(dn) {
+			continue
+		}
+		if found == nil || len(mt.dn.RDNs) > len(found.dn.RDNs) {
+			found = &mounts[i]
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return found.backend
+}
+
+// subordinateMounts returns the mounts whose suffixes are within the scope of
+// a search request, below its base DN, so their backends must be searched
+// along with the backend that serves the base DN (see: serveComposedSearch)
+func (m *Mux) subordinateMounts(req *Request) []mount {
+	if req.routeOp != SearchRouteOperation {
+		return nil
+	}
+	m.mu.Lock()
+	mounts := m.mounts
+	m.mu.Unlock()
+	if len(mounts) == 0 {
+		return nil
+	}
+	sm, ok := req.message.(*SearchMessage)
+	if !ok || sm.Scope == BaseObject {
+		return nil
+	}
+	base, err := ldap.ParseDN(sm.BaseDN)
+	if err != nil {
+		return nil
+	}
+	var subs []mount
+	for _, mt := range mounts {
+		if !base.AncestorOfFold(mt.dn) {
+			continue
+		}
+		if sm.Scope == SingleLevel && len(mt.dn.RDNs) != len(base.RDNs)+1 {
+			continue
+		}
+		subs = append(subs, mt)
+	}
+	return subs
+}
+
+// serveComposedSearch serves a search whose scope spans the subordinate
+// mounts, so a composed tree behaves like a single directory.  The search is
+// served by the backend of its base DN (or the mux's routes) and then by each
+// subordinate backend, whose search is based at its suffix: a single level
+// search is a base object search of the suffix, and a subtree search is a
+// subtree search of the suffix.  The entries, references and intermediate
+// responses of every backend are written as they're received, and one
+// SearchResponseDone is written once they're all done.  Its result is the first
+// failure other than noSuchObject when there's one (i.e. a sizeLimitExceeded),
+// success when any of the backends succeeded, and noSuchObject otherwise.  The
+// search's size limit applies to the aggregated entries.
+func (m *Mux) serveComposedSearch(w *ResponseWriter, req *Request, subs []mount) {
+	cs := &composedSearch{}
+	if sm, ok := req.message.(*SearchMessage); ok {
+		cs.sizeLimit = sm.SizeLimit
+	}
+	cw := w.WithInterceptor(cs.intercept)
+	m.serveMounted(cw, req)
+	cs.mu.Lock()
+	cs.primaryDone = true
+	cs.mu.Unlock()
+	for _, sub := range subs {
+		if cs.sizeLimited() || req.Context().Err() != nil {
+			break
+		}
+		scope := WholeSubtree
+		if sm := req.message.(*SearchMessage); sm.Scope == SingleLevel {
+			scope = BaseObject
+		}
+		m.chain(sub.backend.serve)(cw, req.withSearchBase(sub.suffix, scope))
+	}
+	_ = w.Write(cs.done(req))
+}
+
+// composedSearch aggregates the responses of the backends serving a composed
+// search (see: serveComposedSearch)
+type composedSearch struct {
+	mu          sync.Mutex
+	sizeLimit   int64
+	entries     int64
+	primaryDone bool
+
+	// primary is the done response of the backend serving the base DN, which
+	// is the done response of the composed search
+	primary *SearchResponseDone
+	// failure is the first done response with a failure other than
+	// noSuchObject
+	failure   *SearchResponseDone
+	succeeded bool
+	limited   bool
+}
+
+// intercept drops the backends' done responses, and the entries beyond the
+// search's size limit, and records their results
+func (cs *composedSearch) intercept(r Response) Response {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	switch resp := r.(type) {
+	case *SearchResponseEntry:
+		if cs.sizeLimit > 0 && cs.entries >= cs.sizeLimit {
+			cs.limited = true
+			return nil
+		}
+		cs.entries++
+		return r
+	case *SearchResponseDone:
+		if !cs.primaryDone && cs.primary == nil {
+			cs.primary = resp
+		}
+		switch resp.resultCode() {
+		case ResultSuccess:
+			cs.succeeded = true
+		case ResultNoSuchObject:
+		default:
+			if cs.failure == nil {
+				cs.failure = resp
+			}
+		}
+		return nil
+	default:
+		return r
+	}
+}
+
+// sizeLimited returns true once the search's size limit has been exceeded
+func (cs *composedSearch) sizeLimited() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.limited
+}
+
+// done returns the composed search's done response
+func (cs *composedSearch) done(req *Request) *SearchResponseDone {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	done := cs.primary
+	if done == nil {
+		done = req.NewSearchDoneResponse(WithResponseCode(ResultNoSuchObject))
+	}
+	switch {
+	case cs.limited:
+		done.SetResultCode(ResultSizeLimitExceeded)
+		done.SetDiagnosticMessage("")
+	case cs.failure != nil:
+		done.SetResultCode(cs.failure.resultCode())
+		done.SetDiagnosticMessage(cs.failure.diagnosticMessage())
+		done.SetMatchedDN(cs.failure.matchedDN)
+	case cs.succeeded:
+		done.SetResultCode(ResultSuccess)
+		done.SetDiagnosticMessage("")
+		done.SetMatchedDN("")
+	}
+	return done
+}
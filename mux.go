@@ -4,8 +4,14 @@
 package gldap
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
 )
 
 // Mux is an ldap request multiplexer. It matches the inbound request against a
@@ -16,8 +22,32 @@ type Mux struct {
 	routes       []route
 	defaultRoute route
 	unbindRoute  route
+	middlewares  []Middleware
+
+	// defaultRoutes are the per-operation default routes, which take
+	// precedence over the defaultRoute
+	defaultRoutes map[RouteOperation]route
+
+	// parent is the mux which an inline mux created by With registers its
+	// routes with, after wrapping their handlers with its inline middlewares
+	parent *Mux
+	inline []Middleware
+
+	// suffixes are the naming contexts that the routes of an inline mux
+	// created by Group are scoped to
+	suffixes []string
+
+	// mounts are the backends that serve the requests within their suffixes
+	// (see: Mount)
+	mounts []mount
 }
 
+// Middleware wraps a HandlerFunc, so cross-cutting concerns like logging,
+// authentication checks, metrics and rate limiting can be applied to a mux's
+// routes (see: Mux.Use and Mux.With).  A middleware can respond to the request
+// itself rather than calling the next handler.
+type Middleware func(next HandlerFunc) HandlerFunc
+
 // NewMux creates a new multiplexer.
 func NewMux(opt ...Option) (*Mux, error) {
 	return &Mux{
@@ -26,25 +56,34 @@ func NewMux(opt ...Option) (*Mux, error) {
 }
 
 // Bind will register a handler for bind requests.
-// Options supported: WithLabel
-func (m *Mux) Bind(bindFn HandlerFunc, opt ...Option) error {
+// Options supported: WithLabel, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) Bind(bindFn HandlerFunc, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Bind"
 	if bindFn == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
 	opts := getRouteOpts(opt...)
 
 	r := &simpleBindRoute{
 		baseRoute: &baseRoute{
-			h:       bindFn,
-			routeOp: bindRouteOperation,
-			label:   opts.withLabel,
+			h:                      bindFn,
+			routeOp:                BindRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
 		},
 		authChoice: SimpleAuthChoice,
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.routes = append(m.routes, r)
+	m.addRoute(r)
 	return nil
 }
 
@@ -53,149 +92,396 @@ func (m *Mux) Bind(bindFn HandlerFunc, opt ...Option) error {
 // whether or not an unbind route is defined the server will stop serving
 // requests for a connection after an unbind request is received.  Options
 // supported: WithLabel
-func (m *Mux) Unbind(bindFn HandlerFunc, opt ...Option) error {
+func (m *Mux) Unbind(bindFn HandlerFunc, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Unbind"
 	if bindFn == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
 	opts := getRouteOpts(opt...)
 
 	r := &unbindRoute{
 		baseRoute: &baseRoute{
 			h:       bindFn,
-			routeOp: bindRouteOperation,
+			routeOp: UnbindRouteOperation,
 			label:   opts.withLabel,
 		},
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.unbindRoute = r
+	m.setUnbindRoute(r)
 	return nil
 }
 
 // Search will register a handler for search requests.
-// Options supported: WithLabel, WithBaseDN, WithScope
-func (m *Mux) Search(searchFn HandlerFunc, opt ...Option) error {
+// Options supported: WithLabel, WithBaseDN, WithBaseDNSuffix, WithFilter,
+// WithFilterPattern, WithScope, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality, WithSingleflight,
+// WithRouteCriticalControlPolicy
+func (m *Mux) Search(searchFn HandlerFunc, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Search"
 	if searchFn == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
 	opts := getRouteOpts(opt...)
+	var compiledFilter *ber.Packet
+	if opts.withFilter != "" {
+		f, err := ldap.CompileFilter(opts.withFilter)
+		if err != nil {
+			return fmt.Errorf("%s: invalid filter %q: %s: %w", op, opts.withFilter, err.Error(), ErrInvalidParameter)
+		}
+		compiledFilter = f
+	}
+	if opts.withSingleflight {
+		searchFn = newSearchFlight().handler(searchFn)
+	}
 	r := &searchRoute{
 		baseRoute: &baseRoute{
-			h:       searchFn,
-			routeOp: searchRouteOperation,
-			label:   opts.withLabel,
+			h:                      searchFn,
+			routeOp:                SearchRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
 		},
-		basedn: opts.withBaseDN,
-		filter: opts.withFilter,
-		scope:  opts.withScope,
+		basedn:         opts.withBaseDN,
+		baseDNSuffix:   opts.withBaseDNSuffix,
+		filter:         opts.withFilter,
+		compiledFilter: compiledFilter,
+		filterPattern:  opts.withFilterPattern,
+		scope:          opts.withScope,
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.routes = append(m.routes, r)
+	m.addRoute(r)
+	return nil
+}
+
+// RootDSE will register a handler for RootDSE search requests (a search with an
+// empty base DN and a scope of BaseObject). Routes are matched in the order
+// they're added, so a RootDSE route should be added before any Search routes
+// without a base DN. See: Personality.RootDSEHandler(...)
+// Options supported: WithLabel, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) RootDSE(rootDSEFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).RootDSE"
+	if rootDSEFn == nil {
+		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
+	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
+	opts := getRouteOpts(opt...)
+	r := &rootDSERoute{
+		baseRoute: &baseRoute{
+			h:                      rootDSEFn,
+			routeOp:                SearchRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
+		},
+	}
+	m.addRoute(r)
 	return nil
 }
 
 // ExtendedOperation will register a handler for extended operation requests.
-// Options supported: WithLabel
-func (m *Mux) ExtendedOperation(operationFn HandlerFunc, exName ExtendedOperationName, opt ...Option) error {
+// Cancel requests (ExtendedOperationCancel) are handled by the server, which
+// cancels the in-flight request's context, unless a handler is registered for
+// them.  Options supported: WithLabel, WithRouteTimeout,
+// WithRequireAuthentication, WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) ExtendedOperation(operationFn HandlerFunc, exName ExtendedOperationName, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Search"
 	if operationFn == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
 	opts := getRouteOpts(opt...)
 	r := &extendedRoute{
 		baseRoute: &baseRoute{
-			h:       operationFn,
-			routeOp: extendedRouteOperation,
-			label:   opts.withLabel,
+			h:                      operationFn,
+			routeOp:                ExtendedRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
 		},
 		extendedName: exName,
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.routes = append(m.routes, r)
+	m.addRoute(r)
 	return nil
 }
 
 // Modify will register a handler for modify operation requests.
-// Options supported: WithLabel
-func (m *Mux) Modify(modifyFn HandlerFunc, opt ...Option) error {
+// Options supported: WithLabel, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) Modify(modifyFn HandlerFunc, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Modify"
 	if modifyFn == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
 	opts := getRouteOpts(opt...)
 	r := &modifyRoute{
 		baseRoute: &baseRoute{
-			h:       modifyFn,
-			routeOp: modifyRouteOperation,
-			label:   opts.withLabel,
+			h:                      modifyFn,
+			routeOp:                ModifyRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
 		},
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.routes = append(m.routes, r)
+	m.addRoute(r)
+	return nil
+}
+
+// ModifyDN will register a handler for modify DN operation requests.
+// Options supported: WithLabel, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) ModifyDN(modifyDNFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).ModifyDN"
+	if modifyDNFn == nil {
+		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
+	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
+	opts := getRouteOpts(opt...)
+	r := &modifyDNRoute{
+		baseRoute: &baseRoute{
+			h:                      modifyDNFn,
+			routeOp:                ModifyDNRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
+		},
+	}
+	m.addRoute(r)
 	return nil
 }
 
 // Add will register a handler for add operation requests.
-// Options supported: WithLabel
-func (m *Mux) Add(addFn HandlerFunc, opt ...Option) error {
+// Options supported: WithLabel, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) Add(addFn HandlerFunc, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Add"
 	if addFn == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
 	opts := getRouteOpts(opt...)
 	r := &addRoute{
 		baseRoute: &baseRoute{
-			h:       addFn,
-			routeOp: addRouteOperation,
-			label:   opts.withLabel,
+			h:                      addFn,
+			routeOp:                AddRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
 		},
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.routes = append(m.routes, r)
+	m.addRoute(r)
 	return nil
 }
 
 // Delete will register a handler for delete operation requests.
-// Options supported: WithLabel
-func (m *Mux) Delete(modifyFn HandlerFunc, opt ...Option) error {
+// Options supported: WithLabel, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) Delete(modifyFn HandlerFunc, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Delete"
 	if modifyFn == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
 	opts := getRouteOpts(opt...)
 	r := &deleteRoute{
 		baseRoute: &baseRoute{
-			h:       modifyFn,
-			routeOp: deleteRouteOperation,
-			label:   opts.withLabel,
+			h:                      modifyFn,
+			routeOp:                DeleteRouteOperation,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
 		},
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.routes = append(m.routes, r)
+	m.addRoute(r)
+	return nil
+}
+
+// MatchFunc will register a handler for requests of the operation that the
+// matchFn returns true for, which allows routes with matching logic beyond
+// the criteria supported by the other routes (i.e. searches whose filter
+// references objectClass=posixAccount).  Routes are matched in the order
+// they're added, so the matchFn is only called for requests that didn't match
+// an earlier route.  The operation can't be UnbindRouteOperation (see:
+// Mux.Unbind) or AbandonRouteOperation, which is handled by the server.
+// Options supported: WithLabel, WithRouteTimeout, WithRequireAuthentication,
+// WithAllowedBindDNs, WithRequireConfidentiality,
+// WithRouteCriticalControlPolicy
+func (m *Mux) MatchFunc(routeOp RouteOperation, matchFn func(*Request) bool, handlerFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).MatchFunc"
+	switch {
+	case matchFn == nil:
+		return fmt.Errorf("%s: missing match func: %w", op, ErrInvalidParameter)
+	case handlerFn == nil:
+		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
+	}
+	switch routeOp {
+	case BindRouteOperation, SearchRouteOperation, ExtendedRouteOperation, ModifyRouteOperation,
+		ModifyDNRouteOperation, AddRouteOperation, DeleteRouteOperation:
+	default:
+		return fmt.Errorf("%s: unsupported route operation %q: %w", op, routeOp, ErrInvalidParameter)
+	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
+	opts := getRouteOpts(opt...)
+	r := &matchFuncRoute{
+		baseRoute: &baseRoute{
+			h:                      handlerFn,
+			routeOp:                routeOp,
+			label:                  opts.withLabel,
+			timeout:                opts.withRouteTimeout,
+			requireAuth:            opts.withRequireAuthentication,
+			allowedBindDNs:         opts.withAllowedBindDNs,
+			requireConfidentiality: opts.withRequireConfidentiality,
+			criticalControlPolicy:  opts.withCriticalControlPolicy,
+			supportedControls:      opts.withSupportedControls,
+		},
+		matchFn: matchFn,
+	}
+	m.addRoute(r)
 	return nil
 }
 
 // DefaultRoute will register a default handler requests which have no other
-// registered handler.
-func (m *Mux) DefaultRoute(noRouteFN HandlerFunc, opt ...Option) error {
+// registered handler.  An operation's own default handler (i.e. DefaultSearch)
+// takes precedence over it.
+func (m *Mux) DefaultRoute(noRouteFN HandlerFunc, opt ...RouteOption) error {
 	const op = "gldap.(Mux).Bind"
 	if noRouteFN == nil {
 		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
 	}
 	r := &baseRoute{
 		h:       noRouteFN,
-		routeOp: bindRouteOperation,
+		routeOp: BindRouteOperation,
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.defaultRoute = r
+	m.setDefaultRoute(r)
+	return nil
+}
+
+// DefaultBind will register a default handler for bind requests which have no
+// other registered handler.  It takes precedence over the DefaultRoute.
+func (m *Mux) DefaultBind(noRouteFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).DefaultBind"
+	return m.setOperationDefault(op, BindRouteOperation, noRouteFn, opt...)
+}
+
+// DefaultSearch will register a default handler for search requests which have
+// no other registered handler (i.e. to respond with ResultNoSuchObject).  It
+// takes precedence over the DefaultRoute.
+func (m *Mux) DefaultSearch(noRouteFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).DefaultSearch"
+	return m.setOperationDefault(op, SearchRouteOperation, noRouteFn, opt...)
+}
+
+// DefaultExtendedOperation will register a default handler for extended
+// operation requests which have no other registered handler.  It takes
+// precedence over the DefaultRoute.
+func (m *Mux) DefaultExtendedOperation(noRouteFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).DefaultExtendedOperation"
+	return m.setOperationDefault(op, ExtendedRouteOperation, noRouteFn, opt...)
+}
+
+// DefaultModify will register a default handler for modify requests which have
+// no other registered handler.  It takes precedence over the DefaultRoute.
+func (m *Mux) DefaultModify(noRouteFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).DefaultModify"
+	return m.setOperationDefault(op, ModifyRouteOperation, noRouteFn, opt...)
+}
+
+// DefaultModifyDN will register a default handler for modify DN requests which
+// have no other registered handler.  It takes precedence over the
+// DefaultRoute.
+func (m *Mux) DefaultModifyDN(noRouteFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).DefaultModifyDN"
+	return m.setOperationDefault(op, ModifyDNRouteOperation, noRouteFn, opt...)
+}
+
+// DefaultAdd will register a default handler for add requests which have no
+// other registered handler.  It takes precedence over the DefaultRoute.
+func (m *Mux) DefaultAdd(noRouteFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).DefaultAdd"
+	return m.setOperationDefault(op, AddRouteOperation, noRouteFn, opt...)
+}
+
+// DefaultDelete will register a default handler for delete requests which have
+// no other registered handler.  It takes precedence over the DefaultRoute.
+func (m *Mux) DefaultDelete(noRouteFn HandlerFunc, opt ...RouteOption) error {
+	const op = "gldap.(Mux).DefaultDelete"
+	return m.setOperationDefault(op, DeleteRouteOperation, noRouteFn, opt...)
+}
+
+// setOperationDefault registers the default handler of the route operation
+// for the caller op.
+func (m *Mux) setOperationDefault(op string, routeOp RouteOperation, noRouteFn HandlerFunc, opt ...RouteOption) error {
+	if noRouteFn == nil {
+		return fmt.Errorf("%s: missing HandlerFunc: %w", op, ErrInvalidParameter)
+	}
+	if err := unsupportedOptions(op, "a route", &routeOptions{}, opt...); err != nil {
+		return err
+	}
+	opts := getRouteOpts(opt...)
+	m.setOperationDefaultRoute(&baseRoute{
+		h:                      noRouteFn,
+		routeOp:                routeOp,
+		label:                  opts.withLabel,
+		timeout:                opts.withRouteTimeout,
+		requireAuth:            opts.withRequireAuthentication,
+		allowedBindDNs:         opts.withAllowedBindDNs,
+		requireConfidentiality: opts.withRequireConfidentiality,
+		criticalControlPolicy:  opts.withCriticalControlPolicy,
+		supportedControls:      opts.withSupportedControls,
+	})
 	return nil
 }
 
@@ -203,38 +489,323 @@ func (m *Mux) DefaultRoute(noRouteFN HandlerFunc, opt ...Option) error {
 func (m *Mux) serve(w *ResponseWriter, req *Request) {
 	const op = "gldap.(Mux).serve"
 	defer func() {
-		w.logger.Debug("finished serving request", "op", op, "connID", w.connID, "requestID", w.requestID)
+		w.logger.Debug("finished serving request", "op", op, "connID", w.connID, "requestID", w.requestID, "correlationID", w.request.CorrelationID())
 	}()
 	if w == nil {
 		// this should be unreachable, and if it is then we'll just panic
 		panic(fmt.Errorf("%s: %d/%d missing response writer: %w", op, w.connID, w.requestID, ErrInternal).Error())
 	}
 	if req == nil {
-		w.logger.Error("missing request", "op", op, "connID", w.connID, "requestID", w.requestID)
+		w.logger.Error("missing request", "op", op, "connID", w.connID, "requestID", w.requestID, "correlationID", w.request.CorrelationID())
 		return
 	}
 
+	// a search whose scope spans mounted backends is served by all of them
+	if subs := m.subordinateMounts(req); len(subs) > 0 {
+		m.serveComposedSearch(w, req, subs)
+		return
+	}
+	m.serveMounted(w, req)
+}
+
+// serveMounted serves the request with the mounted backend whose subtree it's
+// within, or with the mux's routes otherwise.
+func (m *Mux) serveMounted(w *ResponseWriter, req *Request) {
+	// a request within a mounted backend's subtree is served by the backend
+	if b := m.mountFor(req); b != nil {
+		m.chain(b.serve)(w, req)
+		return
+	}
+	m.serveRoutes(w, req)
+}
+
+// serveRoutes serves the request with the first of the mux's routes that
+// matches it, or its default routes.
+func (m *Mux) serveRoutes(w *ResponseWriter, req *Request) {
+	const op = "gldap.(Mux).serveRoutes"
 	// find the first matching route to dispatch the request to and then return
 	for _, r := range m.routes {
-		if !r.match(req) {
+		if !r.match(req) || !inRouteSuffixes(r, req) {
 			continue
 		}
+		// a request the matching route denies isn't dispatched to a later
+		// route (i.e. a catch-all route)
+		if code, diag := routeAuthorized(r, req); code != ResultSuccess {
+			_ = w.Write(req.resultResponse(code, diag))
+			return
+		}
 		h := r.handler()
 		if h == nil {
-			w.logger.Error("route is missing handler", "op", op, "connID", w.connID, "requestID", w.requestID, "route", r.op)
+			w.logger.Error("route is missing handler", "op", op, "connID", w.connID, "requestID", w.requestID, "correlationID", w.request.CorrelationID(), "route", r.op)
 			return
 		}
 		// the handler intentionally doesn't return errors, since we want the
 		// handler to response to the connection's client with errors.
-		h(w, req)
+		if t, ok := r.(interface{ routeTimeout() time.Duration }); ok && t.routeTimeout() > 0 {
+			h = timeoutHandler(h, t.routeTimeout())
+		}
+		m.chain(h)(w, req)
+		return
+	}
+	if r, ok := m.defaultRoutes[req.routeOp]; ok {
+		if code, diag := routeAuthorized(r, req); code != ResultSuccess {
+			_ = w.Write(req.resultResponse(code, diag))
+			return
+		}
+		h := r.handler()
+		if t, ok := r.(interface{ routeTimeout() time.Duration }); ok && t.routeTimeout() > 0 {
+			h = timeoutHandler(h, t.routeTimeout())
+		}
+		m.chain(h)(w, req)
 		return
 	}
 	if m.defaultRoute != nil {
+		// the DefaultRoute is subject to the server's policy for unknown
+		// critical controls like every other route
+		if code, diag := routeAuthorized(m.defaultRoute, req); code != ResultSuccess {
+			_ = w.Write(req.resultResponse(code, diag))
+			return
+		}
 		h := m.defaultRoute.handler()
-		h(w, req)
+		m.chain(h)(w, req)
 		return
 	}
-	w.logger.Error("no matching handler found for request and returning internal error", "op", op, "connID", w.connID, "requestID", w.requestID, "routeOp", req.routeOp)
+	w.logger.Error("no matching handler found for request and returning internal error", "op", op, "connID", w.connID, "requestID", w.requestID, "correlationID", w.request.CorrelationID(), "routeOp", req.routeOp)
 	resp := req.NewResponse(WithResponseCode(ResultUnwillingToPerform), WithDiagnosticMessage("No matching handler found"))
 	_ = w.Write(resp)
 }
+
+// Routes returns descriptions of the mux's routes, so the routing table can be
+// logged or asserted by tests.  The routes are returned in the order they're
+// matched, followed by the unbind route, the per-operation default routes
+// (see: DefaultSearch) and the DefaultRoute.  An inline mux (see: With and
+// Group) returns the routes of the mux it registers its routes with.
+func (m *Mux) Routes() []RouteInfo {
+	if m.parent != nil {
+		return m.parent.Routes()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	routes := make([]RouteInfo, 0, len(m.routes)+len(m.defaultRoutes)+2)
+	for _, r := range m.routes {
+		routes = append(routes, routeInfo(r))
+	}
+	if m.unbindRoute != nil {
+		routes = append(routes, routeInfo(m.unbindRoute))
+	}
+	for _, o := range monitorOperations {
+		if r, ok := m.defaultRoutes[o.op]; ok {
+			info := routeInfo(r)
+			info.Default = true
+			routes = append(routes, info)
+		}
+	}
+	if m.defaultRoute != nil {
+		info := routeInfo(m.defaultRoute)
+		info.Operation = ""
+		info.Default = true
+		routes = append(routes, info)
+	}
+	return routes
+}
+
+// timeoutHandler returns a handler that serves the request with the handler,
+// and responds to the request with timeLimitExceeded when the handler hasn't
+// responded within the timeout.  The handler is served with a copy of the
+// request whose context has a deadline of the timeout, so the request's own
+// context isn't replaced.  It's applied within the mux's middlewares, so the
+// timeLimitExceeded response is written with their interceptors (i.e.
+// RedactAttributes).  It waits for the handler to return, so the handler's
+// panics are raised by the caller's goroutine.
+func timeoutHandler(h HandlerFunc, timeout time.Duration) HandlerFunc {
+	return func(w *ResponseWriter, req *Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		req.setRouteDeadline(req.now().Add(timeout))
+		timedReq := req.withContext(ctx)
+
+		done := make(chan struct{})
+		var panicked interface{}
+		go func() {
+			defer close(done)
+			defer func() { panicked = recover() }()
+			h(w, timedReq)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && req.markTimedOut() {
+				_ = w.writeIntercepted(req.resultResponse(ResultTimeLimitExceeded, "route timeout exceeded"))
+			}
+			<-done
+		}
+		if panicked != nil {
+			panic(panicked)
+		}
+	}
+}
+
+// hasExtendedRoute returns true if the mux has a route for the named extended
+// operation.
+func (m *Mux) hasExtendedRoute(name ExtendedOperationName) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.routes {
+		if e, ok := r.(*extendedRoute); ok && e.extendedName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Use appends middlewares to the mux's middleware stack, which are applied to
+// every route's handler (including the default and unbind routes) when a
+// request is served.  Middlewares are applied in the order they're added, so
+// the first middleware is the outermost one.  For an inline mux (see: With)
+// the middlewares are only applied to its routes.
+func (m *Mux) Use(middlewares ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.parent != nil {
+		m.inline = append(m.inline, middlewares...)
+		return
+	}
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+// With returns an inline mux whose routes are registered with the mux after
+// their handlers are wrapped with the middlewares, so per-route middlewares
+// can be applied.  The mux's own middlewares (see: Use) are applied to the
+// inline mux's routes as well.
+//
+//	_ = mux.With(requireBound).Modify(modifyHandler)
+func (m *Mux) With(middlewares ...Middleware) *Mux {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inline := make([]Middleware, 0, len(m.inline)+len(middlewares))
+	inline = append(inline, m.inline...)
+	inline = append(inline, middlewares...)
+	parent := m
+	if m.parent != nil {
+		parent = m.parent
+	}
+	return &Mux{
+		routes:   []route{},
+		parent:   parent,
+		inline:   inline,
+		suffixes: m.suffixes,
+	}
+}
+
+// Group returns an inline mux whose routes are scoped to the subtree of the
+// suffix (i.e. a naming context like "dc=corp,dc=example,dc=com"), which
+// allows one mux to serve several suffixes.  A group's routes only match
+// requests whose target DN (i.e. a bind's name, a search's base DN or the DN
+// of the entry being modified, added or deleted) is within the suffix's
+// subtree.  DNs are compared case-insensitively.  Requests without a target
+// DN (i.e. extended operations) and the group's default and unbind routes
+// aren't scoped.  Middlewares added to the group with Use are only applied to
+// the group's routes that are registered after them.  Groups can be nested, in
+// which case a route must match all of the groups' suffixes.
+//
+//	corp := mux.Group("dc=corp,dc=example,dc=com")
+//	corp.Use(requireBound)
+//	_ = corp.Search(corpSearchHandler)
+func (m *Mux) Group(suffix string) *Mux {
+	g := m.With()
+	g.suffixes = append(append([]string{}, m.suffixes...), suffix)
+	return g
+}
+
+// addRoute adds the route to the mux, or its parent if it's an inline mux.
+func (m *Mux) addRoute(r route) {
+	if m.parent != nil {
+		m.wrapInline(r)
+		if b, ok := r.(interface{ setSuffixes([]string) }); ok && len(m.suffixes) > 0 {
+			b.setSuffixes(m.suffixes)
+		}
+		m.parent.addRoute(r)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = append(m.routes, r)
+}
+
+// setDefaultRoute sets the mux's default route, or its parent's if it's an
+// inline mux.
+func (m *Mux) setDefaultRoute(r route) {
+	if m.parent != nil {
+		m.wrapInline(r)
+		m.parent.setDefaultRoute(r)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultRoute = r
+}
+
+// setOperationDefaultRoute sets the mux's default route for the route's
+// operation, or its parent's if it's an inline mux.
+func (m *Mux) setOperationDefaultRoute(r route) {
+	if m.parent != nil {
+		m.wrapInline(r)
+		m.parent.setOperationDefaultRoute(r)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.defaultRoutes == nil {
+		m.defaultRoutes = map[RouteOperation]route{}
+	}
+	m.defaultRoutes[r.op()] = r
+}
+
+// setUnbindRoute sets the mux's unbind route, or its parent's if it's an
+// inline mux.
+func (m *Mux) setUnbindRoute(r route) {
+	if m.parent != nil {
+		m.wrapInline(r)
+		m.parent.setUnbindRoute(r)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unbindRoute = r
+}
+
+// wrapInline wraps the route's handler with the inline mux's middlewares
+func (m *Mux) wrapInline(r route) {
+	m.mu.Lock()
+	inline := m.inline
+	m.mu.Unlock()
+	if b, ok := r.(interface{ wrap([]Middleware) }); ok {
+		b.wrap(inline)
+	}
+}
+
+// chain wraps the handler with the mux's middlewares
+func (m *Mux) chain(h HandlerFunc) HandlerFunc {
+	m.mu.Lock()
+	middlewares := m.middlewares
+	m.mu.Unlock()
+	return wrapHandler(h, middlewares)
+}
+
+// serveUnbind serves an unbind request with the mux's unbind route, if it has
+// one.
+func (m *Mux) serveUnbind(w *ResponseWriter, req *Request) {
+	if m.unbindRoute == nil {
+		return
+	}
+	m.chain(m.unbindRoute.handler())(w, req)
+}
+
+// wrapHandler wraps the handler with the middlewares, so the first middleware
+// is the outermost one.
+func wrapHandler(h HandlerFunc, middlewares []Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
@@ -7,8 +7,10 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -155,6 +157,157 @@ func TestMux_Delete(t *testing.T) {
 	}
 }
 
+func TestMux_Search(t *testing.T) {
+	tests := []struct {
+		name            string
+		fn              HandlerFunc
+		opt             []RouteOption
+		wantErr         bool
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{
+			name:            "missing-fn",
+			wantErr:         true,
+			wantErrIs:       ErrInvalidParameter,
+			wantErrContains: "missing HandlerFunc",
+		},
+		{
+			name:            "invalid-filter",
+			fn:              func(*ResponseWriter, *Request) {},
+			opt:             []RouteOption{WithFilter("(uid=alice")},
+			wantErr:         true,
+			wantErrIs:       ErrInvalidParameter,
+			wantErrContains: "invalid filter",
+		},
+		{
+			name: "valid",
+			fn:   func(*ResponseWriter, *Request) {},
+			opt:  []RouteOption{WithFilter("(uid=alice)")},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			m, err := NewMux()
+			require.NoError(err)
+			err = m.Search(tc.fn, tc.opt...)
+			if tc.wantErr {
+				require.Error(err)
+				if tc.wantErrIs != nil {
+					assert.ErrorIs(err, tc.wantErrIs)
+				}
+				if tc.wantErrContains != "" {
+					assert.Contains(err.Error(), tc.wantErrContains)
+				}
+				assert.Empty(m.Routes())
+				return
+			}
+			require.NoError(err)
+			assert.Len(m.Routes(), 1)
+		})
+	}
+}
+
+func TestMux_MatchFunc(t *testing.T) {
+	matchFn := func(*Request) bool { return true }
+	tests := []struct {
+		name            string
+		routeOp         RouteOperation
+		matchFn         func(*Request) bool
+		fn              HandlerFunc
+		wantErr         bool
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{
+			name:            "missing-match-fn",
+			routeOp:         SearchRouteOperation,
+			fn:              func(*ResponseWriter, *Request) {},
+			wantErr:         true,
+			wantErrIs:       ErrInvalidParameter,
+			wantErrContains: "missing match func",
+		},
+		{
+			name:            "missing-fn",
+			routeOp:         SearchRouteOperation,
+			matchFn:         matchFn,
+			wantErr:         true,
+			wantErrIs:       ErrInvalidParameter,
+			wantErrContains: "missing HandlerFunc",
+		},
+		{
+			name:            "unbind",
+			routeOp:         UnbindRouteOperation,
+			matchFn:         matchFn,
+			fn:              func(*ResponseWriter, *Request) {},
+			wantErr:         true,
+			wantErrIs:       ErrInvalidParameter,
+			wantErrContains: "unsupported route operation",
+		},
+		{
+			name:            "unknown",
+			routeOp:         RouteOperation("compare"),
+			matchFn:         matchFn,
+			fn:              func(*ResponseWriter, *Request) {},
+			wantErr:         true,
+			wantErrIs:       ErrInvalidParameter,
+			wantErrContains: "unsupported route operation",
+		},
+		{
+			name:    "valid",
+			routeOp: SearchRouteOperation,
+			matchFn: matchFn,
+			fn:      func(*ResponseWriter, *Request) {},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			mux, err := NewMux()
+			require.NoError(err)
+			err = mux.MatchFunc(tc.routeOp, tc.matchFn, tc.fn)
+			if tc.wantErr {
+				require.Error(err)
+				if tc.wantErrIs != nil {
+					assert.ErrorIs(err, tc.wantErrIs)
+				}
+				if tc.wantErrContains != "" {
+					assert.Contains(err.Error(), tc.wantErrContains)
+				}
+				return
+			}
+			require.NoError(err)
+		})
+	}
+	t.Run("e2e", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.MatchFunc(SearchRouteOperation, func(r *Request) bool {
+			m, err := r.GetSearchMessage()
+			return err == nil && strings.Contains(strings.ToLower(m.Filter), "objectclass=posixaccount")
+		}, func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchResponseEntry("uid=alice,ou=people,dc=example,dc=org"))
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultNoSuchObject)))
+		}))
+		_, url := testServer(t, mux)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+
+		res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(&(objectClass=posixAccount)(uid=alice))", nil, nil))
+		require.NoError(err)
+		assert.Len(res.Entries, 1)
+
+		_, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=person)", nil, nil))
+		assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject))
+	})
+}
+
 func TestMux_Unbind(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -195,3 +348,567 @@ func TestMux_Unbind(t *testing.T) {
 		})
 	}
 }
+
+func TestMux_Use(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w *ResponseWriter, r *Request) {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				next(w, r)
+			}
+		}
+	}
+	requireBound := func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			if r.conn.authzID() == "" {
+				_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultInsufficientAccessRights)))
+				return
+			}
+			next(w, r)
+		}
+	}
+	gotCalls := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		got := calls
+		calls = nil
+		return got
+	}
+
+	mux, err := NewMux()
+	require.NoError(err)
+	mux.Use(record("first"), record("second"))
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	inline := mux.With(record("inline"), requireBound)
+	require.NoError(inline.Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.With(record("default")).DefaultRoute(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultUnwillingToPerform)))
+	}))
+	// the inline mux's routes are registered with the mux
+	assert.Len(mux.routes, 2)
+	assert.Empty(inline.routes)
+
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	modify := ldap.NewModifyRequest("cn=alice", nil)
+	modify.Replace("mail", []string{"alice@example.org"})
+	err = client.Modify(modify)
+	assert.True(ldap.IsErrorWithCode(err, ResultInsufficientAccessRights))
+	assert.Equal([]string{"first", "second", "inline"}, gotCalls())
+
+	require.NoError(client.Bind("cn=alice", "password"))
+	assert.Equal([]string{"first", "second"}, gotCalls())
+	require.NoError(client.Modify(modify))
+	assert.Equal([]string{"first", "second", "inline"}, gotCalls())
+
+	err = client.Del(ldap.NewDelRequest("cn=alice", nil))
+	assert.True(ldap.IsErrorWithCode(err, ResultUnwillingToPerform))
+	assert.Equal([]string{"first", "second", "default"}, gotCalls())
+}
+
+func TestMux_Group(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w *ResponseWriter, r *Request) {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				next(w, r)
+			}
+		}
+	}
+	searchHandler := func(suffix string) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchResponseEntry(suffix))
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}
+	}
+
+	corp := mux.Group("dc=corp,dc=example,dc=com")
+	corp.Use(record("corp"))
+	require.NoError(corp.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(corp.Search(searchHandler("dc=corp,dc=example,dc=com")))
+	require.NoError(corp.Group("ou=people,dc=corp,dc=example,dc=com").Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}))
+	lab := mux.Group("dc=lab,dc=example,dc=com")
+	lab.Use(record("lab"))
+	require.NoError(lab.Search(searchHandler("dc=lab,dc=example,dc=com")))
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultNoSuchObject)))
+	}))
+	require.NoError(mux.DefaultRoute(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationBindResponse), WithResponseCode(ResultInvalidCredentials)))
+	}))
+
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	search := func(baseDN string) (*ldap.SearchResult, error) {
+		return client.Search(ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	}
+	res, err := search("ou=people,DC=Corp,dc=example,dc=com")
+	require.NoError(err)
+	require.Len(res.Entries, 1)
+	assert.Equal("dc=corp,dc=example,dc=com", res.Entries[0].DN)
+
+	res, err = search("dc=lab,dc=example,dc=com")
+	require.NoError(err)
+	require.Len(res.Entries, 1)
+	assert.Equal("dc=lab,dc=example,dc=com", res.Entries[0].DN)
+
+	_, err = search("dc=example,dc=com")
+	assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject))
+
+	require.NoError(client.Bind("cn=alice,ou=people,dc=corp,dc=example,dc=com", "password"))
+	err = client.Bind("cn=alice,ou=people,dc=lab,dc=example,dc=com", "password")
+	assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials))
+
+	modify := ldap.NewModifyRequest("cn=alice,ou=people,dc=corp,dc=example,dc=com", nil)
+	modify.Replace("mail", []string{"alice@corp.example.com"})
+	require.NoError(client.Modify(modify))
+	modify = ldap.NewModifyRequest("cn=admins,ou=groups,dc=corp,dc=example,dc=com", nil)
+	modify.Replace("mail", []string{"admins@corp.example.com"})
+	assert.Error(client.Modify(modify))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]string{"corp", "lab", "corp", "corp"}, calls)
+}
+
+func TestMux_Mount(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w *ResponseWriter, r *Request) {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				next(w, r)
+			}
+		}
+	}
+	backend := func(t *testing.T, name string) *Mux {
+		t.Helper()
+		b, err := NewMux()
+		require.NoError(t, err)
+		b.Use(record(name))
+		require.NoError(t, b.Search(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchResponseEntry(name))
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		require.NoError(t, b.DefaultRoute(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.resultResponse(ResultUnwillingToPerform, name))
+		}))
+		return b
+	}
+
+	assert, require := assert.New(t), require.New(t)
+	mux, err := NewMux()
+	require.NoError(err)
+	mux.Use(record("root"))
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("root"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.ExtendedOperation(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewExtendedResponse(WithResponseCode(ResultSuccess)))
+	}, ExtendedOperationWhoAmI))
+	require.NoError(mux.Mount("ou=people,dc=example,dc=org", backend(t, "people")))
+	require.NoError(mux.Mount("ou=corp,dc=example,dc=org", backend(t, "corp")))
+	require.NoError(mux.Group("dc=example,dc=org").Mount("ou=eng,ou=corp,dc=example,dc=org", backend(t, "eng")))
+
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	servedBy := func(baseDN string) string {
+		t.Helper()
+		res, err := client.Search(ldap.NewSearchRequest(baseDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+		require.Len(res.Entries, 1)
+		return res.Entries[0].DN
+	}
+	assert.Equal("people", servedBy("ou=people,dc=example,dc=org"))
+	assert.Equal("people", servedBy("uid=alice,OU=People,dc=example,dc=org"))
+	assert.Equal("corp", servedBy("ou=sales,ou=corp,dc=example,dc=org"))
+	// the longest suffix wins
+	assert.Equal("eng", servedBy("uid=bob,ou=eng,ou=corp,dc=example,dc=org"))
+	assert.Equal("root", servedBy("dc=example,dc=org"))
+
+	// the backend's routes serve all the operations within its suffix
+	err = client.Del(ldap.NewDelRequest("uid=alice,ou=people,dc=example,dc=org", nil))
+	assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform))
+	assert.Contains(err.Error(), "people")
+
+	// requests without a target DN are served by the mux
+	_, err = client.WhoAmI(nil)
+	require.NoError(err)
+
+	mu.Lock()
+	assert.Equal([]string{"root", "people", "root", "people", "root", "corp", "root", "eng", "root", "root", "people", "root"}, calls)
+	mu.Unlock()
+
+	// invalid mounts
+	b := backend(t, "test")
+	err = mux.Mount("", b)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	assert.Contains(err.Error(), "missing suffix")
+	err = mux.Mount("ou=test,dc=example,dc=org", nil)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	assert.Contains(err.Error(), "missing backend")
+	err = mux.Mount("invalid", b)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	assert.Contains(err.Error(), "invalid suffix")
+	err = mux.Mount("OU=People,dc=example,dc=org", b)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	assert.Contains(err.Error(), "already mounted")
+	err = mux.Mount("ou=test,dc=example,dc=org", mux)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	assert.Contains(err.Error(), "on itself")
+}
+
+func TestMux_composedSearch(t *testing.T) {
+	t.Parallel()
+	// backend returns a mux which searches the entries
+	backend := func(t *testing.T, dns ...string) *Mux {
+		t.Helper()
+		b, err := NewMux()
+		require.NoError(t, err)
+		require.NoError(t, b.Search(func(w *ResponseWriter, r *Request) {
+			m, err := r.GetSearchMessage()
+			require.NoError(t, err)
+			found := false
+			for _, dn := range dns {
+				if ok, _ := dnInScope(dn, m.BaseDN, BaseObject); ok {
+					found = true
+				}
+			}
+			if !found {
+				_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultNoSuchObject)))
+				return
+			}
+			for _, dn := range dns {
+				if ok, _ := dnInScope(dn, m.BaseDN, m.Scope); ok {
+					_ = w.Write(r.NewSearchResponseEntry(dn))
+				}
+			}
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		return b
+	}
+	mux := backend(t, "dc=example,dc=org", "ou=groups,dc=example,dc=org")
+	require.NoError(t, mux.Mount("ou=people,dc=example,dc=org", backend(t, "ou=people,dc=example,dc=org", "uid=alice,ou=people,dc=example,dc=org")))
+	require.NoError(t, mux.Mount("ou=corp,dc=example,dc=org", backend(t, "ou=corp,dc=example,dc=org", "uid=bob,ou=corp,dc=example,dc=org")))
+	require.NoError(t, mux.Mount("ou=eng,ou=corp,dc=example,dc=org", backend(t, "ou=eng,ou=corp,dc=example,dc=org")))
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+	defer client.Close()
+
+	search := func(baseDN string, scope, sizeLimit int) ([]string, error) {
+		t.Helper()
+		res, err := client.Search(ldap.NewSearchRequest(baseDN, scope, ldap.NeverDerefAliases, sizeLimit, 0, false, "(objectClass=*)", nil, nil))
+		var dns []string
+		if res != nil {
+			for _, e := range res.Entries {
+				dns = append(dns, e.DN)
+			}
+		}
+		return dns, err
+	}
+	assert, require := assert.New(t), require.New(t)
+	dns, err := search("dc=example,dc=org", ldap.ScopeWholeSubtree, 0)
+	require.NoError(err)
+	assert.Equal([]string{
+		"dc=example,dc=org",
+		"ou=groups,dc=example,dc=org",
+		"ou=people,dc=example,dc=org",
+		"uid=alice,ou=people,dc=example,dc=org",
+		"ou=corp,dc=example,dc=org",
+		"uid=bob,ou=corp,dc=example,dc=org",
+		"ou=eng,ou=corp,dc=example,dc=org",
+	}, dns)
+
+	// a single level search only includes the suffixes of the backends
+	// mounted one level below the base DN
+	dns, err = search("dc=example,dc=org", ldap.ScopeSingleLevel, 0)
+	require.NoError(err)
+	assert.Equal([]string{"ou=groups,dc=example,dc=org", "ou=people,dc=example,dc=org", "ou=corp,dc=example,dc=org"}, dns)
+
+	// a search based in a backend includes the backends mounted below it
+	dns, err = search("ou=corp,dc=example,dc=org", ldap.ScopeWholeSubtree, 0)
+	require.NoError(err)
+	assert.Equal([]string{"ou=corp,dc=example,dc=org", "uid=bob,ou=corp,dc=example,dc=org", "ou=eng,ou=corp,dc=example,dc=org"}, dns)
+
+	// the size limit applies to the aggregated entries
+	dns, err = search("dc=example,dc=org", ldap.ScopeWholeSubtree, 3)
+	assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded))
+	assert.Len(dns, 3)
+
+	// a backend without the base DN doesn't fail the search, unlike the other
+	// failures of a subordinate backend
+	glue := backend(t)
+	require.NoError(glue.Mount("ou=people,dc=example,dc=org", backend(t, "ou=people,dc=example,dc=org")))
+	require.NoError(glue.Mount("ou=busy,dc=example,dc=org", func() *Mux {
+		b, err := NewMux()
+		require.NoError(err)
+		require.NoError(b.Search(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultBusy), WithDiagnosticMessage("busy")))
+		}))
+		return b
+	}()))
+	_, url = testServer(t, glue)
+	glueClient, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer glueClient.Close()
+	res, err := glueClient.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultBusy))
+	assert.Contains(err.Error(), "busy")
+	require.NotNil(res)
+	require.Len(res.Entries, 1)
+	assert.Equal("ou=people,dc=example,dc=org", res.Entries[0].DN)
+	_, err = glueClient.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject))
+}
+
+func TestMux_OperationDefaults(t *testing.T) {
+	t.Run("missing-fn", func(t *testing.T) {
+		assert := assert.New(t)
+		mux, err := NewMux()
+		require.NoError(t, err)
+		for _, fn := range []func(HandlerFunc, ...Option) error{
+			mux.DefaultBind,
+			mux.DefaultSearch,
+			mux.DefaultExtendedOperation,
+			mux.DefaultModify,
+			mux.DefaultModifyDN,
+			mux.DefaultAdd,
+			mux.DefaultDelete,
+		} {
+			err := fn(nil)
+			assert.ErrorIs(err, ErrInvalidParameter)
+			assert.Contains(err.Error(), "missing HandlerFunc")
+		}
+	})
+	t.Run("e2e", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org"))
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}, WithBaseDN("dc=example,dc=org")))
+		require.NoError(mux.DefaultSearch(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultNoSuchObject)))
+		}))
+		require.NoError(mux.DefaultModify(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultUnwillingToPerform)))
+		}))
+		require.NoError(mux.DefaultRoute(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultInsufficientAccessRights)))
+		}))
+		_, url := testServer(t, mux)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+
+		res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+		assert.Len(res.Entries, 1)
+
+		_, err = client.Search(ldap.NewSearchRequest("dc=other,dc=org", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		assert.True(ldap.IsErrorWithCode(err, ResultNoSuchObject))
+
+		mod := ldap.NewModifyRequest("cn=alice,dc=example,dc=org", nil)
+		mod.Replace("mail", []string{"alice@example.org"})
+		err = client.Modify(mod)
+		assert.True(ldap.IsErrorWithCode(err, ResultUnwillingToPerform))
+
+		// operations without a default of their own fall back to the
+		// default route
+		err = client.Del(ldap.NewDelRequest("cn=alice,dc=example,dc=org", nil))
+		assert.True(ldap.IsErrorWithCode(err, ResultInsufficientAccessRights))
+	})
+}
+
+func TestMux_Routes(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	fn := func(*ResponseWriter, *Request) {}
+	mux, err := NewMux()
+	require.NoError(err)
+	assert.Empty(mux.Routes())
+
+	require.NoError(mux.RootDSE(fn, WithLabel("rootDSE")))
+	require.NoError(mux.Bind(fn, WithLabel("bind")))
+	require.NoError(mux.Search(fn, WithBaseDN("ou=people,dc=example,dc=org"), WithFilter("(uid=*)"), WithScope(SingleLevel)))
+	require.NoError(mux.Search(fn, WithBaseDNSuffix("dc=example,dc=org"), WithFilterPattern(regexp.MustCompile(`^\(cn=`))))
+	require.NoError(mux.ExtendedOperation(fn, ExtendedOperationWhoAmI))
+	require.NoError(mux.Group("dc=corp,dc=example,dc=org").Modify(fn))
+	require.NoError(mux.MatchFunc(DeleteRouteOperation, func(*Request) bool { return true }, fn))
+	require.NoError(mux.Unbind(fn))
+	require.NoError(mux.DefaultModify(fn))
+	require.NoError(mux.DefaultSearch(fn))
+	require.NoError(mux.DefaultRoute(fn))
+
+	want := []RouteInfo{
+		{Operation: SearchRouteOperation, Label: "rootDSE", RootDSE: true},
+		{Operation: BindRouteOperation, Label: "bind"},
+		{Operation: SearchRouteOperation, BaseDN: "ou=people,dc=example,dc=org", Filter: "(uid=*)", Scope: SingleLevel},
+		{Operation: SearchRouteOperation, BaseDNSuffix: "dc=example,dc=org", FilterPattern: `^\(cn=`},
+		{Operation: ExtendedRouteOperation, ExtendedName: ExtendedOperationWhoAmI},
+		{Operation: ModifyRouteOperation, Suffixes: []string{"dc=corp,dc=example,dc=org"}},
+		{Operation: DeleteRouteOperation, MatchFunc: true},
+		{Operation: UnbindRouteOperation},
+		{Operation: SearchRouteOperation, Default: true},
+		{Operation: ModifyRouteOperation, Default: true},
+		{Default: true},
+	}
+	assert.Equal(want, mux.Routes())
+	// an inline mux returns the routes of its parent
+	assert.Equal(want, mux.With().Routes())
+}
+
+func TestMux_routeTimeout(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	mux, err := NewMux()
+	require.NoError(err)
+	lateWriteErr := make(chan error, 1)
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		<-r.Context().Done()
+		lateWriteErr <- w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}, WithBaseDN("ou=slow,dc=example,dc=org"), WithRouteTimeout(50*time.Millisecond)))
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,ou=fast,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}, WithBaseDN("ou=fast,dc=example,dc=org"), WithRouteTimeout(time.Minute)))
+	require.NoError(mux.DefaultModify(func(w *ResponseWriter, r *Request) {
+		<-r.Context().Done()
+	}, WithRouteTimeout(50*time.Millisecond)))
+	// the timeout response is written with the interceptors of the mux's
+	// middlewares, which are served with the request's own context
+	outerCtxErr := make(chan error, 1)
+	mux.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			next(w.WithInterceptor(func(resp Response) Response {
+				if rc, ok := resp.(interface{ ResultCode() int }); ok && rc.ResultCode() == ResultTimeLimitExceeded {
+					resp.(interface{ SetDiagnosticMessage(string) }).SetDiagnosticMessage("intercepted")
+				}
+				return resp
+			}), r)
+			if r.routeOp == ModifyRouteOperation {
+				outerCtxErr <- r.Context().Err()
+			}
+		}
+	})
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	_, err = client.Search(ldap.NewSearchRequest("ou=slow,dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	assert.True(ldap.IsErrorWithCode(err, ResultTimeLimitExceeded))
+	assert.ErrorIs(<-lateWriteErr, ErrInvalidState)
+
+	res, err := client.Search(ldap.NewSearchRequest("ou=fast,dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	assert.Len(res.Entries, 1)
+
+	mod := ldap.NewModifyRequest("cn=alice,dc=example,dc=org", nil)
+	mod.Replace("mail", []string{"alice@example.org"})
+	err = client.Modify(mod)
+	assert.True(ldap.IsErrorWithCode(err, ResultTimeLimitExceeded))
+	assert.ErrorContains(err, "intercepted")
+	assert.NoError(<-outerCtxErr)
+}
+
+func TestMux_requireAuthentication(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}, WithRequireAuthentication()))
+	require.NoError(mux.Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}, WithAllowedBindDNs("cn=admin,dc=example,dc=org")))
+	// a catch-all route after the restricted routes doesn't serve the requests
+	// they deny
+	var catchAll atomic.Int64
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		catchAll.Add(1)
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+
+	routes := mux.Routes()
+	require.Len(routes, 4)
+	assert.True(routes[1].RequireAuthentication)
+	assert.Equal([]string{"cn=admin,dc=example,dc=org"}, routes[2].AllowedBindDNs)
+	assert.False(routes[3].RequireAuthentication)
+	assert.Nil(routes[3].AllowedBindDNs)
+	// the routes' bind DNs are copies
+	routes[2].AllowedBindDNs[0] = "cn=eve,dc=example,dc=org"
+	assert.Equal([]string{"cn=admin,dc=example,dc=org"}, mux.Routes()[2].AllowedBindDNs)
+
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	search := ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	mod := ldap.NewModifyRequest("cn=alice,dc=example,dc=org", nil)
+	mod.Replace("mail", []string{"alice@example.org"})
+
+	// anonymous connections aren't routed to any of the handlers
+	_, err = client.Search(search)
+	assert.True(ldap.IsErrorWithCode(err, ResultInsufficientAccessRights))
+	assert.True(ldap.IsErrorWithCode(client.Modify(mod), ResultInsufficientAccessRights))
+	assert.Equal(int64(0), catchAll.Load())
+
+	// any bind DN may search, but only the allowed bind DNs may modify
+	require.NoError(client.Bind("cn=alice,dc=example,dc=org", "password"))
+	res, err := client.Search(search)
+	require.NoError(err)
+	assert.Len(res.Entries, 1)
+	assert.True(ldap.IsErrorWithCode(client.Modify(mod), ResultInsufficientAccessRights))
+
+	require.NoError(client.Bind("CN=Admin,DC=example,DC=org", "password"))
+	assert.NoError(client.Modify(mod))
+
+	// an anonymous bind resets the connection's authentication state
+	require.NoError(client.UnauthenticatedBind(""))
+	_, err = client.Search(search)
+	assert.True(ldap.IsErrorWithCode(err, ResultInsufficientAccessRights))
+}
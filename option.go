@@ -4,14 +4,106 @@
 package gldap
 
 import (
+	"fmt"
 	"io"
 	"reflect"
 )
 
 // Option defines a common functional options type which can be used in a
-// variadic parameter pattern.
+// variadic parameter pattern.  Every option constructor returns an Option, so
+// options of any type can be stored in an []Option and passed to a func which
+// accepts them.
 type Option func(interface{})
 
+// ServerOption is an Option for a Server (see: NewServer, Server.Run and
+// Server.Serve).  Passing an Option which doesn't apply to a server (i.e.
+// WithBaseDN) is reported with an ErrInvalidParameter error.
+type ServerOption = Option
+
+// RouteOption is an Option for a Mux's route (i.e. Mux.Search).  Passing an
+// Option which doesn't apply to a route (i.e. WithReadTimeout) is reported
+// with an ErrInvalidParameter error.
+type RouteOption = Option
+
+// ResponseOption is an Option for a response (i.e.
+// Request.NewSearchDoneResponse).  Passing an Option which doesn't apply to a
+// response (i.e. WithLabel) is logged as a warning by the request's server,
+// since the response constructors don't return an error.
+type ResponseOption = Option
+
+// serverOption returns an Option which only applies to a server's options
+func serverOption(fn func(*configOptions)) Option {
+	return targetedOption(func(o interface{}) bool {
+		v, ok := o.(*configOptions)
+		if ok {
+			fn(v)
+		}
+		return ok
+	})
+}
+
+// routeOption returns an Option which only applies to a route's options
+func routeOption(fn func(*routeOptions)) Option {
+	return targetedOption(func(o interface{}) bool {
+		v, ok := o.(*routeOptions)
+		if ok {
+			fn(v)
+		}
+		return ok
+	})
+}
+
+// responseOption returns an Option which only applies to a response's options
+func responseOption(fn func(*responseOptions)) Option {
+	return targetedOption(func(o interface{}) bool {
+		v, ok := o.(*responseOptions)
+		if ok {
+			fn(v)
+		}
+		return ok
+	})
+}
+
+// optionTarget is applied by an Option returned by targetedOption to find
+// whether the option applies to the target's options, which are a scratch
+// value of the options' type (see: unsupportedOptions)
+type optionTarget struct {
+	opts      interface{}
+	declared  bool // the option declares the options it applies to
+	supported bool // the option applies to the options
+}
+
+// targetedOption returns an Option which applies fn to the options, where fn
+// returns false when the Option doesn't apply to them (i.e. WithClock applies
+// to a server's options and a changelog's options).
+func targetedOption(fn func(opts interface{}) bool) Option {
+	return func(o interface{}) {
+		if t, ok := o.(*optionTarget); ok {
+			t.declared, t.supported = true, fn(t.opts)
+			return
+		}
+		fn(o)
+	}
+}
+
+// unsupportedOptions returns an error when one of the opts doesn't apply to
+// the scratch opts (i.e. &configOptions{}) of the target, which is a name
+// (i.e. "a server") for the error's message.  Options that don't declare the
+// options they apply to (i.e. a func literal) are assumed to apply.
+func unsupportedOptions(op string, target string, opts interface{}, opt ...Option) error {
+	for i, o := range opt {
+		if o == nil {
+			continue
+		}
+		t := &optionTarget{opts: opts}
+		o(t)
+		if t.declared && !t.supported {
+			return fmt.Errorf("%s: option %d doesn't apply to %s: %w", op, i, target, ErrInvalidParameter)
+		}
+	}
+	return nil
+}
+
 // applyOpts takes a pointer to the options struct as a set of default options
 // and applies the slice of opts as overrides.
 func applyOpts(opts interface{}, opt ...Option) {
@@ -4,8 +4,10 @@
 package gldap
 
 import (
+	"crypto/tls"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -40,6 +42,103 @@ func Test_getGeneralOpts(t *testing.T) {
 	}
 }
 
+func Test_typedOptions(t *testing.T) {
+	assert := assert.New(t)
+	tc := &tls.Config{}
+	clock := NewTestClock(t, time.Now())
+
+	// options of every type can be stored together as an []Option
+	opts := []Option{WithTLSConfig(tc), WithClock(clock), WithReadOnly(), WithLabel("label"), WithMatchedDN("dc=example,dc=org")}
+	var nilOpt Option
+	serverOpts := getConfigOpts(append(opts, nilOpt, nil)...)
+	wantServer := configDefaults()
+	wantServer.withTLSConfig = tc
+	wantServer.withClock = clock
+	wantServer.withReadOnly = true
+	assert.Equal(wantServer, serverOpts)
+
+	routeOpts := getRouteOpts(opts...)
+	wantRoute := routeDefaults()
+	wantRoute.withLabel = "label"
+	assert.Equal(wantRoute, routeOpts)
+
+	responseOpts := getResponseOpts(opts...)
+	wantResponse := responseDefaults()
+	wantResponse.withMatchedDN = "dc=example,dc=org"
+	assert.Equal(wantResponse, responseOpts)
+}
+
+func Test_unsupportedOptions(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Now())
+	// an option whose targets aren't declared is assumed to apply
+	var legacy Option = func(interface{}) {}
+	tests := []struct {
+		name    string
+		target  string
+		opts    interface{}
+		opt     []Option
+		wantErr string
+	}{
+		{
+			name:   "server",
+			target: "a server",
+			opts:   &configOptions{},
+			opt:    []Option{WithClock(clock), WithTLSConfig(&tls.Config{}), WithReadOnly(), legacy, nil},
+		},
+		{
+			name:    "server-route-option",
+			target:  "a server",
+			opts:    &configOptions{},
+			opt:     []Option{WithReadOnly(), WithLabel("label")},
+			wantErr: "test: option 1 doesn't apply to a server",
+		},
+		{
+			name:   "response",
+			target: "a response",
+			opts:   &responseOptions{},
+			opt:    []Option{WithMatchedDN("dc=example,dc=org"), legacy},
+		},
+		{
+			name:    "response-clock",
+			target:  "a response",
+			opts:    &responseOptions{},
+			opt:     []Option{WithClock(clock)},
+			wantErr: "test: option 0 doesn't apply to a response",
+		},
+		{
+			name:    "route-tls",
+			target:  "a route",
+			opts:    &routeOptions{},
+			opt:     []Option{WithTLSConfig(&tls.Config{})},
+			wantErr: "test: option 0 doesn't apply to a route",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			err := unsupportedOptions("test", tc.target, tc.opts, tc.opt...)
+			if tc.wantErr != "" {
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.ErrorContains(err, tc.wantErr)
+				return
+			}
+			assert.NoError(err)
+		})
+	}
+	t.Run("entry-points", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := NewServer(WithLabel("label"))
+		assert.ErrorIs(err, ErrInvalidParameter)
+		mux, err := NewMux()
+		assert.NoError(err)
+		assert.ErrorIs(mux.Search(func(*ResponseWriter, *Request) {}, WithReadOnly()), ErrInvalidParameter)
+		s, err := NewServer()
+		assert.NoError(err)
+		assert.ErrorIs(s.NoticeOfDisconnection(1, WithLabel("label")), ErrInvalidParameter)
+	})
+}
+
 func Test_isNil(t *testing.T) {
 	testWriter := new(strings.Builder)
 	tests := []struct {
@@ -6,10 +6,10 @@ package gldap
 import (
 	"fmt"
 	"io"
+	"os"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 	"github.com/go-ldap/ldap/v3"
-	"github.com/hashicorp/go-hclog"
 )
 
 type packet struct {
@@ -128,12 +128,16 @@ func (p *packet) requestType() (requestType, error) {
 		return extendedRequestType, nil
 	case ApplicationModifyRequest:
 		return modifyRequestType, nil
+	case ApplicationModifyDNRequest:
+		return modifyDNRequestType, nil
 	case ApplicationAddRequest:
 		return addRequestType, nil
 	case ApplicationDelRequest:
 		return deleteRequestType, nil
 	case ApplicationUnbindRequest:
 		return unbindRequestType, nil
+	case ApplicationAbandonRequest:
+		return abandonRequestType, nil
 	default:
 		return unknownRequestType, fmt.Errorf("%s: unhandled request type %d: %w", op, requestPacket.Tag, ErrInternal)
 	}
@@ -258,6 +262,30 @@ func (p *packet) extendedOperationName() (ExtendedOperationName, error) {
 	return ExtendedOperationName(n), nil
 }
 
+// extendedOperationValue returns the optional request value of an extended
+// operation and an empty string when there isn't one
+func (p *packet) extendedOperationValue() (string, error) {
+	const (
+		op = "gldap.(Packet).extendedOperationValue"
+
+		childExtendedOperationValue = 1
+	)
+	requestPacket, err := p.requestPacket()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if requestPacket.Packet.Tag != ApplicationExtendedRequest {
+		return "", fmt.Errorf("%s: not an extended operation request, expected tag %d and got %d: %w", op, ApplicationExtendedRequest, requestPacket.Tag, ErrInvalidParameter)
+	}
+	if len(requestPacket.Children) <= childExtendedOperationValue {
+		return "", nil
+	}
+	if err := requestPacket.assert(ber.ClassContext, ber.TypePrimitive, withTag(1), withAssertChild(childExtendedOperationValue)); err != nil {
+		return "", fmt.Errorf("%s: invalid request value packet: %w", op, ErrInvalidParameter)
+	}
+	return requestPacket.Children[childExtendedOperationValue].Data.String(), nil
+}
+
 // Password is a simple bind request password
 type Password string
 
@@ -365,6 +393,75 @@ func (p *packet) addParameters() (*addParameters, error) {
 	return &add, nil
 }
 
+type modifyDNParameters struct {
+	dn           string
+	newRDN       string
+	deleteOldRDN bool
+	newSuperior  string
+	controls     []Control
+}
+
+// modifyDNParameters returns the parameters of a modify DN request
+func (p *packet) modifyDNParameters() (*modifyDNParameters, error) {
+	const (
+		op = "gldap.(packet).modifyDNParameters"
+
+		childDN           = 0
+		childNewRDN       = 1
+		childDeleteOldRDN = 2
+		childNewSuperior  = 3
+		tagNewSuperior    = 0
+	)
+	requestPacket, err := p.requestPacket()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if requestPacket.Packet.Tag != ApplicationModifyDNRequest {
+		return nil, fmt.Errorf("%s: not a modify dn request, expected tag %d and got %d: %w", op, ApplicationModifyDNRequest, requestPacket.Tag, ErrInvalidParameter)
+	}
+	var parameters modifyDNParameters
+	if err := requestPacket.assert(ber.ClassUniversal, ber.TypePrimitive, withTag(ber.TagOctetString), withAssertChild(childDN)); err != nil {
+		return nil, fmt.Errorf("%s: missing/invalid DN: %w", op, ErrInvalidParameter)
+	}
+	parameters.dn = requestPacket.Children[childDN].Data.String()
+
+	if err := requestPacket.assert(ber.ClassUniversal, ber.TypePrimitive, withTag(ber.TagOctetString), withAssertChild(childNewRDN)); err != nil {
+		return nil, fmt.Errorf("%s: missing/invalid new RDN: %w", op, ErrInvalidParameter)
+	}
+	parameters.newRDN = requestPacket.Children[childNewRDN].Data.String()
+
+	if err := requestPacket.assert(ber.ClassUniversal, ber.TypePrimitive, withTag(ber.TagBoolean), withAssertChild(childDeleteOldRDN)); err != nil {
+		return nil, fmt.Errorf("%s: missing/invalid delete old RDN: %w", op, ErrInvalidParameter)
+	}
+	var ok bool
+	if parameters.deleteOldRDN, ok = requestPacket.Children[childDeleteOldRDN].Value.(bool); !ok {
+		return nil, fmt.Errorf("%s: delete old RDN is not a bool: %w", op, ErrInvalidParameter)
+	}
+
+	if len(requestPacket.Children) > childNewSuperior {
+		if err := requestPacket.assert(ber.ClassContext, ber.TypePrimitive, withTag(tagNewSuperior), withAssertChild(childNewSuperior)); err != nil {
+			return nil, fmt.Errorf("%s: invalid new superior: %w", op, ErrInvalidParameter)
+		}
+		parameters.newSuperior = requestPacket.Children[childNewSuperior].Data.String()
+	}
+
+	controlPacket, err := p.controlPacket()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if controlPacket != nil {
+		parameters.controls = make([]Control, 0, len(controlPacket.Children))
+		for _, c := range controlPacket.Children {
+			ctrl, err := decodeControl(c)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			parameters.controls = append(parameters.controls, ctrl)
+		}
+	}
+	return &parameters, nil
+}
+
 type searchParameters struct {
 	baseDN       string
 	scope        int64
@@ -542,8 +639,8 @@ func (p *packet) assertApplicationRequest() error {
 	}
 	switch chkPacket.TagType {
 	case ber.TypePrimitive:
-		if chkPacket.Tag != ApplicationDelRequest && chkPacket.Tag != ApplicationUnbindRequest {
-			return fmt.Errorf("%s: incorrect type, primitive %q must be a delete request %q or an unbind request %q, but got %q", op, ber.TypePrimitive, ApplicationDelRequest, ApplicationUnbindRequest, chkPacket.Tag)
+		if chkPacket.Tag != ApplicationDelRequest && chkPacket.Tag != ApplicationUnbindRequest && chkPacket.Tag != ApplicationAbandonRequest {
+			return fmt.Errorf("%s: incorrect type, primitive %q must be a delete request %q, an unbind request %q or an abandon request %q, but got %q", op, ber.TypePrimitive, ApplicationDelRequest, ApplicationUnbindRequest, ApplicationAbandonRequest, chkPacket.Tag)
 		}
 	case ber.TypeConstructed:
 	default:
@@ -553,11 +650,7 @@ func (p *packet) assertApplicationRequest() error {
 }
 
 func (p *packet) debug() {
-	testLogger := hclog.New(&hclog.LoggerOptions{
-		Name:  "debug-logger",
-		Level: hclog.Debug,
-	})
-	p.Log(testLogger.StandardWriter(&hclog.StandardLoggerOptions{}), 0, false)
+	p.Log(os.Stderr, 0, false)
 }
 
 // Log will pretty print log a packet
@@ -627,6 +720,25 @@ func (p *packet) deleteParameters() (string, []Control, error) {
 	return dn, controls, nil
 }
 
+func (p *packet) abandonMessageID() (int64, error) {
+	const op = "gldap.(packet).abandonMessageID"
+
+	requestPacket, err := p.requestPacket()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if requestPacket.Packet.Tag != ApplicationAbandonRequest {
+		return 0, fmt.Errorf("%s: not an abandon request, expected tag %d and got %d: %w", op, ApplicationAbandonRequest, requestPacket.Tag, ErrInvalidParameter)
+	}
+	// the abandon request is a primitive integer which the ber package
+	// doesn't decode for application tags.
+	id, err := ber.ParseInt64(requestPacket.Data.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid message id: %w", op, err)
+	}
+	return id, nil
+}
+
 var tagMap = map[ber.Tag]string{
 	ber.TagEOC:              "EOC (End-of-Content)",
 	ber.TagBoolean:          "Boolean",
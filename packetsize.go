@@ -0,0 +1,136 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// errRequestTooLarge is returned when a request's packet is larger than the
+// server's maximum request size (see: WithMaxRequestSize)
+var errRequestTooLarge = errors.New("request too large")
+
+// errMalformedPacket is returned when a request's packet isn't a valid BER
+// encoding for LDAP: a child's length exceeds its parent's content, or the
+// packet uses the indefinite length form, which LDAP doesn't allow (see:
+// https://tools.ietf.org/html/rfc4511#section-5.1)
+var errMalformedPacket = errors.New("malformed packet")
+
+// readBoundedPacket reads the encoded BER packet of a request, which must not be
+// larger than max bytes.  The packet's size is determined from its header
+// before it's read, so an enormous length is rejected without allocating
+// memory for it, and the lengths of the packet's children are verified before
+// it's decoded, since a child's content is allocated when it's decoded.
+func readBoundedPacket(r *bufio.Reader, max int) ([]byte, error) {
+	const op = "gldap.readBoundedPacket"
+	hdrLen, contentLen, err := berHeader(r.Peek)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if contentLen < 0 {
+		return nil, fmt.Errorf("%s: indefinite length: %w", op, errMalformedPacket)
+	}
+	if int64(hdrLen)+contentLen > int64(max) {
+		return nil, fmt.Errorf("%s: packet exceeds %d bytes: %w", op, max, errRequestTooLarge)
+	}
+	data := make([]byte, hdrLen+int(contentLen))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := verifyBERLengths(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return data, nil
+}
+
+// berHeader returns the length of a BER packet's header (its identifier and
+// length octets) along with the length of its content, which is -1 when the
+// packet uses the indefinite length form.  The header is read with peek, which
+// returns the first n bytes of the packet.
+func berHeader(peek func(n int) ([]byte, error)) (int, int64, error) {
+	const op = "gldap.berHeader"
+	const maxLengthOctets = 8
+	b, err := peek(1)
+	if err != nil {
+		return 0, 0, err
+	}
+	n := 1
+	if b[0]&0x1f == 0x1f {
+		// the high tag number form, whose last octet doesn't have bit 8 set
+		for {
+			n++
+			if b, err = peek(n); err != nil {
+				return 0, 0, err
+			}
+			if b[n-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	n++
+	if b, err = peek(n); err != nil {
+		return 0, 0, err
+	}
+	l := b[n-1]
+	switch {
+	case l < 0x80:
+		return n, int64(l), nil
+	case l == 0x80:
+		return n, -1, nil
+	}
+	numOctets := int(l & 0x7f)
+	if numOctets > maxLengthOctets {
+		return 0, 0, fmt.Errorf("%s: length has %d octets: %w", op, numOctets, errRequestTooLarge)
+	}
+	if b, err = peek(n + numOctets); err != nil {
+		return 0, 0, err
+	}
+	var length uint64
+	for _, o := range b[n : n+numOctets] {
+		length = length<<8 | uint64(o)
+	}
+	if length > math.MaxInt32 {
+		return 0, 0, fmt.Errorf("%s: length %d: %w", op, length, errRequestTooLarge)
+	}
+	return n + numOctets, int64(length), nil
+}
+
+// verifyBERLengths verifies that the content of every packet within the data,
+// including the children of constructed packets, fits within the data.  The
+// data has been read, so a header that doesn't fit within it is malformed
+// rather than truncated by the client closing its conn.
+func verifyBERLengths(data []byte) error {
+	const op = "gldap.verifyBERLengths"
+	for len(data) > 0 {
+		peek := func(n int) ([]byte, error) {
+			if n > len(data) {
+				return nil, errMalformedPacket
+			}
+			return data[:n], nil
+		}
+		hdrLen, contentLen, err := berHeader(peek)
+		switch {
+		case errors.Is(err, errRequestTooLarge):
+			return fmt.Errorf("%s: invalid packet length: %s: %w", op, err, errMalformedPacket)
+		case err != nil:
+			return fmt.Errorf("%s: invalid packet header: %w", op, err)
+		case contentLen < 0:
+			return fmt.Errorf("%s: indefinite length: %w", op, errMalformedPacket)
+		case contentLen > int64(len(data)-hdrLen):
+			return fmt.Errorf("%s: invalid packet length: %w", op, errMalformedPacket)
+		}
+		content := data[hdrLen : hdrLen+int(contentLen)]
+		if data[0]&0x20 != 0 { // constructed
+			if err := verifyBERLengths(content); err != nil {
+				return err
+			}
+		}
+		data = data[hdrLen+int(contentLen):]
+	}
+	return nil
+}
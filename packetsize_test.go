@@ -0,0 +1,222 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readBoundedPacket(t *testing.T) {
+	t.Parallel()
+	bind := testSimpleBindRequestPacket(t, SimpleBindMessage{baseMessage: baseMessage{id: 1}, UserName: "alice", Password: "password"}).Bytes()
+
+	tests := []struct {
+		name      string
+		data      []byte
+		max       int
+		want      []byte
+		wantErrIs error
+	}{
+		{
+			name: "valid",
+			data: bind,
+			max:  len(bind),
+			want: bind,
+		},
+		{
+			name:      "too-large",
+			data:      bind,
+			max:       len(bind) - 1,
+			wantErrIs: errRequestTooLarge,
+		},
+		{
+			name:      "enormous-length",
+			data:      []byte{0x30, 0x84, 0x7f, 0xff, 0xff, 0xff},
+			max:       1024,
+			wantErrIs: errRequestTooLarge,
+		},
+		{
+			name:      "length-overflow",
+			data:      []byte{0x30, 0x89, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			max:       1024,
+			wantErrIs: errRequestTooLarge,
+		},
+		{
+			name:      "indefinite-length",
+			data:      []byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00},
+			max:       1024,
+			wantErrIs: errMalformedPacket,
+		},
+		{
+			name: "child-exceeds-parent",
+			// a sequence of 6 bytes whose octet string child claims to be
+			// 2GB
+			data:      []byte{0x30, 0x06, 0x04, 0x84, 0x7f, 0xff, 0xff, 0xff},
+			max:       1024,
+			wantErrIs: errMalformedPacket,
+		},
+		{
+			name: "truncated-child-header",
+			// a sequence of 2 bytes whose octet string child's length octets
+			// are missing
+			data:      []byte{0x30, 0x02, 0x04, 0x82},
+			max:       1024,
+			wantErrIs: errMalformedPacket,
+		},
+		{
+			name:      "indefinite-length-child",
+			data:      []byte{0x30, 0x04, 0x30, 0x80, 0x00, 0x00},
+			max:       1024,
+			wantErrIs: errMalformedPacket,
+		},
+		{
+			name:      "truncated",
+			data:      bind[:len(bind)-1],
+			max:       1024,
+			wantErrIs: io.ErrUnexpectedEOF,
+		},
+		{
+			name:      "empty",
+			max:       1024,
+			wantErrIs: io.EOF,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := readBoundedPacket(bufio.NewReader(bytes.NewReader(tc.data)), tc.max)
+			if tc.wantErrIs != nil {
+				require.Error(err)
+				assert.ErrorIs(err, tc.wantErrIs)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func Test_berHeader(t *testing.T) {
+	t.Parallel()
+	peek := func(data []byte) func(int) ([]byte, error) {
+		return func(n int) ([]byte, error) {
+			if n > len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return data[:n], nil
+		}
+	}
+	tests := []struct {
+		name           string
+		data           []byte
+		wantHdrLen     int
+		wantContentLen int64
+	}{
+		{name: "short-form", data: []byte{0x30, 0x05}, wantHdrLen: 2, wantContentLen: 5},
+		{name: "long-form", data: []byte{0x30, 0x82, 0x01, 0x00}, wantHdrLen: 4, wantContentLen: 256},
+		{name: "high-tag-number", data: []byte{0x7f, 0x81, 0x01, 0x03}, wantHdrLen: 4, wantContentLen: 3},
+		{name: "indefinite", data: []byte{0x30, 0x80}, wantHdrLen: 2, wantContentLen: -1},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			hdrLen, contentLen, err := berHeader(peek(tc.data))
+			require.NoError(err)
+			assert.Equal(tc.wantHdrLen, hdrLen)
+			assert.Equal(tc.wantContentLen, contentLen)
+		})
+	}
+}
+
+func TestServer_maxRequestSize(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	s, _ := testServer(t, mux, WithMaxRequestSize(128))
+	c, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(err)
+	defer c.Close()
+	require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+
+	// requests within the limit are served
+	_, err = c.Write(testSimpleBindRequestPacket(t, SimpleBindMessage{baseMessage: baseMessage{id: 1}, UserName: "alice", Password: "password"}).Bytes())
+	require.NoError(err)
+	p, err := ber.ReadPacket(c)
+	require.NoError(err)
+	require.Len(p.Children, 2)
+	assert.Equal(int64(1), p.Children[0].Value)
+
+	// a larger request is responded to with a notice of disconnection
+	_, err = c.Write(testSimpleBindRequestPacket(t, SimpleBindMessage{baseMessage: baseMessage{id: 2}, UserName: "alice", Password: Password(strings.Repeat("x", 256))}).Bytes())
+	require.NoError(err)
+	p, err = ber.ReadPacket(c)
+	require.NoError(err)
+	require.Len(p.Children, 2)
+	assert.Equal(int64(0), p.Children[0].Value)
+	res := p.Children[1]
+	require.GreaterOrEqual(len(res.Children), 3)
+	assert.Equal(int64(ResultProtocolError), res.Children[0].Value)
+	assert.Equal(fmt.Sprintf("request exceeds the maximum size of %d bytes", 128), res.Children[2].Value)
+
+	// and the conn is closed
+	_, err = ber.ReadPacket(c)
+	assert.ErrorIs(err, io.EOF)
+}
+
+func TestServer_malformedRequest(t *testing.T) {
+	t.Parallel()
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	s, _ := testServer(t, mux, WithMaxRequestSize(128))
+
+	for name, data := range map[string][]byte{
+		"child-exceeds-parent": {0x30, 0x06, 0x04, 0x84, 0x7f, 0xff, 0xff, 0xff},
+		"indefinite-length":    {0x30, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			c, err := net.Dial("tcp", s.Addr().String())
+			require.NoError(err)
+			defer c.Close()
+			require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+
+			// a malformed request is responded to with a notice of
+			// disconnection
+			_, err = c.Write(data)
+			require.NoError(err)
+			p, err := ber.ReadPacket(c)
+			require.NoError(err)
+			require.Len(p.Children, 2)
+			assert.Equal(int64(0), p.Children[0].Value)
+			res := p.Children[1]
+			require.GreaterOrEqual(len(res.Children), 3)
+			assert.Equal(int64(ResultProtocolError), res.Children[0].Value)
+			assert.Equal("malformed request", res.Children[2].Value)
+
+			// and the conn is closed
+			_, err = ber.ReadPacket(c)
+			assert.ErrorIs(err, io.EOF)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// PasswordModifyMessage is an LDAP password modify extended operation request
+// message (see: https://tools.ietf.org/html/rfc3062)
+type PasswordModifyMessage struct {
+	baseMessage
+	// UserIdentity is the optional identity of the user whose password is
+	// being modified, which defaults to the user associated with the
+	// connection when empty
+	UserIdentity string
+	// OldPassword is the optional current password of the user
+	OldPassword Password
+	// NewPassword is the optional new password of the user.  When it's empty,
+	// the server should generate a password (see:
+	// ExtendedResponse.SetGeneratedPassword)
+	NewPassword Password
+}
+
+// GetPasswordModifyMessage retrieves the PasswordModifyMessage from an
+// ExtendedOperationPasswordModify request.  All of the request's fields are
+// optional, so a request without a value is valid.
+func (r *Request) GetPasswordModifyMessage() (*PasswordModifyMessage, error) {
+	const (
+		op = "gldap.(Request).GetPasswordModifyMessage"
+
+		tagUserIdentity = 0
+		tagOldPassword  = 1
+		tagNewPassword  = 2
+	)
+	m, err := r.GetExtendedOperationMessage()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	pm := &PasswordModifyMessage{
+		baseMessage: baseMessage{id: r.message.GetID()},
+	}
+	if m.Name == ExtendedOperationPasswordModify && m.Value == "" {
+		return pm, nil
+	}
+	seq, err := r.extendedOperationSequence(ExtendedOperationPasswordModify)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, child := range seq.Children {
+		if child.ClassType != ber.ClassContext {
+			return nil, fmt.Errorf("%s: unexpected class %d in password modify request: %w", op, child.ClassType, ErrInvalidParameter)
+		}
+		switch child.Tag {
+		case tagUserIdentity:
+			pm.UserIdentity = child.Data.String()
+		case tagOldPassword:
+			pm.OldPassword = Password(child.Data.String())
+		case tagNewPassword:
+			pm.NewPassword = Password(child.Data.String())
+		default:
+			return nil, fmt.Errorf("%s: unexpected tag %d in password modify request: %w", op, child.Tag, ErrInvalidParameter)
+		}
+	}
+	return pm, nil
+}
+
+// SetGeneratedPassword will set the response value of a password modify
+// extended operation response to the password generated by the server, which
+// is returned when the request didn't have a new password.
+func (r *ExtendedResponse) SetGeneratedPassword(p Password) {
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Password Modify Response")
+	seq.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, string(p), "genPasswd"))
+	r.value = seq.Bytes()
+}
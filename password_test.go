@@ -0,0 +1,127 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_GetPasswordModifyMessage(t *testing.T) {
+	passwdValue := func(children ...*ber.Packet) string {
+		seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Password Modify Request")
+		for _, c := range children {
+			seq.AppendChild(c)
+		}
+		return string(seq.Bytes())
+	}
+	field := func(tag ber.Tag, value string) *ber.Packet {
+		return ber.NewString(ber.ClassContext, ber.TypePrimitive, tag, value, "")
+	}
+	tests := []struct {
+		name            string
+		r               *Request
+		want            *PasswordModifyMessage
+		wantErrContains string
+	}{
+		{
+			name:            "not-extended",
+			r:               &Request{message: &SearchMessage{}},
+			wantErrContains: "not an extended operation request",
+		},
+		{
+			name:            "not-password-modify",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationWhoAmI, Value: passwdValue()}},
+			wantErrContains: "is not a 1.3.6.1.4.1.4203.1.11.1 request",
+		},
+		{
+			name:            "invalid-class",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationPasswordModify, Value: passwdValue(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "alice", ""))}},
+			wantErrContains: "unexpected class",
+		},
+		{
+			name:            "invalid-tag",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationPasswordModify, Value: passwdValue(field(3, "alice"))}},
+			wantErrContains: "unexpected tag 3",
+		},
+		{
+			name: "missing-value",
+			r:    &Request{message: &ExtendedOperationMessage{baseMessage: baseMessage{id: 2}, Name: ExtendedOperationPasswordModify}},
+			want: &PasswordModifyMessage{baseMessage: baseMessage{id: 2}},
+		},
+		{
+			name: "valid",
+			r: &Request{message: &ExtendedOperationMessage{
+				baseMessage: baseMessage{id: 2},
+				Name:        ExtendedOperationPasswordModify,
+				Value:       passwdValue(field(0, "uid=alice,ou=people,dc=example,dc=org"), field(1, "old"), field(2, "new")),
+			}},
+			want: &PasswordModifyMessage{
+				baseMessage:  baseMessage{id: 2},
+				UserIdentity: "uid=alice,ou=people,dc=example,dc=org",
+				OldPassword:  "old",
+				NewPassword:  "new",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := tc.r.GetPasswordModifyMessage()
+			if tc.wantErrContains != "" {
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tc.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestExtendedResponse_SetGeneratedPassword(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	msgCh := make(chan *PasswordModifyMessage, 1)
+	require.NoError(mux.ExtendedOperation(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetPasswordModifyMessage()
+		if err != nil {
+			_ = w.Write(r.NewExtendedResponse(WithResponseCode(ResultProtocolError)))
+			return
+		}
+		msgCh <- m
+		resp := r.NewExtendedResponse(WithResponseCode(ResultSuccess))
+		if m.NewPassword == "" {
+			resp.SetGeneratedPassword("generated")
+		}
+		_ = w.Write(resp)
+	}, ExtendedOperationPasswordModify))
+	_, url := testServer(t, mux)
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	res, err := client.PasswordModify(ldap.NewPasswordModifyRequest("uid=alice,ou=people,dc=example,dc=org", "old", ""))
+	require.NoError(err)
+	assert.Equal("generated", res.GeneratedPassword)
+	got := <-msgCh
+	assert.Equal("uid=alice,ou=people,dc=example,dc=org", got.UserIdentity)
+	assert.Equal(Password("old"), got.OldPassword)
+	assert.Empty(got.NewPassword)
+
+	res, err = client.PasswordModify(ldap.NewPasswordModifyRequest("", "old", "new"))
+	require.NoError(err)
+	assert.Empty(res.GeneratedPassword)
+	got = <-msgCh
+	assert.Equal(Password("new"), got.NewPassword)
+}
@@ -0,0 +1,353 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+)
+
+// Personality defines how a server presents itself to clients, so it can
+// resemble a specific directory product when testing product-specific client
+// code paths.  A personality defines the server's RootDSE contents (including
+// its vendorName, vendorVersion and supported controls) and the style of its
+// diagnostic messages.
+//
+// See: PersonalityOpenLDAP, Personality389DS, PersonalityEDirectory and
+// PersonalityActiveDirectory
+type Personality struct {
+	// Name of the personality
+	Name string
+
+	// VendorName is the RootDSE's vendorName (optional)
+	VendorName string
+
+	// VendorVersion is the RootDSE's vendorVersion (optional)
+	VendorVersion string
+
+	// ObjectClasses are the RootDSE's objectClass values
+	ObjectClasses []string
+
+	// SupportedControls are the RootDSE's supportedControl OIDs
+	SupportedControls []string
+
+	// SupportedExtensions are the RootDSE's supportedExtension OIDs
+	SupportedExtensions []string
+
+	// SupportedFeatures are the RootDSE's supportedFeatures OIDs
+	SupportedFeatures []string
+
+	// SupportedSASLMechanisms are the RootDSE's supportedSASLMechanisms
+	SupportedSASLMechanisms []string
+
+	// Attributes are additional product specific RootDSE attributes
+	Attributes map[string][]string
+
+	// Diagnostics maps result codes to the diagnostic message the product sends
+	// with them.  See: Personality.Diagnostic(...)
+	Diagnostics map[int]string
+}
+
+// RootDSE returns the personality's RootDSE entry (see:
+// https://datatracker.ietf.org/doc/html/rfc4512#section-5.1) with the naming
+// contexts.  Attributes without values are omitted.
+func (p *Personality) RootDSE(namingContexts ...string) *Entry {
+	attrs := map[string][]string{
+		"objectClass":          p.ObjectClasses,
+		"namingContexts":       namingContexts,
+		"supportedLDAPVersion": {"3"},
+		"supportedControl":     p.SupportedControls,
+		"supportedExtension":   p.SupportedExtensions,
+		"supportedFeatures":    p.SupportedFeatures,
+	}
+	if len(p.SupportedSASLMechanisms) > 0 {
+		attrs["supportedSASLMechanisms"] = p.SupportedSASLMechanisms
+	}
+	if p.VendorName != "" {
+		attrs["vendorName"] = []string{p.VendorName}
+	}
+	if p.VendorVersion != "" {
+		attrs["vendorVersion"] = []string{p.VendorVersion}
+	}
+	for name, values := range p.Attributes {
+		attrs[name] = values
+	}
+	for name, values := range attrs {
+		if len(values) == 0 {
+			delete(attrs, name)
+		}
+	}
+	return NewEntry("", attrs)
+}
+
+// RootDSEHandler returns a HandlerFunc which responds to RootDSE search requests
+// with the personality's RootDSE.  See: Mux.RootDSE(...)
+func (p *Personality) RootDSEHandler(namingContexts ...string) HandlerFunc {
+	return func(w *ResponseWriter, r *Request) {
+		e := p.RootDSE(namingContexts...)
+		m, err := r.GetSearchMessage()
+		if err != nil {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		requested := m.RequestedAttributes()
+		resp := r.NewSearchResponseEntry(e.DN)
+		for _, a := range e.Attributes {
+			if !requestedAttribute(requested, a.Name) {
+				continue
+			}
+			if m.TypesOnly {
+				resp.AddAttribute(a.Name, nil)
+				continue
+			}
+			resp.AddAttribute(a.Name, a.Values)
+		}
+		if err := w.Write(resp); err != nil {
+			return
+		}
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}
+}
+
+// requestedAttribute returns true if the attribute was requested.  The RootDSE
+// attributes are all operational, however most products return them when no
+// attributes (or "*") are requested, which this supports.
+func requestedAttribute(requested SearchAttributes, name string) bool {
+	return requested.WantsOperational() || requested.Wants(name)
+}
+
+// Diagnostic returns the personality's diagnostic message for the result code
+// or the defaultMsg when the personality doesn't define one.
+func (p *Personality) Diagnostic(code int, defaultMsg string) string {
+	if msg, ok := p.Diagnostics[code]; ok {
+		return msg
+	}
+	return defaultMsg
+}
+
+// String returns the personality's name
+func (p *Personality) String() string {
+	return fmt.Sprintf("Personality: %s", p.Name)
+}
+
+var (
+	// PersonalityOpenLDAP resembles an OpenLDAP (slapd) server, which doesn't
+	// publish a vendorName/vendorVersion and typically sends empty diagnostic
+	// messages.
+	PersonalityOpenLDAP = &Personality{
+		Name:          "OpenLDAP",
+		ObjectClasses: []string{"top", "OpenLDAProotDSE"},
+		SupportedControls: []string{
+			"2.16.840.1.113730.3.4.18", // proxied authorization
+			ControlTypeManageDsaIT,     // manage DSA IT
+			"1.3.6.1.4.1.4203.1.10.1",  // subentries
+			"1.3.6.1.1.22",             // don't use copy
+			"1.2.840.113556.1.4.819",   // LDAP_SERVER_SHUTDOWN_NOTIFY_OID
+			ControlTypePaging,          // paged results
+			"1.2.826.0.1.3344810.2.3",  // matched values
+			"1.3.6.1.1.13.2",           // post read
+			"1.3.6.1.1.13.1",           // pre read
+			ControlTypeAssertion,       // assertion
+			ControlTypeSyncRequest,     // content synchronization
+			"1.2.840.113556.1.4.473",   // server side sorting
+			"2.16.840.1.113730.3.4.9",  // virtual list view
+			ControlTypeBeheraPasswordPolicy,
+		},
+		SupportedExtensions: []string{
+			string(ExtendedOperationStartTLS),
+			"1.3.6.1.4.1.4203.1.11.1", // password modify
+			string(ExtendedOperationWhoAmI),
+			string(ExtendedOperationCancel),
+		},
+		SupportedFeatures: []string{
+			"1.3.6.1.1.14",           // modify-increment
+			"1.3.6.1.4.1.4203.1.5.1", // all operational attributes
+			"1.3.6.1.4.1.4203.1.5.2", // OC AD lists
+			"1.3.6.1.4.1.4203.1.5.3", // true/false filters
+			"1.3.6.1.4.1.4203.1.5.4", // language tag options
+			"1.3.6.1.4.1.4203.1.5.5", // language range options
+		},
+		SupportedSASLMechanisms: []string{"SCRAM-SHA-256", "SCRAM-SHA-1", "GS2-IAKERB", "GS2-KRB5", "GSSAPI", "DIGEST-MD5", "OTP", "CRAM-MD5", "PLAIN", "LOGIN"},
+		Attributes: map[string][]string{
+			"entryDN":           {""},
+			"subschemaSubentry": {"cn=Subschema"},
+		},
+		Diagnostics: map[int]string{
+			ResultInvalidCredentials:          "",
+			ResultNoSuchObject:                "",
+			ResultInsufficientAccessRights:    "no write access to parent",
+			ResultUnwillingToPerform:          "unauthenticated bind (DN with no password) disallowed",
+			ResultConfidentialityRequired:     "TLS confidentiality required",
+			ResultInappropriateAuthentication: "anonymous bind disallowed",
+		},
+	}
+
+	// Personality389DS resembles a 389 Directory Server (or Red Hat Directory
+	// Server)
+	Personality389DS = &Personality{
+		Name:          "389-ds",
+		VendorName:    "389 Project",
+		VendorVersion: "389-Directory/2.4.4 B2024.050.0000",
+		ObjectClasses: []string{"top"},
+		SupportedControls: []string{
+			"2.16.840.1.113730.3.4.2",   // manage DSA IT
+			"2.16.840.1.113730.3.4.3",   // persistent search
+			"2.16.840.1.113730.3.4.4",   // password expired
+			"2.16.840.1.113730.3.4.5",   // password expiring
+			"2.16.840.1.113730.3.4.9",   // virtual list view
+			"2.16.840.1.113730.3.4.16",  // authorization identity request
+			"2.16.840.1.113730.3.4.15",  // authorization identity response
+			"2.16.840.1.113730.3.4.17",  // real attributes only
+			"2.16.840.1.113730.3.4.19",  // virtual attributes only
+			"2.16.840.1.113730.3.4.12",  // proxied authorization (v1)
+			"2.16.840.1.113730.3.4.18",  // proxied authorization (v2)
+			"2.16.840.1.113730.3.4.13",  // replication update information
+			"1.2.840.113556.1.4.473",    // server side sorting
+			"1.2.840.113556.1.4.319",    // paged results
+			"1.3.6.1.1.13.1",            // pre read
+			"1.3.6.1.1.13.2",            // post read
+			"1.3.6.1.4.1.42.2.27.8.5.1", // password policy
+			ControlTypeGetEffectiveRights,
+			"1.3.6.1.4.1.4203.1.9.1.1", // content synchronization
+		},
+		SupportedExtensions: []string{
+			"2.16.840.1.113730.3.5.7", // transaction response
+			"2.16.840.1.113730.3.5.8", // transaction request
+			"2.16.840.1.113730.3.5.3", // start replication session
+			"2.16.840.1.113730.3.5.5", // end replication session
+			"2.16.840.1.113730.3.5.6", // replication entry
+			"1.3.6.1.4.1.4203.1.11.1", // password modify
+			string(ExtendedOperationWhoAmI),
+			string(ExtendedOperationStartTLS),
+		},
+		SupportedFeatures: []string{
+			"1.3.6.1.1.14",           // modify-increment
+			"1.3.6.1.4.1.4203.1.5.1", // all operational attributes
+		},
+		SupportedSASLMechanisms: []string{"EXTERNAL", "GSS-SPNEGO", "GSSAPI", "DIGEST-MD5", "CRAM-MD5", "PLAIN", "LOGIN", "ANONYMOUS"},
+		Attributes: map[string][]string{
+			"subschemaSubentry": {"cn=schema"},
+		},
+		Diagnostics: map[int]string{
+			ResultInvalidCredentials:       "Invalid credentials",
+			ResultNoSuchObject:             "",
+			ResultInsufficientAccessRights: "Insufficient 'write' privilege to the 'userPassword' attribute",
+			ResultUnwillingToPerform:       "Operation not allowed",
+		},
+	}
+
+	// PersonalityEDirectory resembles a NetIQ (Novell) eDirectory server, which
+	// sends NDS error diagnostic messages.
+	PersonalityEDirectory = &Personality{
+		Name:          "eDirectory",
+		VendorName:    "NetIQ Corporation",
+		VendorVersion: "LDAP Agent for NetIQ eDirectory 9.2.4 (40105.04)",
+		ObjectClasses: []string{"top"},
+		SupportedControls: []string{
+			"2.16.840.1.113730.3.4.2",       // manage DSA IT
+			"2.16.840.1.113730.3.4.3",       // persistent search
+			"2.16.840.1.113730.3.4.9",       // virtual list view
+			"2.16.840.1.113730.3.4.18",      // proxied authorization
+			"1.2.840.113556.1.4.473",        // server side sorting
+			"1.2.840.113556.1.4.319",        // paged results
+			"2.16.840.1.113719.1.27.101.5",  // sstatus
+			"2.16.840.1.113719.1.27.101.6",  // tree delete
+			"2.16.840.1.113719.1.27.101.40", // reference
+			"2.16.840.1.113719.1.27.103.7",  // grouping
+		},
+		SupportedExtensions: []string{
+			string(ExtendedOperationStartTLS),
+			"2.16.840.1.113719.1.27.100.1",  // ndsToLdapResponse
+			"2.16.840.1.113719.1.27.100.2",  // ndsToLdapRequest
+			"2.16.840.1.113719.1.27.100.33", // getEffectivePrivileges
+			"1.3.6.1.4.1.4203.1.11.1",       // password modify
+			string(ExtendedOperationGetBindDN),
+			string(ExtendedOperationWhoAmI),
+		},
+		Attributes: map[string][]string{
+			"dsaName":           {"cn=ldap-server,o=novell"},
+			"directoryTreeName": {"EDIR-TREE"},
+			"subschemaSubentry": {"cn=schema"},
+		},
+		Diagnostics: map[int]string{
+			ResultInvalidCredentials:       "NDS error: failed authentication (-669)",
+			ResultNoSuchObject:             "NDS error: no such entry (-601)",
+			ResultInsufficientAccessRights: "NDS error: no access (-672)",
+			ResultUnwillingToPerform:       "NDS error: login lockout (-197)",
+			ResultConstraintViolation:      "NDS error: password too short (-216)",
+		},
+	}
+
+	// PersonalityActiveDirectory resembles a Microsoft Active Directory domain
+	// controller, which doesn't publish a vendorName/vendorVersion and sends
+	// diagnostic messages with Windows error codes.  See: ADBindDiagnostic(...)
+	PersonalityActiveDirectory = &Personality{
+		Name:          "Active Directory",
+		ObjectClasses: []string{"top"},
+		SupportedControls: []string{
+			"1.2.840.113556.1.4.319",   // paged results
+			"1.2.840.113556.1.4.801",   // sd flags
+			"1.2.840.113556.1.4.473",   // server side sorting
+			"1.2.840.113556.1.4.528",   // notification
+			"1.2.840.113556.1.4.417",   // show deleted
+			"1.2.840.113556.1.4.619",   // lazy commit
+			"1.2.840.113556.1.4.841",   // dirsync
+			"1.2.840.113556.1.4.529",   // extended dn
+			"1.2.840.113556.1.4.805",   // tree delete
+			"1.2.840.113556.1.4.521",   // cross domain move target
+			"1.2.840.113556.1.4.970",   // get stats
+			"1.2.840.113556.1.4.1338",  // verify name
+			"1.2.840.113556.1.4.474",   // sort result
+			"1.2.840.113556.1.4.1339",  // domain scope
+			"1.2.840.113556.1.4.1340",  // search options
+			"1.2.840.113556.1.4.1413",  // permissive modify
+			"2.16.840.1.113730.3.4.9",  // virtual list view
+			"2.16.840.1.113730.3.4.10", // virtual list view response
+			"1.2.840.113556.1.4.1504",  // attribute scoped query
+			"1.2.840.113556.1.4.1852",  // quota control
+			"1.2.840.113556.1.4.802",   // range option
+			"1.2.840.113556.1.4.1907",  // shutdown notify
+			"1.2.840.113556.1.4.1948",  // range retrieval no error
+			"1.2.840.113556.1.4.1974",  // force update
+			"1.2.840.113556.1.4.1341",  // rodc dcpromo
+			"1.2.840.113556.1.4.2026",  // input dn
+			"1.2.840.113556.1.4.2064",  // show recycled
+			"1.2.840.113556.1.4.2065",  // show deactivated link
+			"1.2.840.113556.1.4.2066",  // policy hints (deprecated)
+			"1.2.840.113556.1.4.2090",  // dirsync ex
+			"1.2.840.113556.1.4.2205",  // update stats
+			"1.2.840.113556.1.4.2204",  // tree delete ex
+			"1.2.840.113556.1.4.2206",  // search hints
+			"1.2.840.113556.1.4.2211",  // expected entry count
+			"1.2.840.113556.1.4.2239",  // policy hints
+			"1.2.840.113556.1.4.2255",  // set owner
+			"1.2.840.113556.1.4.2256",  // bypass quota
+			"1.2.840.113556.1.4.2309",  // link ttl
+		},
+		SupportedExtensions: []string{
+			"1.3.6.1.4.1.1466.20037",     // start tls
+			"1.3.6.1.4.1.1466.101.119.1", // dynamic refresh
+			"1.2.840.113556.1.4.1781",    // fast concurrent bind
+			"1.3.6.1.4.1.4203.1.11.3",    // who am i
+			"1.2.840.113556.1.4.2212",    // batch request
+		},
+		SupportedSASLMechanisms: []string{"GSSAPI", "GSS-SPNEGO", "EXTERNAL", "DIGEST-MD5"},
+		Attributes: map[string][]string{
+			"supportedLDAPPolicies":         {"MaxPoolThreads", "MaxPercentDirSyncRequests", "MaxDatagramRecv", "MaxReceiveBuffer", "InitRecvTimeout", "MaxConnections", "MaxConnIdleTime", "MaxPageSize", "MaxBatchReturnMessages", "MaxQueryDuration", "MaxDirSyncDuration", "MaxTempTableSize", "MaxResultSetSize", "MinResultSets", "MaxResultSetsPerConn", "MaxNotificationPerConn", "MaxValRange", "MaxValRangeTransitive", "ThreadMemoryLimit", "SystemMemoryLimitPercent"},
+			"supportedCapabilities":         {"1.2.840.113556.1.4.800", "1.2.840.113556.1.4.1670", "1.2.840.113556.1.4.1791", "1.2.840.113556.1.4.1935", "1.2.840.113556.1.4.2080", "1.2.840.113556.1.4.2237"},
+			"domainFunctionality":           {"7"},
+			"forestFunctionality":           {"7"},
+			"domainControllerFunctionality": {"7"},
+			"isSynchronized":                {"TRUE"},
+			"isGlobalCatalogReady":          {"TRUE"},
+		},
+		Diagnostics: map[int]string{
+			ResultInvalidCredentials:       ADBindDiagnostic(ADBindErrorInvalidCredentials),
+			ResultNoSuchObject:             "0000208D: NameErr: DSID-03100241, problem 2001 (NO_OBJECT), data 0, best match of:\n\t''\n",
+			ResultInsufficientAccessRights: "00002098: SecErr: DSID-03150BC1, problem 4003 (INSUFF_ACCESS_RIGHTS), data 0\n",
+			ResultUnwillingToPerform:       "0000052D: SvcErr: DSID-031A126C, problem 5003 (WILL_NOT_PERFORM), data 0\n",
+			ResultOperationsError:          "000004DC: LdapErr: DSID-0C090A71, comment: In order to perform this operation a successful bind must be completed on the connection., data 0, v4563",
+			ResultConstraintViolation:      "0000052D: AtrErr: DSID-03191083, #1:\n\t0: 0000052D: DSID-03191083, problem 1005 (CONSTRAINT_ATT_TYPE), data 0, Att 9005a (unicodePwd)\n",
+		},
+	}
+)
@@ -0,0 +1,55 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersonality_RootDSE(t *testing.T) {
+	t.Parallel()
+	t.Run("presets", func(t *testing.T) {
+		for _, p := range []*Personality{PersonalityOpenLDAP, Personality389DS, PersonalityEDirectory, PersonalityActiveDirectory} {
+			assert, require := assert.New(t), require.New(t)
+			e := p.RootDSE("dc=example,dc=org")
+			require.NotNil(e)
+			assert.Equal("", e.DN, p.Name)
+			assert.Equal([]string{"dc=example,dc=org"}, e.GetAttributeValues("namingContexts"), p.Name)
+			assert.Equal([]string{"3"}, e.GetAttributeValues("supportedLDAPVersion"), p.Name)
+			assert.NotEmpty(e.GetAttributeValues("supportedControl"), p.Name)
+			assert.NotEmpty(e.GetAttributeValues("supportedExtension"), p.Name)
+			if p.VendorName != "" {
+				assert.Equal([]string{p.VendorName}, e.GetAttributeValues("vendorName"), p.Name)
+				assert.Equal([]string{p.VendorVersion}, e.GetAttributeValues("vendorVersion"), p.Name)
+			}
+		}
+	})
+	t.Run("omits-empty", func(t *testing.T) {
+		assert := assert.New(t)
+		p := &Personality{
+			Name:       "custom",
+			VendorName: "Example, Inc.",
+			Attributes: map[string][]string{"empty": nil, "custom": {"value"}},
+		}
+		e := p.RootDSE()
+		assert.Equal([]string{"Example, Inc."}, e.GetAttributeValues("vendorName"))
+		assert.Equal([]string{"value"}, e.GetAttributeValues("custom"))
+		for _, name := range []string{"empty", "vendorVersion", "namingContexts", "supportedControl", "objectClass"} {
+			assert.Empty(e.GetAttributeValues(name), name)
+		}
+	})
+}
+
+func TestPersonality_Diagnostic(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Equal("NDS error: failed authentication (-669)", PersonalityEDirectory.Diagnostic(ResultInvalidCredentials, "default"))
+	assert.Equal(ADBindDiagnostic(ADBindErrorInvalidCredentials), PersonalityActiveDirectory.Diagnostic(ResultInvalidCredentials, "default"))
+	assert.Equal("", PersonalityOpenLDAP.Diagnostic(ResultInvalidCredentials, "default"))
+	assert.Equal("default", Personality389DS.Diagnostic(ResultBusy, "default"))
+	assert.Equal("Personality: 389-ds", Personality389DS.String())
+}
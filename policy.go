@@ -0,0 +1,248 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is how often a rate limit forgets the keys (i.e.
+// IPs) whose token buckets have refilled, so its memory is bounded by the
+// number of keys that were used recently.
+const rateLimitSweepInterval = time.Minute
+
+// ConnectionPolicy defines a function which decides whether the server serves
+// a connection accepted from the remote address (see: WithConnectionPolicy).
+// The connection is closed without being served when the policy returns an
+// error.  A policy is called concurrently when the server has several
+// listeners.
+type ConnectionPolicy func(remoteAddr net.Addr) error
+
+// ConnectionPolicies returns a policy which rejects a connection when any of
+// the policies reject it, which are called in order (i.e. a denylist followed
+// by a rate limit).
+func ConnectionPolicies(policy ...ConnectionPolicy) ConnectionPolicy {
+	return func(remoteAddr net.Addr) error {
+		for _, p := range policy {
+			if p == nil {
+				continue
+			}
+			if err := p(remoteAddr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AllowCIDRs returns a policy which only allows connections from IPs within
+// the CIDRs (i.e. "10.0.0.0/8").  A CIDR without a prefix length (i.e.
+// "192.168.1.10") is a single IP.  Connections whose remote IP can't be
+// determined are rejected.
+func AllowCIDRs(cidr ...string) (ConnectionPolicy, error) {
+	const op = "gldap.AllowCIDRs"
+	prefixes, err := parsePrefixes(cidr...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return func(remoteAddr net.Addr) error {
+		const op = "gldap.(ConnectionPolicy).AllowCIDRs"
+		ip, ok := remoteIP(remoteAddr)
+		if !ok {
+			return fmt.Errorf("%s: unknown remote address %v: %w", op, remoteAddr, ErrConnectionRejected)
+		}
+		for _, p := range prefixes {
+			if p.Contains(ip) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %s isn't allowed: %w", op, ip, ErrConnectionRejected)
+	}, nil
+}
+
+// DenyCIDRs returns a policy which rejects connections from IPs within the
+// CIDRs (i.e. "10.0.0.0/8").  A CIDR without a prefix length (i.e.
+// "192.168.1.10") is a single IP.  Connections whose remote IP can't be
+// determined are allowed.
+func DenyCIDRs(cidr ...string) (ConnectionPolicy, error) {
+	const op = "gldap.DenyCIDRs"
+	prefixes, err := parsePrefixes(cidr...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return func(remoteAddr net.Addr) error {
+		const op = "gldap.(ConnectionPolicy).DenyCIDRs"
+		ip, ok := remoteIP(remoteAddr)
+		if !ok {
+			return nil
+		}
+		for _, p := range prefixes {
+			if p.Contains(ip) {
+				return fmt.Errorf("%s: %s is denied: %w", op, ip, ErrConnectionRejected)
+			}
+		}
+		return nil
+	}, nil
+}
+
+type rateLimitOptions struct {
+	withClock Clock
+}
+
+func rateLimitDefaults() rateLimitOptions {
+	return rateLimitOptions{
+		withClock: systemClock{},
+	}
+}
+
+func getRateLimitOpts(opt ...Option) rateLimitOptions {
+	opts := rateLimitDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// RateLimitPerIP returns a policy which limits the rate of the connections
+// from each remote IP with a token bucket, which holds up to burst tokens
+// and is refilled with perSecond tokens every second.  Every connection takes
+// a token from its IP's bucket, and it's rejected when the bucket is empty.
+// Connections whose remote IP can't be determined are allowed.
+//
+// Supported options: WithClock
+func RateLimitPerIP(perSecond float64, burst int, opt ...Option) (ConnectionPolicy, error) {
+	const op = "gldap.RateLimitPerIP"
+	switch {
+	case perSecond <= 0:
+		return nil, fmt.Errorf("%s: rate must be greater than zero: %w", op, ErrInvalidParameter)
+	case burst < 1:
+		return nil, fmt.Errorf("%s: burst must be at least one: %w", op, ErrInvalidParameter)
+	}
+	opts := getRateLimitOpts(opt...)
+	l := &ipRateLimiter{
+		rate:      perSecond,
+		burst:     float64(burst),
+		clock:     opts.withClock,
+		buckets:   map[netip.Addr]*tokenBucket{},
+		lastSweep: opts.withClock.Now(),
+	}
+	return func(remoteAddr net.Addr) error {
+		const op = "gldap.(ConnectionPolicy).RateLimitPerIP"
+		ip, ok := remoteIP(remoteAddr)
+		if !ok {
+			return nil
+		}
+		if !l.allow(ip) {
+			return fmt.Errorf("%s: %s exceeded its rate limit: %w", op, ip, ErrConnectionRejected)
+		}
+		return nil
+	}, nil
+}
+
+// ipRateLimiter has a token bucket for every IP that has connected recently
+type ipRateLimiter = rateLimiter[netip.Addr]
+
+// rateLimiter has a token bucket for every key that has been used recently
+type rateLimiter[K comparable] struct {
+	rate  float64
+	burst float64
+	clock Clock
+
+	mu        sync.Mutex
+	buckets   map[K]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the key's bucket, and returns false when it's empty
+func (l *rateLimiter[K]) allow(key K) bool {
+	now := l.clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		for k, b := range l.buckets {
+			if l.refill(b, now) >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if l.refill(b, now) < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the tokens accrued since the bucket was last refilled, and
+// returns the bucket's tokens.
+func (l *rateLimiter[K]) refill(b *tokenBucket, now time.Time) float64 {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+	return b.tokens
+}
+
+// parsePrefixes parses the CIDRs, which may be single IPs
+func parsePrefixes(cidr ...string) ([]netip.Prefix, error) {
+	const op = "gldap.parsePrefixes"
+	if len(cidr) == 0 {
+		return nil, fmt.Errorf("%s: missing cidrs: %w", op, ErrInvalidParameter)
+	}
+	prefixes := make([]netip.Prefix, 0, len(cidr))
+	for _, c := range cidr {
+		if !strings.Contains(c, "/") {
+			ip, err := netip.ParseAddr(c)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid cidr %q: %s: %w", op, c, err.Error(), ErrInvalidParameter)
+			}
+			ip = ip.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid cidr %q: %s: %w", op, c, err.Error(), ErrInvalidParameter)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// remoteIP returns the IP of the remote address, and false when it can't be
+// determined (i.e. the address of an in-memory connection).
+func remoteIP(addr net.Addr) (netip.Addr, bool) {
+	if addr == nil {
+		return netip.Addr{}, false
+	}
+	if a, ok := addr.(*net.TCPAddr); ok {
+		ip, ok := netip.AddrFromSlice(a.IP)
+		return ip.Unmap(), ok
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
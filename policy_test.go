@@ -0,0 +1,172 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tcpAddr(t *testing.T, ip string) net.Addr {
+	t.Helper()
+	parsed := net.ParseIP(ip)
+	require.NotNil(t, parsed)
+	return &net.TCPAddr{IP: parsed, Port: 12345}
+}
+
+func TestAllowCIDRs(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	_, err := AllowCIDRs()
+	assert.ErrorIs(err, ErrInvalidParameter)
+	_, err = AllowCIDRs("10.0.0.0/33")
+	assert.ErrorIs(err, ErrInvalidParameter)
+	_, err = AllowCIDRs("not-an-ip")
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	p, err := AllowCIDRs("10.0.0.0/8", "192.168.1.10", "fd00::/8")
+	require.NoError(err)
+	assert.NoError(p(tcpAddr(t, "10.1.2.3")))
+	assert.NoError(p(tcpAddr(t, "::ffff:10.1.2.3")))
+	assert.NoError(p(tcpAddr(t, "192.168.1.10")))
+	assert.NoError(p(tcpAddr(t, "fd00::1")))
+	assert.ErrorIs(p(tcpAddr(t, "192.168.1.11")), ErrConnectionRejected)
+	assert.ErrorIs(p(tcpAddr(t, "2001:db8::1")), ErrConnectionRejected)
+	// addresses without an IP are rejected
+	assert.ErrorIs(p(&net.UnixAddr{Name: "/tmp/ldap.sock", Net: "unix"}), ErrConnectionRejected)
+	assert.ErrorIs(p(nil), ErrConnectionRejected)
+}
+
+func TestDenyCIDRs(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	_, err := DenyCIDRs()
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	p, err := DenyCIDRs("10.0.0.0/8", "192.168.1.10")
+	require.NoError(err)
+	assert.ErrorIs(p(tcpAddr(t, "10.1.2.3")), ErrConnectionRejected)
+	assert.ErrorIs(p(tcpAddr(t, "192.168.1.10")), ErrConnectionRejected)
+	assert.NoError(p(tcpAddr(t, "192.168.1.11")))
+	// addresses without an IP are allowed
+	assert.NoError(p(&net.UnixAddr{Name: "/tmp/ldap.sock", Net: "unix"}))
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	_, err := RateLimitPerIP(0, 1)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	_, err = RateLimitPerIP(1, 0)
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	clock := NewTestClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p, err := RateLimitPerIP(2, 3, WithClock(clock))
+	require.NoError(err)
+	alice, bob := tcpAddr(t, "10.0.0.1"), tcpAddr(t, "10.0.0.2")
+
+	// the burst is allowed, and then the bucket is empty
+	for i := 0; i < 3; i++ {
+		assert.NoError(p(alice))
+	}
+	assert.ErrorIs(p(alice), ErrConnectionRejected)
+	// every IP has its own bucket
+	assert.NoError(p(bob))
+
+	// the bucket is refilled at the rate
+	clock.Advance(500 * time.Millisecond)
+	assert.NoError(p(alice))
+	assert.ErrorIs(p(alice), ErrConnectionRejected)
+
+	// the bucket never holds more than the burst
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.NoError(p(alice))
+	}
+	assert.ErrorIs(p(alice), ErrConnectionRejected)
+
+	// addresses without an IP are allowed
+	assert.NoError(p(&net.UnixAddr{Name: "/tmp/ldap.sock", Net: "unix"}))
+}
+
+func Test_ipRateLimiter_sweep(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	clock := NewTestClock(t, time.Now())
+	l := &ipRateLimiter{rate: 1, burst: 2, clock: clock, buckets: map[netip.Addr]*tokenBucket{}, lastSweep: clock.Now()}
+	alice, bob := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")
+	assert.True(l.allow(alice))
+	clock.Advance(rateLimitSweepInterval - time.Second)
+	assert.True(l.allow(bob))
+	assert.True(l.allow(bob))
+
+	// the IPs whose buckets have refilled are forgotten
+	clock.Advance(time.Second)
+	assert.True(l.allow(bob))
+	assert.NotContains(l.buckets, alice)
+	assert.Contains(l.buckets, bob)
+}
+
+func TestConnectionPolicies(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	errDenied := errors.New("denied")
+	var called []string
+	p := ConnectionPolicies(
+		func(net.Addr) error { called = append(called, "first"); return nil },
+		nil,
+		func(net.Addr) error { called = append(called, "second"); return errDenied },
+		func(net.Addr) error { called = append(called, "third"); return nil },
+	)
+	assert.ErrorIs(p(tcpAddr(t, "10.0.0.1")), errDenied)
+	assert.Equal([]string{"first", "second"}, called)
+	assert.NoError(ConnectionPolicies()(tcpAddr(t, "10.0.0.1")))
+}
+
+func TestServer_connectionPolicy(t *testing.T) {
+	t.Parallel()
+	startServer := func(t *testing.T, p ConnectionPolicy) string {
+		t.Helper()
+		require := require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}))
+		_, url := testServer(t, mux, WithConnectionPolicy(p))
+		return url
+	}
+	bind := func(t *testing.T, url string) error {
+		t.Helper()
+		client, err := ldap.DialURL(url)
+		require.NoError(t, err)
+		defer client.Close()
+		return client.Bind("cn=alice", "password")
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		p, err := AllowCIDRs("127.0.0.0/8")
+		require.NoError(t, err)
+		assert.NoError(t, bind(t, startServer(t, p)))
+	})
+	t.Run("denied", func(t *testing.T) {
+		p, err := DenyCIDRs("127.0.0.0/8")
+		require.NoError(t, err)
+		assert.Error(t, bind(t, startServer(t, p)))
+	})
+	t.Run("rate-limited", func(t *testing.T) {
+		p, err := RateLimitPerIP(0.001, 1)
+		require.NoError(t, err)
+		url := startServer(t, p)
+		assert.NoError(t, bind(t, url))
+		assert.Error(t, bind(t, url))
+	})
+}
@@ -0,0 +1,315 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// ChangeType is the type of change to an entry that's returned by a persistent
+// search (see: https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03).
+// Change types are bit flags and may be combined when requesting a persistent
+// search.
+type ChangeType int64
+
+const (
+	// ChangeTypeAdd indicates the entry was added
+	ChangeTypeAdd ChangeType = 1
+
+	// ChangeTypeDelete indicates the entry was deleted
+	ChangeTypeDelete ChangeType = 2
+
+	// ChangeTypeModify indicates the entry was modified
+	ChangeTypeModify ChangeType = 4
+
+	// ChangeTypeModDN indicates the entry was renamed
+	ChangeTypeModDN ChangeType = 8
+
+	// ChangeTypeAny is all the change types combined
+	ChangeTypeAny = ChangeTypeAdd | ChangeTypeDelete | ChangeTypeModify | ChangeTypeModDN
+)
+
+// ChangeTypeMap contains human readable descriptions of change types
+var ChangeTypeMap = map[ChangeType]string{
+	ChangeTypeAdd:    "add",
+	ChangeTypeDelete: "delete",
+	ChangeTypeModify: "modify",
+	ChangeTypeModDN:  "modDN",
+}
+
+// String returns the change types as a human readable list (i.e. "add|modify")
+func (t ChangeType) String() string {
+	var types []string
+	for _, ct := range []ChangeType{ChangeTypeAdd, ChangeTypeDelete, ChangeTypeModify, ChangeTypeModDN} {
+		if t&ct != 0 {
+			types = append(types, ChangeTypeMap[ct])
+		}
+	}
+	return strings.Join(types, "|")
+}
+
+// ControlPersistentSearch implements the persistent search control described
+// in https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+type ControlPersistentSearch struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// ChangeTypes are the types of changes the client wants returned
+	ChangeTypes ChangeType
+	// ChangesOnly indicates the client only wants changed entries returned
+	// (rather than first returning the entries matching the search)
+	ChangesOnly bool
+	// ReturnECs indicates the client wants an entry change notification
+	// control returned with every changed entry
+	ReturnECs bool
+}
+
+// GetControlType returns the OID
+func (c *ControlPersistentSearch) GetControlType() string {
+	return ControlTypePersistentSearch
+}
+
+// Encode returns the ber packet representation
+func (c *ControlPersistentSearch) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypePersistentSearch, "Control Type ("+ControlTypeMap[ControlTypePersistentSearch]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Persistent Search Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.ChangeTypes), "Change Types"))
+	seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ChangesOnly, "Changes Only"))
+	seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ReturnECs, "Return ECs"))
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Persistent Search)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlPersistentSearch) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  ChangeTypes: %s  ChangesOnly: %t  ReturnECs: %t",
+		ControlTypeMap[ControlTypePersistentSearch],
+		ControlTypePersistentSearch,
+		c.Criticality,
+		c.ChangeTypes,
+		c.ChangesOnly,
+		c.ReturnECs)
+}
+
+// NewControlPersistentSearch returns a persistent search control.  Supported
+// options: WithCriticality, WithChangesOnly and WithReturnECs
+func NewControlPersistentSearch(changeTypes ChangeType, opt ...Option) (*ControlPersistentSearch, error) {
+	const op = "gldap.NewControlPersistentSearch"
+	if changeTypes <= 0 || changeTypes&^ChangeTypeAny != 0 {
+		return nil, fmt.Errorf("%s: invalid change types %d: %w", op, changeTypes, ErrInvalidParameter)
+	}
+	opts := getControlOpts(opt...)
+	return &ControlPersistentSearch{
+		Criticality: opts.withCriticality,
+		ChangeTypes: changeTypes,
+		ChangesOnly: opts.withChangesOnly,
+		ReturnECs:   opts.withReturnECs,
+	}, nil
+}
+
+// ControlEntryChangeNotification implements the entry change notification
+// control described in
+// https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+type ControlEntryChangeNotification struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// ChangeType of the change
+	ChangeType ChangeType
+	// PreviousDN is the DN of the entry before it was renamed and is only set
+	// when the ChangeType is ChangeTypeModDN
+	PreviousDN string
+	// ChangeNumber is the optional change number of the change (see:
+	// https://tools.ietf.org/html/draft-good-ldap-changelog-04)
+	ChangeNumber *int64
+}
+
+// GetControlType returns the OID
+func (c *ControlEntryChangeNotification) GetControlType() string {
+	return ControlTypeEntryChangeNotification
+}
+
+// Encode returns the ber packet representation
+func (c *ControlEntryChangeNotification) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeEntryChangeNotification, "Control Type ("+ControlTypeMap[ControlTypeEntryChangeNotification]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Entry Change Notification Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.ChangeType), "Change Type"))
+	if c.PreviousDN != "" {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.PreviousDN, "Previous DN"))
+	}
+	if c.ChangeNumber != nil {
+		seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, *c.ChangeNumber, "Change Number"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Entry Change Notification)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlEntryChangeNotification) String() string {
+	changeNumber := "none"
+	if c.ChangeNumber != nil {
+		changeNumber = fmt.Sprintf("%d", *c.ChangeNumber)
+	}
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  ChangeType: %s  PreviousDN: %q  ChangeNumber: %s",
+		ControlTypeMap[ControlTypeEntryChangeNotification],
+		ControlTypeEntryChangeNotification,
+		c.Criticality,
+		c.ChangeType,
+		c.PreviousDN,
+		changeNumber)
+}
+
+// NewControlEntryChangeNotification returns an entry change notification
+// control for a single change.  Supported options: WithCriticality,
+// WithPreviousDN and WithChangeNumber
+func NewControlEntryChangeNotification(changeType ChangeType, opt ...Option) (*ControlEntryChangeNotification, error) {
+	const op = "gldap.NewControlEntryChangeNotification"
+	if _, ok := ChangeTypeMap[changeType]; !ok {
+		return nil, fmt.Errorf("%s: invalid change type %d: %w", op, changeType, ErrInvalidParameter)
+	}
+	opts := getControlOpts(opt...)
+	if opts.withPreviousDN != "" && changeType != ChangeTypeModDN {
+		return nil, fmt.Errorf("%s: previous DN is only valid for a %s change: %w", op, ChangeTypeModDN, ErrInvalidParameter)
+	}
+	return &ControlEntryChangeNotification{
+		Criticality:  opts.withCriticality,
+		ChangeType:   changeType,
+		PreviousDN:   opts.withPreviousDN,
+		ChangeNumber: opts.withChangeNumber,
+	}, nil
+}
+
+func decodeControlPersistentSearch(value *ber.Packet, criticality bool) (*ControlPersistentSearch, error) {
+	const op = "gldap.decodeControlPersistentSearch"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) != 3 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in persistent search control: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	changeTypes, ok := seq.Children[0].Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid change types: %w", op, ErrInvalidParameter)
+	}
+	changesOnly, ok := seq.Children[1].Value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid changes only: %w", op, ErrInvalidParameter)
+	}
+	returnECs, ok := seq.Children[2].Value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid return ECs: %w", op, ErrInvalidParameter)
+	}
+	c, err := NewControlPersistentSearch(ChangeType(changeTypes), WithCriticality(criticality), WithChangesOnly(changesOnly), WithReturnECs(returnECs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+func decodeControlEntryChangeNotification(value *ber.Packet, criticality bool) (*ControlEntryChangeNotification, error) {
+	const op = "gldap.decodeControlEntryChangeNotification"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) == 0 || len(seq.Children) > 3 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in entry change notification control: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	changeType, ok := seq.Children[0].Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid change type: %w", op, ErrInvalidParameter)
+	}
+	opts := []Option{WithCriticality(criticality)}
+	for _, child := range seq.Children[1:] {
+		switch child.Tag {
+		case ber.TagOctetString:
+			opts = append(opts, WithPreviousDN(child.Data.String()))
+		case ber.TagInteger:
+			n, _ := child.Value.(int64)
+			opts = append(opts, WithChangeNumber(n))
+		default:
+			return nil, fmt.Errorf("%s: unexpected tag %d in entry change notification control: %w", op, child.Tag, ErrInvalidParameter)
+		}
+	}
+	c, err := NewControlEntryChangeNotification(ChangeType(changeType), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+// GetPersistentSearchControl returns the request's persistent search control
+// and false if the request doesn't have one.
+func (r *Request) GetPersistentSearchControl() (*ControlPersistentSearch, bool) {
+	c, ok := r.findControl(ControlTypePersistentSearch).(*ControlPersistentSearch)
+	return c, ok
+}
+
+// WriteChange writes an entry change notification to the client as part of a
+// persistent search.  The change is not written if the client didn't request
+// the change type and an entry change notification control is included when
+// the client requested one.  A persistent search is long-lived, so the handler
+// should keep writing changes until the request's Context is done, which
+// happens when the client abandons the search, the client unbinds, the
+// connection is closed or the server is stopped.  Entries matching the search
+// should be written (using Write) before any changes, unless the request's
+// persistent search control specifies ChangesOnly.  The server's changes can be
+// received by subscribing to its change notifier (see: WithChangeNotifier).
+//
+// Supported options: WithPreviousDN and WithChangeNumber
+func (rw *ResponseWriter) WriteChange(e *Entry, changeType ChangeType, opt ...Option) error {
+	const op = "gldap.(ResponseWriter).WriteChange"
+	if e == nil {
+		return fmt.Errorf("%s: missing entry: %w", op, ErrInvalidParameter)
+	}
+	if _, ok := ChangeTypeMap[changeType]; !ok {
+		return fmt.Errorf("%s: invalid change type %d: %w", op, changeType, ErrInvalidParameter)
+	}
+	if rw.request == nil {
+		return fmt.Errorf("%s: missing request: %w", op, ErrInvalidParameter)
+	}
+	ps, ok := rw.request.GetPersistentSearchControl()
+	if !ok {
+		return fmt.Errorf("%s: not a persistent search request: %w", op, ErrInvalidParameter)
+	}
+	if ps.ChangeTypes&changeType == 0 {
+		return nil
+	}
+	resp := &SearchResponseEntry{
+		baseResponse: &baseResponse{
+			messageID: rw.messageID(),
+		},
+		entry: Entry{
+			DN:         e.DN,
+			Attributes: e.Attributes,
+		},
+	}
+	if ps.ReturnECs {
+		c, err := NewControlEntryChangeNotification(changeType, opt...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		resp.controls = []Control{c}
+	}
+	if err := rw.Write(resp); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
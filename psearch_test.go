@@ -0,0 +1,173 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlPersistentSearch(t *testing.T) {
+	runControlTest(t,
+		testControlPersistentSearch(t, ChangeTypeAdd|ChangeTypeModify, WithCriticality(true), WithChangesOnly(true), WithReturnECs(true)),
+		withTestType(ControlTypePersistentSearch),
+		withTestToString("Control Type: Persistent Search (\"2.16.840.1.113730.3.4.3\")  Criticality: true  ChangeTypes: add|modify  ChangesOnly: true  ReturnECs: true"),
+	)
+	runControlTest(t, testControlPersistentSearch(t, ChangeTypeAny))
+
+	_, err := NewControlPersistentSearch(0)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewControlPersistentSearch(16)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestControlEntryChangeNotification(t *testing.T) {
+	runControlTest(t,
+		testControlEntryChangeNotification(t, ChangeTypeModDN, WithPreviousDN("cn=alice,ou=people,dc=example,dc=org"), WithChangeNumber(42)),
+		withTestType(ControlTypeEntryChangeNotification),
+		withTestToString("Control Type: Entry Change Notification (\"2.16.840.1.113730.3.4.7\")  Criticality: false  ChangeType: modDN  PreviousDN: \"cn=alice,ou=people,dc=example,dc=org\"  ChangeNumber: 42"),
+	)
+	runControlTest(t, testControlEntryChangeNotification(t, ChangeTypeDelete))
+	runControlTest(t, testControlEntryChangeNotification(t, ChangeTypeModify, WithChangeNumber(0)))
+
+	_, err := NewControlEntryChangeNotification(ChangeTypeAdd | ChangeTypeDelete)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewControlEntryChangeNotification(ChangeTypeAdd, WithPreviousDN("cn=alice"))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestChangeType_String(t *testing.T) {
+	assert.Equal(t, "add", ChangeTypeAdd.String())
+	assert.Equal(t, "add|delete|modify|modDN", ChangeTypeAny.String())
+	assert.Equal(t, "", ChangeType(0).String())
+}
+
+func TestResponseWriter_WriteChange(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		// end ends the persistent search with the message ID 1
+		end func(t *testing.T, c net.Conn)
+	}{
+		{
+			name: "abandon",
+			end: func(t *testing.T, c net.Conn) {
+				_, err := c.Write(testAbandonRequestPacket(t, AbandonMessage{baseMessage: baseMessage{id: 2}, MessageID: 1}).Bytes())
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "unbind",
+			end: func(t *testing.T, c net.Conn) {
+				_, err := c.Write(testUnbindRequestPacket(t, UnbindMessage{baseMessage: baseMessage{id: 2}}).Bytes())
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "close",
+			end: func(t *testing.T, c net.Conn) {
+				require.NoError(t, c.Close())
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+
+			mux, err := NewMux()
+			require.NoError(err)
+			startedCh := make(chan struct{})
+			doneCh := make(chan error, 1)
+			require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+				close(startedCh)
+				changes := []struct {
+					entry      *Entry
+					changeType ChangeType
+					opts       []Option
+				}{
+					{entry: NewEntry("cn=alice,ou=people,dc=example,dc=org", map[string][]string{"cn": {"alice"}}), changeType: ChangeTypeAdd, opts: []Option{WithChangeNumber(1)}},
+					{entry: &Entry{DN: "cn=bob,ou=people,dc=example,dc=org"}, changeType: ChangeTypeDelete},
+					{entry: NewEntry("cn=eve,ou=people,dc=example,dc=org", map[string][]string{"cn": {"eve"}}), changeType: ChangeTypeModDN, opts: []Option{WithPreviousDN("cn=eve,ou=groups,dc=example,dc=org")}},
+				}
+				for _, c := range changes {
+					if err := w.WriteChange(c.entry, c.changeType, c.opts...); err != nil {
+						doneCh <- err
+						return
+					}
+				}
+				<-r.Context().Done()
+				doneCh <- r.Context().Err()
+			}))
+			s, _ := testServer(t, mux)
+
+			c, err := net.Dial("tcp", s.Addr().String())
+			require.NoError(err)
+			defer c.Close()
+
+			search := testSearchRequestPacket(t, SearchMessage{
+				baseMessage: baseMessage{id: 1},
+				BaseDN:      "ou=people,dc=example,dc=org",
+				Scope:       WholeSubtree,
+				Filter:      "(objectClass=*)",
+				Controls: []Control{
+					testControlPersistentSearch(t, ChangeTypeAdd|ChangeTypeModDN, WithChangesOnly(true), WithReturnECs(true)),
+				},
+			})
+			_, err = c.Write(search.Bytes())
+			require.NoError(err)
+			<-startedCh
+
+			// only the add and modDN changes were requested
+			var got []*ControlEntryChangeNotification
+			for i := 0; i < 2; i++ {
+				require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+				p, err := ber.ReadPacket(c)
+				require.NoError(err)
+				require.Len(p.Children, 3)
+				assert.Equal(int64(1), p.Children[0].Value)
+				assert.Equal(ber.Tag(ApplicationSearchResultEntry), p.Children[1].Tag)
+				require.Len(p.Children[2].Children, 1)
+				ctrl, err := decodeControl(p.Children[2].Children[0])
+				require.NoError(err)
+				ecn, ok := ctrl.(*ControlEntryChangeNotification)
+				require.True(ok)
+				got = append(got, ecn)
+			}
+			assert.Equal(ChangeTypeAdd, got[0].ChangeType)
+			require.NotNil(got[0].ChangeNumber)
+			assert.Equal(int64(1), *got[0].ChangeNumber)
+			assert.Equal(ChangeTypeModDN, got[1].ChangeType)
+			assert.Equal("cn=eve,ou=groups,dc=example,dc=org", got[1].PreviousDN)
+
+			tc.end(t, c)
+			select {
+			case err := <-doneCh:
+				assert.Error(err)
+			case <-time.After(5 * time.Second):
+				assert.Fail("persistent search was not cancelled")
+			}
+		})
+	}
+}
+
+func TestResponseWriter_WriteChange_errors(t *testing.T) {
+	rw := &ResponseWriter{}
+	err := rw.WriteChange(nil, ChangeTypeAdd)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	err = rw.WriteChange(&Entry{}, ChangeTypeAny)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	err = rw.WriteChange(&Entry{}, ChangeTypeAdd)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	rw.request = &Request{message: &SearchMessage{}}
+	err = rw.WriteChange(&Entry{}, ChangeTypeAdd)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	assert.Contains(t, err.Error(), "not a persistent search request")
+}
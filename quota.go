@@ -0,0 +1,105 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuotaExceededDiagnosticMessage is the diagnostic message of the
+// adminLimitExceeded responses to the requests rejected by a quota (see:
+// Quota)
+const QuotaExceededDiagnosticMessage = "request quota exceeded"
+
+// QuotaKeyFunc returns the key of the request's quota, so the requests with
+// the same key share a quota (see: WithQuotaKey)
+type QuotaKeyFunc func(r *Request) string
+
+// QuotaPerBoundDN is the default QuotaKeyFunc of a quota, which gives every
+// bound DN its own quota.  The anonymous requests share a quota.  DNs are
+// compared case-insensitively.
+func QuotaPerBoundDN(r *Request) string {
+	return strings.ToLower(r.BoundDN())
+}
+
+// QuotaShared is a QuotaKeyFunc which makes all the requests share one quota
+// (i.e. the quota of a backend, see: Mux.Mount)
+func QuotaShared(*Request) string {
+	return ""
+}
+
+type quotaOptions struct {
+	withClock Clock
+	withKey   QuotaKeyFunc
+}
+
+func quotaDefaults() quotaOptions {
+	return quotaOptions{
+		withClock: systemClock{},
+		withKey:   QuotaPerBoundDN,
+	}
+}
+
+func getQuotaOpts(opt ...Option) quotaOptions {
+	opts := quotaDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithQuotaKey sets the function which returns the key of a request's quota
+// (see: QuotaPerBoundDN and QuotaShared).  A nil function is ignored.
+func WithQuotaKey(fn QuotaKeyFunc) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*quotaOptions); ok && fn != nil {
+			o.withKey = fn
+		}
+	}
+}
+
+// Quota returns a Middleware that limits the requests served by the handlers
+// it wraps to perMinute requests a minute for each key (the request's bound
+// DN by default, see: WithQuotaKey), so one client (i.e. a team's sync job)
+// can't starve the others.  The quota is a token bucket that holds up to
+// perMinute tokens and is refilled continuously, and every request takes a
+// token.  A request that exceeds its quota is responded to with
+// adminLimitExceeded and QuotaExceededDiagnosticMessage.  The requests that
+// have no response (abandon and unbind requests) aren't limited.  The
+// middleware can be used with a mux for an overall quota, with a mounted
+// backend for a per-backend quota, or with a route for a per-route quota:
+//
+//	quota, _ := gldap.Quota(600, gldap.WithQuotaKey(gldap.QuotaShared))
+//	peopleBackend.Use(quota)
+//	_ = mux.With(perUserQuota).Search(searchHandler)
+//
+// Supported options: WithQuotaKey, WithClock
+func Quota(perMinute int, opt ...Option) (Middleware, error) {
+	const op = "gldap.Quota"
+	if perMinute < 1 {
+		return nil, fmt.Errorf("%s: quota must be at least one request a minute: %w", op, ErrInvalidParameter)
+	}
+	opts := getQuotaOpts(opt...)
+	l := &rateLimiter[string]{
+		rate:      float64(perMinute) / 60,
+		burst:     float64(perMinute),
+		clock:     opts.withClock,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: opts.withClock.Now(),
+	}
+	key := opts.withKey
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			switch r.routeOp {
+			case AbandonRouteOperation, UnbindRouteOperation:
+				next(w, r)
+				return
+			}
+			if !l.allow(key(r)) {
+				_ = w.Write(r.resultResponse(ResultAdminLimitExceeded, QuotaExceededDiagnosticMessage))
+				return
+			}
+			next(w, r)
+		}
+	}, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithQuotaKey(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getQuotaOpts(WithQuotaKey(QuotaShared))
+	assert.Equal("", opts.withKey(&Request{}))
+
+	// a nil key func is ignored
+	assert.NotNil(getQuotaOpts(WithQuotaKey(nil)).withKey)
+}
+
+func TestQuota(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	perDN, err := Quota(2, WithClock(clock))
+	require.NoError(t, err)
+	shared, err := Quota(3, WithQuotaKey(QuotaShared), WithClock(clock))
+	require.NoError(t, err)
+
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.With(perDN).Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	backend, err := NewMux()
+	require.NoError(t, err)
+	backend.Use(shared)
+	require.NoError(t, backend.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Mount("ou=people,dc=example,dc=org", backend))
+	_, url := testServer(t, mux)
+
+	dial := func(t *testing.T, dn string) *ldap.Conn {
+		t.Helper()
+		client, err := ldap.DialURL(url)
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Close() })
+		require.NoError(t, client.Bind(dn, "password"))
+		return client
+	}
+	search := func(client *ldap.Conn) error {
+		_, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		return err
+	}
+	del := func(client *ldap.Conn) error {
+		return client.Del(ldap.NewDelRequest("uid=eve,ou=people,dc=example,dc=org", nil))
+	}
+
+	assert := assert.New(t)
+	alice, bob := dial(t, "cn=alice"), dial(t, "cn=bob")
+	aliceAgain := dial(t, "CN=Alice")
+
+	// every bound DN has its own quota of the route
+	assert.NoError(search(alice))
+	assert.NoError(search(aliceAgain))
+	err = search(alice)
+	assert.True(ldap.IsErrorWithCode(err, ldap.LDAPResultAdminLimitExceeded))
+	assert.Contains(err.Error(), QuotaExceededDiagnosticMessage)
+	assert.NoError(search(bob))
+
+	// the quota is refilled over time
+	clock.Advance(30 * time.Second)
+	assert.NoError(search(alice))
+	assert.Error(search(alice))
+
+	// the backend's requests share its quota, and the other routes aren't
+	// limited
+	assert.NoError(del(alice))
+	assert.NoError(del(bob))
+	assert.NoError(del(aliceAgain))
+	assert.True(ldap.IsErrorWithCode(del(bob), ldap.LDAPResultAdminLimitExceeded))
+	assert.NoError(alice.Bind("cn=alice", "password"))
+
+	_, err = Quota(0)
+	assert.ErrorIs(err, ErrInvalidParameter)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// DefaultGeneratedPasswordLength is the length of the passwords generated by
+// GeneratePassword when a length isn't specified.
+const DefaultGeneratedPasswordLength = 16
+
+// generatedPasswordChars are the characters of the passwords generated by
+// GeneratePassword
+const generatedPasswordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+type randOptions struct {
+	withRandReader io.Reader
+}
+
+func randDefaults() randOptions {
+	return randOptions{
+		withRandReader: rand.Reader,
+	}
+}
+
+func getRandOpts(opt ...Option) randOptions {
+	opts := randDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithRandReader specifies an optional source of randomness, which defaults
+// to crypto/rand's Reader.  A deterministic reader (i.e. a seeded math/rand)
+// makes the generated values reproducible, which is useful for golden test
+// outputs, but it must never be used outside of tests.
+func WithRandReader(r io.Reader) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*randOptions); ok && !isNil(r) {
+			o.withRandReader = r
+		}
+	}
+}
+
+// NewEntryUUID generates a random (version 4) UUID for an entry's entryUUID
+// attribute, which is also the format of a sync state control's entryUUID
+// (see: https://tools.ietf.org/html/rfc4530 and ResponseWriter.WriteSyncEntry)
+//
+// Supported options: WithRandReader
+func NewEntryUUID(opt ...Option) ([16]byte, error) {
+	const op = "gldap.NewEntryUUID"
+	opts := getRandOpts(opt...)
+	var u [16]byte
+	if _, err := io.ReadFull(opts.withRandReader, u[:]); err != nil {
+		return [16]byte{}, fmt.Errorf("%s: unable to read random bytes: %w", op, err)
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return u, nil
+}
+
+// FormatEntryUUID formats the UUID in its string representation (i.e.
+// "6ba7b810-9dad-41d1-80b4-00c04fd430c8")
+func FormatEntryUUID(u [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// GeneratePassword generates a random alphanumeric password of the length,
+// or DefaultGeneratedPasswordLength when the length is zero, for a password
+// modify extended operation's response (see:
+// ExtendedResponse.SetGeneratedPassword)
+//
+// Supported options: WithRandReader
+func GeneratePassword(length int, opt ...Option) (Password, error) {
+	const op = "gldap.GeneratePassword"
+	switch {
+	case length < 0:
+		return "", fmt.Errorf("%s: invalid length %d: %w", op, length, ErrInvalidParameter)
+	case length == 0:
+		length = DefaultGeneratedPasswordLength
+	}
+	opts := getRandOpts(opt...)
+	// bytes are rejected rather than reduced modulo the number of characters,
+	// so every character is equally likely
+	const maxByte = 256 - (256 % len(generatedPasswordChars))
+	p := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(p) < length {
+		if _, err := io.ReadFull(opts.withRandReader, buf); err != nil {
+			return "", fmt.Errorf("%s: unable to read random bytes: %w", op, err)
+		}
+		for _, b := range buf {
+			if int(b) >= maxByte {
+				continue
+			}
+			p = append(p, generatedPasswordChars[int(b)%len(generatedPasswordChars)])
+			if len(p) == length {
+				break
+			}
+		}
+	}
+	return Password(p), nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"bytes"
+	"math/rand"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithRandReader(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	r := bytes.NewReader(nil)
+	opts := getRandOpts(WithRandReader(r))
+	testOpts := randDefaults()
+	testOpts.withRandReader = r
+	assert.Equal(opts, testOpts)
+
+	// a nil reader is ignored
+	assert.Equal(randDefaults(), getRandOpts(WithRandReader(nil)))
+}
+
+func TestNewEntryUUID(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	u, err := NewEntryUUID()
+	require.NoError(err)
+	assert.Regexp(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), FormatEntryUUID(u))
+
+	// a deterministic reader generates reproducible UUIDs
+	seeded := func() Option { return WithRandReader(rand.New(rand.NewSource(1))) }
+	u1, err := NewEntryUUID(seeded())
+	require.NoError(err)
+	u2, err := NewEntryUUID(seeded())
+	require.NoError(err)
+	assert.Equal(u1, u2)
+
+	_, err = NewEntryUUID(WithRandReader(bytes.NewReader([]byte{1, 2, 3})))
+	assert.Error(err)
+}
+
+func TestGeneratePassword(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	p, err := GeneratePassword(0)
+	require.NoError(err)
+	assert.Regexp(regexp.MustCompile(`^[a-zA-Z0-9]{16}$`), string(p))
+	p, err = GeneratePassword(40)
+	require.NoError(err)
+	assert.Len(p, 40)
+
+	seeded := func() Option { return WithRandReader(rand.New(rand.NewSource(1))) }
+	p1, err := GeneratePassword(24, seeded())
+	require.NoError(err)
+	p2, err := GeneratePassword(24, seeded())
+	require.NoError(err)
+	assert.Equal(p1, p2)
+
+	// bytes beyond the characters are rejected
+	p, err = GeneratePassword(2, WithRandReader(bytes.NewReader([]byte{255, 0, 255, 1})))
+	require.NoError(err)
+	assert.Equal(Password("ab"), p)
+
+	_, err = GeneratePassword(-1)
+	assert.ErrorIs(err, ErrInvalidParameter)
+	_, err = GeneratePassword(4, WithRandReader(bytes.NewReader(nil)))
+	assert.Error(err)
+}
@@ -0,0 +1,31 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+// DefaultReadOnlyDiagnosticMessage is the default diagnostic message of the
+// responses to update requests rejected by a read-only server (see:
+// WithReadOnly)
+const DefaultReadOnlyDiagnosticMessage = "server is read-only"
+
+// isUpdateRequest returns true if the request is an update request (i.e. an
+// add, modify, modify DN, delete or password modify request).
+func isUpdateRequest(r *Request) bool {
+	switch r.routeOp {
+	case AddRouteOperation, ModifyRouteOperation, ModifyDNRouteOperation, DeleteRouteOperation:
+		return true
+	case ExtendedRouteOperation:
+		return r.extendedName == ExtendedOperationPasswordModify
+	default:
+		return false
+	}
+}
+
+// serveReadOnly rejects an update request to a read-only server.
+func (c *conn) serveReadOnly(w *ResponseWriter, r *Request) {
+	diag := c.readOnlyDiag
+	if diag == "" {
+		diag = DefaultReadOnlyDiagnosticMessage
+	}
+	_ = w.Write(r.resultResponse(ResultUnwillingToPerform, diag))
+}
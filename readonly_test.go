@@ -0,0 +1,82 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WithReadOnly(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		opts     []ServerOption
+		wantDiag string
+	}{
+		{
+			name:     "default-diagnostic",
+			opts:     []ServerOption{WithReadOnly()},
+			wantDiag: DefaultReadOnlyDiagnosticMessage,
+		},
+		{
+			name:     "diagnostic",
+			opts:     []ServerOption{WithReadOnly(), WithReadOnlyDiagnosticMessage("read-only replica")},
+			wantDiag: "read-only replica",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			mux, err := NewMux()
+			require.NoError(err)
+			success := func(w *ResponseWriter, r *Request) {
+				_ = w.Write(r.resultResponse(ResultSuccess, ""))
+			}
+			require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+				_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org"))
+				_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+			}))
+			require.NoError(mux.Add(success))
+			require.NoError(mux.Modify(success))
+			require.NoError(mux.ModifyDN(success))
+			require.NoError(mux.Delete(success))
+			require.NoError(mux.ExtendedOperation(success, ExtendedOperationPasswordModify))
+			_, url := testServer(t, mux, tc.opts...)
+			client, err := ldap.DialURL(url)
+			require.NoError(err)
+			defer client.Close()
+
+			// searches are still routed to the server's handlers
+			res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+			require.NoError(err)
+			assert.Len(res.Entries, 1)
+
+			const dn = "cn=alice,dc=example,dc=org"
+			add := ldap.NewAddRequest(dn, nil)
+			add.Attribute("cn", []string{"alice"})
+			mod := ldap.NewModifyRequest(dn, nil)
+			mod.Replace("mail", []string{"alice@example.org"})
+			_, passwdErr := client.PasswordModify(ldap.NewPasswordModifyRequest(dn, "old", "new"))
+			for name, err := range map[string]error{
+				"add":             client.Add(add),
+				"modify":          client.Modify(mod),
+				"modify-dn":       client.ModifyDN(ldap.NewModifyDNRequest(dn, "cn=alicia", true, "")),
+				"delete":          client.Del(ldap.NewDelRequest(dn, nil)),
+				"password-modify": passwdErr,
+			} {
+				require.Error(err, name)
+				var ldapErr *ldap.Error
+				require.ErrorAs(err, &ldapErr, name)
+				assert.Equal(uint16(ResultUnwillingToPerform), ldapErr.ResultCode, name)
+				assert.Contains(ldapErr.Err.Error(), tc.wantDiag, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+// DefaultRedactedAttributes are the sensitive attributes removed from search
+// result entries by the RedactAttributes middleware by default.
+var DefaultRedactedAttributes = []string{"userPassword", "unixUserPassword", "krbPrincipalKey"}
+
+type redactOptions struct {
+	withAttributes []string
+	withAdminDNs   []string
+}
+
+func redactDefaults() redactOptions {
+	return redactOptions{
+		withAttributes: DefaultRedactedAttributes,
+	}
+}
+
+func getRedactOpts(opt ...Option) redactOptions {
+	opts := redactDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithRedactedAttributes sets the attributes removed from search result
+// entries by the RedactAttributes middleware, which replace the
+// DefaultRedactedAttributes.  Attribute names are compared
+// case-insensitively.
+func WithRedactedAttributes(name ...string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*redactOptions); ok {
+			o.withAttributes = name
+		}
+	}
+}
+
+// WithRedactionAdminDNs sets the DNs of the admins whose sessions are exempt
+// from the RedactAttributes middleware, so a conn bound as one of them gets
+// the entries unredacted.
+func WithRedactionAdminDNs(dn ...string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*redactOptions); ok {
+			o.withAdminDNs = dn
+		}
+	}
+}
+
+// RedactAttributes returns a Middleware that removes sensitive attributes
+// (DefaultRedactedAttributes, unless WithRedactedAttributes is used) from the
+// search result entries written by the mux's handlers, unless the request's
+// conn is bound as one of the admin DNs (see: WithRedactionAdminDNs).
+//
+// Options supported: WithRedactedAttributes, WithRedactionAdminDNs
+func RedactAttributes(opt ...Option) Middleware {
+	opts := getRedactOpts(opt...)
+	attrs := append([]string{}, opts.withAttributes...)
+	adminDNs := append([]string{}, opts.withAdminDNs...)
+	redact := func(resp Response) Response {
+		if e, ok := resp.(*SearchResponseEntry); ok {
+			e.RemoveAttribute(attrs...)
+		}
+		return resp
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			if len(attrs) == 0 || isAdminSession(r, adminDNs) {
+				next(w, r)
+				return
+			}
+			next(w.WithInterceptor(redact), r)
+		}
+	}
+}
+
+// isAdminSession returns true if the request's conn is bound as one of the
+// admin DNs.
+func isAdminSession(r *Request, adminDNs []string) bool {
+	if len(adminDNs) == 0 {
+		return false
+	}
+	boundDN := r.BoundDN()
+	if boundDN == "" {
+		return false
+	}
+	for _, dn := range adminDNs {
+		if ok, err := dnInScope(boundDN, dn, BaseObject); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactAttributes(t *testing.T) {
+	t.Parallel()
+	const adminDN = "cn=admin,dc=example,dc=org"
+	tests := []struct {
+		name      string
+		opts      []Option
+		bindDN    string
+		wantAttrs []string
+	}{
+		{
+			name:      "anonymous",
+			opts:      []Option{WithRedactionAdminDNs(adminDN)},
+			wantAttrs: []string{"cn", "mail"},
+		},
+		{
+			name:      "user",
+			opts:      []Option{WithRedactionAdminDNs(adminDN)},
+			bindDN:    "cn=alice,dc=example,dc=org",
+			wantAttrs: []string{"cn", "mail"},
+		},
+		{
+			name:      "admin",
+			opts:      []Option{WithRedactionAdminDNs(adminDN)},
+			bindDN:    "CN=Admin, DC=example, DC=org",
+			wantAttrs: []string{"cn", "mail", "userPassword", "unixUserPassword", "krbPrincipalKey"},
+		},
+		{
+			name:      "attributes",
+			opts:      []Option{WithRedactedAttributes("MAIL")},
+			wantAttrs: []string{"cn", "userPassword", "unixUserPassword", "krbPrincipalKey"},
+		},
+		{
+			name:      "no-attributes",
+			opts:      []Option{WithRedactedAttributes()},
+			wantAttrs: []string{"cn", "mail", "userPassword", "unixUserPassword", "krbPrincipalKey"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			mux, err := NewMux()
+			require.NoError(err)
+			mux.Use(RedactAttributes(tc.opts...))
+			require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+				_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+			}))
+			require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+				_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org", WithAttributes(map[string][]string{
+					"cn":               {"alice"},
+					"mail":             {"alice@example.org"},
+					"userPassword":     {"{SSHA}secret"},
+					"unixUserPassword": {"secret"},
+					"krbPrincipalKey":  {"key"},
+				})))
+				_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+			}))
+			_, url := testServer(t, mux)
+			client, err := ldap.DialURL(url)
+			require.NoError(err)
+			defer client.Close()
+			if tc.bindDN != "" {
+				require.NoError(client.Bind(tc.bindDN, "password"))
+			}
+
+			res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+			require.NoError(err)
+			require.Len(res.Entries, 1)
+			var gotAttrs []string
+			for _, a := range res.Entries[0].Attributes {
+				gotAttrs = append(gotAttrs, a.Name)
+			}
+			assert.ElementsMatch(tc.wantAttrs, gotAttrs)
+		})
+	}
+}
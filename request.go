@@ -4,11 +4,17 @@
 package gldap
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
 )
 
 // ExtendedOperationName is an extended operation request/response name
@@ -16,13 +22,19 @@ type ExtendedOperationName string
 
 // Extended operation response/request names
 const (
-	ExtendedOperationDisconnection   ExtendedOperationName = "1.3.6.1.4.1.1466.2003"
+	ExtendedOperationDisconnection   ExtendedOperationName = "1.3.6.1.4.1.1466.20036"
 	ExtendedOperationCancel          ExtendedOperationName = "1.3.6.1.1.8"
 	ExtendedOperationStartTLS        ExtendedOperationName = "1.3.6.1.4.1.1466.20037"
 	ExtendedOperationWhoAmI          ExtendedOperationName = "1.3.6.1.4.1.4203.1.11.3"
 	ExtendedOperationGetConnectionID ExtendedOperationName = "1.3.6.1.4.1.26027.1.6.2"
 	ExtendedOperationPasswordModify  ExtendedOperationName = "1.3.6.1.4.1.4203.1.11.1"
+	ExtendedOperationTurn            ExtendedOperationName = "1.3.6.1.1.19"
 	ExtendedOperationUnknown         ExtendedOperationName = "Unknown"
+
+	// ExtendedOperationGetBindDN is the eDirectory variant of the "Who am I?"
+	// operation and ExtendedOperationGetBindDNResponse is its response name.
+	ExtendedOperationGetBindDN         ExtendedOperationName = "2.16.840.1.113719.1.27.100.31"
+	ExtendedOperationGetBindDNResponse ExtendedOperationName = "2.16.840.1.113719.1.27.100.32"
 )
 
 // Request represents an ldap request
@@ -34,8 +46,58 @@ type Request struct {
 	// conn is needed this for cancellation among other things.
 	conn         *conn
 	message      Message
-	routeOp      routeOperation
+	packet       *packet
+	routeOp      RouteOperation
 	extendedName ExtendedOperationName
+
+	// extendedEncodeFn encodes the values of extended responses (see:
+	// Mux.ExtendedOperationWithCodec)
+	extendedEncodeFn ExtendedEncodeFunc
+
+	// ctx is cancelled when the request is abandoned, the client unbinds,
+	// the conn is closed, or the server is stopped.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{} // closed when an in-flight request has completed
+
+	// stateMu protects the request's cancellation state (see:
+	// ExtendedOperationCancel)
+	stateMu   sync.Mutex
+	responded bool
+	canceled  bool
+	timedOut  bool
+
+	// routeDeadline is the deadline of the request's route (see:
+	// WithRouteTimeout), which is protected by stateMu
+	routeDeadline time.Time
+
+	// received is when the request was read
+	received time.Time
+
+	// correlationID is the request's unique ID (see: CorrelationID)
+	correlationID string
+
+	// filterOnce compiles the filter of a search request once, for all the
+	// search routes it's matched against (see: searchFilter)
+	filterOnce sync.Once
+	filter     *ber.Packet
+	filterErr  error
+
+	// entries is the number of search result entries written to the request
+	// (see: WithAccessLog)
+	entries atomic.Int64
+
+	// span is the span of the request's operation (see: WithTracer)
+	span *requestSpan
+
+	// writeHook is called with the responses written to the request (see:
+	// WithSingleflight)
+	writeHook func(Response)
+
+	// state is the connection state of a request without a conn (see:
+	// ConnectionState)
+	stateOnce sync.Once
+	state     *ConnectionState
 }
 
 func newRequest(id int, c *conn, p *packet) (*Request, error) {
@@ -52,23 +114,27 @@ func newRequest(id int, c *conn, p *packet) (*Request, error) {
 		return nil, fmt.Errorf("%s: unable to build message for request %d: %w", op, id, err)
 	}
 	var extendedName ExtendedOperationName
-	var routeOp routeOperation
+	var routeOp RouteOperation
 	switch v := m.(type) {
 	case *SimpleBindMessage:
-		routeOp = bindRouteOperation
+		routeOp = BindRouteOperation
 	case *SearchMessage:
-		routeOp = searchRouteOperation
+		routeOp = SearchRouteOperation
 	case *ExtendedOperationMessage:
-		routeOp = extendedRouteOperation
+		routeOp = ExtendedRouteOperation
 		extendedName = v.Name
 	case *ModifyMessage:
-		routeOp = modifyRouteOperation
+		routeOp = ModifyRouteOperation
+	case *ModifyDNMessage:
+		routeOp = ModifyDNRouteOperation
 	case *AddMessage:
-		routeOp = addRouteOperation
+		routeOp = AddRouteOperation
 	case *DeleteMessage:
-		routeOp = deleteRouteOperation
+		routeOp = DeleteRouteOperation
 	case *UnbindMessage:
-		routeOp = unbindRouteOperation
+		routeOp = UnbindRouteOperation
+	case *AbandonMessage:
+		routeOp = AbandonRouteOperation
 	default:
 		// this should be unreachable, since newMessage defaults to returning an
 		// *ExtendedOperationMessage
@@ -76,15 +142,34 @@ func newRequest(id int, c *conn, p *packet) (*Request, error) {
 	}
 
 	r := &Request{
-		ID:           id,
-		conn:         c,
-		message:      m,
-		routeOp:      routeOp,
-		extendedName: extendedName,
+		ID:            id,
+		conn:          c,
+		message:       m,
+		packet:        p,
+		routeOp:       routeOp,
+		extendedName:  extendedName,
+		correlationID: fmt.Sprintf("%d-%d-%d", c.connID, m.GetID(), requestSeq.Add(1)),
 	}
 	return r, nil
 }
 
+// requestSeq is the sequence of the requests read by the process's servers,
+// which makes their correlation IDs unique (see: Request.CorrelationID)
+var requestSeq atomic.Uint64
+
+// CorrelationID returns the request's unique ID, which is its connection ID,
+// its message ID and the sequence number of the request among all the requests
+// read by the process's servers (i.e. "3-12-105").  The server's logs, access
+// log records (see: WithAccessLog) and spans (see: WithTracer) include it, so
+// they can be correlated with the logs of the request's handler.  It's empty
+// for a request that wasn't read from a connection.
+func (r *Request) CorrelationID() string {
+	if r == nil {
+		return ""
+	}
+	return r.correlationID
+}
+
 // ConnectionID returns the request's connection ID which enables you to know
 // "who" (i.e. which connection) made a request. Using the connection ID you
 // can do things like ensure a connection performing a search operation has
@@ -93,10 +178,103 @@ func (r *Request) ConnectionID() int {
 	return r.conn.connID
 }
 
+// ConnectionTLSState returns the TLS state of the request's connection, which
+// is nil until the connection is secured by a TLS listener (see:
+// WithTLSConfig) or a StartTLS request (see: WithStartTLS).  When the server's
+// tls.Config requests client certificates (i.e. its ClientAuth is
+// tls.RequireAndVerifyClientCert), the state's PeerCertificates and
+// VerifiedChains are the client's, which allows a bind handler to implement
+// certificate based authentication (i.e. a SASL EXTERNAL bind).  The returned
+// state must not be modified.
+func (r *Request) ConnectionTLSState() *tls.ConnectionState {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.tlsState.Load()
+}
+
+// BoundDN returns the DN established by the last successful simple bind of the
+// request's connection, which is empty when the connection is anonymous.  The
+// connection is anonymous until its first successful bind, while a bind is in
+// progress (a bind request resets the bound DN before it's handled, see:
+// https://tools.ietf.org/html/rfc4513#section-5.1), after a failed bind or an
+// unauthenticated bind (a bind with a name and no password), and once it has
+// unbound.  Authorization middleware can rely on it rather than tracking binds
+// themselves.
+func (r *Request) BoundDN() string {
+	if r.conn == nil {
+		return ""
+	}
+	return r.conn.getBoundDN()
+}
+
+// RemoteAddr returns the network address of the client of the request's
+// connection (i.e. to build audit logs or to implement per-client behavior),
+// which is nil when the request wasn't read from a connection.
+func (r *Request) RemoteAddr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.remoteAddr
+}
+
+// LocalAddr returns the server's network address the request's connection was
+// accepted on (i.e. to tell apart the requests of a server's listeners, see:
+// Server.Serve), which is nil when the request wasn't read from a connection.
+func (r *Request) LocalAddr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.localAddr
+}
+
+// Context returns the request's context.  The context is cancelled when the
+// client abandons the request, the client unbinds, the connection is closed or
+// the server is stopped; which makes it useful for long-lived operations like
+// a persistent search.  It has a deadline when the request's route has a
+// timeout (see: WithRouteTimeout).  It carries the span of the request's
+// operation when the server has a tracer (see: WithTracer).
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		if r.span != nil {
+			return r.span.ctx
+		}
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Message returns the request's decoded message, which is one of the message
+// types (i.e. *SearchMessage) that are also returned by the request's typed
+// getters (i.e. GetSearchMessage).
+func (r *Request) Message() Message {
+	return r.message
+}
+
+// Packet returns a copy of the request's raw BER packet, so a handler can
+// inspect the parts of a request that aren't decoded into its message (i.e.
+// unknown controls or vendor specific fields).  The packet is the request's
+// LDAPMessage (see: https://tools.ietf.org/html/rfc4511#section-4.1.1), whose
+// children are its message ID, protocol operation and optional controls.
+// Changes to the copy don't affect the request, and it's nil when the request
+// wasn't read from a connection.
+func (r *Request) Packet() *ber.Packet {
+	if r.packet == nil || r.packet.Packet == nil {
+		return nil
+	}
+	p, err := ber.DecodePacketErr(r.packet.Bytes())
+	if err != nil {
+		// unreachable, since the packet was decoded when it was read
+		return nil
+	}
+	return p
+}
+
 // NewModifyResponse creates a modify response
 // Supported options: WithResponseCode, WithDiagnosticMessage, WithMatchedDN
-func (r *Request) NewModifyResponse(opt ...Option) *ModifyResponse {
-	opts := getResponseOpts(opt...)
+func (r *Request) NewModifyResponse(opt ...ResponseOption) *ModifyResponse {
+	const op = "gldap.(Request).NewModifyResponse"
+	opts := r.responseOpts(op, opt...)
 	return &ModifyResponse{
 		GeneralResponse: r.NewResponse(
 			WithApplicationCode(ApplicationModifyResponse),
@@ -107,6 +285,21 @@ func (r *Request) NewModifyResponse(opt ...Option) *ModifyResponse {
 	}
 }
 
+// NewModifyDNResponse creates a modify DN response
+// Supported options: WithResponseCode, WithDiagnosticMessage, WithMatchedDN
+func (r *Request) NewModifyDNResponse(opt ...ResponseOption) *ModifyDNResponse {
+	const op = "gldap.(Request).NewModifyDNResponse"
+	opts := r.responseOpts(op, opt...)
+	return &ModifyDNResponse{
+		GeneralResponse: r.NewResponse(
+			WithApplicationCode(ApplicationModifyDNResponse),
+			WithResponseCode(*opts.withResponseCode),
+			WithDiagnosticMessage(opts.withDiagnosticMessage),
+			WithMatchedDN(opts.withMatchedDN),
+		),
+	}
+}
+
 // StartTLS will start a TLS connection using the Message's existing connection
 func (r *Request) StartTLS(tlsconfig *tls.Config) error {
 	const op = "gldap.(Message).StartTLS"
@@ -120,6 +313,7 @@ func (r *Request) StartTLS(tlsconfig *tls.Config) error {
 	if err := r.conn.initConn(tlsConn); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	r.conn.setTLSState(tlsConn)
 	return nil
 }
 
@@ -127,9 +321,9 @@ func (r *Request) StartTLS(tlsconfig *tls.Config) error {
 // request because you can set WithApplicationCode).
 // Supported options: WithResponseCode, WithApplicationCode,
 // WithDiagnosticMessage, WithMatchedDN
-func (r *Request) NewResponse(opt ...Option) *GeneralResponse {
-	const op = "gldap.NewResponse" // nolint:unused
-	opts := getResponseOpts(opt...)
+func (r *Request) NewResponse(opt ...ResponseOption) *GeneralResponse {
+	const op = "gldap.NewResponse"
+	opts := r.responseOpts(op, opt...)
 	if opts.withResponseCode == nil {
 		opts.withResponseCode = intPtr(ResultUnwillingToPerform)
 	}
@@ -149,13 +343,14 @@ func (r *Request) NewResponse(opt ...Option) *GeneralResponse {
 
 // NewExtendedResponse creates a new extended response.
 // Supported options: WithResponseCode
-func (r *Request) NewExtendedResponse(opt ...Option) *ExtendedResponse {
-	const op = "gldap.NewExtendedResponse" // nolint:unused
-	opts := getResponseOpts(opt...)
+func (r *Request) NewExtendedResponse(opt ...ResponseOption) *ExtendedResponse {
+	const op = "gldap.NewExtendedResponse"
+	opts := r.responseOpts(op, opt...)
 	resp := &ExtendedResponse{
 		baseResponse: &baseResponse{
 			messageID: r.message.GetID(),
 		},
+		encodeFn: r.extendedEncodeFn,
 	}
 	if opts.withResponseCode != nil {
 		resp.code = int16(*opts.withResponseCode)
@@ -165,9 +360,9 @@ func (r *Request) NewExtendedResponse(opt ...Option) *ExtendedResponse {
 
 // NewBindResponse creates a new bind response.
 // Supported options: WithResponseCode
-func (r *Request) NewBindResponse(opt ...Option) *BindResponse {
-	const op = "gldap.NewBindResponse" // nolint:unused
-	opts := getResponseOpts(opt...)
+func (r *Request) NewBindResponse(opt ...ResponseOption) *BindResponse {
+	const op = "gldap.NewBindResponse"
+	opts := r.responseOpts(op, opt...)
 	resp := &BindResponse{
 		baseResponse: &baseResponse{
 			messageID: r.message.GetID(),
@@ -179,6 +374,28 @@ func (r *Request) NewBindResponse(opt ...Option) *BindResponse {
 	return resp
 }
 
+// NewIntermediateResponse creates a new intermediate response with the
+// (optional) response name and value.
+func (r *Request) NewIntermediateResponse(name string, value []byte) *IntermediateResponse {
+	return &IntermediateResponse{
+		messageID: r.message.GetID(),
+		name:      name,
+		value:     value,
+	}
+}
+
+// GetExtendedOperationMessage retrieves the ExtendedOperationMessage from the
+// request, which allows you to handle the request based on the message
+// attributes.
+func (r *Request) GetExtendedOperationMessage() (*ExtendedOperationMessage, error) {
+	const op = "gldap.(Request).GetExtendedOperationMessage"
+	m, ok := r.message.(*ExtendedOperationMessage)
+	if !ok {
+		return nil, fmt.Errorf("%s: %T not an extended operation request: %w", op, r.message, ErrInvalidParameter)
+	}
+	return m, nil
+}
+
 // GetSimpleBindMessage retrieves the SimpleBindMessage from the request, which
 // allows you handle the request based on the message attributes.
 func (r *Request) GetSimpleBindMessage() (*SimpleBindMessage, error) {
@@ -192,12 +409,14 @@ func (r *Request) GetSimpleBindMessage() (*SimpleBindMessage, error) {
 
 // NewSearchDoneResponse creates a new search done response.  If there are no
 // results found, then set the response code by adding the option
-// WithResponseCode(ResultNoSuchObject)
+// WithResponseCode(ResultNoSuchObject) along with the matchedDN of the base DN's
+// closest existing ancestor (see: MatchedDN)
 //
-// Supported options: WithResponseCode
-func (r *Request) NewSearchDoneResponse(opt ...Option) *SearchResponseDone {
-	const op = "gldap.(Request).NewSearchDoneResponse" // nolint:unused
-	opts := getResponseOpts(opt...)
+// Supported options: WithResponseCode, WithDiagnosticMessage, WithMatchedDN
+func (r *Request) NewSearchDoneResponse(opt ...ResponseOption) *SearchResponseDone {
+	const op = "gldap.(Request).NewSearchDoneResponse"
+	opts := r.responseOpts(op, opt...)
+	defaults := responseDefaults()
 	resp := &SearchResponseDone{
 		baseResponse: &baseResponse{
 			messageID: r.message.GetID(),
@@ -206,6 +425,12 @@ func (r *Request) NewSearchDoneResponse(opt ...Option) *SearchResponseDone {
 	if opts.withResponseCode != nil {
 		resp.code = int16(*opts.withResponseCode)
 	}
+	if opts.withDiagnosticMessage != defaults.withDiagnosticMessage {
+		resp.diagMessage = opts.withDiagnosticMessage
+	}
+	if opts.withMatchedDN != defaults.withMatchedDN {
+		resp.matchedDN = opts.withMatchedDN
+	}
 	return resp
 }
 
@@ -221,12 +446,13 @@ func (r *Request) GetSearchMessage() (*SearchMessage, error) {
 }
 
 // NewSearchResponseEntry is a search response entry.
-// Supported options: WithAttributes
-func (r *Request) NewSearchResponseEntry(entryDN string, opt ...Option) *SearchResponseEntry {
-	opts := getResponseOpts(opt...)
-	newAttrs := make([]*EntryAttribute, 0, len(opts.withAttributes))
-	for name, values := range opts.withAttributes {
-		newAttrs = append(newAttrs, NewEntryAttribute(name, values))
+// Supported options: WithAttributes, WithAttribute
+func (r *Request) NewSearchResponseEntry(entryDN string, opt ...ResponseOption) *SearchResponseEntry {
+	const op = "gldap.(Request).NewSearchResponseEntry"
+	opts := r.responseOpts(op, opt...)
+	newAttrs := opts.withAttributes
+	if newAttrs == nil {
+		newAttrs = []*EntryAttribute{}
 	}
 	return &SearchResponseEntry{
 		baseResponse: &baseResponse{
@@ -250,6 +476,17 @@ func (r *Request) GetModifyMessage() (*ModifyMessage, error) {
 	return m, nil
 }
 
+// GetModifyDNMessage retrieves the ModifyDNMessage from the request, which
+// allows you handle the request based on the message attributes.
+func (r *Request) GetModifyDNMessage() (*ModifyDNMessage, error) {
+	const op = "gldap.(Request).GetModifyDNMessage"
+	m, ok := r.message.(*ModifyDNMessage)
+	if !ok {
+		return nil, fmt.Errorf("%s: %T not a modify dn request: %w", op, r.message, ErrInvalidParameter)
+	}
+	return m, nil
+}
+
 // GetAddMessage retrieves the AddMessage from the request, which
 // allows you handle the request based on the message attributes.
 func (r *Request) GetAddMessage() (*AddMessage, error) {
@@ -283,6 +520,185 @@ func (r *Request) GetUnbindMessage() (*UnbindMessage, error) {
 	return m, nil
 }
 
+// controls returns the controls sent with the request's message.
+func (r *Request) controls() []Control {
+	switch m := r.message.(type) {
+	case *SearchMessage:
+		return m.Controls
+	case *SimpleBindMessage:
+		return m.Controls
+	case *ModifyMessage:
+		return m.Controls
+	case *ModifyDNMessage:
+		return m.Controls
+	case *AddMessage:
+		return m.Controls
+	case *DeleteMessage:
+		return m.Controls
+	default:
+		return nil
+	}
+}
+
+// targetDN returns the DN that the request's operation targets (i.e. a
+// search's base DN), and false if the operation doesn't target a DN.
+func (r *Request) targetDN() (string, bool) {
+	switch m := r.message.(type) {
+	case *SimpleBindMessage:
+		return m.UserName, true
+	case *SearchMessage:
+		return m.BaseDN, true
+	case *ModifyMessage:
+		return m.DN, true
+	case *ModifyDNMessage:
+		return m.DN, true
+	case *AddMessage:
+		return m.DN, true
+	case *DeleteMessage:
+		return m.DN, true
+	default:
+		return "", false
+	}
+}
+
+// searchFilter returns the compiled filter of a search request, which is
+// compiled the first time it's needed.
+func (r *Request) searchFilter() (*ber.Packet, error) {
+	const op = "gldap.(Request).searchFilter"
+	r.filterOnce.Do(func() {
+		m, ok := r.message.(*SearchMessage)
+		if !ok {
+			r.filterErr = fmt.Errorf("%s: not a search request: %w", op, ErrInvalidParameter)
+			return
+		}
+		if r.filter, r.filterErr = ldap.CompileFilter(m.Filter); r.filterErr != nil {
+			r.filterErr = fmt.Errorf("%s: unable to compile filter: %w", op, r.filterErr)
+		}
+	})
+	return r.filter, r.filterErr
+}
+
+// withContext returns a copy of the request with the ctx, which shares the
+// request's conn and message.  The copy's cancellation state isn't shared, so
+// it's tracked by the ResponseWriter's request.
+func (r *Request) withContext(ctx context.Context) *Request {
+	r.stateMu.Lock()
+	routeDeadline := r.routeDeadline
+	r.stateMu.Unlock()
+	return &Request{
+		ID:               r.ID,
+		conn:             r.conn,
+		message:          r.message,
+		packet:           r.packet,
+		routeOp:          r.routeOp,
+		extendedName:     r.extendedName,
+		extendedEncodeFn: r.extendedEncodeFn,
+		ctx:              ctx,
+		cancel:           r.cancel,
+		done:             r.done,
+		routeDeadline:    routeDeadline,
+		received:         r.received,
+		correlationID:    r.correlationID,
+		span:             r.span,
+	}
+}
+
+// withSearchBase returns a copy of a search request whose search is based at
+// the baseDN with the scope (see: Mux.Mount), which shares the request's conn
+// and context.
+func (r *Request) withSearchBase(baseDN string, scope Scope) *Request {
+	cp := r.withContext(r.ctx)
+	if m, ok := r.message.(*SearchMessage); ok {
+		sm := *m
+		sm.BaseDN, sm.Scope = baseDN, scope
+		cp.message = &sm
+	}
+	return cp
+}
+
+// findControl returns the first request control with the OID, or nil if the
+// request doesn't have one.
+func (r *Request) findControl(oid string) Control {
+	return findControl(r.controls(), oid)
+}
+
+// resultResponse creates a response with the application code that
+// corresponds to the request's operation.
+func (r *Request) resultResponse(code int, diagMessage string) Response {
+	appCode := ApplicationExtendedResponse
+	switch r.routeOp {
+	case BindRouteOperation:
+		appCode = ApplicationBindResponse
+	case SearchRouteOperation:
+		appCode = ApplicationSearchResultDone
+	case ModifyRouteOperation:
+		appCode = ApplicationModifyResponse
+	case ModifyDNRouteOperation:
+		appCode = ApplicationModifyDNResponse
+	case AddRouteOperation:
+		appCode = ApplicationAddResponse
+	case DeleteRouteOperation:
+		appCode = ApplicationDelResponse
+	}
+	return r.NewResponse(
+		WithResponseCode(code),
+		WithApplicationCode(appCode),
+		WithDiagnosticMessage(diagMessage),
+	)
+}
+
+// ManageDsaIT returns true when the request includes the ManageDsaIT control
+// (see: https://tools.ietf.org/html/rfc3296), which signals that the client
+// wants to operate on referral objects themselves rather than have the server
+// return a referral.
+func (r *Request) ManageDsaIT() bool {
+	_, ok := r.findControl(ControlTypeManageDsaIT).(*ControlManageDsaIT)
+	return ok
+}
+
+// GetAssertionControl returns the request's assertion control (see:
+// https://tools.ietf.org/html/rfc4528) and false if the request doesn't have
+// one.
+func (r *Request) GetAssertionControl() (*ControlAssertion, bool) {
+	c, ok := r.findControl(ControlTypeAssertion).(*ControlAssertion)
+	return c, ok
+}
+
+// CheckAssertion evaluates the request's assertion control (if any) against
+// the entry the operation targets.  It returns true when the request has no
+// assertion control or the entry matches the assertion's filter.  Otherwise, it
+// writes an assertionFailed response (or an operationsError response if the
+// assertion cannot be evaluated) to w and returns false, in which case the
+// handler must not perform the operation.
+func (r *Request) CheckAssertion(w *ResponseWriter, e *Entry) (bool, error) {
+	const op = "gldap.(Request).CheckAssertion"
+	if w == nil {
+		return false, fmt.Errorf("%s: missing response writer: %w", op, ErrInvalidParameter)
+	}
+	c, ok := r.GetAssertionControl()
+	if !ok {
+		return true, nil
+	}
+	if e == nil {
+		if err := w.Write(r.resultResponse(ResultAssertionFailed, "assertion failed: entry not found")); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		return false, nil
+	}
+	matched, err := c.Evaluate(e)
+	if err != nil {
+		_ = w.Write(r.resultResponse(ResultOperationsError, "unable to evaluate assertion"))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !matched {
+		if err := w.Write(r.resultResponse(ResultAssertionFailed, "assertion failed")); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
 // ConvertString will convert an ASN1 BER Octet string into a "native" go
 // string.  Support ber string encoding types: OctetString, GeneralString and
 // all other types will return an error.
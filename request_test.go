@@ -4,9 +4,17 @@
 package gldap
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -89,6 +97,21 @@ func Test_newRequest(t *testing.T) {
 				baseMessage: baseMessage{id: 1},
 			},
 		},
+		{
+			name:      "valid-abandon",
+			requestID: 1,
+			conn:      &conn{},
+			packet: testAbandonRequestPacket(t,
+				AbandonMessage{
+					baseMessage: baseMessage{id: 2},
+					MessageID:   1,
+				},
+			),
+			wantMsg: &AbandonMessage{
+				baseMessage: baseMessage{id: 2},
+				MessageID:   1,
+			},
+		},
 		{
 			name:      "valid-search",
 			requestID: 1,
@@ -104,6 +127,23 @@ func Test_newRequest(t *testing.T) {
 			conn:      &conn{},
 			packet:    testStartTLSRequestPacket(t, 1),
 		},
+		{
+			name:      "valid-extended-with-value",
+			requestID: 1,
+			conn:      &conn{},
+			packet: testExtendedOperationRequestPacket(t,
+				ExtendedOperationMessage{
+					baseMessage: baseMessage{id: 1},
+					Name:        ExtendedOperationPasswordModify,
+					Value:       "value",
+				},
+			),
+			wantMsg: &ExtendedOperationMessage{
+				baseMessage: baseMessage{id: 1},
+				Name:        ExtendedOperationPasswordModify,
+				Value:       "value",
+			},
+		},
 		{
 			name:      "valid-modify",
 			requestID: 1,
@@ -204,6 +244,33 @@ func Test_newRequest(t *testing.T) {
 			wantErr:         true,
 			wantErrContains: "failed to decode attribute packet",
 		},
+		{
+			name:      "valid-modify-dn",
+			requestID: 1,
+			conn:      &conn{},
+			packet: testModifyDNRequestPacket(t,
+				ModifyDNMessage{
+					baseMessage:  baseMessage{id: 1},
+					DN:           "uid=alice,ou=people,dc=example,dc=com",
+					NewRDN:       "uid=alice.smith",
+					DeleteOldRDN: true,
+					NewSuperior:  "ou=staff,dc=example,dc=com",
+					Controls: []Control{
+						testControlString(t, "generic-control", WithControlValue("generic-value")),
+					},
+				},
+			),
+			wantMsg: &ModifyDNMessage{
+				baseMessage:  baseMessage{id: 1},
+				DN:           "uid=alice,ou=people,dc=example,dc=com",
+				NewRDN:       "uid=alice.smith",
+				DeleteOldRDN: true,
+				NewSuperior:  "ou=staff,dc=example,dc=com",
+				Controls: []Control{
+					testControlString(t, "generic-control", WithControlValue("generic-value")),
+				},
+			},
+		},
 		{
 			name:      "valid-delete",
 			requestID: 1,
@@ -319,6 +386,138 @@ func TestRequest_GetConnectionID(t *testing.T) {
 	assert.Equal(connID, req.ConnectionID())
 }
 
+func TestRequest_BoundDN(t *testing.T) {
+	t.Parallel()
+	duringBind := make(chan string, 1)
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		duringBind <- r.BoundDN()
+		m, err := r.GetSimpleBindMessage()
+		if err != nil || string(m.Password) == "bad" {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultInvalidCredentials)))
+			return
+		}
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry(r.BoundDN()))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+	defer client.Close()
+	boundDN := func() string {
+		t.Helper()
+		result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(t, err)
+		require.Len(t, result.Entries, 1)
+		return result.Entries[0].DN
+	}
+
+	assert := assert.New(t)
+	assert.Empty(boundDN())
+	assert.Empty((&Request{}).BoundDN())
+
+	require.NoError(t, client.Bind("cn=alice", "password"))
+	assert.Empty(<-duringBind)
+	assert.Equal("cn=alice", boundDN())
+
+	// a new bind resets the bound DN before it's handled
+	require.NoError(t, client.Bind("cn=bob", "password"))
+	assert.Empty(<-duringBind)
+	assert.Equal("cn=bob", boundDN())
+
+	// a failed bind leaves the conn anonymous
+	assert.Error(client.Bind("cn=alice", "bad"))
+	<-duringBind
+	assert.Empty(boundDN())
+
+	// as does an unauthenticated bind
+	require.NoError(t, client.Bind("cn=alice", "password"))
+	<-duringBind
+	require.NoError(t, client.UnauthenticatedBind("cn=alice"))
+	<-duringBind
+	assert.Empty(boundDN())
+}
+
+func TestRequest_Addrs(t *testing.T) {
+	t.Parallel()
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("", WithAttributes(map[string][]string{
+			"remoteAddr": {r.RemoteAddr().String()},
+			"localAddr":  {r.LocalAddr().String()},
+		})))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+	defer client.Close()
+	result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+
+	assert := assert.New(t)
+	// the local addr is the one the server listens on, and the remote addr is
+	// the client's
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(url, "ldap://"))
+	require.NoError(t, err)
+	_, localPort, err := net.SplitHostPort(result.Entries[0].GetAttributeValue("localAddr"))
+	require.NoError(t, err)
+	assert.Equal(port, localPort)
+	_, remotePort, err := net.SplitHostPort(result.Entries[0].GetAttributeValue("remoteAddr"))
+	require.NoError(t, err)
+	assert.NotEqual(port, remotePort)
+
+	assert.Nil((&Request{}).RemoteAddr())
+	assert.Nil((&Request{}).LocalAddr())
+}
+
+func TestRequest_Packet(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	assert.Nil((&Request{}).Packet())
+
+	p := testSearchRequestPacket(t,
+		SearchMessage{baseMessage: baseMessage{id: 1}, BaseDN: "dc=example,dc=org", Filter: "(uid=alice)"},
+	)
+	// a control that isn't decoded into the message
+	controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	control := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	control.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "1.3.6.1.4.1.99999.1", "Control Type"))
+	control.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "vendor value", "Control Value"))
+	controls.AppendChild(control)
+	p.AppendChild(controls)
+	req, err := newRequest(1, &conn{connID: 1}, p)
+	require.NoError(err)
+
+	m, ok := req.Message().(*SearchMessage)
+	require.True(ok)
+	assert.Equal("dc=example,dc=org", m.BaseDN)
+
+	got := req.Packet()
+	require.NotNil(got)
+	assert.Equal(p.Bytes(), got.Bytes())
+	require.Len(got.Children, 3)
+	assert.Equal(int64(1), got.Children[0].Value)
+	assert.Equal(ber.Tag(ApplicationSearchRequest), got.Children[1].Tag)
+	require.Len(got.Children[2].Children, 1)
+	gotControl := got.Children[2].Children[0]
+	require.Len(gotControl.Children, 2)
+	assert.Equal("1.3.6.1.4.1.99999.1", gotControl.Children[0].Value)
+	assert.Equal("vendor value", gotControl.Children[1].Value)
+
+	// changes to the copy don't affect the request
+	got.Children[0].Value = int64(2)
+	got.Children = got.Children[:1]
+	assert.Equal(p.Bytes(), req.Packet().Bytes())
+	assert.Len(req.Packet().Children, 3)
+}
+
 func TestConvertString(t *testing.T) {
 	t.Parallel()
 
@@ -372,3 +571,222 @@ func TestConvertString(t *testing.T) {
 		})
 	}
 }
+
+func TestRequest_CheckAssertion(t *testing.T) {
+	t.Parallel()
+	alice := NewEntry("cn=alice,ou=people,dc=example,dc=org", map[string][]string{"cn": {"alice"}, "mail": {"alice@example.org"}})
+	tests := []struct {
+		name            string
+		req             *Request
+		entry           *Entry
+		want            bool
+		wantCode        int
+		wantAppCode     int
+		wantErr         bool
+		wantErrContains string
+	}{
+		{
+			name:  "no-assertion",
+			req:   &Request{message: &ModifyMessage{baseMessage: baseMessage{id: 1}}, routeOp: modifyRouteOperation},
+			entry: alice,
+			want:  true,
+		},
+		{
+			name: "matched",
+			req: &Request{
+				message: &ModifyMessage{baseMessage: baseMessage{id: 1}, Controls: []Control{testControlAssertion(t, "(mail=alice@example.org)")}},
+				routeOp: modifyRouteOperation,
+			},
+			entry: alice,
+			want:  true,
+		},
+		{
+			name: "not-matched",
+			req: &Request{
+				message: &ModifyMessage{baseMessage: baseMessage{id: 1}, Controls: []Control{testControlAssertion(t, "(mail=eve@example.org)")}},
+				routeOp: modifyRouteOperation,
+			},
+			entry:       alice,
+			wantCode:    ResultAssertionFailed,
+			wantAppCode: ApplicationModifyResponse,
+		},
+		{
+			name: "missing-entry",
+			req: &Request{
+				message: &DeleteMessage{baseMessage: baseMessage{id: 1}, Controls: []Control{testControlAssertion(t, "(cn=alice)")}},
+				routeOp: deleteRouteOperation,
+			},
+			wantCode:    ResultAssertionFailed,
+			wantAppCode: ApplicationDelResponse,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			var buf bytes.Buffer
+			w, err := newResponseWriter(bufio.NewWriter(&buf), &sync.Mutex{}, hclog.NewNullLogger(), 1, 1)
+			require.NoError(err)
+			got, err := tc.req.CheckAssertion(w, tc.entry)
+			if tc.wantErr {
+				require.Error(err)
+				assert.Contains(err.Error(), tc.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+			if tc.want {
+				assert.Zero(buf.Len())
+				return
+			}
+			resp, err := ber.DecodePacketErr(buf.Bytes())
+			require.NoError(err)
+			require.Len(resp.Children, 2)
+			assert.Equal(ber.Tag(tc.wantAppCode), resp.Children[1].Tag)
+			assert.Equal(int64(tc.wantCode), resp.Children[1].Children[0].Value)
+		})
+	}
+	t.Run("missing-writer", func(t *testing.T) {
+		r := &Request{message: &ModifyMessage{}, routeOp: modifyRouteOperation}
+		_, err := r.CheckAssertion(nil, alice)
+		require.ErrorIs(t, err, ErrInvalidParameter)
+	})
+}
+
+func TestRequest_ManageDsaIT(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		req  *Request
+		want bool
+	}{
+		{
+			name: "search-with-control",
+			req:  &Request{message: &SearchMessage{Controls: []Control{testControlManageDsaIT(t, WithCriticality(true))}}},
+			want: true,
+		},
+		{
+			name: "modify-with-control",
+			req:  &Request{message: &ModifyMessage{Controls: []Control{testControlString(t, "x"), testControlManageDsaIT(t)}}},
+			want: true,
+		},
+		{
+			name: "delete-without-control",
+			req:  &Request{message: &DeleteMessage{Controls: []Control{testControlString(t, "x")}}},
+		},
+		{
+			name: "extended-operation",
+			req:  &Request{message: &ExtendedOperationMessage{}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.req.ManageDsaIT())
+		})
+	}
+}
+
+func TestModifyMessage_PermissiveModify(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		controls []Control
+		want     bool
+	}{
+		{name: "with-control", controls: []Control{testControlString(t, "x"), testControlMicrosoftPermissiveModify(t, WithCriticality(true))}, want: true},
+		{name: "without-control", controls: []Control{testControlMicrosoftTreeDelete(t)}},
+		{name: "no-controls"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			p := testModifyRequestPacket(t, ModifyMessage{baseMessage: baseMessage{id: 1}, DN: "cn=alice", Controls: tc.controls})
+			r, err := newRequest(1, &conn{}, p)
+			require.NoError(err)
+			m, err := r.GetModifyMessage()
+			require.NoError(err)
+			assert.Equal(t, tc.want, m.PermissiveModify())
+		})
+	}
+}
+
+func TestDeleteMessage_TreeDelete(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		controls []Control
+		want     bool
+	}{
+		{name: "with-control", controls: []Control{testControlMicrosoftTreeDelete(t, WithCriticality(true))}, want: true},
+		{name: "without-control", controls: []Control{testControlMicrosoftPermissiveModify(t)}},
+		{name: "no-controls"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			p := testDeleteRequestPacket(t, DeleteMessage{baseMessage: baseMessage{id: 1}, DN: "cn=alice", Controls: tc.controls})
+			r, err := newRequest(1, &conn{}, p)
+			require.NoError(err)
+			m, err := r.GetDeleteMessage()
+			require.NoError(err)
+			assert.Equal(t, tc.want, m.TreeDelete())
+		})
+	}
+}
+
+func TestRequest_CorrelationID(t *testing.T) {
+	t.Parallel()
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("", WithAttributes(map[string][]string{
+			"correlationID": {r.CorrelationID()},
+		})))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	records := make(chan AccessLogRecord, 2)
+	buf := testSafeBuf(t)
+	_, url := testServer(t, mux,
+		WithAccessLog(func(r AccessLogRecord) { records <- r }),
+		WithLogger(hclog.New(&hclog.LoggerOptions{Output: buf, Level: hclog.Debug})),
+	)
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+	defer client.Close()
+
+	assert, require := assert.New(t), require.New(t)
+	var ids []string
+	for i := 0; i < 2; i++ {
+		result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		id := result.Entries[0].GetAttributeValue("correlationID")
+		// the ID is the conn ID, the message ID and the request sequence
+		rec := <-records
+		assert.Regexp(fmt.Sprintf(`^%d-%d-\d+$`, rec.ConnectionID, rec.MessageID), id)
+		assert.Equal(id, rec.CorrelationID)
+		assert.Contains(buf.String(), id)
+		ids = append(ids, id)
+	}
+	assert.NotEqual(ids[0], ids[1])
+
+	// a request that wasn't read from a conn doesn't have one
+	assert.Empty((&Request{}).CorrelationID())
+	assert.Empty((*Request)(nil).CorrelationID())
+}
+
+func TestRequest_searchFilter(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	r := &Request{message: &SearchMessage{Filter: "(uid=alice)"}}
+	f, err := r.searchFilter()
+	require.NoError(err)
+	// the filter is compiled once
+	again, err := r.searchFilter()
+	require.NoError(err)
+	assert.Same(f, again)
+
+	_, err = (&Request{message: &SearchMessage{Filter: "(uid=alice"}}).searchFilter()
+	assert.Error(err)
+	_, err = (&Request{message: &DeleteMessage{}}).searchFilter()
+	assert.ErrorIs(err, ErrInvalidParameter)
+}
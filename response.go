@@ -5,11 +5,13 @@ package gldap
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
-	"github.com/hashicorp/go-hclog"
 )
 
 // ResponseWriter is an ldap request response writer which is used by a
@@ -17,12 +19,64 @@ import (
 type ResponseWriter struct {
 	writerMu  *sync.Mutex // a shared lock across all requests to prevent data races when writing
 	writer    *bufio.Writer
-	logger    hclog.Logger
+	logger    Logger
 	connID    int
 	requestID int
+
+	// request being responded to, which is set once the request has been
+	// read and is used when streaming responses (see: WriteSyncEntry,
+	// WriteSyncInfo and WriteChange)
+	request *Request
+
+	// interceptors are called with every response before it's written, the
+	// last one first (see: WithInterceptor)
+	interceptors []ResponseInterceptor
+}
+
+// ResponseInterceptor defines a function which is called with a response
+// before it's written, and returns the response to write in its place (see:
+// ResponseWriter.WithInterceptor).  The response can be modified (i.e. to
+// redact the attributes of a SearchResponseEntry) or replaced, and it isn't
+// written when the interceptor returns nil.  A request's final response (i.e.
+// its SearchResponseDone) shouldn't be dropped, since the client waits for it.
+type ResponseInterceptor func(Response) Response
+
+// WithInterceptor returns a copy of the ResponseWriter whose responses are
+// passed to the interceptor before they're written, which allows a
+// middleware (see: Mux.Use) to enrich or redact the responses of the handlers
+// it wraps.  For example:
+//
+//	mux.Use(func(next gldap.HandlerFunc) gldap.HandlerFunc {
+//		return func(w *gldap.ResponseWriter, r *gldap.Request) {
+//			next(w.WithInterceptor(func(resp gldap.Response) gldap.Response {
+//				if e, ok := resp.(*gldap.SearchResponseEntry); ok {
+//					e.RemoveAttribute("userPassword")
+//				}
+//				return resp
+//			}), r)
+//		}
+//	})
+//
+// The interceptor is called before the interceptors of the ResponseWriter it
+// was created from.
+func (rw *ResponseWriter) WithInterceptor(fn ResponseInterceptor) *ResponseWriter {
+	if fn == nil {
+		return rw
+	}
+	cp := *rw
+	cp.interceptors = append(append([]ResponseInterceptor{}, rw.interceptors...), fn)
+	return &cp
+}
+
+// messageID returns the message ID of the request being responded to
+func (rw *ResponseWriter) messageID() int64 {
+	if rw.request == nil || rw.request.message == nil {
+		return 0
+	}
+	return rw.request.message.GetID()
 }
 
-func newResponseWriter(w *bufio.Writer, lock *sync.Mutex, logger hclog.Logger, connID, requestID int) (*ResponseWriter, error) {
+func newResponseWriter(w *bufio.Writer, lock *sync.Mutex, logger Logger, connID, requestID int) (*ResponseWriter, error) {
 	const op = "gldap.NewResponseWriter"
 	if w == nil {
 		return nil, fmt.Errorf("%s: missing writer: %w", op, ErrInvalidParameter)
@@ -48,29 +102,164 @@ func newResponseWriter(w *bufio.Writer, lock *sync.Mutex, logger hclog.Logger, c
 	}, nil
 }
 
-// Write will write the response to the client
+// Write will write the response to the client.  Responses to a request whose
+// route timed out (see: WithRouteTimeout) aren't written, since the request
+// has already been responded to.
 func (rw *ResponseWriter) Write(r Response) error {
 	const op = "gldap.(ResponseWriter).Write"
 	if r == nil {
 		return fmt.Errorf("%s: missing response: %w", op, ErrInvalidParameter)
 	}
+	if rw.request != nil && rw.request.isTimedOut() {
+		return fmt.Errorf("%s: route timed out: %w", op, ErrInvalidState)
+	}
+	return rw.writeIntercepted(r)
+}
+
+// writeIntercepted will write the response to the client once the
+// interceptors have been applied to it
+func (rw *ResponseWriter) writeIntercepted(r Response) error {
+	for i := len(rw.interceptors) - 1; i >= 0; i-- {
+		if r = rw.interceptors[i](r); r == nil {
+			// the interceptor dropped the response
+			return nil
+		}
+	}
+	return rw.write(r)
+}
+
+// write will write the response to the client
+func (rw *ResponseWriter) write(r Response) error {
+	const op = "gldap.(ResponseWriter).write"
+	if rw.request != nil && isFinalResponse(r) && rw.request.markResponded() {
+		// a canceled request's final response must have the canceled result
+		// code (see: https://tools.ietf.org/html/rfc3909#section-2.2)
+		if resp, ok := r.(interface{ SetResultCode(int) }); ok {
+			resp.SetResultCode(ResultCanceled)
+		}
+	}
+	if rw.request != nil && rw.request.conn != nil {
+		applyDiagnosticHook(rw.request.conn.diagnosticHook, rw.request, r)
+	}
+	// the conn's state is updated before the response is written, so it's
+	// current when the client sends its next request.
+	rw.recordChange(r)
+	rw.forwardChange(r)
+	rw.trackBind(r)
+	rw.sendEvent(r)
+	rw.audit(r)
+	if rw.request != nil && rw.request.conn != nil {
+		if _, ok := r.(*SearchResponseEntry); ok {
+			rw.request.entries.Add(1)
+		}
+		if isFinalResponse(r) {
+			rw.request.conn.journal.record(rw.request, r)
+			rw.request.conn.logAccess(rw.request, r)
+			rw.request.conn.checkSlowOperation(rw.request, r)
+			rw.request.conn.observeOperation(rw.request, r)
+			rw.request.endSpan(r)
+		}
+	}
 	p := r.packet()
 	if rw.logger.IsDebug() {
-		rw.logger.Debug("response write", "op", op, "conn", rw.connID, "requestID", rw.requestID)
-		p.Log(rw.logger.StandardWriter(&hclog.StandardLoggerOptions{}), 0, false)
+		rw.logger.Debug("response write", "op", op, "conn", rw.connID, "requestID", rw.requestID, "correlationID", rw.request.CorrelationID())
+		logPacket(rw.logger, p)
 	}
+	b := p.Bytes()
 	rw.writerMu.Lock()
 	defer rw.writerMu.Unlock()
-	if _, err := rw.writer.Write(r.packet().Bytes()); err != nil {
+	if rw.request != nil && rw.request.conn != nil && rw.request.conn.netConn != nil {
+		if err := rw.request.conn.setWriteDeadline(); err != nil {
+			return fmt.Errorf("%s: unable to set write deadline: %w", op, err)
+		}
+	}
+	if _, err := rw.writer.Write(b); err != nil {
 		return fmt.Errorf("%s: unable to write response: %w", op, err)
 	}
 	if err := rw.writer.Flush(); err != nil {
 		return fmt.Errorf("%s: unable to flush write: %w", op, err)
 	}
-	rw.logger.Debug("finished writing", "op", op, "conn", rw.connID, "requestID", rw.requestID)
+	if rw.request != nil && rw.request.conn != nil {
+		rw.request.conn.stats.responseWritten(rw.request.routeOp, len(b))
+		if h := rw.request.conn.metricsHook; h != nil {
+			h.BytesWritten(rw.request.routeOp, len(b))
+		}
+	}
+	if rw.request != nil {
+		if fn := rw.request.getWriteHook(); fn != nil {
+			fn(r)
+		}
+	}
+	rw.logger.Debug("finished writing", "op", op, "conn", rw.connID, "requestID", rw.requestID, "correlationID", rw.request.CorrelationID())
 	return nil
 }
 
+// trackBind sets the conn's bound DN when the response is the final response
+// to a bind request.  A failed bind leaves the conn anonymous (see:
+// https://tools.ietf.org/html/rfc4513#section-5.1)
+func (rw *ResponseWriter) trackBind(r Response) {
+	if rw.request == nil || rw.request.conn == nil {
+		return
+	}
+	m, ok := rw.request.message.(*SimpleBindMessage)
+	if !ok || !isFinalResponse(r) {
+		return
+	}
+	var dn string
+	if resp, ok := r.(interface{ resultCode() int }); ok && resp.resultCode() == ResultSuccess && m.Password != "" {
+		dn = m.UserName
+	}
+	rw.request.conn.setBoundDN(dn)
+}
+
+// recordChange records the change made by a request in the conn's changelog
+// and publishes it to the conn's change notifier when the response is the
+// request's successful final response.
+func (rw *ResponseWriter) recordChange(r Response) {
+	const op = "gldap.(ResponseWriter).recordChange"
+	if rw.request == nil || rw.request.conn == nil || !isFinalResponse(r) {
+		return
+	}
+	c := rw.request.conn
+	if c.changelog == nil && c.changeNotifier == nil {
+		return
+	}
+	if resp, ok := r.(interface{ resultCode() int }); !ok || resp.resultCode() != ResultSuccess {
+		return
+	}
+	var changeNumber *int64
+	if c.changelog != nil {
+		e, err := c.changelog.recordRequest(rw.request)
+		switch {
+		case err != nil:
+			rw.logger.Error("unable to record change", "op", op, "conn", rw.connID, "requestID", rw.requestID, "correlationID", rw.request.CorrelationID(), "err", err)
+		case e != nil:
+			changeNumber = &e.ChangeNumber
+		}
+	}
+	if c.changeNotifier != nil {
+		if err := c.changeNotifier.notifyRequest(rw.request, changeNumber); err != nil {
+			rw.logger.Error("unable to notify change", "op", op, "conn", rw.connID, "requestID", rw.requestID, "correlationID", rw.request.CorrelationID(), "err", err)
+		}
+	}
+}
+
+// forwardChange forwards the change made by a request to the conn's
+// write-through upstream server when the response is the request's successful
+// final response.
+func (rw *ResponseWriter) forwardChange(r Response) {
+	const op = "gldap.(ResponseWriter).forwardChange"
+	if rw.request == nil || rw.request.conn == nil || rw.request.conn.writeThrough == nil || !isFinalResponse(r) {
+		return
+	}
+	if resp, ok := r.(interface{ resultCode() int }); !ok || resp.resultCode() != ResultSuccess {
+		return
+	}
+	if err := rw.request.conn.writeThrough.forwardRequest(rw.request.Context(), rw.request); err != nil {
+		rw.logger.Error("unable to forward change", "op", op, "conn", rw.connID, "requestID", rw.requestID, "correlationID", rw.request.CorrelationID(), "err", err)
+	}
+}
+
 func beginResponse(messageID int64) *ber.Packet {
 	const op = "gldap.beginResponse" // nolint:unused
 	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
@@ -78,7 +267,7 @@ func beginResponse(messageID int64) *ber.Packet {
 	return p
 }
 
-func addOptionalResponseChildren(bindResponse *ber.Packet, opt ...Option) {
+func addOptionalResponseChildren(bindResponse *ber.Packet, opt ...ResponseOption) {
 	const op = "gldap.addOptionalResponseChildren" // nolint:unused
 	opts := getResponseOpts(opt...)
 	bindResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, opts.withMatchedDN, "matchedDN"))
@@ -102,6 +291,16 @@ func (l *baseResponse) SetResultCode(code int) {
 	l.code = int16(code)
 }
 
+func (l *baseResponse) resultCode() int {
+	return int(l.code)
+}
+
+// ResultCode returns the result code of the response, which allows a
+// ResponseInterceptor to check whether an operation succeeded.
+func (l *baseResponse) ResultCode() int {
+	return l.resultCode()
+}
+
 // SetDiagnosticMessage sets the optional diagnostic message for a response.
 func (l *baseResponse) SetDiagnosticMessage(msg string) {
 	l.diagMessage = msg
@@ -115,7 +314,10 @@ func (l *baseResponse) SetMatchedDN(dn string) {
 // ExtendedResponse represents a response to an extended operation request
 type ExtendedResponse struct {
 	*baseResponse
-	name ExtendedOperationName
+	name  ExtendedOperationName
+	value []byte
+
+	encodeFn ExtendedEncodeFunc
 }
 
 // SetResponseName will set the response name for the extended operation response.
@@ -123,6 +325,12 @@ func (r *ExtendedResponse) SetResponseName(n ExtendedOperationName) {
 	r.name = n
 }
 
+// SetResponseValue will set the optional response value for the extended
+// operation response.
+func (r *ExtendedResponse) SetResponseValue(value []byte) {
+	r.value = value
+}
+
 func (r *ExtendedResponse) packet() *packet {
 	replyPacket := beginResponse(r.messageID)
 
@@ -134,6 +342,14 @@ func (r *ExtendedResponse) packet() *packet {
 	// Add optional diagnostic message and matched DN
 	addOptionalResponseChildren(resultPacket, WithDiagnosticMessage(r.diagMessage), WithMatchedDN(r.matchedDN))
 
+	// Add optional response name and value
+	if r.name != "" {
+		resultPacket.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, string(r.name), "responseName"))
+	}
+	if r.value != nil {
+		resultPacket.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 11, string(r.value), "responseValue"))
+	}
+
 	replyPacket.AppendChild(resultPacket)
 	return &packet{Packet: replyPacket}
 }
@@ -221,7 +437,13 @@ func (r *SearchResponseDone) packet() *packet {
 // SearchResponseEntry is an ldap entry that's part of search response.
 type SearchResponseEntry struct {
 	*baseResponse
-	entry Entry
+	entry    Entry
+	controls []Control
+}
+
+// SetControls for the search response entry
+func (r *SearchResponseEntry) SetControls(controls ...Control) {
+	r.controls = controls
 }
 
 // AddAttribute will an attributes to the response entry
@@ -229,6 +451,71 @@ func (r *SearchResponseEntry) AddAttribute(name string, values []string) {
 	r.entry.Attributes = append(r.entry.Attributes, NewEntryAttribute(name, values))
 }
 
+// DN returns the DN of the response entry
+func (r *SearchResponseEntry) DN() string {
+	return r.entry.DN
+}
+
+// SetDN sets the DN of the response entry (i.e. to rename the entries of a
+// proxied directory)
+func (r *SearchResponseEntry) SetDN(dn string) {
+	r.entry.DN = dn
+}
+
+// Attributes returns the attributes of the response entry, which can be
+// modified until the entry is written.
+func (r *SearchResponseEntry) Attributes() []*EntryAttribute {
+	return r.entry.Attributes
+}
+
+// GetAttributeValues returns the values of the named attribute, or an empty
+// list.  Attribute names are compared case-insensitively.
+func (r *SearchResponseEntry) GetAttributeValues(name string) []string {
+	for _, a := range r.entry.Attributes {
+		if strings.EqualFold(a.Name, name) {
+			return a.Values
+		}
+	}
+	return []string{}
+}
+
+// SetAttribute replaces the values of the named attribute, which is added to
+// the response entry when it doesn't have it.  Attribute names are compared
+// case-insensitively.
+func (r *SearchResponseEntry) SetAttribute(name string, values []string) {
+	for i, a := range r.entry.Attributes {
+		if strings.EqualFold(a.Name, name) {
+			r.entry.Attributes[i] = NewEntryAttribute(a.Name, values)
+			return
+		}
+	}
+	r.AddAttribute(name, values)
+}
+
+// RemoveAttribute removes the named attributes from the response entry (i.e.
+// to redact a userPassword).  Attribute names are compared case-insensitively.
+func (r *SearchResponseEntry) RemoveAttribute(name ...string) {
+	attrs := r.entry.Attributes[:0]
+	for _, a := range r.entry.Attributes {
+		removed := false
+		for _, n := range name {
+			if strings.EqualFold(a.Name, n) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			attrs = append(attrs, a)
+		}
+	}
+	r.entry.Attributes = attrs
+}
+
+// Controls returns the controls of the response entry
+func (r *SearchResponseEntry) Controls() []Control {
+	return r.controls
+}
+
 func (r *SearchResponseEntry) packet() *packet {
 	const op = "gldap.(SearchEntryResponse).packet" // nolint:unused
 	replyPacket := beginResponse(r.messageID)
@@ -241,6 +528,43 @@ func (r *SearchResponseEntry) packet() *packet {
 	}
 	resultPacket.AppendChild(attributesPacket)
 
+	replyPacket.AppendChild(resultPacket)
+	if len(r.controls) > 0 {
+		replyPacket.AppendChild(encodeControls(r.controls))
+	}
+	return &packet{Packet: replyPacket}
+}
+
+// IntermediateResponse represents an intermediate response which may be sent
+// zero or more times before the final response to a request (see:
+// https://datatracker.ietf.org/doc/html/rfc4511#section-4.13)
+type IntermediateResponse struct {
+	messageID int64
+	name      string
+	value     []byte
+}
+
+// SetResponseName sets the optional name (OID) of the intermediate response
+func (r *IntermediateResponse) SetResponseName(name string) {
+	r.name = name
+}
+
+// SetResponseValue sets the optional value of the intermediate response
+func (r *IntermediateResponse) SetResponseValue(value []byte) {
+	r.value = value
+}
+
+func (r *IntermediateResponse) packet() *packet {
+	const op = "gldap.(IntermediateResponse).packet" // nolint:unused
+	replyPacket := beginResponse(r.messageID)
+
+	resultPacket := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationIntermediateResponse, nil, ApplicationCodeMap[ApplicationIntermediateResponse])
+	if r.name != "" {
+		resultPacket.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, r.name, "responseName"))
+	}
+	if r.value != nil {
+		resultPacket.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, string(r.value), "responseValue"))
+	}
 	replyPacket.AppendChild(resultPacket)
 	return &packet{Packet: replyPacket}
 }
@@ -249,3 +573,108 @@ func (r *SearchResponseEntry) packet() *packet {
 type ModifyResponse struct {
 	*GeneralResponse
 }
+
+// ModifyDNResponse is a response to a modify DN request.
+type ModifyDNResponse struct {
+	*GeneralResponse
+}
+
+// WriteSearchResult writes the entries and then the search done response with
+// the doneCode and controls to the client, which responds to the request in a
+// single call.  Each entry's attributes are projected to the attributes
+// requested by the search, and only their types are written when the search
+// is TypesOnly.  When the search has a SizeLimit and there are more entries,
+// only SizeLimit entries are written and the done response's code is
+// sizeLimitExceeded (see: https://tools.ietf.org/html/rfc4511#section-4.5.1.5).
+// Entries aren't matched against the search's filter or scope, which is the
+// caller's responsibility.
+func (rw *ResponseWriter) WriteSearchResult(entries []*Entry, doneCode int, controls ...Control) error {
+	const op = "gldap.(ResponseWriter).WriteSearchResult"
+	for i, e := range entries {
+		if e == nil {
+			return fmt.Errorf("%s: missing entry %d: %w", op, i, ErrInvalidParameter)
+		}
+	}
+	if err := rw.writeSearchSource(NewEntrySliceSource(entries...), doneCode, controls...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// WriteSearchSource writes the entries yielded by the source and then the
+// search done response with the doneCode and controls to the client, in the
+// same way as WriteSearchResult.  Entries are written as they're yielded, so
+// they don't need to be materialized by the handler.  When the source returns
+// an error, the done response's code is operationsError and the error is
+// returned, unless the request's Context is done (i.e. the request was
+// abandoned) in which case a done response isn't written.
+func (rw *ResponseWriter) WriteSearchSource(src EntrySource, doneCode int, controls ...Control) error {
+	const op = "gldap.(ResponseWriter).WriteSearchSource"
+	if src == nil {
+		return fmt.Errorf("%s: missing entry source: %w", op, ErrInvalidParameter)
+	}
+	if err := rw.writeSearchSource(src, doneCode, controls...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// writeSearchSource writes the source's entries, projected to the requested
+// attributes and limited to the search's SizeLimit, and then the done
+// response.
+func (rw *ResponseWriter) writeSearchSource(src EntrySource, doneCode int, controls ...Control) error {
+	const op = "gldap.(ResponseWriter).writeSearchSource"
+	if rw.request == nil {
+		return fmt.Errorf("%s: missing request: %w", op, ErrInvalidParameter)
+	}
+	m, err := rw.request.GetSearchMessage()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	ctx := rw.request.Context()
+	requested := m.RequestedAttributes()
+	var srcErr error
+	for written := int64(0); ; written++ {
+		e, err := src.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil && e == nil {
+			err = fmt.Errorf("missing entry %d: %w", written, ErrInvalidParameter)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			srcErr = fmt.Errorf("%s: unable to get entry: %w", op, err)
+			doneCode = ResultOperationsError
+			break
+		}
+		if m.SizeLimit > 0 && written >= m.SizeLimit {
+			doneCode = ResultSizeLimitExceeded
+			break
+		}
+		resp := rw.request.NewSearchResponseEntry(e.DN)
+		for _, a := range e.Attributes {
+			if !requested.Wants(a.Name) {
+				continue
+			}
+			if m.TypesOnly {
+				resp.AddAttribute(a.Name, nil)
+				continue
+			}
+			resp.AddAttribute(a.Name, a.Values)
+		}
+		if err := rw.Write(resp); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	done := rw.request.NewSearchDoneResponse(WithResponseCode(doneCode))
+	if len(controls) > 0 {
+		done.SetControls(controls...)
+	}
+	if err := rw.Write(done); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return srcErr
+}
@@ -3,12 +3,17 @@
 
 package gldap
 
+import (
+	"sort"
+	"strings"
+)
+
 type responseOptions struct {
 	withDiagnosticMessage string
 	withMatchedDN         string
 	withResponseCode      *int
 	withApplicationCode   *int
-	withAttributes        map[string][]string
+	withAttributes        []*EntryAttribute
 }
 
 func responseDefaults() responseOptions {
@@ -18,58 +23,91 @@ func responseDefaults() responseOptions {
 	}
 }
 
-func getResponseOpts(opt ...Option) responseOptions {
+func getResponseOpts(opt ...ResponseOption) responseOptions {
 	opts := responseDefaults()
 	applyOpts(&opts, opt...)
 	return opts
 }
 
+// responseOpts gets the response options of the request, and logs a warning
+// for the opts that don't apply to a response, since the response
+// constructors don't return an error.
+func (r *Request) responseOpts(op string, opt ...ResponseOption) responseOptions {
+	if err := unsupportedOptions(op, "a response", &responseOptions{}, opt...); err != nil && r != nil && r.conn != nil {
+		r.conn.logger.Warn("ignoring option", "op", op, "err", err)
+	}
+	return getResponseOpts(opt...)
+}
+
 // WithDiagnosticMessage provides an optional diagnostic message for the
 // response.
 func WithDiagnosticMessage(msg string) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*responseOptions); ok {
-			o.withDiagnosticMessage = msg
-		}
-	}
+	return responseOption(func(o *responseOptions) {
+		o.withDiagnosticMessage = msg
+	})
 }
 
 // WithMatchedDN provides an optional match DN for the response.
 func WithMatchedDN(dn string) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*responseOptions); ok {
-			o.withMatchedDN = dn
-		}
-	}
+	return responseOption(func(o *responseOptions) {
+		o.withMatchedDN = dn
+	})
 }
 
 // WithResponseCode specifies the ldap response code.  For a list of valid codes
 // see:
 // https://github.com/go-ldap/ldap/blob/13008e4c5260d08625b65eb1f172ae909152b751/v3/error.go#L11
 func WithResponseCode(code int) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*responseOptions); ok {
-			o.withResponseCode = &code
-		}
-	}
+	return responseOption(func(o *responseOptions) {
+		o.withResponseCode = &code
+	})
 }
 
 // WithApplicationCode specifies the ldap application code.  For a list of valid codes
 // for a list of supported application codes see:
 // https://github.com/jimlambrt/gldap/blob/8f171b8eb659c76019719382c4daf519dd1281e6/codes.go#L159
 func WithApplicationCode(applicationCode int) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*responseOptions); ok {
-			o.withApplicationCode = &applicationCode
-		}
-	}
+	return responseOption(func(o *responseOptions) {
+		o.withApplicationCode = &applicationCode
+	})
 }
 
-// WithAttributes specifies optional attributes for a response entry
+// WithAttributes specifies optional attributes for a response entry.  The
+// attributes are merged with those of the response's other WithAttributes and
+// WithAttribute options, so the values of an attribute that's specified more
+// than once are combined.  Attribute names are compared case-insensitively.
 func WithAttributes(attributes map[string][]string) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*responseOptions); ok {
-			o.withAttributes = attributes
+	return responseOption(func(o *responseOptions) {
+		names := make([]string, 0, len(attributes))
+		for name := range attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			o.addAttribute(name, attributes[name]...)
+		}
+	})
+}
+
+// WithAttribute specifies an optional attribute for a response entry, which
+// allows the entry's attributes to be built incrementally.  Like
+// WithAttributes, the values of an attribute that's specified more than once
+// are combined.
+func WithAttribute(name string, values ...string) Option {
+	return responseOption(func(o *responseOptions) {
+		o.addAttribute(name, values...)
+	})
+}
+
+// addAttribute adds the values to the options' attribute with the name, which
+// is added when the options don't have it.  Attributes are kept in the order
+// they're added.
+func (o *responseOptions) addAttribute(name string, values ...string) {
+	for _, a := range o.withAttributes {
+		if strings.EqualFold(a.Name, name) {
+			a.AddValue(values...)
+			return
 		}
 	}
+	o.withAttributes = append(o.withAttributes, NewEntryAttribute(name, append([]string{}, values...)))
 }
@@ -53,6 +53,38 @@ func Test_WithAttributes(t *testing.T) {
 	}
 	opts := getResponseOpts(WithAttributes(attrs))
 	testOpts := responseDefaults()
-	testOpts.withAttributes = attrs
+	testOpts.withAttributes = []*EntryAttribute{NewEntryAttribute("email", []string{"alice@alice.com"})}
 	assert.Equal(opts, testOpts)
+
+	// attributes are merged rather than replaced
+	opts = getResponseOpts(
+		WithAttributes(map[string][]string{"cn": {"alice"}, "email": {"alice@alice.com"}}),
+		WithAttributes(map[string][]string{"Email": {"alice@example.com"}, "sn": {"smith"}}),
+	)
+	testOpts.withAttributes = []*EntryAttribute{
+		NewEntryAttribute("cn", []string{"alice"}),
+		NewEntryAttribute("email", []string{"alice@alice.com", "alice@example.com"}),
+		NewEntryAttribute("sn", []string{"smith"}),
+	}
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithAttribute(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	values := []string{"alice@alice.com"}
+	opts := getResponseOpts(
+		WithAttribute("email", values...),
+		WithAttribute("cn", "alice"),
+		WithAttributes(map[string][]string{"email": {"alice@example.com"}}),
+		WithAttribute("EMAIL", "a@example.com"),
+	)
+	testOpts := responseDefaults()
+	testOpts.withAttributes = []*EntryAttribute{
+		NewEntryAttribute("email", []string{"alice@alice.com", "alice@example.com", "a@example.com"}),
+		NewEntryAttribute("cn", []string{"alice"}),
+	}
+	assert.Equal(opts, testOpts)
+	// the caller's values aren't modified
+	assert.Equal([]string{"alice@alice.com"}, values)
 }
@@ -6,10 +6,15 @@ package gldap
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-ldap/ldap/v3"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -193,6 +198,97 @@ func Test_baseResponse(t *testing.T) {
 	})
 }
 
+func TestSearchResponseEntry_mutation(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	r := &Request{ID: 1, message: &SearchMessage{baseMessage: baseMessage{id: 1}}}
+	e := r.NewSearchResponseEntry("cn=alice,ou=people,dc=example,dc=org",
+		WithAttribute("cn", "alice"),
+		WithAttribute("mail", "alice@example.org"),
+		WithAttribute("userPassword", "secret"),
+	)
+	assert.Equal("cn=alice,ou=people,dc=example,dc=org", e.DN())
+	assert.Equal([]string{"secret"}, e.GetAttributeValues("USERPASSWORD"))
+	assert.Empty(e.GetAttributeValues("missing"))
+
+	e.SetDN("cn=alice,ou=users,dc=example,dc=org")
+	e.RemoveAttribute("userpassword", "missing")
+	e.SetAttribute("MAIL", []string{"alice@example.com"})
+	e.SetAttribute("displayName", []string{"Alice"})
+	require.Len(e.Attributes(), 3)
+	assert.Equal("mail", e.Attributes()[1].Name)
+	assert.Empty(e.GetAttributeValues("userPassword"))
+
+	// the mutations are encoded when the entry is written
+	p := e.packet().Children[1]
+	require.Len(p.Children, 2)
+	assert.Equal("cn=alice,ou=users,dc=example,dc=org", p.Children[0].Value)
+	got := map[string][]string{}
+	for _, a := range p.Children[1].Children {
+		for _, v := range a.Children[1].Children {
+			got[a.Children[0].Value.(string)] = append(got[a.Children[0].Value.(string)], v.Data.String())
+		}
+	}
+	assert.Equal(map[string][]string{"cn": {"alice"}, "mail": {"alice@example.com"}, "displayName": {"Alice"}}, got)
+}
+
+func TestResponseWriter_WithInterceptor(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	s, err := NewServer()
+	require.NoError(err)
+	mux, err := NewMux()
+	require.NoError(err)
+	var calls []string
+	mux.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			next(w.WithInterceptor(func(resp Response) Response {
+				calls = append(calls, "redact")
+				if e, ok := resp.(*SearchResponseEntry); ok {
+					e.RemoveAttribute("userPassword")
+				}
+				return resp
+			}), r)
+		}
+	})
+	mux.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			next(w.WithInterceptor(func(resp Response) Response {
+				calls = append(calls, "filter")
+				if e, ok := resp.(*SearchResponseEntry); ok && e.DN() == "cn=hidden,dc=example,dc=org" {
+					return nil
+				}
+				return resp
+			}), r)
+		}
+	})
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org", WithAttribute("cn", "alice"), WithAttribute("userPassword", "secret")))
+		_ = w.Write(r.NewSearchResponseEntry("cn=hidden,dc=example,dc=org", WithAttribute("cn", "hidden")))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(s.Router(mux))
+	go func() { _ = s.Run("127.0.0.1:0") }()
+	defer func() { _ = s.Stop() }()
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+	client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", s.Addr()))
+	require.NoError(err)
+	defer client.Close()
+
+	res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	require.Len(res.Entries, 1)
+	assert.Equal("cn=alice,dc=example,dc=org", res.Entries[0].DN)
+	assert.Equal([]string{"alice"}, res.Entries[0].GetAttributeValues("cn"))
+	assert.Empty(res.Entries[0].GetAttributeValues("userPassword"))
+	// the innermost interceptor is called first, and a dropped response isn't
+	// passed to the outer interceptors
+	assert.Equal([]string{"filter", "redact", "filter", "filter", "redact"}, calls)
+
+	rw := &ResponseWriter{}
+	assert.Same(rw, rw.WithInterceptor(nil))
+}
+
 type testResponse struct {
 	*baseResponse
 	data string
@@ -203,3 +299,115 @@ func (r *testResponse) packet() *packet {
 	addOptionalResponseChildren(p, WithDiagnosticMessage(r.data))
 	return &packet{Packet: p}
 }
+
+func TestResponseWriter_WriteSearchResult(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	entries := []*Entry{
+		NewEntry("cn=alice,dc=example,dc=org", map[string][]string{"cn": {"alice"}, "mail": {"alice@example.org"}}),
+		NewEntry("cn=bob,dc=example,dc=org", map[string][]string{"cn": {"bob"}, "mail": {"bob@example.org"}}),
+	}
+	s, err := NewServer()
+	require.NoError(err)
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		c, err := NewControlString("1.2.3.4")
+		if err != nil {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		_ = w.WriteSearchResult(entries, ResultSuccess, c)
+	}))
+	require.NoError(s.Router(mux))
+	port := freePort(t)
+	go func() { _ = s.Run(fmt.Sprintf(":%d", port)) }()
+	defer func() { _ = s.Stop() }()
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+	client, err := ldap.DialURL(fmt.Sprintf("ldap://localhost:%d", port))
+	require.NoError(err)
+	defer client.Close()
+
+	res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"mail"}, nil))
+	require.NoError(err)
+	require.Len(res.Entries, 2)
+	assert.Equal("cn=bob,dc=example,dc=org", res.Entries[1].DN)
+	assert.Equal([]string{"alice@example.org"}, res.Entries[0].GetAttributeValues("mail"))
+	assert.Empty(res.Entries[0].GetAttributeValues("cn"))
+	require.Len(res.Controls, 1)
+	assert.Equal("1.2.3.4", res.Controls[0].GetControlType())
+
+	res, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, true, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	require.Len(res.Entries, 2)
+	require.Len(res.Entries[0].Attributes, 2)
+	assert.Empty(res.Entries[0].Attributes[0].Values)
+
+	res, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=*)", nil, nil))
+	assert.True(ldap.IsErrorWithCode(err, ResultSizeLimitExceeded))
+	require.NotNil(res)
+	assert.Len(res.Entries, 1)
+}
+
+func TestResponseWriter_WriteSearchResult_errors(t *testing.T) {
+	rw := &ResponseWriter{}
+	err := rw.WriteSearchResult(nil, ResultSuccess)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	rw.request = &Request{message: &DeleteMessage{}}
+	err = rw.WriteSearchResult(nil, ResultSuccess)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	rw.request = &Request{message: &SearchMessage{}}
+	err = rw.WriteSearchResult([]*Entry{nil}, ResultSuccess)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestResponseWriter_WriteSearchSource(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	s, err := NewServer()
+	require.NoError(err)
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		// the source yields entries until it fails
+		var n int
+		_ = w.WriteSearchSource(EntrySourceFunc(func(ctx context.Context) (*Entry, error) {
+			n++
+			if n > 2 {
+				return nil, errors.New("connection reset")
+			}
+			return NewEntry(fmt.Sprintf("cn=user%d,ou=failing,dc=example,dc=org", n), map[string][]string{"cn": {fmt.Sprintf("user%d", n)}}), nil
+		}), ResultSuccess)
+	}, WithBaseDN("ou=failing,dc=example,dc=org")))
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.WriteSearchSource(NewEntrySliceSource(
+			NewEntry("cn=alice,dc=example,dc=org", nil),
+			NewEntry("cn=bob,dc=example,dc=org", nil),
+		), ResultSuccess)
+	}))
+	require.NoError(s.Router(mux))
+	port := freePort(t)
+	go func() { _ = s.Run(fmt.Sprintf(":%d", port)) }()
+	defer func() { _ = s.Stop() }()
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+	client, err := ldap.DialURL(fmt.Sprintf("ldap://localhost:%d", port))
+	require.NoError(err)
+	defer client.Close()
+
+	res, err := client.Search(ldap.NewSearchRequest("ou=failing,dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	assert.True(ldap.IsErrorWithCode(err, ResultOperationsError))
+	require.NotNil(res)
+	assert.Len(res.Entries, 2)
+
+	// a size limit equal to the number of entries isn't exceeded
+	res, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	assert.Len(res.Entries, 2)
+	_, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=*)", nil, nil))
+	assert.True(ldap.IsErrorWithCode(err, ResultSizeLimitExceeded))
+
+	rw := &ResponseWriter{}
+	assert.ErrorIs(rw.WriteSearchSource(nil, ResultSuccess), ErrInvalidParameter)
+}
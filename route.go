@@ -4,74 +4,247 @@
 package gldap
 
 import (
+	"context"
+	"fmt"
+	"regexp"
 	"strings"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
 )
 
-// routeOperation represents the ldap operation for a route.
-type routeOperation string
+// RouteOperation represents the ldap operation for a route (see:
+// Mux.MatchFunc)
+type RouteOperation string
 
 const (
 	// undefinedRouteOperation is an undefined operation.
-	undefinedRouteOperation routeOperation = "" // nolint:unused
+	undefinedRouteOperation RouteOperation = "" // nolint:unused
+
+	// BindRouteOperation is a route supporting the bind operation
+	BindRouteOperation RouteOperation = "bind"
 
-	// bindRouteOperation is a route supporting the bind operation
-	bindRouteOperation routeOperation = "bind"
+	// SearchRouteOperation is a route supporting the search operation
+	SearchRouteOperation RouteOperation = "search"
 
-	// searchRouteOperation is a route supporting the search operation
-	searchRouteOperation routeOperation = "search"
+	// ExtendedRouteOperation is a route supporting an extended operation
+	ExtendedRouteOperation RouteOperation = "extendedOperation"
 
-	// extendedRouteOperation is a route supporting an extended operation
-	extendedRouteOperation routeOperation = "extendedOperation"
+	// ModifyRouteOperation is a route supporting the modify operation
+	ModifyRouteOperation RouteOperation = "modify"
 
-	// modifyRouteOperation is a route supporting the modify operation
-	modifyRouteOperation routeOperation = "modify"
+	// ModifyDNRouteOperation is a route supporting the modify DN operation
+	ModifyDNRouteOperation RouteOperation = "modifyDN"
 
-	// addRouteOperation is a route supporting the add operation
-	addRouteOperation routeOperation = "add"
+	// AddRouteOperation is a route supporting the add operation
+	AddRouteOperation RouteOperation = "add"
 
-	// deleteRouteOperation is a route supporting the delete operation
-	deleteRouteOperation routeOperation = "delete"
+	// DeleteRouteOperation is a route supporting the delete operation
+	DeleteRouteOperation RouteOperation = "delete"
 
-	// unbindRouteOperation is a route supporting the unbind operation
-	unbindRouteOperation routeOperation = "unbind"
+	// UnbindRouteOperation is a route supporting the unbind operation
+	UnbindRouteOperation RouteOperation = "unbind"
+
+	// AbandonRouteOperation is the abandon operation, which is handled by the
+	// conn and never routed to a handler
+	AbandonRouteOperation RouteOperation = "abandon"
 
 	// defaultRouteOperation is a default route which is used when there are no routes
 	// defined for a particular operation
-	defaultRouteOperation routeOperation = "noRoute" // nolint:unused
+	defaultRouteOperation RouteOperation = "noRoute" // nolint:unused
+)
+
+// the unexported names of the route operations, which predate their exported
+// names and are kept so existing code compiles
+const (
+	bindRouteOperation     = BindRouteOperation     // nolint:unused
+	searchRouteOperation   = SearchRouteOperation   // nolint:unused
+	extendedRouteOperation = ExtendedRouteOperation // nolint:unused
+	modifyRouteOperation   = ModifyRouteOperation   // nolint:unused
+	modifyDNRouteOperation = ModifyDNRouteOperation // nolint:unused
+	addRouteOperation      = AddRouteOperation      // nolint:unused
+	deleteRouteOperation   = DeleteRouteOperation   // nolint:unused
+	unbindRouteOperation   = UnbindRouteOperation   // nolint:unused
+	abandonRouteOperation  = AbandonRouteOperation  // nolint:unused
 )
 
+// String returns the operation's name (i.e. "search")
+func (o RouteOperation) String() string {
+	return string(o)
+}
+
+// ParseRouteOperation returns the operation with the name (i.e. "search" or
+// "modifyDN").  Names are compared case-insensitively.
+func ParseRouteOperation(name string) (RouteOperation, error) {
+	const op = "gldap.ParseRouteOperation"
+	// the monitor reports every operation
+	for _, o := range monitorOperations {
+		if strings.EqualFold(string(o.op), name) {
+			return o.op, nil
+		}
+	}
+	return "", fmt.Errorf("%s: unknown operation %q: %w", op, name, ErrInvalidParameter)
+}
+
 // HandlerFunc defines a function for handling an LDAP request.
 type HandlerFunc func(*ResponseWriter, *Request)
 
+// HandlerFuncCtx defines a function for handling an LDAP request with the
+// request's context (see: Request.Context), which is done when the client
+// abandons or cancels the request, the client unbinds, the connection is
+// closed, the server is stopped or the route times out (see:
+// WithRouteTimeout).  The ctx can be passed to database and HTTP clients, and
+// a HandlerFuncCtx is registered with a Mux using its HandlerFunc adapter:
+//
+//	mux.Search(gldap.HandlerFuncCtx(func(ctx context.Context, w *gldap.ResponseWriter, r *gldap.Request) {
+//		rows, err := db.QueryContext(ctx, ...)
+//		...
+//	}).HandlerFunc())
+type HandlerFuncCtx func(ctx context.Context, w *ResponseWriter, r *Request)
+
+// HandlerFunc adapts the handler to a HandlerFunc, which is called with the
+// request's context.
+func (fn HandlerFuncCtx) HandlerFunc() HandlerFunc {
+	return func(w *ResponseWriter, r *Request) {
+		fn(r.Context(), w, r)
+	}
+}
+
+// HandlerFuncCtx adapts the handler to a HandlerFuncCtx, for composing it
+// with handlers that use the HandlerFuncCtx signature.  The ctx isn't passed
+// to the handler, which uses the request's context instead.
+func (fn HandlerFunc) HandlerFuncCtx() HandlerFuncCtx {
+	return func(_ context.Context, w *ResponseWriter, r *Request) {
+		fn(w, r)
+	}
+}
+
 type route interface {
 	match(req *Request) bool
 	handler() HandlerFunc
-	op() routeOperation
+	op() RouteOperation
 }
 
 type baseRoute struct {
 	h       HandlerFunc
-	routeOp routeOperation
+	routeOp RouteOperation
 	label   string
+	timeout time.Duration
+
+	// requireAuth and allowedBindDNs restrict the route to authenticated
+	// conns (see: WithRequireAuthentication and WithAllowedBindDNs)
+	requireAuth    bool
+	allowedBindDNs []string
+
+	// requireConfidentiality restricts the route to conns protected by TLS
+	// (see: WithRequireConfidentiality)
+	requireConfidentiality bool
+
+	// criticalControlPolicy is the route's policy for requests with unknown
+	// critical controls, which is the conn's policy when it's zero (see:
+	// WithRouteCriticalControlPolicy)
+	criticalControlPolicy CriticalControlPolicy
+
+	// supportedControls are the types of the controls the route serves in
+	// addition to its conn's (see: WithRouteSupportedControls)
+	supportedControls []string
+
+	// suffixes are the naming contexts the route is scoped to (see: Mux.Group)
+	suffixes []string
 }
 
 func (r *baseRoute) handler() HandlerFunc {
 	return r.h
 }
 
-func (r *baseRoute) op() routeOperation {
+// wrap wraps the route's handler with the middlewares
+func (r *baseRoute) wrap(middlewares []Middleware) {
+	r.h = wrapHandler(r.h, middlewares)
+}
+
+// setSuffixes scopes the route to the suffixes
+func (r *baseRoute) setSuffixes(suffixes []string) {
+	r.suffixes = suffixes
+}
+
+func (r *baseRoute) routeSuffixes() []string {
+	return r.suffixes
+}
+
+func (r *baseRoute) op() RouteOperation {
 	return r.routeOp
 }
 
+func (r *baseRoute) routeLabel() string {
+	return r.label
+}
+
+func (r *baseRoute) routeTimeout() time.Duration {
+	return r.timeout
+}
+
+func (r *baseRoute) routeAuth() (bool, []string) {
+	return r.requireAuth, r.allowedBindDNs
+}
+
+// routeConfidentiality returns true if the route is restricted to conns
+// protected by TLS
+func (r *baseRoute) routeConfidentiality() bool {
+	return r.requireConfidentiality
+}
+
+func (r *baseRoute) routeCriticalControlPolicy() CriticalControlPolicy {
+	return r.criticalControlPolicy
+}
+
+// authorized returns ResultSuccess if the request's conn is authorized to use
+// the route, or the result code and diagnostic message of the response when it
+// isn't: confidentialityRequired for a conn that isn't protected by TLS and
+// insufficientAccessRights for a conn that isn't bound as an allowed DN.
+func (r *baseRoute) authorized(req *Request) (int, string) {
+	if code, diag := r.criticalControlsSupported(req); code != ResultSuccess {
+		return code, diag
+	}
+	if r.requireConfidentiality && (req.conn == nil || !req.conn.isTLS()) {
+		return ResultConfidentialityRequired, confidentialityRequiredDiag
+	}
+	if !r.requireAuth && len(r.allowedBindDNs) == 0 {
+		return ResultSuccess, ""
+	}
+	boundDN := req.BoundDN()
+	if boundDN == "" {
+		return ResultInsufficientAccessRights, "authentication required"
+	}
+	if len(r.allowedBindDNs) == 0 {
+		return ResultSuccess, ""
+	}
+	for _, dn := range r.allowedBindDNs {
+		if ok, err := dnInScope(boundDN, dn, BaseObject); err == nil && ok {
+			return ResultSuccess, ""
+		}
+	}
+	return ResultInsufficientAccessRights, "bind DN not allowed"
+}
+
 func (r *baseRoute) match(req *Request) bool {
 	return false
 }
 
 type searchRoute struct {
 	*baseRoute
-	basedn string
-	filter string
-	scope  Scope
+	basedn       string
+	baseDNSuffix string
+	filter       string
+	// compiledFilter is the filter compiled when the route is registered,
+	// so it isn't compiled for every request (see: routeFilter)
+	compiledFilter *ber.Packet
+	filterPattern  *regexp.Regexp
+	scope          Scope
+}
+
+type rootDSERoute struct {
+	*baseRoute
 }
 
 type simpleBindRoute struct {
@@ -92,6 +265,15 @@ type modifyRoute struct {
 	*baseRoute
 }
 
+type matchFuncRoute struct {
+	*baseRoute
+	matchFn func(*Request) bool
+}
+
+type modifyDNRoute struct {
+	*baseRoute
+}
+
 type addRoute struct {
 	*baseRoute
 }
@@ -126,6 +308,29 @@ func (r *addRoute) match(req *Request) bool {
 	return true
 }
 
+func (r *matchFuncRoute) match(req *Request) bool {
+	if req == nil {
+		return false
+	}
+	if r.op() != req.routeOp {
+		return false
+	}
+	return r.matchFn(req)
+}
+
+func (r *modifyDNRoute) match(req *Request) bool {
+	if req == nil {
+		return false
+	}
+	if r.op() != req.routeOp {
+		return false
+	}
+	if _, ok := req.message.(*ModifyDNMessage); !ok {
+		return false
+	}
+	return true
+}
+
 func (r *modifyRoute) match(req *Request) bool {
 	if req == nil {
 		return false
@@ -168,6 +373,20 @@ func (r *extendedRoute) match(req *Request) bool {
 	return ok
 }
 
+func (r *rootDSERoute) match(req *Request) bool {
+	if req == nil {
+		return false
+	}
+	if r.op() != req.routeOp {
+		return false
+	}
+	searchMsg, ok := req.message.(*SearchMessage)
+	if !ok {
+		return false
+	}
+	return searchMsg.BaseDN == "" && searchMsg.Scope == BaseObject
+}
+
 func (r *searchRoute) match(req *Request) bool {
 	if req == nil {
 		return false
@@ -182,7 +401,15 @@ func (r *searchRoute) match(req *Request) bool {
 	if r.basedn != "" && !strings.EqualFold(searchMsg.BaseDN, r.basedn) {
 		return false
 	}
-	if r.filter != "" && !strings.EqualFold(searchMsg.Filter, r.filter) {
+	if r.baseDNSuffix != "" {
+		if ok, err := dnInScope(searchMsg.BaseDN, r.baseDNSuffix, WholeSubtree); err != nil || !ok {
+			return false
+		}
+	}
+	if rf := r.routeFilter(); rf != nil && !matchRouteFilter(rf, req) {
+		return false
+	}
+	if r.filterPattern != nil && !r.filterPattern.MatchString(searchMsg.Filter) {
 		return false
 	}
 	if r.scope != 0 && searchMsg.Scope != r.scope {
@@ -193,3 +420,152 @@ func (r *searchRoute) match(req *Request) bool {
 	// match.
 	return true
 }
+
+// routeFilter returns the route's compiled filter, which is compiled when the
+// route wasn't registered with one.  It returns nil when the route doesn't
+// have a filter.
+func (r *searchRoute) routeFilter() *ber.Packet {
+	if r.compiledFilter != nil || r.filter == "" {
+		return r.compiledFilter
+	}
+	f, err := ldap.CompileFilter(r.filter)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// matchRouteFilter returns true if the search request's filter is semantically
+// equal to the route's compiled filter (see: filtersEqual).  The request's
+// filter is compiled once for all the routes (see: Request.searchFilter), and
+// a request filter that can't be compiled doesn't match.
+func matchRouteFilter(routeFilter *ber.Packet, req *Request) bool {
+	f, err := req.searchFilter()
+	if err != nil {
+		return false
+	}
+	return filtersEqual(routeFilter, f)
+}
+
+// inRouteSuffixes returns true if the request's target DN is within the
+// subtrees of the route's suffixes (see: Mux.Group).  Requests without a
+// target DN are always within them.
+func inRouteSuffixes(r route, req *Request) bool {
+	s, ok := r.(interface{ routeSuffixes() []string })
+	if !ok || len(s.routeSuffixes()) == 0 {
+		return true
+	}
+	dn, ok := req.targetDN()
+	if !ok {
+		return true
+	}
+	for _, suffix := range s.routeSuffixes() {
+		if ok, err := dnInScope(dn, suffix, WholeSubtree); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// routeAuthorized returns ResultSuccess if the request's conn is authorized to
+// use the route, or the result code and diagnostic message of the response
+// when it isn't.
+func routeAuthorized(r route, req *Request) (int, string) {
+	if a, ok := r.(interface {
+		authorized(*Request) (int, string)
+	}); ok {
+		return a.authorized(req)
+	}
+	return ResultSuccess, ""
+}
+
+// RouteInfo describes a route registered with a Mux (see: Mux.Routes)
+type RouteInfo struct {
+	// Operation of the requests the route serves, which is empty for the
+	// mux's DefaultRoute since it serves every operation
+	Operation RouteOperation
+	// Label of the route (see: WithLabel)
+	Label string
+	// Timeout of the route's handler (see: WithRouteTimeout)
+	Timeout time.Duration
+	// RequireAuthentication is true for a route restricted to authenticated
+	// connections (see: WithRequireAuthentication)
+	RequireAuthentication bool
+	// AllowedBindDNs are the bind DNs a route is restricted to (see:
+	// WithAllowedBindDNs)
+	AllowedBindDNs []string
+	// RequireConfidentiality is true for a route restricted to connections
+	// protected by TLS (see: WithRequireConfidentiality)
+	RequireConfidentiality bool
+	// CriticalControlPolicy of the route, which is zero when the route uses
+	// its server's policy (see: WithRouteCriticalControlPolicy)
+	CriticalControlPolicy CriticalControlPolicy
+	// BaseDN of a search route (see: WithBaseDN)
+	BaseDN string
+	// BaseDNSuffix of a search route (see: WithBaseDNSuffix)
+	BaseDNSuffix string
+	// Filter of a search route (see: WithFilter)
+	Filter string
+	// FilterPattern of a search route (see: WithFilterPattern)
+	FilterPattern string
+	// Scope of a search route (see: WithScope)
+	Scope Scope
+	// ExtendedName is the name (OID) of an extended operation route
+	ExtendedName ExtendedOperationName
+	// Suffixes are the naming contexts the route is scoped to (see:
+	// Mux.Group)
+	Suffixes []string
+	// RootDSE is true for a route registered with Mux.RootDSE
+	RootDSE bool
+	// MatchFunc is true for a route registered with Mux.MatchFunc
+	MatchFunc bool
+	// Default is true for a default route (see: Mux.DefaultRoute and
+	// Mux.DefaultSearch)
+	Default bool
+}
+
+// routeInfo returns the description of the route
+func routeInfo(r route) RouteInfo {
+	info := RouteInfo{Operation: r.op()}
+	if b, ok := r.(interface{ routeLabel() string }); ok {
+		info.Label = b.routeLabel()
+	}
+	if b, ok := r.(interface{ routeTimeout() time.Duration }); ok {
+		info.Timeout = b.routeTimeout()
+	}
+	if b, ok := r.(interface{ routeAuth() (bool, []string) }); ok {
+		requireAuth, allowedBindDNs := b.routeAuth()
+		info.RequireAuthentication = requireAuth
+		if len(allowedBindDNs) > 0 {
+			info.AllowedBindDNs = append([]string{}, allowedBindDNs...)
+		}
+	}
+	if b, ok := r.(interface{ routeConfidentiality() bool }); ok {
+		info.RequireConfidentiality = b.routeConfidentiality()
+	}
+	if b, ok := r.(interface {
+		routeCriticalControlPolicy() CriticalControlPolicy
+	}); ok {
+		info.CriticalControlPolicy = b.routeCriticalControlPolicy()
+	}
+	if b, ok := r.(interface{ routeSuffixes() []string }); ok && len(b.routeSuffixes()) > 0 {
+		info.Suffixes = append([]string{}, b.routeSuffixes()...)
+	}
+	switch v := r.(type) {
+	case *searchRoute:
+		info.BaseDN = v.basedn
+		info.BaseDNSuffix = v.baseDNSuffix
+		info.Filter = v.filter
+		if v.filterPattern != nil {
+			info.FilterPattern = v.filterPattern.String()
+		}
+		info.Scope = v.scope
+	case *extendedRoute:
+		info.ExtendedName = v.extendedName
+	case *rootDSERoute:
+		info.RootDSE = true
+	case *matchFuncRoute:
+		info.MatchFunc = true
+	}
+	return info
+}
@@ -3,18 +3,40 @@
 
 package gldap
 
+import (
+	"regexp"
+	"time"
+)
+
 type routeOptions struct {
 	withLabel  string
 	withBaseDN string
 	withFilter string
 	withScope  Scope
+
+	withBaseDNSuffix  string
+	withFilterPattern *regexp.Regexp
+
+	withEncodeFunc ExtendedEncodeFunc
+
+	withRouteTimeout time.Duration
+
+	withRequireAuthentication bool
+	withAllowedBindDNs        []string
+
+	withRequireConfidentiality bool
+
+	withSingleflight bool
+
+	withCriticalControlPolicy CriticalControlPolicy
+	withSupportedControls     []string
 }
 
 func routeDefaults() routeOptions {
 	return routeOptions{}
 }
 
-func getRouteOpts(opt ...Option) routeOptions {
+func getRouteOpts(opt ...RouteOption) routeOptions {
 	opts := routeDefaults()
 	applyOpts(&opts, opt...)
 	return opts
@@ -22,36 +44,127 @@ func getRouteOpts(opt ...Option) routeOptions {
 
 // WithLabel specifies an optional label for the route
 func WithLabel(l string) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*routeOptions); ok {
-			o.withLabel = l
-		}
-	}
+	return routeOption(func(o *routeOptions) {
+		o.withLabel = l
+	})
 }
 
 // WithBaseDN specifies an optional base DN to associate with a Search route
 func WithBaseDN(dn string) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*routeOptions); ok {
-			o.withBaseDN = dn
-		}
-	}
+	return routeOption(func(o *routeOptions) {
+		o.withBaseDN = dn
+	})
 }
 
-// WithFilter specifies an optional filter to associate with a Search route
+// WithBaseDNSuffix specifies an optional base DN suffix (i.e. a naming context
+// like "dc=example,dc=com") to associate with a Search route, which matches
+// searches with a base DN anywhere within the suffix's subtree.  DNs are
+// compared case-insensitively.
+func WithBaseDNSuffix(suffix string) Option {
+	return routeOption(func(o *routeOptions) {
+		o.withBaseDNSuffix = suffix
+	})
+}
+
+// WithFilter specifies an optional filter to associate with a Search route.
+// Filters are matched semantically, so the order of the filters within an
+// and/or filter doesn't matter and attribute descriptions and values are
+// compared case-insensitively.  The filter is compiled when the route is
+// registered and an invalid filter is an error.
 func WithFilter(filter string) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*routeOptions); ok {
-			o.withFilter = filter
-		}
-	}
+	return routeOption(func(o *routeOptions) {
+		o.withFilter = filter
+	})
+}
+
+// WithFilterPattern specifies an optional regular expression to associate
+// with a Search route, which matches searches with a filter that matches the
+// pattern (i.e. regexp.MustCompile(`^\(&\(objectClass=person\)`))
+func WithFilterPattern(pattern *regexp.Regexp) Option {
+	return routeOption(func(o *routeOptions) {
+		o.withFilterPattern = pattern
+	})
 }
 
 // WithScope specifies and optional scope to associate with a Search route
 func WithScope(s Scope) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*routeOptions); ok {
-			o.withScope = s
+	return routeOption(func(o *routeOptions) {
+		o.withScope = s
+	})
+}
+
+// WithEncodeFunc specifies an optional function to encode the response values
+// of an ExtendedOperationWithCodec route
+func WithEncodeFunc(fn ExtendedEncodeFunc) Option {
+	return routeOption(func(o *routeOptions) {
+		o.withEncodeFunc = fn
+	})
+}
+
+// WithRouteTimeout specifies an optional timeout for a route's handler (i.e. a
+// search that proxies to a slow backend).  The request's context (see:
+// Request.Context) has a deadline of the timeout, and when the handler hasn't
+// responded by then the request is responded to with timeLimitExceeded, which
+// is written with the interceptors of the mux's middlewares (see: Mux.Use).
+// Responses the handler writes after the timeout aren't written.
+func WithRouteTimeout(d time.Duration) Option {
+	return routeOption(func(o *routeOptions) {
+		if d > 0 {
+			o.withRouteTimeout = d
 		}
-	}
+	})
+}
+
+// WithRequireAuthentication specifies that a route only serves requests of
+// connections that have completed a successful bind.  A request of an
+// anonymous connection that matches the route is responded to with
+// insufficientAccessRights without invoking a handler, rather than being
+// dispatched to a later route.
+func WithRequireAuthentication() Option {
+	return routeOption(func(o *routeOptions) {
+		o.withRequireAuthentication = true
+	})
+}
+
+// WithAllowedBindDNs specifies that a route only serves requests of
+// connections whose last successful bind was as one of the DNs, which are
+// compared case-insensitively.  A request of another connection that matches
+// the route is responded to with insufficientAccessRights without invoking a
+// handler, rather than being dispatched to a later route.
+func WithAllowedBindDNs(dn ...string) Option {
+	return routeOption(func(o *routeOptions) {
+		o.withAllowedBindDNs = append(o.withAllowedBindDNs, dn...)
+	})
+}
+
+// WithRequireConfidentiality specifies that a route only serves requests of
+// connections protected by TLS, either because they were accepted by a TLS
+// listener or they completed a StartTLS request.  A request of another
+// connection that matches the route is responded to with
+// confidentialityRequired without invoking a handler, rather than being
+// dispatched to a later route (see: WithRequireTLS to require TLS for every
+// route).
+func WithRequireConfidentiality() Option {
+	return routeOption(func(o *routeOptions) {
+		o.withRequireConfidentiality = true
+	})
+}
+
+// WithSingleflight specifies that identical search requests which arrive while
+// the route's handler is serving one of them (i.e. a thundering herd of
+// clients refreshing the same cache) wait for the handler to return and are
+// responded to with its responses, rather than calling the handler
+// themselves.  Searches are identical when all their parameters (base DN,
+// scope, filter, attributes, limits, etc) and their connections' bound DNs are
+// equal, and searches with controls are never deduplicated.  The handler's
+// responses must only depend on those parameters (i.e. not on the connection
+// ID, request ID or the request's arrival time) and the responses are only
+// shared when the handler writes search entries and a search done response.
+// Otherwise, the waiting requests call the handler themselves, which also
+// happens when the first request is abandoned, canceled or times out.
+// Middlewares are called for every request (see: Mux.Use).
+func WithSingleflight() Option {
+	return routeOption(func(o *routeOptions) {
+		o.withSingleflight = true
+	})
 }
@@ -4,7 +4,11 @@
 package gldap
 
 import (
+	"reflect"
+	"regexp"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -27,6 +31,15 @@ func Test_WithBaseDN(t *testing.T) {
 	assert.Equal(opts, testOpts)
 }
 
+func Test_WithBaseDNSuffix(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithBaseDNSuffix("dc=example,dc=com"))
+	testOpts := routeDefaults()
+	testOpts.withBaseDNSuffix = "dc=example,dc=com"
+	assert.Equal(opts, testOpts)
+}
+
 func Test_WithFilter(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
@@ -36,6 +49,16 @@ func Test_WithFilter(t *testing.T) {
 	assert.Equal(opts, testOpts)
 }
 
+func Test_WithFilterPattern(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	pattern := regexp.MustCompile(`^\(uid=.*\)$`)
+	opts := getRouteOpts(WithFilterPattern(pattern))
+	testOpts := routeDefaults()
+	testOpts.withFilterPattern = pattern
+	assert.Equal(opts, testOpts)
+}
+
 func Test_WithScope(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
@@ -44,3 +67,83 @@ func Test_WithScope(t *testing.T) {
 	testOpts.withScope = SingleLevel
 	assert.Equal(opts, testOpts)
 }
+
+func Test_WithEncodeFunc(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	fn := func(interface{}) ([]byte, error) { return nil, nil }
+	opts := getRouteOpts(WithEncodeFunc(fn))
+	testOpts := routeDefaults()
+	testOpts.withEncodeFunc = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withEncodeFunc).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withEncodeFunc).Pointer()).Name())
+}
+
+func Test_WithRouteTimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithRouteTimeout(time.Second))
+	testOpts := routeDefaults()
+	testOpts.withRouteTimeout = time.Second
+	assert.Equal(opts, testOpts)
+
+	// a timeout that isn't positive is ignored
+	assert.Equal(routeDefaults(), getRouteOpts(WithRouteTimeout(-1)))
+}
+
+func Test_WithRequireAuthentication(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithRequireAuthentication())
+	testOpts := routeDefaults()
+	testOpts.withRequireAuthentication = true
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithAllowedBindDNs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithAllowedBindDNs("cn=alice,dc=example,dc=org"), WithAllowedBindDNs("cn=bob,dc=example,dc=org"))
+	testOpts := routeDefaults()
+	testOpts.withAllowedBindDNs = []string{"cn=alice,dc=example,dc=org", "cn=bob,dc=example,dc=org"}
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithRequireConfidentiality(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithRequireConfidentiality())
+	testOpts := routeDefaults()
+	testOpts.withRequireConfidentiality = true
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithSingleflight(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithSingleflight())
+	testOpts := routeDefaults()
+	testOpts.withSingleflight = true
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithRouteCriticalControlPolicy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithRouteCriticalControlPolicy(IgnoreUnknownCriticalControls))
+	testOpts := routeDefaults()
+	testOpts.withCriticalControlPolicy = IgnoreUnknownCriticalControls
+	assert.Equal(opts, testOpts)
+
+	// an invalid policy is ignored
+	assert.Equal(routeDefaults(), getRouteOpts(WithRouteCriticalControlPolicy(42)))
+}
+
+func Test_WithRouteSupportedControls(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getRouteOpts(WithRouteSupportedControls("1.2.3.4"), WithRouteSupportedControls("1.2.3.5"))
+	testOpts := routeDefaults()
+	testOpts.withSupportedControls = []string{"1.2.3.4", "1.2.3.5"}
+	assert.Equal(opts, testOpts)
+}
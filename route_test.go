@@ -4,6 +4,9 @@
 package gldap
 
 import (
+	"context"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -80,6 +83,68 @@ func TestSearchRoute_match(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "baseDNSuffix-match",
+			route: &searchRoute{
+				baseRoute: &baseRoute{
+					routeOp: searchRouteOperation,
+				},
+				baseDNSuffix: "dc=example,dc=com",
+			},
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					BaseDN: "uid=alice,OU=People,dc=example,dc=com",
+				},
+			},
+			wantMatch: true,
+		},
+		{
+			name: "baseDNSuffix-match-suffix",
+			route: &searchRoute{
+				baseRoute: &baseRoute{
+					routeOp: searchRouteOperation,
+				},
+				baseDNSuffix: "dc=example,dc=com",
+			},
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					BaseDN: "DC=Example,DC=Com",
+				},
+			},
+			wantMatch: true,
+		},
+		{
+			name: "baseDNSuffix-mismatch",
+			route: &searchRoute{
+				baseRoute: &baseRoute{
+					routeOp: searchRouteOperation,
+				},
+				baseDNSuffix: "dc=example,dc=com",
+			},
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					BaseDN: "ou=people,dc=alice,dc=com",
+				},
+			},
+		},
+		{
+			name: "baseDNSuffix-invalid-base-dn",
+			route: &searchRoute{
+				baseRoute: &baseRoute{
+					routeOp: searchRouteOperation,
+				},
+				baseDNSuffix: "dc=example,dc=com",
+			},
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					BaseDN: "invalid",
+				},
+			},
+		},
 		{
 			name: "filter-match",
 			route: &searchRoute{
@@ -111,6 +176,53 @@ func TestSearchRoute_match(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "filter-semantic-match",
+			route: &searchRoute{
+				baseRoute: &baseRoute{
+					routeOp: searchRouteOperation,
+				},
+				filter: "(&(objectClass=person)(uid=alice))",
+			},
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					Filter: "(&(uid=Alice)(objectclass=person))",
+				},
+			},
+			wantMatch: true,
+		},
+		{
+			name: "filterPattern-match",
+			route: &searchRoute{
+				baseRoute: &baseRoute{
+					routeOp: searchRouteOperation,
+				},
+				filterPattern: regexp.MustCompile(`^\(uid=[a-z]+\)$`),
+			},
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					Filter: "(uid=alice)",
+				},
+			},
+			wantMatch: true,
+		},
+		{
+			name: "filterPattern-mismatch",
+			route: &searchRoute{
+				baseRoute: &baseRoute{
+					routeOp: searchRouteOperation,
+				},
+				filterPattern: regexp.MustCompile(`^\(uid=[a-z]+\)$`),
+			},
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					Filter: "(cn=alice)",
+				},
+			},
+		},
 		{
 			name: "scope-match",
 			route: &searchRoute{
@@ -157,6 +269,71 @@ func TestSearchRoute_match(t *testing.T) {
 	}
 }
 
+func TestRootDSERoute_match(t *testing.T) {
+	t.Parallel()
+	route := &rootDSERoute{
+		baseRoute: &baseRoute{
+			routeOp: searchRouteOperation,
+		},
+	}
+	tests := []struct {
+		name      string
+		req       *Request
+		wantMatch bool
+	}{
+		{
+			name: "req-nil",
+		},
+		{
+			name: "op-mismatched",
+			req: &Request{
+				routeOp: bindRouteOperation,
+			},
+		},
+		{
+			name: "not-a-search-msg",
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SimpleBindMessage{},
+			},
+		},
+		{
+			name: "baseDN-mismatch",
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					BaseDN: "dc=example,dc=com",
+					Scope:  BaseObject,
+				},
+			},
+		},
+		{
+			name: "scope-mismatch",
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					Scope: WholeSubtree,
+				},
+			},
+		},
+		{
+			name: "match",
+			req: &Request{
+				routeOp: searchRouteOperation,
+				message: &SearchMessage{
+					Scope: BaseObject,
+				},
+			},
+			wantMatch: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantMatch, route.match(tc.req))
+		})
+	}
+}
+
 func TestSimpleBindRoute_match(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -459,9 +636,113 @@ func TestModifyRoute_match(t *testing.T) {
 	}
 }
 
+func TestMatchFuncRoute_match(t *testing.T) {
+	t.Parallel()
+	posixAccount := func(r *Request) bool {
+		m, err := r.GetSearchMessage()
+		return err == nil && strings.Contains(strings.ToLower(m.Filter), "objectclass=posixaccount")
+	}
+	tests := []struct {
+		name      string
+		route     *matchFuncRoute
+		req       *Request
+		wantMatch bool
+	}{
+		{
+			name: "req-nil",
+			route: &matchFuncRoute{
+				baseRoute: &baseRoute{routeOp: SearchRouteOperation},
+				matchFn:   posixAccount,
+			},
+		},
+		{
+			name: "op-mismatched",
+			route: &matchFuncRoute{
+				baseRoute: &baseRoute{routeOp: SearchRouteOperation},
+				matchFn:   func(*Request) bool { return true },
+			},
+			req: &Request{
+				routeOp: ModifyRouteOperation,
+				message: &ModifyMessage{},
+			},
+		},
+		{
+			name: "func-mismatched",
+			route: &matchFuncRoute{
+				baseRoute: &baseRoute{routeOp: SearchRouteOperation},
+				matchFn:   posixAccount,
+			},
+			req: &Request{
+				routeOp: SearchRouteOperation,
+				message: &SearchMessage{Filter: "(objectClass=person)"},
+			},
+		},
+		{
+			name: "success",
+			route: &matchFuncRoute{
+				baseRoute: &baseRoute{routeOp: SearchRouteOperation},
+				matchFn:   posixAccount,
+			},
+			req: &Request{
+				routeOp: SearchRouteOperation,
+				message: &SearchMessage{Filter: "(&(objectClass=posixAccount)(uid=alice))"},
+			},
+			wantMatch: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(tc.wantMatch, tc.route.match(tc.req))
+		})
+	}
+}
+
 func TestBaseRoute_match(t *testing.T) {
 	t.Run("always-fail", func(t *testing.T) {
 		r := baseRoute{}
 		require.False(t, r.match(&Request{}))
 	})
 }
+
+func TestHandlerFuncCtx(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	type ctxKey struct{}
+	r := &Request{ctx: context.WithValue(context.Background(), ctxKey{}, "value")}
+
+	var got context.Context
+	HandlerFuncCtx(func(ctx context.Context, w *ResponseWriter, r *Request) {
+		got = ctx
+	}).HandlerFunc()(&ResponseWriter{}, r)
+	assert.Equal("value", got.Value(ctxKey{}))
+
+	var called bool
+	HandlerFunc(func(w *ResponseWriter, req *Request) {
+		called = true
+		assert.Same(r, req)
+	}).HandlerFuncCtx()(context.Background(), &ResponseWriter{}, r)
+	assert.True(called)
+}
+
+func TestParseRouteOperation(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	for _, o := range []RouteOperation{
+		BindRouteOperation, SearchRouteOperation, ExtendedRouteOperation,
+		ModifyRouteOperation, ModifyDNRouteOperation, AddRouteOperation,
+		DeleteRouteOperation, UnbindRouteOperation, AbandonRouteOperation,
+	} {
+		got, err := ParseRouteOperation(o.String())
+		assert.NoError(err)
+		assert.Equal(o, got)
+	}
+	got, err := ParseRouteOperation("MODIFYDN")
+	assert.NoError(err)
+	assert.Equal(ModifyDNRouteOperation, got)
+
+	for _, name := range []string{"", "noRoute", "compare"} {
+		_, err := ParseRouteOperation(name)
+		assert.ErrorIs(err, ErrInvalidParameter, name)
+	}
+}
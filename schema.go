@@ -0,0 +1,162 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ObjectClass is an object class of a Schema (see:
+// https://tools.ietf.org/html/rfc4512#section-4.1.1)
+type ObjectClass struct {
+	// Name of the object class
+	Name string
+	// Must are the attributes an entry of the object class must have
+	Must []string
+	// May are the attributes an entry of the object class may have
+	May []string
+}
+
+// AttributeType is an attribute type of a Schema (see:
+// https://tools.ietf.org/html/rfc4512#section-4.1.2)
+type AttributeType struct {
+	// Name of the attribute type
+	Name string
+	// Equality is the matching rule used to compare the attribute's values
+	Equality MatchingRule
+	// SingleValue is true if the attribute may only have one value
+	SingleValue bool
+	// NoUserModification is true if the attribute can't be modified by
+	// clients (i.e. an operational attribute maintained by the server)
+	NoUserModification bool
+}
+
+// Schema is a minimal directory schema, which is used to validate modified
+// entries (see: ApplyModify).  Attributes without an attribute type use
+// AttributeMatchingRule(...) to compare their values.
+type Schema struct {
+	mu             sync.RWMutex
+	objectClasses  map[string]ObjectClass
+	attributeTypes map[string]AttributeType
+}
+
+// NewSchema creates a new schema which has the "top" and "extensibleObject"
+// object classes (see: https://tools.ietf.org/html/rfc4512#section-4.3)
+func NewSchema() *Schema {
+	s := &Schema{
+		objectClasses:  map[string]ObjectClass{},
+		attributeTypes: map[string]AttributeType{},
+	}
+	s.objectClasses["top"] = ObjectClass{Name: "top", Must: []string{"objectClass"}}
+	s.objectClasses["extensibleobject"] = ObjectClass{Name: "extensibleObject"}
+	return s
+}
+
+// AddObjectClass adds the object class to the schema
+func (s *Schema) AddObjectClass(oc ObjectClass) error {
+	const op = "gldap.(Schema).AddObjectClass"
+	if oc.Name == "" {
+		return fmt.Errorf("%s: missing object class name: %w", op, ErrInvalidParameter)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(oc.Name)
+	if _, ok := s.objectClasses[key]; ok {
+		return fmt.Errorf("%s: object class %q already exists: %w", op, oc.Name, ErrInvalidParameter)
+	}
+	s.objectClasses[key] = oc
+	return nil
+}
+
+// AddAttributeType adds the attribute type to the schema
+func (s *Schema) AddAttributeType(at AttributeType) error {
+	const op = "gldap.(Schema).AddAttributeType"
+	if at.Name == "" {
+		return fmt.Errorf("%s: missing attribute type name: %w", op, ErrInvalidParameter)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(at.Name)
+	if _, ok := s.attributeTypes[key]; ok {
+		return fmt.Errorf("%s: attribute type %q already exists: %w", op, at.Name, ErrInvalidParameter)
+	}
+	s.attributeTypes[key] = at
+	return nil
+}
+
+// MatchingRule returns the equality matching rule of the attribute, which is
+// the attribute type's matching rule or AttributeMatchingRule(...) when the
+// schema doesn't have the attribute type.
+func (s *Schema) MatchingRule(name string) MatchingRule {
+	if at, ok := s.attributeType(name); ok {
+		return at.Equality
+	}
+	return AttributeMatchingRule(name)
+}
+
+// attributeType returns the attribute type of the attribute, whose options
+// (i.e. ";binary") are ignored.
+func (s *Schema) attributeType(name string) (AttributeType, bool) {
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	at, ok := s.attributeTypes[strings.ToLower(name)]
+	return at, ok
+}
+
+// validateEntry returns a *ChangeError with a result code of
+// ResultObjectClassViolation or ResultConstraintViolation when the entry's
+// attributes violate the schema.
+func (s *Schema) validateEntry(attrs []*EntryAttribute) error {
+	violation := func(code int, format string, a ...interface{}) error {
+		return &ChangeError{ResultCode: code, Msg: fmt.Sprintf(format, a...)}
+	}
+	objectClasses := findAttribute(attrs, "objectClass")
+	if objectClasses == nil || len(objectClasses.Values) == 0 {
+		return violation(ResultObjectClassViolation, "missing objectClass")
+	}
+
+	s.mu.RLock()
+	allowed := map[string]struct{}{"objectclass": {}}
+	var must []string
+	var extensible bool
+	for _, name := range objectClasses.Values {
+		oc, ok := s.objectClasses[strings.ToLower(name)]
+		if !ok {
+			s.mu.RUnlock()
+			return violation(ResultObjectClassViolation, "unknown objectClass %q", name)
+		}
+		if strings.EqualFold(oc.Name, "extensibleObject") {
+			extensible = true
+		}
+		must = append(must, oc.Must...)
+		for _, a := range append(oc.Must, oc.May...) {
+			allowed[strings.ToLower(a)] = struct{}{}
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, a := range must {
+		if attr := findAttribute(attrs, a); attr == nil || len(attr.Values) == 0 {
+			return violation(ResultObjectClassViolation, "missing required attribute %q", a)
+		}
+	}
+	for _, a := range attrs {
+		name := strings.ToLower(a.Name)
+		if i := strings.IndexByte(name, ';'); i >= 0 {
+			name = name[:i]
+		}
+		if _, ok := allowed[name]; !ok && !extensible {
+			return violation(ResultObjectClassViolation, "attribute %q not allowed", a.Name)
+		}
+		if at, ok := s.attributeType(a.Name); ok && at.SingleValue && len(a.Values) > 1 {
+			return violation(ResultConstraintViolation, "attribute %q is single-valued", a.Name)
+		}
+	}
+	return nil
+}
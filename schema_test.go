@@ -0,0 +1,186 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSchema(t *testing.T) *Schema {
+	t.Helper()
+	require := require.New(t)
+	s := NewSchema()
+	require.NoError(s.AddObjectClass(ObjectClass{Name: "person", Must: []string{"cn", "sn"}, May: []string{"userPassword", "description"}}))
+	require.NoError(s.AddObjectClass(ObjectClass{Name: "inetOrgPerson", May: []string{"mail", "uid", "employeeNumber"}}))
+	require.NoError(s.AddAttributeType(AttributeType{Name: "employeeNumber", SingleValue: true}))
+	require.NoError(s.AddAttributeType(AttributeType{Name: "uid", Equality: CaseExactMatch}))
+	require.NoError(s.AddAttributeType(AttributeType{Name: "entryUUID", NoUserModification: true}))
+	return s
+}
+
+func TestSchema_Add(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	s := testSchema(t)
+	assert.ErrorIs(s.AddObjectClass(ObjectClass{}), ErrInvalidParameter)
+	assert.ErrorIs(s.AddObjectClass(ObjectClass{Name: "Person"}), ErrInvalidParameter)
+	assert.ErrorIs(s.AddAttributeType(AttributeType{}), ErrInvalidParameter)
+	assert.ErrorIs(s.AddAttributeType(AttributeType{Name: "UID"}), ErrInvalidParameter)
+
+	assert.Equal(CaseExactMatch, s.MatchingRule("uid"))
+	assert.Equal(CaseExactMatch, s.MatchingRule("uid;lang-en"))
+	assert.Equal(CaseIgnoreMatch, s.MatchingRule("cn"))
+	assert.Equal(OctetStringMatch, s.MatchingRule("userPassword"))
+}
+
+func TestApplyModify(t *testing.T) {
+	t.Parallel()
+	newEntry := func() *Entry {
+		return NewEntry("cn=alice,ou=people,dc=example,dc=org", map[string][]string{
+			"objectClass": {"top", "person", "inetOrgPerson"},
+			"cn":          {"alice"},
+			"sn":          {"smith"},
+			"uid":         {"alice"},
+		})
+	}
+	change := func(op int64, name string, vals ...string) Change {
+		return Change{Operation: op, Modification: PartialAttribute{Type: name, Vals: vals}}
+	}
+	// modify messages are parsed from requests, so the tests do the same
+	modifyMessage := func(t *testing.T, changes ...Change) *ModifyMessage {
+		t.Helper()
+		require := require.New(t)
+		p := testModifyRequestPacket(t, ModifyMessage{
+			baseMessage: baseMessage{id: 1},
+			DN:          "cn=alice,ou=people,dc=example,dc=org",
+			Changes:     changes,
+		})
+		r, err := newRequest(1, &conn{connID: 1}, p)
+		require.NoError(err)
+		m, err := r.GetModifyMessage()
+		require.NoError(err)
+		return m
+	}
+	tests := []struct {
+		name     string
+		changes  []Change
+		noSchema bool
+		want     map[string][]string
+		wantCode int
+	}{
+		{
+			name:    "success",
+			changes: []Change{change(AddAttribute, "mail", "alice@example.org"), change(ReplaceAttribute, "description", "eng")},
+			want: map[string][]string{
+				"objectClass": {"top", "person", "inetOrgPerson"},
+				"cn":          {"alice"},
+				"sn":          {"smith"},
+				"uid":         {"alice"},
+				"mail":        {"alice@example.org"},
+				"description": {"eng"},
+			},
+		},
+		{
+			name:    "schema-matching-rule",
+			changes: []Change{change(AddAttribute, "uid", "Alice")},
+			want: map[string][]string{
+				"objectClass": {"top", "person", "inetOrgPerson"},
+				"cn":          {"alice"},
+				"sn":          {"smith"},
+				"uid":         {"alice", "Alice"},
+			},
+		},
+		{
+			name:     "no-schema-matching-rule",
+			changes:  []Change{change(AddAttribute, "uid", "Alice")},
+			noSchema: true,
+			wantCode: ResultAttributeOrValueExists,
+		},
+		{
+			name:     "no-such-attribute",
+			changes:  []Change{change(DeleteAttribute, "mail")},
+			wantCode: ResultNoSuchAttribute,
+		},
+		{
+			name:     "missing-required",
+			changes:  []Change{change(DeleteAttribute, "sn")},
+			wantCode: ResultObjectClassViolation,
+		},
+		{
+			name:    "missing-required-without-schema",
+			changes: []Change{change(DeleteAttribute, "sn")},
+			want: map[string][]string{
+				"objectClass": {"top", "person", "inetOrgPerson"},
+				"cn":          {"alice"},
+				"uid":         {"alice"},
+			},
+			noSchema: true,
+		},
+		{
+			name:     "not-allowed",
+			changes:  []Change{change(AddAttribute, "title", "eng")},
+			wantCode: ResultObjectClassViolation,
+		},
+		{
+			name:    "extensible-object",
+			changes: []Change{change(AddAttribute, "objectClass", "extensibleObject"), change(AddAttribute, "title", "eng")},
+			want: map[string][]string{
+				"objectClass": {"top", "person", "inetOrgPerson", "extensibleObject"},
+				"cn":          {"alice"},
+				"sn":          {"smith"},
+				"uid":         {"alice"},
+				"title":       {"eng"},
+			},
+		},
+		{
+			name:     "unknown-object-class",
+			changes:  []Change{change(AddAttribute, "objectClass", "account")},
+			wantCode: ResultObjectClassViolation,
+		},
+		{
+			name:     "single-value",
+			changes:  []Change{change(AddAttribute, "employeeNumber", "1", "2")},
+			wantCode: ResultConstraintViolation,
+		},
+		{
+			name:     "no-user-modification",
+			changes:  []Change{change(AddAttribute, "objectClass", "extensibleObject"), change(ReplaceAttribute, "entryUUID", "1")},
+			wantCode: ResultConstraintViolation,
+		},
+		{
+			name:     "rdn",
+			changes:  []Change{change(ReplaceAttribute, "cn", "bob")},
+			noSchema: true,
+			wantCode: ResultNotAllowedOnRDN,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			var schema *Schema
+			if !tc.noSchema {
+				schema = testSchema(t)
+			}
+			e := newEntry()
+			err := ApplyModify(e, modifyMessage(t, tc.changes...), schema)
+			if tc.wantCode != 0 {
+				var changeErr *ChangeError
+				require.ErrorAs(err, &changeErr)
+				assert.Equal(tc.wantCode, changeErr.ResultCode)
+				assert.Equal(newEntry(), e)
+				return
+			}
+			require.NoError(err)
+			assert.Empty(DiffEntries(e, NewEntry(e.DN, tc.want)))
+		})
+	}
+
+	assert.ErrorIs(t, ApplyModify(nil, &ModifyMessage{}, nil), ErrInvalidParameter)
+	assert.ErrorIs(t, ApplyModify(newEntry(), nil, nil), ErrInvalidParameter)
+}
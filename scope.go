@@ -0,0 +1,90 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "fmt"
+
+// DNTree is the minimal interface of a custom store's tree of entries, which
+// allows the store's entries to be walked with search scope semantics (see:
+// WalkScope)
+type DNTree interface {
+	// Exists returns true if the entry with the dn exists.  The null DN ("")
+	// is the root of the tree.
+	Exists(dn string) (bool, error)
+
+	// Children returns the DNs of the immediate subordinates of the entry
+	// with the dn.
+	Children(dn string) ([]string, error)
+}
+
+// WalkScope walks the DNs of the tree's entries that are within the scope of
+// the baseDN, calling visit for each DN until visit returns false.  Entries
+// are visited in depth first order with each entry visited before its
+// subordinates.  The baseDN itself isn't visited by a SingleLevel or
+// SubordinateSubtree scope, nor by a WholeSubtree scope when it's the null DN
+// (i.e. the root DSE).  An error that wraps ErrNotFound is returned when the
+// baseDN doesn't exist, which should be responded to with ResultNoSuchObject.
+func WalkScope(tree DNTree, baseDN string, scope Scope, visit func(dn string) bool) error {
+	const op = "gldap.WalkScope"
+	switch {
+	case tree == nil:
+		return fmt.Errorf("%s: missing tree: %w", op, ErrInvalidParameter)
+	case visit == nil:
+		return fmt.Errorf("%s: missing visit func: %w", op, ErrInvalidParameter)
+	}
+	switch scope {
+	case BaseObject, SingleLevel, WholeSubtree, SubordinateSubtree:
+	default:
+		return fmt.Errorf("%s: invalid scope %d: %w", op, scope, ErrInvalidParameter)
+	}
+	exists, err := tree.Exists(baseDN)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	case !exists:
+		return fmt.Errorf("%s: base dn %q: %w", op, baseDN, ErrNotFound)
+	}
+
+	visitBase := scope == BaseObject || (scope == WholeSubtree && baseDN != "")
+	if visitBase && !visit(baseDN) {
+		return nil
+	}
+	if scope == BaseObject {
+		return nil
+	}
+	children, err := tree.Children(baseDN)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if scope == SingleLevel {
+		for _, dn := range children {
+			if !visit(dn) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	// the stack holds the DNs still to be visited, with the next DN to visit
+	// on the top of the stack.
+	stack := make([]string, 0, len(children))
+	for i := len(children) - 1; i >= 0; i-- {
+		stack = append(stack, children[i])
+	}
+	for len(stack) > 0 {
+		dn := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !visit(dn) {
+			return nil
+		}
+		children, err := tree.Children(dn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, children[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDNTree is a DNTree of the DNs and their children
+type testDNTree map[string][]string
+
+func (t testDNTree) Exists(dn string) (bool, error) {
+	_, ok := t[dn]
+	return ok, nil
+}
+
+func (t testDNTree) Children(dn string) ([]string, error) {
+	if dn == "error" {
+		return nil, errors.New("children error")
+	}
+	return t[dn], nil
+}
+
+func TestWalkScope(t *testing.T) {
+	t.Parallel()
+	tree := testDNTree{
+		"":                  {"dc=example,dc=org"},
+		"dc=example,dc=org": {"ou=people,dc=example,dc=org", "ou=groups,dc=example,dc=org"},
+		"ou=people,dc=example,dc=org": {
+			"cn=alice,ou=people,dc=example,dc=org",
+			"cn=bob,ou=people,dc=example,dc=org",
+		},
+		"cn=alice,ou=people,dc=example,dc=org":  nil,
+		"cn=bob,ou=people,dc=example,dc=org":    nil,
+		"ou=groups,dc=example,dc=org":           {"cn=admins,ou=groups,dc=example,dc=org"},
+		"cn=admins,ou=groups,dc=example,dc=org": nil,
+		"error":                                 nil,
+	}
+	tests := []struct {
+		name      string
+		tree      DNTree
+		baseDN    string
+		scope     Scope
+		visit     func(dn string) bool
+		limit     int
+		want      []string
+		wantErrIs error
+	}{
+		{
+			name:   "base",
+			baseDN: "ou=people,dc=example,dc=org",
+			scope:  BaseObject,
+			want:   []string{"ou=people,dc=example,dc=org"},
+		},
+		{
+			name:   "one",
+			baseDN: "dc=example,dc=org",
+			scope:  SingleLevel,
+			want:   []string{"ou=people,dc=example,dc=org", "ou=groups,dc=example,dc=org"},
+		},
+		{
+			name:   "sub",
+			baseDN: "dc=example,dc=org",
+			scope:  WholeSubtree,
+			want: []string{
+				"dc=example,dc=org",
+				"ou=people,dc=example,dc=org",
+				"cn=alice,ou=people,dc=example,dc=org",
+				"cn=bob,ou=people,dc=example,dc=org",
+				"ou=groups,dc=example,dc=org",
+				"cn=admins,ou=groups,dc=example,dc=org",
+			},
+		},
+		{
+			name:   "children",
+			baseDN: "ou=people,dc=example,dc=org",
+			scope:  SubordinateSubtree,
+			want:   []string{"cn=alice,ou=people,dc=example,dc=org", "cn=bob,ou=people,dc=example,dc=org"},
+		},
+		{
+			name:   "sub-root-dse",
+			baseDN: "",
+			scope:  WholeSubtree,
+			limit:  2,
+			want:   []string{"dc=example,dc=org", "ou=people,dc=example,dc=org"},
+		},
+		{
+			name:   "stop",
+			baseDN: "dc=example,dc=org",
+			scope:  SingleLevel,
+			limit:  1,
+			want:   []string{"ou=people,dc=example,dc=org"},
+		},
+		{
+			name:   "leaf",
+			baseDN: "cn=alice,ou=people,dc=example,dc=org",
+			scope:  SingleLevel,
+		},
+		{
+			name:      "no-such-base",
+			baseDN:    "ou=devices,dc=example,dc=org",
+			scope:     WholeSubtree,
+			wantErrIs: ErrNotFound,
+		},
+		{
+			name:      "invalid-scope",
+			baseDN:    "dc=example,dc=org",
+			scope:     Scope(4),
+			wantErrIs: ErrInvalidParameter,
+		},
+		{
+			name:      "missing-tree",
+			tree:      testDNTree(nil),
+			baseDN:    "dc=example,dc=org",
+			scope:     BaseObject,
+			wantErrIs: ErrNotFound,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			walkTree := tc.tree
+			if walkTree == nil {
+				walkTree = tree
+			}
+			var got []string
+			err := WalkScope(walkTree, tc.baseDN, tc.scope, func(dn string) bool {
+				got = append(got, dn)
+				return tc.limit == 0 || len(got) < tc.limit
+			})
+			if tc.wantErrIs != nil {
+				require.Error(err)
+				assert.ErrorIs(err, tc.wantErrIs)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+	t.Run("children-error", func(t *testing.T) {
+		err := WalkScope(tree, "error", SingleLevel, func(string) bool { return true })
+		assert.ErrorContains(t, err, "children error")
+	})
+	t.Run("missing-params", func(t *testing.T) {
+		assert.ErrorIs(t, WalkScope(nil, "", BaseObject, func(string) bool { return true }), ErrInvalidParameter)
+		assert.ErrorIs(t, WalkScope(tree, "", BaseObject, nil), ErrInvalidParameter)
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "strings"
+
+// Special attribute selectors of a search request's attribute list
+const (
+	// AllUserAttributes requests all the user attributes (see:
+	// https://tools.ietf.org/html/rfc4511#section-4.5.1.8)
+	AllUserAttributes = "*"
+	// AllOperationalAttributes requests all the operational attributes (see:
+	// https://tools.ietf.org/html/rfc3673)
+	AllOperationalAttributes = "+"
+	// NoAttributes requests that no attributes are returned (see:
+	// https://tools.ietf.org/html/rfc4511#section-4.5.1.8)
+	NoAttributes = "1.1"
+)
+
+// SearchAttributes is the decoded attribute list of a search request, which
+// distinguishes the special selectors ("*", "+" and "1.1") from the
+// attributes requested by name.
+type SearchAttributes struct {
+	all         bool
+	operational bool
+	none        bool
+	names       []string
+}
+
+// NewSearchAttributes decodes a search request's attribute list.  An empty
+// list requests all the user attributes and "1.1" is ignored when it's not the
+// only attribute in the list, as required by RFC 4511.
+func NewSearchAttributes(attributes []string) SearchAttributes {
+	var a SearchAttributes
+	if len(attributes) == 0 {
+		a.all = true
+		return a
+	}
+	for _, attr := range attributes {
+		switch attr {
+		case AllUserAttributes:
+			a.all = true
+		case AllOperationalAttributes:
+			a.operational = true
+		case NoAttributes:
+		default:
+			a.names = append(a.names, attr)
+		}
+	}
+	a.none = !a.all && !a.operational && len(a.names) == 0
+	return a
+}
+
+// WantsAll returns true if all the user attributes were requested, either by
+// "*" or an empty attribute list.
+func (a SearchAttributes) WantsAll() bool { return a.all }
+
+// WantsOperational returns true if all the operational attributes were
+// requested by "+".
+func (a SearchAttributes) WantsOperational() bool { return a.operational }
+
+// WantsNone returns true if no attributes were requested by "1.1".
+func (a SearchAttributes) WantsNone() bool { return a.none }
+
+// Names returns the attributes requested by name, excluding the special
+// selectors.
+func (a SearchAttributes) Names() []string {
+	return append([]string(nil), a.names...)
+}
+
+// Requested returns true if the attribute was requested by name.  Names are
+// compared case-insensitively.
+func (a SearchAttributes) Requested(name string) bool {
+	for _, n := range a.names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants returns true if the user attribute should be returned, since it was
+// requested by name or all the user attributes were requested.  Operational
+// attributes should be checked with WantsOperational and Requested instead.
+func (a SearchAttributes) Wants(name string) bool {
+	return a.all || a.Requested(name)
+}
+
+// RequestedAttributes returns the search's decoded attribute list.
+func (m *SearchMessage) RequestedAttributes() SearchAttributes {
+	return NewSearchAttributes(m.Attributes)
+}
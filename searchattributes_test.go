@@ -0,0 +1,60 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSearchAttributes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		attributes      []string
+		wantAll         bool
+		wantOperational bool
+		wantNone        bool
+		wantNames       []string
+	}{
+		{name: "empty", wantAll: true},
+		{name: "all-user", attributes: []string{"*"}, wantAll: true},
+		{name: "all-operational", attributes: []string{"+"}, wantOperational: true},
+		{name: "all", attributes: []string{"*", "+"}, wantAll: true, wantOperational: true},
+		{name: "none", attributes: []string{"1.1"}, wantNone: true},
+		{name: "none-with-names", attributes: []string{"1.1", "cn"}, wantNames: []string{"cn"}},
+		{name: "names", attributes: []string{"cn", "mail"}, wantNames: []string{"cn", "mail"}},
+		{name: "names-with-operational", attributes: []string{"cn", "+"}, wantOperational: true, wantNames: []string{"cn"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			got := NewSearchAttributes(tc.attributes)
+			assert.Equal(tc.wantAll, got.WantsAll())
+			assert.Equal(tc.wantOperational, got.WantsOperational())
+			assert.Equal(tc.wantNone, got.WantsNone())
+			assert.Equal(tc.wantNames, got.Names())
+		})
+	}
+}
+
+func TestSearchAttributes_Wants(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	m := &SearchMessage{Attributes: []string{"CN", "+"}}
+	a := m.RequestedAttributes()
+	assert.True(a.Requested("cn"))
+	assert.True(a.Wants("cn"))
+	assert.False(a.Wants("mail"))
+
+	a = NewSearchAttributes(nil)
+	assert.False(a.Requested("mail"))
+	assert.True(a.Wants("mail"))
+
+	a = NewSearchAttributes([]string{"1.1"})
+	assert.False(a.Wants("mail"))
+}
@@ -0,0 +1,143 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultSelfWritableAttributes are the attributes of their own entry that a
+// bound user may modify under the SelfWritePolicy middleware by default.
+var DefaultSelfWritableAttributes = []string{"userPassword", "displayName", "mobile"}
+
+type selfWriteOptions struct {
+	withAttributes []string
+	withAdminDNs   []string
+}
+
+func selfWriteDefaults() selfWriteOptions {
+	return selfWriteOptions{
+		withAttributes: DefaultSelfWritableAttributes,
+	}
+}
+
+func getSelfWriteOpts(opt ...Option) selfWriteOptions {
+	opts := selfWriteDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithSelfWritableAttributes sets the attributes of their own entry that a
+// bound user may modify under the SelfWritePolicy middleware, which replace
+// the DefaultSelfWritableAttributes.  Attribute names are compared
+// case-insensitively.
+func WithSelfWritableAttributes(name ...string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*selfWriteOptions); ok {
+			o.withAttributes = name
+		}
+	}
+}
+
+// WithSelfWriteAdminDNs sets the DNs of the admins who are exempt from the
+// SelfWritePolicy middleware, so a conn bound as one of them may modify any
+// attribute of any entry.
+func WithSelfWriteAdminDNs(dn ...string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*selfWriteOptions); ok {
+			o.withAdminDNs = dn
+		}
+	}
+}
+
+// SelfWritePolicy returns a Middleware which only allows a bound user to
+// modify the self-writable attributes (DefaultSelfWritableAttributes, unless
+// WithSelfWritableAttributes is used) of their own entry, which emulates the
+// self-service password and profile flows of real directories.  Every other
+// modify request, and every password modify extended operation for another
+// user (or when userPassword isn't self-writable), is rejected with
+// ResultInsufficientAccessRights unless the conn is bound as one of the admin
+// DNs (see: WithSelfWriteAdminDNs).  Other requests are passed to the next
+// handler unchanged.
+//
+// Options supported: WithSelfWritableAttributes, WithSelfWriteAdminDNs
+func SelfWritePolicy(opt ...Option) Middleware {
+	opts := getSelfWriteOpts(opt...)
+	writable := make(map[string]bool, len(opts.withAttributes))
+	for _, a := range opts.withAttributes {
+		writable[strings.ToLower(a)] = true
+	}
+	adminDNs := append([]string{}, opts.withAdminDNs...)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			isPasswordModify := r.routeOp == ExtendedRouteOperation && r.extendedName == ExtendedOperationPasswordModify
+			if (r.routeOp != ModifyRouteOperation && !isPasswordModify) || isAdminSession(r, adminDNs) {
+				next(w, r)
+				return
+			}
+			boundDN := r.BoundDN()
+			if boundDN == "" {
+				_ = w.Write(r.resultResponse(ResultInsufficientAccessRights, "authentication required"))
+				return
+			}
+			var diag string
+			if isPasswordModify {
+				diag = selfPasswordModifyDenied(r, boundDN, writable)
+			} else {
+				diag = selfModifyDenied(r, boundDN, writable)
+			}
+			if diag != "" {
+				_ = w.Write(r.resultResponse(ResultInsufficientAccessRights, diag))
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// selfModifyDenied returns the diagnostic message of a modify request the
+// bound DN isn't allowed to make, or an empty string when it's allowed.
+func selfModifyDenied(r *Request, boundDN string, writable map[string]bool) string {
+	m, err := r.GetModifyMessage()
+	if err != nil {
+		return "not a modify request"
+	}
+	if !isSelf(boundDN, m.DN) {
+		return "only your own entry can be modified"
+	}
+	for _, c := range m.Changes {
+		if !writable[strings.ToLower(c.Modification.Type)] {
+			return fmt.Sprintf("attribute %q can't be modified", c.Modification.Type)
+		}
+	}
+	return ""
+}
+
+// selfPasswordModifyDenied returns the diagnostic message of a password modify
+// extended operation the bound DN isn't allowed to make, or an empty string
+// when it's allowed.  A request without a user identity modifies the
+// password of the conn's user.
+func selfPasswordModifyDenied(r *Request, boundDN string, writable map[string]bool) string {
+	if !writable["userpassword"] {
+		return `attribute "userPassword" can't be modified`
+	}
+	m, err := r.GetPasswordModifyMessage()
+	if err != nil {
+		return "not a password modify request"
+	}
+	if m.UserIdentity == "" {
+		return ""
+	}
+	if !isSelf(boundDN, strings.TrimPrefix(m.UserIdentity, "dn:")) {
+		return "only your own password can be modified"
+	}
+	return ""
+}
+
+// isSelf returns true if the DNs are the same entry
+func isSelf(boundDN, dn string) bool {
+	ok, err := dnInScope(dn, boundDN, BaseObject)
+	return err == nil && ok
+}
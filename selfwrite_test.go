@@ -0,0 +1,154 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfWritePolicy(t *testing.T) {
+	t.Parallel()
+	const (
+		aliceDN = "cn=alice,dc=example,dc=org"
+		bobDN   = "cn=bob,dc=example,dc=org"
+		adminDN = "cn=admin,dc=example,dc=org"
+	)
+	mux, err := NewMux()
+	require.NoError(t, err)
+	mux.Use(SelfWritePolicy(WithSelfWriteAdminDNs(adminDN)))
+	success := func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.resultResponse(ResultSuccess, ""))
+	}
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Modify(success))
+	require.NoError(t, mux.ExtendedOperation(success, ExtendedOperationPasswordModify))
+	require.NoError(t, mux.Delete(success))
+	_, url := testServer(t, mux)
+
+	modify := func(dn string, attrs ...string) *ldap.ModifyRequest {
+		m := ldap.NewModifyRequest(dn, nil)
+		for _, a := range attrs {
+			m.Replace(a, []string{"value"})
+		}
+		return m
+	}
+	tests := []struct {
+		name     string
+		bindDN   string
+		req      func(*ldap.Conn) error
+		wantDiag string
+	}{
+		{
+			name:   "self-allowed",
+			bindDN: aliceDN,
+			req:    func(c *ldap.Conn) error { return c.Modify(modify(aliceDN, "userPassword", "DisplayName", "mobile")) },
+		},
+		{
+			name:   "self-case-insensitive-dn",
+			bindDN: aliceDN,
+			req:    func(c *ldap.Conn) error { return c.Modify(modify("CN=Alice, DC=example, DC=org", "mobile")) },
+		},
+		{
+			name:     "self-not-writable",
+			bindDN:   aliceDN,
+			req:      func(c *ldap.Conn) error { return c.Modify(modify(aliceDN, "mobile", "employeeNumber")) },
+			wantDiag: `attribute "employeeNumber" can't be modified`,
+		},
+		{
+			name:     "other-entry",
+			bindDN:   aliceDN,
+			req:      func(c *ldap.Conn) error { return c.Modify(modify(bobDN, "mobile")) },
+			wantDiag: "only your own entry can be modified",
+		},
+		{
+			name:     "anonymous",
+			req:      func(c *ldap.Conn) error { return c.Modify(modify(aliceDN, "mobile")) },
+			wantDiag: "authentication required",
+		},
+		{
+			name:   "admin",
+			bindDN: adminDN,
+			req:    func(c *ldap.Conn) error { return c.Modify(modify(bobDN, "employeeNumber")) },
+		},
+		{
+			name:   "password-modify-self",
+			bindDN: aliceDN,
+			req: func(c *ldap.Conn) error {
+				_, err := c.PasswordModify(ldap.NewPasswordModifyRequest("", "old", "new"))
+				return err
+			},
+		},
+		{
+			name:   "password-modify-self-identity",
+			bindDN: aliceDN,
+			req: func(c *ldap.Conn) error {
+				_, err := c.PasswordModify(ldap.NewPasswordModifyRequest("dn:"+aliceDN, "old", "new"))
+				return err
+			},
+		},
+		{
+			name:   "password-modify-other",
+			bindDN: aliceDN,
+			req: func(c *ldap.Conn) error {
+				_, err := c.PasswordModify(ldap.NewPasswordModifyRequest(bobDN, "old", "new"))
+				return err
+			},
+			wantDiag: "only your own password can be modified",
+		},
+		{
+			name:   "other-operation",
+			bindDN: aliceDN,
+			req:    func(c *ldap.Conn) error { return c.Del(ldap.NewDelRequest(bobDN, nil)) },
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			client, err := ldap.DialURL(url)
+			require.NoError(err)
+			defer client.Close()
+			if tc.bindDN != "" {
+				require.NoError(client.Bind(tc.bindDN, "password"))
+			}
+			err = tc.req(client)
+			if tc.wantDiag == "" {
+				require.NoError(err)
+				return
+			}
+			require.Error(err)
+			var ldapErr *ldap.Error
+			require.ErrorAs(err, &ldapErr)
+			assert.Equal(uint16(ResultInsufficientAccessRights), ldapErr.ResultCode)
+			assert.Contains(ldapErr.Err.Error(), tc.wantDiag)
+		})
+	}
+
+	t.Run("password-not-writable", func(t *testing.T) {
+		t.Parallel()
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		mux.Use(SelfWritePolicy(WithSelfWritableAttributes("mobile")))
+		require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}))
+		require.NoError(mux.ExtendedOperation(success, ExtendedOperationPasswordModify))
+		_, url := testServer(t, mux)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(client.Bind(aliceDN, "password"))
+		_, err = client.PasswordModify(ldap.NewPasswordModifyRequest("", "old", "new"))
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, ResultInsufficientAccessRights))
+	})
+}
@@ -6,122 +6,426 @@ package gldap
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+)
+
+// rejectConnTimeout is the write timeout of the notice of disconnection sent to
+// a rejected connection when the server has no write timeout (see:
+// WithRejectExcessConnections)
+const rejectConnTimeout = 5 * time.Second
 
-	"github.com/hashicorp/go-hclog"
+// minAcceptDelay and maxAcceptDelay bound the backoff of the accept loop after
+// temporary accept errors (see: Server.Serve)
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
 )
 
+// maxConnLifetimeDiag is the diagnostic message of the notice of disconnection
+// sent to an expired connection (see: WithMaxConnectionLifetime)
+const maxConnLifetimeDiag = "connection lifetime exceeded"
+
 // Server is an ldap server that you can add a mux (multiplexer) router to and
 // then run it to accept and process requests.
 type Server struct {
 	mu             sync.RWMutex
-	logger         hclog.Logger
+	logger         Logger
 	connWg         sync.WaitGroup
-	listener       net.Listener
+	listeners      []net.Listener
 	listenerReady  bool
-	router         *Mux
-	tlsConfig      *tls.Config
+	router         atomic.Pointer[Mux]
 	readTimeout    time.Duration
 	writeTimeout   time.Duration
+	idleTimeout    time.Duration
 	onCloseHandler OnCloseHandler
+	onConnect      OnConnectHandler
+	diagnosticHook DiagnosticMessageHook
+	stats          *serverStats
+	monitor        bool
+	changelog      *Changelog
+	changeNotifier *ChangeNotifier
+	autoWhoAmI     bool
+	startTLSConfig *tls.Config
+	keepAlive      time.Duration
+	writeThrough   *WriteThrough
+	readOnly       bool
+	readOnlyDiag   string
+	requireTLS     bool
+	requireTLSOps  []RouteOperation
+	maintenance    *maintenanceMode
+
+	connIDGenerator ConnectionIDGenerator
+
+	// connSlots holds a token for every open connection when the server has a
+	// connection limit (see: WithMaxConnections)
+	connSlots         chan struct{}
+	rejectExcessConns bool
+
+	connPolicy     ConnectionPolicy
+	maxRequestSize int
+	journal        *journal
+	clock          Clock
+	eventSink      EventSink
+	accessLog      AccessLogSink
+	metricsHook    MetricsHook
+	tracer         Tracer
+	criticalCtrl   CriticalControlPolicy
+	supportedCtrls []string
+	auditor        Auditor
+
+	// slowOpThreshold and slowOpFn report the operations that take longer
+	// than the threshold (see: WithSlowOperationThreshold)
+	slowOpThreshold time.Duration
+	slowOpFn        SlowOperationFunc
+
+	maxConnLifetime time.Duration
+
+	connsMu    sync.Mutex
+	conns      map[int]*conn // open connections by ID
+	lastConnID int           // ID of the last accepted connection
 
 	disablePanicRecovery bool
 	shutdownCancel       context.CancelFunc
 	shutdownCtx          context.Context
+
+	leakedStates atomic.Int64 // connection state values set once their connection was closed
+
+	shutdownReportMu sync.Mutex
+	shutdownReport   *ShutdownReport // of the last Stop or Shutdown
 }
 
 // NewServer creates a new ldap server
 //
 // Options supported:
-// - WithLogger allows you pass a logger with whatever hclog.Level you wish including hclog.Off to turn off all logging
-// - WithReadTimeout will set a read time out per connection
-// - WithWriteTimeout will set a write time out per connection
+// - WithLogger allows you pass a logger (i.e. a *slog.Logger with NewSlogLogger, or an hclog.Logger with the hclogger package)
+// - WithReadTimeout will set the time allowed to read a request
+// - WithWriteTimeout will set the time allowed to write a response
+// - WithIdleTimeout will close connections which haven't sent a request for the duration
+// - WithMaxConnectionLifetime will gracefully terminate connections which have been open for the duration
 // - WithOnClose will define a callback the server will call every time a connection is closed
-func NewServer(opt ...Option) (*Server, error) {
+// - WithOnConnect will define a callback the server will call every time a connection is accepted, which can reject it
+// - WithMonitor will enable the cn=Monitor backend
+// - WithChangelog will enable the cn=changelog backend
+// - WithChangeNotifier will publish successful mutations to the notifier's subscribers
+// - WithAutoWhoAmI will enable the server's "Who am I?" extended operation handler
+// - WithStartTLS will enable the server's StartTLS extended operation handler
+// - WithKeepAlive will set the period of the TCP keepalive probes of idle connections
+// - WithWriteThrough will forward successful mutations to an upstream server
+// - WithReadOnly will reject every update request
+// - WithReadOnlyDiagnosticMessage will set the diagnostic message of rejected update requests
+// - WithRequireTLS will reject simple binds with a password, and other operations, until a connection is protected by TLS
+// - WithClock will set the clock of the monitor backend's timestamps
+// - WithConnectionIDGenerator will set the generator of connection IDs
+// - WithMaxConnections will limit the number of open connections
+// - WithRejectExcessConnections will reject connections beyond the limit rather than queue them
+// - WithConnectionPolicy will set the policy which decides whether accepted connections are served
+// - WithMaxRequestSize will limit the size of a request's packet
+// - WithDiagnosticMessageHook will transform the diagnostic messages of responses before they're written
+// - WithJournal will retain the decoded summaries of the last requests and their responses
+// - WithEventSink will set a sink which receives the server's connection, bind and modification events
+// - WithAccessLog will enable the access log, which sends a record of every operation to a sink
+// - WithMetricsHook will set a hook which receives the server's connection, operation and byte measurements
+// - WithTracer will set a tracer which starts the spans of connections and their operations
+// - WithCriticalControlPolicy will set the routes' policy for requests with unknown critical controls
+// - WithSlowOperationThreshold will log a warning for every operation that takes longer than the threshold
+// - WithSlowOperationFunc will set a func which is called with the record of every slow operation
+// - WithAuditor will set an auditor which receives the server's typed connection, bind, search and modification events
+func NewServer(opt ...ServerOption) (*Server, error) {
+	const op = "gldap.NewServer"
+	if err := unsupportedOptions(op, "a server", &configOptions{}, opt...); err != nil {
+		return nil, err
+	}
 	cancelCtx, cancel := context.WithCancel(context.Background())
 	opts := getConfigOpts(opt...)
 
 	if opts.withLogger == nil {
-		opts.withLogger = hclog.New(&hclog.LoggerOptions{
-			Name:  "Server-logger",
-			Level: hclog.Error,
-		})
+		opts.withLogger = defaultLogger()
 	}
 
-	return &Server{
-		router:               &Mux{}, // TODO: a better default router
+	s := &Server{
 		logger:               opts.withLogger,
 		shutdownCancel:       cancel,
 		shutdownCtx:          cancelCtx,
 		writeTimeout:         opts.withWriteTimeout,
 		readTimeout:          opts.withReadTimeout,
+		idleTimeout:          opts.withIdleTimeout,
 		disablePanicRecovery: opts.withDisablePanicRecovery,
 		onCloseHandler:       opts.withOnClose,
-	}, nil
+		onConnect:            opts.withOnConnect,
+		diagnosticHook:       opts.withDiagnosticHook,
+		stats:                newServerStats(opts.withClock),
+		monitor:              opts.withMonitor,
+		changelog:            opts.withChangelog,
+		changeNotifier:       opts.withChangeNotifier,
+		autoWhoAmI:           opts.withAutoWhoAmI,
+		startTLSConfig:       opts.withStartTLS,
+		keepAlive:            opts.withKeepAlive,
+		writeThrough:         opts.withWriteThrough,
+		readOnly:             opts.withReadOnly,
+		readOnlyDiag:         opts.withReadOnlyDiagnostic,
+		requireTLS:           opts.withRequireTLS,
+		requireTLSOps:        opts.withRequireTLSOps,
+		maintenance:          &maintenanceMode{},
+		connIDGenerator:      opts.withConnectionIDGenerator,
+		conns:                map[int]*conn{},
+		rejectExcessConns:    opts.withRejectExcessConnections,
+		connPolicy:           opts.withConnectionPolicy,
+		maxRequestSize:       opts.withMaxRequestSize,
+		journal:              newJournal(opts.withJournal, opts.withClock),
+		clock:                opts.withClock,
+		eventSink:            opts.withEventSink,
+		accessLog:            opts.withAccessLog,
+		metricsHook:          opts.withMetricsHook,
+		tracer:               opts.withTracer,
+		criticalCtrl:         opts.withCriticalControlPolicy,
+		supportedCtrls:       opts.withSupportedControls,
+		slowOpThreshold:      opts.withSlowOpThreshold,
+		slowOpFn:             opts.withSlowOpFn,
+		auditor:              opts.withAuditor,
+		maxConnLifetime:      opts.withMaxConnLifetime,
+	}
+	if opts.withMaxConnections > 0 {
+		s.connSlots = make(chan struct{}, opts.withMaxConnections)
+	}
+	s.router.Store(&Mux{}) // TODO: a better default router
+	return s, nil
 }
 
-// Run will run the server which will listen and serve requests.
+// Run will run the server which will listen and serve requests.  Run can be
+// called concurrently with distinct addresses and options, so one server (and
+// its router) serves several listeners (i.e. ldap on port 389 with StartTLS and
+// ldaps on port 636):
+//
+//	go s.Run(":389")
+//	go s.Run(":636", gldap.WithTLSConfig(tlsConfig))
 //
-// Options supported: WithTLSConfig
-func (s *Server) Run(addr string, opt ...Option) error {
+// Options supported: WithTLSConfig, WithConnectionIDGenerator
+func (s *Server) Run(addr string, opt ...ServerOption) error {
 	const op = "gldap.(Server).Run"
-	opts := getConfigOpts(opt...)
-
-	var err error
-	s.mu.Lock()
-	s.listener, err = net.Listen("tcp", addr)
-	s.listenerReady = true
-	s.mu.Unlock()
+	if err := unsupportedOptions(op, "a server", &configOptions{}, opt...); err != nil {
+		s.mu.Lock()
+		s.listenerReady = true
+		s.mu.Unlock()
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
 	if err != nil {
+		s.mu.Lock()
+		s.listenerReady = true
+		s.mu.Unlock()
 		return fmt.Errorf("%s: unable to listen to addr %s: %w", op, addr, err)
 	}
+	if err := s.Serve(l, opt...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Serve will serve requests of the connections accepted by the listener,
+// which allows the server to use a listener it didn't create (i.e. one
+// inherited through systemd socket activation, or one bound to port 0 whose
+// address is discovered with its Addr).  The listener is closed when the
+// server is stopped.  Like Run, Serve can be called concurrently with other
+// listeners, whose connections share the server's connection IDs and
+// shutdown.
+//
+// Temporary accept errors (i.e. running out of file descriptors) don't stop
+// the server: they're logged and accepting is retried after a delay that
+// doubles with every consecutive error, up to a second.
+//
+// Options supported: WithTLSConfig, WithConnectionIDGenerator
+func (s *Server) Serve(l net.Listener, opt ...ServerOption) error {
+	const op = "gldap.(Server).Serve"
+	if l == nil {
+		return fmt.Errorf("%s: missing listener: %w", op, ErrInvalidParameter)
+	}
+	if err := unsupportedOptions(op, "a server", &configOptions{}, opt...); err != nil {
+		return err
+	}
+	opts := getConfigOpts(opt...)
+
 	if opts.withTLSConfig != nil {
 		s.logger.Debug("setting up TLS listener", "op", op)
-		s.tlsConfig = opts.withTLSConfig
-		s.mu.Lock()
-		s.listener = tls.NewListener(s.listener, s.tlsConfig)
+		l = tls.NewListener(l, opts.withTLSConfig)
+	}
+	s.mu.Lock()
+	if s.shutdownCtx.Err() != nil {
+		// the server was stopped before it could serve the listener
 		s.mu.Unlock()
+		_ = l.Close()
+		return nil
 	}
-	s.logger.Info("listening", "op", op, "addr", s.listener.Addr())
+	s.listeners = append(s.listeners, l)
+	s.listenerReady = true
+	s.mu.Unlock()
+	s.logger.Info("listening", "op", op, "addr", l.Addr())
 
-	connID := 0
+	nextConnID := s.nextConnID
+	switch {
+	case opts.withConnectionIDGenerator != nil:
+		nextConnID = opts.withConnectionIDGenerator
+	case s.connIDGenerator != nil:
+		nextConnID = s.connIDGenerator
+	}
+
+	var acceptDelay time.Duration
 	for {
-		connID++
 		select {
 		case <-s.shutdownCtx.Done():
 			return nil
 		default:
 			// need a default to fall through to rest of loop...
 		}
-		c, err := s.listener.Accept()
+		if s.connSlots != nil && !s.rejectExcessConns {
+			// wait for a slot before accepting, so excess connections wait
+			// in the listener's accept queue
+			select {
+			case s.connSlots <- struct{}{}:
+			case <-s.shutdownCtx.Done():
+				return nil
+			}
+		}
+		c, err := l.Accept()
 		if err != nil {
-			if strings.Contains(err.Error(), "use of closed network connection") {
+			if !s.rejectExcessConns {
+				s.releaseConnSlot()
+			}
+			if errors.Is(err, net.ErrClosed) || strings.Contains(err.Error(), "use of closed network connection") {
 				s.logger.Debug("accept on closed conn")
 				return nil
 			}
+			if isTemporaryAcceptError(err) {
+				// like net/http, back off and keep accepting rather than
+				// stopping the server on a transient error (i.e. EMFILE)
+				acceptDelay = nextAcceptDelay(acceptDelay)
+				s.logger.Error("error accepting conn, retrying", "op", op, "err", err, "delay", acceptDelay)
+				select {
+				case <-time.After(acceptDelay):
+				case <-s.shutdownCtx.Done():
+					return nil
+				}
+				continue
+			}
 			return fmt.Errorf("%s: error accepting conn: %w", op, err)
 		}
+		acceptDelay = 0
+		if s.connPolicy != nil {
+			if err := s.connPolicy(c.RemoteAddr()); err != nil {
+				s.logger.Debug("connection rejected by policy", "op", op, "remoteAddr", c.RemoteAddr(), "err", err)
+				if !s.rejectExcessConns {
+					s.releaseConnSlot()
+				}
+				_ = c.Close()
+				continue
+			}
+		}
+		if s.connSlots != nil && s.rejectExcessConns {
+			select {
+			case s.connSlots <- struct{}{}:
+			default:
+				s.logger.Warn("rejecting connection, too many open connections", "op", op, "remoteAddr", c.RemoteAddr())
+				go s.rejectConn(c)
+				continue
+			}
+		}
+		connID := nextConnID()
+		if connID <= 0 {
+			s.logger.Error("invalid connection ID", "op", op, "conn", connID)
+			s.releaseConnSlot()
+			_ = c.Close()
+			continue
+		}
 		s.logger.Debug("new connection accepted", "op", op, "conn", connID)
-		conn, err := newConn(s.shutdownCtx, connID, c, s.logger, s.router)
+		conn, err := newConn(s.shutdownCtx, connID, c, s.logger, s.router.Load())
 		if err != nil {
+			s.releaseConnSlot()
 			return fmt.Errorf("%s: unable to create in-memory conn: %w", op, err)
 		}
+		conn.routerFn = s.router.Load
+		conn.opened = s.clock.Now()
+		conn.stats = s.stats
+		conn.state.leaks = &s.leakedStates
+		conn.monitor = s.monitor
+		conn.changelog = s.changelog
+		conn.changeNotifier = s.changeNotifier
+		conn.writeThrough = s.writeThrough
+		conn.readOnly = s.readOnly
+		conn.readOnlyDiag = s.readOnlyDiag
+		conn.requireTLS = s.requireTLS
+		conn.requireTLSOps = s.requireTLSOps
+		conn.maintenance = s.maintenance
+		conn.autoWhoAmI = s.autoWhoAmI
+		conn.startTLSConfig = s.startTLSConfig
+		conn.diagnosticHook = s.diagnosticHook
+		conn.maxRequestSize = s.maxRequestSize
+		conn.journal = s.journal
+		conn.eventSink = s.eventSink
+		conn.accessLog = s.accessLog
+		conn.metricsHook = s.metricsHook
+		conn.tracer = s.tracer
+		conn.criticalCtrl = s.criticalCtrl
+		conn.supportedCtrls = s.supportedCtrls
+		conn.slowOpThreshold = s.slowOpThreshold
+		conn.slowOpFn = s.slowOpFn
+		conn.auditor = s.auditor
+		conn.clock = s.clock
+		conn.readTimeout = s.readTimeout
+		conn.writeTimeout = s.writeTimeout
+		conn.idleTimeout = s.idleTimeout
+		s.connsMu.Lock()
+		if _, ok := s.conns[connID]; ok {
+			s.connsMu.Unlock()
+			s.logger.Error("duplicate connection ID", "op", op, "conn", connID)
+			s.releaseConnSlot()
+			_ = c.Close()
+			continue
+		}
+		s.conns[connID] = conn
+		s.connsMu.Unlock()
+		s.stats.connOpened()
+		if s.metricsHook != nil {
+			s.metricsHook.ConnectionOpened()
+		}
 		localConnID := connID
 		s.connWg.Add(1)
 		go func() {
+			var opened bool   // the conn was accepted by the on connect handler
+			var connSpan Span // ends once the conn is closed (see: WithTracer)
+			// the conn is done once it's closed, so its requests have
+			// finished (see: Shutdown)
 			defer func() {
 				s.logger.Debug("connWg done", "op", op, "conn", localConnID)
 				s.connWg.Done()
+			}()
+			defer func() {
+				s.stats.connClosed()
+				if s.metricsHook != nil {
+					s.metricsHook.ConnectionClosed()
+				}
 				err := conn.close()
+				// the conn remains open until its requests have finished
+				s.connsMu.Lock()
+				delete(s.conns, localConnID)
+				s.connsMu.Unlock()
+				s.releaseConnSlot()
 				if err != nil {
 					s.logger.Error("error closing conn", "op", op, "conn", localConnID, "conn/req", "err", err)
 					// we are intentionally not returning here; since we still
 					// need to call the onCloseHandler if it's not nil
 				}
+				if opened {
+					conn.sendConnEvent(EventConnectionClosed)
+					conn.auditConnClosed()
+				}
+				if connSpan != nil {
+					connSpan.End()
+				}
 				if s.onCloseHandler != nil {
 					s.onCloseHandler(localConnID)
 				}
@@ -136,18 +440,35 @@ func (s *Server) Run(addr string, opt ...Option) error {
 					}
 				}()
 			}
-			if s.readTimeout != 0 {
-				if err := c.SetReadDeadline(time.Now().Add(s.readTimeout)); err != nil {
-					s.logger.Error("unable to set read deadline", "op", op, "err", err.Error())
+			if s.keepAlive != 0 {
+				if err := setKeepAlive(c, s.keepAlive); err != nil {
+					s.logger.Error("unable to set keepalive", "op", op, "conn", localConnID, "err", err.Error())
 					return
 				}
 			}
-			if s.writeTimeout != 0 {
-				if err := c.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
-					s.logger.Error("unable to set write deadline", "op", op, "err", err.Error())
+			if s.onConnect != nil {
+				if err := s.onConnect(localConnID, c.RemoteAddr()); err != nil {
+					s.logger.Debug("connection rejected by on connect handler", "op", op, "conn", localConnID, "remoteAddr", c.RemoteAddr(), "err", err)
 					return
 				}
 			}
+			opened = true
+			conn.sendConnEvent(EventConnectionOpened)
+			conn.auditConnOpened()
+			connSpan = conn.startConnSpan()
+			if s.maxConnLifetime > 0 {
+				expiry := time.AfterFunc(s.maxConnLifetime, func() {
+					s.logger.Debug("connection lifetime exceeded", "op", op, "conn", localConnID, "maxConnLifetime", s.maxConnLifetime)
+					if err := conn.drain(true, maxConnLifetimeDiag); err != nil {
+						s.logger.Debug("unable to drain conn", "op", op, "conn", localConnID, "err", err)
+					}
+				})
+				defer expiry.Stop()
+			}
+			if err := conn.handshake(); err != nil {
+				s.logger.Error("unable to complete tls handshake", "op", op, "conn", localConnID, "err", err.Error())
+				return
+			}
 			if err := conn.serveRequests(); err != nil {
 				s.logger.Error("error handling conn", "op", op, "conn", localConnID, "err", err.Error())
 			}
@@ -155,6 +476,63 @@ func (s *Server) Run(addr string, opt ...Option) error {
 	}
 }
 
+// releaseConnSlot frees the slot of a closed connection when the server has a
+// connection limit (see: WithMaxConnections)
+func (s *Server) releaseConnSlot() {
+	if s.connSlots != nil {
+		<-s.connSlots
+	}
+}
+
+// rejectConn sends a connection beyond the server's connection limit a notice
+// of disconnection and closes it (see: WithRejectExcessConnections).
+func (s *Server) rejectConn(c net.Conn) {
+	const op = "gldap.(Server).rejectConn"
+	defer func() { _ = c.Close() }()
+	timeout := s.writeTimeout
+	if timeout == 0 {
+		timeout = rejectConnTimeout
+	}
+	if err := c.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		s.logger.Debug("unable to set write deadline", "op", op, "err", err)
+		return
+	}
+	notice := noticeOfDisconnection(ResultBusy, "too many connections")
+	applyDiagnosticHook(s.diagnosticHook, nil, notice)
+	if _, err := c.Write(notice.packet().Bytes()); err != nil {
+		s.logger.Debug("unable to write notice of disconnection", "op", op, "err", err)
+	}
+}
+
+// isTemporaryAcceptError returns true if the listener's accept error is
+// transient (i.e. EMFILE or ECONNABORTED), so accepting can be retried.
+func isTemporaryAcceptError(err error) bool {
+	var te interface{ Temporary() bool }
+	return errors.As(err, &te) && te.Temporary()
+}
+
+// nextAcceptDelay returns the delay before accepting again after a temporary
+// accept error, which doubles after every consecutive error up to
+// maxAcceptDelay.
+func nextAcceptDelay(d time.Duration) time.Duration {
+	if d == 0 {
+		return minAcceptDelay
+	}
+	if d *= 2; d > maxAcceptDelay {
+		return maxAcceptDelay
+	}
+	return d
+}
+
+// nextConnID returns the ID of a newly accepted connection, which is unique
+// across the server's listeners.  It's the default ConnectionIDGenerator.
+func (s *Server) nextConnID() int {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	s.lastConnID++
+	return s.lastConnID
+}
+
 // Ready will return true when the server is ready to accept connection
 func (s *Server) Ready() bool {
 	s.mu.RLock()
@@ -162,48 +540,84 @@ func (s *Server) Ready() bool {
 	return s.listenerReady
 }
 
-// Stop a running ldap server
+// Addr returns the address of the server's listener, which is nil until the
+// server is ready (see: Ready).  When the server has several listeners (see:
+// Run), it's the address of the first one that became ready.  It's useful for
+// discovering the port of a server that was run with port 0 (i.e. ":0").
+func (s *Server) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.listeners) == 0 {
+		return nil
+	}
+	return s.listeners[0].Addr()
+}
+
+// Stop a running ldap server.  Every open connection is sent a notice of
+// disconnection before the server waits for them to close.  Their in-flight
+// requests are cancelled, see Shutdown to allow them to finish instead.  The
+// connections with in-flight requests are reported as terminated by the
+// server's shutdown report (see: Server.ShutdownReport).
 func (s *Server) Stop() error {
 	const op = "gldap.(Server).Stop"
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	s.logger.Debug("shutting down")
-	if s.listener == nil && s.shutdownCancel == nil {
+	start := s.clock.Now()
+	if len(s.listeners) == 0 && s.shutdownCancel == nil {
 		s.logger.Debug("nothing to do for shutdown")
 		return nil
 	}
 
-	if s.listener != nil {
+	var closeErrs []error
+	for _, l := range s.listeners {
 		s.logger.Debug("closing listener")
-		if err := s.listener.Close(); err != nil {
+		if err := l.Close(); err != nil {
 			switch {
-			case !strings.Contains(err.Error(), "use of closed network connection"):
-				return fmt.Errorf("%s: %w", op, err)
+			case !errors.Is(err, net.ErrClosed) && !strings.Contains(err.Error(), "use of closed network connection"):
+				closeErrs = append(closeErrs, err)
 			default:
 				s.logger.Debug("listener already closed")
 			}
 		}
 	}
+	if len(closeErrs) > 0 {
+		return fmt.Errorf("%s: %w", op, errors.Join(closeErrs...))
+	}
+	conns := s.openConns()
+	report := ShutdownReport{Connections: len(conns)}
+	for _, c := range conns {
+		if n := c.inFlightRequests(); n > 0 {
+			report.Terminated++
+			report.AbortedRequests += n
+		}
+	}
+	report.Drained = report.Connections - report.Terminated
+	s.logger.Debug("sending notices of disconnection")
+	s.disconnectAll(ResultUnavailable, "server stopping")
 	if s.shutdownCancel != nil {
 		s.logger.Debug("shutdown cancel func")
 		s.shutdownCancel()
 	}
 	s.logger.Debug("waiting on connections to close")
 	s.connWg.Wait()
+	report.Duration = s.clock.Now().Sub(start)
+	s.setShutdownReport(report)
 	s.logger.Debug("stopped")
 	return nil
 }
 
 // Router sets the mux (multiplexer) router for matching inbound requests
-// to handlers.
+// to handlers.  The router can be replaced while the server is running (i.e.
+// to reload its configuration), and the replacement takes effect immediately
+// for subsequent requests, including those of existing connections.  Requests
+// that are in-flight when it's replaced are served by the previous router.
 func (s *Server) Router(r *Mux) error {
 	const op = "gldap.(Server).HandleRoutes"
 	if r == nil {
 		return fmt.Errorf("%s: missing router: %w", op, ErrInvalidParameter)
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.router = r
+	s.router.Store(r)
 	return nil
 }
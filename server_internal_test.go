@@ -10,8 +10,12 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/go-ldap/ldap/v3"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,7 +43,7 @@ func TestServer_Stop(t *testing.T) {
 				require.NoError(t, err)
 				s.mu.Lock()
 				defer s.mu.Unlock()
-				s.listener = nil
+				s.listeners = nil
 				return s
 			}(),
 		},
@@ -53,7 +57,7 @@ func TestServer_Stop(t *testing.T) {
 				require.NoError(t, err)
 				s.mu.Lock()
 				defer s.mu.Unlock()
-				s.listener = l
+				s.listeners = []net.Listener{l}
 				s.shutdownCancel = nil
 				return s
 			}(),
@@ -65,7 +69,7 @@ func TestServer_Stop(t *testing.T) {
 				require.NoError(t, err)
 				s.mu.Lock()
 				defer s.mu.Unlock()
-				s.listener = nil
+				s.listeners = nil
 				s.shutdownCancel = nil
 				return s
 			}(),
@@ -81,7 +85,7 @@ func TestServer_Stop(t *testing.T) {
 				require.NoError(t, err)
 				s.mu.Lock()
 				defer s.mu.Unlock()
-				s.listener = l
+				s.listeners = []net.Listener{l}
 				s.shutdownCancel = cancel
 				l.Close()
 				return s
@@ -95,7 +99,7 @@ func TestServer_Stop(t *testing.T) {
 				require.NoError(t, err)
 				s.mu.Lock()
 				defer s.mu.Unlock()
-				s.listener = &mockListener{}
+				s.listeners = []net.Listener{&mockListener{}}
 				s.shutdownCancel = cancel
 				return s
 			}(),
@@ -126,3 +130,133 @@ type mockListener struct {
 func (*mockListener) Close() error {
 	return errors.New("mockListener.Close error")
 }
+
+func TestServer_Router(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	newMux := func(dn string) *Mux {
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewSearchResponseEntry(dn))
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		return mux
+	}
+	s, url := testServer(t, newMux("cn=old,dc=example,dc=org"))
+	assert.ErrorIs(s.Router(nil), ErrInvalidParameter)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	search := func() string {
+		res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+		require.Len(res.Entries, 1)
+		return res.Entries[0].DN
+	}
+	assert.Equal("cn=old,dc=example,dc=org", search())
+
+	// the replacement router serves the existing conn's subsequent requests
+	require.NoError(s.Router(newMux("cn=new,dc=example,dc=org")))
+	assert.Equal("cn=new,dc=example,dc=org", search())
+}
+
+// flakyListener returns the errs from Accept before accepting conns from the
+// wrapped listener.
+type flakyListener struct {
+	net.Listener
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		l.mu.Unlock()
+		return nil, err
+	}
+	l.mu.Unlock()
+	return l.Listener.Accept()
+}
+
+func TestServer_Serve_acceptErrors(t *testing.T) {
+	t.Parallel()
+	temporary := &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+	t.Run("temporary", func(t *testing.T) {
+		t.Parallel()
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}))
+		s, err := NewServer()
+		require.NoError(err)
+		require.NoError(s.Router(mux))
+		t.Cleanup(func() { _ = s.Stop() })
+		l, err := net.Listen("tcp", "localhost:0")
+		require.NoError(err)
+		fl := &flakyListener{Listener: l, errs: []error{temporary, temporary, temporary}}
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(fl) }()
+
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", l.Addr()))
+		require.NoError(err)
+		defer client.Close()
+		assert.NoError(client.Bind("cn=alice", "password"))
+		select {
+		case err := <-served:
+			t.Fatalf("Serve returned: %v", err)
+		default:
+		}
+		require.NoError(s.Stop())
+		assert.NoError(<-served)
+	})
+	t.Run("stop-during-backoff", func(t *testing.T) {
+		t.Parallel()
+		assert, require := assert.New(t), require.New(t)
+		s, err := NewServer()
+		require.NoError(err)
+		l, err := net.Listen("tcp", "localhost:0")
+		require.NoError(err)
+		errs := make([]error, 20)
+		for i := range errs {
+			errs[i] = temporary
+		}
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(&flakyListener{Listener: l, errs: errs}) }()
+		for !s.Ready() {
+			time.Sleep(time.Millisecond)
+		}
+		require.NoError(s.Stop())
+		assert.NoError(<-served)
+	})
+	t.Run("permanent", func(t *testing.T) {
+		t.Parallel()
+		assert, require := assert.New(t), require.New(t)
+		s, err := NewServer()
+		require.NoError(err)
+		l, err := net.Listen("tcp", "localhost:0")
+		require.NoError(err)
+		t.Cleanup(func() { _ = s.Stop() })
+		err = s.Serve(&flakyListener{Listener: l, errs: []error{errors.New("permanent")}})
+		require.Error(err)
+		assert.Contains(err.Error(), "error accepting conn: permanent")
+	})
+}
+
+func Test_nextAcceptDelay(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	var d time.Duration
+	var got []time.Duration
+	for i := 0; i < 10; i++ {
+		d = nextAcceptDelay(d)
+		got = append(got, d)
+	}
+	assert.Equal(minAcceptDelay, got[0])
+	assert.Equal(2*minAcceptDelay, got[1])
+	assert.Equal(maxAcceptDelay, got[len(got)-1])
+}
@@ -5,78 +5,159 @@ package gldap
 
 import (
 	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
 	"time"
-
-	"github.com/hashicorp/go-hclog"
 )
 
 type configOptions struct {
 	withTLSConfig            *tls.Config
-	withLogger               hclog.Logger
+	withLogger               Logger
 	withReadTimeout          time.Duration
 	withWriteTimeout         time.Duration
+	withIdleTimeout          time.Duration
 	withDisablePanicRecovery bool
 	withOnClose              OnCloseHandler
+	withOnConnect            OnConnectHandler
+	withDiagnosticHook       DiagnosticMessageHook
+	withMonitor              bool
+	withChangelog            *Changelog
+	withChangeNotifier       *ChangeNotifier
+	withAutoWhoAmI           bool
+	withStartTLS             *tls.Config
+	withKeepAlive            time.Duration
+	withWriteThrough         *WriteThrough
+	withReadOnly             bool
+	withReadOnlyDiagnostic   string
+	withRequireTLS           bool
+	withRequireTLSOps        []RouteOperation
+	withClock                Clock
+
+	withConnectionIDGenerator ConnectionIDGenerator
+
+	withMaxConnections          int
+	withRejectExcessConnections bool
+	withConnectionPolicy        ConnectionPolicy
+	withMaxRequestSize          int
+	withJournal                 int
+	withEventSink               EventSink
+	withMaxConnLifetime         time.Duration
+	withAccessLog               AccessLogSink
+	withMetricsHook             MetricsHook
+	withTracer                  Tracer
+	withCriticalControlPolicy   CriticalControlPolicy
+	withSupportedControls       []string
+	withSlowOpThreshold         time.Duration
+	withSlowOpFn                SlowOperationFunc
+	withAuditor                 Auditor
 }
 
 func configDefaults() configOptions {
-	return configOptions{}
+	return configOptions{
+		withClock:                 systemClock{},
+		withCriticalControlPolicy: RejectUnknownCriticalControls,
+	}
 }
 
 // getConfigOpts gets the defaults and applies the opt overrides passed
 // in.
-func getConfigOpts(opt ...Option) configOptions {
+func getConfigOpts(opt ...ServerOption) configOptions {
 	opts := configDefaults()
 	applyOpts(&opts, opt...)
 	return opts
 }
 
-// WithLogger provides the optional logger.
-func WithLogger(l hclog.Logger) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*configOptions); ok {
-			o.withLogger = l
-		}
-	}
+// WithLogger provides the optional logger, which logs errors to stderr with a
+// *slog.Logger by default (go 1.21 or later, otherwise messages are
+// discarded).  Any Logger can be used (see: NewSlogLogger and the hclogger
+// package).
+func WithLogger(l Logger) Option {
+	return serverOption(func(o *configOptions) {
+		o.withLogger = l
+	})
 }
 
-// WithTLSConfig provides an optional tls.Config
+// WithTLSConfig provides an optional tls.Config, which is also used by an
+// UpstreamPool to dial ldaps:// URLs.  A server's certificates can be rotated
+// without a restart and selected by server name with the config's callbacks
+// (see: NewCertificateReloader and SNIConfig).
 func WithTLSConfig(tc *tls.Config) Option {
-	return func(o interface{}) {
+	return targetedOption(func(o interface{}) bool {
 		switch v := o.(type) {
 		case *configOptions:
 			v.withTLSConfig = tc
+		case *upstreamOptions:
+			v.withTLSConfig = tc
+		default:
+			return false
 		}
-	}
+		return true
+	})
 }
 
-// WithReadTimeout will set a read time out per connection
+// WithKeepAlive will set the period of the TCP keepalive probes that verify the
+// liveness of idle connections.  A probe is sent once a connection has been
+// idle for the period, so dead connections (including their paged and
+// persistent searches) are reaped rather than held open indefinitely.  A
+// period less than zero disables the probes.  Connections use the operating
+// system's keepalive defaults when the period is zero, which is the default.
+func WithKeepAlive(period time.Duration) Option {
+	return serverOption(func(o *configOptions) {
+		o.withKeepAlive = period
+	})
+}
+
+// WithReadTimeout will set the time allowed to read a request, which starts
+// once the request's first byte is received.  The time a connection waits for
+// its next request is limited by WithIdleTimeout.
 func WithReadTimeout(d time.Duration) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*configOptions); ok {
-			o.withReadTimeout = d
-		}
-	}
+	return serverOption(func(o *configOptions) {
+		o.withReadTimeout = d
+	})
 }
 
-// WithWriteTimeout will set a write timeout per connection
+// WithWriteTimeout will set the time allowed to write a response
 func WithWriteTimeout(d time.Duration) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*configOptions); ok {
-			o.withWriteTimeout = d
+	return serverOption(func(o *configOptions) {
+		o.withWriteTimeout = d
+	})
+}
+
+// WithIdleTimeout will close connections which haven't sent a request for the
+// duration.  The timeout is reset every time a request is received, and a
+// connection isn't idle while it has in-flight requests (i.e. a persistent
+// search).
+func WithIdleTimeout(d time.Duration) Option {
+	return serverOption(func(o *configOptions) {
+		o.withIdleTimeout = d
+	})
+}
+
+// WithMaxConnectionLifetime will gracefully terminate connections once
+// they've been open for the duration (i.e. to force clients to re-resolve the
+// server's DNS name and rebalance across replicas).  An expired connection
+// stops reading requests and it's closed once its in-flight requests have been
+// responded to, and an idle one is sent a notice of disconnection first (see:
+// https://tools.ietf.org/html/rfc4511#section-4.4.1).  A duration less than or
+// equal to zero is ignored.
+func WithMaxConnectionLifetime(d time.Duration) Option {
+	return serverOption(func(o *configOptions) {
+		if d > 0 {
+			o.withMaxConnLifetime = d
 		}
-	}
+	})
 }
 
 // WithDisablePanicRecovery will disable recovery from panics which occur when
 // handling a request.  This is helpful for debugging since you'll get the
 // panic's callstack.
 func WithDisablePanicRecovery() Option {
-	return func(o interface{}) {
-		if o, ok := o.(*configOptions); ok {
-			o.withDisablePanicRecovery = true
-		}
-	}
+	return serverOption(func(o *configOptions) {
+		o.withDisablePanicRecovery = true
+	})
 }
 
 // OnCloseHandler defines a function for a "on close" callback handler.  See:
@@ -88,9 +169,228 @@ type OnCloseHandler func(connectionID int)
 // clean up resources for closed connections (using their ID to determine which
 // one to clean up)
 func WithOnClose(handler OnCloseHandler) Option {
-	return func(o interface{}) {
-		if o, ok := o.(*configOptions); ok {
-			o.withOnClose = handler
+	return serverOption(func(o *configOptions) {
+		o.withOnClose = handler
+	})
+}
+
+// OnConnectHandler defines a function for an "on connect" callback handler,
+// which returns an error to reject the connection.  See: NewServer(...) and
+// WithOnConnect(...) option for more information
+type OnConnectHandler func(connectionID int, remoteAddr net.Addr) error
+
+// WithOnConnect defines an OnConnectHandler that the server will use as a
+// callback every time a connection to the server is accepted, before the TLS
+// handshake of a TLS listener's connection and before any request of the
+// connection is read.  When the handler returns an error, the connection is
+// closed without being served.  The OnCloseHandler (see: WithOnClose) is
+// called for every connection passed to the OnConnectHandler, including the
+// rejected ones, which allows callers to keep track of their connections (using
+// their ID).
+func WithOnConnect(handler OnConnectHandler) Option {
+	return serverOption(func(o *configOptions) {
+		o.withOnConnect = handler
+	})
+}
+
+// WithMonitor enables the monitor backend, which responds to searches of the
+// cn=Monitor subtree with entries describing the server's connections,
+// operations and backends.  The entries mirror OpenLDAP's monitor backend, so
+// existing LDAP monitoring tooling can be used with the server.  Searches of
+// the subtree are never routed to the server's handlers when it's enabled.
+func WithMonitor() Option {
+	return serverOption(func(o *configOptions) {
+		o.withMonitor = true
+	})
+}
+
+// WithChangelog enables the changelog backend, which records the server's
+// successful add, modify and delete requests in the changelog and responds to
+// searches of the cn=changelog subtree with its entries.  Searches of the
+// subtree are never routed to the server's handlers when it's enabled.
+func WithChangelog(c *Changelog) Option {
+	return serverOption(func(o *configOptions) {
+		o.withChangelog = c
+	})
+}
+
+// WithChangeNotifier enables publishing the changes made by the server's
+// successful add, modify, modify DN and delete requests to the notifier's
+// subscribers (see: ChangeNotifier.Subscribe), which are typically the handlers
+// of persistent searches.  The changes include their change numbers when the
+// server has a changelog (see: WithChangelog).
+func WithChangeNotifier(n *ChangeNotifier) Option {
+	return serverOption(func(o *configOptions) {
+		o.withChangeNotifier = n
+	})
+}
+
+// WithWriteThrough enables forwarding the server's successful add, modify,
+// modify DN and delete requests to the write-through's upstream server (see:
+// WriteThrough), after they've been applied by the server's handlers.
+func WithWriteThrough(wt *WriteThrough) Option {
+	return serverOption(func(o *configOptions) {
+		o.withWriteThrough = wt
+	})
+}
+
+// WithReadOnly enables the server's read-only mode, which rejects every update
+// request (add, modify, modify DN, delete and password modify) with
+// unwillingToPerform and a diagnostic message of
+// DefaultReadOnlyDiagnosticMessage (see: WithReadOnlyDiagnosticMessage).
+// Update requests are never routed to the server's handlers when it's
+// enabled.
+func WithReadOnly() Option {
+	return serverOption(func(o *configOptions) {
+		o.withReadOnly = true
+	})
+}
+
+// WithReadOnlyDiagnosticMessage sets the diagnostic message of the responses
+// to the update requests rejected by the server's read-only mode (see:
+// WithReadOnly).
+func WithReadOnlyDiagnosticMessage(msg string) Option {
+	return serverOption(func(o *configOptions) {
+		o.withReadOnlyDiagnostic = msg
+	})
+}
+
+// WithRequireTLS makes the server reply confidentialityRequired to the simple
+// binds with a password, and to the requests of the operations (i.e.
+// ModifyRouteOperation), of a connection until it's protected by TLS: either
+// it was accepted by a TLS listener (see: WithTLSConfig) or it completed a
+// StartTLS request (see: WithStartTLS).  The rejected requests are never
+// routed to the server's handlers.  Anonymous and unauthenticated simple binds
+// (without a password) are still allowed, so are the StartTLS requests.
+func WithRequireTLS(op ...RouteOperation) Option {
+	return serverOption(func(o *configOptions) {
+		o.withRequireTLS = true
+		o.withRequireTLSOps = append(o.withRequireTLSOps, op...)
+	})
+}
+
+// WithAutoWhoAmI enables the server's "Who am I?" extended operation handler
+// (see: https://tools.ietf.org/html/rfc4532), which responds with the identity
+// established by the connection's last successful bind ("dn:<boundDN>") or an
+// empty authzId for an anonymous connection.  "Who am I?" requests are never
+// routed to the server's handlers when it's enabled.
+func WithAutoWhoAmI() Option {
+	return serverOption(func(o *configOptions) {
+		o.withAutoWhoAmI = true
+	})
+}
+
+// WithStartTLS enables the server's StartTLS extended operation handler (see:
+// https://tools.ietf.org/html/rfc4511#section-4.14), which responds to StartTLS
+// requests and negotiates TLS using the tls.Config.  A StartTLS request on a
+// connection that's already secured is rejected with an operationsError.
+// StartTLS requests are never routed to the server's handlers when it's
+// enabled.  Like WithTLSConfig, the config's callbacks (i.e. GetCertificate)
+// are called for every StartTLS handshake.
+func WithStartTLS(tc *tls.Config) Option {
+	return serverOption(func(o *configOptions) {
+		o.withStartTLS = tc
+	})
+}
+
+// WithMaxConnections limits the number of the server's open connections,
+// across all its listeners, to n.  Once the limit is reached the server stops
+// accepting connections until one of them is closed, so new connections wait
+// in the listener's accept queue (see: WithRejectExcessConnections).  A limit
+// less than one means there's no limit, which is the default.
+func WithMaxConnections(n int) Option {
+	return serverOption(func(o *configOptions) {
+		if n > 0 {
+			o.withMaxConnections = n
+		}
+	})
+}
+
+// WithRejectExcessConnections specifies that connections beyond the server's
+// limit (see: WithMaxConnections) are accepted and immediately closed with a
+// notice of disconnection with a result code of ResultBusy, rather than
+// waiting in the listener's accept queue.  This gives clients a prompt error
+// instead of waiting on a connection that isn't served.
+func WithRejectExcessConnections() Option {
+	return serverOption(func(o *configOptions) {
+		o.withRejectExcessConnections = true
+	})
+}
+
+// WithConnectionPolicy specifies an optional policy which is called with the
+// remote address of every accepted connection, and a connection the policy
+// rejects is closed without being served (i.e. to only allow connections from
+// a private network, see: AllowCIDRs, DenyCIDRs, RateLimitPerIP and
+// ConnectionPolicies).  Rejected connections don't count towards the server's
+// connection limit (see: WithMaxConnections).
+func WithConnectionPolicy(p ConnectionPolicy) Option {
+	return serverOption(func(o *configOptions) {
+		if p != nil {
+			o.withConnectionPolicy = p
+		}
+	})
+}
+
+// WithMaxRequestSize limits the size of a request's encoded packet to n bytes,
+// so a client can't make the server allocate unbounded memory by sending a
+// packet with an enormous length.  A connection that sends a larger request is
+// sent a notice of disconnection with a result code of ResultProtocolError
+// and closed, without the request being read.  A request whose nested lengths
+// are malformed, or which uses the indefinite length form, is treated the same
+// way.  A limit less than one means there's no limit, which is the default.
+func WithMaxRequestSize(n int) Option {
+	return serverOption(func(o *configOptions) {
+		if n > 0 {
+			o.withMaxRequestSize = n
+		}
+	})
+}
+
+// ConnectionIDGenerator defines a function which generates the IDs of a
+// server's connections (see: Request.ConnectionID).  IDs must be greater than
+// zero and unique among a server's open connections; a connection whose ID is
+// invalid or in use is closed when it's accepted.  A generator may be called
+// concurrently when the server has several listeners.
+type ConnectionIDGenerator func() int
+
+// WithConnectionIDGenerator specifies an optional generator of connection IDs,
+// which replaces the server's counter that starts at one (i.e. so the logs of
+// several servers can be merged without their connection IDs colliding, see:
+// RandomConnectionIDGenerator).  It can be passed to NewServer for every
+// listener, or to Run or Serve for the connections of one listener (i.e. to
+// namespace the IDs per listener).
+func WithConnectionIDGenerator(g ConnectionIDGenerator) Option {
+	return serverOption(func(o *configOptions) {
+		if g != nil {
+			o.withConnectionIDGenerator = g
+		}
+	})
+}
+
+// RandomConnectionIDGenerator returns a generator of random positive
+// connection IDs, which are unlikely to collide with those of other servers.
+//
+// Supported options: WithRandReader
+func RandomConnectionIDGenerator(opt ...Option) ConnectionIDGenerator {
+	opts := getRandOpts(opt...)
+	var mu sync.Mutex
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		var b [8]byte
+		if _, err := io.ReadFull(opts.withRandReader, b[:]); err != nil {
+			// an invalid ID, so the connection is closed
+			return 0
+		}
+		// IDs are at most 53 bits, so they're positive ints and exactly
+		// representable by JSON log consumers
+		bits := 53
+		if strconv.IntSize < 64 {
+			bits = strconv.IntSize - 1
+		}
+		if id := int(binary.BigEndian.Uint64(b[:]) >> (64 - bits)); id > 0 {
+			return id
 		}
+		return 1
 	}
 }
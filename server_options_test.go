@@ -4,7 +4,9 @@
 package gldap
 
 import (
+	"bytes"
 	"crypto/tls"
+	"net"
 	"reflect"
 	"runtime"
 	"testing"
@@ -52,6 +54,28 @@ func Test_WithWriteTimeout(t *testing.T) {
 	assert.Equal(opts, testOpts)
 }
 
+func Test_WithIdleTimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	timeout := 1 * time.Minute
+	opts := getConfigOpts(WithIdleTimeout(timeout))
+	testOpts := configDefaults()
+	testOpts.withIdleTimeout = timeout
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithMaxConnectionLifetime(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithMaxConnectionLifetime(time.Hour))
+	testOpts := configDefaults()
+	testOpts.withMaxConnLifetime = time.Hour
+	assert.Equal(opts, testOpts)
+
+	// a duration less than or equal to zero is ignored
+	assert.Equal(configDefaults(), getConfigOpts(WithMaxConnectionLifetime(0)))
+}
+
 func Test_WithDisablePanicRecovery(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
@@ -71,3 +95,310 @@ func Test_WitOnClose(t *testing.T) {
 	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withOnClose).Pointer()).Name(),
 		runtime.FuncForPC(reflect.ValueOf(testOpts.withOnClose).Pointer()).Name())
 }
+
+func Test_WithOnConnect(t *testing.T) {
+	t.Parallel()
+	fn := func(int, net.Addr) error { return nil }
+	assert := assert.New(t)
+	opts := getConfigOpts(WithOnConnect(fn))
+	testOpts := configDefaults()
+	testOpts.withOnConnect = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withOnConnect).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withOnConnect).Pointer()).Name())
+}
+
+func Test_WithDiagnosticMessageHook(t *testing.T) {
+	t.Parallel()
+	fn := func(_ *Request, _ int, msg string) string { return msg }
+	assert := assert.New(t)
+	opts := getConfigOpts(WithDiagnosticMessageHook(fn))
+	testOpts := configDefaults()
+	testOpts.withDiagnosticHook = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withDiagnosticHook).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withDiagnosticHook).Pointer()).Name())
+}
+
+func Test_WithJournal(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithJournal(10))
+	testOpts := configDefaults()
+	testOpts.withJournal = 10
+	assert.Equal(opts, testOpts)
+
+	// a max less than one is ignored
+	assert.Equal(configDefaults(), getConfigOpts(WithJournal(0)))
+}
+
+func Test_WithEventSink(t *testing.T) {
+	t.Parallel()
+	fn := func(Event) {}
+	assert := assert.New(t)
+	opts := getConfigOpts(WithEventSink(fn))
+	testOpts := configDefaults()
+	testOpts.withEventSink = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withEventSink).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withEventSink).Pointer()).Name())
+}
+
+func Test_WithAccessLog(t *testing.T) {
+	t.Parallel()
+	fn := func(AccessLogRecord) {}
+	assert := assert.New(t)
+	opts := getConfigOpts(WithAccessLog(fn))
+	testOpts := configDefaults()
+	testOpts.withAccessLog = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withAccessLog).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withAccessLog).Pointer()).Name())
+}
+
+func Test_WithMetricsHook(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	h := &testMetricsHook{}
+	opts := getConfigOpts(WithMetricsHook(h))
+	testOpts := configDefaults()
+	testOpts.withMetricsHook = h
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithTracer(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	tr := &testTracer{}
+	opts := getConfigOpts(WithTracer(tr))
+	testOpts := configDefaults()
+	testOpts.withTracer = tr
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithCriticalControlPolicy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithCriticalControlPolicy(IgnoreUnknownCriticalControls))
+	testOpts := configDefaults()
+	testOpts.withCriticalControlPolicy = IgnoreUnknownCriticalControls
+	assert.Equal(opts, testOpts)
+
+	// unknown critical controls are rejected by default, and an invalid
+	// policy is ignored
+	assert.Equal(RejectUnknownCriticalControls, configDefaults().withCriticalControlPolicy)
+	assert.Equal(configDefaults(), getConfigOpts(WithCriticalControlPolicy(0)))
+}
+
+func Test_WithSupportedControls(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithSupportedControls("1.2.3.4", "1.2.3.5"))
+	testOpts := configDefaults()
+	testOpts.withSupportedControls = []string{"1.2.3.4", "1.2.3.5"}
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithAuditor(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	a := &testAuditor{}
+	opts := getConfigOpts(WithAuditor(a))
+	testOpts := configDefaults()
+	testOpts.withAuditor = a
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithSlowOperationThreshold(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithSlowOperationThreshold(time.Second))
+	testOpts := configDefaults()
+	testOpts.withSlowOpThreshold = time.Second
+	assert.Equal(opts, testOpts)
+
+	// a threshold less than or equal to zero is ignored
+	assert.Equal(configDefaults(), getConfigOpts(WithSlowOperationThreshold(0)))
+}
+
+func Test_WithSlowOperationFunc(t *testing.T) {
+	t.Parallel()
+	fn := func(AccessLogRecord) {}
+	assert := assert.New(t)
+	opts := getConfigOpts(WithSlowOperationFunc(fn))
+	testOpts := configDefaults()
+	testOpts.withSlowOpFn = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withSlowOpFn).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withSlowOpFn).Pointer()).Name())
+}
+
+func Test_WithMonitor(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithMonitor())
+	testOpts := configDefaults()
+	testOpts.withMonitor = true
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithChangelog(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	c := &Changelog{}
+	opts := getConfigOpts(WithChangelog(c))
+	testOpts := configDefaults()
+	testOpts.withChangelog = c
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithChangeNotifier(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	n := &ChangeNotifier{}
+	opts := getConfigOpts(WithChangeNotifier(n))
+	testOpts := configDefaults()
+	testOpts.withChangeNotifier = n
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithAutoWhoAmI(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithAutoWhoAmI())
+	testOpts := configDefaults()
+	testOpts.withAutoWhoAmI = true
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithStartTLS(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	tc := &tls.Config{}
+	opts := getConfigOpts(WithStartTLS(tc))
+	testOpts := configDefaults()
+	testOpts.withStartTLS = tc
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithKeepAlive(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithKeepAlive(30 * time.Second))
+	testOpts := configDefaults()
+	testOpts.withKeepAlive = 30 * time.Second
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithWriteThrough(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	wt := &WriteThrough{}
+	opts := getConfigOpts(WithWriteThrough(wt))
+	testOpts := configDefaults()
+	testOpts.withWriteThrough = wt
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithReadOnly(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithReadOnly())
+	testOpts := configDefaults()
+	testOpts.withReadOnly = true
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithReadOnlyDiagnosticMessage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithReadOnlyDiagnosticMessage("read-only replica"))
+	testOpts := configDefaults()
+	testOpts.withReadOnlyDiagnostic = "read-only replica"
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithRequireTLS(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithRequireTLS(ModifyRouteOperation), WithRequireTLS(AddRouteOperation))
+	testOpts := configDefaults()
+	testOpts.withRequireTLS = true
+	testOpts.withRequireTLSOps = []RouteOperation{ModifyRouteOperation, AddRouteOperation}
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithConnectionIDGenerator(t *testing.T) {
+	t.Parallel()
+	fn := func() int { return 1 }
+	assert := assert.New(t)
+	opts := getConfigOpts(WithConnectionIDGenerator(fn))
+	testOpts := configDefaults()
+	testOpts.withConnectionIDGenerator = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withConnectionIDGenerator).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withConnectionIDGenerator).Pointer()).Name())
+
+	// a nil generator is ignored
+	assert.Nil(getConfigOpts(WithConnectionIDGenerator(nil)).withConnectionIDGenerator)
+}
+
+func TestRandomConnectionIDGenerator(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	g := RandomConnectionIDGenerator()
+	ids := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		id := g()
+		assert.Greater(id, 0)
+		ids[id] = true
+	}
+	assert.Len(ids, 100)
+
+	// an all zero source still generates a valid ID, and an exhausted one
+	// generates an invalid ID
+	g = RandomConnectionIDGenerator(WithRandReader(bytes.NewReader(make([]byte, 8))))
+	assert.Equal(1, g())
+	assert.Equal(0, g())
+}
+
+func Test_WithMaxConnections(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithMaxConnections(10))
+	testOpts := configDefaults()
+	testOpts.withMaxConnections = 10
+	assert.Equal(opts, testOpts)
+
+	// a limit less than one is ignored
+	assert.Equal(configDefaults(), getConfigOpts(WithMaxConnections(0)))
+}
+
+func Test_WithRejectExcessConnections(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithRejectExcessConnections())
+	testOpts := configDefaults()
+	testOpts.withRejectExcessConnections = true
+	assert.Equal(opts, testOpts)
+}
+
+func Test_WithConnectionPolicy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	fn := func(net.Addr) error { return nil }
+	opts := getConfigOpts(WithConnectionPolicy(fn))
+	testOpts := configDefaults()
+	testOpts.withConnectionPolicy = fn
+	assert.Equal(runtime.FuncForPC(reflect.ValueOf(opts.withConnectionPolicy).Pointer()).Name(),
+		runtime.FuncForPC(reflect.ValueOf(testOpts.withConnectionPolicy).Pointer()).Name())
+
+	// a nil policy is ignored
+	assert.Nil(getConfigOpts(WithConnectionPolicy(nil)).withConnectionPolicy)
+}
+
+func Test_WithMaxRequestSize(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getConfigOpts(WithMaxRequestSize(1024))
+	testOpts := configDefaults()
+	testOpts.withMaxRequestSize = 1024
+	assert.Equal(opts, testOpts)
+
+	// a limit less than one is ignored
+	assert.Equal(configDefaults(), getConfigOpts(WithMaxRequestSize(0)))
+}
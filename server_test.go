@@ -6,7 +6,9 @@ package gldap_test
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -156,6 +158,63 @@ func TestServer_Run(t *testing.T) {
 		wg.Wait()
 		assert.Equal(1, closeCnt)
 	})
+	t.Run("WithOnConnect", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+
+		var mu sync.Mutex
+		connected := map[int]net.Addr{}
+		closed := make(chan int, 2)
+		reject := true
+		testOnConnectFn := func(connID int, remoteAddr net.Addr) error {
+			mu.Lock()
+			defer mu.Unlock()
+			connected[connID] = remoteAddr
+			if reject {
+				reject = false
+				return errors.New("rejected")
+			}
+			return nil
+		}
+		testOnCloseFn := func(connID int) { closed <- connID }
+		s, err := gldap.NewServer(gldap.WithOnConnect(testOnConnectFn), gldap.WithOnClose(testOnCloseFn), gldap.WithLogger(testLogger))
+		require.NoError(err)
+		mux, err := gldap.NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+			_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+		}))
+		require.NoError(s.Router(mux))
+
+		port := testdirectory.FreePort(t)
+		go func() {
+			err := s.Run(fmt.Sprintf(":%d", port))
+			assert.NoError(err)
+		}()
+		t.Cleanup(func() { err := s.Stop(); assert.NoError(err) })
+		for !s.Ready() {
+			time.Sleep(100 * time.Nanosecond)
+		}
+
+		// the first connection is rejected before its requests are read
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://localhost:%d", port))
+		require.NoError(err)
+		assert.Error(client.Bind("cn=alice", "password"))
+		client.Close()
+		rejectedID := <-closed
+
+		client, err = ldap.DialURL(fmt.Sprintf("ldap://localhost:%d", port))
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(client.Bind("cn=alice", "password"))
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(connected, 2)
+		require.Contains(connected, rejectedID)
+		for _, addr := range connected {
+			assert.NotEmpty(addr.String())
+		}
+	})
 }
 
 func TestServer_shutdownCtx(t *testing.T) {
@@ -231,3 +290,368 @@ func TestServer_Router(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_Serve(t *testing.T) {
+	t.Parallel()
+	newServer := func(t *testing.T) *gldap.Server {
+		t.Helper()
+		s, err := gldap.NewServer()
+		require.NoError(t, err)
+		mux, err := gldap.NewMux()
+		require.NoError(t, err)
+		require.NoError(t, mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+			_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+		}))
+		require.NoError(t, s.Router(mux))
+		return s
+	}
+	t.Run("port-zero", func(t *testing.T) {
+		require := require.New(t)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(err)
+		s := newServer(t)
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(l) }()
+		for !s.Ready() {
+			time.Sleep(100 * time.Nanosecond)
+		}
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", l.Addr()))
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(client.Bind("cn=alice", "password"))
+		require.NoError(s.Stop())
+		require.NoError(<-served)
+	})
+	t.Run("pipe", func(t *testing.T) {
+		require := require.New(t)
+		l := newPipeListener()
+		s := newServer(t)
+		go func() { _ = s.Serve(l) }()
+		defer func() { _ = s.Stop() }()
+		client := ldap.NewConn(l.dial(), false)
+		client.Start()
+		defer client.Close()
+		require.NoError(client.Bind("cn=alice", "password"))
+	})
+	t.Run("missing-listener", func(t *testing.T) {
+		s := newServer(t)
+		err := s.Serve(nil)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, gldap.ErrInvalidParameter)
+	})
+}
+
+// pipeListener is a net.Listener of the server ends of net.Pipe connections
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// dial returns the client end of a connection accepted by the listener
+func (l *pipeListener) dial() net.Conn {
+	client, server := net.Pipe()
+	l.conns <- server
+	return client
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: "pipe", Net: "pipe"}
+}
+
+func TestServer_multipleListeners(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	srvTLS, clientTLS := testdirectory.GetTLSConfig(t)
+
+	var connIDsMu sync.Mutex
+	connIDs := map[int]bool{}
+	s, err := gldap.NewServer()
+	require.NoError(err)
+	mux, err := gldap.NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		connIDsMu.Lock()
+		connIDs[r.ConnectionID()] = true
+		connIDsMu.Unlock()
+		_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+	}))
+	require.NoError(s.Router(mux))
+
+	ldapListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	ldapsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	var wg sync.WaitGroup
+	for _, serve := range []func() error{
+		func() error { return s.Serve(ldapListener) },
+		func() error { return s.Serve(ldapsListener, gldap.WithTLSConfig(srvTLS)) },
+	} {
+		serve := serve
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(serve())
+		}()
+	}
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+
+	for i := 0; i < 2; i++ {
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", ldapListener.Addr()))
+		require.NoError(err)
+		require.NoError(client.Bind("cn=alice", "password"))
+		client.Close()
+
+		client, err = ldap.DialURL(fmt.Sprintf("ldaps://localhost:%d", ldapsListener.Addr().(*net.TCPAddr).Port), ldap.DialWithTLSConfig(clientTLS))
+		require.NoError(err)
+		require.NoError(client.Bind("cn=alice", "password"))
+		client.Close()
+	}
+	// connection IDs are unique across the listeners
+	connIDsMu.Lock()
+	assert.Len(connIDs, 4)
+	connIDsMu.Unlock()
+
+	// stopping the server stops serving every listener
+	require.NoError(s.Stop())
+	wg.Wait()
+}
+
+func TestServer_connectionIDGenerator(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	var connIDsMu sync.Mutex
+	var connIDs []int
+	s, err := gldap.NewServer(gldap.WithConnectionIDGenerator(func() int { return 7 }))
+	require.NoError(err)
+	mux, err := gldap.NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		connIDsMu.Lock()
+		connIDs = append(connIDs, r.ConnectionID())
+		connIDsMu.Unlock()
+		_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+	}))
+	require.NoError(s.Router(mux))
+
+	// the server's generator is used unless a listener has its own
+	serverListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	listenerIDs, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	var next int
+	go func() { _ = s.Serve(serverListener) }()
+	go func() {
+		_ = s.Serve(listenerIDs, gldap.WithConnectionIDGenerator(func() int {
+			next++
+			return 1000 + next
+		}))
+	}()
+	defer func() { _ = s.Stop() }()
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+
+	bind := func(l net.Listener) *ldap.Conn {
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", l.Addr()))
+		require.NoError(err)
+		require.NoError(client.Bind("cn=alice", "password"))
+		return client
+	}
+	held := bind(serverListener)
+	defer held.Close()
+	bind(listenerIDs).Close()
+	bind(listenerIDs).Close()
+
+	// a connection whose ID is in use is closed
+	client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", serverListener.Addr()))
+	require.NoError(err)
+	defer client.Close()
+	assert.Error(client.Bind("cn=alice", "password"))
+
+	connIDsMu.Lock()
+	defer connIDsMu.Unlock()
+	assert.Equal([]int{7, 1001, 1002}, connIDs)
+}
+
+func TestServer_Addr(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	s, err := gldap.NewServer()
+	require.NoError(err)
+	mux, err := gldap.NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+	}))
+	require.NoError(s.Router(mux))
+	assert.Nil(s.Addr())
+
+	go func() { _ = s.Run("127.0.0.1:0") }()
+	defer func() { _ = s.Stop() }()
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+	addr := s.Addr()
+	require.NotNil(addr)
+	assert.NotZero(addr.(*net.TCPAddr).Port)
+	client, err := ldap.DialURL(fmt.Sprintf("ldap://%s", addr))
+	require.NoError(err)
+	defer client.Close()
+	assert.NoError(client.Bind("cn=alice", "password"))
+}
+
+func TestServer_maxConnections(t *testing.T) {
+	t.Parallel()
+	startServer := func(t *testing.T, opt ...gldap.ServerOption) string {
+		t.Helper()
+		require := require.New(t)
+		s, err := gldap.NewServer(opt...)
+		require.NoError(err)
+		mux, err := gldap.NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+			_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+		}))
+		require.NoError(s.Router(mux))
+		go func() { _ = s.Run("127.0.0.1:0") }()
+		t.Cleanup(func() { _ = s.Stop() })
+		for !s.Ready() {
+			time.Sleep(100 * time.Nanosecond)
+		}
+		return fmt.Sprintf("ldap://%s", s.Addr())
+	}
+	bind := func(t *testing.T, url string) (*ldap.Conn, error) {
+		t.Helper()
+		client, err := ldap.DialURL(url)
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Close() })
+		return client, client.Bind("cn=alice", "password")
+	}
+
+	t.Run("queued", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		url := startServer(t, gldap.WithMaxConnections(1))
+		held, err := bind(t, url)
+		require.NoError(err)
+
+		// the second conn isn't served until the first one is closed
+		queued, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer queued.Close()
+		bound := make(chan error, 1)
+		go func() { bound <- queued.Bind("cn=alice", "password") }()
+		select {
+		case err := <-bound:
+			assert.FailNow("conn beyond the limit was served", "err: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		held.Close()
+		select {
+		case err := <-bound:
+			assert.NoError(err)
+		case <-time.After(5 * time.Second):
+			assert.FailNow("queued conn wasn't served")
+		}
+	})
+	t.Run("rejected", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		url := startServer(t, gldap.WithMaxConnections(1), gldap.WithRejectExcessConnections())
+		held, err := bind(t, url)
+		require.NoError(err)
+		_, err = bind(t, url)
+		assert.Error(err)
+
+		// the closed conn's slot is freed
+		held.Close()
+		require.Eventually(func() bool {
+			client, err := ldap.DialURL(url)
+			if err != nil {
+				return false
+			}
+			defer client.Close()
+			return client.Bind("cn=alice", "password") == nil
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestServer_maxConnectionLifetime(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	const lifetime = 200 * time.Millisecond
+	closed := make(chan int, 2)
+	s, err := gldap.NewServer(
+		gldap.WithMaxConnectionLifetime(lifetime),
+		gldap.WithOnClose(func(connID int) { closed <- connID }),
+	)
+	require.NoError(err)
+	mux, err := gldap.NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+	}))
+	inFlight := make(chan struct{})
+	require.NoError(mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		close(inFlight)
+		// the search outlives the conn's lifetime
+		time.Sleep(2 * lifetime)
+		_ = w.Write(r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+	}))
+	require.NoError(s.Router(mux))
+	go func() { _ = s.Run("127.0.0.1:0") }()
+	t.Cleanup(func() { _ = s.Stop() })
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+	url := fmt.Sprintf("ldap://%s", s.Addr())
+
+	// an idle conn is closed once it expires
+	idle, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer idle.Close()
+	require.NoError(idle.Bind("cn=alice", "password"))
+
+	// a busy conn's in-flight request is responded to before it's closed
+	busy, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer busy.Close()
+	require.NoError(busy.Bind("cn=alice", "password"))
+	searchErr := make(chan error, 1)
+	go func() {
+		_, err := busy.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		searchErr <- err
+	}()
+	<-inFlight
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			require.FailNow("expired conn wasn't closed")
+		}
+	}
+	assert.NoError(<-searchErr)
+	assert.Error(idle.Bind("cn=alice", "password"))
+	assert.Error(busy.Bind("cn=alice", "password"))
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Session tracking identifier formats (see:
+// https://tools.ietf.org/html/draft-wahl-ldap-session-03)
+const (
+	// SessionTrackingRADIUSAcctSessionID identifies the session using a RADIUS
+	// Acct-Session-Id
+	SessionTrackingRADIUSAcctSessionID = "1.3.6.1.4.1.21008.108.63.1.1"
+
+	// SessionTrackingRADIUSAcctMultiSessionID identifies the session using a
+	// RADIUS Acct-Multi-Session-Id
+	SessionTrackingRADIUSAcctMultiSessionID = "1.3.6.1.4.1.21008.108.63.1.2"
+
+	// SessionTrackingUsername identifies the session using the end-user's
+	// username
+	SessionTrackingUsername = "1.3.6.1.4.1.21008.108.63.1.3"
+)
+
+// maxSessionSourceIPLen is the max length of a session tracking source IP
+const maxSessionSourceIPLen = 128
+
+// ControlSessionTracking implements the session tracking control described in
+// https://tools.ietf.org/html/draft-wahl-ldap-session-03, which an
+// application (i.e. a web app or RADIUS server) uses to tell the server which
+// end-user session a request is made on behalf of.
+type ControlSessionTracking struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// SourceIP is the IP address of the end-user's client
+	SourceIP string
+	// SourceName is the host name of the end-user's client
+	SourceName string
+	// FormatOID is the format of the Identifier (i.e. SessionTrackingUsername)
+	FormatOID string
+	// Identifier of the session
+	Identifier string
+}
+
+// GetControlType returns the OID
+func (c *ControlSessionTracking) GetControlType() string {
+	return ControlTypeSessionTracking
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSessionTracking) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSessionTracking, "Control Type ("+ControlTypeMap[ControlTypeSessionTracking]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Session Tracking Value")
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.SourceIP, "Source IP"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.SourceName, "Source Name"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.FormatOID, "Format OID"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.Identifier, "Identifier"))
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Session Tracking)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSessionTracking) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  SourceIP: %q  SourceName: %q  FormatOID: %q  Identifier: %q",
+		ControlTypeMap[ControlTypeSessionTracking],
+		ControlTypeSessionTracking,
+		c.Criticality,
+		c.SourceIP,
+		c.SourceName,
+		c.FormatOID,
+		c.Identifier)
+}
+
+// NewControlSessionTracking returns a session tracking control.  Supported
+// options: WithCriticality, WithSourceIP and WithSourceName
+func NewControlSessionTracking(formatOID, identifier string, opt ...Option) (*ControlSessionTracking, error) {
+	const op = "gldap.NewControlSessionTracking"
+	if formatOID == "" {
+		return nil, fmt.Errorf("%s: missing format OID: %w", op, ErrInvalidParameter)
+	}
+	opts := getControlOpts(opt...)
+	if len(opts.withSourceIP) > maxSessionSourceIPLen {
+		return nil, fmt.Errorf("%s: source IP is longer than %d: %w", op, maxSessionSourceIPLen, ErrInvalidParameter)
+	}
+	return &ControlSessionTracking{
+		Criticality: opts.withCriticality,
+		SourceIP:    opts.withSourceIP,
+		SourceName:  opts.withSourceName,
+		FormatOID:   formatOID,
+		Identifier:  identifier,
+	}, nil
+}
+
+func decodeControlSessionTracking(value *ber.Packet, criticality bool) (*ControlSessionTracking, error) {
+	const op = "gldap.decodeControlSessionTracking"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) != 4 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in session tracking control: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	c, err := NewControlSessionTracking(
+		seq.Children[2].Data.String(),
+		seq.Children[3].Data.String(),
+		WithCriticality(criticality),
+		WithSourceIP(seq.Children[0].Data.String()),
+		WithSourceName(seq.Children[1].Data.String()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+// GetSessionTrackingControls returns the request's session tracking controls.
+// A request may have more than one, each identifying the session using a
+// different format.
+func (r *Request) GetSessionTrackingControls() []*ControlSessionTracking {
+	var controls []*ControlSessionTracking
+	for _, c := range r.controls() {
+		if st, ok := c.(*ControlSessionTracking); ok {
+			controls = append(controls, st)
+		}
+	}
+	return controls
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlSessionTracking(t *testing.T) {
+	runControlTest(t,
+		testControlSessionTracking(t, SessionTrackingUsername, "alice", WithCriticality(true), WithSourceIP("192.0.2.1"), WithSourceName("client.example.org")),
+		withTestType(ControlTypeSessionTracking),
+		withTestToString("Control Type: Session Tracking (\"1.3.6.1.4.1.21008.108.63.1\")  Criticality: true  SourceIP: \"192.0.2.1\"  SourceName: \"client.example.org\"  FormatOID: \"1.3.6.1.4.1.21008.108.63.1.3\"  Identifier: \"alice\""),
+	)
+	runControlTest(t, testControlSessionTracking(t, SessionTrackingRADIUSAcctSessionID, ""))
+
+	_, err := NewControlSessionTracking("", "alice")
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewControlSessionTracking(SessionTrackingUsername, "alice", WithSourceIP(strings.Repeat("1", 129)))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestRequest_GetSessionTrackingControls(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	controlsCh := make(chan []*ControlSessionTracking, 1)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		controlsCh <- r.GetSessionTrackingControls()
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux)
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	// go-ldap doesn't implement the control, so send its encoded value
+	controlValue := func(c *ControlSessionTracking) string {
+		return string(c.Encode().Children[1].Data.Bytes())
+	}
+	username := testControlSessionTracking(t, SessionTrackingUsername, "alice", WithSourceIP("192.0.2.1"), WithSourceName("client.example.org"))
+	radius := testControlSessionTracking(t, SessionTrackingRADIUSAcctSessionID, "5f3a")
+	_, err = client.SimpleBind(&ldap.SimpleBindRequest{
+		Username: "cn=app,dc=example,dc=org",
+		Password: "password",
+		Controls: []ldap.Control{
+			ldap.NewControlString(ControlTypeSessionTracking, false, controlValue(username)),
+			ldap.NewControlString(ControlTypeSessionTracking, false, controlValue(radius)),
+		},
+	})
+	require.NoError(err)
+
+	got := <-controlsCh
+	assert.Equal([]*ControlSessionTracking{username, radius}, got)
+
+	r := &Request{message: &SearchMessage{}}
+	assert.Empty(r.GetSessionTrackingControls())
+}
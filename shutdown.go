@@ -0,0 +1,228 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+type shutdownOptions struct {
+	withNotice           bool
+	withNoticeDiagnostic string
+}
+
+func shutdownDefaults() shutdownOptions {
+	return shutdownOptions{
+		withNoticeDiagnostic: "server stopping",
+	}
+}
+
+func getShutdownOpts(opt ...Option) shutdownOptions {
+	opts := shutdownDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithShutdownNotice specifies that Shutdown sends a notice of disconnection
+// with the diagMsg (or "server stopping" when it's empty) to the connections
+// that are idle when the server shuts down (see:
+// https://tools.ietf.org/html/rfc4511#section-4.4.1)
+func WithShutdownNotice(diagMsg string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*shutdownOptions); ok {
+			o.withNotice = true
+			if diagMsg != "" {
+				o.withNoticeDiagnostic = diagMsg
+			}
+		}
+	}
+}
+
+// ShutdownReport describes how a server's connections were closed when it was
+// stopped or shut down (see: Shutdown and Server.ShutdownReport), so test
+// harnesses can assert a clean teardown.
+type ShutdownReport struct {
+	// Connections is the number of connections that were open when the server
+	// started shutting down
+	Connections int
+	// Drained is the number of connections that were closed once their
+	// in-flight requests had finished
+	Drained int
+	// Terminated is the number of connections that were closed before their
+	// in-flight requests had finished
+	Terminated int
+	// AbortedRequests is the number of in-flight requests that were cancelled
+	AbortedRequests int
+	// Duration of the shutdown, from closing the listeners until the
+	// connections were closed
+	Duration time.Duration
+}
+
+// Clean returns true when every connection was drained, without cancelling
+// any in-flight requests.
+func (r ShutdownReport) Clean() bool {
+	return r.Terminated == 0 && r.AbortedRequests == 0
+}
+
+// ShutdownReport returns the report of the server's last Stop or Shutdown, and
+// false when it hasn't been stopped or shut down.
+func (s *Server) ShutdownReport() (ShutdownReport, bool) {
+	s.shutdownReportMu.Lock()
+	defer s.shutdownReportMu.Unlock()
+	if s.shutdownReport == nil {
+		return ShutdownReport{}, false
+	}
+	return *s.shutdownReport, true
+}
+
+// setShutdownReport sets the report of the server's last Stop or Shutdown
+func (s *Server) setShutdownReport(r ShutdownReport) {
+	s.shutdownReportMu.Lock()
+	defer s.shutdownReportMu.Unlock()
+	s.shutdownReport = &r
+}
+
+// Shutdown gracefully shuts down a running ldap server.  It stops accepting
+// connections, stops reading requests from the open connections and waits for
+// their in-flight requests to be responded to, closing every connection once
+// it's idle.  When the ctx is done before then, the remaining connections are
+// terminated: their requests' contexts are cancelled (see: Request.Context)
+// and they're closed without waiting for their handlers to return.  Shutdown
+// returns a report of the drained and terminated connections, along with the
+// ctx's error when any were terminated.  Unlike Stop, in-flight requests
+// aren't cancelled until the ctx is done.
+//
+// Options supported: WithShutdownNotice
+func (s *Server) Shutdown(ctx context.Context, opt ...Option) (ShutdownReport, error) {
+	const op = "gldap.(Server).Shutdown"
+	if ctx == nil {
+		return ShutdownReport{}, fmt.Errorf("%s: missing context: %w", op, ErrInvalidParameter)
+	}
+	opts := getShutdownOpts(opt...)
+
+	s.logger.Debug("shutting down gracefully", "op", op)
+	start := s.clock.Now()
+	s.mu.Lock()
+	var closeErrs []error
+	for _, l := range s.listeners {
+		if err := l.Close(); err != nil && !errors.Is(err, net.ErrClosed) && !strings.Contains(err.Error(), "use of closed network connection") {
+			closeErrs = append(closeErrs, err)
+		}
+	}
+	s.listeners = nil
+	s.mu.Unlock()
+	if len(closeErrs) > 0 {
+		return ShutdownReport{}, fmt.Errorf("%s: %w", op, errors.Join(closeErrs...))
+	}
+
+	conns := s.openConns()
+	report := ShutdownReport{Connections: len(conns)}
+	for _, c := range conns {
+		if err := c.drain(opts.withNotice, opts.withNoticeDiagnostic); err != nil {
+			s.logger.Debug("unable to drain conn", "op", op, "conn", c.connID, "err", err)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.connWg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		if s.shutdownCancel != nil {
+			s.shutdownCancel()
+		}
+		report.Drained = report.Connections
+		report.Duration = s.clock.Now().Sub(start)
+		s.setShutdownReport(report)
+		s.logger.Debug("shut down", "op", op)
+		return report, nil
+	case <-ctx.Done():
+	}
+
+	// terminate the conns whose requests didn't finish in time
+	remaining := s.openConns()
+	for _, c := range remaining {
+		report.AbortedRequests += c.inFlightRequests()
+	}
+	if s.shutdownCancel != nil {
+		s.shutdownCancel()
+	}
+	for _, c := range remaining {
+		if err := c.netConn.Close(); err != nil {
+			s.logger.Debug("unable to close conn", "op", op, "conn", c.connID, "err", err)
+		}
+	}
+	report.Terminated = len(remaining)
+	if report.Connections > report.Terminated {
+		report.Drained = report.Connections - report.Terminated
+	}
+	report.Duration = s.clock.Now().Sub(start)
+	s.setShutdownReport(report)
+	s.logger.Debug("terminated connections", "op", op, "count", len(remaining))
+	return report, fmt.Errorf("%s: %d connections terminated: %w", op, len(remaining), ctx.Err())
+}
+
+// openConns returns the server's open connections
+func (s *Server) openConns() []*conn {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	conns := make([]*conn, 0, len(s.conns))
+	for _, c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// drain stops the conn from serving any more requests, while allowing its
+// in-flight requests to finish.  The conn is sent a notice of disconnection
+// when notice is true and it doesn't have any in-flight requests.  It's a
+// no-op when the conn has already been disconnected.
+func (c *conn) drain(notice bool, diagMsg string) (retErr error) {
+	const op = "gldap.(Conn).drain"
+	c.disconnectMu.Lock()
+	if c.disconnected {
+		c.disconnectMu.Unlock()
+		return nil
+	}
+	c.disconnected = true
+	c.draining = true
+	c.disconnectMu.Unlock()
+
+	// unblock the conn's pending read, so it stops serving requests, once the
+	// notice has been written or has failed to be written
+	defer func() {
+		if err := c.netConn.SetReadDeadline(time.Now()); err != nil && retErr == nil {
+			retErr = fmt.Errorf("%s: %w", op, err)
+		}
+	}()
+
+	c.inFlightMu.Lock()
+	idle := len(c.inFlight) == 0
+	c.inFlightMu.Unlock()
+
+	c.writerMu.Lock()
+	defer c.writerMu.Unlock()
+	if notice && idle {
+		if err := c.setWriteDeadline(); err != nil {
+			return fmt.Errorf("%s: unable to set write deadline: %w", op, err)
+		}
+		n := noticeOfDisconnection(ResultUnavailable, diagMsg)
+		applyDiagnosticHook(c.diagnosticHook, nil, n)
+		if _, err := c.writer.Write(n.packet().Bytes()); err != nil {
+			return fmt.Errorf("%s: unable to write notice of disconnection: %w", op, err)
+		}
+		if err := c.writer.Flush(); err != nil {
+			return fmt.Errorf("%s: unable to flush notice of disconnection: %w", op, err)
+		}
+	}
+	c.logger.Debug("draining", "op", op, "conn", c.connID, "idle", idle)
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithShutdownNotice(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	opts := getShutdownOpts(WithShutdownNotice("maintenance"))
+	testOpts := shutdownDefaults()
+	testOpts.withNotice = true
+	testOpts.withNoticeDiagnostic = "maintenance"
+	assert.Equal(opts, testOpts)
+
+	// an empty diagnostic message keeps the default
+	testOpts.withNoticeDiagnostic = shutdownDefaults().withNoticeDiagnostic
+	assert.Equal(testOpts, getShutdownOpts(WithShutdownNotice("")))
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	// startServer starts a server whose searches block until they're released
+	startServer := func(t *testing.T, release <-chan struct{}, searchErr chan<- error) (*Server, string) {
+		t.Helper()
+		require := require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+		}))
+		require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			searchErr <- r.Context().Err()
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+		}))
+		return testServer(t, mux)
+	}
+	// waitForConns waits until the server has n open conns
+	waitForConns := func(s *Server, n int) {
+		for len(s.openConns()) != n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	search := func(client *ldap.Conn) error {
+		_, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		return err
+	}
+
+	t.Run("in-flight", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		release, searchErr := make(chan struct{}), make(chan error, 1)
+		s, url := startServer(t, release, searchErr)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		clientErr := make(chan error, 1)
+		go func() { clientErr <- search(client) }()
+		for {
+			if conns := s.openConns(); len(conns) == 1 {
+				conns[0].inFlightMu.Lock()
+				n := len(conns[0].inFlight)
+				conns[0].inFlightMu.Unlock()
+				if n == 1 {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		_, ok := s.ShutdownReport()
+		assert.False(ok)
+		type result struct {
+			report ShutdownReport
+			err    error
+		}
+		shutdown := make(chan result, 1)
+		go func() {
+			report, err := s.Shutdown(context.Background())
+			shutdown <- result{report, err}
+		}()
+		// new connections aren't accepted
+		require.Eventually(func() bool {
+			_, err := ldap.DialURL(url)
+			return err != nil
+		}, 5*time.Second, time.Millisecond)
+
+		// the in-flight search isn't cancelled and is responded to
+		close(release)
+		assert.NoError(<-searchErr)
+		assert.NoError(<-clientErr)
+		res := <-shutdown
+		assert.NoError(res.err)
+		assert.True(res.report.Clean())
+		assert.Equal(1, res.report.Connections)
+		assert.Equal(1, res.report.Drained)
+		report, ok := s.ShutdownReport()
+		assert.True(ok)
+		assert.Equal(res.report, report)
+	})
+	t.Run("idle-notice", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		s, _ := startServer(t, nil, make(chan error, 1))
+		c, err := net.Dial("tcp", s.Addr().String())
+		require.NoError(err)
+		defer c.Close()
+		waitForConns(s, 1)
+
+		report, err := s.Shutdown(context.Background(), WithShutdownNotice("down for maintenance"))
+		require.NoError(err)
+		assert.Equal(ShutdownReport{Connections: 1, Drained: 1, Duration: report.Duration}, report)
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.Len(p.Children, 2)
+		assert.Equal(int64(0), p.Children[0].Value.(int64))
+		assert.Equal("down for maintenance", p.Children[1].Children[2].Value.(string))
+		_, err = ber.ReadPacket(c)
+		assert.ErrorIs(err, io.EOF)
+	})
+	t.Run("terminated", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		searchErr := make(chan error, 1)
+		s, url := startServer(t, nil, searchErr)
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		go func() { _ = search(client) }()
+		waitForConns(s, 1)
+		time.Sleep(50 * time.Millisecond) // wait for the search to be in-flight
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		report, err := s.Shutdown(ctx)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+		assert.False(report.Clean())
+		assert.Equal(1, report.Connections)
+		assert.Equal(0, report.Drained)
+		assert.Equal(1, report.Terminated)
+		assert.Equal(1, report.AbortedRequests)
+		assert.GreaterOrEqual(report.Duration, 50*time.Millisecond)
+		// the terminated search's context is cancelled
+		assert.ErrorIs(<-searchErr, context.Canceled)
+	})
+	t.Run("stop", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		searchErr := make(chan error, 1)
+		s, url := startServer(t, nil, searchErr)
+		busy, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer busy.Close()
+		idle, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer idle.Close()
+		go func() { _ = search(busy) }()
+		waitForConns(s, 2)
+		time.Sleep(50 * time.Millisecond) // wait for the search to be in-flight
+
+		require.NoError(s.Stop())
+		assert.ErrorIs(<-searchErr, context.Canceled)
+		report, ok := s.ShutdownReport()
+		require.True(ok)
+		assert.Equal(2, report.Connections)
+		assert.Equal(1, report.Drained)
+		assert.Equal(1, report.Terminated)
+		assert.Equal(1, report.AbortedRequests)
+	})
+	t.Run("missing-ctx", func(t *testing.T) {
+		s, err := NewServer()
+		require.NoError(t, err)
+		_, err = s.Shutdown(nil) //nolint:staticcheck // testing a nil context
+		assert.ErrorIs(t, err, ErrInvalidParameter)
+	})
+}
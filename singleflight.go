@@ -0,0 +1,130 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"sync"
+)
+
+// searchFlight deduplicates identical concurrent searches of a route, so the
+// route's handler is called once and its responses are written to every
+// client (see: WithSingleflight).
+type searchFlight struct {
+	mu    sync.Mutex
+	calls map[string]*searchCall
+}
+
+// searchCall is a search whose handler is in flight, along with the responses
+// it has written.
+type searchCall struct {
+	done chan struct{} // closed once the handler has returned
+
+	mu      sync.Mutex
+	entries []*SearchResponseEntry
+	result  *SearchResponseDone
+	// shareable is false when the handler wrote a response that isn't
+	// replayed to the other requests (i.e. an intermediate response)
+	shareable bool
+	// shared is true when the responses are replayed to the other requests,
+	// otherwise they call the handler themselves.
+	shared bool
+}
+
+func newSearchFlight() *searchFlight {
+	return &searchFlight{calls: map[string]*searchCall{}}
+}
+
+// handler returns a HandlerFunc which calls fn once for identical concurrent
+// searches.  The first request calls fn and the others wait for it to return,
+// and then its responses are replayed to them.  When its responses can't be
+// replayed (i.e. the first request was abandoned), the others call fn
+// themselves.
+func (f *searchFlight) handler(fn HandlerFunc) HandlerFunc {
+	return func(w *ResponseWriter, r *Request) {
+		key, ok := searchFlightKey(r)
+		if !ok {
+			fn(w, r)
+			return
+		}
+		f.mu.Lock()
+		if c, ok := f.calls[key]; ok {
+			f.mu.Unlock()
+			select {
+			case <-c.done:
+			case <-r.Context().Done():
+				return
+			}
+			if !c.shared {
+				fn(w, r)
+				return
+			}
+			c.replay(w)
+			return
+		}
+		c := &searchCall{done: make(chan struct{}), shareable: true}
+		f.calls[key] = c
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			delete(f.calls, key)
+			f.mu.Unlock()
+			close(c.done)
+		}()
+
+		r.setWriteHook(c.record)
+		fn(w, r)
+		r.setWriteHook(nil)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.shared = c.shareable && c.result != nil && c.result.code != ResultCanceled && r.Context().Err() == nil && !r.isTimedOut()
+	}
+}
+
+// record records a response written by the handler
+func (c *searchCall) record(resp Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch v := resp.(type) {
+	case *SearchResponseEntry:
+		c.entries = append(c.entries, v)
+	case *SearchResponseDone:
+		c.result = v
+	default:
+		c.shareable = false
+	}
+}
+
+// replay writes the recorded responses with the message ID of the request
+// being responded to.  Every request is written copies of the entries, since
+// the requests are replayed concurrently and their middleware may modify the
+// entries (see: ResponseWriter.WithInterceptor).
+func (c *searchCall) replay(w *ResponseWriter) {
+	for _, e := range c.entries {
+		resp := &SearchResponseEntry{
+			baseResponse: &baseResponse{messageID: w.messageID()},
+			entry:        e.entry.clone(),
+			controls:     append([]Control{}, e.controls...),
+		}
+		if err := w.Write(resp); err != nil {
+			return
+		}
+	}
+	base := *c.result.baseResponse
+	base.messageID = w.messageID()
+	_ = w.Write(&SearchResponseDone{baseResponse: &base, controls: c.result.controls})
+}
+
+// searchFlightKey returns the key of identical searches, which includes the
+// conn's bound DN so the responses are only shared by requests with the same
+// authorization.  Searches with controls aren't deduplicated, since their
+// responses may depend on the controls' state (i.e. paged results).
+func searchFlightKey(r *Request) (string, bool) {
+	m, ok := r.message.(*SearchMessage)
+	if !ok || len(m.Controls) > 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%q %q %d %d %d %d %t %q %q", r.BoundDN(), m.BaseDN, m.Scope, m.DerefAliases, m.TimeLimit, m.SizeLimit, m.TypesOnly, m.Filter, m.Attributes), true
+}
@@ -0,0 +1,168 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMux_singleflight(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	const clients = 5
+	var calls, arrived atomic.Int64
+	release := make(chan struct{})
+	mux, err := NewMux()
+	require.NoError(err)
+	mux.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			arrived.Add(1)
+			next(w, r)
+		}
+	})
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		calls.Add(1)
+		<-release
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org", WithAttributes(map[string][]string{"cn": {"alice"}})))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}, WithSingleflight()))
+	_, url := testServer(t, mux)
+
+	var wg sync.WaitGroup
+	results := make(chan *ldap.SearchResult, clients)
+	for i := 0; i < clients; i++ {
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(cn=alice)", nil, nil))
+			if err == nil {
+				results <- res
+			}
+		}()
+	}
+	for arrived.Load() < clients {
+		time.Sleep(time.Millisecond)
+	}
+	// wait for the requests to reach the handler after the middleware
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(int64(1), calls.Load())
+	var n int
+	for res := range results {
+		n++
+		require.Len(res.Entries, 1)
+		assert.Equal("cn=alice,dc=example,dc=org", res.Entries[0].DN)
+		assert.Equal([]string{"alice"}, res.Entries[0].GetAttributeValues("cn"))
+	}
+	assert.Equal(clients, n)
+
+	// searches that aren't in flight call the handler
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	_, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(cn=alice)", nil, nil))
+	require.NoError(err)
+	assert.Equal(int64(2), calls.Load())
+}
+
+func Test_searchFlightKey(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	search := func(filter string, controls ...Control) *Request {
+		return &Request{message: &SearchMessage{BaseDN: "dc=example,dc=org", Filter: filter, Controls: controls}}
+	}
+	alice, ok := searchFlightKey(search("(cn=alice)"))
+	assert.True(ok)
+	again, _ := searchFlightKey(search("(cn=alice)"))
+	assert.Equal(alice, again)
+	bob, _ := searchFlightKey(search("(cn=bob)"))
+	assert.NotEqual(alice, bob)
+
+	_, ok = searchFlightKey(search("(cn=alice)", &ControlManageDsaIT{}))
+	assert.False(ok)
+	_, ok = searchFlightKey(&Request{message: &DeleteMessage{}})
+	assert.False(ok)
+}
+
+func TestMux_singleflight_interceptors(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	const clients = 5
+	var arrived atomic.Int64
+	release := make(chan struct{})
+	mux, err := NewMux()
+	require.NoError(err)
+	// every request's responses are redacted by its own interceptor, which
+	// modifies the replayed entries concurrently (run with -race)
+	mux.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w *ResponseWriter, r *Request) {
+			arrived.Add(1)
+			next(w.WithInterceptor(func(resp Response) Response {
+				if e, ok := resp.(*SearchResponseEntry); ok {
+					e.RemoveAttribute("userPassword")
+					e.SetAttribute("description", []string{fmt.Sprintf("request %d", r.ID)})
+				}
+				return resp
+			}), r)
+		}
+	})
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		<-release
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org",
+			WithAttribute("userPassword", "secret"),
+			WithAttribute("cn", "alice"),
+			WithAttribute("mail", "alice@example.org"),
+		))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}, WithSingleflight()))
+	_, url := testServer(t, mux)
+
+	var wg sync.WaitGroup
+	results := make(chan *ldap.SearchResult, clients)
+	for i := 0; i < clients; i++ {
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(cn=alice)", nil, nil))
+			if err == nil {
+				results <- res
+			}
+		}()
+	}
+	for arrived.Load() < clients {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	var n int
+	for res := range results {
+		n++
+		require.Len(res.Entries, 1)
+		e := res.Entries[0]
+		assert.Empty(e.GetAttributeValues("userPassword"))
+		assert.Equal([]string{"alice"}, e.GetAttributeValues("cn"))
+		assert.Equal([]string{"alice@example.org"}, e.GetAttributeValues("mail"))
+		assert.Len(e.GetAttributeValues("description"), 1)
+		assert.Len(e.Attributes, 3)
+	}
+	assert.Equal(clients, n)
+}
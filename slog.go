@@ -0,0 +1,39 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+//go:build go1.21
+
+package gldap
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// slogLogger adapts a *slog.Logger to a Logger, whose logging methods are
+// the *slog.Logger's
+type slogLogger struct {
+	*slog.Logger
+}
+
+// NewSlogLogger returns a Logger which logs with the *slog.Logger, so a server
+// can use the standard library's structured logging (see: WithLogger).  A nil
+// *slog.Logger is replaced by slog.Default().
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{Logger: l}
+}
+
+// IsDebug returns true if the logger's handler is enabled for the debug level
+func (l slogLogger) IsDebug() bool {
+	return l.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// defaultLogger returns the logger of a server without one (see:
+// WithLogger), which logs errors to stderr.
+func defaultLogger() Logger {
+	return NewSlogLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+}
@@ -0,0 +1,44 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+//go:build go1.21
+
+package gldap
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	buf := testSafeBuf(t)
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	assert.True(l.IsDebug())
+	assert.False(NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))).IsDebug())
+	assert.NotNil(NewSlogLogger(nil))
+	// the default logger only logs errors
+	assert.False(defaultLogger().IsDebug())
+
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux, WithLogger(l))
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	require.NoError(client.Bind("cn=alice", "password"))
+
+	// the server logs with the slog logger, including its packet dumps
+	logged := buf.String()
+	assert.Contains(logged, "msg=listening")
+	assert.Contains(logged, `msg="packet read"`)
+	assert.Contains(logged, "cn=alice")
+}
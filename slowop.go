@@ -0,0 +1,50 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import "time"
+
+// SlowOperationFunc is called with the record of an operation that took longer
+// than the server's slow operation threshold (see: WithSlowOperationFunc)
+type SlowOperationFunc func(AccessLogRecord)
+
+// WithSlowOperationThreshold enables the server's slow operation log, which
+// logs a warning with the details of every operation that takes longer than
+// d, from receiving its request until writing its final response, which helps
+// diagnose sluggish backends behind search routes.  A threshold less than or
+// equal to zero is ignored.
+func WithSlowOperationThreshold(d time.Duration) Option {
+	return serverOption(func(o *configOptions) {
+		if d > 0 {
+			o.withSlowOpThreshold = d
+		}
+	})
+}
+
+// WithSlowOperationFunc sets a func which is called with the record of every
+// operation that takes longer than the server's slow operation threshold
+// (see: WithSlowOperationThreshold), along with logging its warning (i.e. to
+// count them or capture a profile).  The func is called synchronously before
+// the operation's final response is written, so it must be safe for
+// concurrent use and it shouldn't block.
+func WithSlowOperationFunc(fn SlowOperationFunc) Option {
+	return serverOption(func(o *configOptions) {
+		o.withSlowOpFn = fn
+	})
+}
+
+// checkSlowOperation logs a warning and calls the conn's slow operation func
+// when the request took longer than the conn's slow operation threshold, with
+// the request's final response, which is nil for the operations without a
+// response.
+func (c *conn) checkSlowOperation(r *Request, resp Response) {
+	if c.slowOpThreshold <= 0 || r == nil || c.clock.Now().Sub(r.received) <= c.slowOpThreshold {
+		return
+	}
+	rec := c.accessLogRecord(r, resp)
+	c.logger.Warn("slow operation", append(rec.logArgs(), "threshold", c.slowOpThreshold)...)
+	if c.slowOpFn != nil {
+		c.slowOpFn(rec)
+	}
+}
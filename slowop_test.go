@@ -0,0 +1,59 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WithSlowOperationThreshold(t *testing.T) {
+	t.Parallel()
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		// a sluggish backend
+		clock.Advance(2 * time.Second)
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	slow := make(chan AccessLogRecord, 2)
+	buf := testSafeBuf(t)
+	_, url := testServer(t, mux,
+		WithClock(clock),
+		WithSlowOperationThreshold(time.Second),
+		WithSlowOperationFunc(func(r AccessLogRecord) { slow <- r }),
+		WithLogger(hclog.New(&hclog.LoggerOptions{Output: buf, Level: hclog.Warn})),
+	)
+
+	assert, require := assert.New(t), require.New(t)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+	require.NoError(client.Bind("cn=alice", "password"))
+	_, err = client.Search(ldap.NewSearchRequest("ou=people,dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=bob)", nil, nil))
+	require.NoError(err)
+
+	// only the search is slow
+	rec := <-slow
+	assert.Len(slow, 0)
+	assert.Equal(SearchRouteOperation, rec.Operation)
+	assert.Equal(2*time.Second, rec.Duration)
+	assert.Equal("cn=alice", rec.BoundDN)
+	assert.Equal("ou=people,dc=example,dc=org", rec.DN)
+	assert.Equal("(uid=bob)", rec.Filter)
+	assert.Equal(ResultSuccess, rec.ResultCode)
+	assert.Contains(buf.String(), "slow operation")
+	assert.Contains(buf.String(), `filter="(uid=bob)"`)
+	assert.Contains(buf.String(), "threshold=1s")
+	assert.NotContains(buf.String(), "operation=bind")
+}
@@ -0,0 +1,60 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// isTLS returns true if the conn is already secured by TLS, either because it
+// was accepted by a TLS listener or a StartTLS request was completed.
+func (c *conn) isTLS() bool {
+	return c.secured.Load()
+}
+
+// setTLSState records the state of the conn's completed TLS handshake (see:
+// Request.ConnectionTLSState)
+func (c *conn) setTLSState(tlsConn *tls.Conn) {
+	state := tlsConn.ConnectionState()
+	c.tlsState.Store(&state)
+}
+
+// serveStartTLS responds to a StartTLS request and negotiates TLS using the
+// server's StartTLS configuration (see: WithStartTLS).  A StartTLS request on a
+// conn that's already secured, or that has outstanding requests, is rejected
+// with an operationsError (see:
+// https://tools.ietf.org/html/rfc4511#section-4.14.1).  An error is returned
+// when the handshake fails, since the conn can't be used once the client has
+// started its handshake, and the conn must then be closed.
+func (c *conn) serveStartTLS(w *ResponseWriter, r *Request) error {
+	const op = "gldap.(Conn).serveStartTLS"
+	res := r.NewExtendedResponse(WithResponseCode(ResultSuccess))
+	res.SetResponseName(ExtendedOperationStartTLS)
+	var diagMsg string
+	switch {
+	case c.isTLS():
+		diagMsg = "TLS already established"
+	case c.hasInFlightRequests():
+		diagMsg = "outstanding requests"
+	}
+	if diagMsg != "" {
+		res.SetResultCode(ResultOperationsError)
+		res.SetDiagnosticMessage(diagMsg)
+		if err := w.Write(res); err != nil {
+			c.logger.Error("unable to write response", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "err", err)
+		}
+		return nil
+	}
+	if err := w.Write(res); err != nil {
+		c.logger.Error("unable to write response", "op", op, "conn", c.connID, "requestID", r.ID, "correlationID", r.CorrelationID(), "err", err)
+		return nil
+	}
+	if err := r.StartTLS(c.startTLSConfig); err != nil {
+		// the success response has been written, so the client has started
+		// its handshake and there's no way to report the error to it.
+		return fmt.Errorf("%s: unable to start tls: %w", op, err)
+	}
+	return nil
+}
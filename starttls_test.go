@@ -0,0 +1,219 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WithStartTLS(t *testing.T) {
+	t.Parallel()
+	srvTLS, clientTLS := testdirectory.GetTLSConfig(t)
+	clientTLS.ServerName = "localhost"
+	searching := make(chan struct{}, 1)
+
+	startServer := func(t *testing.T, opt ...gldap.ServerOption) int {
+		t.Helper()
+		require := require.New(t)
+		s, err := gldap.NewServer(gldap.WithStartTLS(srvTLS))
+		require.NoError(err)
+		mux, err := gldap.NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+			_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+		}))
+		require.NoError(mux.ExtendedOperation(func(w *gldap.ResponseWriter, r *gldap.Request) {
+			_ = w.Write(r.NewExtendedResponse(gldap.WithResponseCode(gldap.ResultUnwillingToPerform)))
+		}, gldap.ExtendedOperationStartTLS))
+		require.NoError(mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+			searching <- struct{}{}
+			<-r.Context().Done()
+		}))
+		require.NoError(s.Router(mux))
+		port := testdirectory.FreePort(t)
+		go func() { _ = s.Run(fmt.Sprintf(":%d", port), opt...) }()
+		t.Cleanup(func() { _ = s.Stop() })
+		for !s.Ready() {
+			time.Sleep(100 * time.Nanosecond)
+		}
+		return port
+	}
+
+	t.Run("start-tls", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		port := startServer(t)
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://localhost:%d", port))
+		require.NoError(err)
+		defer client.Close()
+
+		// the server negotiates tls rather than routing to the handler
+		require.NoError(client.StartTLS(clientTLS))
+		_, ok := client.TLSConnectionState()
+		assert.True(ok)
+		require.NoError(client.Bind("cn=alice", "password"))
+	})
+	startTLSRequest := func() []byte {
+		envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+		envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 1, "MessageID"))
+		request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, gldap.ApplicationExtendedRequest, nil, "Start TLS")
+		request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, string(gldap.ExtendedOperationStartTLS), "TLS Extended Command"))
+		envelope.AppendChild(request)
+		return envelope.Bytes()
+	}
+	t.Run("outstanding-requests", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		port := startServer(t)
+		c, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		require.NoError(err)
+		defer c.Close()
+
+		// go-ldap refuses to send a StartTLS request with
+		// outstanding requests, so the search is encoded by hand
+		filter, err := ldap.CompileFilter("(objectClass=*)")
+		require.NoError(err)
+		envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+		envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 2, "MessageID"))
+		search := ber.Encode(ber.ClassApplication, ber.TypeConstructed, gldap.ApplicationSearchRequest, nil, "Search Request")
+		search.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "dc=example,dc=org", "Base DN"))
+		search.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, ldap.ScopeWholeSubtree, "Scope"))
+		search.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, ldap.NeverDerefAliases, "Deref Aliases"))
+		search.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 0, "Size Limit"))
+		search.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 0, "Time Limit"))
+		search.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, false, "Types Only"))
+		search.AppendChild(filter)
+		search.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+		envelope.AppendChild(search)
+		_, err = c.Write(envelope.Bytes())
+		require.NoError(err)
+		<-searching
+
+		_, err = c.Write(startTLSRequest())
+		require.NoError(err)
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.Len(p.Children, 2)
+		assert.Equal(int64(1), p.Children[0].Value.(int64))
+		require.NotEmpty(p.Children[1].Children)
+		assert.Equal(int64(gldap.ResultOperationsError), p.Children[1].Children[0].Value.(int64))
+	})
+	t.Run("handshake-failure", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		port := startServer(t)
+		c, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		require.NoError(err)
+		defer c.Close()
+		_, err = c.Write(startTLSRequest())
+		require.NoError(err)
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.Len(p.Children, 2)
+		assert.Equal(int64(gldap.ResultSuccess), p.Children[1].Children[0].Value.(int64))
+
+		// the conn is closed once the handshake fails, rather than reading
+		// requests from it
+		_, err = c.Write([]byte("not a tls client hello\r\n"))
+		require.NoError(err)
+		_, err = io.ReadAll(c)
+		assert.NoError(err)
+	})
+	t.Run("already-secured", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		port := startServer(t, gldap.WithTLSConfig(srvTLS))
+		c, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", port), clientTLS)
+		require.NoError(err)
+		defer c.Close()
+		_, err = c.Write(startTLSRequest())
+		require.NoError(err)
+
+		require.NoError(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		p, err := ber.ReadPacket(c)
+		require.NoError(err)
+		require.Len(p.Children, 2)
+		assert.Equal(ber.Tag(gldap.ApplicationExtendedResponse), p.Children[1].Tag)
+		require.NotEmpty(p.Children[1].Children)
+		assert.Equal(int64(gldap.ResultOperationsError), p.Children[1].Children[0].Value.(int64))
+	})
+}
+
+func TestRequest_ConnectionTLSState(t *testing.T) {
+	t.Parallel()
+	srvTLS, clientTLS := testdirectory.GetTLSConfig(t, testdirectory.WithMTLS(t))
+	clientTLS.ServerName = "localhost"
+	states := make(chan *tls.ConnectionState, 1)
+
+	startServer := func(t *testing.T, opt ...gldap.ServerOption) int {
+		t.Helper()
+		require := require.New(t)
+		s, err := gldap.NewServer(gldap.WithStartTLS(srvTLS))
+		require.NoError(err)
+		mux, err := gldap.NewMux()
+		require.NoError(err)
+		require.NoError(mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+			states <- r.ConnectionTLSState()
+			_ = w.Write(r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+		}))
+		require.NoError(s.Router(mux))
+		port := testdirectory.FreePort(t)
+		go func() { _ = s.Run(fmt.Sprintf(":%d", port), opt...) }()
+		t.Cleanup(func() { _ = s.Stop() })
+		for !s.Ready() {
+			time.Sleep(100 * time.Nanosecond)
+		}
+		return port
+	}
+	assertClientCert := func(t *testing.T, state *tls.ConnectionState) {
+		t.Helper()
+		assert, require := assert.New(t), require.New(t)
+		require.NotNil(state)
+		assert.True(state.HandshakeComplete)
+		assert.NotZero(state.Version)
+		assert.NotZero(state.CipherSuite)
+		require.NotEmpty(state.PeerCertificates)
+		assert.Equal(clientTLS.Certificates[0].Certificate[0], state.PeerCertificates[0].Raw)
+		assert.NotEmpty(state.VerifiedChains)
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		require := require.New(t)
+		port := startServer(t)
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://localhost:%d", port))
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(client.Bind("cn=alice", "password"))
+		assert.Nil(t, <-states)
+	})
+	t.Run("start-tls", func(t *testing.T) {
+		require := require.New(t)
+		port := startServer(t)
+		client, err := ldap.DialURL(fmt.Sprintf("ldap://localhost:%d", port))
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(client.StartTLS(clientTLS))
+		require.NoError(client.Bind("cn=alice", "password"))
+		assertClientCert(t, <-states)
+	})
+	t.Run("tls-listener", func(t *testing.T) {
+		require := require.New(t)
+		port := startServer(t, gldap.WithTLSConfig(srvTLS))
+		client, err := ldap.DialURL(fmt.Sprintf("ldaps://localhost:%d", port), ldap.DialWithTLSConfig(clientTLS))
+		require.NoError(err)
+		defer client.Close()
+		require.NoError(client.Bind("cn=alice", "password"))
+		assertClientCert(t, <-states)
+	})
+}
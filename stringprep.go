@@ -0,0 +1,113 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// caseFolder folds the case of prepared strings, which is stateless and safe
+// for concurrent use.
+var caseFolder = cases.Fold()
+
+// prepareString prepares the value for matching with the string preparation
+// algorithm of https://tools.ietf.org/html/rfc4518#section-2: the code points
+// which are mapped to nothing are removed and the other control, separator
+// and whitespace code points are mapped to a space, the value is normalized
+// to Unicode Form KC, its case is folded when caseFold is true (i.e.
+// CaseIgnoreMatch), and insignificant spaces are removed (leading, trailing
+// and repeated spaces).  Invalid UTF-8 sequences are kept as-is, so values
+// which aren't UTF-8 only match themselves.
+func prepareString(value string, caseFold bool) string {
+	if !utf8.ValidString(value) {
+		return value
+	}
+	mapped := strings.Map(mapRune, value)
+	mapped = norm.NFKC.String(mapped)
+	if caseFold {
+		// folding may produce code points that must be normalized again
+		// (see: https://tools.ietf.org/html/rfc4518#section-2.3)
+		mapped = norm.NFKC.String(caseFolder.String(mapped))
+	}
+	return strings.Join(strings.Fields(mapped), " ")
+}
+
+// mapRune is the mapping of https://tools.ietf.org/html/rfc4518#section-2.2,
+// which returns -1 for the code points that are mapped to nothing.
+func mapRune(r rune) rune {
+	switch {
+	case r == '\t', r == '\n', r == '\v', r == '\f', r == '\r', r == 0x85:
+		return ' '
+	case r == 0xAD, r == 0x1806, r == 0x034F, r >= 0x180B && r <= 0x180D,
+		r >= 0xFE00 && r <= 0xFE0F, r == 0xFFFC, r == 0x200B:
+		return -1
+	case unicode.Is(unicode.Cc, r), unicode.Is(unicode.Cf, r):
+		return -1
+	case unicode.Is(unicode.Zs, r), unicode.Is(unicode.Zl, r), unicode.Is(unicode.Zp, r):
+		return ' '
+	default:
+		return r
+	}
+}
+
+// invalidUTF8 returns the result code and diagnostic message of a response to
+// a request whose DNs or directory string values aren't valid UTF-8 (see:
+// https://tools.ietf.org/html/rfc4511#section-4.1.2), which is
+// ResultInvalidDNSyntax for a DN and ResultInvalidAttributeSyntax for the value
+// of an attribute which isn't compared with OctetStringMatch (see:
+// AttributeMatchingRule).  It returns ResultSuccess for a valid request.
+func (r *Request) invalidUTF8() (int, string) {
+	invalidDN := func(dn string) (int, string) {
+		return ResultInvalidDNSyntax, fmt.Sprintf("invalid UTF-8 in DN %q", dn)
+	}
+	invalidValue := func(attr string) (int, string) {
+		return ResultInvalidAttributeSyntax, fmt.Sprintf("invalid UTF-8 in value of attribute %q", attr)
+	}
+	if dn, ok := r.targetDN(); ok && !utf8.ValidString(dn) {
+		return invalidDN(dn)
+	}
+	switch m := r.message.(type) {
+	case *ModifyDNMessage:
+		switch {
+		case !utf8.ValidString(m.NewRDN):
+			return invalidDN(m.NewRDN)
+		case !utf8.ValidString(m.NewSuperior):
+			return invalidDN(m.NewSuperior)
+		}
+	case *AddMessage:
+		for _, a := range m.Attributes {
+			if AttributeMatchingRule(a.Type) == OctetStringMatch {
+				continue
+			}
+			for _, v := range a.Vals {
+				if !utf8.ValidString(v) {
+					return invalidValue(a.Type)
+				}
+			}
+		}
+	case *ModifyMessage:
+		for _, ch := range m.Changes {
+			if AttributeMatchingRule(ch.Modification.Type) == OctetStringMatch {
+				continue
+			}
+			vals, err := decodeModificationValues(ch.Modification.Vals)
+			if err != nil {
+				// the handler responds to a change it can't decode
+				continue
+			}
+			for _, v := range vals {
+				if !utf8.ValidString(v) {
+					return invalidValue(ch.Modification.Type)
+				}
+			}
+		}
+	}
+	return ResultSuccess, ""
+}
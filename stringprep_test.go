@@ -0,0 +1,140 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_prepareString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		value    string
+		caseFold bool
+		want     string
+	}{
+		{name: "ascii", value: " Alice  Smith ", want: "Alice Smith"},
+		{name: "ascii-fold", value: "Alice Smith", caseFold: true, want: "alice smith"},
+		{name: "composed-accents", value: "José Ångström", caseFold: true, want: "josé ångström"},
+		{name: "decomposed-accents", value: "Jose\u0301 A\u030Angstro\u0308m", caseFold: true, want: "jos\u00e9 \u00e5ngstr\u00f6m"},
+		{name: "cjk", value: "山田 太郎", want: "山田 太郎"},
+		{name: "fullwidth", value: "Ａｌｉｃｅ", caseFold: true, want: "alice"},
+		{name: "halfwidth-katakana", value: "ｶﾀｶﾅ", want: "カタカナ"},
+		{name: "sharp-s-fold", value: "Straße", caseFold: true, want: "strasse"},
+		{name: "kelvin-sign-fold", value: "\u212a", caseFold: true, want: "k"},
+		{name: "mapped-to-nothing", value: "Al\u00adi\u200bce\ufeff", want: "Alice"},
+		{name: "mapped-to-space", value: "Alice\u00a0\tSmith\u3000", want: "Alice Smith"},
+		{name: "invalid-utf8", value: "Al\xffice", caseFold: true, want: "Al\xffice"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, prepareString(tc.value, tc.caseFold))
+		})
+	}
+}
+
+func TestEntry_MatchFilter_i18n(t *testing.T) {
+	t.Parallel()
+	e := NewEntry("cn=José,dc=example,dc=org", map[string][]string{
+		"cn":          {"José Ångström"},
+		"displayName": {"山田 太郎"},
+		"description": {"Ａｌｉｃｅ"},
+	})
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{filter: "(cn=josé ångström)", want: true},
+		{filter: "(cn=José Ångström)", want: true},
+		{filter: "(cn=jose angstrom)"},
+		{filter: "(cn=JOSÉ*)", want: true},
+		{filter: "(cn=*NGSTRÖM)", want: true},
+		{filter: "(displayName=山田 太郎)", want: true},
+		{filter: "(displayName=山田*)", want: true},
+		{filter: "(description=alice)", want: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.filter, func(t *testing.T) {
+			t.Parallel()
+			got, err := e.MatchFilter(tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNormalizeDN_i18n(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	a, err := NormalizeDN("CN=José,OU=営業部,DC=Example,DC=Org", nil)
+	require.NoError(err)
+	b, err := NormalizeDN("cn=josé,ou=営業部,dc=example,dc=org", nil)
+	require.NoError(err)
+	assert.Equal(a, b)
+}
+
+func TestServer_invalidUTF8(t *testing.T) {
+	t.Parallel()
+	mux, err := NewMux()
+	require.NoError(t, err)
+	success := func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.resultResponse(ResultSuccess, ""))
+	}
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Add(success))
+	require.NoError(t, mux.Modify(success))
+	require.NoError(t, mux.Delete(success))
+	_, url := testServer(t, mux)
+	client, err := ldap.DialURL(url)
+	require.NoError(t, err)
+	defer client.Close()
+
+	newAdd := func(dn, attr, value string) *ldap.AddRequest {
+		add := ldap.NewAddRequest(dn, nil)
+		add.Attribute("objectClass", []string{"person"})
+		add.Attribute(attr, []string{value})
+		return add
+	}
+	newModify := func(dn, attr, value string) *ldap.ModifyRequest {
+		mod := ldap.NewModifyRequest(dn, nil)
+		mod.Replace(attr, []string{value})
+		return mod
+	}
+	tests := []struct {
+		name     string
+		fn       func() error
+		wantCode uint16
+	}{
+		{name: "bind-dn", fn: func() error { return client.Bind("cn=Jos\xe9,dc=example,dc=org", "password") }, wantCode: ResultInvalidDNSyntax},
+		{name: "bind-i18n", fn: func() error { return client.Bind("cn=José,dc=example,dc=org", "password") }},
+		{name: "add-dn", fn: func() error { return client.Add(newAdd("cn=Jos\xe9,dc=example,dc=org", "sn", "Smith")) }, wantCode: ResultInvalidDNSyntax},
+		{name: "add-value", fn: func() error { return client.Add(newAdd("cn=alice,dc=example,dc=org", "sn", "Jos\xe9")) }, wantCode: ResultInvalidAttributeSyntax},
+		{name: "add-binary-value", fn: func() error { return client.Add(newAdd("cn=alice,dc=example,dc=org", "jpegPhoto", "\xff\xd8\xff")) }},
+		{name: "add-i18n", fn: func() error { return client.Add(newAdd("cn=山田,dc=example,dc=org", "sn", "山田")) }},
+		{name: "modify-value", fn: func() error { return client.Modify(newModify("cn=alice,dc=example,dc=org", "sn", "Jos\xe9")) }, wantCode: ResultInvalidAttributeSyntax},
+		{name: "modify-binary-value", fn: func() error { return client.Modify(newModify("cn=alice,dc=example,dc=org", "userPassword", "\xff")) }},
+		{name: "delete-dn", fn: func() error { return client.Del(ldap.NewDelRequest("cn=Jos\xe9,dc=example,dc=org", nil)) }, wantCode: ResultInvalidDNSyntax},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.fn()
+			if tc.wantCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, ldap.IsErrorWithCode(err, tc.wantCode), err.Error())
+		})
+	}
+}
@@ -0,0 +1,498 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// IntermediateResponseSyncInfo is the response name of a sync info message
+// intermediate response (see: https://tools.ietf.org/html/rfc4533#section-2.5)
+const IntermediateResponseSyncInfo = "1.3.6.1.4.1.4203.1.9.1.4"
+
+// SyncRequestMode is the mode of a content synchronization request (see:
+// https://tools.ietf.org/html/rfc4533#section-2.2)
+type SyncRequestMode int64
+
+const (
+	// SyncRequestModeRefreshOnly requests a refresh of the client's content
+	SyncRequestModeRefreshOnly SyncRequestMode = 1
+
+	// SyncRequestModeRefreshAndPersist requests a refresh of the client's
+	// content followed by a stream of changes as they happen
+	SyncRequestModeRefreshAndPersist SyncRequestMode = 3
+)
+
+// SyncState is the state of an entry sent as part of a content
+// synchronization (see: https://tools.ietf.org/html/rfc4533#section-2.3)
+type SyncState int64
+
+const (
+	// SyncStatePresent indicates the entry is present in the content
+	SyncStatePresent SyncState = 0
+
+	// SyncStateAdd indicates the entry was added to the content
+	SyncStateAdd SyncState = 1
+
+	// SyncStateModify indicates the entry in the content was modified
+	SyncStateModify SyncState = 2
+
+	// SyncStateDelete indicates the entry was deleted from the content
+	SyncStateDelete SyncState = 3
+)
+
+// SyncStateMap contains human readable descriptions of sync states
+var SyncStateMap = map[SyncState]string{
+	SyncStatePresent: "present",
+	SyncStateAdd:     "add",
+	SyncStateModify:  "modify",
+	SyncStateDelete:  "delete",
+}
+
+// ControlSyncRequest implements the sync request control described in
+// https://tools.ietf.org/html/rfc4533#section-2.2
+type ControlSyncRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Mode of the content synchronization
+	Mode SyncRequestMode
+	// Cookie represents the client's current synchronization state
+	Cookie []byte
+	// ReloadHint indicates the client requests the server to send the full
+	// content instead of sending a delete/present phase
+	ReloadHint bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncRequest) GetControlType() string {
+	return ControlTypeSyncRequest
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncRequest) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncRequest, "Control Type ("+ControlTypeMap[ControlTypeSyncRequest]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Sync Request Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.Mode), "Mode"))
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	if c.ReloadHint {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ReloadHint, "Reload Hint"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Request)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Mode: %d  Cookie: %q  ReloadHint: %t",
+		ControlTypeMap[ControlTypeSyncRequest],
+		ControlTypeSyncRequest,
+		c.Criticality,
+		c.Mode,
+		string(c.Cookie),
+		c.ReloadHint)
+}
+
+// NewControlSyncRequest returns a sync request control.  Supported options:
+// WithCriticality, WithCookie and WithReloadHint
+func NewControlSyncRequest(mode SyncRequestMode, opt ...Option) (*ControlSyncRequest, error) {
+	const op = "gldap.NewControlSyncRequest"
+	switch mode {
+	case SyncRequestModeRefreshOnly, SyncRequestModeRefreshAndPersist:
+	default:
+		return nil, fmt.Errorf("%s: invalid sync request mode %d: %w", op, mode, ErrInvalidParameter)
+	}
+	opts := getControlOpts(opt...)
+	return &ControlSyncRequest{
+		Criticality: opts.withCriticality,
+		Mode:        mode,
+		Cookie:      opts.withCookie,
+		ReloadHint:  opts.withReloadHint,
+	}, nil
+}
+
+// ControlSyncState implements the sync state control described in
+// https://tools.ietf.org/html/rfc4533#section-2.3
+type ControlSyncState struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// State of the entry
+	State SyncState
+	// EntryUUID of the entry
+	EntryUUID [16]byte
+	// Cookie is the optional updated synchronization state
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncState) GetControlType() string {
+	return ControlTypeSyncState
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncState) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncState, "Control Type ("+ControlTypeMap[ControlTypeSyncState]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Sync State Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.State), "State"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.EntryUUID[:]), "EntryUUID"))
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync State)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncState) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  State: %s  EntryUUID: %x  Cookie: %q",
+		ControlTypeMap[ControlTypeSyncState],
+		ControlTypeSyncState,
+		c.Criticality,
+		SyncStateMap[c.State],
+		c.EntryUUID,
+		string(c.Cookie))
+}
+
+// NewControlSyncState returns a sync state control for an entry.  Supported
+// options: WithCriticality and WithCookie
+func NewControlSyncState(state SyncState, entryUUID [16]byte, opt ...Option) (*ControlSyncState, error) {
+	const op = "gldap.NewControlSyncState"
+	if _, ok := SyncStateMap[state]; !ok {
+		return nil, fmt.Errorf("%s: invalid sync state %d: %w", op, state, ErrInvalidParameter)
+	}
+	opts := getControlOpts(opt...)
+	return &ControlSyncState{
+		Criticality: opts.withCriticality,
+		State:       state,
+		EntryUUID:   entryUUID,
+		Cookie:      opts.withCookie,
+	}, nil
+}
+
+// ControlSyncDone implements the sync done control described in
+// https://tools.ietf.org/html/rfc4533#section-2.4
+type ControlSyncDone struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Cookie is the optional updated synchronization state
+	Cookie []byte
+	// RefreshDeletes indicates the refresh phase used a delete phase (rather
+	// than a present phase)
+	RefreshDeletes bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncDone) GetControlType() string {
+	return ControlTypeSyncDone
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncDone) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncDone, "Control Type ("+ControlTypeMap[ControlTypeSyncDone]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Sync Done Value")
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	if c.RefreshDeletes {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.RefreshDeletes, "Refresh Deletes"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Done)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncDone) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Cookie: %q  RefreshDeletes: %t",
+		ControlTypeMap[ControlTypeSyncDone],
+		ControlTypeSyncDone,
+		c.Criticality,
+		string(c.Cookie),
+		c.RefreshDeletes)
+}
+
+// NewControlSyncDone returns a sync done control which is sent with the search
+// result done response at the end of a refresh.  Supported options:
+// WithCriticality, WithCookie and WithRefreshDeletes
+func NewControlSyncDone(opt ...Option) (*ControlSyncDone, error) {
+	opts := getControlOpts(opt...)
+	return &ControlSyncDone{
+		Criticality:    opts.withCriticality,
+		Cookie:         opts.withCookie,
+		RefreshDeletes: opts.withRefreshDeletes,
+	}, nil
+}
+
+// decodeControlValueSequence returns the sequence encoded in a control's value
+func decodeControlValueSequence(value *ber.Packet) (*ber.Packet, error) {
+	const op = "gldap.decodeControlValueSequence"
+	if value == nil {
+		return nil, fmt.Errorf("%s: missing control value: %w", op, ErrInvalidParameter)
+	}
+	seq, err := ber.DecodePacketErr(value.Data.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to decode data bytes: %w", op, err)
+	}
+	if seq.Tag != ber.TagSequence {
+		return nil, fmt.Errorf("%s: control value is not a sequence: %w", op, ErrInvalidParameter)
+	}
+	return seq, nil
+}
+
+func decodeControlSyncRequest(value *ber.Packet, criticality bool) (*ControlSyncRequest, error) {
+	const op = "gldap.decodeControlSyncRequest"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) == 0 || len(seq.Children) > 3 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in sync request control: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	mode, ok := seq.Children[0].Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid sync request mode: %w", op, ErrInvalidParameter)
+	}
+	opts := []Option{WithCriticality(criticality)}
+	for _, child := range seq.Children[1:] {
+		switch child.Tag {
+		case ber.TagOctetString:
+			opts = append(opts, WithCookie(child.Data.Bytes()))
+		case ber.TagBoolean:
+			hint, _ := child.Value.(bool)
+			opts = append(opts, WithReloadHint(hint))
+		default:
+			return nil, fmt.Errorf("%s: unexpected tag %d in sync request control: %w", op, child.Tag, ErrInvalidParameter)
+		}
+	}
+	c, err := NewControlSyncRequest(SyncRequestMode(mode), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+func decodeControlSyncState(value *ber.Packet, criticality bool) (*ControlSyncState, error) {
+	const op = "gldap.decodeControlSyncState"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) < 2 || len(seq.Children) > 3 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in sync state control: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	state, ok := seq.Children[0].Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid sync state: %w", op, ErrInvalidParameter)
+	}
+	var entryUUID [16]byte
+	if n := copy(entryUUID[:], seq.Children[1].Data.Bytes()); n != len(entryUUID) || seq.Children[1].Data.Len() != len(entryUUID) {
+		return nil, fmt.Errorf("%s: invalid entryUUID length: %w", op, ErrInvalidParameter)
+	}
+	opts := []Option{WithCriticality(criticality)}
+	if len(seq.Children) == 3 {
+		opts = append(opts, WithCookie(seq.Children[2].Data.Bytes()))
+	}
+	c, err := NewControlSyncState(SyncState(state), entryUUID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+func decodeControlSyncDone(value *ber.Packet, criticality bool) (*ControlSyncDone, error) {
+	const op = "gldap.decodeControlSyncDone"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := []Option{WithCriticality(criticality)}
+	for _, child := range seq.Children {
+		switch child.Tag {
+		case ber.TagOctetString:
+			opts = append(opts, WithCookie(child.Data.Bytes()))
+		case ber.TagBoolean:
+			refreshDeletes, _ := child.Value.(bool)
+			opts = append(opts, WithRefreshDeletes(refreshDeletes))
+		default:
+			return nil, fmt.Errorf("%s: unexpected tag %d in sync done control: %w", op, child.Tag, ErrInvalidParameter)
+		}
+	}
+	c, err := NewControlSyncDone(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+// SyncInfoType is the type of sync info message (see:
+// https://tools.ietf.org/html/rfc4533#section-2.5)
+type SyncInfoType int
+
+const (
+	// SyncInfoNewCookie provides the client with a new cookie
+	SyncInfoNewCookie SyncInfoType = 0
+
+	// SyncInfoRefreshDelete indicates the end of a delete phase
+	SyncInfoRefreshDelete SyncInfoType = 1
+
+	// SyncInfoRefreshPresent indicates the end of a present phase
+	SyncInfoRefreshPresent SyncInfoType = 2
+
+	// SyncInfoSyncIDSet provides a set of entryUUIDs for entries which are
+	// either present or deleted
+	SyncInfoSyncIDSet SyncInfoType = 3
+)
+
+// SyncInfo is a sync info message which is sent to a client in an
+// intermediate response during a content synchronization (see:
+// https://tools.ietf.org/html/rfc4533#section-2.5)
+type SyncInfo struct {
+	// Type of the sync info message
+	Type SyncInfoType
+
+	// Cookie is the updated synchronization state, which is required for
+	// SyncInfoNewCookie and optional for every other type.
+	Cookie []byte
+
+	// RefreshDone indicates the refresh phase is complete (only used for
+	// SyncInfoRefreshDelete and SyncInfoRefreshPresent)
+	RefreshDone bool
+
+	// RefreshDeletes indicates the SyncUUIDs are deleted entries, rather than
+	// present entries (only used for SyncInfoSyncIDSet)
+	RefreshDeletes bool
+
+	// SyncUUIDs is the set of entryUUIDs (only used for SyncInfoSyncIDSet)
+	SyncUUIDs [][16]byte
+}
+
+// encode returns the ber encoded syncInfoValue
+func (s *SyncInfo) encode() (*ber.Packet, error) {
+	const op = "gldap.(SyncInfo).encode"
+	switch s.Type {
+	case SyncInfoNewCookie:
+		if s.Cookie == nil {
+			return nil, fmt.Errorf("%s: missing cookie: %w", op, ErrInvalidParameter)
+		}
+		return ber.NewString(ber.ClassContext, ber.TypePrimitive, ber.Tag(s.Type), string(s.Cookie), "newcookie"), nil
+	case SyncInfoRefreshDelete, SyncInfoRefreshPresent:
+		desc := "refreshDelete"
+		if s.Type == SyncInfoRefreshPresent {
+			desc = "refreshPresent"
+		}
+		p := ber.Encode(ber.ClassContext, ber.TypeConstructed, ber.Tag(s.Type), nil, desc)
+		if s.Cookie != nil {
+			p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(s.Cookie), "Cookie"))
+		}
+		// refreshDone defaults to TRUE
+		if !s.RefreshDone {
+			p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, s.RefreshDone, "Refresh Done"))
+		}
+		return p, nil
+	case SyncInfoSyncIDSet:
+		p := ber.Encode(ber.ClassContext, ber.TypeConstructed, ber.Tag(s.Type), nil, "syncIdSet")
+		if s.Cookie != nil {
+			p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(s.Cookie), "Cookie"))
+		}
+		if s.RefreshDeletes {
+			p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, s.RefreshDeletes, "Refresh Deletes"))
+		}
+		uuids := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "syncUUIDs")
+		for _, u := range s.SyncUUIDs {
+			uuids.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(u[:]), "syncUUID"))
+		}
+		p.AppendChild(uuids)
+		return p, nil
+	default:
+		return nil, fmt.Errorf("%s: unknown sync info type %d: %w", op, s.Type, ErrInvalidParameter)
+	}
+}
+
+// GetSyncRequestControl returns the request's sync request control (see:
+// https://tools.ietf.org/html/rfc4533#section-2.2) and false if the request
+// doesn't have one.
+func (r *Request) GetSyncRequestControl() (*ControlSyncRequest, bool) {
+	c, ok := r.findControl(ControlTypeSyncRequest).(*ControlSyncRequest)
+	return c, ok
+}
+
+// WriteSyncEntry writes a search result entry with a sync state control to the
+// client as part of a content synchronization.  Entries with a state of
+// SyncStatePresent or SyncStateDelete should only include the entry's DN.  The
+// sync search is finished by writing a SearchResponseDone with a
+// ControlSyncDone (see: NewControlSyncDone), however entries may continue to be
+// written after the refresh phase when the request's mode is
+// SyncRequestModeRefreshAndPersist.
+//
+// Supported options: WithCookie
+func (rw *ResponseWriter) WriteSyncEntry(e *Entry, state SyncState, entryUUID [16]byte, opt ...Option) error {
+	const op = "gldap.(ResponseWriter).WriteSyncEntry"
+	if e == nil {
+		return fmt.Errorf("%s: missing entry: %w", op, ErrInvalidParameter)
+	}
+	opts := getControlOpts(opt...)
+	c, err := NewControlSyncState(state, entryUUID, WithCookie(opts.withCookie))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp := &SearchResponseEntry{
+		baseResponse: &baseResponse{
+			messageID: rw.messageID(),
+		},
+		entry: Entry{
+			DN:         e.DN,
+			Attributes: e.Attributes,
+		},
+		controls: []Control{c},
+	}
+	if err := rw.Write(resp); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// WriteSyncInfo writes a sync info message intermediate response to the client
+// as part of a content synchronization.
+func (rw *ResponseWriter) WriteSyncInfo(info *SyncInfo) error {
+	const op = "gldap.(ResponseWriter).WriteSyncInfo"
+	if info == nil {
+		return fmt.Errorf("%s: missing sync info: %w", op, ErrInvalidParameter)
+	}
+	v, err := info.encode()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp := &IntermediateResponse{
+		messageID: rw.messageID(),
+		name:      IntermediateResponseSyncInfo,
+		value:     v.Bytes(),
+	}
+	if err := rw.Write(resp); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
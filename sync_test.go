@@ -0,0 +1,162 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlSyncRequest(t *testing.T) {
+	runControlTest(t,
+		testControlSyncRequest(t, SyncRequestModeRefreshAndPersist, WithCriticality(true), WithCookie([]byte("rid=001,csn=1")), WithReloadHint(true)),
+		withTestType(ControlTypeSyncRequest),
+		withTestToString("Control Type: Sync Request (\"1.3.6.1.4.1.4203.1.9.1.1\")  Criticality: true  Mode: 3  Cookie: \"rid=001,csn=1\"  ReloadHint: true"),
+	)
+	runControlTest(t, testControlSyncRequest(t, SyncRequestModeRefreshOnly))
+	runControlTest(t, testControlSyncRequest(t, SyncRequestModeRefreshOnly, WithReloadHint(true)))
+
+	_, err := NewControlSyncRequest(2)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestControlSyncState(t *testing.T) {
+	entryUUID := [16]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	runControlTest(t,
+		testControlSyncState(t, SyncStateModify, entryUUID, WithCookie([]byte("csn=2"))),
+		withTestType(ControlTypeSyncState),
+		withTestToString("Control Type: Sync State (\"1.3.6.1.4.1.4203.1.9.1.2\")  Criticality: false  State: modify  EntryUUID: 0102030405060708090a0b0c0d0e0f10  Cookie: \"csn=2\""),
+	)
+	runControlTest(t, testControlSyncState(t, SyncStateDelete, entryUUID))
+
+	_, err := NewControlSyncState(4, entryUUID)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestControlSyncDone(t *testing.T) {
+	runControlTest(t,
+		testControlSyncDone(t, WithCookie([]byte("csn=3")), WithRefreshDeletes(true)),
+		withTestType(ControlTypeSyncDone),
+		withTestToString("Control Type: Sync Done (\"1.3.6.1.4.1.4203.1.9.1.3\")  Criticality: false  Cookie: \"csn=3\"  RefreshDeletes: true"),
+	)
+	runControlTest(t, testControlSyncDone(t))
+	runControlTest(t, testControlSyncDone(t, WithRefreshDeletes(true)))
+}
+
+func TestSyncInfo_encode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		info            *SyncInfo
+		wantTag         int
+		wantChildren    int
+		wantErr         bool
+		wantErrContains string
+	}{
+		{name: "new-cookie", info: &SyncInfo{Type: SyncInfoNewCookie, Cookie: []byte("csn=1")}, wantTag: 0},
+		{name: "new-cookie-missing-cookie", info: &SyncInfo{Type: SyncInfoNewCookie}, wantErr: true, wantErrContains: "missing cookie"},
+		{name: "refresh-delete-done", info: &SyncInfo{Type: SyncInfoRefreshDelete, RefreshDone: true}, wantTag: 1},
+		{name: "refresh-present-not-done", info: &SyncInfo{Type: SyncInfoRefreshPresent, Cookie: []byte("csn=1")}, wantTag: 2, wantChildren: 2},
+		{name: "sync-id-set", info: &SyncInfo{Type: SyncInfoSyncIDSet, RefreshDeletes: true, SyncUUIDs: [][16]byte{{0x01}, {0x02}}}, wantTag: 3, wantChildren: 2},
+		{name: "unknown", info: &SyncInfo{Type: 4}, wantErr: true, wantErrContains: "unknown sync info type"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := tc.info.encode()
+			if tc.wantErr {
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tc.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(ber.ClassContext, got.ClassType)
+			assert.Equal(ber.Tag(tc.wantTag), got.Tag)
+			assert.Len(got.Children, tc.wantChildren)
+		})
+	}
+}
+
+func TestResponseWriter_Sync(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	aliceUUID := [16]byte{0xaa}
+	bobUUID := [16]byte{0xbb}
+
+	mux, err := NewMux()
+	require.NoError(err)
+	requestCh := make(chan *ControlSyncRequest, 1)
+	require.NoError(mux.Search(func(w *ResponseWriter, r *Request) {
+		c, ok := r.GetSyncRequestControl()
+		if !ok {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultUnwillingToPerform)))
+			return
+		}
+		requestCh <- c
+		_ = w.WriteSyncEntry(NewEntry("cn=alice,ou=people,dc=example,dc=org", map[string][]string{"cn": {"alice"}}), SyncStateAdd, aliceUUID)
+		_ = w.WriteSyncEntry(&Entry{DN: "cn=bob,ou=people,dc=example,dc=org"}, SyncStatePresent, bobUUID, WithCookie([]byte("csn=1")))
+		_ = w.WriteSyncInfo(&SyncInfo{Type: SyncInfoRefreshPresent, RefreshDone: true})
+		done := r.NewSearchDoneResponse(WithResponseCode(ResultSuccess))
+		syncDone, err := NewControlSyncDone(WithCookie([]byte("csn=2")))
+		if err != nil {
+			_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultOperationsError)))
+			return
+		}
+		done.SetControls(syncDone)
+		_ = w.Write(done)
+	}))
+	_, url := testServer(t, mux)
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp := client.Syncrepl(ctx, ldap.NewSearchRequest("ou=people,dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.DerefAlways, 0, 0, false, "(objectClass=*)", nil, nil), 0, ldap.SyncRequestModeRefreshOnly, []byte("csn=0"), false)
+
+	var entries []*ldap.Entry
+	var controls []ldap.Control
+	for resp.Next() {
+		if e := resp.Entry(); e != nil {
+			entries = append(entries, e)
+		}
+		controls = append(controls, resp.Controls()...)
+	}
+	require.NoError(resp.Err())
+
+	gotReq := <-requestCh
+	assert.Equal(SyncRequestModeRefreshOnly, gotReq.Mode)
+	assert.Equal([]byte("csn=0"), gotReq.Cookie)
+
+	require.Len(entries, 2)
+	assert.Equal("cn=alice,ou=people,dc=example,dc=org", entries[0].DN)
+	assert.Equal([]string{"alice"}, entries[0].GetAttributeValues("cn"))
+	assert.Equal("cn=bob,ou=people,dc=example,dc=org", entries[1].DN)
+
+	require.Len(controls, 4)
+	aliceState, ok := controls[0].(*ldap.ControlSyncState)
+	require.True(ok)
+	assert.Equal(ldap.SyncStateAdd, aliceState.State)
+	assert.Equal(aliceUUID[:], aliceState.EntryUUID[:])
+	bobState, ok := controls[1].(*ldap.ControlSyncState)
+	require.True(ok)
+	assert.Equal(ldap.SyncStatePresent, bobState.State)
+	assert.Equal(bobUUID[:], bobState.EntryUUID[:])
+	info, ok := controls[2].(*ldap.ControlSyncInfo)
+	require.True(ok)
+	assert.Equal(ldap.SyncInfoRefreshPresent, info.Value)
+	require.NotNil(info.RefreshPresent)
+	assert.True(info.RefreshPresent.RefreshDone)
+	done, ok := controls[3].(*ldap.ControlSyncDone)
+	require.True(ok)
+	assert.Equal([]byte("csn=2"), done.Cookie)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package testdirectory
+
+import "github.com/jimlambrt/gldap"
+
+// AccountState is a set of flags which define the state of a user's account.
+// A user's AccountState influences the results of their bind requests.  See:
+// Directory.SetAccountState(...)
+type AccountState uint
+
+const (
+	// AccountDisabled indicates the account is disabled
+	AccountDisabled AccountState = 1 << iota
+
+	// AccountExpired indicates the account has expired
+	AccountExpired
+
+	// AccountPasswordExpired indicates the account's password has expired
+	AccountPasswordExpired
+
+	// AccountMustChangePassword indicates the account's password must be
+	// changed/reset before the user can log in
+	AccountMustChangePassword
+
+	// AccountLocked indicates the account is locked out
+	AccountLocked
+)
+
+// accountStateData maps an AccountState to the sub-code included in the
+// diagnostic message of an Active Directory style invalid credentials bind
+// response (in order of precedence)
+var accountStateData = []struct {
+	state AccountState
+	data  gldap.ADBindErrorData
+}{
+	{AccountLocked, gldap.ADBindErrorAccountLocked},
+	{AccountDisabled, gldap.ADBindErrorAccountDisabled},
+	{AccountExpired, gldap.ADBindErrorAccountExpired},
+	{AccountPasswordExpired, gldap.ADBindErrorPasswordExpired},
+	{AccountMustChangePassword, gldap.ADBindErrorMustResetPassword},
+}
+
+// Has returns true if all the flags in s are set.
+func (a AccountState) Has(s AccountState) bool {
+	return a&s == s
+}
+
+// data returns the AD sub-code for the highest precedence state that's set, or
+// an empty string when no states are set.
+func (a AccountState) data() gldap.ADBindErrorData {
+	for _, d := range accountStateData {
+		if a.Has(d.state) {
+			return d.data
+		}
+	}
+	return ""
+}
+
+// AccountState returns the account state for the user's DN
+func (d *Directory) AccountState(userDN string) AccountState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.accountStates[dnKey(userDN, d.schemaOf(userDN))]
+}
+
+// SetAccountState sets the account state for the user's DN.  When any states
+// are set, the user's binds with a valid password will fail with
+// gldap.ResultInvalidCredentials and an AD style diagnostic message that
+// includes the state's sub-code (data 533 for disabled, data 701 for expired,
+// data 532 for an expired password, data 773 for must change password and data
+// 775 for locked).  Setting a zero AccountState clears the account's state.
+func (d *Directory) SetAccountState(userDN string, state AccountState) {
+	if v, ok := interface{}(d.t).(HelperT); ok {
+		v.Helper()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.accountStates == nil {
+		d.accountStates = map[string]AccountState{}
+	}
+	if state == 0 {
+		delete(d.accountStates, dnKey(userDN, d.schemaOf(userDN)))
+		return
+	}
+	d.accountStates[dnKey(userDN, d.schemaOf(userDN))] = state
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package testdirectory
+
+import (
+	"strings"
+
+	"github.com/jimlambrt/gldap"
+)
+
+// AttributeACL restricts who can read an attribute of the Directory's entries.
+// An attribute without an AttributeACL can be read by anyone, while an
+// attribute with one is only returned to the readers it allows and is omitted
+// from everyone else's search results.  See: Directory.SetAttributeACLs(...)
+type AttributeACL struct {
+	// Attribute is the name of the attribute, which is compared
+	// case-insensitively
+	Attribute string
+
+	// Self allows the entry's own DN to read the attribute (i.e. a user can
+	// read their own mobile number)
+	Self bool
+
+	// ReaderDNs are the bound DNs allowed to read the attribute of any entry
+	// (i.e. only the admins can read employeeNumber)
+	ReaderDNs []string
+}
+
+// allows returns true if the ACL allows the bound DN to read the attribute of
+// the entry.  Anonymous requesters are never allowed.
+func (a AttributeACL) allows(boundDN, entryDN string, schema *gldap.Schema) bool {
+	if boundDN == "" {
+		return false
+	}
+	if a.Self && sameDN(boundDN, entryDN, schema) {
+		return true
+	}
+	for _, dn := range a.ReaderDNs {
+		if sameDN(boundDN, dn, schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// AttributeACLs returns the Directory's attribute ACLs
+func (d *Directory) AttributeACLs() []AttributeACL {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.attributeACLs
+}
+
+// SetAttributeACLs sets the attribute ACLs which are evaluated, using the DN
+// the requester's connection is bound as, when the entries are projected into
+// search results.  When an attribute has more than one ACL, a reader allowed
+// by any of them can read it.  An empty list disables attribute ACLs.
+func (d *Directory) SetAttributeACLs(acls ...AttributeACL) {
+	if v, ok := interface{}(d.t).(HelperT); ok {
+		v.Helper()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.attributeACLs = acls
+}
+
+// newSearchResponseEntry projects the entry into a search result entry for the
+// request, which only includes the attributes the request's bound DN is
+// allowed to read (see: AttributeACL)
+func (d *Directory) newSearchResponseEntry(r *gldap.Request, e *gldap.Entry) *gldap.SearchResponseEntry {
+	d.mu.Lock()
+	acls := d.attributeACLs
+	schema := d.schemaOf(e.DN)
+	d.mu.Unlock()
+	return d.projectEntry(r, e, acls, schema)
+}
+
+// projectEntry projects the entry into a search result entry for the request,
+// which only includes the attributes the acls allow the request's bound DN to
+// read.  The DNs are compared with the matching rules of the schema of the
+// entry's naming context.
+func (d *Directory) projectEntry(r *gldap.Request, e *gldap.Entry, acls []AttributeACL, schema *gldap.Schema) *gldap.SearchResponseEntry {
+	boundDN := r.BoundDN()
+	d.mu.Lock()
+	if d.rootDN != "" && sameDN(boundDN, d.rootDN, d.schemaOf(d.rootDN)) {
+		// the root DN bypasses the ACLs
+		acls = nil
+	}
+	d.mu.Unlock()
+
+	result := r.NewSearchResponseEntry(e.DN)
+	for _, attr := range e.Attributes {
+		if !readable(acls, attr.Name, boundDN, e.DN, schema) {
+			continue
+		}
+		result.AddAttribute(attr.Name, attr.Values)
+	}
+	return result
+}
+
+// readable returns true if the ACLs allow the bound DN to read the attribute
+// of the entry.
+func readable(acls []AttributeACL, attr, boundDN, entryDN string, schema *gldap.Schema) bool {
+	restricted := false
+	for _, a := range acls {
+		if !strings.EqualFold(a.Attribute, attr) {
+			continue
+		}
+		if a.allows(boundDN, entryDN, schema) {
+			return true
+		}
+		restricted = true
+	}
+	return !restricted
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/go-ldap/ldap/v3"
 	"github.com/hashicorp/go-hclog"
 	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/hclogger"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/exp/slices"
 )
@@ -77,6 +78,13 @@ type Directory struct {
 	tokenGroups        map[string][]*gldap.Entry // string == SID
 	allowAnonymousBind bool
 	controls           []gldap.Control
+	requiredGroups     []string
+	accountStates      map[string]AccountState // string == normalized DN (see: dnKey)
+	personality        *gldap.Personality
+	attributeACLs      []AttributeACL
+	rootDN             string
+	rootPassword       string
+	hostedContexts     []*NamingContext
 
 	// userDN is the base distinguished name to use when searching for users
 	userDN string
@@ -110,16 +118,25 @@ func Start(t TestingT, opt ...Option) *Directory {
 		userDN:             opts.withDefaults.UserDN,
 		groupDN:            opts.withDefaults.GroupDN,
 		allowAnonymousBind: opts.withDefaults.AllowAnonymousBind,
+		requiredGroups:     opts.withDefaults.RequiredGroups,
+		personality:        opts.withPersonality,
+		attributeACLs:      opts.withDefaults.AttributeACLs,
+		rootDN:             opts.withDefaults.RootDN,
+		rootPassword:       opts.withDefaults.RootPassword,
+		hostedContexts:     opts.withDefaults.NamingContexts,
 	}
 
 	var err error
 	var srvOpts []gldap.Option
 	if opts.withLogger != nil {
-		srvOpts = append(srvOpts, gldap.WithLogger(opts.withLogger))
+		srvOpts = append(srvOpts, gldap.WithLogger(hclogger.New(opts.withLogger)))
 	}
 	if opts.withDisablePanicRecovery {
 		srvOpts = append(srvOpts, gldap.WithDisablePanicRecovery())
 	}
+	if opts.withClock != nil {
+		srvOpts = append(srvOpts, gldap.WithClock(opts.withClock))
+	}
 	d.s, err = gldap.NewServer(srvOpts...)
 	require.NoError(err)
 
@@ -128,8 +145,13 @@ func Start(t TestingT, opt ...Option) *Directory {
 	mux, err := gldap.NewMux()
 	require.NoError(err)
 	require.NoError(mux.DefaultRoute(d.handleNotFound(t)))
-	require.NoError(mux.Bind(d.handleBind(t)))
+	require.NoError(mux.Bind(d.requireGroups(d.handleBind(t))))
 	require.NoError(mux.ExtendedOperation(d.handleStartTLS(t), gldap.ExtendedOperationStartTLS))
+	require.NoError(mux.RootDSE(d.handleRootDSE(t), gldap.WithLabel("RootDSE")))
+	require.NoError(mux.MatchFunc(gldap.SearchRouteOperation, d.inNamingContext, d.handleSearchNamingContext(t), gldap.WithLabel("Search - Naming Contexts")))
+	require.NoError(mux.MatchFunc(gldap.ModifyRouteOperation, d.inNamingContext, d.handleModifyNamingContext(t), gldap.WithLabel("Modify - Naming Contexts")))
+	require.NoError(mux.MatchFunc(gldap.AddRouteOperation, d.inNamingContext, d.handleAddNamingContext(t), gldap.WithLabel("Add - Naming Contexts")))
+	require.NoError(mux.MatchFunc(gldap.DeleteRouteOperation, d.inNamingContext, d.handleDeleteNamingContext(t), gldap.WithLabel("Delete - Naming Contexts")))
 	require.NoError(mux.Search(d.handleSearchUsers(t), gldap.WithBaseDN(d.userDN), gldap.WithLabel("Search - Users")))
 	require.NoError(mux.Search(d.handleSearchGroups(t), gldap.WithBaseDN(d.groupDN), gldap.WithLabel("Search - Groups")))
 	require.NoError(mux.Search(d.handleSearchGeneric(t), gldap.WithLabel("Search - Generic")))
@@ -213,28 +235,169 @@ func (d *Directory) handleBind(t TestingT) func(w *gldap.ResponseWriter, r *glda
 		}
 		if m.Password == "" && d.allowAnonymousBind {
 			resp.SetResultCode(gldap.ResultSuccess)
+			if r.AuthzIDRequested() {
+				authzID, _ := gldap.NewControlAuthzIDResponse("")
+				resp.SetControls(authzID)
+			}
+			return
+		}
+
+		if d.rootBind(m) {
+			d.logger.Debug("found bind root DN", "op", op, "DN", m.UserName)
+			resp.SetResultCode(gldap.ResultSuccess)
+			if r.AuthzIDRequested() {
+				authzID, _ := gldap.NewControlAuthzIDResponse("dn:" + m.UserName)
+				resp.SetControls(authzID)
+			}
 			return
 		}
 
+		d.mu.Lock()
+		schema := d.schemaOf(m.UserName)
+		d.mu.Unlock()
 		for _, u := range d.users {
 			d.logger.Debug("user", "u.DN", u.DN, "m.UserName", m.UserName)
-			if u.DN == m.UserName {
+			if sameDN(u.DN, m.UserName, schema) {
 				d.logger.Debug("found bind user", "op", op, "DN", u.DN)
 				values := u.GetAttributeValues("password")
 				if len(values) > 0 && string(m.Password) == values[0] {
+					if data := d.AccountState(u.DN).data(); data != "" {
+						d.logger.Debug("bind user account state prevents bind", "op", op, "DN", u.DN, "data", data)
+						resp.SetDiagnosticMessage(gldap.ADBindDiagnostic(data))
+						return
+					}
 					resp.SetResultCode(gldap.ResultSuccess)
-					if d.controls != nil {
-						d.mu.Lock()
-						defer d.mu.Unlock()
-						resp.SetControls(d.controls...)
+					d.mu.Lock()
+					defer d.mu.Unlock()
+					controls := append([]gldap.Control{}, d.controls...)
+					if r.AuthzIDRequested() {
+						authzID, _ := gldap.NewControlAuthzIDResponse("dn:" + u.DN)
+						controls = append(controls, authzID)
+					}
+					if len(controls) > 0 {
+						resp.SetControls(controls...)
 					}
 					return
 				}
 			}
 		}
 		// bind failed...
-		return //nolint:gosimple // (ignore redundant return)
+		if d.personality != nil {
+			resp.SetDiagnosticMessage(d.personality.Diagnostic(gldap.ResultInvalidCredentials, ""))
+		}
+	}
+}
+
+// RequireGroupsMiddleware returns a middleware for bind handlers which rejects
+// simple binds with ResultInsufficientAccessRights when the bind user is not a
+// (nested) member of at least one of the groupDNs.  The membership is only
+// checked once the next handler has accepted the bind's credentials, so a bind
+// with invalid credentials fails with the next handler's response whether or
+// not the user is a member.  Anonymous binds are passed through to the next
+// handler unchanged.  This emulates the "require group" login policies found
+// in many directories.
+func (d *Directory) RequireGroupsMiddleware(groupDNs ...string) func(gldap.HandlerFunc) gldap.HandlerFunc {
+	const op = "testdirectory.(Directory).RequireGroupsMiddleware"
+	return func(next gldap.HandlerFunc) gldap.HandlerFunc {
+		return func(w *gldap.ResponseWriter, r *gldap.Request) {
+			if len(groupDNs) == 0 {
+				next(w, r)
+				return
+			}
+			m, err := r.GetSimpleBindMessage()
+			if err != nil || m.Password == "" || d.isRootDN(m.UserName) {
+				next(w, r)
+				return
+			}
+			next(w.WithInterceptor(func(resp gldap.Response) gldap.Response {
+				if b, ok := resp.(*gldap.BindResponse); !ok || b.ResultCode() != gldap.ResultSuccess {
+					return resp
+				}
+				for _, g := range groupDNs {
+					if d.IsMemberOf(m.UserName, g, true) {
+						return resp
+					}
+				}
+				d.logger.Debug("bind user is not a member of a required group", "op", op, "DN", m.UserName)
+				denied := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInsufficientAccessRights))
+				denied.SetDiagnosticMessage(fmt.Sprintf("%s is not a member of a required group", m.UserName))
+				return denied
+			}), r)
+		}
+	}
+}
+
+// requireGroups wraps the bind handler with a RequireGroupsMiddleware which
+// uses the Directory's current required groups (see: SetRequiredGroups)
+func (d *Directory) requireGroups(next gldap.HandlerFunc) gldap.HandlerFunc {
+	return func(w *gldap.ResponseWriter, r *gldap.Request) {
+		d.mu.Lock()
+		groups := d.requiredGroups
+		d.mu.Unlock()
+		d.RequireGroupsMiddleware(groups...)(next)(w, r)
+	}
+}
+
+// IsMemberOf returns true if the userDN is a member of the groupDN.  A user is
+// a member when the group's member/uniqueMember attributes contain the userDN
+// or the user's memberOf attribute contains the groupDN.  When nested is true,
+// membership is evaluated transitively through groups which are members of
+// other groups (with either attribute).  DNs are compared case-insensitively.
+func (d *Directory) IsMemberOf(userDN, groupDN string, nested bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	visited := map[string]bool{}
+	memberDNs := []string{userDN}
+	for len(memberDNs) > 0 {
+		memberDN := memberDNs[0]
+		memberDNs = memberDNs[1:]
+		visited[dnKey(memberDN, d.schemaOf(memberDN))] = true
+		for _, g := range d.parentGroups(memberDN) {
+			schema := d.schemaOf(g)
+			if sameDN(g, groupDN, schema) {
+				return true
+			}
+			if nested && !visited[dnKey(g, schema)] {
+				memberDNs = append(memberDNs, g)
+			}
+		}
+	}
+	return false
+}
+
+// parentGroups returns the DNs of the groups which the memberDN is a member
+// of, with either the groups' member/uniqueMember attributes or the member's
+// memberOf attribute.  The caller must hold d.mu.
+func (d *Directory) parentGroups(memberDN string) []string {
+	var parents []string
+	schema := d.schemaOf(memberDN)
+	for _, entries := range [][]*gldap.Entry{d.users, d.groups} {
+		for _, e := range entries {
+			if sameDN(e.DN, memberDN, schema) {
+				parents = append(parents, e.GetAttributeValues("memberOf")...)
+			}
+		}
+	}
+	for _, g := range d.groups {
+		if isGroupMember(g, memberDN, schema) {
+			parents = append(parents, g.DN)
+		}
 	}
+	return parents
+}
+
+// isGroupMember returns true if the group's member/uniqueMember attributes
+// contain the memberDN, which is compared with the matching rules of the schema
+func isGroupMember(group *gldap.Entry, memberDN string, schema *gldap.Schema) bool {
+	for _, attr := range []string{"member", "uniqueMember"} {
+		for _, m := range group.GetAttributeValues(attr) {
+			if sameDN(m, memberDN, schema) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (d *Directory) handleNotFound(t TestingT) func(w *gldap.ResponseWriter, r *gldap.Request) {
@@ -325,10 +488,7 @@ func (d *Directory) handleSearchGeneric(t TestingT) func(w *gldap.ResponseWriter
 			sid = strings.TrimSuffix(sid, ">")
 			for _, g := range d.tokenGroups[sid] {
 				d.logger.Debug("found tokenGroup", "op", op, "group DN", g.DN)
-				result := r.NewSearchResponseEntry(g.DN)
-				for _, attr := range g.Attributes {
-					result.AddAttribute(attr.Name, attr.Values)
-				}
+				result := d.newSearchResponseEntry(r, g)
 				foundEntries += 1
 				err = w.Write(result)
 				if err != nil {
@@ -365,10 +525,7 @@ func (d *Directory) handleSearchGeneric(t TestingT) func(w *gldap.ResponseWriter
 		if foundEntries > 0 {
 			d.logger.Debug("found entries", "op", op, "count", foundEntries)
 			for _, e := range entries {
-				result := r.NewSearchResponseEntry(e.DN)
-				for _, attr := range e.Attributes {
-					result.AddAttribute(attr.Name, attr.Values)
-				}
+				result := d.newSearchResponseEntry(r, e)
 				foundEntries += 1
 				err := w.Write(result)
 				if err != nil {
@@ -426,10 +583,7 @@ func (d *Directory) handleSearchGroups(t TestingT) func(w *gldap.ResponseWriter,
 
 		if foundEntries > 0 {
 			for _, e := range entries {
-				result := r.NewSearchResponseEntry(e.DN)
-				for _, attr := range e.Attributes {
-					result.AddAttribute(attr.Name, attr.Values)
-				}
+				result := d.newSearchResponseEntry(r, e)
 				foundEntries += 1
 				err = w.Write(result)
 				if err != nil {
@@ -477,10 +631,7 @@ func (d *Directory) handleSearchUsers(t TestingT) func(w *gldap.ResponseWriter,
 			return
 		}
 		for _, e := range entries {
-			result := r.NewSearchResponseEntry(e.DN)
-			for _, attr := range e.Attributes {
-				result.AddAttribute(attr.Name, attr.Values)
-			}
+			result := d.newSearchResponseEntry(r, e)
 			foundEntries += 1
 			err := w.Write(result)
 			if err != nil {
@@ -524,10 +675,16 @@ func (d *Directory) handleModify(t TestingT) func(w *gldap.ResponseWriter, r *gl
 		d.logger.Info("modify request", "dn", m.DN)
 
 		var entries []*gldap.Entry
-		_, _, entries = find(d.t, fmt.Sprintf("(%s)", m.DN), d.users)
+		d.mu.Lock()
+		for _, i := range indexDN(m.DN, d.users, d.schemaOf(m.DN)) {
+			entries = append(entries, d.users[i])
+		}
 		if len(entries) == 0 {
-			_, _, entries = find(d.t, m.DN, d.groups)
+			for _, i := range indexDN(m.DN, d.groups, d.schemaOf(m.DN)) {
+				entries = append(entries, d.groups[i])
+			}
 		}
+		d.mu.Unlock()
 		if len(entries) == 0 {
 			return
 		}
@@ -536,6 +693,19 @@ func (d *Directory) handleModify(t TestingT) func(w *gldap.ResponseWriter, r *gl
 			res.SetDiagnosticMessage(fmt.Sprintf("more than one match: %d entries", len(entries)))
 			return
 		}
+		if c, ok := r.GetAssertionControl(); ok {
+			matched, err := c.Evaluate(entries[0])
+			switch {
+			case err != nil:
+				d.logger.Error("unable to evaluate assertion", "op", op, "err", err)
+				res.SetResultCode(gldap.ResultOperationsError)
+				return
+			case !matched:
+				res.SetResultCode(gldap.ResultAssertionFailed)
+				res.SetDiagnosticMessage("assertion failed")
+				return
+			}
+		}
 		d.mu.Lock()
 		defer d.mu.Unlock()
 		e := entries[0]
@@ -602,11 +772,6 @@ func (d *Directory) handleAdd(t TestingT) func(w *gldap.ResponseWriter, r *gldap
 		}
 		d.logger.Info("add request", "dn", m.DN)
 
-		if found, _, _ := find(d.t, fmt.Sprintf("(%s)", m.DN), d.users); found {
-			res.SetResultCode(gldap.ResultEntryAlreadyExists)
-			res.SetDiagnosticMessage(fmt.Sprintf("entry exists for DN: %s", m.DN))
-			return
-		}
 		attrs := map[string][]string{}
 		for _, a := range m.Attributes {
 			attrs[a.Type] = a.Vals
@@ -614,6 +779,11 @@ func (d *Directory) handleAdd(t TestingT) func(w *gldap.ResponseWriter, r *gldap
 		newEntry := gldap.NewEntry(m.DN, attrs)
 		d.mu.Lock()
 		defer d.mu.Unlock()
+		if len(indexDN(m.DN, d.users, d.schemaOf(m.DN))) > 0 {
+			res.SetResultCode(gldap.ResultEntryAlreadyExists)
+			res.SetDiagnosticMessage(fmt.Sprintf("entry exists for DN: %s", m.DN))
+			return
+		}
 		d.users = append(d.users, newEntry)
 		res.SetResultCode(gldap.ResultSuccess)
 	}
@@ -641,28 +811,26 @@ func (d *Directory) handleDelete(t TestingT) func(w *gldap.ResponseWriter, r *gl
 		}
 		d.logger.Info("delete request", "dn", m.DN)
 
-		_, foundAt, _ := find(d.t, fmt.Sprintf("(%s)", m.DN), d.users)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		foundAt := indexDN(m.DN, d.users, d.schemaOf(m.DN))
 		if len(foundAt) > 0 {
 			if len(foundAt) > 1 {
 				res.SetResultCode(gldap.ResultInappropriateMatching)
 				res.SetDiagnosticMessage(fmt.Sprintf("more than one match: %d entries", len(foundAt)))
 				return
 			}
-			d.mu.Lock()
-			defer d.mu.Unlock()
 			d.users = append(d.users[:foundAt[0]], d.users[foundAt[0]+1:]...)
 			res.SetResultCode(gldap.ResultSuccess)
 			return
 		}
-		_, foundAt, _ = find(d.t, fmt.Sprintf("(%s)", m.DN), d.groups)
+		foundAt = indexDN(m.DN, d.groups, d.schemaOf(m.DN))
 		if len(foundAt) > 0 {
 			if len(foundAt) > 1 {
 				res.SetResultCode(gldap.ResultInappropriateMatching)
 				res.SetDiagnosticMessage(fmt.Sprintf("more than one match: %d entries", len(foundAt)))
 				return
 			}
-			d.mu.Lock()
-			defer d.mu.Unlock()
 			d.groups = append(d.groups[:foundAt[0]], d.groups[foundAt[0]+1:]...)
 			res.SetResultCode(gldap.ResultSuccess)
 			return
@@ -721,7 +889,8 @@ func match(filter string, attr string) (bool, error) {
 		element = strings.Trim(element, "(")
 		element = strings.Trim(element, ")")
 		element = strings.TrimSpace(element)
-		if strings.Contains(attr, element) {
+		// DNs and most attribute values are compared case-insensitively
+		if strings.Contains(strings.ToLower(attr), strings.ToLower(element)) {
 			return true, nil
 		}
 	}
@@ -833,6 +1002,31 @@ func (d *Directory) ClientKey() string {
 	return string(pemKey)
 }
 
+// Personality returns the Directory's personality (nil when the Directory was
+// started without WithPersonality(...))
+func (d *Directory) Personality() *gldap.Personality {
+	return d.personality
+}
+
+// namingContexts returns the Directory's naming contexts, which are the user
+// and group base DNs (or their common suffix when they have one).
+func (d *Directory) namingContexts() []string {
+	userParts := strings.Split(d.userDN, ",")
+	groupParts := strings.Split(d.groupDN, ",")
+	var common []string
+	for i := 1; i <= len(userParts) && i <= len(groupParts); i++ {
+		u, g := strings.TrimSpace(userParts[len(userParts)-i]), strings.TrimSpace(groupParts[len(groupParts)-i])
+		if !strings.EqualFold(u, g) {
+			break
+		}
+		common = append([]string{u}, common...)
+	}
+	if len(common) > 0 {
+		return []string{strings.Join(common, ",")}
+	}
+	return []string{d.userDN, d.groupDN}
+}
+
 // Controls returns all the current bind controls for the Directory
 func (d *Directory) Controls() []gldap.Control {
 	return d.controls
@@ -908,6 +1102,22 @@ func (d *Directory) SetAllowAnonymousBind(enabled bool) {
 	d.allowAnonymousBind = enabled
 }
 
+// RequiredGroups returns the group DNs a user must be a member of to bind
+func (d *Directory) RequiredGroups() []string {
+	return d.requiredGroups
+}
+
+// SetRequiredGroups sets the group DNs a user must be a (nested) member of for
+// a simple bind to succeed.  An empty list disables the policy.
+func (d *Directory) SetRequiredGroups(groupDNs ...string) {
+	if v, ok := interface{}(d.t).(HelperT); ok {
+		v.Helper()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requiredGroups = groupDNs
+}
+
 func (d *Directory) logSearchRequest(m *gldap.SearchMessage) {
 	d.logger.Info("search request",
 		"baseDN", m.BaseDN,
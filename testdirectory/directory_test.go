@@ -234,6 +234,23 @@ func TestDirectory_SimpleBindResponse(t *testing.T) {
 			assert.NoError(err)
 		})
 	}
+	t.Run("authzid", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		client := td.Conn()
+		defer func() { client.Close() }()
+		userName := fmt.Sprintf("%s=alice,%s", testdirectory.DefaultUserAttr, testdirectory.DefaultUserDN)
+		res, err := client.SimpleBind(&ldap.SimpleBindRequest{
+			Username: userName,
+			Password: "password",
+			Controls: []ldap.Control{ldap.NewControlString(gldap.ControlTypeAuthzIDRequest, false, "")},
+		})
+		require.NoError(err)
+		ctrl := ldap.FindControl(res.Controls, gldap.ControlTypeAuthzIDResponse)
+		require.NotNil(ctrl)
+		assert.Equal("dn:"+userName, ctrl.(*ldap.ControlString).ControlValue)
+		// the directory's controls are still returned
+		assert.NotNil(ldap.FindControl(res.Controls, gldap.ControlTypeBeheraPasswordPolicy))
+	})
 }
 
 func TestDirectory_SearchResponse(t *testing.T) {
@@ -724,3 +741,638 @@ func TestDirectory_DeleteResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestDirectory_IsMemberOf(t *testing.T) {
+	t.Parallel()
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_IsMemberOf-logger",
+		Level: hclog.Error,
+	})
+	td := testdirectory.Start(t,
+		testdirectory.WithLogger(t, testLogger),
+		testdirectory.WithNoTLS(t),
+	)
+	adminDN := fmt.Sprintf("%s=admin,%s", testdirectory.DefaultGroupAttr, testdirectory.DefaultGroupDN)
+	users := testdirectory.NewUsers(t, []string{"alice", "bob", "eve"})
+	users = append(users, testdirectory.NewUsers(t, []string{"carol"}, testdirectory.WithMembersOf(t, adminDN))...)
+	admin := testdirectory.NewGroup(t, "admin", []string{"alice"})
+	// "staff" is a member of "admin" and bob is a member of "staff"
+	staff := testdirectory.NewGroup(t, "staff", []string{"bob"})
+	admin.Attributes[0].AddValue(staff.DN)
+	// dave is a member of "ops" with his memberOf, and "ops" is a member of
+	// "admin" with its memberOf
+	opsDN := fmt.Sprintf("%s=ops,%s", testdirectory.DefaultGroupAttr, testdirectory.DefaultGroupDN)
+	users = append(users, testdirectory.NewUsers(t, []string{"dave"}, testdirectory.WithMembersOf(t, opsDN))...)
+	ops := testdirectory.NewGroup(t, "ops", nil)
+	ops.Attributes = append(ops.Attributes, gldap.NewEntryAttribute("memberOf", []string{adminDN}))
+	td.SetUsers(users...)
+	td.SetGroups(admin, staff, ops)
+
+	userDN := func(n string) string {
+		return fmt.Sprintf("%s=%s,%s", testdirectory.DefaultUserAttr, n, testdirectory.DefaultUserDN)
+	}
+
+	t.Run("IsMemberOf", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.True(td.IsMemberOf(userDN("alice"), adminDN, false))
+		assert.True(td.IsMemberOf(strings.ToUpper(userDN("alice")), adminDN, false))
+		assert.True(td.IsMemberOf(userDN("carol"), adminDN, false))
+		assert.False(td.IsMemberOf(userDN("bob"), adminDN, false))
+		assert.True(td.IsMemberOf(userDN("bob"), adminDN, true))
+		assert.False(td.IsMemberOf(userDN("eve"), adminDN, true))
+		assert.True(td.IsMemberOf(userDN("dave"), opsDN, false))
+		assert.False(td.IsMemberOf(userDN("dave"), adminDN, false))
+		assert.True(td.IsMemberOf(userDN("dave"), adminDN, true))
+	})
+	t.Run("RequiredGroups", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		td.SetRequiredGroups(adminDN)
+		t.Cleanup(func() { td.SetRequiredGroups() })
+		assert.Equal([]string{adminDN}, td.RequiredGroups())
+
+		client := td.Conn()
+		defer func() { client.Close() }()
+
+		require.NoError(client.Bind(userDN("alice"), "password"))
+		require.NoError(client.Bind(userDN("bob"), "password"))
+		err := client.Bind(userDN("eve"), "password")
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, gldap.ResultInsufficientAccessRights))
+
+		// invalid credentials don't reveal whether the user is a member
+		assert.True(ldap.IsErrorWithCode(client.Bind(userDN("alice"), "bad"), gldap.ResultInvalidCredentials))
+		assert.True(ldap.IsErrorWithCode(client.Bind(userDN("eve"), "bad"), gldap.ResultInvalidCredentials))
+	})
+}
+
+func TestDirectory_AccountState(t *testing.T) {
+	t.Parallel()
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_AccountState-logger",
+		Level: hclog.Error,
+	})
+	td := testdirectory.Start(t,
+		testdirectory.WithLogger(t, testLogger),
+		testdirectory.WithNoTLS(t),
+	)
+	td.SetUsers(testdirectory.NewUsers(t, []string{"alice"})...)
+	aliceDN := fmt.Sprintf("%s=alice,%s", testdirectory.DefaultUserAttr, testdirectory.DefaultUserDN)
+
+	tests := []struct {
+		name         string
+		state        testdirectory.AccountState
+		wantErr      bool
+		wantDataCode string
+	}{
+		{name: "none"},
+		{name: "disabled", state: testdirectory.AccountDisabled, wantErr: true, wantDataCode: "data 533"},
+		{name: "expired", state: testdirectory.AccountExpired, wantErr: true, wantDataCode: "data 701"},
+		{name: "password-expired", state: testdirectory.AccountPasswordExpired, wantErr: true, wantDataCode: "data 532"},
+		{name: "must-change-password", state: testdirectory.AccountMustChangePassword, wantErr: true, wantDataCode: "data 773"},
+		{name: "locked", state: testdirectory.AccountLocked, wantErr: true, wantDataCode: "data 775"},
+		{name: "locked-and-disabled", state: testdirectory.AccountLocked | testdirectory.AccountDisabled, wantErr: true, wantDataCode: "data 775"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			td.SetAccountState(aliceDN, tc.state)
+			assert.Equal(tc.state, td.AccountState(strings.ToUpper(aliceDN)))
+
+			client := td.Conn()
+			defer func() { client.Close() }()
+			err := client.Bind(aliceDN, "password")
+			if tc.wantErr {
+				require.Error(err)
+				assert.True(ldap.IsErrorWithCode(err, gldap.ResultInvalidCredentials))
+				assert.Contains(err.Error(), tc.wantDataCode)
+				return
+			}
+			require.NoError(err)
+		})
+	}
+}
+
+func TestDirectory_AttributeACLs(t *testing.T) {
+	t.Parallel()
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_AttributeACLs-logger",
+		Level: hclog.Error,
+	})
+	aliceDN := fmt.Sprintf("%s=alice,%s", testdirectory.DefaultUserAttr, testdirectory.DefaultUserDN)
+	bobDN := fmt.Sprintf("%s=bob,%s", testdirectory.DefaultUserAttr, testdirectory.DefaultUserDN)
+	adminDN := fmt.Sprintf("%s=admin,%s", testdirectory.DefaultUserAttr, testdirectory.DefaultUserDN)
+	td := testdirectory.Start(t,
+		testdirectory.WithLogger(t, testLogger),
+		testdirectory.WithNoTLS(t),
+		testdirectory.WithDefaults(t, &testdirectory.Defaults{
+			AllowAnonymousBind: true,
+			AttributeACLs: []testdirectory.AttributeACL{
+				{Attribute: "mobile", Self: true},
+				{Attribute: "EmployeeNumber", ReaderDNs: []string{adminDN}},
+			},
+		}),
+	)
+	users := testdirectory.NewUsers(t, []string{"alice", "bob", "admin"})
+	users[0].Attributes = append(users[0].Attributes,
+		gldap.NewEntryAttribute("mobile", []string{"555-0100"}),
+		gldap.NewEntryAttribute("employeeNumber", []string{"42"}),
+	)
+	td.SetUsers(users...)
+	assert.Len(t, td.AttributeACLs(), 2)
+
+	tests := []struct {
+		name      string
+		bindDN    string
+		wantAttrs []string
+	}{
+		{name: "anonymous", wantAttrs: []string{"name", "email", "password"}},
+		{name: "self", bindDN: aliceDN, wantAttrs: []string{"name", "email", "password", "mobile"}},
+		{name: "other-user", bindDN: bobDN, wantAttrs: []string{"name", "email", "password"}},
+		{name: "admin", bindDN: adminDN, wantAttrs: []string{"name", "email", "password", "employeeNumber"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			client := td.Conn()
+			defer func() { client.Close() }()
+			if tc.bindDN != "" {
+				require.NoError(client.Bind(tc.bindDN, "password"))
+			}
+			result, err := client.Search(&ldap.SearchRequest{
+				BaseDN: testdirectory.DefaultUserDN,
+				Scope:  ldap.ScopeWholeSubtree,
+				Filter: "(cn=alice)",
+			})
+			require.NoError(err)
+			require.Len(result.Entries, 1)
+			var gotAttrs []string
+			for _, a := range result.Entries[0].Attributes {
+				gotAttrs = append(gotAttrs, a.Name)
+			}
+			assert.ElementsMatch(tc.wantAttrs, gotAttrs)
+		})
+	}
+
+	t.Run("failed-rebind", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		client := td.Conn()
+		defer func() { client.Close() }()
+		require.NoError(client.Bind(aliceDN, "password"))
+		require.Error(client.Bind(aliceDN, "wrong"))
+		result, err := client.Search(&ldap.SearchRequest{
+			BaseDN: testdirectory.DefaultUserDN,
+			Scope:  ldap.ScopeWholeSubtree,
+			Filter: "(cn=alice)",
+		})
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		assert.Empty(result.Entries[0].GetAttributeValues("mobile"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		td.SetAttributeACLs()
+		client := td.Conn()
+		defer func() { client.Close() }()
+		result, err := client.Search(&ldap.SearchRequest{
+			BaseDN: testdirectory.DefaultUserDN,
+			Scope:  ldap.ScopeWholeSubtree,
+			Filter: "(cn=alice)",
+		})
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		assert.Equal([]string{"555-0100"}, result.Entries[0].GetAttributeValues("mobile"))
+		assert.Equal([]string{"42"}, result.Entries[0].GetAttributeValues("employeeNumber"))
+	})
+}
+
+func TestDirectory_RootDN(t *testing.T) {
+	t.Parallel()
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_RootDN-logger",
+		Level: hclog.Error,
+	})
+	const rootDN = "cn=manager,dc=example,dc=org"
+	td := testdirectory.Start(t,
+		testdirectory.WithLogger(t, testLogger),
+		testdirectory.WithNoTLS(t),
+		testdirectory.WithDefaults(t, &testdirectory.Defaults{
+			RootDN:         rootDN,
+			RootPassword:   "secret",
+			RequiredGroups: []string{"cn=admins,ou=groups,dc=example,dc=org"},
+			AttributeACLs:  []testdirectory.AttributeACL{{Attribute: "employeeNumber"}},
+		}),
+	)
+	users := testdirectory.NewUsers(t, []string{"alice"})
+	users[0].Attributes = append(users[0].Attributes, gldap.NewEntryAttribute("employeeNumber", []string{"42"}))
+	td.SetUsers(users...)
+	assert.Equal(t, rootDN, td.RootDN())
+
+	t.Run("bypasses-acls", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		client := td.RootConn()
+		defer func() { client.Close() }()
+		result, err := client.Search(&ldap.SearchRequest{
+			BaseDN: testdirectory.DefaultUserDN,
+			Scope:  ldap.ScopeWholeSubtree,
+			Filter: "(cn=alice)",
+		})
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		assert.Equal([]string{"42"}, result.Entries[0].GetAttributeValues("employeeNumber"))
+	})
+	t.Run("user", func(t *testing.T) {
+		assert := assert.New(t)
+		client := td.Conn()
+		defer func() { client.Close() }()
+		// alice isn't a member of the required group, unlike the root DN
+		err := client.Bind(fmt.Sprintf("%s=alice,%s", testdirectory.DefaultUserAttr, testdirectory.DefaultUserDN), "password")
+		assert.True(ldap.IsErrorWithCode(err, gldap.ResultInsufficientAccessRights))
+	})
+	t.Run("invalid-password", func(t *testing.T) {
+		assert := assert.New(t)
+		client := td.Conn()
+		defer func() { client.Close() }()
+		assert.True(ldap.IsErrorWithCode(client.Bind(rootDN, "wrong"), gldap.ResultInvalidCredentials))
+		assert.Error(client.UnauthenticatedBind(rootDN))
+	})
+	t.Run("removed", func(t *testing.T) {
+		assert := assert.New(t)
+		td.SetRootDN("", "")
+		assert.Empty(td.RootDN())
+		client := td.Conn()
+		defer func() { client.Close() }()
+		assert.Error(client.Bind(rootDN, "secret"))
+	})
+}
+
+func TestDirectory_ModifyAssertion(t *testing.T) {
+	t.Parallel()
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_ModifyAssertion-logger",
+		Level: hclog.Error,
+	})
+	td := testdirectory.Start(t,
+		testdirectory.WithLogger(t, testLogger),
+		testdirectory.WithNoTLS(t),
+		testdirectory.WithDefaults(t, &testdirectory.Defaults{AllowAnonymousBind: true}),
+	)
+	users := testdirectory.NewUsers(t, []string{"alice"})
+	td.SetUsers(users...)
+
+	tests := []struct {
+		name     string
+		filter   string
+		wantCode uint16
+	}{
+		{name: "assertion-failed", filter: "(email=eve@example.com)", wantCode: gldap.ResultAssertionFailed},
+		{name: "assertion-matched", filter: "(email=alice@example.com)", wantCode: gldap.ResultSuccess},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			f, err := ldap.CompileFilter(tc.filter)
+			require.NoError(err)
+			client := td.Conn()
+			defer func() { client.Close() }()
+			err = client.Modify(&ldap.ModifyRequest{
+				DN: users[0].DN,
+				Changes: []ldap.Change{
+					{
+						Operation:    ldap.AddAttribute,
+						Modification: ldap.PartialAttribute{Type: "description", Vals: []string{tc.name}},
+					},
+				},
+				Controls: []ldap.Control{ldap.NewControlString(gldap.ControlTypeAssertion, true, string(f.Bytes()))},
+			})
+			if tc.wantCode != gldap.ResultSuccess {
+				require.Error(err)
+				assert.True(ldap.IsErrorWithCode(err, tc.wantCode))
+				return
+			}
+			require.NoError(err)
+		})
+	}
+}
+
+func TestDirectory_Personality(t *testing.T) {
+	t.Parallel()
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_Personality-logger",
+		Level: hclog.Error,
+	})
+	tests := []struct {
+		name            string
+		personality     *gldap.Personality
+		wantVendorName  string
+		wantControl     string
+		wantDiagnostic  string
+		wantObjectClass string
+	}{
+		{
+			name:            "openldap",
+			personality:     gldap.PersonalityOpenLDAP,
+			wantControl:     gldap.ControlTypeSyncRequest,
+			wantObjectClass: "OpenLDAProotDSE",
+		},
+		{
+			name:            "389-ds",
+			personality:     gldap.Personality389DS,
+			wantVendorName:  "389 Project",
+			wantControl:     "2.16.840.1.113730.3.4.3",
+			wantDiagnostic:  "Invalid credentials",
+			wantObjectClass: "top",
+		},
+		{
+			name:            "edirectory",
+			personality:     gldap.PersonalityEDirectory,
+			wantVendorName:  "NetIQ Corporation",
+			wantControl:     "2.16.840.1.113719.1.27.101.6",
+			wantDiagnostic:  "NDS error: failed authentication (-669)",
+			wantObjectClass: "top",
+		},
+		{
+			name:            "active-directory",
+			personality:     gldap.PersonalityActiveDirectory,
+			wantControl:     "1.2.840.113556.1.4.841",
+			wantDiagnostic:  "data 52e",
+			wantObjectClass: "top",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			td := testdirectory.Start(t,
+				testdirectory.WithLogger(t, testLogger),
+				testdirectory.WithNoTLS(t),
+				testdirectory.WithPersonality(t, tc.personality),
+			)
+			td.SetUsers(testdirectory.NewUsers(t, []string{"alice"})...)
+			assert.Equal(tc.personality, td.Personality())
+
+			client := td.Conn()
+			defer func() { client.Close() }()
+
+			result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+			require.NoError(err)
+			require.Len(result.Entries, 1)
+			rootDSE := result.Entries[0]
+			assert.Equal("", rootDSE.DN)
+			assert.Equal([]string{"dc=example,dc=org"}, rootDSE.GetAttributeValues("namingContexts"))
+			assert.Equal([]string{"3"}, rootDSE.GetAttributeValues("supportedLDAPVersion"))
+			assert.Contains(rootDSE.GetAttributeValues("supportedControl"), tc.wantControl)
+			assert.Contains(rootDSE.GetAttributeValues("objectClass"), tc.wantObjectClass)
+			assert.Equal(tc.wantVendorName, rootDSE.GetAttributeValue("vendorName"))
+
+			result, err = client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"vendorVersion"}, nil))
+			require.NoError(err)
+			require.Len(result.Entries, 1)
+			if tc.wantVendorName != "" {
+				require.Len(result.Entries[0].Attributes, 1)
+				assert.Equal("vendorVersion", result.Entries[0].Attributes[0].Name)
+			} else {
+				assert.Empty(result.Entries[0].Attributes)
+			}
+
+			err = client.Bind(fmt.Sprintf("%s=alice,%s", testdirectory.DefaultUserAttr, testdirectory.DefaultUserDN), "bad-password")
+			require.Error(err)
+			assert.True(ldap.IsErrorWithCode(err, gldap.ResultInvalidCredentials))
+			if tc.wantDiagnostic != "" {
+				assert.Contains(err.Error(), tc.wantDiagnostic)
+			}
+		})
+	}
+}
+
+func TestDirectory_NamingContexts(t *testing.T) {
+	t.Parallel()
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_NamingContexts-logger",
+		Level: hclog.Error,
+	})
+	schema := gldap.NewSchema()
+	require.NoError(t, schema.AddObjectClass(gldap.ObjectClass{Name: "dcObject", Must: []string{"dc"}}))
+	require.NoError(t, schema.AddObjectClass(gldap.ObjectClass{Name: "organizationalUnit", Must: []string{"ou"}, May: []string{"description"}}))
+	require.NoError(t, schema.AddObjectClass(gldap.ObjectClass{Name: "device", Must: []string{"cn"}, May: []string{"serialNumber"}}))
+	const rootDN = "cn=manager,dc=example,dc=org"
+	td := testdirectory.Start(t,
+		testdirectory.WithLogger(t, testLogger),
+		testdirectory.WithNoTLS(t),
+		testdirectory.WithDefaults(t, &testdirectory.Defaults{
+			AllowAnonymousBind: true,
+			RootDN:             rootDN,
+			RootPassword:       "secret",
+			NamingContexts: []*testdirectory.NamingContext{
+				{
+					Suffix: "dc=test,dc=local",
+					Schema: schema,
+					Entries: []*gldap.Entry{
+						gldap.NewEntry("dc=test,dc=local", map[string][]string{"objectClass": {"top", "dcObject"}, "dc": {"test"}}),
+						gldap.NewEntry("ou=devices,dc=test,dc=local", map[string][]string{"objectClass": {"top", "organizationalUnit"}, "ou": {"devices"}}),
+						gldap.NewEntry("cn=printer,ou=devices,dc=test,dc=local", map[string][]string{"objectClass": {"top", "device"}, "cn": {"printer"}, "serialNumber": {"42"}}),
+					},
+					AttributeACLs: []testdirectory.AttributeACL{{Attribute: "serialNumber"}},
+				},
+			},
+		}),
+	)
+	require.Len(t, td.NamingContexts(), 1)
+
+	t.Run("root-dse", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		client := td.Conn()
+		defer func() { client.Close() }()
+		result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"namingContexts"}, nil))
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		assert.Equal([]string{"dc=example,dc=org", "dc=test,dc=local"}, result.Entries[0].GetAttributeValues("namingContexts"))
+	})
+	t.Run("search", func(t *testing.T) {
+		client := td.Conn()
+		defer func() { client.Close() }()
+		tests := []struct {
+			name     string
+			baseDN   string
+			scope    int
+			filter   string
+			wantDNs  []string
+			wantCode uint16
+		}{
+			{name: "base", baseDN: "DC=Test,DC=Local", scope: ldap.ScopeBaseObject, filter: "(objectClass=*)", wantDNs: []string{"dc=test,dc=local"}},
+			{name: "one", baseDN: "dc=test,dc=local", scope: ldap.ScopeSingleLevel, filter: "(objectClass=*)", wantDNs: []string{"ou=devices,dc=test,dc=local"}},
+			{name: "sub", baseDN: "dc=test,dc=local", scope: ldap.ScopeWholeSubtree, filter: "(objectClass=*)", wantDNs: []string{"dc=test,dc=local", "ou=devices,dc=test,dc=local", "cn=printer,ou=devices,dc=test,dc=local"}},
+			{name: "children", baseDN: "dc=test,dc=local", scope: int(gldap.SubordinateSubtree), filter: "(objectClass=*)", wantDNs: []string{"ou=devices,dc=test,dc=local", "cn=printer,ou=devices,dc=test,dc=local"}},
+			{name: "filter", baseDN: "dc=test,dc=local", scope: ldap.ScopeWholeSubtree, filter: "(objectClass=device)", wantDNs: []string{"cn=printer,ou=devices,dc=test,dc=local"}},
+			{name: "no-such-object", baseDN: "ou=missing,dc=test,dc=local", scope: ldap.ScopeWholeSubtree, filter: "(objectClass=*)", wantCode: gldap.ResultNoSuchObject},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				result, err := client.Search(ldap.NewSearchRequest(tc.baseDN, tc.scope, ldap.NeverDerefAliases, 0, 0, false, tc.filter, nil, nil))
+				if tc.wantCode != 0 {
+					require.Error(err)
+					assert.True(ldap.IsErrorWithCode(err, tc.wantCode))
+					return
+				}
+				require.NoError(err)
+				var dns []string
+				for _, e := range result.Entries {
+					dns = append(dns, e.DN)
+				}
+				assert.Equal(tc.wantDNs, dns)
+			})
+		}
+	})
+	t.Run("acls", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		search := ldap.NewSearchRequest("cn=printer,ou=devices,dc=test,dc=local", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		client := td.Conn()
+		defer func() { client.Close() }()
+		result, err := client.Search(search)
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		assert.Empty(result.Entries[0].GetAttributeValues("serialNumber"))
+
+		root := td.RootConn()
+		defer func() { root.Close() }()
+		result, err = root.Search(search)
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		assert.Equal([]string{"42"}, result.Entries[0].GetAttributeValues("serialNumber"))
+	})
+	t.Run("add-modify-delete", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		client := td.RootConn()
+		defer func() { client.Close() }()
+
+		add := ldap.NewAddRequest("cn=scanner,ou=devices,dc=test,dc=local", nil)
+		add.Attribute("objectClass", []string{"top", "device"})
+		add.Attribute("cn", []string{"scanner"})
+		require.NoError(client.Add(add))
+		err := client.Add(add)
+		assert.True(ldap.IsErrorWithCode(err, gldap.ResultEntryAlreadyExists))
+
+		orphan := ldap.NewAddRequest("cn=scanner,ou=missing,dc=test,dc=local", nil)
+		orphan.Attribute("objectClass", []string{"top", "device"})
+		orphan.Attribute("cn", []string{"scanner"})
+		assert.True(ldap.IsErrorWithCode(client.Add(orphan), gldap.ResultNoSuchObject))
+
+		invalid := ldap.NewAddRequest("cn=phone,ou=devices,dc=test,dc=local", nil)
+		invalid.Attribute("objectClass", []string{"top", "device"})
+		invalid.Attribute("cn", []string{"phone"})
+		invalid.Attribute("mail", []string{"phone@test.local"})
+		assert.True(ldap.IsErrorWithCode(client.Add(invalid), gldap.ResultObjectClassViolation))
+
+		modify := ldap.NewModifyRequest("cn=scanner,ou=devices,dc=test,dc=local", nil)
+		modify.Add("serialNumber", []string{"43"})
+		require.NoError(client.Modify(modify))
+		modify = ldap.NewModifyRequest("cn=scanner,ou=devices,dc=test,dc=local", nil)
+		modify.Add("mail", []string{"scanner@test.local"})
+		assert.True(ldap.IsErrorWithCode(client.Modify(modify), gldap.ResultObjectClassViolation))
+
+		result, err := client.Search(ldap.NewSearchRequest("cn=scanner,ou=devices,dc=test,dc=local", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+		require.Len(result.Entries, 1)
+		assert.Equal([]string{"43"}, result.Entries[0].GetAttributeValues("serialNumber"))
+
+		assert.True(ldap.IsErrorWithCode(client.Del(ldap.NewDelRequest("ou=devices,dc=test,dc=local", nil)), gldap.ResultNotAllowedOnNonLeaf))
+		require.NoError(client.Del(ldap.NewDelRequest("cn=scanner,ou=devices,dc=test,dc=local", nil)))
+		assert.True(ldap.IsErrorWithCode(client.Del(ldap.NewDelRequest("cn=scanner,ou=devices,dc=test,dc=local", nil)), gldap.ResultNoSuchObject))
+	})
+	t.Run("create-parents", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		td.SetNamingContexts(append(td.NamingContexts(), &testdirectory.NamingContext{
+			Suffix:        "dc=glue,dc=local",
+			Schema:        schema,
+			CreateParents: true,
+		})...)
+		client := td.RootConn()
+		defer func() { client.Close() }()
+
+		add := ldap.NewAddRequest("cn=router,ou=network,dc=glue,dc=local", nil)
+		add.Attribute("objectClass", []string{"top", "device"})
+		add.Attribute("cn", []string{"router"})
+		require.NoError(client.Add(add))
+		result, err := client.Search(ldap.NewSearchRequest("dc=glue,dc=local", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=glue)", nil, nil))
+		require.NoError(err)
+		require.Len(result.Entries, 2)
+		assert.Equal("dc=glue,dc=local", result.Entries[0].DN)
+		assert.Equal([]string{"glue"}, result.Entries[0].GetAttributeValues("dc"))
+		assert.Equal("ou=network,dc=glue,dc=local", result.Entries[1].DN)
+		assert.Equal([]string{"network"}, result.Entries[1].GetAttributeValues("ou"))
+
+		// a strict naming context responds with the closest existing parent
+		add = ldap.NewAddRequest("cn=router,ou=network,ou=devices,dc=test,dc=local", nil)
+		add.Attribute("objectClass", []string{"top", "device"})
+		add.Attribute("cn", []string{"router"})
+		err = client.Add(add)
+		require.Error(err)
+		assert.True(ldap.IsErrorWithCode(err, gldap.ResultNoSuchObject))
+		var ldapErr *ldap.Error
+		require.ErrorAs(err, &ldapErr)
+		assert.Equal("ou=devices,dc=test,dc=local", ldapErr.MatchedDN)
+	})
+	t.Run("schema-matching-rules", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		// the DNs within a naming context are compared with its schema's
+		// matching rules, which make "serialNumber" case-sensitive
+		exact := gldap.NewSchema()
+		require.NoError(exact.AddAttributeType(gldap.AttributeType{Name: "serialNumber", Equality: gldap.CaseExactMatch}))
+		td.SetNamingContexts(append(td.NamingContexts(), &testdirectory.NamingContext{
+			Suffix: "dc=exact,dc=local",
+			Schema: exact,
+		})...)
+		td.SetAccountState("serialNumber=AB12,dc=exact,dc=local", testdirectory.AccountLocked)
+		assert.Equal(testdirectory.AccountLocked, td.AccountState("serialNumber=AB12,dc=exact,dc=local"))
+		assert.Zero(td.AccountState("serialNumber=ab12,dc=exact,dc=local"))
+
+		// without a schema the values are compared case-insensitively
+		td.SetAccountState("serialNumber=AB12,dc=example,dc=org", testdirectory.AccountLocked)
+		assert.Equal(testdirectory.AccountLocked, td.AccountState("serialNumber=ab12,dc=example,dc=org"))
+	})
+}
+
+func TestDirectory_CasePreservingDNs(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Name:  "TestDirectory_CasePreservingDNs-logger",
+		Level: hclog.Error,
+	})
+	td := testdirectory.Start(t,
+		testdirectory.WithLogger(t, testLogger),
+		testdirectory.WithNoTLS(t),
+	)
+	const bobDN = "CN=Bob,OU=People,DC=Example,DC=Org"
+	bob := gldap.NewEntry(bobDN, map[string][]string{"cn": {"Bob"}, "password": {"password"}})
+	td.SetUsers(bob)
+	td.SetGroups(gldap.NewEntry("cn=admins,ou=groups,dc=example,dc=org", map[string][]string{"member": {"cn=bob, ou=people, dc=example, dc=org"}}))
+	td.SetAccountState("cn=bob,ou=people,dc=example,dc=org", testdirectory.AccountDisabled)
+	assert.Equal(testdirectory.AccountDisabled, td.AccountState(bobDN))
+	td.SetAccountState(bobDN, 0)
+	assert.True(td.IsMemberOf("cn=BOB,ou=people,dc=example,dc=org", "CN=Admins,OU=Groups,DC=Example,DC=Org", false))
+
+	client := td.Conn()
+	defer func() { client.Close() }()
+	require.NoError(client.Bind("cn=bob,ou=people,dc=example,dc=org", "password"))
+
+	modify := ldap.NewModifyRequest("cn=bob, ou=people, dc=example, dc=org", nil)
+	modify.Add("mail", []string{"bob@example.org"})
+	require.NoError(client.Modify(modify))
+
+	result, err := client.Search(ldap.NewSearchRequest(testdirectory.DefaultUserDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(cn=Bob)", nil, nil))
+	require.NoError(err)
+	require.Len(result.Entries, 1)
+	// the DN is returned as it was written
+	assert.Equal(bobDN, result.Entries[0].DN)
+	assert.NotEmpty(result.Entries[0].GetAttributeValues("mail"))
+
+	add := ldap.NewAddRequest("cn=BOB,ou=people,dc=example,dc=org", nil)
+	add.Attribute("cn", []string{"bob"})
+	assert.True(ldap.IsErrorWithCode(client.Add(add), gldap.ResultEntryAlreadyExists))
+
+	require.NoError(client.Del(ldap.NewDelRequest("cn=bob,ou=people,dc=example,dc=org", nil)))
+	assert.Empty(td.Users())
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package testdirectory
+
+import (
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jimlambrt/gldap"
+)
+
+// dnKey returns the normalized form of the dn (see: gldap.NormalizeDN), which
+// indexes the Directory's entries case-insensitively while their DNs are
+// stored and returned as the fixture's author wrote them.  A dn that can't be
+// parsed is compared lower-cased.
+func dnKey(dn string, schema *gldap.Schema) string {
+	key, err := gldap.NormalizeDN(dn, schema)
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+	return key
+}
+
+// sameDN returns true if the DNs are equal (i.e. "CN=Alice,DC=Example,DC=Org"
+// and "cn=alice, dc=example, dc=org") with the matching rules of the schema
+// (see: Directory.schemaOf)
+func sameDN(a, b string, schema *gldap.Schema) bool {
+	return dnKey(a, schema) == dnKey(b, schema)
+}
+
+// indexDN returns the indexes of the entries with the dn, which are compared
+// with the matching rules of the schema
+func indexDN(dn string, entries []*gldap.Entry, schema *gldap.Schema) []int {
+	key := dnKey(dn, schema)
+	var indexes []int
+	for i, e := range entries {
+		if dnKey(e.DN, schema) == key {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// schemaOf returns the schema of the hosted naming context which contains the
+// dn, or nil when the dn isn't within one (i.e. the Directory's users and
+// groups).  The caller must hold d.mu.
+func (d *Directory) schemaOf(dn string) *gldap.Schema {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return nil
+	}
+	for _, nc := range d.hostedContexts {
+		if nc.contains(parsed) {
+			return nc.Schema
+		}
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package testdirectory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jimlambrt/gldap"
+)
+
+// NamingContext is a naming context (suffix) hosted by the Directory besides
+// its users and groups, so a single Directory can serve several suffixes (i.e.
+// "dc=example,dc=org" and "dc=test,dc=local").  Searches, adds, modifies and
+// deletes of the DNs within a naming context are served from its entries with
+// its schema and attribute ACLs, and its suffix is advertised in the
+// namingContexts of the Directory's RootDSE.  A naming context shouldn't
+// contain the Directory's user or group DNs.  See:
+// Directory.SetNamingContexts(...)
+type NamingContext struct {
+	// Suffix is the DN of the naming context
+	Suffix string
+
+	// Entries are the entries of the naming context, whose DNs are within
+	// its suffix
+	Entries []*gldap.Entry
+
+	// Schema validates the entries added and modified within the naming
+	// context (optional)
+	Schema *gldap.Schema
+
+	// AttributeACLs restrict who can read the attributes of the naming
+	// context's entries, instead of the Directory's attribute ACLs (optional)
+	AttributeACLs []AttributeACL
+
+	// CreateParents creates the missing parents of an added entry as glue
+	// entries (like OpenLDAP's glue objects), instead of responding with
+	// ResultNoSuchObject and the matched DN of the closest existing parent.
+	// Glue entries have the "top" and "glue" object classes and the values of
+	// their RDN, and aren't validated against the Schema.
+	CreateParents bool
+}
+
+// contains returns true if the dn is within the naming context's suffix
+func (nc *NamingContext) contains(dn *ldap.DN) bool {
+	suffix, err := ldap.ParseDN(nc.Suffix)
+	if err != nil {
+		return false
+	}
+	return suffix.EqualFold(dn) || suffix.AncestorOfFold(dn)
+}
+
+// entryIndex returns the index of the entry with the dn, or -1 when it doesn't
+// exist.  DNs are compared with the matching rules of the naming context's
+// schema.
+func (nc *NamingContext) entryIndex(dn *ldap.DN) int {
+	key := dnKey(dn.String(), nc.Schema)
+	for i, e := range nc.Entries {
+		if dnKey(e.DN, nc.Schema) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// missingParents returns the glue entries of the dn's parents which don't
+// exist within the naming context, ordered from the closest to the suffix to
+// the dn's parent.
+func (nc *NamingContext) missingParents(dn *ldap.DN) []*gldap.Entry {
+	suffix, err := ldap.ParseDN(nc.Suffix)
+	if err != nil {
+		return nil
+	}
+	var glue []*gldap.Entry
+	for i := 1; i < len(dn.RDNs) && suffix.AncestorOfFold(&ldap.DN{RDNs: dn.RDNs[i-1:]}); i++ {
+		parent := &ldap.DN{RDNs: dn.RDNs[i:]}
+		if nc.entryIndex(parent) >= 0 {
+			break
+		}
+		attrs := map[string][]string{"objectClass": {"top", "glue"}}
+		for _, a := range parent.RDNs[0].Attributes {
+			attrs[a.Type] = append(attrs[a.Type], a.Value)
+		}
+		glue = append([]*gldap.Entry{gldap.NewEntry(parent.String(), attrs)}, glue...)
+	}
+	return glue
+}
+
+// NamingContexts returns the naming contexts hosted by the Directory
+func (d *Directory) NamingContexts() []*NamingContext {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hostedContexts
+}
+
+// SetNamingContexts sets the naming contexts hosted by the Directory besides
+// its users and groups.  An empty list removes them.
+func (d *Directory) SetNamingContexts(contexts ...*NamingContext) {
+	if v, ok := interface{}(d.t).(HelperT); ok {
+		v.Helper()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hostedContexts = contexts
+}
+
+// namingContextOf returns the hosted naming context which contains the
+// request's target DN (the base DN of a search), or nil when it isn't within
+// one.
+func (d *Directory) namingContextOf(r *gldap.Request) *NamingContext {
+	var target string
+	switch m := r.Message().(type) {
+	case *gldap.SearchMessage:
+		target = m.BaseDN
+	case *gldap.AddMessage:
+		target = m.DN
+	case *gldap.ModifyMessage:
+		target = m.DN
+	case *gldap.DeleteMessage:
+		target = m.DN
+	default:
+		return nil
+	}
+	if target == "" {
+		return nil
+	}
+	dn, err := ldap.ParseDN(target)
+	if err != nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, nc := range d.hostedContexts {
+		if nc.contains(dn) {
+			return nc
+		}
+	}
+	return nil
+}
+
+// inNamingContext returns true if the request's target DN is within one of the
+// hosted naming contexts, and it's the MatchFunc of their routes.
+func (d *Directory) inNamingContext(r *gldap.Request) bool {
+	return d.namingContextOf(r) != nil
+}
+
+// inScope returns true if the dn is within the scope of the base DN
+func inScope(dn, base *ldap.DN, scope gldap.Scope) bool {
+	switch scope {
+	case gldap.BaseObject:
+		return base.EqualFold(dn)
+	case gldap.SingleLevel:
+		return len(dn.RDNs) == len(base.RDNs)+1 && base.AncestorOfFold(dn)
+	case gldap.WholeSubtree:
+		return base.EqualFold(dn) || base.AncestorOfFold(dn)
+	case gldap.SubordinateSubtree:
+		return base.AncestorOfFold(dn)
+	default:
+		return false
+	}
+}
+
+func (d *Directory) handleSearchNamingContext(t TestingT) func(w *gldap.ResponseWriter, r *gldap.Request) {
+	const op = "testdirectory.(Directory).handleSearchNamingContext"
+	if v, ok := interface{}(t).(HelperT); ok {
+		v.Helper()
+	}
+	return func(w *gldap.ResponseWriter, r *gldap.Request) {
+		d.logger.Debug(op)
+		res := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultNoSuchObject))
+		defer func() {
+			if err := w.Write(res); err != nil {
+				d.logger.Error("error writing result", "op", op, "err", err)
+			}
+		}()
+		m, err := r.GetSearchMessage()
+		if err != nil {
+			d.logger.Error("not a search message", "op", op, "err", err)
+			return
+		}
+		d.logSearchRequest(m)
+		nc := d.namingContextOf(r)
+		if nc == nil {
+			return
+		}
+		base, err := ldap.ParseDN(m.BaseDN)
+		if err != nil {
+			res.SetResultCode(gldap.ResultInvalidDNSyntax)
+			return
+		}
+
+		d.mu.Lock()
+		entries := append([]*gldap.Entry{}, nc.Entries...)
+		acls, schema := nc.AttributeACLs, nc.Schema
+		found := nc.entryIndex(base) >= 0
+		d.mu.Unlock()
+		if !found {
+			res.SetMatchedDN(gldap.MatchedDN(m.BaseDN, entries))
+			return
+		}
+		for _, e := range entries {
+			dn, err := ldap.ParseDN(e.DN)
+			if err != nil || !inScope(dn, base, m.Scope) {
+				continue
+			}
+			if ok, err := e.MatchFilter(m.Filter); err != nil || !ok {
+				continue
+			}
+			if err := w.Write(d.projectEntry(r, e, acls, schema)); err != nil {
+				d.logger.Error("error writing result", "op", op, "err", err)
+				return
+			}
+		}
+		res.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+func (d *Directory) handleAddNamingContext(t TestingT) func(w *gldap.ResponseWriter, r *gldap.Request) {
+	const op = "testdirectory.(Directory).handleAddNamingContext"
+	if v, ok := interface{}(t).(HelperT); ok {
+		v.Helper()
+	}
+	return func(w *gldap.ResponseWriter, r *gldap.Request) {
+		d.logger.Debug(op)
+		res := r.NewResponse(gldap.WithApplicationCode(gldap.ApplicationAddResponse), gldap.WithResponseCode(gldap.ResultOperationsError))
+		defer func() {
+			if err := w.Write(res); err != nil {
+				d.logger.Error("error writing result", "op", op, "err", err)
+			}
+		}()
+		m, err := r.GetAddMessage()
+		if err != nil {
+			d.logger.Error("not an add message", "op", op, "err", err)
+			return
+		}
+		d.logger.Info("add request", "dn", m.DN)
+		nc := d.namingContextOf(r)
+		if nc == nil {
+			return
+		}
+		dn, err := ldap.ParseDN(m.DN)
+		if err != nil {
+			res.SetResultCode(gldap.ResultInvalidDNSyntax)
+			return
+		}
+		attrs := make(map[string][]string, len(m.Attributes))
+		for _, a := range m.Attributes {
+			attrs[a.Type] = append(attrs[a.Type], a.Vals...)
+		}
+		newEntry := gldap.NewEntry(m.DN, attrs)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if nc.entryIndex(dn) >= 0 {
+			res.SetResultCode(gldap.ResultEntryAlreadyExists)
+			res.SetDiagnosticMessage(fmt.Sprintf("entry exists for DN: %s", m.DN))
+			return
+		}
+		glue := nc.missingParents(dn)
+		if len(glue) > 0 && !nc.CreateParents {
+			res.SetResultCode(gldap.ResultNoSuchObject)
+			res.SetMatchedDN(gldap.MatchedDN(m.DN, nc.Entries))
+			return
+		}
+		// applying no changes validates the new entry against the schema and
+		// checks it has the values of its RDN
+		if err := gldap.ApplyModify(newEntry, &gldap.ModifyMessage{DN: m.DN}, nc.Schema); err != nil {
+			setChangeError(res, err)
+			return
+		}
+		nc.Entries = append(nc.Entries, glue...)
+		nc.Entries = append(nc.Entries, newEntry)
+		res.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+func (d *Directory) handleModifyNamingContext(t TestingT) func(w *gldap.ResponseWriter, r *gldap.Request) {
+	const op = "testdirectory.(Directory).handleModifyNamingContext"
+	if v, ok := interface{}(t).(HelperT); ok {
+		v.Helper()
+	}
+	return func(w *gldap.ResponseWriter, r *gldap.Request) {
+		d.logger.Debug(op)
+		res := r.NewModifyResponse(gldap.WithResponseCode(gldap.ResultNoSuchObject))
+		defer func() {
+			if err := w.Write(res); err != nil {
+				d.logger.Error("error writing result", "op", op, "err", err)
+			}
+		}()
+		m, err := r.GetModifyMessage()
+		if err != nil {
+			d.logger.Error("not a modify message", "op", op, "err", err)
+			return
+		}
+		d.logger.Info("modify request", "dn", m.DN)
+		nc := d.namingContextOf(r)
+		if nc == nil {
+			return
+		}
+		dn, err := ldap.ParseDN(m.DN)
+		if err != nil {
+			res.SetResultCode(gldap.ResultInvalidDNSyntax)
+			return
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		i := nc.entryIndex(dn)
+		if i < 0 {
+			res.SetMatchedDN(gldap.MatchedDN(m.DN, nc.Entries))
+			return
+		}
+		if err := gldap.ApplyModify(nc.Entries[i], m, nc.Schema); err != nil {
+			setChangeError(res, err)
+			return
+		}
+		res.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+func (d *Directory) handleDeleteNamingContext(t TestingT) func(w *gldap.ResponseWriter, r *gldap.Request) {
+	const op = "testdirectory.(Directory).handleDeleteNamingContext"
+	if v, ok := interface{}(t).(HelperT); ok {
+		v.Helper()
+	}
+	return func(w *gldap.ResponseWriter, r *gldap.Request) {
+		d.logger.Debug(op)
+		res := r.NewResponse(gldap.WithResponseCode(gldap.ResultNoSuchObject), gldap.WithApplicationCode(gldap.ApplicationDelResponse))
+		defer func() {
+			if err := w.Write(res); err != nil {
+				d.logger.Error("error writing response", "op", op, "err", err)
+			}
+		}()
+		m, err := r.GetDeleteMessage()
+		if err != nil {
+			d.logger.Error("not a delete message", "op", op, "err", err)
+			return
+		}
+		d.logger.Info("delete request", "dn", m.DN)
+		nc := d.namingContextOf(r)
+		if nc == nil {
+			return
+		}
+		dn, err := ldap.ParseDN(m.DN)
+		if err != nil {
+			res.SetResultCode(gldap.ResultInvalidDNSyntax)
+			return
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		i := nc.entryIndex(dn)
+		if i < 0 {
+			res.SetMatchedDN(gldap.MatchedDN(m.DN, nc.Entries))
+			return
+		}
+		for _, e := range nc.Entries {
+			if child, err := ldap.ParseDN(e.DN); err == nil && dn.AncestorOfFold(child) {
+				res.SetResultCode(gldap.ResultNotAllowedOnNonLeaf)
+				return
+			}
+		}
+		nc.Entries = append(nc.Entries[:i], nc.Entries[i+1:]...)
+		res.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+// resultSetter is a response with a result code and diagnostic message
+type resultSetter interface {
+	SetResultCode(int)
+	SetDiagnosticMessage(string)
+}
+
+// setChangeError sets the result code and diagnostic message of the response
+// from the error of a change that couldn't be applied (see: gldap.ChangeError)
+func setChangeError(res resultSetter, err error) {
+	var ce *gldap.ChangeError
+	if !errors.As(err, &ce) {
+		res.SetResultCode(gldap.ResultOperationsError)
+		res.SetDiagnosticMessage(err.Error())
+		return
+	}
+	res.SetResultCode(ce.ResultCode)
+	res.SetDiagnosticMessage(ce.Msg)
+}
+
+// hostedSuffixes returns the suffixes of the hosted naming contexts
+func (d *Directory) hostedSuffixes() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	suffixes := make([]string, 0, len(d.hostedContexts))
+	for _, nc := range d.hostedContexts {
+		suffixes = append(suffixes, nc.Suffix)
+	}
+	return suffixes
+}
+
+// handleRootDSE responds to RootDSE searches with the RootDSE of the
+// Directory's personality, or a minimal RootDSE when it has no personality,
+// which advertise the naming contexts of the users and groups and the hosted
+// naming contexts.  Without a personality or hosted naming contexts, RootDSE
+// searches are served like any other search.
+func (d *Directory) handleRootDSE(t TestingT) func(w *gldap.ResponseWriter, r *gldap.Request) {
+	if v, ok := interface{}(t).(HelperT); ok {
+		v.Helper()
+	}
+	generic := d.handleSearchGeneric(t)
+	return func(w *gldap.ResponseWriter, r *gldap.Request) {
+		hosted := d.hostedSuffixes()
+		p := d.personality
+		switch {
+		case p == nil && len(hosted) == 0:
+			generic(w, r)
+			return
+		case p == nil:
+			p = &gldap.Personality{ObjectClasses: []string{"top"}}
+		}
+		contexts := d.namingContexts()
+		for _, s := range hosted {
+			if !containsFold(contexts, s) {
+				contexts = append(contexts, s)
+			}
+		}
+		p.RootDSEHandler(contexts...)(w, r)
+	}
+}
+
+func containsFold(values []string, v string) bool {
+	for _, s := range values {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -44,6 +44,8 @@ type options struct {
 	withMTLS                 bool
 	withDisablePanicRecovery bool
 	withDefaults             *Defaults
+	withPersonality          *gldap.Personality
+	withClock                gldap.Clock
 
 	withMembersOf      []string
 	withTokenGroupSIDs [][]byte
@@ -98,9 +100,26 @@ type Defaults struct {
 	// AllowAnonymousBind determines if anon binds are allowed
 	AllowAnonymousBind bool
 
+	// RequiredGroups are the group DNs a user must be a (nested) member of for
+	// a simple bind to succeed (optional)
+	RequiredGroups []string
+
 	// UPNDomain is the userPrincipalName domain, which enables a
 	// userPrincipalDomain login with [username]@UPNDomain (optional)
 	UPNDomain string
+
+	// AttributeACLs restrict who can read the entries' attributes (optional)
+	AttributeACLs []AttributeACL
+
+	// RootDN and RootPassword configure the Directory's manager account,
+	// which bypasses the bind policies and attribute ACLs (optional).  See:
+	// Directory.SetRootDN(...)
+	RootDN       string
+	RootPassword string
+
+	// NamingContexts are the naming contexts hosted besides the users and
+	// groups (optional).  See: Directory.SetNamingContexts(...)
+	NamingContexts []*NamingContext
 }
 
 // WithDefaults provides an option to provide a set of defaults to
@@ -136,6 +155,19 @@ func WithDefaults(t TestingT, defaults *Defaults) Option {
 				if defaults.UPNDomain != "" {
 					o.withDefaults.UPNDomain = defaults.UPNDomain
 				}
+				if len(defaults.RequiredGroups) > 0 {
+					o.withDefaults.RequiredGroups = defaults.RequiredGroups
+				}
+				if len(defaults.AttributeACLs) > 0 {
+					o.withDefaults.AttributeACLs = defaults.AttributeACLs
+				}
+				if defaults.RootDN != "" {
+					o.withDefaults.RootDN = defaults.RootDN
+					o.withDefaults.RootPassword = defaults.RootPassword
+				}
+				if len(defaults.NamingContexts) > 0 {
+					o.withDefaults.NamingContexts = defaults.NamingContexts
+				}
 			}
 		}
 	}
@@ -217,6 +249,31 @@ func WithTokenGroups(t TestingT, tokenGroupSID ...[]byte) Option {
 	}
 }
 
+// WithPersonality provides an optional personality for the directory, which
+// makes it resemble a specific directory product: the directory will serve the
+// personality's RootDSE and its bind failures will include the personality's
+// diagnostic message.  See: gldap.PersonalityOpenLDAP, gldap.Personality389DS,
+// gldap.PersonalityEDirectory and gldap.PersonalityActiveDirectory
+func WithPersonality(t TestingT, p *gldap.Personality) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*options); ok {
+			o.withPersonality = p
+		}
+	}
+}
+
+// WithClock provides an optional clock for the directory's server, which is
+// used for the times the server records (see: gldap.WithClock).  The
+// directory's own data has no timestamps or lockout windows: an account is
+// locked by setting its state (see: Directory.SetAccountState).
+func WithClock(t TestingT, c gldap.Clock) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*options); ok {
+			o.withClock = c
+		}
+	}
+}
+
 func WithDisablePanicRecovery(t TestingT, disable bool) Option {
 	return func(o interface{}) {
 		if o, ok := o.(*options); ok {
@@ -5,6 +5,7 @@ package testdirectory
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/jimlambrt/gldap"
@@ -34,6 +35,7 @@ func TestAllOptions(t *testing.T) {
 				},
 				AllowAnonymousBind: true,
 				UPNDomain:          "domain",
+				RequiredGroups:     []string{"cn=admin,grp-dn"},
 			}))
 		testOpts := defaults(t)
 		testOpts.withLogger = testLogger
@@ -50,6 +52,7 @@ func TestAllOptions(t *testing.T) {
 		}
 		testOpts.withDefaults.AllowAnonymousBind = true
 		testOpts.withDefaults.UPNDomain = "domain"
+		testOpts.withDefaults.RequiredGroups = []string{"cn=admin,grp-dn"}
 		assert.Equal(opts, testOpts)
 	})
 	t.Run("withFirst", func(t *testing.T) {
@@ -68,6 +71,23 @@ func TestAllOptions(t *testing.T) {
 		testOpts.withDisablePanicRecovery = true
 		assert.Equal(opts, testOpts)
 	})
+	t.Run("WithPersonality", func(t *testing.T) {
+		assert := assert.New(t)
+		opts := getOpts(t, WithLogger(t, testLogger), WithPersonality(t, gldap.PersonalityEDirectory))
+		testOpts := defaults(t)
+		testOpts.withLogger = testLogger
+		testOpts.withPersonality = gldap.PersonalityEDirectory
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithClock", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := gldap.NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		opts := getOpts(t, WithLogger(t, testLogger), WithClock(t, clock))
+		testOpts := defaults(t)
+		testOpts.withLogger = testLogger
+		testOpts.withClock = clock
+		assert.Equal(opts, testOpts)
+	})
 }
 
 func Test_applyOpts(t *testing.T) {
@@ -0,0 +1,69 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package testdirectory
+
+import (
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jimlambrt/gldap"
+	"github.com/stretchr/testify/require"
+)
+
+// RootDN returns the DN of the Directory's manager account, which is empty
+// when the Directory doesn't have one (see: SetRootDN)
+func (d *Directory) RootDN() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rootDN
+}
+
+// SetRootDN sets the DN and password of the Directory's manager account (like
+// OpenLDAP's olcRootDN and olcRootPW).  The root DN isn't an entry of the
+// Directory: it can bind with its password even though there's no user entry
+// for it, and its binds aren't subject to the required groups or account
+// states.  A conn bound as the root DN bypasses the attribute ACLs, so it's
+// returned every attribute of the entries.  An empty dn removes the manager
+// account.
+func (d *Directory) SetRootDN(dn, password string) {
+	if v, ok := interface{}(d.t).(HelperT); ok {
+		v.Helper()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rootDN = dn
+	d.rootPassword = password
+	if dn == "" {
+		d.rootPassword = ""
+	}
+}
+
+// RootConn returns an *ldap.Conn that's connected to the directory (see: Conn)
+// and bound as the root DN, which is handy for managing a test's fixtures.
+func (d *Directory) RootConn() *ldap.Conn {
+	if v, ok := interface{}(d.t).(HelperT); ok {
+		v.Helper()
+	}
+	d.mu.Lock()
+	dn, password := d.rootDN, d.rootPassword
+	d.mu.Unlock()
+	require.NotEmpty(d.t, dn, "directory doesn't have a root DN")
+	conn := d.Conn()
+	require.NoError(d.t, conn.Bind(dn, password))
+	return conn
+}
+
+// isRootDN returns true if the dn is the Directory's root DN
+func (d *Directory) isRootDN(dn string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rootDN != "" && sameDN(d.rootDN, dn, d.schemaOf(d.rootDN))
+}
+
+// rootBind returns true if the simple bind is for the root DN with its
+// password.  An unauthenticated bind (without a password) is never a root
+// bind.
+func (d *Directory) rootBind(m *gldap.SimpleBindMessage) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rootDN != "" && m.Password != "" && sameDN(d.rootDN, m.UserName, d.schemaOf(d.rootDN)) && string(m.Password) == d.rootPassword
+}
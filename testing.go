@@ -4,11 +4,18 @@
 package gldap
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
 	"net"
 	"os"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 	"github.com/go-ldap/ldap/v3"
@@ -64,6 +71,62 @@ func testStartTLSRequestPacket(t *testing.T, messageID int) *packet {
 	}
 }
 
+func testExtendedOperationRequestPacket(t *testing.T, m ExtendedOperationMessage) *packet {
+	t.Helper()
+	envelope := testRequestEnvelope(t, int(m.GetID()))
+
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "Extended Request")
+	request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, string(m.Name), "Extended Request Name"))
+	if m.Value != "" {
+		request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, m.Value, "Extended Request Value"))
+	}
+	envelope.AppendChild(request)
+
+	return &packet{
+		Packet: envelope,
+	}
+}
+
+func testControlGetEffectiveRights(t *testing.T, opt ...Option) *ControlGetEffectiveRights {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlGetEffectiveRights(opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlDerefRequest(t *testing.T, specs []DerefSpec, opt ...Option) *ControlDerefRequest {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlDerefRequest(specs, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlDerefResponse(t *testing.T, results []DerefResult, opt ...Option) *ControlDerefResponse {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlDerefResponse(results, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlDirSyncRequest(t *testing.T, flags DirSyncFlag, opt ...Option) *ControlDirSyncRequest {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlDirSyncRequest(flags, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlSessionTracking(t *testing.T, formatOID, identifier string, opt ...Option) *ControlSessionTracking {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlSessionTracking(formatOID, identifier, opt...)
+	require.NoError(err)
+	return c
+}
+
 func testSearchRequestPacket(t *testing.T, s SearchMessage) *packet {
 	t.Helper()
 	require := require.New(t)
@@ -128,6 +191,18 @@ func testUnbindRequestPacket(t *testing.T, m UnbindMessage) *packet {
 	}
 }
 
+func testAbandonRequestPacket(t *testing.T, m AbandonMessage) *packet {
+	t.Helper()
+
+	envelope := testRequestEnvelope(t, int(m.GetID()))
+	pkt := ber.NewInteger(ber.ClassApplication, ber.TypePrimitive, ApplicationAbandonRequest, m.MessageID, "Abandon Request")
+	envelope.AppendChild(pkt)
+
+	return &packet{
+		Packet: envelope,
+	}
+}
+
 func testModifyRequestPacket(t *testing.T, m ModifyMessage) *packet {
 	t.Helper()
 	envelope := testRequestEnvelope(t, int(m.GetID()))
@@ -148,6 +223,26 @@ func testModifyRequestPacket(t *testing.T, m ModifyMessage) *packet {
 	}
 }
 
+func testModifyDNRequestPacket(t *testing.T, m ModifyDNMessage) *packet {
+	t.Helper()
+	envelope := testRequestEnvelope(t, int(m.GetID()))
+	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationModifyDNRequest, nil, "Modify DN Request")
+	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, m.DN, "DN"))
+	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, m.NewRDN, "New RDN"))
+	pkt.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, m.DeleteOldRDN, "Delete Old RDN"))
+	if m.NewSuperior != "" {
+		pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, m.NewSuperior, "New Superior"))
+	}
+
+	envelope.AppendChild(pkt)
+	if len(m.Controls) > 0 {
+		envelope.AppendChild(encodeControls(m.Controls))
+	}
+	return &packet{
+		Packet: envelope,
+	}
+}
+
 func testDeleteRequestPacket(t *testing.T, m DeleteMessage) *packet {
 	t.Helper()
 	envelope := testRequestEnvelope(t, int(m.GetID()))
@@ -183,6 +278,38 @@ func testAddRequestPacket(t *testing.T, m AddMessage) *packet {
 	}
 }
 
+// testCertificate returns the PEM encoded certificate and key of a new
+// self-signed certificate for the DNS names (the first one is its common
+// name).
+func testCertificate(t *testing.T, dnsNames ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	require := require.New(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	require.NoError(err)
+	serial, err := cryptorand.Int(cryptorand.Reader, big.NewInt(1<<62))
+	require.NoError(err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if len(dnsNames) > 0 {
+		tmpl.Subject.CommonName = dnsNames[0]
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(err)
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
 func testRequestEnvelope(t *testing.T, messageID int) *ber.Packet {
 	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
 	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(messageID), "MessageID"))
@@ -205,6 +332,54 @@ func testControlManageDsaIT(t *testing.T, opt ...Option) *ControlManageDsaIT {
 	return c
 }
 
+func testControlAssertion(t *testing.T, filter string, opt ...Option) *ControlAssertion {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlAssertion(filter, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlSyncRequest(t *testing.T, mode SyncRequestMode, opt ...Option) *ControlSyncRequest {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlSyncRequest(mode, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlSyncState(t *testing.T, state SyncState, entryUUID [16]byte, opt ...Option) *ControlSyncState {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlSyncState(state, entryUUID, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlSyncDone(t *testing.T, opt ...Option) *ControlSyncDone {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlSyncDone(opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlPersistentSearch(t *testing.T, changeTypes ChangeType, opt ...Option) *ControlPersistentSearch {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlPersistentSearch(changeTypes, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlEntryChangeNotification(t *testing.T, changeType ChangeType, opt ...Option) *ControlEntryChangeNotification {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlEntryChangeNotification(changeType, opt...)
+	require.NoError(err)
+	return c
+}
+
 func testControlMicrosoftNotification(t *testing.T, opt ...Option) *ControlMicrosoftNotification {
 	t.Helper()
 	require := require.New(t)
@@ -229,6 +404,22 @@ func testControlMicrosoftShowDeleted(t *testing.T, opt ...Option) *ControlMicros
 	return c
 }
 
+func testControlMicrosoftPermissiveModify(t *testing.T, opt ...Option) *ControlMicrosoftPermissiveModify {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlMicrosoftPermissiveModify(opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlMicrosoftTreeDelete(t *testing.T, opt ...Option) *ControlMicrosoftTreeDelete {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlMicrosoftTreeDelete(opt...)
+	require.NoError(err)
+	return c
+}
+
 func testControlPaging(t *testing.T, pagingSize uint32, opt ...Option) *ControlPaging {
 	t.Helper()
 	require := require.New(t)
@@ -237,6 +428,27 @@ func testControlPaging(t *testing.T, pagingSize uint32, opt ...Option) *ControlP
 	return c
 }
 
+// AssertNoLeaks asserts that the server, once it's been stopped (see: Stop and
+// Shutdown), has released all its resources: its connections are closed, the
+// handlers of their requests have returned and no connection state was set
+// once its connection was closed (i.e. the paging cursors, SASL steps or
+// persistent searches of a handler that outlived its connection, see:
+// Request.ConnectionState).  Since terminated connections finish closing in
+// the background, it waits up to a second for them before failing the test.
+func AssertNoLeaks(t *testing.T, s *Server) {
+	t.Helper()
+	require.NotNil(t, s, "missing server")
+	deadline := time.Now().Add(time.Second)
+	leaks := s.leaks()
+	for len(leaks) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		leaks = s.leaks()
+	}
+	for _, l := range leaks {
+		t.Errorf("gldap leak: %s", l)
+	}
+}
+
 // TestWithDebug specifies that the test should be run under "debug" mode
 func TestWithDebug(t *testing.T) bool {
 	t.Helper()
@@ -252,6 +464,40 @@ func TestEncodeString(t *testing.T, tag ber.Tag, s string, opt ...Option) string
 	return string(dec.Bytes())
 }
 
+// TestClock is a Clock for tests (see: WithClock), whose time only changes
+// when it's set or advanced.
+type TestClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewTestClock creates a new test clock whose current time is now.
+func NewTestClock(t *testing.T, now time.Time) *TestClock {
+	t.Helper()
+	return &TestClock{now: now}
+}
+
+// Now returns the clock's current time
+func (c *TestClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the clock's current time
+func (c *TestClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance advances the clock's current time by d
+func (c *TestClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
 type safeBuf struct {
 	buf *strings.Builder
 	mu  *sync.Mutex
@@ -276,3 +522,30 @@ func (w *safeBuf) String() string {
 	defer w.mu.Unlock()
 	return w.buf.String()
 }
+
+func testControlAuthzIDRequest(t *testing.T, opt ...Option) *ControlAuthzIDRequest {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlAuthzIDRequest(opt...)
+	require.NoError(err)
+	return c
+}
+
+func testControlAuthzIDResponse(t *testing.T, authzID string, opt ...Option) *ControlAuthzIDResponse {
+	t.Helper()
+	require := require.New(t)
+	c, err := NewControlAuthzIDResponse(authzID, opt...)
+	require.NoError(err)
+	return c
+}
+
+func testCancelRequestPacket(t *testing.T, id int, cancelID int64) *packet {
+	t.Helper()
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Cancel Request")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, cancelID, "cancelID"))
+	return testExtendedOperationRequestPacket(t, ExtendedOperationMessage{
+		baseMessage: baseMessage{id: int64(id)},
+		Name:        ExtendedOperationCancel,
+		Value:       string(seq.Bytes()),
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testServer runs a server which serves requests with the mux on a free local
+// port, and returns it along with its URL, which uses the "ldaps" scheme when
+// the options include WithTLSConfig.  The server is stopped when the test
+// finishes.
+func testServer(t *testing.T, mux *Mux, opt ...ServerOption) (*Server, string) {
+	t.Helper()
+	require := require.New(t)
+	s, err := NewServer(opt...)
+	require.NoError(err)
+	if mux != nil {
+		require.NoError(s.Router(mux))
+	}
+	port := freePort(t)
+	go func() { _ = s.Run(fmt.Sprintf("localhost:%d", port), opt...) }()
+	t.Cleanup(func() { _ = s.Stop() })
+	for !s.Ready() {
+		time.Sleep(100 * time.Nanosecond)
+	}
+	scheme := "ldap"
+	if getConfigOpts(opt...).withTLSConfig != nil {
+		scheme = "ldaps"
+	}
+	return s, fmt.Sprintf("%s://localhost:%d", scheme, port)
+}
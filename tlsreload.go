@@ -0,0 +1,211 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCertificateCheckInterval is the default minimum time between two
+// checks of a CertificateReloader's files for changes
+const DefaultCertificateCheckInterval = 10 * time.Second
+
+type certReloaderOptions struct {
+	withCheckInterval time.Duration
+	withClock         Clock
+}
+
+func certReloaderDefaults() certReloaderOptions {
+	return certReloaderOptions{
+		withCheckInterval: DefaultCertificateCheckInterval,
+		withClock:         systemClock{},
+	}
+}
+
+func getCertReloaderOpts(opt ...Option) certReloaderOptions {
+	opts := certReloaderDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithCertificateCheckInterval sets the minimum time between two checks of a
+// CertificateReloader's files for changes (the default is
+// DefaultCertificateCheckInterval).  An interval less than or equal to zero
+// checks the files on every handshake.
+func WithCertificateCheckInterval(d time.Duration) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*certReloaderOptions); ok {
+			o.withCheckInterval = d
+		}
+	}
+}
+
+// CertificateReloader serves a certificate and key pair loaded from PEM files,
+// which are re-read when they change, so a long-running server can rotate its
+// certificate without being restarted.  Its GetCertificate is used as the
+// tls.Config's GetCertificate of a TLS listener (see: WithTLSConfig) or of
+// StartTLS (see: WithStartTLS):
+//
+//	r, err := gldap.NewCertificateReloader("server.crt", "server.key")
+//	if err != nil {
+//		// handle error
+//	}
+//	go s.Run(":636", gldap.WithTLSConfig(&tls.Config{GetCertificate: r.GetCertificate}))
+//
+// The files are checked for changes (their modification time and size) during
+// a handshake at most once per check interval (see:
+// WithCertificateCheckInterval).  A changed pair that can't be loaded (i.e. the
+// certificate was replaced but not the key yet) is ignored and the last valid
+// certificate is served until the pair is valid again.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	clock    Clock
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certStat  fileStamp
+	keyStat   fileStamp
+	lastCheck time.Time
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertificateReloader creates a CertificateReloader of the certificate and
+// key files, which are loaded before it's returned.
+//
+// Options supported: WithCertificateCheckInterval, WithClock
+func NewCertificateReloader(certFile, keyFile string, opt ...Option) (*CertificateReloader, error) {
+	const op = "gldap.NewCertificateReloader"
+	switch {
+	case certFile == "":
+		return nil, fmt.Errorf("%s: missing certificate file: %w", op, ErrInvalidParameter)
+	case keyFile == "":
+		return nil, fmt.Errorf("%s: missing key file: %w", op, ErrInvalidParameter)
+	}
+	opts := getCertReloaderOpts(opt...)
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: opts.withCheckInterval,
+		clock:    opts.withClock,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return r, nil
+}
+
+// Reload re-reads the certificate and key files, regardless of the check
+// interval (i.e. when the server receives a SIGHUP).  The last valid
+// certificate is kept when an error is returned.
+func (r *CertificateReloader) Reload() error {
+	const op = "gldap.(CertificateReloader).Reload"
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetCertificate returns the current certificate, after re-reading the files
+// if they've changed since they were last loaded.  It has the signature of a
+// tls.Config's GetCertificate.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	if r.interval <= 0 || now.Sub(r.lastCheck) >= r.interval {
+		r.lastCheck = now
+		certStat, certErr := statFile(r.certFile)
+		keyStat, keyErr := statFile(r.keyFile)
+		if certErr == nil && keyErr == nil && (certStat != r.certStat || keyStat != r.keyStat) {
+			// an invalid pair is ignored until it's valid again
+			_ = r.load()
+		}
+	}
+	return r.cert, nil
+}
+
+// load reads the certificate and key files, and must be called with r.mu held
+func (r *CertificateReloader) load() error {
+	const op = "gldap.(CertificateReloader).load"
+	certStat, err := statFile(r.certFile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	keyStat, err := statFile(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("%s: unable to load key pair: %w", op, err)
+	}
+	r.cert = &cert
+	r.certStat = certStat
+	r.keyStat = keyStat
+	return nil
+}
+
+func statFile(name string) (fileStamp, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// SNIConfig returns a tls.Config which selects the tls.Config of each
+// handshake by the server name the client sent (see:
+// https://tools.ietf.org/html/rfc6066#section-3), so a server can serve a
+// different certificate (or client authentication) for each of its names.
+// The configs are keyed by server name, which is matched case-insensitively,
+// and a key with a leading "*." (i.e. "*.example.org") matches a single label
+// of a name that has no key of its own.  The fallback config is used for the
+// handshakes without a server name or with a name that doesn't match.  The
+// returned config can be used for a TLS listener (see: WithTLSConfig) or for
+// StartTLS (see: WithStartTLS).
+func SNIConfig(fallback *tls.Config, configs map[string]*tls.Config) (*tls.Config, error) {
+	const op = "gldap.SNIConfig"
+	if fallback == nil {
+		return nil, fmt.Errorf("%s: missing fallback config: %w", op, ErrInvalidParameter)
+	}
+	byName := make(map[string]*tls.Config, len(configs))
+	for name, c := range configs {
+		if name == "" || c == nil {
+			return nil, fmt.Errorf("%s: invalid config for server name %q: %w", op, name, ErrInvalidParameter)
+		}
+		byName[strings.ToLower(name)] = c
+	}
+	tc := fallback.Clone()
+	tc.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+		if name == "" {
+			return nil, nil
+		}
+		if c, ok := byName[name]; ok {
+			return c, nil
+		}
+		if _, parent, ok := strings.Cut(name, "."); ok {
+			if c, ok := byName["*."+parent]; ok {
+				return c, nil
+			}
+		}
+		// a nil config continues the handshake with the fallback
+		return nil, nil
+	}
+	return tc, nil
+}
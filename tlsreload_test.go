@@ -0,0 +1,219 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCertificate writes a new self-signed certificate for the name to the
+// files, with a modification time of mod, and returns its PEM certificate.
+func writeTestCertificate(t *testing.T, certFile, keyFile, name string, mod time.Time) []byte {
+	t.Helper()
+	certPEM, keyPEM := testCertificate(t, name)
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	require.NoError(t, os.Chtimes(certFile, mod, mod))
+	require.NoError(t, os.Chtimes(keyFile, mod, mod))
+	return certPEM
+}
+
+// certCommonName returns the common name of the certificate's leaf
+func certCommonName(t *testing.T, c *tls.Certificate) string {
+	t.Helper()
+	require.NotNil(t, c)
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestNewCertificateReloader(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertificate(t, certFile, keyFile, "a.example.org", time.Now())
+	tests := []struct {
+		name            string
+		certFile        string
+		keyFile         string
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{name: "missing-cert-file", keyFile: keyFile, wantErrIs: ErrInvalidParameter, wantErrContains: "missing certificate file"},
+		{name: "missing-key-file", certFile: certFile, wantErrIs: ErrInvalidParameter, wantErrContains: "missing key file"},
+		{name: "no-such-file", certFile: filepath.Join(dir, "missing.pem"), keyFile: keyFile, wantErrIs: os.ErrNotExist},
+		{name: "mismatched-pair", certFile: keyFile, keyFile: keyFile, wantErrContains: "unable to load key pair"},
+		{name: "valid", certFile: certFile, keyFile: keyFile},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			r, err := NewCertificateReloader(tc.certFile, tc.keyFile)
+			if tc.wantErrIs != nil || tc.wantErrContains != "" {
+				require.Error(err)
+				assert.Nil(r)
+				if tc.wantErrIs != nil {
+					assert.ErrorIs(err, tc.wantErrIs)
+				}
+				assert.Contains(err.Error(), tc.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			c, err := r.GetCertificate(nil)
+			require.NoError(err)
+			assert.Equal("a.example.org", certCommonName(t, c))
+		})
+	}
+}
+
+func TestCertificateReloader_GetCertificate(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	mod := time.Now().Add(-time.Hour)
+	writeTestCertificate(t, certFile, keyFile, "a.example.org", mod)
+	clock := NewTestClock(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	r, err := NewCertificateReloader(certFile, keyFile, WithCertificateCheckInterval(time.Minute), WithClock(clock))
+	require.NoError(err)
+	getCN := func() string {
+		c, err := r.GetCertificate(nil)
+		require.NoError(err)
+		return certCommonName(t, c)
+	}
+	assert.Equal("a.example.org", getCN())
+
+	// the files aren't checked again until the interval has passed
+	writeTestCertificate(t, certFile, keyFile, "b.example.org", mod.Add(time.Minute))
+	assert.Equal("a.example.org", getCN())
+	clock.Advance(time.Minute)
+	assert.Equal("b.example.org", getCN())
+
+	// a pair that can't be loaded is ignored
+	require.NoError(os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	clock.Advance(time.Minute)
+	assert.Equal("b.example.org", getCN())
+	assert.Error(r.Reload())
+	assert.Equal("b.example.org", getCN())
+
+	// Reload doesn't wait for the interval
+	writeTestCertificate(t, certFile, keyFile, "c.example.org", mod.Add(2*time.Minute))
+	require.NoError(r.Reload())
+	assert.Equal("c.example.org", getCN())
+}
+
+func TestServer_certificateReloader(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	mod := time.Now().Add(-time.Hour)
+	oldPEM := writeTestCertificate(t, certFile, keyFile, "localhost", mod)
+	r, err := NewCertificateReloader(certFile, keyFile, WithCertificateCheckInterval(0))
+	require.NoError(err)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux, WithTLSConfig(&tls.Config{GetCertificate: r.GetCertificate}))
+	dial := func(rootPEM []byte) error {
+		pool := x509.NewCertPool()
+		require.True(pool.AppendCertsFromPEM(rootPEM))
+		client, err := ldap.DialURL(url, ldap.DialWithTLSConfig(&tls.Config{RootCAs: pool}))
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		return client.Bind("cn=alice", "password")
+	}
+	require.NoError(dial(oldPEM))
+
+	// the rotated certificate is served without restarting the server
+	newPEM := writeTestCertificate(t, certFile, keyFile, "localhost", mod.Add(time.Minute))
+	assert.Error(dial(oldPEM))
+	assert.NoError(dial(newPEM))
+}
+
+func TestSNIConfig(t *testing.T) {
+	t.Parallel()
+	newConfig := func(name string) *tls.Config {
+		certPEM, keyPEM := testCertificate(t, name)
+		c, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+		return &tls.Config{Certificates: []tls.Certificate{c}}
+	}
+	fallback := newConfig("localhost")
+	configs := map[string]*tls.Config{
+		"LDAP.example.org": newConfig("ldap.example.org"),
+		"*.example.com":    newConfig("wildcard.example.com"),
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		_, err := SNIConfig(nil, configs)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		_, err = SNIConfig(fallback, map[string]*tls.Config{"ldap.example.org": nil})
+		assert.ErrorIs(err, ErrInvalidParameter)
+		_, err = SNIConfig(fallback, map[string]*tls.Config{"": fallback})
+		assert.ErrorIs(err, ErrInvalidParameter)
+	})
+
+	sni, err := SNIConfig(fallback, configs)
+	require.NoError(t, err)
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{serverName: "ldap.example.org", want: "ldap.example.org"},
+		{serverName: "Ldap.Example.Org.", want: "ldap.example.org"},
+		{serverName: "a.example.com", want: "wildcard.example.com"},
+		{serverName: "a.b.example.com", want: "localhost"},
+		{serverName: "other.example.org", want: "localhost"},
+		{serverName: "", want: "localhost"},
+	}
+
+	mux, err := NewMux()
+	require.NoError(t, err)
+	_, ldapsURL := testServer(t, mux, WithTLSConfig(sni))
+	_, startTLSURL := testServer(t, mux, WithStartTLS(sni))
+	u, err := url.Parse(ldapsURL)
+	require.NoError(t, err)
+	for _, tc := range tests {
+		tc := tc
+		t.Run("listener-"+tc.serverName, func(t *testing.T) {
+			t.Parallel()
+			d := &net.Dialer{Timeout: time.Second}
+			c, err := tls.DialWithDialer(d, "tcp", u.Host, &tls.Config{ServerName: tc.serverName, InsecureSkipVerify: true}) //nolint:gosec // the test checks the served certificate
+			require.NoError(t, err)
+			defer c.Close()
+			assert.Equal(t, tc.want, c.ConnectionState().PeerCertificates[0].Subject.CommonName)
+		})
+		t.Run("starttls-"+tc.serverName, func(t *testing.T) {
+			t.Parallel()
+			client, err := ldap.DialURL(startTLSURL)
+			require.NoError(t, err)
+			defer client.Close()
+			require.NoError(t, client.StartTLS(&tls.Config{ServerName: tc.serverName, InsecureSkipVerify: true})) //nolint:gosec // the test checks the served certificate
+			state, ok := client.TLSConnectionState()
+			require.True(t, ok)
+			assert.Equal(t, tc.want, state.PeerCertificates[0].Subject.CommonName)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"sync"
+)
+
+// Span attribute keys
+const (
+	// SpanAttributeConnectionID is the ID of the span's connection
+	SpanAttributeConnectionID = "ldap.connection.id"
+	// SpanAttributePeerAddress is the network address of the connection's client
+	SpanAttributePeerAddress = "network.peer.address"
+	// SpanAttributeLocalAddress is the network address the connection was
+	// accepted on
+	SpanAttributeLocalAddress = "network.local.address"
+	// SpanAttributeOperation is the operation of the span's request
+	SpanAttributeOperation = "ldap.operation"
+	// SpanAttributeMessageID is the message ID of the span's request
+	SpanAttributeMessageID = "ldap.message.id"
+	// SpanAttributeCorrelationID is the correlation ID of the span's request
+	// (see: Request.CorrelationID)
+	SpanAttributeCorrelationID = "ldap.correlation.id"
+	// SpanAttributeDN is the DN the span's request targets (i.e. a search's
+	// base DN)
+	SpanAttributeDN = "ldap.dn"
+	// SpanAttributeScope is the scope of a search
+	SpanAttributeScope = "ldap.search.scope"
+	// SpanAttributeResultCode is the result code of the request's final
+	// response
+	SpanAttributeResultCode = "ldap.result.code"
+)
+
+// SpanAttribute is a key/value attribute of a span, whose value is a string,
+// an int or an int64
+type SpanAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts the spans of a server's connections and operations (see:
+// WithTracer), so they can be recorded with a tracing library.  Its methods
+// mirror those of an OpenTelemetry trace.Tracer, so one can be adapted with a
+// few lines of code.
+type Tracer interface {
+	// Start starts a span with the name and attributes, as a child of the
+	// ctx's span when it has one, and returns a copy of the ctx which carries
+	// the span.
+	Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetAttributes sets the span's attributes
+	SetAttributes(attrs ...SpanAttribute)
+	// End ends the span
+	End()
+}
+
+// WithTracer sets a tracer which starts a span for every connection (named
+// "ldap.connection") and a child span for each operation of the connection
+// (named "ldap." and the operation, i.e. "ldap.search").  The operation's span
+// is carried by its request's context (see: Request.Context), so the spans
+// of a handler's downstream calls (i.e. to a database or an http service) are
+// its children.  An operation's span ends when its final response is
+// written, or when its handler returns without one.
+func WithTracer(t Tracer) Option {
+	return serverOption(func(o *configOptions) {
+		o.withTracer = t
+	})
+}
+
+// requestSpan is the span of a request's operation, which is shared by the
+// copies of the request (see: Request.withSearchBase)
+type requestSpan struct {
+	ctx  context.Context
+	span Span
+	once sync.Once
+}
+
+// startConnSpan starts the conn's span, whose ctx becomes the parent of the
+// conn's requests' contexts, and returns it.  It returns nil when the conn
+// doesn't have a tracer.
+func (c *conn) startConnSpan() Span {
+	if c.tracer == nil {
+		return nil
+	}
+	attrs := []SpanAttribute{{Key: SpanAttributeConnectionID, Value: c.connID}}
+	if c.remoteAddr != nil {
+		attrs = append(attrs, SpanAttribute{Key: SpanAttributePeerAddress, Value: c.remoteAddr.String()})
+	}
+	if c.localAddr != nil {
+		attrs = append(attrs, SpanAttribute{Key: SpanAttributeLocalAddress, Value: c.localAddr.String()})
+	}
+	var span Span
+	c.shutdownCtx, span = c.tracer.Start(c.shutdownCtx, "ldap.connection", attrs...)
+	return span
+}
+
+// startSpan starts the span of the request's operation, as a child of its
+// conn's span.
+func (c *conn) startSpan(r *Request) {
+	if c.tracer == nil || r == nil {
+		return
+	}
+	attrs := []SpanAttribute{
+		{Key: SpanAttributeConnectionID, Value: c.connID},
+		{Key: SpanAttributeOperation, Value: string(r.routeOp)},
+		{Key: SpanAttributeCorrelationID, Value: r.correlationID},
+	}
+	if r.message != nil {
+		attrs = append(attrs, SpanAttribute{Key: SpanAttributeMessageID, Value: r.message.GetID()})
+	}
+	if dn, ok := r.targetDN(); ok {
+		attrs = append(attrs, SpanAttribute{Key: SpanAttributeDN, Value: dn})
+	}
+	if m, ok := r.message.(*SearchMessage); ok {
+		attrs = append(attrs, SpanAttribute{Key: SpanAttributeScope, Value: int(m.Scope)})
+	}
+	ctx, span := c.tracer.Start(c.shutdownCtx, "ldap."+string(r.routeOp), attrs...)
+	r.span = &requestSpan{ctx: ctx, span: span}
+}
+
+// endSpan ends the span of the request's operation, with the request's final
+// response, which is nil for the operations without a response.  It's a no-op
+// once the span has ended.
+func (r *Request) endSpan(resp Response) {
+	if r == nil || r.span == nil {
+		return
+	}
+	r.span.once.Do(func() {
+		if d, ok := resp.(diagnosticResponse); ok {
+			r.span.span.SetAttributes(SpanAttribute{Key: SpanAttributeResultCode, Value: d.resultCode()})
+		}
+		r.span.span.End()
+	})
+}
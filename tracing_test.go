@@ -0,0 +1,128 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTracer records the spans it starts
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	tracer *testTracer
+	name   string
+	parent *testSpan
+	attrs  map[string]interface{}
+	ended  bool
+}
+
+func (tr *testTracer) Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	s := &testSpan{tracer: tr, name: name, attrs: map[string]interface{}{}}
+	s.parent, _ = ctx.Value(testSpanKey{}).(*testSpan)
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+	tr.spans = append(tr.spans, s)
+	return context.WithValue(ctx, testSpanKey{}, s), s
+}
+
+func (s *testSpan) SetAttributes(attrs ...SpanAttribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.ended = true
+}
+
+// ended returns the spans that have ended by name
+func (tr *testTracer) ended() map[string]testSpan {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	spans := map[string]testSpan{}
+	for _, s := range tr.spans {
+		if s.ended {
+			spans[s.name] = *s
+		}
+	}
+	return spans
+}
+
+func TestServer_WithTracer(t *testing.T) {
+	t.Parallel()
+	handlerSpan := make(chan *testSpan, 1)
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultInvalidCredentials)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		// the handler's context carries the operation's span
+		s, _ := r.Context().Value(testSpanKey{}).(*testSpan)
+		handlerSpan <- s
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	tr := &testTracer{}
+	_, url := testServer(t, mux, WithTracer(tr))
+
+	assert, require := assert.New(t), require.New(t)
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	assert.Error(client.Bind("cn=alice", "bad"))
+	_, err = client.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	require.NoError(err)
+	require.NoError(client.Unbind())
+	assert.Eventually(func() bool { return len(tr.ended()) == 4 }, time.Second, 10*time.Millisecond)
+
+	spans := tr.ended()
+	conn, ok := spans["ldap.connection"]
+	require.True(ok)
+	assert.Nil(conn.parent)
+	connID := conn.attrs[SpanAttributeConnectionID]
+	assert.NotZero(connID)
+	assert.NotEmpty(conn.attrs[SpanAttributePeerAddress])
+	assert.NotEmpty(conn.attrs[SpanAttributeLocalAddress])
+
+	bind := spans["ldap.bind"]
+	assert.Equal("ldap.connection", bind.parent.name)
+	assert.Equal(connID, bind.attrs[SpanAttributeConnectionID])
+	assert.Equal("bind", bind.attrs[SpanAttributeOperation])
+	assert.Equal("cn=alice", bind.attrs[SpanAttributeDN])
+	assert.Equal(ResultInvalidCredentials, bind.attrs[SpanAttributeResultCode])
+
+	search := spans["ldap.search"]
+	assert.Equal("ldap.connection", search.parent.name)
+	assert.Equal("dc=example,dc=org", search.attrs[SpanAttributeDN])
+	assert.Equal(int(WholeSubtree), search.attrs[SpanAttributeScope])
+	assert.Equal(ResultSuccess, search.attrs[SpanAttributeResultCode])
+	assert.NotZero(search.attrs[SpanAttributeMessageID])
+	assert.NotEmpty(search.attrs[SpanAttributeCorrelationID])
+	s := <-handlerSpan
+	require.NotNil(s)
+	assert.Equal("ldap.search", s.name)
+
+	// an unbind doesn't have a result
+	unbind := spans["ldap.unbind"]
+	assert.NotContains(unbind.attrs, SpanAttributeResultCode)
+}
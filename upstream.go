@@ -0,0 +1,316 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// UpstreamPool is a pool of connections to an upstream LDAP server, for
+// handlers that proxy requests to another directory.  Connections are bound
+// with the pool's credentials when they're opened (see: WithUpstreamBind),
+// rebound when they're released after a handler has bound them as someone
+// else, and health checked before they're reused after being idle (see:
+// WithUpstreamHealthCheckInterval).  For example:
+//
+//	pool, err := gldap.NewUpstreamPool("ldap://upstream.example.org", gldap.WithUpstreamBind(dn, password))
+//	...
+//	mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+//		c, err := pool.Get(r.Context())
+//		if err != nil {
+//			_ = w.Write(r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultUnavailable)))
+//			return
+//		}
+//		defer c.Release()
+//		...
+//	})
+type UpstreamPool struct {
+	addr   string
+	useTLS bool
+	opts   upstreamOptions
+
+	// sem holds a token for every open connection and idle holds the open
+	// connections which aren't in use.
+	sem  chan struct{}
+	idle chan *UpstreamConn
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewUpstreamPool creates a new pool of connections to the upstream LDAP
+// server at the ldapURL, which must have an ldap:// or ldaps:// scheme.
+//
+// Supported options: WithUpstreamMaxConns, WithUpstreamBind,
+// WithUpstreamHealthCheckInterval, WithUpstreamRequestTimeout, WithTLSConfig,
+// WithClock
+func NewUpstreamPool(ldapURL string, opt ...Option) (*UpstreamPool, error) {
+	const op = "gldap.NewUpstreamPool"
+	u, err := url.Parse(ldapURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid url %q: %s: %w", op, ldapURL, err.Error(), ErrInvalidParameter)
+	}
+	var useTLS bool
+	var defaultPort string
+	switch u.Scheme {
+	case "ldap":
+		defaultPort = ldap.DefaultLdapPort
+	case "ldaps":
+		useTLS, defaultPort = true, ldap.DefaultLdapsPort
+	default:
+		return nil, fmt.Errorf("%s: invalid url scheme %q: %w", op, u.Scheme, ErrInvalidParameter)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%s: missing host in url %q: %w", op, ldapURL, ErrInvalidParameter)
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	opts := getUpstreamOpts(opt...)
+	return &UpstreamPool{
+		addr:   net.JoinHostPort(u.Hostname(), port),
+		useTLS: useTLS,
+		opts:   opts,
+		sem:    make(chan struct{}, opts.withMaxConns),
+		idle:   make(chan *UpstreamConn, opts.withMaxConns),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Get retrieves a connection from the pool, opening a new one when there
+// aren't any idle connections and the pool has fewer than its maximum number
+// of open connections.  Otherwise, it waits until a connection is released or
+// the ctx is done.  The ctx's deadline, if it has one, is propagated as the
+// timeout of the connection's requests, and a connection isn't retrieved once
+// the deadline has passed.  The connection must be released
+// (see: UpstreamConn.Release) once the caller is done with it.
+func (p *UpstreamPool) Get(ctx context.Context) (*UpstreamConn, error) {
+	const op = "gldap.(UpstreamPool).Get"
+	if ctx == nil {
+		return nil, fmt.Errorf("%s: missing context: %w", op, ErrInvalidParameter)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			// the ctx's timer may not have fired yet
+			return nil, fmt.Errorf("%s: %w", op, context.DeadlineExceeded)
+		}
+		var c *UpstreamConn
+		select {
+		case <-p.done:
+			return nil, fmt.Errorf("%s: pool is closed: %w", op, ErrInvalidState)
+		case c = <-p.idle:
+		default:
+			select {
+			case c = <-p.idle:
+			case p.sem <- struct{}{}:
+				c, err := p.dial(ctx)
+				if err != nil {
+					<-p.sem
+					return nil, fmt.Errorf("%s: %w", op, err)
+				}
+				return c, nil
+			case <-p.done:
+				return nil, fmt.Errorf("%s: pool is closed: %w", op, ErrInvalidState)
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+			}
+		}
+		c.setTimeout(ctx)
+		if c.healthy() {
+			c.released = false
+			return c, nil
+		}
+		p.discard(c)
+	}
+}
+
+// Close closes the pool's idle connections.  Connections that are in use are
+// closed when they're released, and the pool can't be used once it's closed.
+func (p *UpstreamPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+	for {
+		select {
+		case c := <-p.idle:
+			p.discard(c)
+		default:
+			return
+		}
+	}
+}
+
+// dial opens a new connection to the upstream server and binds it with the
+// pool's credentials.
+func (p *UpstreamPool) dial(ctx context.Context) (*UpstreamConn, error) {
+	const op = "gldap.(UpstreamPool).dial"
+	var nc net.Conn
+	var err error
+	if p.useTLS {
+		d := tls.Dialer{Config: p.opts.withTLSConfig}
+		nc, err = d.DialContext(ctx, "tcp", p.addr)
+	} else {
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", p.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to dial %s: %w", op, p.addr, err)
+	}
+	lc := ldap.NewConn(nc, p.useTLS)
+	lc.Start()
+	c := &UpstreamConn{Conn: lc, pool: p}
+	c.setTimeout(ctx)
+	if p.opts.withBindDN != "" {
+		if err := lc.Bind(p.opts.withBindDN, p.opts.withBindPassword); err != nil {
+			lc.Close()
+			return nil, fmt.Errorf("%s: unable to bind: %w", op, err)
+		}
+	}
+	return c, nil
+}
+
+// put returns a released connection to the pool's idle connections
+func (p *UpstreamPool) put(c *UpstreamConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.discard(c)
+		return
+	}
+	c.lastUsed = p.opts.withClock.Now()
+	// idle can't be full, since it has room for every open connection
+	p.idle <- c
+}
+
+// discard closes the connection and frees its slot in the pool
+func (p *UpstreamPool) discard(c *UpstreamConn) {
+	c.Conn.Close()
+	<-p.sem
+}
+
+// UpstreamConn is a connection retrieved from an UpstreamPool, which must be
+// released once the caller is done with it (see: UpstreamConn.Release).
+type UpstreamConn struct {
+	*ldap.Conn
+	pool     *UpstreamPool
+	lastUsed time.Time
+	rebind   bool
+	released bool
+}
+
+// Bind performs a bind with the username and password, and the connection is
+// rebound with the pool's credentials when it's released.
+func (c *UpstreamConn) Bind(username, password string) error {
+	c.rebind = true
+	return c.Conn.Bind(username, password)
+}
+
+// SimpleBind performs a simple bind with the request, and the connection is
+// rebound with the pool's credentials when it's released.
+func (c *UpstreamConn) SimpleBind(r *ldap.SimpleBindRequest) (*ldap.SimpleBindResult, error) {
+	c.rebind = true
+	return c.Conn.SimpleBind(r)
+}
+
+// UnauthenticatedBind performs an unauthenticated bind with the username, and
+// the connection is rebound with the pool's credentials when it's released.
+func (c *UpstreamConn) UnauthenticatedBind(username string) error {
+	c.rebind = true
+	return c.Conn.UnauthenticatedBind(username)
+}
+
+// Release returns the connection to its pool.  A connection that was bound
+// by the caller is rebound with the pool's credentials, and a connection that
+// is closing or can't be rebound is closed rather than reused.  Release can
+// be called more than once, but the connection must not be used after it's
+// released.
+func (c *UpstreamConn) Release() {
+	if c.released {
+		return
+	}
+	c.released = true
+	if c.Conn.IsClosing() {
+		c.pool.discard(c)
+		return
+	}
+	c.Conn.SetTimeout(c.pool.opts.withRequestTimeout)
+	if c.rebind {
+		var err error
+		switch {
+		case c.pool.opts.withBindDN != "":
+			err = c.Conn.Bind(c.pool.opts.withBindDN, c.pool.opts.withBindPassword)
+		default:
+			err = c.Conn.UnauthenticatedBind("")
+		}
+		if err != nil {
+			c.pool.discard(c)
+			return
+		}
+		c.rebind = false
+	}
+	c.pool.put(c)
+}
+
+// Discard closes the connection rather than returning it to its pool, which
+// should be used when the connection is in a state that another caller
+// shouldn't inherit.  Discard can be called more than once, but the
+// connection must not be used after it's discarded.
+func (c *UpstreamConn) Discard() {
+	if c.released {
+		return
+	}
+	c.released = true
+	c.pool.discard(c)
+}
+
+// minUpstreamRequestTimeout is the timeout of a connection's requests when its
+// ctx's deadline is about to pass, since requests whose timeout isn't positive
+// never time out.
+const minUpstreamRequestTimeout = time.Millisecond
+
+// setTimeout sets the timeout of the connection's requests to the time
+// remaining until the ctx's deadline, or the pool's request timeout when the
+// ctx has no deadline.
+func (c *UpstreamConn) setTimeout(ctx context.Context) {
+	timeout := c.pool.opts.withRequestTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout < minUpstreamRequestTimeout {
+			timeout = minUpstreamRequestTimeout
+		}
+	}
+	c.Conn.SetTimeout(timeout)
+}
+
+// healthy returns true if the connection can be reused.  A connection that has
+// been idle for the pool's health check interval is probed with a search of
+// the root DSE, and any response from the upstream server (including an
+// error result) means it's healthy.
+func (c *UpstreamConn) healthy() bool {
+	if c.Conn.IsClosing() {
+		return false
+	}
+	if c.pool.opts.withClock.Now().Sub(c.lastUsed) < c.pool.opts.withHealthCheckInterval {
+		return true
+	}
+	_, err := c.Conn.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"1.1"}, nil))
+	return err == nil || !ldap.IsErrorWithCode(err, ldap.ErrorNetwork)
+}
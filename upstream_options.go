@@ -0,0 +1,89 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// DefaultUpstreamMaxConns is the default maximum number of open connections
+// of an UpstreamPool
+const DefaultUpstreamMaxConns = 10
+
+// DefaultUpstreamHealthCheckInterval is the default idle time after which an
+// UpstreamPool's connections are health checked before they're reused
+const DefaultUpstreamHealthCheckInterval = 30 * time.Second
+
+type upstreamOptions struct {
+	withTLSConfig           *tls.Config
+	withMaxConns            int
+	withBindDN              string
+	withBindPassword        string
+	withHealthCheckInterval time.Duration
+	withRequestTimeout      time.Duration
+	withClock               Clock
+}
+
+func upstreamDefaults() upstreamOptions {
+	return upstreamOptions{
+		withMaxConns:            DefaultUpstreamMaxConns,
+		withHealthCheckInterval: DefaultUpstreamHealthCheckInterval,
+		withClock:               systemClock{},
+		withRequestTimeout:      ldap.DefaultTimeout,
+	}
+}
+
+func getUpstreamOpts(opt ...Option) upstreamOptions {
+	opts := upstreamDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// WithUpstreamMaxConns sets the maximum number of open connections of an
+// UpstreamPool (the default is DefaultUpstreamMaxConns)
+func WithUpstreamMaxConns(n int) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*upstreamOptions); ok && n > 0 {
+			o.withMaxConns = n
+		}
+	}
+}
+
+// WithUpstreamBind sets the credentials an UpstreamPool's connections are
+// bound with when they're opened, and rebound with when they're released
+// after a handler has bound them as someone else.
+func WithUpstreamBind(dn string, password string) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*upstreamOptions); ok {
+			o.withBindDN = dn
+			o.withBindPassword = password
+		}
+	}
+}
+
+// WithUpstreamHealthCheckInterval sets the idle time after which an
+// UpstreamPool's connections are health checked before they're reused (the
+// default is DefaultUpstreamHealthCheckInterval).  Zero health checks
+// connections every time they're reused.
+func WithUpstreamHealthCheckInterval(d time.Duration) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*upstreamOptions); ok && d >= 0 {
+			o.withHealthCheckInterval = d
+		}
+	}
+}
+
+// WithUpstreamRequestTimeout sets the timeout of the requests made with an
+// UpstreamPool's connections when the context they were retrieved with has no
+// deadline (the default is ldap.DefaultTimeout)
+func WithUpstreamRequestTimeout(d time.Duration) Option {
+	return func(o interface{}) {
+		if o, ok := o.(*upstreamOptions); ok && d > 0 {
+			o.withRequestTimeout = d
+		}
+	}
+}
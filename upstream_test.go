@@ -0,0 +1,262 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUpstreamPool(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		url      string
+		wantAddr string
+		wantTLS  bool
+		wantErr  bool
+	}{
+		{name: "ldap", url: "ldap://localhost", wantAddr: "localhost:389"},
+		{name: "ldaps", url: "ldaps://localhost", wantAddr: "localhost:636", wantTLS: true},
+		{name: "port", url: "ldap://localhost:1389", wantAddr: "localhost:1389"},
+		{name: "invalid-url", url: "ldap://local host:%", wantErr: true},
+		{name: "invalid-scheme", url: "http://localhost", wantErr: true},
+		{name: "missing-host", url: "ldap://", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			p, err := NewUpstreamPool(tc.url)
+			if tc.wantErr {
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.wantAddr, p.addr)
+			assert.Equal(tc.wantTLS, p.useTLS)
+		})
+	}
+}
+
+func Test_getUpstreamOpts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	tc := &tls.Config{}
+	opts := getUpstreamOpts(
+		WithUpstreamMaxConns(2),
+		WithUpstreamBind("cn=proxy", "password"),
+		WithUpstreamHealthCheckInterval(time.Second),
+		WithUpstreamRequestTimeout(time.Minute),
+		WithTLSConfig(tc),
+	)
+	testOpts := upstreamDefaults()
+	testOpts.withMaxConns = 2
+	testOpts.withBindDN = "cn=proxy"
+	testOpts.withBindPassword = "password"
+	testOpts.withHealthCheckInterval = time.Second
+	testOpts.withRequestTimeout = time.Minute
+	testOpts.withTLSConfig = tc
+	assert.Equal(opts, testOpts)
+
+	// invalid values are ignored
+	opts = getUpstreamOpts(WithUpstreamMaxConns(0), WithUpstreamHealthCheckInterval(-1), WithUpstreamRequestTimeout(0))
+	assert.Equal(upstreamDefaults(), opts)
+}
+
+func TestUpstreamPool(t *testing.T) {
+	t.Parallel()
+
+	// the upstream server records the DNs of its binds
+	var bindsMu sync.Mutex
+	var binds []string
+	gotBinds := func() []string {
+		bindsMu.Lock()
+		defer bindsMu.Unlock()
+		return append([]string{}, binds...)
+	}
+	mux, err := NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultProtocolError)))
+			return
+		}
+		bindsMu.Lock()
+		binds = append(binds, m.UserName)
+		bindsMu.Unlock()
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(t, mux.Search(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewSearchResponseEntry("cn=alice,dc=example,dc=org"))
+		_ = w.Write(r.NewSearchDoneResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, upstreamURL := testServer(t, mux)
+
+	t.Run("reuse", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(upstreamURL, WithUpstreamBind("cn=reuse", "password"))
+		require.NoError(err)
+		defer p.Close()
+
+		c, err := p.Get(context.Background())
+		require.NoError(err)
+		res, err := c.Search(ldap.NewSearchRequest("dc=example,dc=org", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		require.NoError(err)
+		assert.Len(res.Entries, 1)
+		first := c.Conn
+		c.Release()
+		c.Release() // releasing more than once is a no-op
+
+		c, err = p.Get(context.Background())
+		require.NoError(err)
+		defer c.Release()
+		assert.Same(first, c.Conn)
+		assert.Equal([]string{"cn=reuse"}, filterDNs(gotBinds(), "cn=reuse"))
+	})
+	t.Run("rebind", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(upstreamURL, WithUpstreamBind("cn=rebind", "password"))
+		require.NoError(err)
+		defer p.Close()
+
+		c, err := p.Get(context.Background())
+		require.NoError(err)
+		require.NoError(c.Bind("cn=rebind-user", "password"))
+		c.Release()
+		assert.Equal([]string{"cn=rebind", "cn=rebind-user", "cn=rebind"}, filterDNs(gotBinds(), "cn=rebind", "cn=rebind-user"))
+	})
+	t.Run("max-conns", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(upstreamURL, WithUpstreamMaxConns(1))
+		require.NoError(err)
+		defer p.Close()
+
+		c, err := p.Get(context.Background())
+		require.NoError(err)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = p.Get(ctx)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			c.Release()
+		}()
+		got, err := p.Get(context.Background())
+		require.NoError(err)
+		defer got.Release()
+		assert.Same(c.Conn, got.Conn)
+	})
+	t.Run("health-check", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(upstreamURL, WithUpstreamMaxConns(1), WithUpstreamHealthCheckInterval(0))
+		require.NoError(err)
+		defer p.Close()
+
+		c, err := p.Get(context.Background())
+		require.NoError(err)
+		dead := c.Conn
+		c.Release()
+		dead.Close()
+
+		c, err = p.Get(context.Background())
+		require.NoError(err)
+		defer c.Release()
+		assert.NotSame(dead, c.Conn)
+	})
+	t.Run("discard", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(upstreamURL, WithUpstreamMaxConns(1))
+		require.NoError(err)
+		defer p.Close()
+
+		c, err := p.Get(context.Background())
+		require.NoError(err)
+		discarded := c.Conn
+		c.Discard()
+		assert.True(discarded.IsClosing())
+
+		c, err = p.Get(context.Background())
+		require.NoError(err)
+		defer c.Release()
+		assert.NotSame(discarded, c.Conn)
+	})
+	t.Run("closed", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(upstreamURL)
+		require.NoError(err)
+		c, err := p.Get(context.Background())
+		require.NoError(err)
+		p.Close()
+		c.Release()
+		assert.True(c.Conn.IsClosing())
+
+		_, err = p.Get(context.Background())
+		assert.ErrorIs(err, ErrInvalidState)
+	})
+	t.Run("expired-deadline", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(upstreamURL)
+		require.NoError(err)
+		defer p.Close()
+		c, err := p.Get(context.Background())
+		require.NoError(err)
+		c.Release()
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		_, err = p.Get(ctx)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+		// a ctx whose deadline has passed before its timer has fired
+		_, err = p.Get(pastDeadlineCtx{Context: context.Background()})
+		assert.ErrorIs(err, context.DeadlineExceeded)
+		assert.Len(p.idle, 1)
+	})
+	t.Run("dial-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p, err := NewUpstreamPool(fmt.Sprintf("ldap://localhost:%d", freePort(t)), WithUpstreamMaxConns(1))
+		require.NoError(err)
+		defer p.Close()
+		_, err = p.Get(context.Background())
+		assert.Error(err)
+		// the failed dial doesn't hold the pool's only slot
+		_, err = p.Get(context.Background())
+		assert.Error(err)
+		assert.Len(p.sem, 0)
+	})
+}
+
+// pastDeadlineCtx is a context whose deadline has passed, but which isn't done
+type pastDeadlineCtx struct {
+	context.Context
+}
+
+func (pastDeadlineCtx) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Second), true
+}
+
+// filterDNs returns the dns which are one of the wanted dns, in order
+func filterDNs(dns []string, wanted ...string) []string {
+	var filtered []string
+	for _, dn := range dns {
+		for _, w := range wanted {
+			if dn == w {
+				filtered = append(filtered, dn)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,235 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"fmt"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Attributes returned by a server in response to a get effective rights
+// control (see: ControlGetEffectiveRights)
+const (
+	AttributeEntryLevelRights     = "entryLevelRights"
+	AttributeAttributeLevelRights = "attributeLevelRights"
+)
+
+// ControlGetEffectiveRights implements the get effective rights control
+// supported by Netscape derived servers (i.e. 389 Directory Server), which
+// asks the server to return the rights an authorization identity has to the
+// entries returned by a search (see:
+// https://tools.ietf.org/html/draft-ietf-ldapext-acl-model-08).  The rights
+// are returned using the AttributeEntryLevelRights and
+// AttributeAttributeLevelRights attributes of each entry.
+type ControlGetEffectiveRights struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// AuthzID is the authorization identity (i.e. "dn:cn=alice,...") whose
+	// rights are requested or empty for the bound identity
+	AuthzID string
+	// Attributes are the attributes whose rights are requested
+	Attributes []string
+}
+
+// GetControlType returns the OID
+func (c *ControlGetEffectiveRights) GetControlType() string {
+	return ControlTypeGetEffectiveRights
+}
+
+// Encode returns the ber packet representation
+func (c *ControlGetEffectiveRights) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeGetEffectiveRights, "Control Type ("+ControlTypeMap[ControlTypeGetEffectiveRights]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Get Effective Rights Value")
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.AuthzID, "AuthzID"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, a := range c.Attributes {
+		attrs.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a, "Attribute"))
+	}
+	seq.AppendChild(attrs)
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Get Effective Rights)")
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlGetEffectiveRights) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  AuthzID: %q  Attributes: %s",
+		ControlTypeMap[ControlTypeGetEffectiveRights],
+		ControlTypeGetEffectiveRights,
+		c.Criticality,
+		c.AuthzID,
+		c.Attributes)
+}
+
+// NewControlGetEffectiveRights returns a get effective rights control.
+// Supported options: WithCriticality, WithAuthzID and WithRightsAttributes
+func NewControlGetEffectiveRights(opt ...Option) (*ControlGetEffectiveRights, error) {
+	opts := getControlOpts(opt...)
+	return &ControlGetEffectiveRights{
+		Criticality: opts.withCriticality,
+		AuthzID:     opts.withAuthzID,
+		Attributes:  opts.withRightsAttributes,
+	}, nil
+}
+
+func decodeControlGetEffectiveRights(value *ber.Packet, criticality bool) (*ControlGetEffectiveRights, error) {
+	const op = "gldap.decodeControlGetEffectiveRights"
+	seq, err := decodeControlValueSequence(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) == 0 || len(seq.Children) > 2 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in get effective rights control: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	opts := []Option{WithCriticality(criticality), WithAuthzID(seq.Children[0].Data.String())}
+	if len(seq.Children) == 2 {
+		attrs := make([]string, 0, len(seq.Children[1].Children))
+		for _, a := range seq.Children[1].Children {
+			attrs = append(attrs, a.Data.String())
+		}
+		opts = append(opts, WithRightsAttributes(attrs...))
+	}
+	c, err := NewControlGetEffectiveRights(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+// GetEffectiveRightsControl returns the request's get effective rights control
+// and false if the request doesn't have one.
+func (r *Request) GetEffectiveRightsControl() (*ControlGetEffectiveRights, bool) {
+	c, ok := r.findControl(ControlTypeGetEffectiveRights).(*ControlGetEffectiveRights)
+	return c, ok
+}
+
+// TurnMessage is an LDAP turn extended operation request message (see:
+// https://tools.ietf.org/html/rfc4531)
+type TurnMessage struct {
+	baseMessage
+	// Mutual indicates the client requests a mutual turn, which defaults to
+	// true
+	Mutual bool
+	// Identifier of the community the client is a member of
+	Identifier string
+}
+
+// GetTurnMessage retrieves the TurnMessage from an ExtendedOperationTurn
+// request
+func (r *Request) GetTurnMessage() (*TurnMessage, error) {
+	const op = "gldap.(Request).GetTurnMessage"
+	seq, err := r.extendedOperationSequence(ExtendedOperationTurn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	m := &TurnMessage{
+		baseMessage: baseMessage{id: r.message.GetID()},
+		Mutual:      true,
+	}
+	for _, child := range seq.Children {
+		switch child.Tag {
+		case ber.TagBoolean:
+			m.Mutual, _ = child.Value.(bool)
+		case ber.TagOctetString:
+			m.Identifier = child.Data.String()
+		default:
+			return nil, fmt.Errorf("%s: unexpected tag %d in turn request: %w", op, child.Tag, ErrInvalidParameter)
+		}
+	}
+	if m.Identifier == "" {
+		return nil, fmt.Errorf("%s: missing identifier: %w", op, ErrInvalidParameter)
+	}
+	return m, nil
+}
+
+// CancelMessage is an LDAP cancel extended operation request message (see:
+// https://tools.ietf.org/html/rfc3909)
+type CancelMessage struct {
+	baseMessage
+	// CancelID is the message ID of the request to cancel
+	CancelID int64
+}
+
+// GetCancelMessage retrieves the CancelMessage from an
+// ExtendedOperationCancel request
+func (r *Request) GetCancelMessage() (*CancelMessage, error) {
+	const op = "gldap.(Request).GetCancelMessage"
+	seq, err := r.extendedOperationSequence(ExtendedOperationCancel)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(seq.Children) != 1 {
+		return nil, fmt.Errorf("%s: invalid number of children (%d) in cancel request: %w", op, len(seq.Children), ErrInvalidParameter)
+	}
+	id, ok := seq.Children[0].Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid cancel ID: %w", op, ErrInvalidParameter)
+	}
+	return &CancelMessage{
+		baseMessage: baseMessage{id: r.message.GetID()},
+		CancelID:    id,
+	}, nil
+}
+
+// extendedOperationSequence returns the sequence encoded in the request
+// value of the named extended operation
+func (r *Request) extendedOperationSequence(name ExtendedOperationName) (*ber.Packet, error) {
+	const op = "gldap.(Request).extendedOperationSequence"
+	m, err := r.GetExtendedOperationMessage()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if m.Name != name {
+		return nil, fmt.Errorf("%s: %s is not a %s request: %w", op, m.Name, name, ErrInvalidParameter)
+	}
+	if m.Value == "" {
+		return nil, fmt.Errorf("%s: missing request value: %w", op, ErrInvalidParameter)
+	}
+	seq, err := ber.DecodePacketErr([]byte(m.Value))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to decode request value: %w", op, err)
+	}
+	if seq.Tag != ber.TagSequence {
+		return nil, fmt.Errorf("%s: request value is not a sequence: %w", op, ErrInvalidParameter)
+	}
+	return seq, nil
+}
+
+// NewWhoAmIResponse creates a response to a "Who am I?" request (see:
+// https://tools.ietf.org/html/rfc4532) or its eDirectory variant
+// (ExtendedOperationGetBindDN) with the authorization identity of the
+// request's connection.  The authzID should be "dn:<dn>", "u:<user>", or empty
+// for an anonymous connection.
+// Supported options: WithResponseCode, WithDiagnosticMessage
+func (r *Request) NewWhoAmIResponse(authzID string, opt ...ResponseOption) (*ExtendedResponse, error) {
+	const op = "gldap.(Request).NewWhoAmIResponse"
+	if err := unsupportedOptions(op, "a response", &responseOptions{}, opt...); err != nil {
+		return nil, err
+	}
+	opts := getResponseOpts(opt...)
+	if opts.withResponseCode == nil {
+		opts.withResponseCode = intPtr(ResultSuccess)
+	}
+	resp := r.NewExtendedResponse(WithResponseCode(*opts.withResponseCode))
+	resp.SetDiagnosticMessage(opts.withDiagnosticMessage)
+	switch r.extendedName {
+	case ExtendedOperationWhoAmI:
+		resp.SetResponseValue([]byte(authzID))
+	case ExtendedOperationGetBindDN:
+		resp.SetResponseName(ExtendedOperationGetBindDNResponse)
+		dn := strings.TrimPrefix(authzID, "dn:")
+		resp.SetResponseValue(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Identity").Bytes())
+	default:
+		return nil, fmt.Errorf("%s: %s is not a who am i request: %w", op, r.extendedName, ErrInvalidParameter)
+	}
+	return resp, nil
+}
@@ -0,0 +1,231 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlGetEffectiveRights(t *testing.T) {
+	runControlTest(t,
+		testControlGetEffectiveRights(t, WithCriticality(true), WithAuthzID("dn:cn=alice,ou=people,dc=example,dc=org"), WithRightsAttributes("cn", "mail")),
+		withTestType(ControlTypeGetEffectiveRights),
+		withTestToString("Control Type: Get Effective Rights (\"1.3.6.1.4.1.42.2.27.9.5.2\")  Criticality: true  AuthzID: \"dn:cn=alice,ou=people,dc=example,dc=org\"  Attributes: [cn mail]"),
+	)
+	runControlTest(t, testControlGetEffectiveRights(t, WithAuthzID("dn:")))
+}
+
+func TestRequest_GetEffectiveRightsControl(t *testing.T) {
+	assert := assert.New(t)
+	ger := testControlGetEffectiveRights(t, WithAuthzID("dn:cn=alice"))
+	r := &Request{message: &SearchMessage{Controls: []Control{ger}}}
+	got, ok := r.GetEffectiveRightsControl()
+	assert.True(ok)
+	assert.Equal(ger, got)
+
+	r = &Request{message: &SearchMessage{}}
+	_, ok = r.GetEffectiveRightsControl()
+	assert.False(ok)
+}
+
+func TestRequest_GetTurnMessage(t *testing.T) {
+	turnValue := func(mutual *bool, identifier string) string {
+		seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Turn Request")
+		if mutual != nil {
+			seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, *mutual, "Mutual"))
+		}
+		if identifier != "" {
+			seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, identifier, "Identifier"))
+		}
+		return string(seq.Bytes())
+	}
+	notMutual := false
+	tests := []struct {
+		name            string
+		r               *Request
+		want            *TurnMessage
+		wantErrContains string
+	}{
+		{
+			name:            "not-extended",
+			r:               &Request{message: &SearchMessage{}},
+			wantErrContains: "not an extended operation request",
+		},
+		{
+			name:            "not-turn",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationWhoAmI}},
+			wantErrContains: "is not a 1.3.6.1.1.19 request",
+		},
+		{
+			name:            "missing-value",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationTurn}},
+			wantErrContains: "missing request value",
+		},
+		{
+			name:            "missing-identifier",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationTurn, Value: turnValue(nil, "")}},
+			wantErrContains: "missing identifier",
+		},
+		{
+			name: "default-mutual",
+			r:    &Request{message: &ExtendedOperationMessage{baseMessage: baseMessage{id: 2}, Name: ExtendedOperationTurn, Value: turnValue(nil, "example")}},
+			want: &TurnMessage{baseMessage: baseMessage{id: 2}, Mutual: true, Identifier: "example"},
+		},
+		{
+			name: "not-mutual",
+			r:    &Request{message: &ExtendedOperationMessage{baseMessage: baseMessage{id: 2}, Name: ExtendedOperationTurn, Value: turnValue(&notMutual, "example")}},
+			want: &TurnMessage{baseMessage: baseMessage{id: 2}, Mutual: false, Identifier: "example"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := tc.r.GetTurnMessage()
+			if tc.wantErrContains != "" {
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tc.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestRequest_GetCancelMessage(t *testing.T) {
+	cancelValue := func(children ...*ber.Packet) string {
+		seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Cancel Request")
+		for _, c := range children {
+			seq.AppendChild(c)
+		}
+		return string(seq.Bytes())
+	}
+	tests := []struct {
+		name            string
+		r               *Request
+		want            *CancelMessage
+		wantErrContains string
+	}{
+		{
+			name:            "not-cancel",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationWhoAmI}},
+			wantErrContains: "is not a 1.3.6.1.1.8 request",
+		},
+		{
+			name:            "missing-cancel-id",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationCancel, Value: cancelValue()}},
+			wantErrContains: "invalid number of children",
+		},
+		{
+			name:            "invalid-cancel-id",
+			r:               &Request{message: &ExtendedOperationMessage{Name: ExtendedOperationCancel, Value: cancelValue(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "1", "cancelID"))}},
+			wantErrContains: "invalid cancel ID",
+		},
+		{
+			name: "valid",
+			r:    &Request{message: &ExtendedOperationMessage{baseMessage: baseMessage{id: 3}, Name: ExtendedOperationCancel, Value: cancelValue(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(2), "cancelID"))}},
+			want: &CancelMessage{baseMessage: baseMessage{id: 3}, CancelID: 2},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := tc.r.GetCancelMessage()
+			if tc.wantErrContains != "" {
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tc.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestRequest_NewWhoAmIResponse(t *testing.T) {
+	t.Run("get-bind-dn", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		r := &Request{message: &ExtendedOperationMessage{baseMessage: baseMessage{id: 1}}, extendedName: ExtendedOperationGetBindDN}
+		resp, err := r.NewWhoAmIResponse("dn:cn=alice,ou=people,dc=example,dc=org")
+		require.NoError(err)
+		assert.Equal(ExtendedOperationGetBindDNResponse, resp.name)
+		identity, err := ber.DecodePacketErr(resp.value)
+		require.NoError(err)
+		assert.Equal("cn=alice,ou=people,dc=example,dc=org", identity.Data.String())
+	})
+	t.Run("not-who-am-i", func(t *testing.T) {
+		r := &Request{message: &ExtendedOperationMessage{baseMessage: baseMessage{id: 1}}, extendedName: ExtendedOperationStartTLS}
+		_, err := r.NewWhoAmIResponse("dn:cn=alice")
+		assert.ErrorIs(t, err, ErrInvalidParameter)
+	})
+	t.Run("who-am-i", func(t *testing.T) {
+		t.Parallel()
+		assert, require := assert.New(t), require.New(t)
+		mux, err := NewMux()
+		require.NoError(err)
+		require.NoError(mux.ExtendedOperation(func(w *ResponseWriter, r *Request) {
+			resp, err := r.NewWhoAmIResponse("dn:cn=alice,ou=people,dc=example,dc=org")
+			if err != nil {
+				_ = w.Write(r.NewExtendedResponse(WithResponseCode(ResultOperationsError)))
+				return
+			}
+			_ = w.Write(resp)
+		}, ExtendedOperationWhoAmI))
+		_, url := testServer(t, mux)
+
+		client, err := ldap.DialURL(url)
+		require.NoError(err)
+		defer client.Close()
+		got, err := client.WhoAmI(nil)
+		require.NoError(err)
+		assert.Equal("dn:cn=alice,ou=people,dc=example,dc=org", got.AuthzID)
+	})
+}
+
+func TestServer_WithAutoWhoAmI(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Bind(func(w *ResponseWriter, r *Request) {
+		m, err := r.GetSimpleBindMessage()
+		if err != nil || (m.Password != "" && m.Password != "password") {
+			_ = w.Write(r.NewBindResponse(WithResponseCode(ResultInvalidCredentials)))
+			return
+		}
+		_ = w.Write(r.NewBindResponse(WithResponseCode(ResultSuccess)))
+	}))
+	_, url := testServer(t, mux, WithAutoWhoAmI())
+
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	whoAmI := func() string {
+		t.Helper()
+		got, err := client.WhoAmI(nil)
+		require.NoError(err)
+		return got.AuthzID
+	}
+	assert.Empty(whoAmI())
+
+	require.NoError(client.Bind("cn=alice,ou=people,dc=example,dc=org", "password"))
+	assert.Equal("dn:cn=alice,ou=people,dc=example,dc=org", whoAmI())
+
+	// a failed bind leaves the connection anonymous
+	require.Error(client.Bind("cn=bob,ou=people,dc=example,dc=org", "invalid"))
+	assert.Empty(whoAmI())
+
+	require.NoError(client.Bind("cn=alice,ou=people,dc=example,dc=org", "password"))
+	require.NoError(client.UnauthenticatedBind("cn=alice,ou=people,dc=example,dc=org"))
+	assert.Empty(whoAmI())
+}
@@ -0,0 +1,204 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// WriteThrough forwards a server's successful add, modify, modify DN and
+// delete requests to an upstream LDAP server (see: WithWriteThrough), so the
+// server's handlers can apply mutations to a local backend while the upstream
+// server is kept in sync (i.e. for migration rehearsals or a facade that keeps
+// working when the upstream server is offline).  A request is forwarded,
+// with the credentials of the UpstreamPool's connections, before its response
+// is written to the client.  Requests that can't be forwarded because the
+// upstream server is unavailable are queued and forwarded in order by the
+// next request or Flush.  Request controls aren't forwarded.
+type WriteThrough struct {
+	pool  *UpstreamPool
+	clock Clock
+
+	// flushMu serializes forwarding, so writes are forwarded in the order
+	// they were queued
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	pending []*PendingWrite
+}
+
+// PendingWrite is a write that a WriteThrough has queued, since it couldn't be
+// forwarded to the upstream server.
+type PendingWrite struct {
+	// Operation of the write
+	Operation RouteOperation
+	// DN of the entry being written
+	DN string
+	// QueuedAt is when the write was queued
+	QueuedAt time.Time
+	// Err is the error of the last attempt to forward the write
+	Err error
+
+	// forwardFn forwards the write using the upstream conn
+	forwardFn func(c *UpstreamConn) error
+}
+
+type writeThroughOptions struct {
+	withClock Clock
+}
+
+func writeThroughDefaults() writeThroughOptions {
+	return writeThroughOptions{
+		withClock: systemClock{},
+	}
+}
+
+func getWriteThroughOpts(opt ...Option) writeThroughOptions {
+	opts := writeThroughDefaults()
+	applyOpts(&opts, opt...)
+	return opts
+}
+
+// NewWriteThrough creates a new write-through which forwards writes to the
+// upstream server of the pool.
+//
+// Supported options: WithClock
+func NewWriteThrough(pool *UpstreamPool, opt ...Option) (*WriteThrough, error) {
+	const op = "gldap.NewWriteThrough"
+	if pool == nil {
+		return nil, fmt.Errorf("%s: missing upstream pool: %w", op, ErrInvalidParameter)
+	}
+	opts := getWriteThroughOpts(opt...)
+	return &WriteThrough{pool: pool, clock: opts.withClock}, nil
+}
+
+// Pending returns the queued writes which haven't been forwarded, oldest first.
+func (wt *WriteThrough) Pending() []PendingWrite {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	pending := make([]PendingWrite, 0, len(wt.pending))
+	for _, pw := range wt.pending {
+		pending = append(pending, *pw)
+	}
+	return pending
+}
+
+// Flush forwards the queued writes in order.  It stops at the first write that
+// can't be forwarded because the upstream server is unavailable, which
+// remains queued.  Writes that are rejected by the upstream server are
+// removed from the queue, and their errors are returned.
+func (wt *WriteThrough) Flush(ctx context.Context) error {
+	const op = "gldap.(WriteThrough).Flush"
+	wt.flushMu.Lock()
+	defer wt.flushMu.Unlock()
+	var rejected []error
+	for {
+		wt.mu.Lock()
+		if len(wt.pending) == 0 {
+			wt.mu.Unlock()
+			break
+		}
+		pw := wt.pending[0]
+		wt.mu.Unlock()
+
+		err := wt.forward(ctx, pw)
+		if err != nil && isUpstreamUnavailable(err) {
+			wt.mu.Lock()
+			pw.Err = err
+			wt.mu.Unlock()
+			return fmt.Errorf("%s: upstream unavailable: %w", op, err)
+		}
+		if err != nil {
+			rejected = append(rejected, fmt.Errorf("%s: %s %q rejected: %w", op, pw.Operation, pw.DN, err))
+		}
+		wt.mu.Lock()
+		wt.pending = wt.pending[1:]
+		wt.mu.Unlock()
+	}
+	return errors.Join(rejected...)
+}
+
+// forwardRequest queues the write of a successful add, modify, modify DN or
+// delete request and then flushes the queue.  Other requests are ignored.
+func (wt *WriteThrough) forwardRequest(ctx context.Context, r *Request) error {
+	const op = "gldap.(WriteThrough).forwardRequest"
+	pw := &PendingWrite{Operation: r.routeOp, QueuedAt: wt.clock.Now()}
+	switch m := r.message.(type) {
+	case *AddMessage:
+		req := ldap.NewAddRequest(m.DN, nil)
+		for _, a := range m.Attributes {
+			req.Attribute(a.Type, a.Vals)
+		}
+		pw.DN = m.DN
+		pw.forwardFn = func(c *UpstreamConn) error { return c.Add(req) }
+	case *ModifyMessage:
+		req := ldap.NewModifyRequest(m.DN, nil)
+		for _, ch := range m.Changes {
+			vals, err := decodeModificationValues(ch.Modification.Vals)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			req.Changes = append(req.Changes, ldap.Change{
+				Operation:    uint(ch.Operation),
+				Modification: ldap.PartialAttribute{Type: ch.Modification.Type, Vals: vals},
+			})
+		}
+		pw.DN = m.DN
+		pw.forwardFn = func(c *UpstreamConn) error { return c.Modify(req) }
+	case *ModifyDNMessage:
+		req := ldap.NewModifyDNRequest(m.DN, m.NewRDN, m.DeleteOldRDN, m.NewSuperior)
+		pw.DN = m.DN
+		pw.forwardFn = func(c *UpstreamConn) error { return c.ModifyDN(req) }
+	case *DeleteMessage:
+		req := ldap.NewDelRequest(m.DN, nil)
+		pw.DN = m.DN
+		pw.forwardFn = func(c *UpstreamConn) error { return c.Del(req) }
+	default:
+		return nil
+	}
+	wt.mu.Lock()
+	wt.pending = append(wt.pending, pw)
+	wt.mu.Unlock()
+	if err := wt.Flush(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// forward forwards the write with a conn from the upstream pool
+func (wt *WriteThrough) forward(ctx context.Context, pw *PendingWrite) error {
+	const op = "gldap.(WriteThrough).forward"
+	c, err := wt.pool.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer c.Release()
+	if err := pw.forwardFn(c); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// isUpstreamUnavailable returns true if the error means a write couldn't be
+// forwarded and should be retried, rather than rejected by the upstream
+// server.
+func isUpstreamUnavailable(err error) bool {
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) {
+		// unable to get an upstream conn
+		return true
+	}
+	switch ldapErr.ResultCode {
+	case ldap.ErrorNetwork, ResultBusy, ResultUnavailable:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) Jim Lambert
+// SPDX-License-Identifier: MIT
+
+package gldap
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWriteThrough(t *testing.T) {
+	t.Parallel()
+	_, err := NewWriteThrough(nil)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestWriteThrough(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	// the upstream server records the writes it's forwarded in its changelog,
+	// and responds to adds with the addCode
+	var addCode atomic.Int64
+	upstreamChangelog, err := NewChangelog(0)
+	require.NoError(err)
+	upstreamMux, err := NewMux()
+	require.NoError(err)
+	require.NoError(upstreamMux.Add(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationAddResponse), WithResponseCode(int(addCode.Load()))))
+	}))
+	require.NoError(upstreamMux.Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(upstreamMux.ModifyDN(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyDNResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(upstreamMux.Delete(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(ResultSuccess)))
+	}))
+	_, upstreamURL := testServer(t, upstreamMux, WithChangelog(upstreamChangelog))
+
+	pool, err := NewUpstreamPool(upstreamURL)
+	require.NoError(err)
+	defer pool.Close()
+	wt, err := NewWriteThrough(pool)
+	require.NoError(err)
+
+	// the local server applies every write, except the deletes of bob
+	mux, err := NewMux()
+	require.NoError(err)
+	require.NoError(mux.Add(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationAddResponse), WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Modify(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.ModifyDN(func(w *ResponseWriter, r *Request) {
+		_ = w.Write(r.NewModifyDNResponse(WithResponseCode(ResultSuccess)))
+	}))
+	require.NoError(mux.Delete(func(w *ResponseWriter, r *Request) {
+		code := ResultSuccess
+		if m, err := r.GetDeleteMessage(); err != nil || m.DN == "cn=bob,dc=example,dc=org" {
+			code = ResultNoSuchObject
+		}
+		_ = w.Write(r.NewResponse(WithApplicationCode(ApplicationDelResponse), WithResponseCode(code)))
+	}))
+	_, url := testServer(t, mux, WithWriteThrough(wt))
+	client, err := ldap.DialURL(url)
+	require.NoError(err)
+	defer client.Close()
+
+	add := ldap.NewAddRequest("cn=alice,dc=example,dc=org", nil)
+	add.Attribute("cn", []string{"alice"})
+	require.NoError(client.Add(add))
+	mod := ldap.NewModifyRequest("cn=alice,dc=example,dc=org", nil)
+	mod.Replace("mail", []string{"alice@example.org"})
+	require.NoError(client.Modify(mod))
+	require.NoError(client.ModifyDN(ldap.NewModifyDNRequest("cn=alice,dc=example,dc=org", "cn=alicia", true, "")))
+	require.NoError(client.Del(ldap.NewDelRequest("cn=alicia,dc=example,dc=org", nil)))
+	// failed writes aren't forwarded
+	require.Error(client.Del(ldap.NewDelRequest("cn=bob,dc=example,dc=org", nil)))
+
+	type change struct {
+		dn         string
+		changeType ChangeType
+		changes    string
+	}
+	gotChanges := func() []change {
+		var changes []change
+		for _, c := range upstreamChangelog.Changes() {
+			changes = append(changes, change{dn: c.TargetDN, changeType: c.ChangeType, changes: c.Changes})
+		}
+		return changes
+	}
+	want := []change{
+		{dn: "cn=alice,dc=example,dc=org", changeType: ChangeTypeAdd, changes: "cn: alice\n"},
+		{dn: "cn=alice,dc=example,dc=org", changeType: ChangeTypeModify, changes: "replace: mail\nmail: alice@example.org\n-\n"},
+		{dn: "cn=alice,dc=example,dc=org", changeType: ChangeTypeModDN, changes: "newrdn: cn=alicia\ndeleteoldrdn: 1\n"},
+		{dn: "cn=alicia,dc=example,dc=org", changeType: ChangeTypeDelete},
+	}
+	assert.Equal(want, gotChanges())
+	assert.Empty(wt.Pending())
+
+	// writes are queued while the upstream server is unavailable, and
+	// subsequent writes are queued behind them
+	addCode.Store(ResultBusy)
+	add = ldap.NewAddRequest("cn=carol,dc=example,dc=org", nil)
+	add.Attribute("cn", []string{"carol"})
+	require.NoError(client.Add(add))
+	require.NoError(client.Del(ldap.NewDelRequest("cn=carol,dc=example,dc=org", nil)))
+	pending := wt.Pending()
+	require.Len(pending, 2)
+	assert.Equal(AddRouteOperation, pending[0].Operation)
+	assert.Equal("cn=carol,dc=example,dc=org", pending[0].DN)
+	var ldapErr *ldap.Error
+	require.ErrorAs(pending[0].Err, &ldapErr)
+	assert.Equal(uint16(ResultBusy), ldapErr.ResultCode)
+	assert.Equal(DeleteRouteOperation, pending[1].Operation)
+	assert.Len(gotChanges(), len(want))
+
+	addCode.Store(ResultSuccess)
+	require.NoError(wt.Flush(context.Background()))
+	assert.Empty(wt.Pending())
+	want = append(want,
+		change{dn: "cn=carol,dc=example,dc=org", changeType: ChangeTypeAdd, changes: "cn: carol\n"},
+		change{dn: "cn=carol,dc=example,dc=org", changeType: ChangeTypeDelete},
+	)
+	assert.Equal(want, gotChanges())
+
+	// writes rejected by the upstream server are dropped
+	addCode.Store(ResultEntryAlreadyExists)
+	add = ldap.NewAddRequest("cn=dave,dc=example,dc=org", nil)
+	add.Attribute("cn", []string{"dave"})
+	require.NoError(client.Add(add))
+	assert.Empty(wt.Pending())
+	assert.Equal(want, gotChanges())
+}